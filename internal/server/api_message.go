@@ -146,7 +146,7 @@ func (m *MessageAPI) sendMessageToChannel(req MessageSendReq, channelId string,
 		setting = setting.Set(wkproto.SettingStream)
 	}
 
-	ctx, span := trace.GlobalTrace.StartSpan(context.Background(), "recvMessageFromApi")
+	ctx, span := trace.GlobalTrace.StartChannelSpan(context.Background(), "recvMessageFromApi", fakeChannelId, fakeChannelType)
 	span.SetString("clientMsgNo", req.ClientMsgNo)
 
 	defer span.End()
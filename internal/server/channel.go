@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/WuKongIM/WuKongIM/pkg/wkdb"
 	"github.com/WuKongIM/WuKongIM/pkg/wklog"
@@ -47,6 +48,12 @@ type channel struct {
 
 	receiverTagKey atomic.String // 当前频道的接受者的tag key
 
+	destroyed atomic.Bool // 是否已销毁，销毁后不再参与reactor的遍历，并在安全点从reactor中移除
+	degraded  atomic.Bool // 是否已损坏（处理逻辑发生过panic）
+
+	isProxy           atomic.Bool  // 当前是否是代理角色
+	forwardQueueDepth atomic.Int64 // 待转发给领导的消息数量
+
 	wklog.Log
 
 	stepFnc func(*ChannelAction) error
@@ -305,6 +312,15 @@ func (c *channel) isInitialized() bool {
 	return c.status == channelStatusInitialized
 }
 
+// makeDestroy 标记频道已销毁（只在reactorSub的loop协程里调用）
+func (c *channel) makeDestroy() {
+	c.destroyed.Store(true)
+}
+
+func (c *channel) isDestroyed() bool {
+	return c.destroyed.Load()
+}
+
 func (c *channel) tick() {
 	c.storageTick++
 	c.initTick++
@@ -344,9 +360,59 @@ func (c *channel) proposeSend(ctx context.Context, fromUid string, fromDeviceId
 
 	c.sendTick = 0
 
+	if err := c.waitForwardQueue(ctx); err != nil {
+		return 0, err
+	}
+
+	message := c.newSendMessage(ctx, fromUid, fromDeviceId, fromConnId, fromNodeId, isEncrypt, sendPacket)
+	action := &ChannelAction{
+		UniqueNo:   c.uniqueNo,
+		ActionType: ChannelActionSend,
+		Messages:   []ReactorChannelMessage{message},
+	}
+	// 拒绝策略下事件队列满了不等待，直接返回ErrChannelReactorBusy，由调用方告诉客户端发送失败
+	if c.opts.Reactor.ForwardOverflowPolicy == ForwardOverflowReject {
+		if err := c.sub.tryStep(c, action); err != nil {
+			return 0, err
+		}
+		return message.MessageId, nil
+	}
+	c.sub.step(c, action)
+
+	return message.MessageId, nil
+}
+
+// proposeSendBatch 批量提案其他节点转发过来的消息，每条消息一个发送事件，整批一次提交给reactor并按顺序处理，
+// 等整批进入频道的消息队列后才返回（转发的节点收到成功响应时消息已经在领导上排队）
+func (c *channel) proposeSendBatch(messages []ReactorChannelMessage) error {
+	if len(messages) == 0 {
+		return nil
+	}
+
+	c.sendTick = 0
+
+	if err := c.waitForwardQueue(messages[0].ctx); err != nil {
+		return err
+	}
+
+	actions := make([]*ChannelAction, 0, len(messages))
+	for _, m := range messages {
+		message := c.newSendMessage(m.ctx, m.FromUid, m.FromDeviceId, m.FromConnId, m.FromNodeId, false, m.SendPacket)
+		actions = append(actions, &ChannelAction{
+			UniqueNo:   c.uniqueNo,
+			ActionType: ChannelActionSend,
+			Messages:   []ReactorChannelMessage{message},
+		})
+	}
+	return c.sub.stepBatchWait(c, actions, 0)
+}
+
+// newSendMessage 生成待发送的频道消息（分配消息ID）
+func (c *channel) newSendMessage(ctx context.Context, fromUid string, fromDeviceId string, fromConnId int64, fromNodeId uint64, isEncrypt bool, sendPacket *wkproto.SendPacket) ReactorChannelMessage {
 	messageId := c.r.messageIDGen.Generate().Int64() // 生成唯一消息ID
-	message := ReactorChannelMessage{
+	return ReactorChannelMessage{
 		ctx:          ctx,
+		proposeTime:  time.Now(),
 		FromConnId:   fromConnId,
 		FromUid:      fromUid,
 		FromDeviceId: fromDeviceId,
@@ -356,14 +422,6 @@ func (c *channel) proposeSend(ctx context.Context, fromUid string, fromDeviceId
 		IsEncrypt:    isEncrypt,
 		ReasonCode:   wkproto.ReasonSuccess, // 初始状态为成功
 	}
-
-	c.sub.step(c, &ChannelAction{
-		UniqueNo:   c.uniqueNo,
-		ActionType: ChannelActionSend,
-		Messages:   []ReactorChannelMessage{message},
-	})
-
-	return messageId, nil
 }
 
 func (c *channel) becomeLeader() {
@@ -436,6 +494,11 @@ type ready struct {
 // 该方法用于为频道创建一个接收者标签，用于标识频道的订阅者及其所在的节点
 // 返回创建的标签和可能的错误
 func (c *channel) makeReceiverTag() (*tag, error) {
+	return c.makeReceiverTagContext(context.Background())
+}
+
+// makeReceiverTagContext 同makeReceiverTag，ctx结束后不再更新频道的接收者标签，返回ctx的错误
+func (c *channel) makeReceiverTagContext(ctx context.Context) (*tag, error) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
@@ -500,6 +563,11 @@ func (c *channel) makeReceiverTag() (*tag, error) {
 		}
 	}
 
+	// 调用方已经放弃（例如初始化超时），不再修改频道状态
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	// 释放旧的接收者标签（如果存在）
 	if c.receiverTagKey.Load() != "" {
 		c.r.s.tagManager.releaseReceiverTag(c.receiverTagKey.Load())
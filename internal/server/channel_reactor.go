@@ -2,6 +2,7 @@ package server
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"hash/fnv"
 
@@ -38,6 +39,10 @@ type channelReactor struct {
 	loadChannMu deadlock.RWMutex
 
 	stopped atomic.Bool
+
+	forwardQueueSize atomic.Int64 // 节点上代理频道待转发的消息总数
+
+	deliverQueueDepth atomic.Int64 // 投递阶段（包括投递者）排队中的消息数量
 }
 
 func newChannelReactor(s *Server, opts *Options) *channelReactor {
@@ -135,6 +140,24 @@ func (r *channelReactor) proposeSend(ctx context.Context, fromUid string, fromDe
 	// 处理消息
 	_, err := ch.proposeSend(ctx, fromUid, fromDeviceId, fromConnId, fromNodeId, isEncrypt, packet)
 	if err != nil {
+		if (errors.Is(err, ErrForwardQueueFull) || errors.Is(err, ErrChannelReactorBusy)) && fromNodeId == r.opts.Cluster.NodeId && fromUid != r.opts.SystemUID {
+			// 转发队列或频道事件队列满了，直接告诉客户端发送失败
+			sendack := &wkproto.SendackPacket{
+				Framer:      packet.Framer,
+				ClientSeq:   packet.ClientSeq,
+				ClientMsgNo: packet.ClientMsgNo,
+				ReasonCode:  wkproto.ReasonSystemError,
+			}
+			if werr := r.s.userReactor.writePacketByConnId(fromUid, fromConnId, sendack); werr != nil {
+				r.Error("writePacketByConnId error", zap.Error(werr), zap.Int64("connId", fromConnId))
+			}
+			if errors.Is(err, ErrChannelReactorBusy) {
+				r.Warn("channel step queue is full", zap.String("channelId", fakeChannelId), zap.Uint8("channelType", channelType), zap.Int("channelStepQueueSize", r.opts.Reactor.ChannelStepQueueSize))
+			} else {
+				r.Warn("forward queue is full", zap.String("channelId", fakeChannelId), zap.Uint8("channelType", channelType), zap.Int64("forwardQueueSize", r.forwardQueueSize.Load()))
+			}
+			return err
+		}
 		r.Error("proposeSend error", zap.Error(err))
 		return err
 	}
@@ -148,7 +171,11 @@ func (r *channelReactor) loadOrCreateChannel(fakeChannelId string, channelType u
 	sub := r.reactorSub(channelKey)
 	ch := sub.channel(channelKey)
 	if ch != nil {
-		return ch
+		if !ch.isDestroyed() {
+			return ch
+		}
+		// 已销毁还没来得及移除的频道，先移除再创建新的
+		sub.removeChannel(ch)
 	}
 
 	ch = newChannel(sub, fakeChannelId, channelType)
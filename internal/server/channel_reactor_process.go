@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/WuKongIM/WuKongIM/pkg/cluster/reactor"
 	"github.com/WuKongIM/WuKongIM/pkg/trace"
 	"github.com/WuKongIM/WuKongIM/pkg/wkdb"
 	"github.com/WuKongIM/WuKongIM/pkg/wkserver/proto"
@@ -35,38 +36,80 @@ func (r *channelReactor) processInitLoop() {
 }
 
 func (r *channelReactor) processInit(req *initReq) {
-	timeoutCtx, cancel := context.WithTimeout(r.s.ctx, time.Second*5)
-	defer cancel()
-	node, err := r.s.cluster.LeaderOfChannel(timeoutCtx, req.ch.channelId, req.ch.channelType)
 	sub := r.reactorSub(req.ch.key)
+	leaderId, err := r.initChannelWithTimeout(req.ch)
 	if err != nil {
-		r.Error("channel init failed", zap.Error(err))
-		sub.step(req.ch, &ChannelAction{
-			UniqueNo:   req.ch.uniqueNo,
-			ActionType: ChannelActionInitResp,
-			Reason:     ReasonError,
-		})
-		return
-	}
-	_, err = req.ch.makeReceiverTag()
-	if err != nil {
-		r.Error("processInit: makeReceiverTag failed", zap.Error(err))
+		if errors.Is(err, ErrChannelInitTimeout) {
+			// 存储卡住了，标记频道为损坏，不再阻塞初始化协程，等下次初始化重试
+			req.ch.degraded.Store(true)
+		}
+		r.Error("channel init failed", zap.Error(err), zap.String("channelId", req.ch.channelId), zap.Uint8("channelType", req.ch.channelType))
 		sub.step(req.ch, &ChannelAction{
 			UniqueNo:   req.ch.uniqueNo,
 			ActionType: ChannelActionInitResp,
-			LeaderId:   node.Id,
+			LeaderId:   leaderId,
 			Reason:     ReasonError,
 		})
 		return
 	}
+	req.ch.degraded.Store(false)
 	sub.step(req.ch, &ChannelAction{
 		UniqueNo:   req.ch.uniqueNo,
 		ActionType: ChannelActionInitResp,
-		LeaderId:   node.Id,
+		LeaderId:   leaderId,
 		Reason:     ReasonSuccess,
 	})
 }
 
+type initResult struct {
+	leaderId uint64
+	err      error
+}
+
+// initChannelWithTimeout 初始化频道，超过StorageOpenTimeout还没完成则返回ErrChannelInitTimeout，避免存储卡住导致初始化协程一直阻塞
+// 超时或停止后取消初始化协程的ctx，初始化协程退出且不再修改频道
+func (r *channelReactor) initChannelWithTimeout(ch *channel) (uint64, error) {
+	timeout := r.opts.Reactor.StorageOpenTimeout
+	if timeout <= 0 {
+		return r.initChannel(r.s.ctx, ch)
+	}
+	ctx, cancel := context.WithCancel(r.s.ctx)
+	defer cancel()
+	resultC := make(chan initResult, 1)
+	go func() {
+		leaderId, err := r.initChannel(ctx, ch)
+		resultC <- initResult{leaderId: leaderId, err: err}
+	}()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case result := <-resultC:
+		return result.leaderId, result.err
+	case <-timer.C:
+		return 0, fmt.Errorf("%w: %s", ErrChannelInitTimeout, timeout)
+	case <-r.stopper.ShouldStop():
+		return 0, ErrReactorStopped
+	}
+}
+
+func (r *channelReactor) initChannel(ctx context.Context, ch *channel) (uint64, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, time.Second*5)
+	defer cancel()
+	node, err := r.s.cluster.LeaderOfChannel(timeoutCtx, ch.channelId, ch.channelType)
+	if err != nil {
+		return 0, err
+	}
+	if err = ctx.Err(); err != nil {
+		return 0, err
+	}
+	_, err = ch.makeReceiverTagContext(ctx)
+	if err != nil {
+		r.Error("processInit: makeReceiverTag failed", zap.Error(err))
+		return node.Id, err
+	}
+	return node.Id, nil
+}
+
 type initReq struct {
 	ch *channel
 }
@@ -219,7 +262,7 @@ func (r *channelReactor) processForward(reqs []*forwardReq) {
 
 			spans := make([]trace.Span, 0, len(req.messages))
 			for _, msg := range req.messages {
-				_, span := trace.GlobalTrace.StartSpan(msg.ctx, "processForward")
+				_, span := trace.GlobalTrace.StartChannelSpan(msg.ctx, "processForward", req.ch.channelId, req.ch.channelType)
 				span.SetUint64("leaderId", req.leaderId)
 				spans = append(spans, span)
 			}
@@ -242,22 +285,23 @@ func (r *channelReactor) processForward(reqs []*forwardReq) {
 		} else {
 			reason = ReasonSuccess
 		}
+		actions := make([]*ChannelAction, 0, 2)
 		if newLeaderId > 0 {
 			r.Info("leader change", zap.Uint64("newLeaderId", newLeaderId), zap.Uint64("oldLeaderId", req.leaderId), zap.String("channelId", req.ch.channelId), zap.Uint8("channelType", req.ch.channelType))
-			sub := r.reactorSub(req.ch.key)
-			sub.step(req.ch, &ChannelAction{
+			actions = append(actions, &ChannelAction{
 				UniqueNo:   req.ch.uniqueNo,
 				ActionType: ChannelActionLeaderChange,
 				LeaderId:   newLeaderId,
 			})
 		}
-		sub := r.reactorSub(req.ch.key)
-		sub.step(req.ch, &ChannelAction{
+		actions = append(actions, &ChannelAction{
 			UniqueNo:   req.ch.uniqueNo,
 			ActionType: ChannelActionForwardResp,
 			Messages:   req.messages,
 			Reason:     reason,
 		})
+		// 领导变更和转发结果一次提交，reactor按顺序处理
+		r.reactorSub(req.ch.key).stepBatch(req.ch, actions)
 
 	}
 
@@ -666,10 +710,14 @@ func (r *channelReactor) processStorage(reqs []*storageReq) {
 			}
 			sotreMessages = append(sotreMessages, msg)
 
-			_, span := trace.GlobalTrace.StartSpan(reactorMsg.ctx, "storeMessages")
+			_, span := trace.GlobalTrace.StartChannelSpan(reactorMsg.ctx, "storeMessages", req.ch.channelId, req.ch.channelType)
 			spans = append(spans, span)
 		}
 
+		if len(messages) > 0 {
+			trace.GlobalTrace.Metrics.App().ChannelMessageCountAdd(req.ch.channelId, req.ch.channelType, int64(len(messages)))
+		}
+
 		reason := ReasonSuccess
 		if len(sotreMessages) > 0 {
 			// 存储消息
@@ -678,7 +726,11 @@ func (r *channelReactor) processStorage(reqs []*storageReq) {
 			} else {
 				r.Debug("store messages", zap.Int("msgCount", len(sotreMessages)), zap.String("channelId", req.ch.channelId), zap.Uint8("channelType", req.ch.channelType))
 			}
-			results, err := r.s.store.AppendMessages(r.s.ctx, req.ch.channelId, req.ch.channelType, sotreMessages)
+			storeCtx := r.s.ctx
+			if r.opts.IsCmdChannel(req.ch.channelId) { // 命令消息（撤回、配置变更等）优先于普通消息追加
+				storeCtx = reactor.WithProposePriority(storeCtx, reactor.ProposePriorityHigh)
+			}
+			results, err := r.s.store.AppendMessagesWithResults(storeCtx, req.ch.channelId, req.ch.channelType, sotreMessages)
 			if err != nil {
 				r.Error("AppendMessages error", zap.Error(err))
 			}
@@ -688,27 +740,31 @@ func (r *channelReactor) processStorage(reqs []*storageReq) {
 				reason = ReasonSuccess
 			}
 
-			for _, span := range spans {
+			for i, span := range spans {
 				span.SetInt("msgCount", len(sotreMessages))
 				if err != nil {
 					span.RecordError(err)
+				} else if i < len(results) && results[i].Err != nil {
+					span.RecordError(results[i].Err)
 				}
 				span.End()
 			}
 
-			if len(results) > 0 {
-				for _, result := range results {
-					msgLen := len(req.messages)
-					logId := int64(result.LogId())
-					logIndex := uint32(result.LogIndex())
-					for i := 0; i < msgLen; i++ {
-						msg := req.messages[i]
-						if msg.MessageId == logId {
-							msg.MessageId = logId
-							msg.MessageSeq = logIndex
-							req.messages[i] = msg
-							break
+			// 每条消息单独处理结果，单条消息追加失败（例如消息过大）不影响同批次的其他消息
+			for _, result := range results {
+				msgLen := len(req.messages)
+				logId := int64(result.Id)
+				for i := 0; i < msgLen; i++ {
+					msg := req.messages[i]
+					if msg.MessageId == logId {
+						if result.Err != nil {
+							r.Warn("append message failed", zap.Error(result.Err), zap.Int64("messageId", logId), zap.String("channelId", req.ch.channelId), zap.Uint8("channelType", req.ch.channelType))
+							msg.ReasonCode = wkproto.ReasonSystemError
+						} else {
+							msg.MessageSeq = uint32(result.Index)
 						}
+						req.messages[i] = msg
+						break
 					}
 				}
 			}
@@ -800,6 +856,10 @@ func (r *channelReactor) processSendackLoop() {
 					done = true
 				}
 			}
+			// 开启了回执合并，则在窗口时间内继续收集请求
+			if r.opts.Reactor.SendackBatchWindow > 0 {
+				reqs = r.collectSendackReqs(reqs, r.opts.Reactor.SendackBatchWindow)
+			}
 			r.processSendack(reqs)
 
 			reqs = reqs[:0]
@@ -810,9 +870,30 @@ func (r *channelReactor) processSendackLoop() {
 	}
 }
 
+// 在窗口时间内收集更多的回执请求
+func (r *channelReactor) collectSendackReqs(reqs []*sendackReq, window time.Duration) []*sendackReq {
+	timer := time.NewTimer(window)
+	defer timer.Stop()
+	for {
+		select {
+		case req := <-r.processSendackC:
+			reqs = append(reqs, req)
+		case <-timer.C:
+			return reqs
+		case <-r.stopper.ShouldStop():
+			return reqs
+		}
+	}
+}
+
 func (r *channelReactor) processSendack(reqs []*sendackReq) {
 	var err error
 	nodeFowardSendackPacketMap := map[uint64][]*ForwardSendackPacket{}
+	batching := r.opts.Reactor.SendackBatchWindow > 0
+	var connSendackMap map[sendackConnKey][]wkproto.Frame // 开启回执合并时，本节点连接的回执按连接分组
+	if batching {
+		connSendackMap = map[sendackConnKey][]wkproto.Frame{}
+	}
 	for _, req := range reqs {
 		for _, msg := range req.messages {
 
@@ -831,6 +912,12 @@ func (r *channelReactor) processSendack(reqs []*sendackReq) {
 				ReasonCode:  msg.ReasonCode,
 			}
 			if msg.FromNodeId == r.opts.Cluster.NodeId { // 连接在本节点
+				if batching {
+					connKey := sendackConnKey{uid: msg.FromUid, connId: msg.FromConnId}
+					connSendackMap[connKey] = append(connSendackMap[connKey], sendack)
+					span.End()
+					continue
+				}
 				err = r.s.userReactor.writePacketByConnId(msg.FromUid, msg.FromConnId, sendack)
 				if err != nil {
 					r.Error("writePacketByConnId error", zap.Error(err), zap.Uint64("nodeId", msg.FromNodeId), zap.Int64("connId", msg.FromConnId))
@@ -856,6 +943,21 @@ func (r *channelReactor) processSendack(reqs []*sendackReq) {
 		})
 	}
 
+	if batching {
+		sendackCount := 0
+		for connKey, sendacks := range connSendackMap {
+			sendackCount += len(sendacks)
+			err = r.s.userReactor.writePacketsByConnId(connKey.uid, connKey.connId, sendacks)
+			if err != nil {
+				r.Error("writePacketsByConnId error", zap.Error(err), zap.String("uid", connKey.uid), zap.Int64("connId", connKey.connId))
+			}
+		}
+		if sendackCount > 0 {
+			trace.GlobalTrace.Metrics.App().SendackCoalescedWriteCountAdd(int64(len(connSendackMap)))
+			trace.GlobalTrace.Metrics.App().SendackSavedWriteCountAdd(int64(sendackCount - len(connSendackMap)))
+		}
+	}
+
 	for nodeId, forwardSendackPackets := range nodeFowardSendackPacketMap {
 		err = r.requestForwardSendack(nodeId, forwardSendackPackets)
 		if err != nil {
@@ -864,6 +966,11 @@ func (r *channelReactor) processSendack(reqs []*sendackReq) {
 	}
 }
 
+type sendackConnKey struct {
+	uid    string
+	connId int64
+}
+
 func (r *channelReactor) requestForwardSendack(nodeId uint64, packets []*ForwardSendackPacket) error {
 	timeoutCtx, cancel := context.WithTimeout(r.s.ctx, time.Second*5)
 	defer cancel()
@@ -896,12 +1003,24 @@ type sendackReq struct {
 
 // =================================== 消息投递 ===================================
 
-func (r *channelReactor) addDeliverReq(req *deliverReq) {
+// addDeliverReq 添加投递请求，投递队列满了返回false（不阻塞reactor，由频道暂停投递等下次再试）
+func (r *channelReactor) addDeliverReq(req *deliverReq) bool {
 	select {
 	case r.processDeliverC <- req:
-	case <-r.stopper.ShouldStop():
+		r.deliverQueueDepthAdd(req.channelType, int64(len(req.messages)))
+		return true
+	default:
+		return false
+	}
+}
+
+// deliverQueueDepthAdd 投递阶段排队中的消息数量变化
+func (r *channelReactor) deliverQueueDepthAdd(channelType uint8, v int64) {
+	if v == 0 {
 		return
 	}
+	r.deliverQueueDepth.Add(v)
+	trace.GlobalTrace.Metrics.App().DeliverQueueDepthAdd(channelType, v)
 }
 
 func (r *channelReactor) processDeliverLoop() {
@@ -955,20 +1074,33 @@ func (r *channelReactor) processDeliver(reqs []*deliverReq) {
 			deliverMessages = append(deliverMessages, msg)
 		}
 
+		r.deliverQueueDepthAdd(req.channelType, -int64(len(req.messages)-len(deliverMessages))) // 不需要投递的消息
 		req.messages = deliverMessages
 
+		reason := ReasonSuccess
 		if len(deliverMessages) > 0 {
+			spans := startDeliverSpans(req.channelId, req.channelType, deliverMessages)
 			if len(deliverMessages) == 1 {
 				r.Debug("deliver message", zap.Uint64("messageId", uint64(req.messages[0].MessageId)), zap.String("channelId", req.channelId), zap.Uint8("channelType", req.channelType))
 			} else {
 				r.Debug("deliver messages", zap.Int("msgCount", len(req.messages)), zap.String("channelId", req.channelId), zap.Uint8("channelType", req.channelType))
 			}
 			// 投递消息
-			r.handleDeliver(req)
+			if !r.handleDeliver(req) {
+				// 投递者的队列都满了，消息留在频道里，频道等下个处理间隔再投递
+				r.Warn("deliver queue is full, pause channel deliver", zap.String("channelId", req.channelId), zap.Uint8("channelType", req.channelType), zap.Int("msgCount", len(deliverMessages)))
+				r.deliverQueueDepthAdd(req.channelType, -int64(len(deliverMessages)))
+				trace.GlobalTrace.Metrics.App().DeliverBackpressureCountAdd(req.channelType, 1)
+				reason = ReasonError
+				lastIndex = 0
+				for _, span := range spans {
+					span.RecordError(errors.New("deliver queue is full"))
+					span.End()
+				}
+			}
 		}
 
 		sub := r.reactorSub(req.ch.key)
-		reason := ReasonSuccess
 
 		sub.step(req.ch, &ChannelAction{
 			UniqueNo:   req.ch.uniqueNo,
@@ -979,8 +1111,8 @@ func (r *channelReactor) processDeliver(reqs []*deliverReq) {
 	}
 }
 
-func (r *channelReactor) handleDeliver(req *deliverReq) {
-	r.s.deliverManager.deliver(req)
+func (r *channelReactor) handleDeliver(req *deliverReq) bool {
+	return r.s.deliverManager.deliver(req)
 }
 
 type deliverReq struct {
@@ -1015,10 +1147,8 @@ func (r *channelReactor) processCloseLoop() {
 
 func (r *channelReactor) processClose(req *closeReq) {
 
+	// 频道已在reactorSub的loop里标记销毁并移除，这里只做记录
 	r.Info("channel close", zap.String("channelId", req.ch.channelId), zap.Uint8("channelType", req.ch.channelType))
-
-	sub := r.reactorSub(req.ch.key)
-	sub.removeChannel(req.ch.key)
 }
 
 type closeReq struct {
@@ -1095,3 +1225,26 @@ func (r *channelReactor) processCheckTag(req *checkTagReq) {
 type checkTagReq struct {
 	ch *channel
 }
+
+// startDeliverSpans 给每条要投递的消息开始一个deliver span，span接在消息的提案链路上并链接到提案span，
+// 然后把消息的ctx换成deliver span的ctx，之后投递（包括转发给其他节点投递）产生的span都挂在deliver span下。
+// 投递失败重试时新的deliver span仍然从提案链路开始，和上一次尝试是兄弟span，不会挂在上一次尝试下。
+// deliver span在投递者扇出完成后结束（见deliverr.handleDeliverReqs）
+func startDeliverSpans(channelId string, channelType uint8, messages []ReactorChannelMessage) []trace.Span {
+	spans := make([]trace.Span, 0, len(messages))
+	for i, msg := range messages {
+		parent := msg.proposeCtx
+		if parent == nil {
+			parent = msg.ctx
+			messages[i].proposeCtx = parent
+		}
+		ctx, span := trace.GlobalTrace.StartChannelSpanWithLinks(parent, "deliver", channelId, channelType, trace.SpanContextFromContext(parent))
+		span.SetInt64("messageId", msg.MessageId)
+		if !msg.proposeTime.IsZero() {
+			span.SetInt64("proposeToDeliverMs", time.Since(msg.proposeTime).Milliseconds())
+		}
+		messages[i].ctx = ctx
+		spans = append(spans, span)
+	}
+	return spans
+}
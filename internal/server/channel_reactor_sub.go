@@ -1,10 +1,16 @@
 package server
 
 import (
+	"context"
+	"errors"
+	"runtime/debug"
 	"time"
 
+	"github.com/WuKongIM/WuKongIM/pkg/trace"
+	"github.com/WuKongIM/WuKongIM/pkg/wklog"
 	"github.com/lni/goutils/syncutil"
 	"go.uber.org/atomic"
+	"go.uber.org/zap"
 )
 
 type channelReactorSub struct {
@@ -13,6 +19,13 @@ type channelReactorSub struct {
 	channelQueue *channelList
 	stopped      atomic.Bool
 
+	// 有待处理事件的频道（只在loop协程内读写），readys只遍历这些频道，全量遍历只在tick时进行
+	dirtyChannels map[string]*channel
+	needFullScan  bool // 下次readys是否需要全量遍历
+
+	// 已标记销毁待移除的频道（只在loop协程内读写），在遍历结束后的安全点统一移除
+	destroyedChannels []*channel
+
 	advanceC     chan struct{}
 	stepChannelC chan stepChannel
 	r            *channelReactor
@@ -21,12 +34,13 @@ type channelReactorSub struct {
 
 func newChannelReactorSub(index int, r *channelReactor) *channelReactorSub {
 	return &channelReactorSub{
-		stopper:      syncutil.NewStopper(),
-		channelQueue: newChannelList(),
-		advanceC:     make(chan struct{}, 1),
-		stepChannelC: make(chan stepChannel, 1024*10),
-		r:            r,
-		index:        index,
+		stopper:       syncutil.NewStopper(),
+		channelQueue:  newChannelList(),
+		dirtyChannels: make(map[string]*channel),
+		advanceC:      make(chan struct{}, 1),
+		stepChannelC:  make(chan stepChannel, r.opts.Reactor.ChannelStepQueueSize),
+		r:             r,
+		index:         index,
 	}
 }
 
@@ -48,14 +62,20 @@ func (r *channelReactorSub) loop() {
 
 	for !r.stopped.Load() {
 		r.readys()
+		r.removeDestroyed()
 		select {
 		case <-tk.C:
 			r.ticks()
+			r.needFullScan = true
 		case <-r.advanceC:
 		case req := <-r.stepChannelC:
 			var err error
 			if req.ch != nil {
-				err = req.ch.step(req.action)
+				if len(req.actions) > 0 {
+					err = r.stepChannel(req.ch, req.actions...)
+				} else {
+					err = r.stepChannel(req.ch, req.action)
+				}
 			}
 			if req.waitC != nil {
 				req.waitC <- err
@@ -66,12 +86,111 @@ func (r *channelReactorSub) loop() {
 	}
 }
 
+// stepChannel 按顺序处理频道的事件，一批事件处理完后才更新频道的转发队列和标记待处理，返回第一个错误（后面的事件继续处理）
+func (r *channelReactorSub) stepChannel(ch *channel, actions ...*ChannelAction) (err error) {
+	destroyed := ch.isDestroyed()
+	stepped := false
+	defer func() {
+		if p := recover(); p != nil {
+			r.onChannelPanic(ch, p)
+			err = ErrChannelPanic
+		}
+	}()
+	for _, action := range actions {
+		if destroyed {
+			// 频道已销毁（提案时拿到的是销毁前的频道），发送的消息转给新的频道，其他的结果直接丢弃
+			if action.ActionType != ChannelActionSend {
+				if err == nil {
+					err = ErrChannelDestroyed
+				}
+				continue
+			}
+			if ch.isDestroyed() {
+				ch = r.r.loadOrCreateChannel(ch.channelId, ch.channelType)
+			}
+			action.UniqueNo = ch.uniqueNo
+		}
+		if stepErr := ch.step(action); stepErr != nil && err == nil {
+			err = stepErr
+		}
+		stepped = true
+	}
+	if stepped {
+		ch.updateForwardQueueDepth()
+		r.markDirty(ch)
+	}
+	return err
+}
+
 func (r *channelReactorSub) step(ch *channel, action *ChannelAction) {
+	r.submit(stepChannel{ch: ch, action: action})
+}
+
+// tryStep 提交频道事件，事件队列满了时不等待，直接返回ErrChannelReactorBusy
+func (r *channelReactorSub) tryStep(ch *channel, action *ChannelAction) error {
 	select {
 	case r.stepChannelC <- stepChannel{ch: ch, action: action}:
+		return nil
+	case <-r.stopper.ShouldStop():
+		return ErrReactorStopped
+	default:
+		trace.GlobalTrace.Metrics.App().ChannelStepQueueFullCountAdd(1)
+		return ErrChannelReactorBusy
+	}
+}
+
+// submit 提交事件到事件队列，队列满了时记录一次并等待队列有空位
+func (r *channelReactorSub) submit(req stepChannel) {
+	select {
+	case r.stepChannelC <- req:
+		return
+	default:
+	}
+	trace.GlobalTrace.Metrics.App().ChannelStepQueueFullCountAdd(1)
+	select {
+	case r.stepChannelC <- req:
 	case <-r.stopper.ShouldStop():
+	}
+}
+
+// stepBatch 一次提交同一个频道的多个事件（只发送一次），reactor按顺序处理
+func (r *channelReactorSub) stepBatch(ch *channel, actions []*ChannelAction) {
+	if len(actions) == 0 {
 		return
 	}
+	r.submit(stepChannel{ch: ch, actions: actions})
+}
+
+// stepBatchWait 提交同一个频道的多个事件并等待整批处理完成，返回第一个错误
+// timeout为等待的超时时间，小于等于0时使用Reactor.StepWaitTimeout，超时返回ErrChannelStepWaitTimeout
+func (r *channelReactorSub) stepBatchWait(ch *channel, actions []*ChannelAction, timeout time.Duration) error {
+	if len(actions) == 0 {
+		return nil
+	}
+	waitC := make(chan error, 1)
+	select {
+	case r.stepChannelC <- stepChannel{ch: ch, actions: actions, waitC: waitC}:
+	case <-r.stopper.ShouldStop():
+		return ErrReactorStopped
+	}
+
+	if timeout <= 0 {
+		timeout = r.r.opts.Reactor.StepWaitTimeout
+	}
+	timeoutCtx, cancel := context.WithTimeout(r.r.s.ctx, timeout)
+	defer cancel()
+
+	select {
+	case err := <-waitC:
+		return err
+	case <-timeoutCtx.Done():
+		if errors.Is(timeoutCtx.Err(), context.DeadlineExceeded) {
+			return ErrChannelStepWaitTimeout
+		}
+		return timeoutCtx.Err()
+	case <-r.stopper.ShouldStop():
+		return ErrReactorStopped
+	}
 }
 
 // func (r *channelReactorSub) stepWait(ch *channel, action *ChannelAction) error {
@@ -107,29 +226,89 @@ func (r *channelReactorSub) step(ch *channel, action *ChannelAction) {
 
 func (r *channelReactorSub) readys() {
 
-	r.channelQueue.iter(func(ch *channel) {
+	// tick后全量遍历，因为tick会推进频道内部的计时（比如初始化、重试等），这些不会经过step
+	if r.needFullScan {
+		r.needFullScan = false
+		for key := range r.dirtyChannels {
+			delete(r.dirtyChannels, key)
+		}
+		r.channelQueue.iter(func(ch *channel) {
+			if r.stopped.Load() || ch.isDestroyed() {
+				return
+			}
+			r.handleReadyIfNeed(ch)
+		})
+		return
+	}
+
+	// 只遍历有待处理事件的频道
+	for key, ch := range r.dirtyChannels {
 		if r.stopped.Load() {
 			return
 		}
-		if ch.hasReady() {
-			r.handleReady(ch)
+		delete(r.dirtyChannels, key)
+		if ch.isDestroyed() {
+			continue
 		}
-	})
+		r.handleReadyIfNeed(ch)
+	}
+}
+
+// 标记频道有待处理的事件
+func (r *channelReactorSub) markDirty(ch *channel) {
+	r.dirtyChannels[ch.key] = ch
 }
 
 func (r *channelReactorSub) ticks() {
 	r.channelQueue.iter(func(ch *channel) {
-		if r.stopped.Load() {
+		if r.stopped.Load() || ch.isDestroyed() {
 			return
 		}
-		ch.tick()
+		r.tickChannel(ch)
 	})
 }
 
+func (r *channelReactorSub) tickChannel(ch *channel) {
+	defer func() {
+		if p := recover(); p != nil {
+			r.onChannelPanic(ch, p)
+		}
+	}()
+	ch.tick()
+}
+
+func (r *channelReactorSub) handleReadyIfNeed(ch *channel) {
+	defer func() {
+		if p := recover(); p != nil {
+			r.onChannelPanic(ch, p)
+		}
+	}()
+	if ch.hasReady() {
+		r.handleReady(ch)
+	}
+}
+
+// onChannelPanic 频道处理逻辑panic，标记频道损坏并销毁（后续的消息会创建新的频道），不影响reactor和其他频道
+func (r *channelReactorSub) onChannelPanic(ch *channel, p interface{}) {
+	r.r.Error("channel panic", zap.String("channelId", ch.channelId), zap.Uint8("channelType", ch.channelType), zap.Any("panic", p), zap.ByteString("stack", debug.Stack()))
+	ch.degraded.Store(true)
+	if !ch.isDestroyed() {
+		ch.makeDestroy()
+		r.destroyedChannels = append(r.destroyedChannels, ch)
+	}
+	if r.r.opts.Reactor.PanicHandler != nil {
+		r.r.opts.Reactor.PanicHandler(ch.channelId, ch.channelType, p)
+	}
+}
+
 func (r *channelReactorSub) handleReady(ch *channel) {
 	rd := ch.ready()
 
 	for _, action := range rd.actions {
+		trace.GlobalTrace.Metrics.App().ChannelActionCountAdd(action.ActionType.String(), 1)
+		if wklog.DebugEnabled() {
+			r.r.Debug("channel action", zap.String("actionType", action.ActionType.String()), zap.String("channelId", ch.channelId), zap.Uint8("channelType", ch.channelType), zap.Int("messages", len(action.Messages)))
+		}
 		switch action.ActionType {
 		case ChannelActionInit: // 初始化
 			r.r.addInitReq(&initReq{
@@ -151,13 +330,18 @@ func (r *channelReactorSub) handleReady(ch *channel) {
 				messages: action.Messages,
 			})
 		case ChannelActionDeliver: // 消息投递
-			r.r.addDeliverReq(&deliverReq{
+			ok := r.r.addDeliverReq(&deliverReq{
 				ch:          ch,
 				channelId:   ch.channelId,
 				channelType: ch.channelType,
 				tagKey:      ch.receiverTagKey.Load(),
 				messages:    action.Messages,
 			})
+			if !ok {
+				// 投递队列满了，暂停这个频道的投递，消息留在队列里，等下个处理间隔再投递（不阻塞reactor）
+				ch.delivering = false
+				trace.GlobalTrace.Metrics.App().DeliverBackpressureCountAdd(ch.channelType, 1)
+			}
 		case ChannelActionSendack: // 发送回执
 			r.r.addSendackReq(&sendackReq{
 				ch:       ch,
@@ -170,6 +354,9 @@ func (r *channelReactorSub) handleReady(ch *channel) {
 				leaderId: action.LeaderId,
 			})
 		case ChannelActionClose:
+			// 这里可能在遍历频道队列中，只做标记，遍历结束后再移除
+			ch.makeDestroy()
+			r.destroyedChannels = append(r.destroyedChannels, ch)
 			r.r.addCloseReq(&closeReq{
 				ch: ch,
 			})
@@ -190,8 +377,24 @@ func (r *channelReactorSub) addChannel(ch *channel) {
 	r.channelQueue.add(ch)
 }
 
-func (r *channelReactorSub) removeChannel(key string) {
-	r.channelQueue.remove(key)
+func (r *channelReactorSub) removeChannel(ch *channel) {
+	r.channelQueue.removeChannel(ch)
+}
+
+// removeDestroyed 移除已标记销毁的频道，只能在loop协程内且不在遍历中调用
+func (r *channelReactorSub) removeDestroyed() {
+	if len(r.destroyedChannels) == 0 {
+		return
+	}
+	for i, ch := range r.destroyedChannels {
+		r.removeChannel(ch)
+		if r.dirtyChannels[ch.key] == ch {
+			delete(r.dirtyChannels, ch.key)
+		}
+		ch.releaseForwardQueueDepth()
+		r.destroyedChannels[i] = nil
+	}
+	r.destroyedChannels = r.destroyedChannels[:0]
 }
 
 // func (r *channelReactorSub) advance() {
@@ -202,7 +405,8 @@ func (r *channelReactorSub) removeChannel(key string) {
 // }
 
 type stepChannel struct {
-	ch     *channel
-	action *ChannelAction
-	waitC  chan error
+	ch      *channel
+	action  *ChannelAction
+	actions []*ChannelAction // 批量提交的事件，不为空时忽略action
+	waitC   chan error
 }
@@ -0,0 +1,555 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/WuKongIM/WuKongIM/pkg/cluster/clusterconfig/pb"
+	"github.com/WuKongIM/WuKongIM/pkg/cluster/icluster"
+	wkproto "github.com/WuKongIM/WuKongIMGoProto"
+	"github.com/stretchr/testify/assert"
+)
+
+// 频道在reactor loop遍历的同时被销毁，不能出现并发问题（需要 -race 运行）
+func TestChannelReactorSubDestroyWhileIterating(t *testing.T) {
+	setupTestTrace(t)
+
+	opts := NewOptions()
+	opts.Reactor.ChannelSubCount = 1
+	opts.Reactor.ChannelDeadlineTick = 1 // 每次tick都关闭频道
+
+	r := newChannelReactor(nil, opts)
+	sub := r.subs[0]
+
+	stopC := make(chan struct{})
+	var drainWg sync.WaitGroup
+	drainWg.Add(1)
+	go func() {
+		defer drainWg.Done()
+		for {
+			select {
+			case <-r.processInitC:
+			case <-r.processCloseC:
+			case <-stopC:
+				return
+			}
+		}
+	}()
+
+	err := sub.start()
+	assert.NoError(t, err)
+
+	var wg sync.WaitGroup
+	deadline := time.Now().Add(time.Second)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for n := 0; time.Now().Before(deadline); n++ {
+				ch := r.loadOrCreateChannel(fmt.Sprintf("g%d", n%20), wkproto.ChannelTypeGroup)
+				sub.step(ch, &ChannelAction{
+					UniqueNo:   ch.uniqueNo,
+					ActionType: ChannelActionSend,
+					Messages:   []ReactorChannelMessage{{FromUid: fmt.Sprintf("u%d", i)}},
+				})
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	sub.stop()
+	close(stopC)
+	drainWg.Wait()
+
+	// 队列里不能残留已销毁的频道，同一个key也只能有一个频道
+	keys := map[string]struct{}{}
+	sub.channelQueue.iter(func(ch *channel) {
+		assert.False(t, ch.isDestroyed())
+		_, ok := keys[ch.key]
+		assert.False(t, ok)
+		keys[ch.key] = struct{}{}
+	})
+}
+
+// 单个频道处理逻辑panic，不影响reactor和其他频道
+func TestChannelReactorSubPanicIsolation(t *testing.T) {
+	setupTestTrace(t)
+
+	opts := NewOptions()
+	opts.Reactor.ChannelSubCount = 1
+	panicC := make(chan string, 1)
+	WithReactorPanicHandler(func(channelId string, channelType uint8, recovered interface{}) {
+		panicC <- channelId
+	})(opts)
+
+	r := newChannelReactor(nil, opts)
+	sub := r.subs[0]
+
+	stopC := make(chan struct{})
+	defer close(stopC)
+	go func() {
+		for {
+			select {
+			case <-r.processInitC:
+			case <-r.processCloseC:
+			case <-stopC:
+				return
+			}
+		}
+	}()
+
+	err := sub.start()
+	assert.NoError(t, err)
+	defer sub.stop()
+
+	stepWait := func(ch *channel, action *ChannelAction) error {
+		waitC := make(chan error, 1)
+		sub.stepChannelC <- stepChannel{ch: ch, action: action, waitC: waitC}
+		return <-waitC
+	}
+
+	bad := r.loadOrCreateChannel("bad", wkproto.ChannelTypeGroup)
+	bad.stepFnc = func(a *ChannelAction) error {
+		panic("storage bug")
+	}
+	good := r.loadOrCreateChannel("good", wkproto.ChannelTypeGroup)
+
+	err = stepWait(bad, &ChannelAction{UniqueNo: bad.uniqueNo, ActionType: ChannelActionPermissionCheckResp})
+	assert.Equal(t, ErrChannelPanic, err)
+	assert.Equal(t, "bad", <-panicC)
+	assert.True(t, bad.degraded.Load())
+	assert.True(t, bad.isDestroyed())
+
+	// 其他频道正常处理
+	err = stepWait(good, &ChannelAction{
+		UniqueNo:   good.uniqueNo,
+		ActionType: ChannelActionSend,
+		Messages:   []ReactorChannelMessage{{FromUid: "u1"}},
+	})
+	assert.NoError(t, err)
+	assert.False(t, good.degraded.Load())
+
+	// 损坏的频道被移除，再次发送会创建新的频道
+	err = stepWait(bad, &ChannelAction{
+		UniqueNo:   bad.uniqueNo,
+		ActionType: ChannelActionSend,
+		Messages:   []ReactorChannelMessage{{FromUid: "u1"}},
+	})
+	assert.NoError(t, err)
+	ch := r.loadOrCreateChannel("bad", wkproto.ChannelTypeGroup)
+	assert.NotSame(t, bad, ch)
+	assert.False(t, ch.degraded.Load())
+}
+
+// 投递阶段处理不过来（投递队列满了）时，频道暂停投递，不阻塞reactor，有空位后继续投递
+func TestChannelReactorSubDeliverBackpressure(t *testing.T) {
+	setupTestTrace(t)
+
+	opts := NewOptions()
+	opts.Reactor.ChannelSubCount = 1
+	r := newChannelReactor(nil, opts)
+	sub := r.subs[0]
+
+	ch := newChannel(sub, "g1", wkproto.ChannelTypeGroup)
+	ch.status = channelStatusInitialized
+	ch.becomeLeader()
+	for i := 0; i < 3; i++ {
+		ch.msgQueue.appendMessage(ReactorChannelMessage{MessageId: int64(i + 1), Index: uint64(i + 1)})
+	}
+	// 消息都已存储和回执，只剩投递
+	ch.msgQueue.payloadDecryptingIndex = ch.msgQueue.lastIndex
+	ch.msgQueue.permissionCheckingIndex = ch.msgQueue.lastIndex
+	ch.msgQueue.storagingIndex = ch.msgQueue.lastIndex
+	ch.msgQueue.sendackingIndex = ch.msgQueue.lastIndex
+
+	// 投递者很慢，队列被占满
+	for i := 0; i < cap(r.processDeliverC); i++ {
+		r.processDeliverC <- &deliverReq{}
+	}
+
+	doneC := make(chan struct{})
+	go func() {
+		defer close(doneC)
+		sub.handleReady(ch)
+	}()
+	select {
+	case <-doneC:
+	case <-time.After(time.Second * 2):
+		t.Fatal("handleReady blocked by full deliver queue")
+	}
+	assert.False(t, ch.delivering)
+	assert.Equal(t, uint64(0), ch.msgQueue.deliveringIndex)
+	assert.Equal(t, int64(0), r.deliverQueueDepth.Load())
+
+	// 处理间隔内不会重试
+	assert.False(t, ch.hasUnDeliver())
+
+	// 投递者处理了一个请求，等处理间隔过后继续投递
+	<-r.processDeliverC
+	for i := 0; i < opts.Reactor.ChannelProcessIntervalTick; i++ {
+		ch.tick()
+	}
+	assert.True(t, ch.hasUnDeliver())
+	sub.handleReady(ch)
+	assert.True(t, ch.delivering)
+	assert.Equal(t, int64(3), r.deliverQueueDepth.Load())
+}
+
+// 存储卡住的集群（获取频道领导时一直阻塞，直到ctx结束）
+type blockingOpenCluster struct {
+	icluster.Cluster
+	blockC    chan struct{}
+	canceledC chan struct{}
+}
+
+func (b *blockingOpenCluster) LeaderOfChannel(ctx context.Context, channelId string, channelType uint8) (*pb.Node, error) {
+	select {
+	case <-b.blockC:
+		return nil, fmt.Errorf("closed")
+	case <-ctx.Done():
+		close(b.canceledC)
+		return nil, ctx.Err()
+	}
+}
+
+func (b *blockingOpenCluster) SlotLeaderOfChannel(channelId string, channelType uint8) (*pb.Node, error) {
+	return &pb.Node{Id: 1}, nil
+}
+
+// 存储打开卡住时，频道初始化超时失败，不会一直阻塞初始化协程
+func TestChannelReactorInitStorageOpenTimeout(t *testing.T) {
+	opts := NewOptions(WithShardStorageOpenTimeout(time.Millisecond * 50))
+	opts.Reactor.ChannelSubCount = 1
+	cluster := &blockingOpenCluster{blockC: make(chan struct{}), canceledC: make(chan struct{})}
+	defer close(cluster.blockC)
+	s := &Server{ctx: context.Background(), cluster: cluster}
+	r := newChannelReactor(s, opts)
+	defer r.stopper.Stop()
+
+	ch := r.loadOrCreateChannel("g1", wkproto.ChannelTypeGroup)
+
+	start := time.Now()
+	doneC := make(chan struct{})
+	go func() {
+		r.processInit(&initReq{ch: ch})
+		close(doneC)
+	}()
+
+	var resp stepChannel
+	select {
+	case resp = <-r.subs[0].stepChannelC:
+	case <-time.After(time.Second):
+		t.Fatal("init not timeout")
+	}
+	<-doneC
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Equal(t, ChannelActionInitResp, resp.action.ActionType)
+	assert.Equal(t, ReasonError, resp.action.Reason)
+	assert.True(t, ch.degraded.Load())
+
+	// 超时后初始化协程的ctx被取消，协程退出
+	select {
+	case <-cluster.canceledC:
+	case <-time.After(time.Second):
+		t.Fatal("init goroutine not canceled")
+	}
+}
+
+// ctx结束后创建接收者标签不修改频道的标签
+func TestChannelMakeReceiverTagContextCanceled(t *testing.T) {
+	opts := NewOptions()
+	opts.Reactor.ChannelSubCount = 1
+	s := &Server{ctx: context.Background(), opts: opts, cluster: &blockingOpenCluster{}}
+	r := newChannelReactor(s, opts)
+	defer r.stopper.Stop()
+
+	ch := r.loadOrCreateChannel(GetFakeChannelIDWith("u1", "u2"), wkproto.ChannelTypePerson)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	tg, err := ch.makeReceiverTagContext(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Nil(t, tg)
+	assert.Equal(t, "", ch.receiverTagKey.Load())
+}
+
+// 批量提交的事件按顺序处理，等待整批处理完成，返回第一个错误
+func TestChannelReactorSubStepBatch(t *testing.T) {
+	opts := NewOptions()
+	opts.Reactor.ChannelSubCount = 1
+	r := newChannelReactor(&Server{ctx: context.Background()}, opts)
+	sub := r.subs[0]
+
+	stopC := make(chan struct{})
+	defer close(stopC)
+	go func() {
+		for {
+			select {
+			case <-r.processInitC:
+			case <-r.processCloseC:
+			case <-stopC:
+				return
+			}
+		}
+	}()
+
+	err := sub.start()
+	assert.NoError(t, err)
+	defer sub.stop()
+
+	ch := r.loadOrCreateChannel("g1", wkproto.ChannelTypeGroup)
+	var stepped []string
+	ch.stepFnc = func(a *ChannelAction) error {
+		stepped = append(stepped, a.Messages[0].FromUid)
+		if a.Messages[0].FromUid == "u2" {
+			return ErrChannelDestroyed
+		}
+		return nil
+	}
+	actions := make([]*ChannelAction, 0, 3)
+	for i := 1; i <= 3; i++ {
+		actions = append(actions, &ChannelAction{
+			UniqueNo:   ch.uniqueNo,
+			ActionType: ChannelActionPermissionCheckResp,
+			Messages:   []ReactorChannelMessage{{FromUid: fmt.Sprintf("u%d", i)}},
+		})
+	}
+	err = sub.stepBatchWait(ch, actions, 0)
+	assert.Equal(t, ErrChannelDestroyed, err)
+	assert.Equal(t, []string{"u1", "u2", "u3"}, stepped)
+
+	assert.NoError(t, sub.stepBatchWait(ch, nil, 0))
+}
+
+// 其他节点转发过来的一批消息一次提交给频道，按顺序进入消息队列后才返回
+func TestChannelProposeSendBatch(t *testing.T) {
+	opts := NewOptions()
+	opts.Reactor.ChannelSubCount = 1
+	r := newChannelReactor(&Server{ctx: context.Background()}, opts)
+	sub := r.subs[0]
+
+	stopC := make(chan struct{})
+	defer close(stopC)
+	go func() {
+		for {
+			select {
+			case <-r.processInitC:
+			case <-r.processCloseC:
+			case <-stopC:
+				return
+			}
+		}
+	}()
+
+	err := sub.start()
+	assert.NoError(t, err)
+	defer sub.stop()
+
+	ch := r.loadOrCreateChannel("g1", wkproto.ChannelTypeGroup)
+	messages := make([]ReactorChannelMessage, 0, 3)
+	for i := 1; i <= 3; i++ {
+		messages = append(messages, ReactorChannelMessage{
+			ctx:        context.Background(),
+			FromUid:    fmt.Sprintf("u%d", i),
+			FromNodeId: 2,
+			SendPacket: &wkproto.SendPacket{ChannelID: "g1", ChannelType: wkproto.ChannelTypeGroup},
+		})
+	}
+	assert.NoError(t, ch.proposeSendBatch(messages))
+
+	queued := ch.msgQueue.slice(ch.msgQueue.offset, ch.msgQueue.lastIndex+1)
+	assert.Len(t, queued, 3)
+	for i, msg := range queued {
+		assert.Equal(t, fmt.Sprintf("u%d", i+1), msg.FromUid)
+		assert.Equal(t, uint64(i+1), msg.Index)
+		assert.NotZero(t, msg.MessageId)
+	}
+
+	assert.NoError(t, ch.proposeSendBatch(nil))
+}
+
+// 同一个频道突发大量事件，逐个提交和批量提交的对比
+func BenchmarkChannelReactorSubBurstStep(b *testing.B) {
+	const burst = 32
+	run := func(b *testing.B, batch bool) {
+		opts := NewOptions()
+		opts.Reactor.ChannelSubCount = 1
+		r := newChannelReactor(&Server{ctx: context.Background()}, opts)
+		sub := r.subs[0]
+		if err := sub.start(); err != nil {
+			b.Fatal(err)
+		}
+		defer sub.stop()
+
+		ch := r.loadOrCreateChannel("g1", wkproto.ChannelTypeGroup)
+		ch.stepFnc = func(a *ChannelAction) error {
+			return nil
+		}
+		actions := make([]*ChannelAction, 0, burst)
+		for i := 0; i < burst; i++ {
+			actions = append(actions, &ChannelAction{UniqueNo: ch.uniqueNo, ActionType: ChannelActionPermissionCheckResp})
+		}
+
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if batch {
+				sub.stepBatch(ch, actions)
+			} else {
+				for _, action := range actions {
+					sub.step(ch, action)
+				}
+			}
+		}
+		if err := sub.stepBatchWait(ch, actions[:1], 0); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.Run("single", func(b *testing.B) {
+		run(b, false)
+	})
+	b.Run("batch", func(b *testing.B) {
+		run(b, true)
+	})
+}
+
+// 事件队列满了时tryStep不阻塞，直接返回ErrChannelReactorBusy
+func TestChannelReactorSubTryStep(t *testing.T) {
+	setupTestTrace(t)
+
+	opts := NewOptions()
+	opts.Reactor.ChannelSubCount = 1
+	opts.Reactor.ChannelStepQueueSize = 1
+	r := newChannelReactor(nil, opts)
+	sub := r.subs[0]
+	assert.Equal(t, 1, cap(sub.stepChannelC))
+
+	ch := newChannel(sub, "g1", wkproto.ChannelTypeGroup)
+	err := sub.tryStep(ch, &ChannelAction{ActionType: ChannelActionSend})
+	assert.NoError(t, err)
+
+	err = sub.tryStep(ch, &ChannelAction{ActionType: ChannelActionSend})
+	assert.ErrorIs(t, err, ErrChannelReactorBusy)
+
+	// 队列有空位后可以继续提交
+	<-sub.stepChannelC
+	err = sub.tryStep(ch, &ChannelAction{ActionType: ChannelActionSend})
+	assert.NoError(t, err)
+}
+
+// 拒绝策略下事件队列满了发送消息直接返回ErrChannelReactorBusy，阻塞策略下等待队列有空位
+func TestChannelProposeSendStepQueueFull(t *testing.T) {
+	setupTestTrace(t)
+
+	opts := NewOptions()
+	opts.Reactor.ChannelSubCount = 1
+	opts.Reactor.ChannelStepQueueSize = 1
+	r := newChannelReactor(nil, opts)
+	sub := r.subs[0]
+	ch := newChannel(sub, "g1", wkproto.ChannelTypeGroup)
+	packet := &wkproto.SendPacket{ChannelID: "g1", ChannelType: wkproto.ChannelTypeGroup}
+
+	_, err := ch.proposeSend(context.Background(), "u1", "d1", 1, 1, false, packet)
+	assert.NoError(t, err)
+	_, err = ch.proposeSend(context.Background(), "u1", "d1", 1, 1, false, packet)
+	assert.ErrorIs(t, err, ErrChannelReactorBusy)
+
+	opts.Reactor.ForwardOverflowPolicy = ForwardOverflowBlock
+	done := make(chan error, 1)
+	go func() {
+		_, err := ch.proposeSend(context.Background(), "u1", "d1", 1, 1, false, packet)
+		done <- err
+	}()
+	select {
+	case <-done:
+		t.Fatal("proposeSend should block while the step queue is full")
+	case <-time.After(time.Millisecond * 50):
+	}
+	<-sub.stepChannelC
+	assert.NoError(t, <-done)
+}
+
+// 等待事件处理超时返回ErrChannelStepWaitTimeout，区别于reactor停止
+func TestChannelReactorSubStepBatchWaitTimeout(t *testing.T) {
+	opts := NewOptions()
+	opts.Reactor.ChannelSubCount = 1
+	r := newChannelReactor(&Server{ctx: context.Background()}, opts)
+	sub := r.subs[0] // 不启动，事件不会被处理
+
+	ch := newChannel(sub, "g1", wkproto.ChannelTypeGroup)
+	actions := []*ChannelAction{{UniqueNo: ch.uniqueNo, ActionType: ChannelActionPermissionCheckResp}}
+
+	start := time.Now()
+	err := sub.stepBatchWait(ch, actions, time.Millisecond*50)
+	assert.ErrorIs(t, err, ErrChannelStepWaitTimeout)
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+// 其他节点转发过来的投递也计入投递排队数量，投递者的队列满了返回错误，排队数量不会变成负数
+func TestDeliverForwardedQueueDepth(t *testing.T) {
+	setupTestTrace(t)
+
+	opts := NewOptions()
+	opts.Reactor.ChannelSubCount = 1
+	opts.Deliver.DeliverrCount = 1
+	s := &Server{opts: opts}
+	s.channelReactor = newChannelReactor(s, opts)
+	s.deliverManager = newDeliverManager(s)
+	deliverr := newDeliverr(0, s.deliverManager)
+	s.deliverManager.deliverrs[0] = deliverr
+
+	msgSet := ChannelMessagesSet{{ChannelId: "g1", ChannelType: wkproto.ChannelTypeGroup, Messages: ReactorChannelMessageSet{{MessageId: 1}, {MessageId: 2}}}}
+	err := s.deliverForwarded(msgSet)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), s.channelReactor.deliverQueueDepth.Load())
+
+	// 投递者处理完后按请求里的消息数量减掉
+	req := <-deliverr.reqC
+	assert.Len(t, req.messages, 2)
+	s.channelReactor.deliverQueueDepthAdd(req.channelType, -int64(len(req.messages)))
+
+	// 投递者的队列满了
+	for len(deliverr.reqC) < cap(deliverr.reqC) {
+		deliverr.reqC <- &deliverReq{}
+	}
+	err = s.deliverForwarded(msgSet)
+	assert.ErrorIs(t, err, ErrDeliverQueueFull)
+	assert.Equal(t, int64(0), s.channelReactor.deliverQueueDepth.Load())
+}
+
+// 大量空闲频道时每次唤醒readys的开销，全量遍历（改动前每次唤醒的行为）和只遍历有事件的频道的对比
+func BenchmarkChannelReactorSubReadysIdle(b *testing.B) {
+	setupTestTrace(b)
+
+	const idleCount = 100000
+	opts := NewOptions()
+	opts.Reactor.ChannelSubCount = 1
+	r := newChannelReactor(&Server{ctx: context.Background()}, opts)
+	sub := r.subs[0] // 不启动，直接调用readys
+
+	var active *channel
+	for i := 0; i < idleCount; i++ {
+		ch := newChannel(sub, fmt.Sprintf("g%d", i), wkproto.ChannelTypeGroup)
+		ch.status = channelStatusInitialized
+		ch.becomeLeader()
+		sub.channelQueue.add(ch)
+		active = ch
+	}
+
+	b.Run("full_scan", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			sub.needFullScan = true
+			sub.markDirty(active)
+			sub.readys()
+		}
+	})
+	b.Run("dirty", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			sub.markDirty(active)
+			sub.readys()
+		}
+	})
+}
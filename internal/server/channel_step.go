@@ -150,6 +150,7 @@ func (c *channel) stepLeader(a *ChannelAction) error {
 				storedMsg := a.Messages[j]
 				if msg.MessageId == storedMsg.MessageId {
 					msg.MessageSeq = storedMsg.MessageSeq
+					msg.ReasonCode = storedMsg.ReasonCode
 					c.msgQueue.messages[i] = msg
 					break
 				}
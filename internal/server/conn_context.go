@@ -160,7 +160,7 @@ func (c *connContext) addSendPacket(packet *wkproto.SendPacket) {
 	trace.GlobalTrace.Metrics.App().SendPacketCountAdd(1)
 	trace.GlobalTrace.Metrics.App().SendPacketBytesAdd(frameSize)
 
-	ctx, span := trace.GlobalTrace.StartSpan(context.Background(), "processMessage")
+	ctx, span := trace.GlobalTrace.StartChannelSpan(context.Background(), "processMessage", packet.ChannelID, packet.ChannelType)
 	span.SetString("clientMsgNo", packet.ClientMsgNo)
 	span.SetBool("noPersist", packet.NoPersist)
 	span.SetBool("syncOnce", packet.SyncOnce)
@@ -195,6 +195,22 @@ func (c *connContext) writePacket(packet wkproto.Frame) error {
 	return c.write(data, packet.GetFrameType())
 }
 
+// 将多个包编码后合并成一次写入，包类型以第一个包为准
+func (c *connContext) writePackets(packets []wkproto.Frame) error {
+	if len(packets) == 0 {
+		return nil
+	}
+	var data []byte
+	for _, packet := range packets {
+		d, err := c.subReactor.r.s.opts.Proto.EncodeFrame(packet, c.protoVersion)
+		if err != nil {
+			return err
+		}
+		data = append(data, d...)
+	}
+	return c.write(data, packets[0].GetFrameType())
+}
+
 func (c *connContext) write(d []byte, frameType wkproto.FrameType) error {
 
 	c.subReactor.step(c.uid, UserAction{
@@ -0,0 +1,70 @@
+package server
+
+import (
+	"testing"
+
+	wkproto "github.com/WuKongIM/WuKongIMGoProto"
+)
+
+// 同一个连接一次收到多个发送回执，逐个写入（关闭回执合并）和合并成一次写入（开启回执合并）的对比
+// writes/op 为每批回执交给用户reactor的写入次数
+func BenchmarkConnContextWriteSendacks(b *testing.B) {
+	const sendackCount = 32
+	run := func(b *testing.B, batch bool) {
+		opts := NewOptions()
+		opts.Reactor.UserSubCount = 1
+		u := newUserReactor(&Server{opts: opts})
+		sub := u.subs[0]
+
+		writes := 0
+		stopC := make(chan struct{})
+		doneC := make(chan struct{})
+		go func() {
+			defer close(doneC)
+			for {
+				select {
+				case <-sub.stepUserC:
+					writes++
+				case <-stopC:
+					for len(sub.stepUserC) > 0 {
+						<-sub.stepUserC
+						writes++
+					}
+					return
+				}
+			}
+		}()
+
+		conn := newConnContextProxy(1, connInfo{uid: "u1", connId: 1, protoVersion: wkproto.LatestVersion}, sub)
+		sendacks := make([]wkproto.Frame, 0, sendackCount)
+		for i := 0; i < sendackCount; i++ {
+			sendacks = append(sendacks, &wkproto.SendackPacket{MessageID: int64(i + 1), MessageSeq: uint32(i + 1), ClientSeq: uint64(i + 1), ReasonCode: wkproto.ReasonSuccess})
+		}
+
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if batch {
+				if err := conn.writePackets(sendacks); err != nil {
+					b.Fatal(err)
+				}
+				continue
+			}
+			for _, sendack := range sendacks {
+				if err := conn.writePacket(sendack); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+		b.StopTimer()
+		close(stopC)
+		<-doneC
+		b.ReportMetric(float64(writes)/float64(b.N), "writes/op")
+	}
+	b.Run("single", func(b *testing.B) {
+		run(b, false)
+	})
+	b.Run("batch", func(b *testing.B) {
+		run(b, true)
+	})
+}
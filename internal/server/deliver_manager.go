@@ -57,22 +57,23 @@ func (d *deliverManager) stop() {
 	d.nodeManager.stop()
 }
 
-func (d *deliverManager) deliver(req *deliverReq) {
-	d.handleDeliver(req)
+// deliver 将投递请求交给投递者，所有投递者的队列都满了返回false
+func (d *deliverManager) deliver(req *deliverReq) bool {
+	return d.handleDeliver(req)
 }
 
-func (d *deliverManager) handleDeliver(req *deliverReq) {
+func (d *deliverManager) handleDeliver(req *deliverReq) bool {
 
 	retry := 0
 	for {
 		if retry > d.s.opts.Deliver.MaxRetry {
 			d.Error("deliver reqC full, retry too many times", zap.Int("retry", retry))
-			return
+			return false
 		}
 		deliver := d.nextDeliver()
 		select {
 		case deliver.reqC <- req:
-			return
+			return true
 		default:
 			retry++
 		}
@@ -139,6 +140,13 @@ func (d *deliverr) loop() {
 func (d *deliverr) handleDeliverReqs(req []*deliverReq) {
 	for _, r := range req {
 		d.handleDeliverReq(r)
+		d.dm.s.channelReactor.deliverQueueDepthAdd(r.channelType, -int64(len(r.messages)))
+		if d.dm.s.opts.TraceOn() {
+			// 扇出完成，结束消息的deliver span
+			for _, message := range r.messages {
+				trace.SpanFromContext(message.ctx).End()
+			}
+		}
 	}
 }
 
@@ -0,0 +1,118 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/WuKongIM/WuKongIM/pkg/trace"
+	wkproto "github.com/WuKongIM/WuKongIMGoProto"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+var (
+	deliverTraceOnce     sync.Once
+	deliverTraceRecorded *tracetest.SpanRecorder
+)
+
+// deliverTraceRecorder 频道的tracer第一次使用后会缓存，所以整个包的测试共用一个TracerProvider
+func deliverTraceRecorder() *tracetest.SpanRecorder {
+	deliverTraceOnce.Do(func() {
+		deliverTraceRecorded = tracetest.NewSpanRecorder()
+		otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(deliverTraceRecorded)))
+	})
+	return deliverTraceRecorded
+}
+
+// 投递span接在消息的提案链路上，并链接到提案span（包括转发到其他节点后通过trace id还原的链路）
+func TestDeliverSpanLinksToPropose(t *testing.T) {
+	setupTestTrace(t, trace.WithTraceOn(true))
+
+	recorder := deliverTraceRecorder()
+
+	ctx, proposeSpan := trace.GlobalTrace.StartSpan(context.Background(), "processMessage")
+	proposeSpanCtx := proposeSpan.SpanContext()
+	proposeSpan.End()
+
+	local := ReactorChannelMessage{
+		ctx:         ctx,
+		MessageId:   1,
+		proposeTime: time.Now().Add(-time.Millisecond * 20),
+	}
+
+	// 消息转发到其他节点时只带上trace id和span id
+	data, err := (&ReactorChannelMessage{ctx: ctx, MessageId: 2, SendPacket: &wkproto.SendPacket{}}).Marshal()
+	assert.NoError(t, err)
+	remote := ReactorChannelMessage{}
+	assert.NoError(t, remote.Unmarshal(data))
+
+	messages := []ReactorChannelMessage{local, remote}
+	spans := startDeliverSpans("g1", wkproto.ChannelTypeGroup, messages)
+	assert.Len(t, spans, 2)
+	for _, span := range spans {
+		span.End()
+	}
+
+	delivers := make([]sdktrace.ReadOnlySpan, 0, 2)
+	for _, span := range recorder.Ended() {
+		if span.Name() == "deliver" && span.SpanContext().TraceID() == proposeSpanCtx.TraceID() {
+			delivers = append(delivers, span)
+		}
+	}
+	assert.Len(t, delivers, 2)
+	for i, deliver := range delivers {
+		assert.Equal(t, proposeSpanCtx.TraceID(), deliver.SpanContext().TraceID())
+		assert.Equal(t, proposeSpanCtx.SpanID(), deliver.Parent().SpanID())
+		if assert.Len(t, deliver.Links(), 1) {
+			assert.Equal(t, proposeSpanCtx.TraceID(), deliver.Links()[0].SpanContext.TraceID())
+			assert.Equal(t, proposeSpanCtx.SpanID(), deliver.Links()[0].SpanContext.SpanID())
+		}
+		// 之后的投递span挂在deliver span下
+		assert.Equal(t, deliver.SpanContext().SpanID(), trace.SpanContextFromContext(messages[i].ctx).SpanID())
+	}
+
+	var proposeToDeliverMs int64 = -1
+	for _, attr := range delivers[0].Attributes() {
+		if attr.Key == "proposeToDeliverMs" {
+			proposeToDeliverMs = attr.Value.AsInt64()
+		}
+	}
+	assert.GreaterOrEqual(t, proposeToDeliverMs, int64(20))
+}
+
+// 投递失败重试时，新的deliver span和上一次尝试是兄弟span，都挂在提案span下
+func TestDeliverSpanRetryIsSibling(t *testing.T) {
+	setupTestTrace(t, trace.WithTraceOn(true))
+
+	recorder := deliverTraceRecorder()
+
+	ctx, proposeSpan := trace.GlobalTrace.StartSpan(context.Background(), "processMessage")
+	proposeSpanCtx := proposeSpan.SpanContext()
+	proposeSpan.End()
+
+	messages := []ReactorChannelMessage{{ctx: ctx, MessageId: 1}}
+	for attempt := 0; attempt < 2; attempt++ {
+		for _, span := range startDeliverSpans("g1", wkproto.ChannelTypeGroup, messages) {
+			span.RecordError(errors.New("deliver queue is full"))
+			span.End()
+		}
+	}
+
+	delivers := make([]sdktrace.ReadOnlySpan, 0, 2)
+	for _, span := range recorder.Ended() {
+		if span.Name() == "deliver" && span.SpanContext().TraceID() == proposeSpanCtx.TraceID() {
+			delivers = append(delivers, span)
+		}
+	}
+	if assert.Len(t, delivers, 2) {
+		assert.NotEqual(t, delivers[0].SpanContext().SpanID(), delivers[1].SpanContext().SpanID())
+		for _, deliver := range delivers {
+			assert.Equal(t, proposeSpanCtx.SpanID(), deliver.Parent().SpanID())
+		}
+	}
+}
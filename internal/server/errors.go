@@ -3,9 +3,17 @@ package server
 import "fmt"
 
 var (
-	ErrConnNotFound     = fmt.Errorf("conn not found")
-	ErrReactorStopped   = fmt.Errorf("reactor stopped")
-	ErrChannelIdIsEmpty = fmt.Errorf("channel id is empty")
+	ErrConnNotFound       = fmt.Errorf("conn not found")
+	ErrReactorStopped     = fmt.Errorf("reactor stopped")
+	ErrChannelIdIsEmpty   = fmt.Errorf("channel id is empty")
+	ErrChannelDestroyed   = fmt.Errorf("channel destroyed")
+	ErrChannelPanic       = fmt.Errorf("channel panic")
+	ErrChannelInitTimeout = fmt.Errorf("channel init timeout")
+	ErrChannelReactorBusy = fmt.Errorf("channel reactor busy")
+	// ErrChannelStepWaitTimeout 等待频道事件处理完成超时（reactor仍在运行，区别于ErrReactorStopped）
+	ErrChannelStepWaitTimeout = fmt.Errorf("channel step wait timeout")
+	// ErrDeliverQueueFull 投递者的队列都满了
+	ErrDeliverQueueFull = fmt.Errorf("deliver queue full")
 )
 
 type errCode int32
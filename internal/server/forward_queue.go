@@ -0,0 +1,72 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/WuKongIM/WuKongIM/pkg/trace"
+)
+
+// ErrForwardQueueFull 代理节点待转发的消息数量达到上限
+var ErrForwardQueueFull = errors.New("forward queue full")
+
+// forwardQueueFull 转发队列是否已满，maxSize为0表示不限制
+func forwardQueueFull(size int64, maxSize int) bool {
+	return maxSize > 0 && size >= int64(maxSize)
+}
+
+// updateForwardQueueDepth 更新频道待转发的消息数量（代理角色时为还没转发成功的消息数量），并同步到节点的转发队列大小
+func (c *channel) updateForwardQueueDepth() {
+	var depth int64
+	isProxy := c.role == channelRoleProxy
+	if isProxy && c.msgQueue.lastIndex > c.msgQueue.forwardingIndex {
+		depth = int64(c.msgQueue.lastIndex - c.msgQueue.forwardingIndex)
+	}
+	c.isProxy.Store(isProxy)
+	c.addForwardQueueDepth(depth - c.forwardQueueDepth.Swap(depth))
+}
+
+// releaseForwardQueueDepth 频道关闭时释放占用的转发队列
+func (c *channel) releaseForwardQueueDepth() {
+	c.addForwardQueueDepth(-c.forwardQueueDepth.Swap(0))
+}
+
+func (c *channel) addForwardQueueDepth(delta int64) {
+	if delta == 0 {
+		return
+	}
+	c.r.forwardQueueSize.Add(delta)
+	trace.GlobalTrace.Metrics.App().ForwardQueueDepthAdd(delta)
+}
+
+// waitForwardQueue 代理频道在转发队列满了时根据策略拒绝或等待队列有空位
+func (c *channel) waitForwardQueue(ctx context.Context) error {
+	maxSize := c.opts.Reactor.MaxForwardQueueSize
+	if !c.isProxy.Load() || !forwardQueueFull(c.r.forwardQueueSize.Load(), maxSize) {
+		return nil
+	}
+	if c.opts.Reactor.ForwardOverflowPolicy == ForwardOverflowBlock {
+		timeout := time.NewTimer(c.opts.Cluster.ReqTimeout)
+		defer timeout.Stop()
+		tk := time.NewTicker(time.Millisecond * 10)
+		defer tk.Stop()
+	wait:
+		for forwardQueueFull(c.r.forwardQueueSize.Load(), maxSize) {
+			select {
+			case <-tk.C:
+			case <-timeout.C:
+				break wait
+			case <-ctx.Done():
+				break wait
+			case <-c.r.stopper.ShouldStop():
+				break wait
+			}
+		}
+		if !forwardQueueFull(c.r.forwardQueueSize.Load(), maxSize) {
+			return nil
+		}
+	}
+	trace.GlobalTrace.Metrics.App().ForwardQueueOverflowCountAdd(1)
+	return ErrForwardQueueFull
+}
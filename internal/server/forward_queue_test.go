@@ -0,0 +1,41 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/lni/goutils/syncutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestForwardQueueFull(t *testing.T) {
+	assert.False(t, forwardQueueFull(100, 0))
+	assert.False(t, forwardQueueFull(9, 10))
+	assert.True(t, forwardQueueFull(10, 10))
+	assert.True(t, forwardQueueFull(11, 10))
+}
+
+func TestWaitForwardQueueBlock(t *testing.T) {
+	opts := NewOptions()
+	opts.Reactor.MaxForwardQueueSize = 10
+	opts.Reactor.ForwardOverflowPolicy = ForwardOverflowBlock
+	opts.Cluster.ReqTimeout = time.Second * 5
+
+	r := &channelReactor{stopper: syncutil.NewStopper(), opts: opts}
+	defer r.stopper.Stop()
+	r.forwardQueueSize.Store(10)
+
+	c := &channel{r: r, opts: opts}
+	c.isProxy.Store(true)
+
+	go func() {
+		time.Sleep(time.Millisecond * 50)
+		r.forwardQueueSize.Store(5)
+	}()
+
+	start := time.Now()
+	err := c.waitForwardQueue(context.Background())
+	assert.NoError(t, err)
+	assert.Less(t, time.Since(start), time.Second)
+}
@@ -31,6 +31,8 @@ type ReactorChannelMessage struct {
 	IsSystem     bool // 是否是系统发送的消息
 	ReasonCode   wkproto.ReasonCode
 	Index        uint64
+	proposeTime  time.Time       // 消息在本节点提案的时间，用于计算提案到投递的耗时（不参与编码）
+	proposeCtx   context.Context // 提案链路的ctx，每次投递尝试的deliver span都从这里开始，互为兄弟span（不参与编码）
 }
 
 func (r *ReactorChannelMessage) Marshal() ([]byte, error) {
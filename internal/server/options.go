@@ -48,6 +48,22 @@ const (
 	RoleProxy   Role = "proxy"
 )
 
+// ForwardOverflowPolicy 转发队列满了时的处理策略
+type ForwardOverflowPolicy string
+
+const (
+	ForwardOverflowReject ForwardOverflowPolicy = "reject" // 直接拒绝（返回ErrForwardQueueFull，客户端收到发送失败的回执）
+	ForwardOverflowBlock  ForwardOverflowPolicy = "block"  // 阻塞等待队列有空位，最多等待Cluster.ReqTimeout
+)
+
+// ChannelProfileName 频道类型使用的内置提案配置
+type ChannelProfileName string
+
+const (
+	ChannelProfileControl ChannelProfileName = "control" // 控制类频道：提案超时短，不是领导时不重试，限制并发提案数，高优先级
+	ChannelProfileBulk    ChannelProfileName = "bulk"    // 批量类频道：提案超时长，不是领导时（选举期间）重试
+)
+
 type Options struct {
 	vp          *viper.Viper // 内部配置对象
 	Mode        Mode         // 模式 debug 测试 release 正式 bench 压力测试
@@ -173,11 +189,64 @@ type Options struct {
 
 		HeartbeatIntervalTick int // 心跳间隔tick
 		ElectionIntervalTick  int // 选举间隔tick
+		ElectionGraceTick     int // 跟随者与领导失联超过选举超时后，再等待领导恢复的tick次数，0表示不等待
 
 		ChannelReactorSubCount int // 频道reactor sub的数量
 		SlotReactorSubCount    int // 槽reactor sub的数量
 
 		PongMaxTick int // 节点超过多少tick没有回应心跳就认为是掉线
+
+		ChannelCreateRate    int           // 节点每秒最多创建多少个频道，0表示不限制
+		ChannelCreateBurst   int           // 频道创建允许的突发数量，0表示和ChannelCreateRate一致
+		ChannelCreateMaxWait time.Duration // 超过频道创建速率时最多排队等待的时间，0表示直接拒绝
+
+		MaxApplyLag            uint64 // 频道已提交未应用的日志数量超过这个值时暂停新的提案，0表示不限制
+		MaxWriteBytesPerSecond int    // 节点每秒最多提案写入的字节数（所有频道和槽共享），0表示不限制
+
+		ProposeRetryOnNotLeader bool          // 频道提案遇到不是领导（选举中）时是否按指数退避重试，直到超时
+		ProposeRetryMaxBackoff  time.Duration // 提案重试的最大退避间隔
+		ForwardTimeout          time.Duration // 转发提案给频道领导的超时时间，0表示比本地提案超时长2秒
+		ElectionStuckThreshold  time.Duration // 频道连续选举失败超过这个时间认为选举卡住，之后退避选举，0表示不检测
+		LeaderTransferGrace     time.Duration // 计划的槽领导转移期间，旧领导继续提供读取、提案排队重试的宽限时间，0表示不开启
+		SnapshotLogThreshold    uint64        // 频道距离上次快照已应用的日志数量达到这个值时做一次快照，0表示不按日志数量触发
+		LeaderFlappingThreshold int           // 频道领导在统计窗口内变更次数达到这个值认为领导在频繁变更（flapping），0表示不检测
+		LeaderFlappingWindow    time.Duration // 领导频繁变更的统计窗口
+		IdleHeartbeatTick       int           // 频道领导超过这个tick数没有提案认为频道空闲，空闲频道的心跳间隔放大IdleHeartbeatMultiple倍，0表示不调整
+		IdleHeartbeatMultiple   int           // 空闲频道心跳间隔的倍数
+		DiskMinFreeBytes        uint64        // 数据目录所在磁盘的剩余空间低于这个值时节点进入只读（拒绝频道提案），并把领导的频道转移给其他副本，0表示不按剩余空间检查
+		DiskCheckInterval       time.Duration // 检查磁盘剩余空间的间隔
+		IdleSweepPaused         bool          // 启动时暂停空闲频道的回收（批量导入等运维操作期间使用），可通过管理接口恢复
+		ChannelInactiveTimeout  time.Duration // 频道超过这个时间没有提案和追加日志则被回收（有未提交日志的领导频道除外），0表示不按时间回收
+
+		DisableProposeOnUnappliedConfig bool // 频道配置变更（副本变化）还没有生效时暂停频道的提案，直到配置生效或提案超时
+
+		ElectionPauseMaxDuration time.Duration // 网络维护期间暂停集群选举的最长时间，到期自动恢复选举，0表示不允许暂停
+
+		InboundMessageRate  int // 每个节点连接每秒最多接收多少条槽和频道的副本消息，避免一个异常的节点压垮消息队列，0表示不限制
+		InboundMessageBurst int // 每个节点连接允许的突发消息数量，0表示和InboundMessageRate一致
+
+		MaxMessageSize uint64 // 节点之间单条副本消息的最大字节数，收到超过的消息直接丢弃，发送的同步响应超过时拆分，0表示不限制
+
+		LogCacheMaxBytes uint64 // 每个频道在内存里缓存最近存储的日志的最大字节数，追随者短暂断开重连后同步时直接从内存返回，0表示不缓存
+
+		InlineApplyMaxLogs      uint64  // 频道一次要应用的日志数量不超过这个值时直接同步应用（低流量频道省去交给应用协程池的开销），0表示都异步应用
+		InlineApplyChannelTypes []uint8 // 允许同步应用的频道类型，为空表示所有频道类型
+
+		RelaxedApplyChannelTypes []uint8 // 宽松顺序应用日志的频道类型（日志之间相互独立，分段并行应用），没有配置的频道类型严格按顺序应用
+
+		MaxHandleReadyCountOfBatch int // 频道reactor每个循环最多处理几轮ready，超过后先处理排队的提案和消息再继续，0表示使用默认值（50）
+
+		ProposeResultChannelBuffer int // 槽和频道最多同时排队通知的提案结果数量，满了时由应用协程直接通知（上报指标cluster_propose_result_backpressure_count），0表示不限制
+
+		MaxConcurrentProposes   int  // 节点最多同时进行中的提案数量（所有频道和槽共享），限制突发流量时的协程数量和CPU占用，0表示不限制
+		ProposeConcurrencyBlock bool // 超过MaxConcurrentProposes时是否等待其他提案完成（最多等待提案超时时间），false表示直接拒绝
+
+		ProposeAuditOn bool // 是否开启提案审计（记录每条追加日志的下标、任期、数据hash等，用于排查问题）
+
+		ReplicaSetPolicy string            // 新频道选择副本节点的策略 random（随机，默认） 或 spreadDomains（分散到不同的故障域）
+		NodeDomains      map[uint64]string // 节点所在的故障域（机架、可用区等），key为节点id，没有配置的节点自成一个故障域，所有节点的配置需要一致
+
+		ChannelProfiles map[uint8]ChannelProfileName // 频道类型使用的内置提案配置（覆盖全局的提案超时、不是领导时重试和应用顺序），没有配置的频道类型使用全局配置
 	}
 
 	Trace struct {
@@ -186,18 +255,30 @@ type Options struct {
 		ServiceHostName  string
 		PrometheusApiUrl string  // prometheus api url
 		SampleRate       float64 // 消息链路采样率 0 ~ 1
+		ChannelTopN      int     // 单独统计消息数量的最繁忙频道个数，其他频道合并为other，0表示不统计
+		LogSizeMetricsOn bool    // 是否统计提案日志数据大小的分布（直方图）
+		// 频道提案副本确认跟踪的采样率 0 ~ 1，被采样的提案会在提案链路上记录每个副本确认的顺序和耗时，0表示不开启
+		ProposeAckSampleRate float64
 	}
 
 	Reactor struct {
-		ChannelSubCount             int // channel reactor sub 的数量
-		ChannelProcessIntervalTick  int // 处理频道逻辑的间隔tick
-		UserProcessIntervalTick     int // 处理用户逻辑的间隔tick
-		UserSubCount                int // user reactor sub 的数量
-		UserNodePingTick            int // 用户节点tick间隔
-		UserNodePongTimeoutTick     int // 用户节点pong超时tick,这个值必须要比UserNodePingTick大，一般建议是UserNodePingTick的2倍
-		ChannelDeadlineTick         int // 死亡的tick次数，超过此次数如果没有收到发送消息的请求，则会将此频道移除活跃状态
-		TagCheckIntervalTick        int // tag检查间隔tick
-		CheckUserLeaderIntervalTick int // 校验用户leader间隔tick，（隔多久验证一下当前领导是否是正确的领导）
+		ChannelSubCount             int                   // channel reactor sub 的数量
+		ChannelProcessIntervalTick  int                   // 处理频道逻辑的间隔tick
+		UserProcessIntervalTick     int                   // 处理用户逻辑的间隔tick
+		UserSubCount                int                   // user reactor sub 的数量
+		UserNodePingTick            int                   // 用户节点tick间隔
+		UserNodePongTimeoutTick     int                   // 用户节点pong超时tick,这个值必须要比UserNodePingTick大，一般建议是UserNodePingTick的2倍
+		ChannelDeadlineTick         int                   // 死亡的tick次数，超过此次数如果没有收到发送消息的请求，则会将此频道移除活跃状态
+		TagCheckIntervalTick        int                   // tag检查间隔tick
+		CheckUserLeaderIntervalTick int                   // 校验用户leader间隔tick，（隔多久验证一下当前领导是否是正确的领导）
+		SendackBatchWindow          time.Duration         // 发送回执的合并窗口，在此窗口内同一个连接的回执会合并成一次写入（只在写入层合并，每条消息仍然是单独的回执包），0表示不合并
+		MaxForwardQueueSize         int                   // 代理节点待转发给领导的消息最大数量（整个节点），0表示不限制
+		ForwardOverflowPolicy       ForwardOverflowPolicy // 转发队列满了时的处理策略 reject 或 block
+		StorageOpenTimeout          time.Duration         // 频道初始化时打开存储（获取领导、加载订阅者等）的超时时间，超时则初始化失败，0表示不限制
+		ChannelStepQueueSize        int                   // 每个channel reactor sub待处理的频道事件队列大小，队列满了时step会阻塞（forwardOverflowPolicy为reject时发送消息不等待，直接返回ErrChannelReactorBusy）
+		StepWaitTimeout             time.Duration         // 提交频道事件并等待处理完成的默认超时时间（调用方没有指定超时时使用）
+		// PanicHandler 频道处理逻辑panic时的回调（panic已被恢复，频道被标记为损坏并移除，reactor和其他频道不受影响）
+		PanicHandler func(channelId string, channelType uint8, recovered interface{})
 	}
 	DeadlockCheck bool // 死锁检查
 
@@ -359,51 +440,134 @@ func NewOptions(op ...Option) *Options {
 			Addr: "0.0.0.0:5172",
 		},
 		Cluster: struct {
-			NodeId                 uint64
-			Addr                   string
-			ServerAddr             string
-			APIUrl                 string
-			ReqTimeout             time.Duration
-			Role                   Role
-			Seed                   string
-			SlotReplicaCount       int
-			ChannelReplicaCount    int
-			SlotCount              int
-			InitNodes              []*Node
-			TickInterval           time.Duration
-			HeartbeatIntervalTick  int
-			ElectionIntervalTick   int
-			ChannelReactorSubCount int
-			SlotReactorSubCount    int
-			PongMaxTick            int
+			NodeId                  uint64
+			Addr                    string
+			ServerAddr              string
+			APIUrl                  string
+			ReqTimeout              time.Duration
+			Role                    Role
+			Seed                    string
+			SlotReplicaCount        int
+			ChannelReplicaCount     int
+			SlotCount               int
+			InitNodes               []*Node
+			TickInterval            time.Duration
+			HeartbeatIntervalTick   int
+			ElectionIntervalTick    int
+			ElectionGraceTick       int
+			ChannelReactorSubCount  int
+			SlotReactorSubCount     int
+			PongMaxTick             int
+			ChannelCreateRate       int
+			ChannelCreateBurst      int
+			ChannelCreateMaxWait    time.Duration
+			MaxApplyLag             uint64
+			MaxWriteBytesPerSecond  int
+			ProposeRetryOnNotLeader bool
+			ProposeRetryMaxBackoff  time.Duration
+			ForwardTimeout          time.Duration
+			ElectionStuckThreshold  time.Duration
+			LeaderTransferGrace     time.Duration
+			SnapshotLogThreshold    uint64
+			LeaderFlappingThreshold int
+			LeaderFlappingWindow    time.Duration
+			IdleHeartbeatTick       int
+			IdleHeartbeatMultiple   int
+			DiskMinFreeBytes        uint64
+			DiskCheckInterval       time.Duration
+			IdleSweepPaused         bool
+			ChannelInactiveTimeout  time.Duration
+
+			DisableProposeOnUnappliedConfig bool
+
+			ElectionPauseMaxDuration time.Duration
+
+			InboundMessageRate  int
+			InboundMessageBurst int
+
+			MaxMessageSize uint64
+
+			LogCacheMaxBytes uint64
+
+			InlineApplyMaxLogs      uint64
+			InlineApplyChannelTypes []uint8
+
+			RelaxedApplyChannelTypes []uint8
+
+			MaxHandleReadyCountOfBatch int
+
+			ProposeResultChannelBuffer int
+
+			MaxConcurrentProposes   int
+			ProposeConcurrencyBlock bool
+
+			ProposeAuditOn bool
+
+			ReplicaSetPolicy string
+			NodeDomains      map[uint64]string
+
+			ChannelProfiles map[uint8]ChannelProfileName
 		}{
-			NodeId:                 1001,
-			Addr:                   "tcp://0.0.0.0:11110",
-			ServerAddr:             "",
-			ReqTimeout:             time.Second * 10,
-			Role:                   RoleReplica,
-			SlotCount:              64,
-			SlotReplicaCount:       3,
-			ChannelReplicaCount:    3,
-			TickInterval:           time.Millisecond * 150,
-			HeartbeatIntervalTick:  1,
-			ElectionIntervalTick:   10,
-			ChannelReactorSubCount: 64,
-			SlotReactorSubCount:    64,
-			PongMaxTick:            30,
+			NodeId:                  1001,
+			Addr:                    "tcp://0.0.0.0:11110",
+			ServerAddr:              "",
+			ReqTimeout:              time.Second * 10,
+			Role:                    RoleReplica,
+			SlotCount:               64,
+			SlotReplicaCount:        3,
+			ChannelReplicaCount:     3,
+			TickInterval:            time.Millisecond * 150,
+			HeartbeatIntervalTick:   1,
+			ElectionIntervalTick:    10,
+			ElectionGraceTick:       0,
+			ChannelReactorSubCount:  64,
+			SlotReactorSubCount:     64,
+			PongMaxTick:             30,
+			ChannelCreateRate:       0,
+			ChannelCreateBurst:      0,
+			ChannelCreateMaxWait:    time.Second,
+			MaxApplyLag:             0,
+			MaxWriteBytesPerSecond:  0,
+			ProposeRetryOnNotLeader: false,
+			ProposeRetryMaxBackoff:  time.Millisecond * 500,
+			ForwardTimeout:          0,
+			ElectionStuckThreshold:  time.Second * 30,
+			LeaderTransferGrace:     0,
+			SnapshotLogThreshold:    0,
+			LeaderFlappingThreshold: 5,
+			LeaderFlappingWindow:    time.Minute,
+			IdleHeartbeatTick:       0,
+			IdleHeartbeatMultiple:   4,
+			DiskMinFreeBytes:        1024 * 1024 * 1024, // 1G
+			DiskCheckInterval:       time.Second * 10,
+			IdleSweepPaused:         false,
+			ProposeAuditOn:          false,
+
+			ElectionPauseMaxDuration: time.Minute * 30,
+
+			InboundMessageRate:  0,
+			InboundMessageBurst: 0,
+
+			MaxMessageSize: 32 * 1024 * 1024, // 32M
+
+			ProposeResultChannelBuffer: 10000,
 		},
 		Trace: struct {
-			Endpoint         string
-			ServiceName      string
-			ServiceHostName  string
-			PrometheusApiUrl string
-			SampleRate       float64
+			Endpoint             string
+			ServiceName          string
+			ServiceHostName      string
+			PrometheusApiUrl     string
+			SampleRate           float64
+			ChannelTopN          int
+			LogSizeMetricsOn     bool
+			ProposeAckSampleRate float64
 		}{
 			Endpoint:         "",
 			ServiceName:      "wukongim",
 			ServiceHostName:  "imnode",
 			PrometheusApiUrl: "http://127.0.0.1:9090",
 			SampleRate:       1,
+			ChannelTopN:      10,
 		},
 		Reactor: struct {
 			ChannelSubCount             int
@@ -415,6 +579,13 @@ func NewOptions(op ...Option) *Options {
 			ChannelDeadlineTick         int
 			TagCheckIntervalTick        int
 			CheckUserLeaderIntervalTick int
+			SendackBatchWindow          time.Duration
+			MaxForwardQueueSize         int
+			ForwardOverflowPolicy       ForwardOverflowPolicy
+			StorageOpenTimeout          time.Duration
+			ChannelStepQueueSize        int
+			StepWaitTimeout             time.Duration
+			PanicHandler                func(channelId string, channelType uint8, recovered interface{})
 		}{
 			ChannelSubCount:             64,
 			ChannelProcessIntervalTick:  1,
@@ -425,6 +596,12 @@ func NewOptions(op ...Option) *Options {
 			ChannelDeadlineTick:         600,
 			TagCheckIntervalTick:        10,
 			CheckUserLeaderIntervalTick: 10,
+			SendackBatchWindow:          0,
+			MaxForwardQueueSize:         0,
+			ForwardOverflowPolicy:       ForwardOverflowReject,
+			StorageOpenTimeout:          time.Second * 10,
+			ChannelStepQueueSize:        1024 * 10,
+			StepWaitTimeout:             time.Second * 5,
 		},
 		Process: struct {
 			AuthPoolSize int
@@ -654,6 +831,80 @@ func (o *Options) ConfigureWithViper(vp *viper.Viper) {
 	o.Cluster.ChannelReplicaCount = o.getInt("cluster.channelReplicaCount", o.Cluster.ChannelReplicaCount)
 	o.Cluster.ServerAddr = o.getString("cluster.serverAddr", o.Cluster.ServerAddr)
 	o.Cluster.PongMaxTick = o.getInt("cluster.pongMaxTick", o.Cluster.PongMaxTick)
+	o.Cluster.ChannelCreateRate = o.getInt("cluster.channelCreateRate", o.Cluster.ChannelCreateRate)
+	o.Cluster.ChannelCreateBurst = o.getInt("cluster.channelCreateBurst", o.Cluster.ChannelCreateBurst)
+	o.Cluster.ChannelCreateMaxWait = o.getDuration("cluster.channelCreateMaxWait", o.Cluster.ChannelCreateMaxWait)
+	o.Cluster.MaxApplyLag = o.getUint64("cluster.maxApplyLag", o.Cluster.MaxApplyLag)
+	o.Cluster.MaxWriteBytesPerSecond = o.getInt("cluster.maxWriteBytesPerSecond", o.Cluster.MaxWriteBytesPerSecond)
+	o.Cluster.ProposeRetryOnNotLeader = o.getBool("cluster.proposeRetryOnNotLeader", o.Cluster.ProposeRetryOnNotLeader)
+	o.Cluster.ProposeRetryMaxBackoff = o.getDuration("cluster.proposeRetryMaxBackoff", o.Cluster.ProposeRetryMaxBackoff)
+	o.Cluster.ForwardTimeout = o.getDuration("cluster.forwardTimeout", o.Cluster.ForwardTimeout)
+	o.Cluster.ElectionStuckThreshold = o.getDuration("cluster.electionStuckThreshold", o.Cluster.ElectionStuckThreshold)
+	o.Cluster.LeaderTransferGrace = o.getDuration("cluster.leaderTransferGrace", o.Cluster.LeaderTransferGrace)
+	o.Cluster.SnapshotLogThreshold = o.getUint64("cluster.snapshotLogThreshold", o.Cluster.SnapshotLogThreshold)
+	o.Cluster.LeaderFlappingThreshold = o.getInt("cluster.leaderFlappingThreshold", o.Cluster.LeaderFlappingThreshold)
+	o.Cluster.LeaderFlappingWindow = o.getDuration("cluster.leaderFlappingWindow", o.Cluster.LeaderFlappingWindow)
+	o.Cluster.IdleHeartbeatTick = o.getInt("cluster.idleHeartbeatTick", o.Cluster.IdleHeartbeatTick)
+	o.Cluster.IdleHeartbeatMultiple = o.getInt("cluster.idleHeartbeatMultiple", o.Cluster.IdleHeartbeatMultiple)
+	o.Cluster.DiskMinFreeBytes = o.getUint64("cluster.diskMinFreeBytes", o.Cluster.DiskMinFreeBytes)
+	o.Cluster.DiskCheckInterval = o.getDuration("cluster.diskCheckInterval", o.Cluster.DiskCheckInterval)
+	o.Cluster.IdleSweepPaused = o.getBool("cluster.idleSweepPaused", o.Cluster.IdleSweepPaused)
+	o.Cluster.ChannelInactiveTimeout = o.getDuration("cluster.channelInactiveTimeout", o.Cluster.ChannelInactiveTimeout)
+	o.Cluster.ElectionPauseMaxDuration = o.getDuration("cluster.electionPauseMaxDuration", o.Cluster.ElectionPauseMaxDuration)
+	o.Cluster.InboundMessageRate = o.getInt("cluster.inboundMessageRate", o.Cluster.InboundMessageRate)
+	o.Cluster.InboundMessageBurst = o.getInt("cluster.inboundMessageBurst", o.Cluster.InboundMessageBurst)
+	o.Cluster.MaxMessageSize = o.getUint64("cluster.maxMessageSize", o.Cluster.MaxMessageSize)
+	o.Cluster.LogCacheMaxBytes = o.getUint64("cluster.logCacheMaxBytes", o.Cluster.LogCacheMaxBytes)
+	o.Cluster.InlineApplyMaxLogs = o.getUint64("cluster.inlineApplyMaxLogs", o.Cluster.InlineApplyMaxLogs)
+	for _, channelType := range o.getIntSlice("cluster.inlineApplyChannelTypes") {
+		o.Cluster.InlineApplyChannelTypes = append(o.Cluster.InlineApplyChannelTypes, uint8(channelType))
+	}
+	for _, channelType := range o.getIntSlice("cluster.relaxedApplyChannelTypes") {
+		o.Cluster.RelaxedApplyChannelTypes = append(o.Cluster.RelaxedApplyChannelTypes, uint8(channelType))
+	}
+	o.Cluster.MaxHandleReadyCountOfBatch = o.getInt("cluster.maxHandleReadyCountOfBatch", o.Cluster.MaxHandleReadyCountOfBatch)
+	o.Cluster.ProposeResultChannelBuffer = o.getInt("cluster.proposeResultChannelBuffer", o.Cluster.ProposeResultChannelBuffer)
+	o.Cluster.MaxConcurrentProposes = o.getInt("cluster.maxConcurrentProposes", o.Cluster.MaxConcurrentProposes)
+	o.Cluster.ProposeConcurrencyBlock = o.getBool("cluster.proposeConcurrencyBlock", o.Cluster.ProposeConcurrencyBlock)
+	o.Cluster.DisableProposeOnUnappliedConfig = o.getBool("cluster.disableProposeOnUnappliedConfig", o.Cluster.DisableProposeOnUnappliedConfig)
+	o.Cluster.ProposeAuditOn = o.getBool("cluster.proposeAuditOn", o.Cluster.ProposeAuditOn)
+	o.Cluster.ReplicaSetPolicy = o.getString("cluster.replicaSetPolicy", o.Cluster.ReplicaSetPolicy)
+	nodeDomains := o.getStringSlice("cluster.nodeDomains") // 格式为： nodeID@domain 例如 1001@zone-a
+	for _, nodeDomainStr := range nodeDomains {
+		nodeDomainStrs := strings.SplitN(nodeDomainStr, "@", 2)
+		if len(nodeDomainStrs) != 2 || strings.TrimSpace(nodeDomainStrs[1]) == "" {
+			continue
+		}
+		nodeID, err := strconv.ParseUint(strings.TrimSpace(nodeDomainStrs[0]), 10, 64)
+		if err != nil {
+			continue
+		}
+		if o.Cluster.NodeDomains == nil {
+			o.Cluster.NodeDomains = make(map[uint64]string)
+		}
+		o.Cluster.NodeDomains[nodeID] = strings.TrimSpace(nodeDomainStrs[1])
+	}
+	channelProfiles := o.getStringSlice("cluster.channelProfiles") // 格式为： channelType@profile 例如 99@control
+	for _, channelProfileStr := range channelProfiles {
+		channelProfileStrs := strings.SplitN(channelProfileStr, "@", 2)
+		if len(channelProfileStrs) != 2 {
+			wklog.Panic("cluster.channelProfiles format must be channelType@profile, but got " + channelProfileStr)
+		}
+		channelType, err := strconv.ParseUint(strings.TrimSpace(channelProfileStrs[0]), 10, 8)
+		if err != nil {
+			wklog.Panic("cluster.channelProfiles channelType must be 0-255, but got " + channelProfileStr)
+		}
+		profile := ChannelProfileName(strings.TrimSpace(channelProfileStrs[1]))
+		switch profile {
+		case ChannelProfileControl, ChannelProfileBulk:
+		default:
+			wklog.Panic("cluster.channelProfiles profile must be control or bulk, but got " + channelProfileStr)
+		}
+		if o.Cluster.ChannelProfiles == nil {
+			o.Cluster.ChannelProfiles = make(map[uint8]ChannelProfileName)
+		}
+		o.Cluster.ChannelProfiles[uint8(channelType)] = profile
+	}
 
 	o.Cluster.ReqTimeout = o.getDuration("cluster.reqTimeout", o.Cluster.ReqTimeout)
 	o.Cluster.Seed = o.getString("cluster.seed", o.Cluster.Seed)
@@ -684,6 +935,7 @@ func (o *Options) ConfigureWithViper(vp *viper.Viper) {
 	}
 	o.Cluster.TickInterval = o.getDuration("cluster.tickInterval", o.Cluster.TickInterval)
 	o.Cluster.ElectionIntervalTick = o.getInt("cluster.electionIntervalTick", o.Cluster.ElectionIntervalTick)
+	o.Cluster.ElectionGraceTick = o.getInt("cluster.electionGraceTick", o.Cluster.ElectionGraceTick)
 	o.Cluster.HeartbeatIntervalTick = o.getInt("cluster.heartbeatIntervalTick", o.Cluster.HeartbeatIntervalTick)
 	o.Cluster.ChannelReactorSubCount = o.getInt("cluster.channelReactorSubCount", o.Cluster.ChannelReactorSubCount)
 	o.Cluster.SlotReactorSubCount = o.getInt("cluster.slotReactorSubCount", o.Cluster.SlotReactorSubCount)
@@ -695,6 +947,9 @@ func (o *Options) ConfigureWithViper(vp *viper.Viper) {
 	o.Trace.ServiceHostName = o.getString("trace.serviceHostName", fmt.Sprintf("%s[%d]", o.Trace.ServiceName, o.Cluster.NodeId))
 	o.Trace.PrometheusApiUrl = o.getString("trace.prometheusApiUrl", o.Trace.PrometheusApiUrl)
 	o.Trace.SampleRate = o.getFloat64("trace.sampleRate", o.Trace.SampleRate)
+	o.Trace.ChannelTopN = o.getInt("trace.channelTopN", o.Trace.ChannelTopN)
+	o.Trace.LogSizeMetricsOn = o.getBool("trace.logSizeMetricsOn", o.Trace.LogSizeMetricsOn)
+	o.Trace.ProposeAckSampleRate = o.getFloat64("trace.proposeAckSampleRate", o.Trace.ProposeAckSampleRate)
 
 	// =================== deliver ===================
 	o.Deliver.DeliverrCount = o.getInt("deliver.deliverrCount", o.Deliver.DeliverrCount)
@@ -712,6 +967,18 @@ func (o *Options) ConfigureWithViper(vp *viper.Viper) {
 	o.Reactor.ChannelDeadlineTick = o.getInt("reactor.channelDeadlineTick", o.Reactor.ChannelDeadlineTick)
 	o.Reactor.TagCheckIntervalTick = o.getInt("reactor.tagCheckIntervalTick", o.Reactor.TagCheckIntervalTick)
 	o.Reactor.CheckUserLeaderIntervalTick = o.getInt("reactor.checkUserLeaderIntervalTick", o.Reactor.CheckUserLeaderIntervalTick)
+	o.Reactor.SendackBatchWindow = o.getDuration("reactor.sendackBatchWindow", o.Reactor.SendackBatchWindow)
+	o.Reactor.MaxForwardQueueSize = o.getInt("reactor.maxForwardQueueSize", o.Reactor.MaxForwardQueueSize)
+	o.Reactor.StorageOpenTimeout = o.getDuration("reactor.storageOpenTimeout", o.Reactor.StorageOpenTimeout)
+	o.Reactor.ChannelStepQueueSize = o.getInt("reactor.channelStepQueueSize", o.Reactor.ChannelStepQueueSize)
+	o.Reactor.StepWaitTimeout = o.getDuration("reactor.stepWaitTimeout", o.Reactor.StepWaitTimeout)
+	forwardOverflowPolicy := o.getString("reactor.forwardOverflowPolicy", string(o.Reactor.ForwardOverflowPolicy))
+	switch forwardOverflowPolicy {
+	case string(ForwardOverflowBlock):
+		o.Reactor.ForwardOverflowPolicy = ForwardOverflowBlock
+	default:
+		o.Reactor.ForwardOverflowPolicy = ForwardOverflowReject
+	}
 
 	// =================== db ===================
 	o.Db.ShardNum = o.getInt("db.shardNum", o.Db.ShardNum)
@@ -874,10 +1141,55 @@ func (o *Options) Check() error {
 	if o.Cluster.NodeId == 0 {
 		return errors.New("cluster.nodeId must be set")
 	}
+	if err := o.checkInitNodes(); err != nil {
+		return err
+	}
+	if o.Reactor.ChannelStepQueueSize <= 0 {
+		return fmt.Errorf("reactor.channelStepQueueSize must be greater than 0 (got %d)", o.Reactor.ChannelStepQueueSize)
+	}
 
 	return nil
 }
 
+// checkInitNodes 检查集群初始节点：节点id和地址都不能重复，本节点必须在列表里，并且地址和cluster.serverAddr一致
+func (o *Options) checkInitNodes() error {
+	if len(o.Cluster.InitNodes) == 0 {
+		return nil
+	}
+	idAddrs := make(map[uint64]string, len(o.Cluster.InitNodes))
+	addrIds := make(map[string]uint64, len(o.Cluster.InitNodes))
+	for _, node := range o.Cluster.InitNodes {
+		if node.Id == 0 {
+			return fmt.Errorf("cluster.initNodes: node id must not be 0 (addr %s)", node.ServerAddr)
+		}
+		addr := normalizeClusterAddr(node.ServerAddr)
+		if addr == "" {
+			return fmt.Errorf("cluster.initNodes: node %d has empty addr", node.Id)
+		}
+		if existAddr, ok := idAddrs[node.Id]; ok {
+			return fmt.Errorf("cluster.initNodes: duplicate node id %d (%s and %s)", node.Id, existAddr, node.ServerAddr)
+		}
+		if existId, ok := addrIds[addr]; ok {
+			return fmt.Errorf("cluster.initNodes: nodes %d and %d have the same addr %s", existId, node.Id, node.ServerAddr)
+		}
+		idAddrs[node.Id] = node.ServerAddr
+		addrIds[addr] = node.Id
+	}
+	localAddr, ok := idAddrs[o.Cluster.NodeId]
+	if !ok {
+		return fmt.Errorf("cluster.initNodes: local node %d (cluster.nodeId) is not in the list", o.Cluster.NodeId)
+	}
+	if strings.TrimSpace(o.Cluster.ServerAddr) != "" && normalizeClusterAddr(o.Cluster.ServerAddr) != normalizeClusterAddr(localAddr) {
+		return fmt.Errorf("cluster.initNodes: local node %d addr %s does not match cluster.serverAddr %s", o.Cluster.NodeId, localAddr, o.Cluster.ServerAddr)
+	}
+	return nil
+}
+
+// 统一地址格式（去掉tcp://前缀，主机名不区分大小写），用于比较两个地址是否相同
+func normalizeClusterAddr(addr string) string {
+	return strings.ToLower(strings.TrimPrefix(strings.TrimSpace(addr), "tcp://"))
+}
+
 func (o *Options) ClusterOn() bool {
 	return o.Cluster.NodeId != 0
 }
@@ -942,6 +1254,10 @@ func (o *Options) getStringSlice(key string) []string {
 	return o.vp.GetStringSlice(key)
 }
 
+func (o *Options) getIntSlice(key string) []int {
+	return o.vp.GetIntSlice(key)
+}
+
 func (o *Options) getInt(key string, defaultValue int) int {
 	v := o.vp.GetInt(key)
 	if v == 0 {
@@ -1238,6 +1554,50 @@ func WithChannelCmdSuffix(cmdSuffix string) Option {
 	}
 }
 
+// WithSendackBatching 开启发送回执合并，window为合并窗口
+// 协议没有批量回执包，合并只发生在写入层：同一个连接的多个回执包一次写入，合并效果见app_sendack_coalesced_write_count和app_sendack_saved_write_count指标
+func WithSendackBatching(window time.Duration) Option {
+	return func(opts *Options) {
+		opts.Reactor.SendackBatchWindow = window
+	}
+}
+
+// WithShardStorageOpenTimeout 设置频道初始化时打开存储的超时时间
+func WithShardStorageOpenTimeout(timeout time.Duration) Option {
+	return func(opts *Options) {
+		opts.Reactor.StorageOpenTimeout = timeout
+	}
+}
+
+// WithReactorChannelStepQueueSize 设置每个channel reactor sub待处理的频道事件队列大小
+func WithReactorChannelStepQueueSize(size int) Option {
+	return func(opts *Options) {
+		opts.Reactor.ChannelStepQueueSize = size
+	}
+}
+
+// WithReactorStepWaitTimeout 设置提交频道事件并等待处理完成的默认超时时间
+func WithReactorStepWaitTimeout(timeout time.Duration) Option {
+	return func(opts *Options) {
+		opts.Reactor.StepWaitTimeout = timeout
+	}
+}
+
+// WithReactorPanicHandler 设置频道处理逻辑panic时的回调
+func WithReactorPanicHandler(f func(channelId string, channelType uint8, recovered interface{})) Option {
+	return func(opts *Options) {
+		opts.Reactor.PanicHandler = f
+	}
+}
+
+// WithMaxForwardQueueSize 设置代理节点转发队列的大小和队列满了时的处理策略
+func WithMaxForwardQueueSize(size int, policy ForwardOverflowPolicy) Option {
+	return func(opts *Options) {
+		opts.Reactor.MaxForwardQueueSize = size
+		opts.Reactor.ForwardOverflowPolicy = policy
+	}
+}
+
 func WithConnIdleTime(connIdleTime time.Duration) Option {
 	return func(opts *Options) {
 		opts.ConnIdleTime = connIdleTime
@@ -1478,6 +1838,12 @@ func WithClusterHeartbeatIntervalTick(heartbeatIntervalTick int) Option {
 	}
 }
 
+func WithClusterElectionGraceTick(tick int) Option {
+	return func(opts *Options) {
+		opts.Cluster.ElectionGraceTick = tick
+	}
+}
+
 func WithClusterElectionIntervalTick(electionIntervalTick int) Option {
 	return func(opts *Options) {
 		opts.Cluster.ElectionIntervalTick = electionIntervalTick
@@ -1509,6 +1875,186 @@ func WithClusterPongMaxTick(pongMaxTick int) Option {
 	}
 }
 
+func WithClusterChannelCreateRate(rate int, burst int, maxWait time.Duration) Option {
+	return func(opts *Options) {
+		opts.Cluster.ChannelCreateRate = rate
+		opts.Cluster.ChannelCreateBurst = burst
+		opts.Cluster.ChannelCreateMaxWait = maxWait
+	}
+}
+
+func WithClusterMaxApplyLag(lag uint64) Option {
+	return func(opts *Options) {
+		opts.Cluster.MaxApplyLag = lag
+	}
+}
+
+func WithClusterMaxWriteBytesPerSecond(n int) Option {
+	return func(opts *Options) {
+		opts.Cluster.MaxWriteBytesPerSecond = n
+	}
+}
+
+func WithClusterProposeRetryOnNotLeader(on bool, maxBackoff time.Duration) Option {
+	return func(opts *Options) {
+		opts.Cluster.ProposeRetryOnNotLeader = on
+		opts.Cluster.ProposeRetryMaxBackoff = maxBackoff
+	}
+}
+
+func WithClusterForwardTimeout(timeout time.Duration) Option {
+	return func(opts *Options) {
+		opts.Cluster.ForwardTimeout = timeout
+	}
+}
+
+func WithClusterElectionStuckThreshold(threshold time.Duration) Option {
+	return func(opts *Options) {
+		opts.Cluster.ElectionStuckThreshold = threshold
+	}
+}
+
+func WithClusterLeaderTransferGrace(grace time.Duration) Option {
+	return func(opts *Options) {
+		opts.Cluster.LeaderTransferGrace = grace
+	}
+}
+
+func WithClusterSnapshotLogThreshold(n uint64) Option {
+	return func(opts *Options) {
+		opts.Cluster.SnapshotLogThreshold = n
+	}
+}
+
+func WithClusterLeaderFlapping(threshold int, window time.Duration) Option {
+	return func(opts *Options) {
+		opts.Cluster.LeaderFlappingThreshold = threshold
+		opts.Cluster.LeaderFlappingWindow = window
+	}
+}
+
+func WithClusterIdleHeartbeat(idleTick int, multiple int) Option {
+	return func(opts *Options) {
+		opts.Cluster.IdleHeartbeatTick = idleTick
+		opts.Cluster.IdleHeartbeatMultiple = multiple
+	}
+}
+
+func WithClusterDiskGuard(minFreeBytes uint64, checkInterval time.Duration) Option {
+	return func(opts *Options) {
+		opts.Cluster.DiskMinFreeBytes = minFreeBytes
+		opts.Cluster.DiskCheckInterval = checkInterval
+	}
+}
+
+func WithClusterIdleSweepPaused(paused bool) Option {
+	return func(opts *Options) {
+		opts.Cluster.IdleSweepPaused = paused
+	}
+}
+
+func WithClusterChannelInactiveTimeout(timeout time.Duration) Option {
+	return func(opts *Options) {
+		opts.Cluster.ChannelInactiveTimeout = timeout
+	}
+}
+
+func WithClusterElectionPauseMaxDuration(d time.Duration) Option {
+	return func(opts *Options) {
+		opts.Cluster.ElectionPauseMaxDuration = d
+	}
+}
+
+func WithClusterInboundMessageRate(rate int, burst int) Option {
+	return func(opts *Options) {
+		opts.Cluster.InboundMessageRate = rate
+		opts.Cluster.InboundMessageBurst = burst
+	}
+}
+
+func WithClusterMaxMessageSize(size uint64) Option {
+	return func(opts *Options) {
+		opts.Cluster.MaxMessageSize = size
+	}
+}
+
+func WithClusterLogCacheMaxBytes(maxBytes uint64) Option {
+	return func(opts *Options) {
+		opts.Cluster.LogCacheMaxBytes = maxBytes
+	}
+}
+
+func WithClusterInlineApplyMaxLogs(n uint64) Option {
+	return func(opts *Options) {
+		opts.Cluster.InlineApplyMaxLogs = n
+	}
+}
+
+func WithClusterInlineApplyChannelTypes(channelTypes ...uint8) Option {
+	return func(opts *Options) {
+		opts.Cluster.InlineApplyChannelTypes = channelTypes
+	}
+}
+
+func WithClusterRelaxedApplyChannelTypes(channelTypes ...uint8) Option {
+	return func(opts *Options) {
+		opts.Cluster.RelaxedApplyChannelTypes = channelTypes
+	}
+}
+
+func WithClusterMaxHandleReadyCountOfBatch(n int) Option {
+	return func(opts *Options) {
+		opts.Cluster.MaxHandleReadyCountOfBatch = n
+	}
+}
+
+func WithClusterProposeResultChannelBuffer(size int) Option {
+	return func(opts *Options) {
+		opts.Cluster.ProposeResultChannelBuffer = size
+	}
+}
+
+func WithClusterMaxConcurrentProposes(n int, block bool) Option {
+	return func(opts *Options) {
+		opts.Cluster.MaxConcurrentProposes = n
+		opts.Cluster.ProposeConcurrencyBlock = block
+	}
+}
+
+func WithClusterDisableProposeOnUnappliedConfig(on bool) Option {
+	return func(opts *Options) {
+		opts.Cluster.DisableProposeOnUnappliedConfig = on
+	}
+}
+
+func WithClusterProposeAuditOn(on bool) Option {
+	return func(opts *Options) {
+		opts.Cluster.ProposeAuditOn = on
+	}
+}
+
+func WithClusterReplicaSetPolicy(policy string) Option {
+	return func(opts *Options) {
+		opts.Cluster.ReplicaSetPolicy = policy
+	}
+}
+
+func WithClusterNodeDomains(nodeDomains map[uint64]string) Option {
+	return func(opts *Options) {
+		opts.Cluster.NodeDomains = nodeDomains
+	}
+}
+
+// WithClusterChannelProfile 设置频道类型使用的内置提案配置
+func WithClusterChannelProfile(channelType uint8, profile ChannelProfileName) Option {
+	return func(opts *Options) {
+		if opts.Cluster.ChannelProfiles == nil {
+			opts.Cluster.ChannelProfiles = make(map[uint8]ChannelProfileName)
+		}
+		opts.Cluster.ChannelProfiles[channelType] = profile
+	}
+}
+
 func WithTraceEndpoint(endpoint string) Option {
 	return func(opts *Options) {
 		opts.Trace.Endpoint = endpoint
@@ -1533,6 +2079,18 @@ func WithTracePrometheusApiUrl(prometheusApiUrl string) Option {
 	}
 }
 
+func WithTraceLogSizeMetricsOn(on bool) Option {
+	return func(opts *Options) {
+		opts.Trace.LogSizeMetricsOn = on
+	}
+}
+
+func WithTraceProposeAckSampleRate(rate float64) Option {
+	return func(opts *Options) {
+		opts.Trace.ProposeAckSampleRate = rate
+	}
+}
+
 func WithReactorChannelSubCount(channelSubCount int) Option {
 	return func(opts *Options) {
 		opts.Reactor.ChannelSubCount = channelSubCount
@@ -0,0 +1,99 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOptionsCheckInitNodes(t *testing.T) {
+	tests := []struct {
+		name       string
+		nodeId     uint64
+		serverAddr string
+		nodes      []*Node
+		err        string
+	}{
+		{
+			name:   "single node",
+			nodeId: 1001,
+		},
+		{
+			name:       "ok",
+			nodeId:     1001,
+			serverAddr: "127.0.0.1:11110",
+			nodes:      []*Node{{Id: 1001, ServerAddr: "tcp://127.0.0.1:11110"}, {Id: 1002, ServerAddr: "127.0.0.1:11111"}},
+		},
+		{
+			name:   "duplicate node id",
+			nodeId: 1001,
+			nodes:  []*Node{{Id: 1001, ServerAddr: "127.0.0.1:11110"}, {Id: 1001, ServerAddr: "127.0.0.1:11111"}},
+			err:    "cluster.initNodes: duplicate node id 1001 (127.0.0.1:11110 and 127.0.0.1:11111)",
+		},
+		{
+			name:   "duplicate addr",
+			nodeId: 1001,
+			nodes:  []*Node{{Id: 1001, ServerAddr: "node1.wk.local:11110"}, {Id: 1002, ServerAddr: "tcp://Node1.wk.local:11110"}},
+			err:    "cluster.initNodes: nodes 1001 and 1002 have the same addr tcp://Node1.wk.local:11110",
+		},
+		{
+			name:   "local node missing",
+			nodeId: 1003,
+			nodes:  []*Node{{Id: 1001, ServerAddr: "127.0.0.1:11110"}, {Id: 1002, ServerAddr: "127.0.0.1:11111"}},
+			err:    "cluster.initNodes: local node 1003 (cluster.nodeId) is not in the list",
+		},
+		{
+			name:       "local node wrong addr",
+			nodeId:     1001,
+			serverAddr: "127.0.0.1:11110",
+			nodes:      []*Node{{Id: 1001, ServerAddr: "127.0.0.1:11111"}, {Id: 1002, ServerAddr: "127.0.0.1:11112"}},
+			err:        "cluster.initNodes: local node 1001 addr 127.0.0.1:11111 does not match cluster.serverAddr 127.0.0.1:11110",
+		},
+		{
+			name:   "zero node id",
+			nodeId: 1001,
+			nodes:  []*Node{{Id: 1001, ServerAddr: "127.0.0.1:11110"}, {Id: 0, ServerAddr: "127.0.0.1:11111"}},
+			err:    "cluster.initNodes: node id must not be 0 (addr 127.0.0.1:11111)",
+		},
+		{
+			name:   "empty addr",
+			nodeId: 1001,
+			nodes:  []*Node{{Id: 1001, ServerAddr: "127.0.0.1:11110"}, {Id: 1002, ServerAddr: " "}},
+			err:    "cluster.initNodes: node 1002 has empty addr",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := NewOptions(WithClusterNodeId(tt.nodeId), WithClusterServerAddr(tt.serverAddr), WithClusterInitNodes(tt.nodes))
+			err := opts.Check()
+			if tt.err == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.EqualError(t, err, tt.err)
+		})
+	}
+}
+
+func TestOptionsCheckChannelStepQueueSize(t *testing.T) {
+	opts := NewOptions(WithClusterNodeId(1001), WithReactorChannelStepQueueSize(0))
+	assert.EqualError(t, opts.Check(), "reactor.channelStepQueueSize must be greater than 0 (got 0)")
+
+	opts = NewOptions(WithClusterNodeId(1001), WithReactorChannelStepQueueSize(1))
+	assert.NoError(t, opts.Check())
+}
+
+func TestOptionsChannelProfiles(t *testing.T) {
+	vp := viper.New()
+	vp.Set("rootDir", t.TempDir())
+	vp.Set("cluster.channelProfiles", []string{"99@control", " 2 @ bulk "})
+	opts := NewOptions()
+	opts.ConfigureWithViper(vp)
+	assert.Equal(t, map[uint8]ChannelProfileName{99: ChannelProfileControl, 2: ChannelProfileBulk}, opts.Cluster.ChannelProfiles)
+
+	vp.Set("cluster.channelProfiles", []string{"2@unknown"})
+	assert.Panics(t, func() {
+		NewOptions().ConfigureWithViper(vp)
+	})
+}
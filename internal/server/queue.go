@@ -46,12 +46,13 @@ func (c *channelList) add(ch *channel) {
 	c.count++
 }
 
-func (c *channelList) remove(key string) *channel {
+// removeChannel 移除指定的频道对象（同key的新频道不会被移除）
+func (c *channelList) removeChannel(ch *channel) bool {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	node := c.head
 	for node != nil {
-		if node.key == key {
+		if node.ch == ch {
 			if node.pre == nil {
 				c.head = node.next
 			} else {
@@ -63,11 +64,11 @@ func (c *channelList) remove(key string) *channel {
 				node.next.pre = node.pre
 			}
 			c.count--
-			return node.ch
+			return true
 		}
 		node = node.next
 	}
-	return nil
+	return false
 }
 
 func (c *channelList) get(key string) *channel {
@@ -95,6 +95,8 @@ func New(opts *Options) *Server {
 			trace.WithServiceName(s.opts.Trace.ServiceName),
 			trace.WithServiceHostName(s.opts.Trace.ServiceHostName),
 			trace.WithPrometheusApiUrl(s.opts.Trace.PrometheusApiUrl),
+			trace.WithChannelTopN(s.opts.Trace.ChannelTopN),
+			trace.WithLogSizeMetricsOn(s.opts.Trace.LogSizeMetricsOn),
 		))
 	trace.SetGlobalTrace(s.trace)
 
@@ -149,6 +151,27 @@ func New(opts *Options) *Server {
 	if s.opts.Cluster.Role == RoleProxy {
 		role = pb.NodeRole_NodeRoleProxy
 	}
+	proposeAuditPath := ""
+	if s.opts.Cluster.ProposeAuditOn {
+		proposeAuditPath = path.Join(opts.DataDir, "cluster", "audit", "propose.log")
+	}
+	replicaSetPolicy := cluster.ReplicaSetPolicyRandom
+	if s.opts.Cluster.ReplicaSetPolicy == cluster.ReplicaSetPolicySpreadDomains.String() {
+		replicaSetPolicy = cluster.ReplicaSetPolicySpreadDomains
+	}
+	channelProfiles := make(map[uint8]cluster.ChannelProfile, len(s.opts.Cluster.ChannelProfiles))
+	for channelType, profile := range s.opts.Cluster.ChannelProfiles {
+		switch profile {
+		case ChannelProfileControl:
+			channelProfiles[channelType] = cluster.ChannelProfileControl
+		case ChannelProfileBulk:
+			channelProfiles[channelType] = cluster.ChannelProfileBulk
+		}
+	}
+	proposeConcurrencyMode := cluster.ProposeConcurrencyReject
+	if s.opts.Cluster.ProposeConcurrencyBlock {
+		proposeConcurrencyMode = cluster.ProposeConcurrencyBlock
+	}
 	clusterServer := cluster.New(
 		cluster.NewOptions(
 			cluster.WithNodeId(s.opts.Cluster.NodeId),
@@ -173,12 +196,43 @@ func New(opts *Options) *Server {
 			}),
 			cluster.WithChannelClusterStorage(clusterstore.NewChannelClusterConfigStore(s.store)),
 			cluster.WithElectionIntervalTick(s.opts.Cluster.ElectionIntervalTick),
+			cluster.WithElectionGraceTick(s.opts.Cluster.ElectionGraceTick),
 			cluster.WithHeartbeatIntervalTick(s.opts.Cluster.HeartbeatIntervalTick),
 			cluster.WithTickInterval(s.opts.Cluster.TickInterval),
 			cluster.WithChannelReactorSubCount(s.opts.Cluster.ChannelReactorSubCount),
 			cluster.WithSlotReactorSubCount(s.opts.Cluster.SlotReactorSubCount),
 			cluster.WithPongMaxTick(s.opts.Cluster.PongMaxTick),
+			cluster.WithMaxApplyLag(s.opts.Cluster.MaxApplyLag),
+			cluster.WithMaxWriteBytesPerSecond(s.opts.Cluster.MaxWriteBytesPerSecond),
+			cluster.WithProposeRetryOnNotLeader(s.opts.Cluster.ProposeRetryOnNotLeader, s.opts.Cluster.ProposeRetryMaxBackoff),
+			cluster.WithForwardTimeout(s.opts.Cluster.ForwardTimeout),
+			cluster.WithElectionStuck(s.opts.Cluster.ElectionStuckThreshold, 0),
+			cluster.WithLeaderTransferGrace(s.opts.Cluster.LeaderTransferGrace),
+			cluster.WithSnapshotLogThreshold(s.opts.Cluster.SnapshotLogThreshold),
+			cluster.WithDisableProposeOnUnappliedConfig(s.opts.Cluster.DisableProposeOnUnappliedConfig),
+			cluster.WithLeaderFlapping(s.opts.Cluster.LeaderFlappingThreshold, s.opts.Cluster.LeaderFlappingWindow),
+			cluster.WithIdleHeartbeat(s.opts.Cluster.IdleHeartbeatTick, s.opts.Cluster.IdleHeartbeatMultiple),
+			cluster.WithDiskGuard(s.opts.Cluster.DiskMinFreeBytes, s.opts.Cluster.DiskCheckInterval),
+			cluster.WithIdleSweepPaused(s.opts.Cluster.IdleSweepPaused),
+			cluster.WithChannelInactiveTimeout(s.opts.Cluster.ChannelInactiveTimeout),
+			cluster.WithElectionPauseMaxDuration(s.opts.Cluster.ElectionPauseMaxDuration),
+			cluster.WithInboundMessageRate(s.opts.Cluster.InboundMessageRate, s.opts.Cluster.InboundMessageBurst),
+			cluster.WithMaxMessageSize(s.opts.Cluster.MaxMessageSize),
+			cluster.WithInlineApplyMaxLogs(s.opts.Cluster.InlineApplyMaxLogs),
+			cluster.WithInlineApplyChannelType(s.opts.Cluster.InlineApplyChannelTypes...),
+			cluster.WithRelaxedApplyChannelType(s.opts.Cluster.RelaxedApplyChannelTypes...),
+			cluster.WithMaxHandleReadyCountOfBatch(s.opts.Cluster.MaxHandleReadyCountOfBatch),
+			cluster.WithProposeResultChannelBuffer(s.opts.Cluster.ProposeResultChannelBuffer),
+			cluster.WithLogCacheMaxBytes(s.opts.Cluster.LogCacheMaxBytes),
+			cluster.WithMaxConcurrentProposes(s.opts.Cluster.MaxConcurrentProposes),
+			cluster.WithProposeConcurrencyMode(proposeConcurrencyMode),
+			cluster.WithProposeAudit(proposeAuditPath),
+			cluster.WithProposeAckTraceSampleRate(s.opts.Trace.ProposeAckSampleRate),
+			cluster.WithChannelCreateRate(s.opts.Cluster.ChannelCreateRate, s.opts.Cluster.ChannelCreateBurst, s.opts.Cluster.ChannelCreateMaxWait),
 			cluster.WithAuth(s.opts.Auth),
+			cluster.WithReplicaSetPolicy(replicaSetPolicy),
+			cluster.WithNodeDomains(s.opts.Cluster.NodeDomains),
+			cluster.WithChannelProfiles(channelProfiles),
 		),
 
 		// cluster.WithOnChannelMetaApply(func(channelID string, channelType uint8, logs []replica.Log) error {
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"time"
 
+	"github.com/WuKongIM/WuKongIM/pkg/trace"
 	"github.com/WuKongIM/WuKongIM/pkg/wkserver"
 	"github.com/WuKongIM/WuKongIM/pkg/wkserver/proto"
 	"github.com/WuKongIM/WuKongIM/pkg/wkutil"
@@ -86,16 +87,13 @@ func (s *Server) handleChannelForward(c *wkserver.Context) {
 		c.WriteErrorAndStatus(errors.New("not is leader"), proto.Status(errCodeNotIsChannelLeader))
 		return
 	}
-	for _, reactorChannelMessage := range req.Messages {
-		sendPacket := reactorChannelMessage.SendPacket
-		// 提案频道消息
-		ch := s.channelReactor.loadOrCreateChannel(req.ChannelId, req.ChannelType)
-		_, err = ch.proposeSend(reactorChannelMessage.ctx, reactorChannelMessage.FromUid, reactorChannelMessage.FromDeviceId, reactorChannelMessage.FromConnId, reactorChannelMessage.FromNodeId, false, sendPacket)
-		if err != nil {
-			s.Error("handleChannelForward: proposeSend failed")
-			c.WriteErr(err)
-			return
-		}
+	// 提案频道消息（一个请求里的消息一次提交给频道）
+	ch := s.channelReactor.loadOrCreateChannel(req.ChannelId, req.ChannelType)
+	err = ch.proposeSendBatch(req.Messages)
+	if err != nil {
+		s.Error("handleChannelForward: proposeSend failed")
+		c.WriteErr(err)
+		return
 	}
 
 	c.WriteOk()
@@ -147,6 +145,8 @@ func (s *Server) handleConnWrite(c *wkserver.Context) {
 		c.WriteOk()
 		return
 	}
+	trace.GlobalTrace.Metrics.Cluster().RecvPacketIncomingBytesAdd(int64(len(fowardWriteReq.Data)))
+	trace.GlobalTrace.Metrics.Cluster().RecvPacketIncomingCountAdd(int64(fowardWriteReq.RecvFrameCount))
 
 	conn := s.userReactor.getConnContextById(fowardWriteReq.Uid, fowardWriteReq.ConnId)
 	if conn == nil {
@@ -286,19 +286,34 @@ func (s *Server) handleDeliver(c *wkserver.Context) {
 		c.WriteErr(err)
 		return
 	}
-	for _, channelMsg := range channelMsgSet {
+	if err = s.deliverForwarded(channelMsgSet); err != nil {
+		s.Warn("handleDeliver failed", zap.Error(err), zap.Int("channelCount", len(channelMsgSet)))
+		c.WriteErr(err)
+		return
+	}
+	c.WriteOk()
+}
 
+// deliverForwarded 投递其他节点转发过来的消息，和本节点频道的投递一样计入投递排队数量（投递者处理完后减掉）
+// 投递者的队列都满了返回ErrDeliverQueueFull，由转发的节点重试
+func (s *Server) deliverForwarded(channelMsgSet ChannelMessagesSet) error {
+	for _, channelMsg := range channelMsgSet {
 		ch := s.channelReactor.loadOrCreateChannel(channelMsg.ChannelId, channelMsg.ChannelType)
-		s.deliverManager.deliver(&deliverReq{
+		req := &deliverReq{
 			channelId:   channelMsg.ChannelId,
 			channelType: channelMsg.ChannelType,
 			ch:          ch,
 			channelKey:  wkutil.ChannelToKey(channelMsg.ChannelId, channelMsg.ChannelType),
 			messages:    channelMsg.Messages,
 			tagKey:      channelMsg.TagKey,
-		})
+		}
+		s.channelReactor.deliverQueueDepthAdd(req.channelType, int64(len(req.messages)))
+		if !s.deliverManager.deliver(req) {
+			s.channelReactor.deliverQueueDepthAdd(req.channelType, -int64(len(req.messages)))
+			return ErrDeliverQueueFull
+		}
 	}
-	c.WriteOk()
+	return nil
 }
 
 func (s *Server) getNodeUidsByTag(c *wkserver.Context) {
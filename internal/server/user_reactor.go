@@ -197,3 +197,16 @@ func (u *userReactor) writePacketByConnId(uid string, connId int64, packet wkpro
 	}
 	return u.reactorSub(uid).writePacket(conn, packet)
 }
+
+// 将多个包合并成一次写入
+func (u *userReactor) writePacketsByConnId(uid string, connId int64, packets []wkproto.Frame) error {
+	if len(packets) == 0 {
+		return nil
+	}
+	conn := u.getConnContextById(uid, connId)
+	if conn == nil {
+		u.Error("conn not found", zap.String("uid", uid), zap.Int64("connId", connId), zap.String("frameType", packets[0].GetFrameType().String()))
+		return ErrConnNotFound
+	}
+	return conn.writePackets(packets)
+}
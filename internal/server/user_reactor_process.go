@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/WuKongIM/WuKongIM/pkg/trace"
 	"github.com/WuKongIM/WuKongIM/pkg/wkdb"
 	"github.com/WuKongIM/WuKongIM/pkg/wkserver/proto"
 	"github.com/WuKongIM/WuKongIM/pkg/wkutil"
@@ -706,6 +707,8 @@ func (r *userReactor) fowardWriteReq(nodeId uint64, req *FowardWriteReq) (proto.
 	if err != nil {
 		return 0, err
 	}
+	trace.GlobalTrace.Metrics.Cluster().RecvPacketOutgoingBytesAdd(int64(len(req.Data)))
+	trace.GlobalTrace.Metrics.Cluster().RecvPacketOutgoingCountAdd(int64(req.RecvFrameCount))
 	resp, err := r.s.cluster.RequestWithContext(timeoutCtx, nodeId, "/wk/connWrite", data)
 	if err != nil {
 		return 0, err
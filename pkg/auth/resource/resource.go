@@ -9,9 +9,17 @@ var Slot = slot{
 
 // 频道资源
 var ClusterChannel = channel{
-	Migrate: "clusterchannelMigrate", // 迁移频道
-	Start:   "clusterchannelStart",   // 启动频道
-	Stop:    "clusterchannelStop",    // 停止频道
+	Migrate:   "clusterchannelMigrate",   // 迁移频道
+	Start:     "clusterchannelStart",     // 启动频道
+	Stop:      "clusterchannelStop",      // 停止频道
+	Rebuild:   "clusterchannelRebuild",   // 重建频道
+	Debug:     "clusterchannelDebug",     // 频道调试监控
+	IdleSweep: "clusterchannelIdleSweep", // 暂停或恢复空闲频道回收
+}
+
+// 集群资源
+var Cluster = cluster{
+	ElectionPause: "clusterElectionPause", // 暂停或恢复集群选举
 }
 
 type slot struct {
@@ -19,9 +27,16 @@ type slot struct {
 }
 
 type channel struct {
-	Migrate Id
-	Start   Id
-	Stop    Id
+	Migrate   Id
+	Start     Id
+	Stop      Id
+	Rebuild   Id
+	Debug     Id
+	IdleSweep Id
+}
+
+type cluster struct {
+	ElectionPause Id
 }
 
 var All Id = "*"
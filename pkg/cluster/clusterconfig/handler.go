@@ -57,16 +57,27 @@ func newHandler(cfg *Config, storage *PebbleShardLogStorage, s *Server) *handler
 		replica.WithLogPrefix("config"),
 		replica.WithElectionOn(true),
 		replica.WithElectionIntervalTick(cfg.opts.ElectionIntervalTick),
+		replica.WithElectionGraceTick(cfg.opts.ElectionGraceTick),
+		replica.WithElectionPaused(cfg.opts.ElectionPaused),
 		replica.WithHeartbeatIntervalTick(cfg.opts.HeartbeatIntervalTick),
 		replica.WithStorage(h.storage),
 		replica.WithLastIndex(lastIndex),
 		replica.WithLastTerm(lastTerm),
 		replica.WithAppliedIndex(appliedIndex),
 		replica.WithAutoRoleSwith(true),
+		replica.WithCandidateHealthy(h.candidateHealthy),
 	)
 	return h
 }
 
+// 候选人是否健康，领导根据心跳标记了离线的节点视为不健康（频繁掉线的节点不适合当领导）
+func (h *handler) candidateHealthy(nodeId uint64) bool {
+	if !h.cfg.hasNode(nodeId) { // 配置里还没有此节点（比如集群初始化时），不做限制
+		return true
+	}
+	return h.cfg.nodeOnline(nodeId)
+}
+
 func (h *handler) updateConfig() {
 	nodes := h.cfg.allowVoteNodes()
 	replicas := make([]uint64, 0, len(nodes))
@@ -7,6 +7,7 @@ import (
 
 	"github.com/WuKongIM/WuKongIM/pkg/cluster/reactor"
 	"github.com/WuKongIM/WuKongIM/pkg/cluster/replica"
+	"github.com/WuKongIM/WuKongIM/pkg/trace"
 	"github.com/WuKongIM/WuKongIM/pkg/wkserver/proto"
 	"go.uber.org/zap"
 )
@@ -63,6 +64,8 @@ func (r *Request) GetLeaderTermStartIndex(req reactor.LeaderTermStartIndexReq) (
 	if err != nil {
 		return 0, err
 	}
+	trace.GlobalTrace.Metrics.Cluster().MsgLeaderTermStartIndexReqOutgoingCountAdd(trace.ClusterKindConfig, 1)
+	trace.GlobalTrace.Metrics.Cluster().MsgLeaderTermStartIndexReqOutgoingBytesAdd(trace.ClusterKindConfig, int64(len(reqBytes)))
 	resp, err := r.request(req.LeaderId, "/clusterconfig/leaderTermStartIndex", reqBytes)
 	if err != nil {
 		return 0, err
@@ -70,6 +73,8 @@ func (r *Request) GetLeaderTermStartIndex(req reactor.LeaderTermStartIndexReq) (
 	if resp.Status != proto.Status_OK {
 		return 0, fmt.Errorf("get leader term start index failed, status: %v", resp.Status)
 	}
+	trace.GlobalTrace.Metrics.Cluster().MsgLeaderTermStartIndexRespIncomingCountAdd(trace.ClusterKindConfig, 1)
+	trace.GlobalTrace.Metrics.Cluster().MsgLeaderTermStartIndexRespIncomingBytesAdd(trace.ClusterKindConfig, int64(len(resp.Body)))
 	if len(resp.Body) > 0 {
 		return binary.BigEndian.Uint64(resp.Body), nil
 	}
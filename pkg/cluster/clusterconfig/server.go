@@ -503,3 +503,11 @@ func (s *Server) AppliedLogIndex() (uint64, error) {
 func (s *Server) LastLogIndex() (uint64, error) {
 	return s.storage.LastIndex()
 }
+
+// LeaderLastLogIndex 领导的最新日志下标，当前节点不是领导时返回replica.ErrNotLeader
+func (s *Server) LeaderLastLogIndex() (uint64, error) {
+	if !s.IsLeader() {
+		return 0, replica.ErrNotLeader
+	}
+	return s.storage.LastIndex()
+}
@@ -6,6 +6,7 @@ import (
 
 	"github.com/WuKongIM/WuKongIM/pkg/cluster/reactor"
 	"github.com/WuKongIM/WuKongIM/pkg/cluster/replica"
+	"github.com/WuKongIM/WuKongIM/pkg/trace"
 	"github.com/WuKongIM/WuKongIM/pkg/wkserver"
 	"go.uber.org/zap"
 )
@@ -17,6 +18,9 @@ func (s *Server) setRoutes() {
 }
 
 func (s *Server) handleLeaderTermStartIndex(c *wkserver.Context) {
+	trace.GlobalTrace.Metrics.Cluster().MsgLeaderTermStartIndexReqIncomingCountAdd(trace.ClusterKindConfig, 1)
+	trace.GlobalTrace.Metrics.Cluster().MsgLeaderTermStartIndexReqIncomingBytesAdd(trace.ClusterKindConfig, int64(len(c.Body())))
+
 	req := &reactor.LeaderTermStartIndexReq{}
 	err := req.Unmarshal(c.Body())
 	if err != nil {
@@ -48,6 +52,8 @@ func (s *Server) handleLeaderTermStartIndex(c *wkserver.Context) {
 		binary.BigEndian.PutUint64(resultBytes, lastIndex)
 	}
 
+	trace.GlobalTrace.Metrics.Cluster().MsgLeaderTermStartIndexRespOutgoingCountAdd(trace.ClusterKindConfig, 1)
+	trace.GlobalTrace.Metrics.Cluster().MsgLeaderTermStartIndexRespOutgoingBytesAdd(trace.ClusterKindConfig, int64(len(resultBytes)))
 	c.Write(resultBytes)
 
 }
@@ -30,6 +30,8 @@ type Options struct {
 	TickInterval          time.Duration // 分布式tick间隔
 	HeartbeatIntervalTick int           // 心跳间隔tick
 	ElectionIntervalTick  int           // 选举间隔tick
+	ElectionGraceTick     int           // 跟随者与领导失联超过选举超时后，再等待领导恢复的tick次数，0表示不等待
+	ElectionPaused        func() bool   // 返回true时配置节点不发起新的选举（网络维护期间），nil表示不暂停
 
 }
 
@@ -129,6 +131,18 @@ func WithHeartbeatIntervalTick(heartbeatIntervalTick int) Option {
 	}
 }
 
+func WithElectionGraceTick(tick int) Option {
+	return func(o *Options) {
+		o.ElectionGraceTick = tick
+	}
+}
+
+func WithElectionPaused(f func() bool) Option {
+	return func(o *Options) {
+		o.ElectionPaused = f
+	}
+}
+
 func WithElectionIntervalTick(electionIntervalTick int) Option {
 	return func(o *Options) {
 		o.ElectionIntervalTick = electionIntervalTick
@@ -71,6 +71,8 @@ func New(opts *Options) *Server {
 		clusterconfig.WithOnAppliedConfig(s.onAppliedConfig),
 		clusterconfig.WithCluster(opts.Cluster),
 		clusterconfig.WithElectionIntervalTick(opts.ElectionIntervalTick),
+		clusterconfig.WithElectionGraceTick(opts.ElectionGraceTick),
+		clusterconfig.WithElectionPaused(opts.ElectionPaused),
 		clusterconfig.WithHeartbeatIntervalTick(opts.HeartbeatIntervalTick),
 		clusterconfig.WithTickInterval(opts.TickInterval),
 	))
@@ -245,6 +247,11 @@ func (s *Server) LastLogIndex() (uint64, error) {
 	return s.cfgServer.LastLogIndex()
 }
 
+// LeaderLastLogIndex 领导的最新日志下标，当前节点不是领导时返回replica.ErrNotLeader
+func (s *Server) LeaderLastLogIndex() (uint64, error) {
+	return s.cfgServer.LeaderLastLogIndex()
+}
+
 func (s *Server) NodeConfigVersion(nodeId uint64) uint64 {
 	return s.cfgServer.NodeConfigVersion(nodeId)
 }
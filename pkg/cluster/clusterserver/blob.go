@@ -0,0 +1,322 @@
+package cluster
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/WuKongIM/WuKongIM/pkg/cluster/reactor"
+	"github.com/WuKongIM/WuKongIM/pkg/cluster/replica"
+	"github.com/WuKongIM/WuKongIM/pkg/wklog"
+	"go.uber.org/zap"
+)
+
+// 超过LargeLogThreshold的日志数据不随日志复制，而是以内容寻址（sha256）的方式存到本地的blob目录，
+// 日志里只复制blob的引用，这样提案、追加队列、同步消息里只保留很小的引用数据。
+// 追随者收到同步响应时先从频道领导获取本地没有的blob（/channel/blob），日志在写入存储前（AppendLogBatch）再用本地的blob将引用还原成真实数据。
+// 领导读取日志用于同步时（getLogs），会再次将大日志转换成引用，所以blob只是一个可重建的缓存，过期后会被清理。
+
+var (
+	blobRefMagic = []byte{0x00, 'W', 'K', 'B', 'L', 'O', 'B', 0x00}
+	blobRefLen   = len(blobRefMagic) + sha256.Size
+
+	ErrBlobNotFound = errors.New("blob not found")
+)
+
+const (
+	blobHashCacheSize = 10000 // 同步日志的blob sha256缓存数量
+	blobFetchPoolSize = 100   // 追随者同时获取blob的同步响应数量
+)
+
+type blobStore struct {
+	dir string
+	wklog.Log
+}
+
+func newBlobStore(dir string) *blobStore {
+	return &blobStore{
+		dir: dir,
+		Log: wklog.NewWKLog(fmt.Sprintf("blobStore[%s]", dir)),
+	}
+}
+
+func (b *blobStore) open() error {
+	return os.MkdirAll(b.dir, 0755)
+}
+
+// put 存储数据，返回数据的sha256
+func (b *blobStore) put(data []byte) ([]byte, error) {
+	sum := sha256.Sum256(data)
+	p := b.path(sum[:])
+	if _, err := os.Stat(p); err == nil {
+		// 已存在，刷新修改时间，避免被清理
+		now := time.Now()
+		_ = os.Chtimes(p, now, now)
+		return sum[:], nil
+	}
+	tmp := p + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmp, p); err != nil {
+		return nil, err
+	}
+	return sum[:], nil
+}
+
+func (b *blobStore) has(hash []byte) bool {
+	_, err := os.Stat(b.path(hash))
+	return err == nil
+}
+
+func (b *blobStore) get(hash []byte) ([]byte, error) {
+	data, err := os.ReadFile(b.path(hash))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrBlobNotFound
+		}
+		return nil, err
+	}
+	return data, nil
+}
+
+// cleanExpired 清理超过retention没有被使用的blob
+func (b *blobStore) cleanExpired(retention time.Duration) {
+	entries, err := os.ReadDir(b.dir)
+	if err != nil {
+		b.Warn("read blob dir failed", zap.Error(err))
+		return
+	}
+	deadline := time.Now().Add(-retention)
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		if info.ModTime().Before(deadline) {
+			if err := os.Remove(filepath.Join(b.dir, entry.Name())); err != nil {
+				b.Warn("remove blob failed", zap.Error(err), zap.String("name", entry.Name()))
+			}
+		}
+	}
+}
+
+func (b *blobStore) path(hash []byte) string {
+	return filepath.Join(b.dir, hex.EncodeToString(hash))
+}
+
+func encodeBlobRef(hash []byte) []byte {
+	ref := make([]byte, 0, blobRefLen)
+	ref = append(ref, blobRefMagic...)
+	return append(ref, hash...)
+}
+
+// decodeBlobRef 如果数据是blob引用，返回blob的sha256
+func decodeBlobRef(data []byte) ([]byte, bool) {
+	if len(data) != blobRefLen || !bytes.HasPrefix(data, blobRefMagic) {
+		return nil, false
+	}
+	return data[len(blobRefMagic):], true
+}
+
+// offloadLargeLogs 将大日志的数据存到blob，日志里只保留引用（不修改传入的logs）
+func (s *Server) offloadLargeLogs(logs []replica.Log) ([]replica.Log, error) {
+	if s.blobStore == nil {
+		return logs, nil
+	}
+	return s.offloadLogs(logs, func(lg replica.Log) ([]byte, error) {
+		return s.blobStore.put(lg.Data)
+	})
+}
+
+// offloadSyncLogs 领导读取日志用于同步时将大日志转换成引用，同一条日志（下标和任期相同）只计算一次sha256
+func (s *Server) offloadSyncLogs(handleKey string, logs []replica.Log) ([]replica.Log, error) {
+	if s.blobStore == nil {
+		return logs, nil
+	}
+	return s.offloadLogs(logs, func(lg replica.Log) ([]byte, error) {
+		if s.blobHashCache == nil {
+			return s.blobStore.put(lg.Data)
+		}
+		cacheKey := fmt.Sprintf("%s:%d:%d", handleKey, lg.Index, lg.Term)
+		if hash, ok := s.blobHashCache.Get(cacheKey); ok {
+			return hash, nil
+		}
+		hash, err := s.blobStore.put(lg.Data)
+		if err != nil {
+			return nil, err
+		}
+		s.blobHashCache.Add(cacheKey, hash)
+		return hash, nil
+	})
+}
+
+func (s *Server) offloadLogs(logs []replica.Log, put func(lg replica.Log) ([]byte, error)) ([]replica.Log, error) {
+	var newLogs []replica.Log
+	for i, lg := range logs {
+		if uint64(len(lg.Data)) <= s.opts.LargeLogThreshold {
+			continue
+		}
+		if _, ok := decodeBlobRef(lg.Data); ok {
+			continue
+		}
+		hash, err := put(lg)
+		if err != nil {
+			return nil, err
+		}
+		if newLogs == nil {
+			newLogs = make([]replica.Log, len(logs))
+			copy(newLogs, logs)
+		}
+		newLogs[i].Data = encodeBlobRef(hash)
+	}
+	if newLogs == nil {
+		return logs, nil
+	}
+	return newLogs, nil
+}
+
+// resolveBlobLogs 将日志里的blob引用还原成真实数据，返回还原后的日志（不修改传入的logs，传入的日志还被副本内存里的日志引用）
+// 只读取本地的blob，追随者收到同步响应时已经把本地没有的blob获取到本地了（见prefetchBlobs），所以追加日志时不会请求网络
+func (s *Server) resolveBlobLogs(handleKey string, logs []replica.Log) ([]replica.Log, error) {
+	if s.blobStore == nil {
+		return logs, nil
+	}
+	var newLogs []replica.Log
+	for i, lg := range logs {
+		hash, ok := decodeBlobRef(lg.Data)
+		if !ok {
+			continue
+		}
+		data, err := s.blobStore.get(hash)
+		if err != nil {
+			s.Error("resolve blob failed", zap.Error(err), zap.String("handleKey", handleKey), zap.Uint64("index", lg.Index))
+			return nil, err
+		}
+		if newLogs == nil {
+			newLogs = make([]replica.Log, len(logs))
+			copy(newLogs, logs)
+		}
+		newLogs[i].Data = data
+	}
+	if newLogs == nil {
+		return logs, nil
+	}
+	return newLogs, nil
+}
+
+// missingBlobLogs 返回同步响应里引用的blob本地不存在的日志
+func (s *Server) missingBlobLogs(m reactor.Message) []replica.Log {
+	if s.blobStore == nil || m.MsgType != replica.MsgSyncResp {
+		return nil
+	}
+	var missing []replica.Log
+	for _, lg := range m.Logs {
+		hash, ok := decodeBlobRef(lg.Data)
+		if !ok || s.blobStore.has(hash) {
+			continue
+		}
+		missing = append(missing, lg)
+	}
+	return missing
+}
+
+// prefetchBlobs 从发送同步响应的领导获取本地没有的blob存到本地
+func (s *Server) prefetchBlobs(m reactor.Message, missing []replica.Log) error {
+	for _, lg := range missing {
+		hash, _ := decodeBlobRef(lg.Data)
+		data, err := s.requestBlob(m.From, m.HandlerKey, lg.Index, hash)
+		if err != nil {
+			return err
+		}
+		if _, err = s.blobStore.put(data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addChannelSyncRespWithBlobs 同步响应引用了本地没有的blob时，先在协程池里获取blob再交给频道，
+// 获取失败丢弃这次同步响应，追随者同步超时后会重新同步
+func (s *Server) addChannelSyncRespWithBlobs(m reactor.Message, missing []replica.Log) {
+	err := s.blobFetchPool.Submit(func() {
+		if err := s.prefetchBlobs(m, missing); err != nil {
+			s.Warn("prefetch blobs failed, drop sync resp", zap.Error(err), zap.String("handleKey", m.HandlerKey), zap.Uint64("from", m.From), zap.Uint64("index", m.Index))
+			return
+		}
+		s.channelManager.addMessage(m)
+	})
+	if err != nil {
+		s.Warn("blobFetchPool is busy, drop sync resp", zap.Error(err), zap.String("handleKey", m.HandlerKey), zap.Uint64("from", m.From))
+	}
+}
+
+func (s *Server) requestBlob(nodeId uint64, handleKey string, index uint64, hash []byte) ([]byte, error) {
+	if nodeId == 0 || nodeId == s.opts.NodeId {
+		return nil, ErrBlobNotFound
+	}
+	node := s.nodeManager.node(nodeId)
+	if node == nil {
+		return nil, ErrNodeNotFound
+	}
+	timeoutCtx, cancel := context.WithTimeout(s.cancelCtx, s.opts.ReqTimeout)
+	defer cancel()
+	data, err := node.requestBlob(timeoutCtx, &BlobReq{
+		HandleKey: handleKey,
+		Index:     index,
+		Hash:      hash,
+	})
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(data)
+	if !bytes.Equal(sum[:], hash) {
+		return nil, fmt.Errorf("blob hash mismatch, index:%d", index)
+	}
+	return data, nil
+}
+
+// 获取blob数据，blob被清理了则从日志存储里读取
+func (s *Server) loadBlob(handleKey string, index uint64, hash []byte) ([]byte, error) {
+	if s.blobStore == nil {
+		return nil, ErrBlobNotFound
+	}
+	data, err := s.blobStore.get(hash)
+	if err == nil {
+		return data, nil
+	}
+	if err != ErrBlobNotFound {
+		return nil, err
+	}
+	logs, err := s.opts.MessageLogStorage.Logs(handleKey, index, index+1, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(logs) == 0 {
+		return nil, ErrBlobNotFound
+	}
+	sum := sha256.Sum256(logs[0].Data)
+	if !bytes.Equal(sum[:], hash) {
+		return nil, ErrBlobNotFound
+	}
+	return logs[0].Data, nil
+}
+
+func (s *Server) blobCleanLoop() {
+	tk := time.NewTicker(s.opts.BlobRetention / 2)
+	defer tk.Stop()
+	for {
+		select {
+		case <-tk.C:
+			s.blobStore.cleanExpired(s.opts.BlobRetention)
+		case <-s.stopper.ShouldStop():
+			return
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package cluster
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"testing"
+
+	"github.com/WuKongIM/WuKongIM/pkg/cluster/reactor"
+	"github.com/WuKongIM/WuKongIM/pkg/cluster/replica"
+	"github.com/WuKongIM/WuKongIM/pkg/wklog"
+	lru "github.com/hashicorp/golang-lru/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOffloadAndResolveLargeLogs(t *testing.T) {
+	opts := NewOptions(WithLargeLogThreshold(16))
+	s := &Server{
+		opts:      opts,
+		blobStore: newBlobStore(t.TempDir()),
+	}
+	err := s.blobStore.open()
+	assert.NoError(t, err)
+
+	large := bytes.Repeat([]byte("a"), 1024)
+	logs := []replica.Log{
+		{Id: 1, Index: 1, Data: []byte("small")},
+		{Id: 2, Index: 2, Data: large},
+	}
+
+	offloaded, err := s.offloadLargeLogs(logs)
+	assert.NoError(t, err)
+
+	// 小日志不变，大日志只保留引用，并且不修改原日志
+	assert.Equal(t, []byte("small"), offloaded[0].Data)
+	assert.Equal(t, blobRefLen, len(offloaded[1].Data))
+	assert.Equal(t, large, logs[1].Data)
+
+	// 还原成真实数据，也不修改传入的日志
+	resolved, err := s.resolveBlobLogs("test", offloaded)
+	assert.NoError(t, err)
+	assert.Equal(t, large, resolved[1].Data)
+	assert.Equal(t, blobRefLen, len(offloaded[1].Data))
+}
+
+// 同步响应引用了本地没有的blob时需要先获取，本地有的不需要
+func TestMissingBlobLogs(t *testing.T) {
+	opts := NewOptions(WithLargeLogThreshold(16))
+	s := &Server{
+		opts:      opts,
+		blobStore: newBlobStore(t.TempDir()),
+		Log:       wklog.NewWKLog("test"),
+	}
+	assert.NoError(t, s.blobStore.open())
+
+	local := bytes.Repeat([]byte("a"), 1024)
+	hash, err := s.blobStore.put(local)
+	assert.NoError(t, err)
+	remoteHash := sha256.Sum256(bytes.Repeat([]byte("b"), 1024))
+
+	m := reactor.Message{
+		HandlerKey: "test",
+		Message: replica.Message{MsgType: replica.MsgSyncResp, From: 2, Logs: []replica.Log{
+			{Index: 1, Data: []byte("small")},
+			{Index: 2, Data: encodeBlobRef(hash)},
+			{Index: 3, Data: encodeBlobRef(remoteHash[:])},
+		}},
+	}
+	missing := s.missingBlobLogs(m)
+	assert.Len(t, missing, 1)
+	assert.Equal(t, uint64(3), missing[0].Index)
+
+	// 本地没有的blob追加时不会去请求网络，直接返回错误
+	_, err = s.resolveBlobLogs("test", m.Logs)
+	assert.Equal(t, ErrBlobNotFound, err)
+
+	// 不是同步响应不需要获取
+	m.MsgType = replica.MsgSyncReq
+	assert.Len(t, s.missingBlobLogs(m), 0)
+}
+
+// 同步读取日志时同一条日志只计算一次sha256
+func TestOffloadSyncLogsCacheHash(t *testing.T) {
+	opts := NewOptions(WithLargeLogThreshold(16))
+	s := &Server{
+		opts:      opts,
+		blobStore: newBlobStore(t.TempDir()),
+	}
+	assert.NoError(t, s.blobStore.open())
+	var err error
+	s.blobHashCache, err = lru.New[string, []byte](10)
+	assert.NoError(t, err)
+
+	logs := []replica.Log{{Index: 1, Term: 1, Data: bytes.Repeat([]byte("a"), 1024)}}
+	offloaded, err := s.offloadSyncLogs("test", logs)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, s.blobHashCache.Len())
+
+	hash, ok := s.blobHashCache.Get("test:1:1")
+	assert.True(t, ok)
+	assert.Equal(t, encodeBlobRef(hash), offloaded[0].Data)
+	offloaded2, err := s.offloadSyncLogs("test", logs)
+	assert.NoError(t, err)
+	assert.Equal(t, offloaded, offloaded2)
+}
+
+func TestDecodeBlobRef(t *testing.T) {
+	hash := bytes.Repeat([]byte{1}, 32)
+	got, ok := decodeBlobRef(encodeBlobRef(hash))
+	assert.True(t, ok)
+	assert.Equal(t, hash, got)
+
+	_, ok = decodeBlobRef([]byte("hello"))
+	assert.False(t, ok)
+}
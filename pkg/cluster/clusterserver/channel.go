@@ -2,10 +2,12 @@ package cluster
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sync"
 	"time"
 
+	"github.com/WuKongIM/WuKongIM/pkg/cluster/icluster"
 	"github.com/WuKongIM/WuKongIM/pkg/cluster/reactor"
 	"github.com/WuKongIM/WuKongIM/pkg/cluster/replica"
 	"github.com/WuKongIM/WuKongIM/pkg/trace"
@@ -23,16 +25,35 @@ type channel struct {
 	channelId   string
 	channelType uint8
 	rc          *replica.Replica
+	rcMu        sync.Mutex // 保护rc，reactor驱动副本和读取副本的诊断状态互斥
 	opts        *Options
 	wklog.Log
 	mu             sync.Mutex
 	cfg            wkdb.ChannelClusterConfig
 	pausePropopose atomic.Bool // 是否暂停提案
+	destroying     atomic.Bool // 是否正在停止（同一个频道同时只有一个停止流程）
+	lastActivity   atomic.Time // 最后一次提案或追加日志的时间，长时间不活跃的频道会被回收
+	// 存储里最后一条日志的下标加1（0表示还没有缓存），追加日志过滤重复日志时使用，不直接写存储的路径（截断、快照）写完后清空
+	storedLastIndexPlusOne atomic.Uint64
+	// 重建（RebuildChannel）后需要追上的领导日志下标，0表示不在恢复中
+	recoverTarget atomic.Uint64
+
+	pendingConfVersion  uint64        // 还没有生效的配置版本（已发起提案或已收到，但副本还没有切换），0表示没有
+	pendingConfDeadline time.Time     // 未生效的配置最晚到这个时间算生效（副本忽略了这个版本的配置时不会通知生效，避免一直暂停提案）
+	appliedConfVersion  uint64        // 副本已经生效的配置版本
+	configAppliedC      chan struct{} // 配置生效的通知
 
 	sendConfigTimeoutTick int // 发送配置超时（达到这个tick表示，需要发送配置请求了）
 
 	learnerToLock sync.Mutex
 
+	events *channelEvents // 最近事件
+
+	leaderFlapping leaderFlapping // 领导频繁变更检测
+
+	profile  ChannelProfile   // 频道类型的提案配置（创建时按频道类型选择）
+	inflight *channelInflight // 进行中的提案数量限制，nil表示不限制
+
 	s *Server
 }
 
@@ -47,6 +68,16 @@ func newChannel(channelId string, channelType uint8, s *Server) *channel {
 		Log:                   wklog.NewWKLog(fmt.Sprintf("cluster.channel[%s]", key)),
 		s:                     s,
 	}
+	c.lastActivity.Store(time.Now())
+	c.profile = s.opts.channelProfile(channelType)
+	c.inflight = newChannelInflight(c.profile.MaxInflightProposes)
+	// 频道移除后又重新加载，接着之前的事件记录
+	if events, ok := s.destroyedChannelEvents.Get(key); ok {
+		s.destroyedChannelEvents.Remove(key)
+		c.events = events
+	} else {
+		c.events = newChannelEvents()
+	}
 
 	appliedIdx, err := c.opts.MessageLogStorage.AppliedIndex(c.key)
 	if err != nil {
@@ -67,16 +98,23 @@ func newChannel(channelId string, channelType uint8, s *Server) *channel {
 		replica.WithLastTerm(lastTerm),
 		replica.WithStorage(newProxyReplicaStorage(c.key, c.opts.MessageLogStorage)),
 		replica.WithOnConfigChange(c.onReplicaConfigChange),
+		replica.WithIdleHeartbeat(c.opts.IdleHeartbeatTick, c.opts.IdleHeartbeatMultiple),
 	)
 	c.rc = rc
 	return c
 }
 
 func (c *channel) switchConfig(cfg wkdb.ChannelClusterConfig) error {
+	c.beginConfigChange(cfg.ConfVersion)
 	c.mu.Lock()
+	oldTerm := c.cfg.Term
 	c.cfg = cfg
 	c.mu.Unlock()
 
+	if oldTerm != 0 && cfg.Term > oldTerm {
+		c.events.add(ChannelEventElection, fmt.Sprintf("term %d -> %d, leader %d", oldTerm, cfg.Term, cfg.LeaderId))
+	}
+
 	// c.Info("switch config", zap.Uint32("slotId", c.s.getSlotId(c.channelId)), zap.String("cfg", cfg.String()))
 
 	var role = replica.RoleUnknown
@@ -92,6 +130,7 @@ func (c *channel) switchConfig(cfg wkdb.ChannelClusterConfig) error {
 	if role == replica.RoleUnknown {
 		c.Info("switch config, role is unknown, remove channel", zap.String("cfg", cfg.String()))
 		c.s.channelManager.remove(c)
+		c.s.channelManager.removeSnapshotFile(c.key)
 		return nil
 	}
 
@@ -114,14 +153,42 @@ func (c *channel) switchConfig(cfg wkdb.ChannelClusterConfig) error {
 }
 
 func (c *channel) onReplicaConfigChange(oldCfg, newCfg replica.Config) {
+	c.configApplied(newCfg.Version)
+	if newCfg.Leader != 0 { // 不管是哪个节点选出的领导，都不再算选举卡住
+		c.s.electionStuck.succeeded(c.channelId, c.channelType)
+	}
+
 	if oldCfg.Role != newCfg.Role {
 		if newCfg.Leader == c.opts.NodeId { // 从非领导变为领导
 			trace.GlobalTrace.Metrics.Cluster().ChannelActiveCountAdd(1)
+			c.events.add(ChannelEventBecomeLeader, fmt.Sprintf("term %d", newCfg.Term))
 		} else if oldCfg.Leader == c.opts.NodeId && newCfg.Leader != c.opts.NodeId { // 从领导变为非领导
 			trace.GlobalTrace.Metrics.Cluster().ChannelActiveCountAdd(-1)
+			c.events.add(ChannelEventLoseLeader, fmt.Sprintf("term %d, new leader %d", newCfg.Term, newCfg.Leader))
 		}
 
 	}
+	var (
+		flapping      bool
+		flappingNodes []uint64
+	)
+	if oldCfg.Leader != 0 && newCfg.Leader != 0 && oldCfg.Leader != newCfg.Leader {
+		flapping, flappingNodes = c.onLeaderChange(oldCfg.Leader, newCfg.Leader)
+	}
+	if newCfg.Leader == c.opts.NodeId && oldCfg.Leader != newCfg.Leader { // 成为频道领导
+		c.s.notifyLeaderChange(LeaderChangeEvent{
+			ShardType:     ShardTypeChannel,
+			ChannelId:     c.channelId,
+			ChannelType:   c.channelType,
+			PrevLeader:    oldCfg.Leader,
+			Leader:        newCfg.Leader,
+			Term:          newCfg.Term,
+			Reason:        channelLeaderChangeReason(oldCfg, newCfg),
+			Time:          time.Now(),
+			Flapping:      flapping,
+			FlappingNodes: flappingNodes,
+		})
+	}
 }
 
 func (c *channel) leaderId() uint64 {
@@ -136,23 +203,312 @@ func (c *channel) term() uint32 {
 	return c.cfg.Term
 }
 
+// isReplica nodeId是否是频道的副本
+func (c *channel) isReplica(nodeId uint64) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return wkutil.ArrayContainsUint64(c.cfg.Replicas, nodeId)
+}
+
 func (c *channel) isLeader() bool {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	return c.cfg.LeaderId == c.opts.NodeId
 }
 
+// StepDown 放弃频道的领导权，用于上层控制器在网络异常等情况下让本节点立即不再做领导
+// 只在本地放弃，不转移领导权也不更新槽里的频道配置：本节点成为term任期没有领导的追随者，之后的提案返回reactor.ErrNotLeader，
+// 等待中的提案也返回reactor.ErrNotLeader（这些提案之后可能被新领导提交，也可能被截断）
+// 需要把领导迁移给其他副本时调用方自己走requestChannelLeaderStepDown（槽的配置变更）
+// 不是领导时返回ErrNotLeader，term不是当前任期时返回replica.ErrTermMismatch
+func (c *channel) StepDown(term uint32) error {
+	c.rcMu.Lock()
+	err := c.rc.StepDown(term)
+	c.rcMu.Unlock()
+	if err != nil {
+		if errors.Is(err, replica.ErrNotLeader) {
+			return ErrNotLeader
+		}
+		return err
+	}
+	c.mu.Lock()
+	c.cfg.LeaderId = 0
+	c.mu.Unlock()
+
+	c.s.channelManager.channelReactor.RejectProposes(c.key, reactor.ErrNotLeader)
+	c.events.add(ChannelEventLoseLeader, fmt.Sprintf("step down, term %d", term))
+	c.Info("step down", c.logFields()...)
+	return nil
+}
+
+// gracefulDestroy 停止频道：先不再接受新的提案，等待进行中的提案完成（提交或失败）或ctx到期后再移除频道
+// ctx到期时还没完成的提案返回reactor.ErrHandlerRemoved，返回ctx的错误（频道仍然会被移除）
+// 同一个频道已经在停止时返回ErrChannelDestroying，移除时持有频道锁，并且只移除当前注册的还是本频道的处理者（等待期间被其他流程移除或重建的不受影响）
+func (c *channel) gracefulDestroy(ctx context.Context) error {
+	if !c.destroying.CompareAndSwap(false, true) {
+		return ErrChannelDestroying
+	}
+	c.pausePropopose.Store(true)
+	err := c.s.channelManager.channelReactor.WaitProposesDrained(ctx, c.key)
+	if err != nil {
+		c.Warn("wait proposes drained failed, destroy anyway", c.logFields(zap.Error(err))...)
+	}
+	c.s.channelKeyLock.Lock(c.channelId)
+	c.s.channelManager.removeIfCurrent(c)
+	c.s.channelKeyLock.Unlock(c.channelId)
+	return err
+}
+
+// beginRecover 本地数据丢弃后（见RebuildChannel）进入恢复状态，存储的日志追上重建时领导的最后日志下标target后退出，target为0不进入
+func (c *channel) beginRecover(target uint64) {
+	if target == 0 {
+		return
+	}
+	c.recoverTarget.Store(target)
+	c.events.add(ChannelEventRecovering, fmt.Sprintf("target %d", target))
+	c.Info("begin recover from leader", c.logFields(zap.Uint64("target", target))...)
+	if lastIndex, err := c.opts.MessageLogStorage.LastIndex(c.key); err == nil {
+		c.checkRecovered(lastIndex)
+	}
+}
+
+// recovering 是否在从领导恢复中
+func (c *channel) recovering() bool {
+	return c.recoverTarget.Load() != 0
+}
+
+// checkRecovered 存储的日志追上恢复目标后退出恢复状态
+func (c *channel) checkRecovered(storedLastIndex uint64) {
+	target := c.recoverTarget.Load()
+	if target == 0 || storedLastIndex < target {
+		return
+	}
+	if !c.recoverTarget.CompareAndSwap(target, 0) {
+		return
+	}
+	c.events.add(ChannelEventRecovered, fmt.Sprintf("index %d", storedLastIndex))
+	c.Info("recovered from leader", c.logFields(zap.Uint64("target", target), logIndexField(storedLastIndex))...)
+}
+
+// hasPendingCommit 是否是领导并且还有没提交的日志（回收会丢掉这些提案的提交结果）
+func (c *channel) hasPendingCommit() bool {
+	if !c.isLeader() {
+		return false
+	}
+	c.rcMu.Lock()
+	defer c.rcMu.Unlock()
+	return c.rc.CommittedIndex() < c.rc.LastLogIndex()
+}
+
+// transferLeadership 把频道领导平滑转移给target（target必须是频道的副本）
+// 先等target同步到当前已提交的日志，再请求槽领导发起迁移（target追上日志后切换领导，见FollowerToLeader），
+// 直到本节点看到新领导是target或ctx结束才返回，看到新领导后才通知领导变更
+func (c *channel) transferLeadership(ctx context.Context, target uint64) error {
+	if !c.isLeader() {
+		return &NotLeaderError{LeaderId: c.leaderId()}
+	}
+	if target == c.opts.NodeId {
+		return nil
+	}
+	if !c.isReplica(target) {
+		return ErrTransferTargetNotReplica
+	}
+
+	tk := time.NewTicker(time.Millisecond * 10)
+	defer tk.Stop()
+
+	c.rcMu.Lock()
+	committedIndex := c.rc.CommittedIndex()
+	c.rcMu.Unlock()
+	for {
+		c.rcMu.Lock()
+		matchIndex := c.rc.GetReplicaLastLog(target)
+		c.rcMu.Unlock()
+		if matchIndex >= committedIndex {
+			break
+		}
+		select {
+		case <-tk.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	c.Info("transfer leadership", c.logFields(zap.Uint64("target", target), zap.Uint64("committedIndex", committedIndex))...)
+	if err := c.s.requestChannelLeaderStepDown(ctx, c.channelId, c.channelType, target); err != nil {
+		return err
+	}
+	for c.LeaderId() != target {
+		select {
+		case <-tk.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	// 看到新领导后才通知，转移失败（ctx结束）不算领导变更
+	c.s.notifyLeaderChange(LeaderChangeEvent{
+		ShardType:   ShardTypeChannel,
+		ChannelId:   c.channelId,
+		ChannelType: c.channelType,
+		PrevLeader:  c.opts.NodeId,
+		Leader:      target,
+		Term:        c.term(),
+		Reason:      LeaderChangeReasonManual,
+		Time:        time.Now(),
+	})
+	c.events.add(ChannelEventLeaderStepDown, fmt.Sprintf("transfer to %d", target))
+	return nil
+}
+
+// ReadIndex 线性一致读，领导确认多数副本仍然认可自己是领导，并且本地已经提交和应用到读下标后，返回调用方可以安全读到的日志下标（读之前提交的写入都不会超过这个下标）
+// 不是领导（或确认期间失去了领导权）返回*NotLeaderError，timeout不大于0时使用频道的提案超时时间
+func (c *channel) ReadIndex(ctx context.Context, timeout time.Duration) (uint64, error) {
+	if !c.isLeader() {
+		return 0, &NotLeaderError{LeaderId: c.leaderId()}
+	}
+	if timeout <= 0 {
+		timeout = c.profile.ProposeTimeout
+	}
+	timeoutCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	index, err := c.s.channelManager.channelReactor.ReadIndex(timeoutCtx, c.key)
+	if err != nil {
+		if errors.Is(err, reactor.ErrNotLeader) {
+			return 0, &NotLeaderError{LeaderId: c.leaderId()}
+		}
+		return 0, err
+	}
+	return index, nil
+}
+
+// proposeBatchWithResults 批量提案，返回每条数据的提案结果（和data一一对应），调用方可以只重试失败的数据
+// 不能提案的数据（空数据、超过最大消息大小）单独失败，其余的数据作为一批提案，一起提交或一起失败，
+// 这一批提案失败时返回它的错误，timeout不大于0时使用频道的提案超时时间
+func (c *channel) proposeBatchWithResults(ctx context.Context, data [][]byte, timeout time.Duration) ([]icluster.ProposeEntryResult, error) {
+	logs := make([]replica.Log, len(data))
+	for i, d := range data {
+		logs[i] = replica.Log{Id: uint64(c.s.logIdGen.Generate().Int64()), Data: d}
+	}
+	return c.proposeLogsWithResults(ctx, logs, timeout)
+}
+
+// proposeLogsWithResults 和proposeBatchWithResults一样，日志id由调用方指定
+func (c *channel) proposeLogsWithResults(ctx context.Context, logs []replica.Log, timeout time.Duration) ([]icluster.ProposeEntryResult, error) {
+	if timeout <= 0 {
+		timeout = c.profile.ProposeTimeout
+	}
+	return proposeEntries(logs, c.s.checkProposeData, func(logs []replica.Log) ([]icluster.ProposeResult, error) {
+		timeoutCtx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		results, err := c.s.channelManager.proposeAndWait(timeoutCtx, c.channelId, c.channelType, logs)
+		if err != nil {
+			return nil, err
+		}
+		if len(results) == 0 { // 频道已经不在本节点
+			return nil, ErrChannelNotFound
+		}
+		iresults := make([]icluster.ProposeResult, len(results))
+		for i, result := range results {
+			iresults[i] = result
+		}
+		return iresults, nil
+	})
+}
+
+// proposeEntries 检查每条日志，不能提案的日志单独失败，其余的日志交给propose一起提案，返回每条日志的提案结果（和logs一一对应）
+func proposeEntries(logs []replica.Log, check func(lg replica.Log) error, propose func(logs []replica.Log) ([]icluster.ProposeResult, error)) ([]icluster.ProposeEntryResult, error) {
+	results := make([]icluster.ProposeEntryResult, len(logs))
+	valid := make([]replica.Log, 0, len(logs))
+	positions := make([]int, 0, len(logs)) // valid对应的logs下标
+	for i, lg := range logs {
+		results[i].Id = lg.Id
+		if err := check(lg); err != nil {
+			results[i].Err = err
+			continue
+		}
+		valid = append(valid, lg)
+		positions = append(positions, i)
+	}
+	if len(valid) == 0 {
+		return results, nil
+	}
+
+	proposeResults, err := propose(valid)
+	if err != nil {
+		for _, i := range positions {
+			results[i].Err = err
+		}
+		return results, err
+	}
+	indexes := make(map[uint64]uint64, len(proposeResults))
+	for _, result := range proposeResults {
+		indexes[result.LogId()] = result.LogIndex()
+	}
+	for _, i := range positions {
+		if index, ok := indexes[results[i].Id]; ok {
+			results[i].Index = index
+		} else {
+			results[i].Err = ErrProposeFailed
+		}
+	}
+	return results, nil
+}
+
 // --------------------------IHandler-------------------------------
 
+// IterateCommittedLogs 从from开始按顺序遍历已提交的日志（只读，不会读到未提交的日志），fn返回false时停止遍历
+// from为0时从第一条日志开始，from超过最后一条日志的下一条（lastIndex+1）时返回ErrLogIndexOutOfRange，日志已经被压缩时返回replica.ErrCompacted
+func (c *channel) IterateCommittedLogs(from uint64, fn func(replica.Log) bool) error {
+	if from == 0 {
+		from = 1
+	}
+	c.rcMu.Lock()
+	lastIndex := c.rc.LastLogIndex()
+	committedIndex := c.rc.CommittedIndex()
+	c.rcMu.Unlock()
+	if from > lastIndex+1 {
+		return ErrLogIndexOutOfRange
+	}
+	next := from
+	for next <= committedIndex {
+		logs, err := c.opts.MessageLogStorage.Logs(c.key, next, committedIndex+1, uint64(c.opts.LogSyncLimitSizeOfEach))
+		if err != nil {
+			return err
+		}
+		if len(logs) == 0 {
+			return nil
+		}
+		if logs[0].Index != next {
+			return replica.ErrCompacted
+		}
+		for _, lg := range logs {
+			if lg.Index > committedIndex {
+				return nil
+			}
+			if !fn(lg) {
+				return nil
+			}
+		}
+		next = logs[len(logs)-1].Index + 1
+	}
+	return nil
+}
+
 func (c *channel) LastLogIndexAndTerm() (uint64, uint32) {
+	c.rcMu.Lock()
+	defer c.rcMu.Unlock()
 	return c.rc.LastLogIndex(), c.rc.Term()
 }
 
 func (c *channel) HasReady() bool {
+	c.rcMu.Lock()
+	defer c.rcMu.Unlock()
 	return c.rc.HasReady()
 }
 
 func (c *channel) Ready() replica.Ready {
+	c.rcMu.Lock()
+	defer c.rcMu.Unlock()
 	return c.rc.Ready()
 }
 
@@ -161,7 +517,30 @@ func (c *channel) GetLogs(startIndex uint64, endIndex uint64) ([]replica.Log, er
 }
 
 func (c *channel) ApplyLogs(startIndex, endIndex uint64) (uint64, error) {
-	return 0, nil
+	if wklog.DebugEnabled() { // logFields要加锁并分配字段，日志级别不输出Debug时不构造
+		c.Debug("apply logs", c.logFields(logIndexField(startIndex), zap.Uint64("endIndex", endIndex))...)
+	}
+	if c.opts.OnChannelApply == nil {
+		return 0, nil
+	}
+	logs, err := c.opts.MessageLogStorage.Logs(c.key, startIndex, endIndex, 0)
+	if err != nil {
+		c.Error("get apply logs error", c.logFields(zap.Error(err), logIndexField(startIndex), zap.Uint64("endIndex", endIndex))...)
+		return 0, err
+	}
+	if len(logs) == 0 {
+		return 0, nil
+	}
+	appliedSize := uint64(0)
+	for _, log := range logs {
+		appliedSize += uint64(log.LogSize())
+	}
+	c.s.channelManager.channelReactor.FillProposeValues(c.key, logs)
+	if err := c.opts.OnChannelApply(c.channelId, c.channelType, logs); err != nil {
+		c.Error("on channel apply error", c.logFields(zap.Error(err), logIndexField(startIndex), zap.Uint64("endIndex", endIndex))...)
+		return 0, err
+	}
+	return appliedSize, nil
 }
 
 func (c *channel) AppliedIndex() (uint64, error) {
@@ -184,7 +563,14 @@ func (c *channel) SetHardState(hd replica.HardState) {
 }
 
 func (c *channel) Tick() {
+	c.rcMu.Lock()
 	c.rc.Tick()
+	c.rcMu.Unlock()
+
+	if c.leaderFlapping.flapping.Load() && c.leaderFlapping.expire(time.Now(), c.opts.LeaderFlappingWindow) {
+		trace.GlobalTrace.Metrics.Cluster().ChannelLeaderFlappingAdd(-1)
+		c.Info("channel leader stopped flapping", c.logFields()...)
+	}
 
 	// if c.isLeader() {
 	// 	c.sendConfigTick++
@@ -201,7 +587,14 @@ func (c *channel) Tick() {
 }
 
 func (c *channel) Step(m replica.Message) error {
-	return c.rc.Step(m)
+	c.rcMu.Lock()
+	err := c.rc.Step(m)
+	c.rcMu.Unlock()
+	if err != nil {
+		c.Error("step message failed", c.logFields(zap.Error(err), zap.String("msgType", m.MsgType.String()), zap.Uint64("from", m.From), logIndexField(m.Index))...)
+		return err
+	}
+	return nil
 }
 
 func (c *channel) LeaderId() uint64 {
@@ -209,10 +602,14 @@ func (c *channel) LeaderId() uint64 {
 }
 
 func (c *channel) SetSpeedLevel(level replica.SpeedLevel) {
+	c.rcMu.Lock()
+	defer c.rcMu.Unlock()
 	c.rc.SetSpeedLevel(level)
 }
 
 func (c *channel) SpeedLevel() replica.SpeedLevel {
+	c.rcMu.Lock()
+	defer c.rcMu.Unlock()
 	return c.rc.SpeedLevel()
 }
 
@@ -240,7 +637,17 @@ func (c *channel) SaveConfig(cfg replica.Config) error {
 }
 
 func (c *channel) AppendLogs(logs []replica.Log) error {
-	return c.opts.MessageLogStorage.AppendLogs(c.key, logs)
+	c.lastActivity.Store(time.Now())
+	defer c.resetStoredLastIndex()
+	if err := c.opts.MessageLogStorage.AppendLogs(c.key, logs); err != nil {
+		var index uint64
+		if len(logs) > 0 {
+			index = logs[0].Index
+		}
+		c.Error("append logs failed", c.logFields(zap.Error(err), logIndexField(index), zap.Int("count", len(logs)))...)
+		return err
+	}
+	return nil
 }
 
 func (c *channel) SetLeaderTermStartIndex(term uint32, index uint64) error {
@@ -261,17 +668,36 @@ func (c *channel) DeleteLeaderTermStartIndexGreaterThanTerm(term uint32) error {
 }
 
 func (c *channel) TruncateLogTo(index uint64) error {
+	defer c.resetStoredLastIndex()
 	return c.opts.MessageLogStorage.TruncateLogTo(c.key, index)
 }
 
+// storedLastIndex 缓存的存储里最后一条日志的下标，ok为false表示没有缓存
+func (c *channel) storedLastIndex() (uint64, bool) {
+	v := c.storedLastIndexPlusOne.Load()
+	if v == 0 {
+		return 0, false
+	}
+	return v - 1, true
+}
+
+func (c *channel) setStoredLastIndex(index uint64) {
+	c.storedLastIndexPlusOne.Store(index + 1)
+	c.checkRecovered(index)
+}
+
+func (c *channel) resetStoredLastIndex() {
+	c.storedLastIndexPlusOne.Store(0)
+}
+
 func (c *channel) LearnerToFollower(learnerId uint64) error {
-	c.Info("learner to  follower", zap.String("channelId", c.channelId), zap.Uint8("channelType", c.channelType), zap.Uint64("learnerId", learnerId))
+	c.Info("learner to  follower", c.logFields(zap.Uint64("learnerId", learnerId))...)
 
 	return c.learnerTo(learnerId)
 }
 
 func (c *channel) LearnerToLeader(learnerId uint64) error {
-	c.Info("learner to  leader", zap.String("channelId", c.channelId), zap.Uint8("channelType", c.channelType), zap.Uint64("learnerId", learnerId))
+	c.Info("learner to  leader", c.logFields(zap.Uint64("learnerId", learnerId))...)
 	return c.learnerTo(learnerId)
 }
 
@@ -280,11 +706,11 @@ func (c *channel) FollowerToLeader(followerId uint64) error {
 	c.learnerToLock.Lock()
 	defer c.learnerToLock.Unlock()
 
-	c.Info("follower to leader", zap.String("channelId", c.channelId), zap.Uint8("channelType", c.channelType), zap.Uint64("followerId", followerId))
+	c.Info("follower to leader", c.logFields(zap.Uint64("followerId", followerId))...)
 
 	channelClusterCfg, err := c.s.loadOnlyChannelClusterConfig(c.channelId, c.channelType)
 	if err != nil {
-		c.Error("onReplicaConfigChange failed", zap.Error(err), zap.String("channelId", c.channelId), zap.Uint8("channelType", c.channelType))
+		c.Error("onReplicaConfigChange failed", c.logFields(zap.Error(err))...)
 		return err
 	}
 	if wkdb.IsEmptyChannelClusterConfig(channelClusterCfg) {
@@ -295,7 +721,7 @@ func (c *channel) FollowerToLeader(followerId uint64) error {
 	}
 
 	if !wkutil.ArrayContainsUint64(channelClusterCfg.Replicas, followerId) {
-		c.Error("FollowerToLeader: follower not in replicas", zap.Uint64("followerId", followerId))
+		c.Error("FollowerToLeader: follower not in replicas", c.logFields(zap.Uint64("followerId", followerId))...)
 		return fmt.Errorf("follower not in replicas")
 	}
 
@@ -312,14 +738,14 @@ func (c *channel) FollowerToLeader(followerId uint64) error {
 
 	err = c.proposeAndUpdateChannelClusterConfig(newChannelClusterCfg)
 	if err != nil {
-		c.Error("FollowerToLeader: proposeAndUpdateChannelClusterConfig failed", zap.Error(err), zap.String("channelId", c.channelId), zap.Uint8("channelType", c.channelType))
+		c.Error("FollowerToLeader: proposeAndUpdateChannelClusterConfig failed", c.logFields(zap.Error(err))...)
 		return err
 	}
 
 	// 发送配置给新领导
 	err = c.s.SendChannelClusterConfigUpdate(newChannelClusterCfg.ChannelId, newChannelClusterCfg.ChannelType, newChannelClusterCfg.LeaderId)
 	if err != nil {
-		c.Error("FollowerToLeader: sendChannelClusterConfigUpdate failed", zap.Error(err), zap.String("channelId", c.channelId), zap.Uint8("channelType", c.channelType))
+		c.Error("FollowerToLeader: sendChannelClusterConfigUpdate failed", c.logFields(zap.Error(err))...)
 		return err
 	}
 
@@ -333,7 +759,7 @@ func (c *channel) learnerTo(learnerId uint64) error {
 
 	channelClusterCfg, err := c.s.loadOnlyChannelClusterConfig(c.channelId, c.channelType)
 	if err != nil {
-		c.Error("onReplicaConfigChange failed", zap.Error(err), zap.String("channelId", c.channelId), zap.Uint8("channelType", c.channelType))
+		c.Error("onReplicaConfigChange failed", c.logFields(zap.Error(err))...)
 		return err
 	}
 	if wkdb.IsEmptyChannelClusterConfig(channelClusterCfg) {
@@ -345,7 +771,7 @@ func (c *channel) learnerTo(learnerId uint64) error {
 	}
 
 	if channelClusterCfg.MigrateTo != learnerId {
-		c.Error("LearnerToFollower: learnerId is not equal to migrateTo", zap.Uint64("learnerId", learnerId), zap.Uint64("migrateTo", channelClusterCfg.MigrateTo))
+		c.Error("LearnerToFollower: learnerId is not equal to migrateTo", c.logFields(zap.Uint64("learnerId", learnerId), zap.Uint64("migrateTo", channelClusterCfg.MigrateTo))...)
 		return fmt.Errorf("LearnerToFollower: learnerId is not equal to migrateTo")
 	}
 
@@ -376,7 +802,7 @@ func (c *channel) learnerTo(learnerId uint64) error {
 
 	err = c.proposeAndUpdateChannelClusterConfig(channelClusterCfg)
 	if err != nil {
-		c.Error("LearnerToFollower: proposeAndUpdateChannelClusterConfig failed", zap.Error(err), zap.String("channelId", c.channelId), zap.Uint8("channelType", c.channelType))
+		c.Error("LearnerToFollower: proposeAndUpdateChannelClusterConfig failed", c.logFields(zap.Error(err))...)
 		return err
 	}
 
@@ -384,7 +810,7 @@ func (c *channel) learnerTo(learnerId uint64) error {
 	if learnerIsLeader {
 		err = c.s.SendChannelClusterConfigUpdate(channelClusterCfg.ChannelId, channelClusterCfg.ChannelType, channelClusterCfg.LeaderId)
 		if err != nil {
-			c.Error("LearnerToFollower: sendChannelClusterConfigUpdate failed", zap.Error(err), zap.String("channelId", c.channelId), zap.Uint8("channelType", c.channelType))
+			c.Error("LearnerToFollower: sendChannelClusterConfigUpdate failed", c.logFields(zap.Error(err))...)
 			return err
 		}
 	}
@@ -396,16 +822,18 @@ func (c *channel) proposeAndUpdateChannelClusterConfig(cfg wkdb.ChannelClusterCo
 	// 保存配置
 	timeoutCtx, cancel := context.WithTimeout(context.Background(), c.opts.ProposeTimeout)
 	defer cancel()
+	c.beginConfigChange(cfg.ConfVersion)
 	err := c.opts.ChannelClusterStorage.Propose(timeoutCtx, cfg)
 	if err != nil {
-		c.Error("propose channel cluster config failed", zap.Error(err), zap.String("channelId", c.channelId), zap.Uint8("channelType", c.channelType))
+		c.abortConfigChange(cfg.ConfVersion)
+		c.Error("propose channel cluster config failed", c.logFields(zap.Error(err))...)
 		return err
 	}
 
 	// 生效配置
 	err = c.switchConfig(cfg)
 	if err != nil {
-		c.Error("proposeAndUpdateChannelClusterConfig: switch config failed", zap.Error(err), zap.String("channelId", c.channelId), zap.Uint8("channelType", c.channelType))
+		c.Error("proposeAndUpdateChannelClusterConfig: switch config failed", c.logFields(zap.Error(err))...)
 		return err
 	}
 
@@ -418,8 +846,38 @@ func (c *channel) proposeAndUpdateChannelClusterConfig(cfg wkdb.ChannelClusterCo
 func (c *channel) getLogs(startLogIndex uint64, endLogIndex uint64, limitSize uint64) ([]replica.Log, error) {
 	logs, err := c.opts.MessageLogStorage.Logs(c.key, startLogIndex, endLogIndex, limitSize)
 	if err != nil {
-		c.Error("get logs error", zap.Error(err))
+		c.Error("get logs error", c.logFields(zap.Error(err), logIndexField(startLogIndex), zap.Uint64("endIndex", endLogIndex))...)
+		return nil, err
+	}
+	if err := c.checkLogsCompacted(startLogIndex, endLogIndex, logs); err != nil {
+		return nil, err
+	}
+	// 大日志同步时只携带blob引用
+	logs, err = c.s.offloadSyncLogs(c.key, logs)
+	if err != nil {
+		c.Error("offload large logs error", c.logFields(zap.Error(err))...)
 		return nil, err
 	}
 	return logs, nil
 }
+
+// checkLogsCompacted 要读取的日志已经被压缩时返回replica.ErrCompacted（副本只能通过安装快照追上）
+func (c *channel) checkLogsCompacted(startLogIndex uint64, endLogIndex uint64, logs []replica.Log) error {
+	if len(logs) > 0 {
+		if logs[0].Index != startLogIndex {
+			return replica.ErrCompacted
+		}
+		return nil
+	}
+	if _, ok := c.opts.MessageLogStorage.(logCompactor); !ok || startLogIndex >= endLogIndex {
+		return nil
+	}
+	lastIndex, err := c.opts.MessageLogStorage.LastIndex(c.key)
+	if err != nil {
+		return err
+	}
+	if startLogIndex <= lastIndex {
+		return replica.ErrCompacted
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package cluster
+
+import (
+	"testing"
+
+	"github.com/WuKongIM/WuKongIM/pkg/cluster/replica"
+	"github.com/WuKongIM/WuKongIM/pkg/wkutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// 只遍历已提交的日志，fn返回false时停止
+func TestChannelIterateCommittedLogs(t *testing.T) {
+	setupTestTrace(t)
+
+	shardNo := wkutil.ChannelToKey("snapshot", 2)
+	storage := newTestSnapshotStorage(t, shardNo, 100)
+	defer storage.Close()
+	assert.NoError(t, storage.SetAppliedIndex(shardNo, 80))
+
+	ch := newSnapshotTestChannel(t, 2, storage, replica.RoleFollower)
+	ch.opts.LogSyncLimitSizeOfEach = 256 // 小批量，多次读取存储
+
+	collect := func(from uint64, limit int) ([]uint64, error) {
+		var indexes []uint64
+		err := ch.IterateCommittedLogs(from, func(lg replica.Log) bool {
+			indexes = append(indexes, lg.Index)
+			return limit == 0 || len(indexes) < limit
+		})
+		return indexes, err
+	}
+
+	indexes, err := collect(0, 0)
+	assert.NoError(t, err)
+	assert.Len(t, indexes, 80)
+	assert.Equal(t, uint64(1), indexes[0])
+	assert.Equal(t, uint64(80), indexes[79])
+
+	indexes, err = collect(75, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, []uint64{75, 76, 77}, indexes)
+
+	// 未提交的日志不会读到
+	indexes, err = collect(90, 0)
+	assert.NoError(t, err)
+	assert.Empty(t, indexes)
+
+	_, err = collect(102, 0)
+	assert.ErrorIs(t, err, ErrLogIndexOutOfRange)
+
+	assert.NoError(t, storage.CompactTo(shardNo, 50))
+	_, err = collect(10, 0)
+	assert.ErrorIs(t, err, replica.ErrCompacted)
+	indexes, err = collect(51, 0)
+	assert.NoError(t, err)
+	assert.Len(t, indexes, 30)
+}
@@ -0,0 +1,102 @@
+package cluster
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// beginConfigChange 频道配置开始变更（发起配置提案或收到新的配置），副本切换到这个版本之前配置都算未生效
+func (c *channel) beginConfigChange(confVersion uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if confVersion <= c.appliedConfVersion || confVersion <= c.pendingConfVersion {
+		return
+	}
+	c.pendingConfVersion = confVersion
+	c.pendingConfDeadline = time.Now().Add(c.opts.ReqTimeout)
+}
+
+// expirePendingConfigLocked 未生效的配置超过期限后不再等待（副本可能已经有更新的配置，忽略了这个版本），返回是否还有未生效的配置
+func (c *channel) expirePendingConfigLocked(now time.Time) bool {
+	if c.pendingConfVersion == 0 {
+		return false
+	}
+	if now.Before(c.pendingConfDeadline) {
+		return true
+	}
+	// 持有c.mu，不能用c.logFields
+	c.Warn("channel config not applied before deadline, stop waiting", channelLogFields(c.channelId, c.channelType, zap.Uint64("pendingConfVersion", c.pendingConfVersion), zap.Uint64("appliedConfVersion", c.appliedConfVersion))...)
+	c.pendingConfVersion = 0
+	c.notifyConfigAppliedLocked()
+	return false
+}
+
+// abortConfigChange 配置提案失败，取消未生效的配置版本
+func (c *channel) abortConfigChange(confVersion uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.pendingConfVersion != confVersion {
+		return
+	}
+	c.pendingConfVersion = 0
+	c.notifyConfigAppliedLocked()
+}
+
+// configApplied 副本已经切换到confVersion版本的配置
+func (c *channel) configApplied(confVersion uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if confVersion > c.appliedConfVersion {
+		c.appliedConfVersion = confVersion
+	}
+	if c.pendingConfVersion == 0 || confVersion < c.pendingConfVersion {
+		return
+	}
+	c.pendingConfVersion = 0
+	c.notifyConfigAppliedLocked()
+}
+
+func (c *channel) notifyConfigAppliedLocked() {
+	if c.configAppliedC != nil {
+		close(c.configAppliedC)
+		c.configAppliedC = nil
+	}
+}
+
+// configChanging 是否有还没有生效的配置变更
+func (c *channel) configChanging() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.expirePendingConfigLocked(time.Now())
+}
+
+// waitConfigApplied 等待未生效的配置变更生效（最多等到配置的期限），ctx到期还没有生效返回ErrChannelConfigChanging
+func (c *channel) waitConfigApplied(ctx context.Context) error {
+	for {
+		c.mu.Lock()
+		if !c.expirePendingConfigLocked(time.Now()) {
+			c.mu.Unlock()
+			return nil
+		}
+		pendingConfVersion := c.pendingConfVersion
+		deadline := time.Until(c.pendingConfDeadline)
+		if c.configAppliedC == nil {
+			c.configAppliedC = make(chan struct{})
+		}
+		waitC := c.configAppliedC
+		c.mu.Unlock()
+
+		timer := time.NewTimer(deadline)
+		select {
+		case <-waitC:
+			timer.Stop()
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			c.Warn("wait channel config applied timeout", c.logFields(zap.Uint64("pendingConfVersion", pendingConfVersion))...)
+			return ErrChannelConfigChanging
+		}
+	}
+}
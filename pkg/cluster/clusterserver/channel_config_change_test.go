@@ -0,0 +1,129 @@
+package cluster
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/WuKongIM/WuKongIM/pkg/cluster/reactor"
+	"github.com/WuKongIM/WuKongIM/pkg/cluster/replica"
+	"github.com/WuKongIM/WuKongIM/pkg/wklog"
+	"github.com/WuKongIM/WuKongIM/pkg/wkutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestConfigChangeChannel(s *Server) *channel {
+	return &channel{
+		key:         wkutil.ChannelToKey("test", 2),
+		channelId:   "test",
+		channelType: 2,
+		opts:        s.opts,
+		Log:         wklog.NewWKLog("test"),
+		events:      newChannelEvents(),
+		s:           s,
+	}
+}
+
+// 成员变更未生效时的提案等到配置生效后才执行
+func TestProposeDuringConfigChange(t *testing.T) {
+	s := &Server{opts: NewOptions(WithNodeId(1), WithDisableProposeOnUnappliedConfig(true))}
+	ch := newTestConfigChangeChannel(s)
+	cfg := replica.Config{Leader: 2, Role: replica.RoleFollower, Replicas: []uint64{1, 2, 3}, Version: 1}
+	ch.configApplied(cfg.Version)
+
+	// 增加副本的配置还在提案中
+	ch.beginConfigChange(2)
+	assert.True(t, ch.configChanging())
+
+	var (
+		mu     sync.Mutex
+		events []string
+	)
+	record := func(event string) {
+		mu.Lock()
+		events = append(events, event)
+		mu.Unlock()
+	}
+	proposeDone := make(chan error, 1)
+	go func() {
+		err := ch.waitConfigApplied(context.Background())
+		record("propose")
+		proposeDone <- err
+	}()
+
+	select {
+	case <-proposeDone:
+		t.Fatal("propose should wait for the config change")
+	case <-time.After(time.Millisecond * 50):
+	}
+
+	// 副本切换到新的配置
+	newCfg := cfg
+	newCfg.Learners = []uint64{4}
+	newCfg.Version = 2
+	record("configApplied")
+	ch.onReplicaConfigChange(cfg, newCfg)
+
+	select {
+	case err := <-proposeDone:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("propose should continue after the config is applied")
+	}
+	mu.Lock()
+	assert.Equal(t, []string{"configApplied", "propose"}, events)
+	mu.Unlock()
+	assert.False(t, ch.configChanging())
+
+	// 旧版本的配置不算变更中
+	ch.beginConfigChange(2)
+	assert.False(t, ch.configChanging())
+
+	// 配置提案失败，提案不再等待
+	ch.beginConfigChange(3)
+	ch.abortConfigChange(3)
+	assert.NoError(t, ch.waitConfigApplied(context.Background()))
+}
+
+// 配置一直没有生效，提案超时返回ErrChannelConfigChanging
+func TestProposeConfigChangeTimeout(t *testing.T) {
+	storage := NewPebbleShardLogStorage(t.TempDir(), 1)
+	assert.NoError(t, storage.Open())
+	defer storage.Close()
+	s := &Server{opts: NewOptions(WithDisableProposeOnUnappliedConfig(true), WithMessageLogStorage(storage))}
+	cm := &channelManager{
+		channelReactor: reactor.New(reactor.NewOptions(reactor.WithReactorType(reactor.ReactorTypeChannel))),
+		opts:           s.opts,
+		s:              s,
+		Log:            wklog.NewWKLog("test"),
+	}
+	s.channelManager = cm
+	ch := newTestConfigChangeChannel(s)
+	ch.rc = replica.New(1)
+	cm.add(ch)
+
+	ch.beginConfigChange(1)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+	_, err := cm.proposeAndWait(ctx, ch.channelId, ch.channelType, []replica.Log{{Id: 1, Data: []byte("hello")}})
+	assert.ErrorIs(t, err, ErrChannelConfigChanging)
+}
+
+// 副本没有通知配置生效（例如忽略了旧版本的配置），到期后不再暂停提案
+func TestPendingConfigDeadline(t *testing.T) {
+	s := &Server{opts: NewOptions(WithNodeId(1), WithReqTimeout(time.Millisecond*50))}
+	ch := newTestConfigChangeChannel(s)
+
+	ch.beginConfigChange(2)
+	assert.True(t, ch.configChanging())
+
+	start := time.Now()
+	assert.NoError(t, ch.waitConfigApplied(context.Background()))
+	assert.GreaterOrEqual(t, time.Since(start), time.Millisecond*40)
+	assert.False(t, ch.configChanging())
+
+	ch.beginConfigChange(3)
+	ch.pendingConfDeadline = time.Now().Add(-time.Millisecond)
+	assert.False(t, ch.configChanging())
+}
@@ -0,0 +1,29 @@
+package cluster
+
+import (
+	"context"
+	"time"
+)
+
+// channelCreateLimiter 节点级别的频道创建限速（令牌桶），平滑突发的新频道创建，避免压垮频道初始化和存储
+type channelCreateLimiter struct {
+	*tokenBucket
+}
+
+func newChannelCreateLimiter(rate int, burst int) *channelCreateLimiter {
+	if burst <= 0 {
+		burst = rate
+	}
+	return &channelCreateLimiter{
+		tokenBucket: newTokenBucket(float64(rate), float64(burst), false),
+	}
+}
+
+// wait 获取一个令牌，最多排队等待maxWait，超过则返回ErrChannelCreateRateLimited
+func (l *channelCreateLimiter) wait(ctx context.Context, maxWait time.Duration) error {
+	_, ok, err := l.tokenBucket.wait(ctx, 1, maxWait)
+	if !ok {
+		return ErrChannelCreateRateLimited
+	}
+	return err
+}
@@ -0,0 +1,50 @@
+package cluster
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChannelCreateLimiterReserve(t *testing.T) {
+	l := newChannelCreateLimiter(10, 2)
+	now := l.last
+
+	// 突发数量内不需要等待
+	wait, ok := l.reserve(now, 1, 0)
+	assert.True(t, ok)
+	assert.Equal(t, time.Duration(0), wait)
+	wait, ok = l.reserve(now, 1, 0)
+	assert.True(t, ok)
+	assert.Equal(t, time.Duration(0), wait)
+
+	// 超过突发数量，不允许等待则拒绝
+	_, ok = l.reserve(now, 1, 0)
+	assert.False(t, ok)
+
+	// 允许等待则排队
+	wait, ok = l.reserve(now, 1, time.Second)
+	assert.True(t, ok)
+	assert.Equal(t, 100*time.Millisecond, wait)
+
+	// 时间过去后令牌恢复
+	wait, ok = l.reserve(now.Add(time.Second), 1, 0)
+	assert.True(t, ok)
+	assert.Equal(t, time.Duration(0), wait)
+}
+
+func TestChannelCreateLimiterWait(t *testing.T) {
+	l := newChannelCreateLimiter(1, 1)
+	err := l.wait(context.Background(), 0)
+	assert.NoError(t, err)
+
+	err = l.wait(context.Background(), 0)
+	assert.Equal(t, ErrChannelCreateRateLimited, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+	defer cancel()
+	err = l.wait(ctx, time.Second*2)
+	assert.Equal(t, context.DeadlineExceeded, err)
+}
@@ -0,0 +1,52 @@
+package cluster
+
+import (
+	"github.com/WuKongIM/WuKongIM/pkg/cluster/replica"
+)
+
+// ChannelDebugState 频道在本节点的内部状态（频道配置和副本的共识状态），频道卡住时用于排查
+type ChannelDebugState struct {
+	ChannelId          string             `json:"channel_id"`
+	ChannelType        uint8              `json:"channel_type"`
+	LeaderId           uint64             `json:"leader_id"`            // 频道配置的领导
+	Term               uint32             `json:"term"`                 // 频道配置的任期
+	ConfVersion        uint64             `json:"conf_version"`         // 频道配置的版本
+	PendingConfVersion uint64             `json:"pending_conf_version"` // 还没有生效的配置版本，0表示没有
+	AppliedConfVersion uint64             `json:"applied_conf_version"` // 副本已经生效的配置版本
+	PausePropose       bool               `json:"pause_propose"`        // 是否暂停了提案
+	Recovering         bool               `json:"recovering"`           // 是否在重建后从领导恢复中
+	RecoverTarget      uint64             `json:"recover_target"`       // 恢复需要追上的领导日志下标，0表示不在恢复中
+	Replica            replica.DebugState `json:"replica"`              // 副本的共识状态
+}
+
+// DebugState 获取频道的内部状态，副本状态在副本锁内读取，和reactor驱动副本互斥，读到的是一致的快照
+func (c *channel) DebugState() ChannelDebugState {
+	c.rcMu.Lock()
+	rcState := c.rc.DebugState()
+	c.rcMu.Unlock()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return ChannelDebugState{
+		ChannelId:          c.channelId,
+		ChannelType:        c.channelType,
+		LeaderId:           c.cfg.LeaderId,
+		Term:               c.cfg.Term,
+		ConfVersion:        c.cfg.ConfVersion,
+		PendingConfVersion: c.pendingConfVersion,
+		AppliedConfVersion: c.appliedConfVersion,
+		PausePropose:       c.pausePropopose.Load(),
+		Recovering:         c.recovering(),
+		RecoverTarget:      c.recoverTarget.Load(),
+		Replica:            rcState,
+	}
+}
+
+// debugState 获取频道在本节点的内部状态，频道没有在本节点运行返回false
+func (c *channelManager) debugState(channelId string, channelType uint8) (ChannelDebugState, bool) {
+	ch, ok := c.get(channelId, channelType).(*channel)
+	if !ok || ch == nil {
+		return ChannelDebugState{}, false
+	}
+	return ch.DebugState(), true
+}
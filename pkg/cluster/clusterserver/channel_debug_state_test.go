@@ -0,0 +1,31 @@
+package cluster
+
+import (
+	"testing"
+
+	"github.com/WuKongIM/WuKongIM/pkg/cluster/replica"
+	"github.com/WuKongIM/WuKongIM/pkg/wkdb"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChannelDebugState(t *testing.T) {
+	s := &Server{opts: NewOptions(WithNodeId(1))}
+	ch := newTestConfigChangeChannel(s)
+	ch.cfg = wkdb.ChannelClusterConfig{ChannelId: "test", ChannelType: 2, LeaderId: 2, Term: 3, ConfVersion: 4}
+	ch.rc = replica.New(1, replica.WithLastIndex(10), replica.WithLastTerm(3), replica.WithAppliedIndex(8))
+	ch.beginConfigChange(5)
+	ch.pausePropopose.Store(true)
+
+	st := ch.DebugState()
+	assert.Equal(t, "test", st.ChannelId)
+	assert.Equal(t, uint8(2), st.ChannelType)
+	assert.Equal(t, uint64(2), st.LeaderId)
+	assert.Equal(t, uint32(3), st.Term)
+	assert.Equal(t, uint64(4), st.ConfVersion)
+	assert.Equal(t, uint64(5), st.PendingConfVersion)
+	assert.True(t, st.PausePropose)
+	assert.Equal(t, replica.StatusUninitialized, st.Replica.Status)
+	assert.Equal(t, uint32(3), st.Replica.Term)
+	assert.Equal(t, uint64(10), st.Replica.LastLogIndex)
+	assert.Equal(t, uint64(8), st.Replica.AppliedIndex)
+}
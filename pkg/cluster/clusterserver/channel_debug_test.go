@@ -0,0 +1,22 @@
+package cluster
+
+import (
+	"testing"
+
+	"github.com/WuKongIM/WuKongIM/pkg/wkdb"
+	"github.com/stretchr/testify/assert"
+)
+
+// 调试开关同步到频道的所有副本，领导变更后新的领导也开启了调试
+func TestChannelDebugNodes(t *testing.T) {
+	cfg := wkdb.ChannelClusterConfig{
+		LeaderId: 1,
+		Replicas: []uint64{1, 2, 3},
+		Learners: []uint64{4},
+	}
+	assert.Equal(t, []uint64{1, 2, 3, 4}, channelDebugNodes(cfg, 1))
+	// 请求的节点不是副本
+	assert.Equal(t, []uint64{5, 1, 2, 3, 4}, channelDebugNodes(cfg, 5))
+	// 频道还没有分布式配置
+	assert.Equal(t, []uint64{5}, channelDebugNodes(wkdb.ChannelClusterConfig{}, 5))
+}
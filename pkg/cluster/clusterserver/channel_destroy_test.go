@@ -0,0 +1,123 @@
+package cluster
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/WuKongIM/WuKongIM/pkg/cluster/reactor"
+	"github.com/WuKongIM/WuKongIM/pkg/cluster/replica"
+	"github.com/WuKongIM/WuKongIM/pkg/keylock"
+	"github.com/WuKongIM/WuKongIM/pkg/wkdb"
+	"github.com/WuKongIM/WuKongIM/pkg/wklog"
+	lru "github.com/hashicorp/golang-lru/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+// 停止频道时先等待进行中的提案完成，新的提案直接拒绝，超时后强制移除
+func TestChannelGracefulDestroy(t *testing.T) {
+	setupTestTrace(t)
+
+	destroyedChannelEvents, err := lru.New[string, *channelEvents](10)
+	assert.NoError(t, err)
+	storage := NewPebbleShardLogStorage(t.TempDir(), 1)
+	assert.NoError(t, storage.Open())
+	defer storage.Close()
+	s := &Server{
+		opts:                   NewOptions(WithNodeId(1), WithMessageLogStorage(storage)),
+		destroyedChannelEvents: destroyedChannelEvents,
+		channelKeyLock:         keylock.NewKeyLock(),
+		Log:                    wklog.NewWKLog("test"),
+	}
+	// 不启动reactor，提案一直等待提交
+	cm := &channelManager{
+		channelReactor: reactor.New(reactor.NewOptions(reactor.WithNodeId(1), reactor.WithReactorType(reactor.ReactorTypeChannel))),
+		opts:           s.opts,
+		s:              s,
+		Log:            wklog.NewWKLog("test"),
+	}
+	s.channelManager = cm
+
+	newLeader := func(channelId string) *channel {
+		ch := newChannel(channelId, 2, s)
+		assert.NoError(t, ch.Step(replica.Message{
+			MsgType: replica.MsgInitResp,
+			Config:  replica.Config{Role: replica.RoleLeader, Term: 2, Leader: 1, Replicas: []uint64{1, 2, 3}, Version: 1},
+		}))
+		ch.cfg = wkdb.ChannelClusterConfig{ChannelId: channelId, ChannelType: 2, LeaderId: 1, Term: 2, Replicas: []uint64{1, 2, 3}}
+		cm.add(ch)
+		return ch
+	}
+	propose := func(channelId string, id uint64) chan error {
+		errC := make(chan error, 1)
+		go func() {
+			_, err := cm.proposeAndWait(context.Background(), channelId, 2, []replica.Log{{Id: id, Data: []byte("hello")}})
+			errC <- err
+		}()
+		return errC
+	}
+
+	// 没有进行中的提案，直接移除
+	idle := newLeader("idle")
+	assert.NoError(t, idle.gracefulDestroy(context.Background()))
+	assert.False(t, cm.channelReactor.ExistHandler(idle.key))
+
+	// 进行中的提案完成后移除
+	ch := newLeader("drain")
+	errC := propose("drain", 1)
+	time.Sleep(time.Millisecond * 50)
+	doneC := make(chan error, 1)
+	go func() {
+		doneC <- ch.gracefulDestroy(context.Background())
+	}()
+	time.Sleep(time.Millisecond * 50)
+	assert.True(t, cm.channelReactor.ExistHandler(ch.key))
+	_, err = cm.proposeAndWait(context.Background(), "drain", 2, []replica.Log{{Id: 2, Data: []byte("hello")}})
+	assert.Equal(t, reactor.ErrPausePropopose, err)
+
+	cm.channelReactor.RejectProposes(ch.key, reactor.ErrNotLeader)
+	assert.Equal(t, reactor.ErrNotLeader, <-errC)
+	select {
+	case err := <-doneC:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("graceful destroy not finished after proposes drained")
+	}
+	assert.False(t, cm.channelReactor.ExistHandler(ch.key))
+
+	// 超时后强制移除，进行中的提案失败
+	ch = newLeader("timeout")
+	errC = propose("timeout", 1)
+	time.Sleep(time.Millisecond * 50)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, ch.gracefulDestroy(ctx))
+	assert.False(t, cm.channelReactor.ExistHandler(ch.key))
+	select {
+	case err := <-errC:
+		assert.Equal(t, reactor.ErrHandlerRemoved, err)
+	case <-time.After(time.Second):
+		t.Fatal("pending propose not failed after destroy")
+	}
+
+	// 同一个频道同时只有一个停止流程，等待期间频道被重建时不会误删新的频道
+	ch = newLeader("race")
+	errC = propose("race", 1)
+	time.Sleep(time.Millisecond * 50)
+	doneC = make(chan error, 1)
+	go func() {
+		doneC <- ch.gracefulDestroy(context.Background())
+	}()
+	time.Sleep(time.Millisecond * 50)
+	assert.Equal(t, ErrChannelDestroying, ch.gracefulDestroy(context.Background()))
+	cm.remove(ch)
+	newCh := newLeader("race")
+	select {
+	case err := <-doneC:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("graceful destroy not finished after channel removed")
+	}
+	assert.Equal(t, reactor.ErrHandlerRemoved, <-errC)
+	assert.True(t, cm.channelReactor.ExistHandler(newCh.key))
+}
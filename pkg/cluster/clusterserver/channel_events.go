@@ -0,0 +1,69 @@
+package cluster
+
+import (
+	"sync"
+	"time"
+)
+
+// 每个频道最多保留的最近事件数量
+const channelEventCap = 32
+
+// 频道事件类型
+const (
+	ChannelEventBecomeLeader   = "becomeLeader"   // 成为领导
+	ChannelEventLoseLeader     = "loseLeader"     // 不再是领导
+	ChannelEventElection       = "election"       // 选举出了新的领导（任期变化）
+	ChannelEventAppendError    = "appendError"    // 追加日志失败
+	ChannelEventCommitTimeout  = "commitTimeout"  // 提案等待提交超时
+	ChannelEventDestroy        = "destroy"        // 频道从本节点移除
+	ChannelEventDegraded       = "degraded"       // 频道状态异常（例如已应用下标超过已提交下标），不再应用日志
+	ChannelEventLeaderFlapping = "leaderFlapping" // 领导频繁变更
+	ChannelEventLeaderStepDown = "leaderStepDown" // 请求把领导转移给其他副本
+	ChannelEventRecovering     = "recovering"     // 本地数据已丢弃，从领导恢复中
+	ChannelEventRecovered      = "recovered"      // 已追上重建时领导的日志，恢复完成
+)
+
+// ChannelEvent 频道最近发生的重要事件
+type ChannelEvent struct {
+	Time   time.Time `json:"time"`
+	Type   string    `json:"type"`
+	Detail string    `json:"detail,omitempty"`
+}
+
+// channelEvents 频道最近事件的环形缓冲，只保留最近channelEventCap条，用于频道出问题后快速查看时间线（不需要提前开启调试日志）
+type channelEvents struct {
+	mu     sync.Mutex
+	events [channelEventCap]ChannelEvent
+	next   int // 下一条事件写入的位置
+	full   bool
+}
+
+func newChannelEvents() *channelEvents {
+	return &channelEvents{}
+}
+
+func (c *channelEvents) add(typ string, detail string) {
+	c.mu.Lock()
+	c.events[c.next] = ChannelEvent{Time: time.Now(), Type: typ, Detail: detail}
+	c.next++
+	if c.next >= channelEventCap {
+		c.next = 0
+		c.full = true
+	}
+	c.mu.Unlock()
+}
+
+// list 按时间从旧到新返回事件
+func (c *channelEvents) list() []ChannelEvent {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.full {
+		events := make([]ChannelEvent, c.next)
+		copy(events, c.events[:c.next])
+		return events
+	}
+	events := make([]ChannelEvent, 0, channelEventCap)
+	events = append(events, c.events[c.next:]...)
+	events = append(events, c.events[:c.next]...)
+	return events
+}
@@ -0,0 +1,32 @@
+package cluster
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChannelEvents(t *testing.T) {
+	events := newChannelEvents()
+	assert.Len(t, events.list(), 0)
+
+	events.add(ChannelEventBecomeLeader, "term 1")
+	events.add(ChannelEventCommitTimeout, "")
+	list := events.list()
+	assert.Len(t, list, 2)
+	assert.Equal(t, ChannelEventBecomeLeader, list[0].Type)
+	assert.Equal(t, ChannelEventCommitTimeout, list[1].Type)
+
+	// 超过容量后只保留最近的事件，按时间从旧到新
+	for i := 0; i < channelEventCap+5; i++ {
+		events.add(ChannelEventElection, fmt.Sprintf("%d", i))
+	}
+	list = events.list()
+	assert.Len(t, list, channelEventCap)
+	assert.Equal(t, "5", list[0].Detail)
+	assert.Equal(t, fmt.Sprintf("%d", channelEventCap+4), list[channelEventCap-1].Detail)
+	for i := 1; i < len(list); i++ {
+		assert.False(t, list[i].Time.Before(list[i-1].Time))
+	}
+}
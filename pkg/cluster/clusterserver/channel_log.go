@@ -0,0 +1,56 @@
+package cluster
+
+import (
+	"github.com/WuKongIM/WuKongIM/pkg/cluster/reactor"
+	"github.com/WuKongIM/WuKongIM/pkg/wkutil"
+	"go.uber.org/zap"
+)
+
+// 频道相关日志的统一字段名，方便按频道检索日志
+const (
+	logFieldChannelId   = "channelId"
+	logFieldChannelType = "channelType"
+	logFieldTerm        = "term"
+	logFieldLeaderId    = "leaderId"
+	logFieldIndex       = "index"
+)
+
+// channelLogFields 频道日志的基础字段（频道id和频道类型）
+func channelLogFields(channelId string, channelType uint8, extra ...zap.Field) []zap.Field {
+	fields := make([]zap.Field, 0, 2+len(extra))
+	fields = append(fields, zap.String(logFieldChannelId, channelId), zap.Uint8(logFieldChannelType, channelType))
+	return append(fields, extra...)
+}
+
+// logIndexField 日志下标字段
+func logIndexField(index uint64) zap.Field {
+	return zap.Uint64(logFieldIndex, index)
+}
+
+// logFields 频道日志的标准字段（频道id、频道类型、任期、领导）
+func (c *channel) logFields(extra ...zap.Field) []zap.Field {
+	c.mu.Lock()
+	term, leaderId := c.cfg.Term, c.cfg.LeaderId
+	c.mu.Unlock()
+
+	fields := channelLogFields(c.channelId, c.channelType)
+	fields = append(fields, zap.Uint32(logFieldTerm, term), zap.Uint64(logFieldLeaderId, leaderId))
+	return append(fields, extra...)
+}
+
+// logFields 根据handleKey获取频道日志的标准字段，频道未加载时只包含频道id和频道类型
+func (c *channelManager) logFields(handleKey string, extra ...zap.Field) []zap.Field {
+	if ch, ok := c.getWithHandleKey(handleKey).(*channel); ok && ch != nil {
+		return ch.logFields(extra...)
+	}
+	channelId, channelType := wkutil.ChannelFromlKey(handleKey)
+	return channelLogFields(channelId, channelType, extra...)
+}
+
+// appendLogReqIndexField 追加请求的第一条日志的下标
+func appendLogReqIndexField(req reactor.AppendLogReq) zap.Field {
+	if len(req.Logs) == 0 {
+		return logIndexField(0)
+	}
+	return logIndexField(req.Logs[0].Index)
+}
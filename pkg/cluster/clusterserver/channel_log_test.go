@@ -0,0 +1,95 @@
+package cluster
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/WuKongIM/WuKongIM/pkg/cluster/replica"
+	"github.com/WuKongIM/WuKongIM/pkg/wkdb"
+	"github.com/WuKongIM/WuKongIM/pkg/wklog"
+	"github.com/WuKongIM/WuKongIM/pkg/wkutil"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+// 记录日志字段的日志实现
+type recordLog struct {
+	wklog.Log
+	entries map[string][]zap.Field
+}
+
+func (r *recordLog) record(msg string, fields []zap.Field) {
+	r.entries[msg] = fields
+}
+
+func (r *recordLog) Info(msg string, fields ...zap.Field)  { r.record(msg, fields) }
+func (r *recordLog) Debug(msg string, fields ...zap.Field) { r.record(msg, fields) }
+func (r *recordLog) Error(msg string, fields ...zap.Field) { r.record(msg, fields) }
+func (r *recordLog) Warn(msg string, fields ...zap.Field)  { r.record(msg, fields) }
+
+// 追加日志失败的存储
+type appendFailStorage struct {
+	IShardLogStorage
+}
+
+func (a *appendFailStorage) AppendLogs(shardNo string, logs []replica.Log) error {
+	return errors.New("disk full")
+}
+
+func assertChannelLogFields(t *testing.T, fields []zap.Field) {
+	keys := make(map[string]struct{}, len(fields))
+	for _, f := range fields {
+		keys[f.Key] = struct{}{}
+	}
+	for _, key := range []string{logFieldChannelId, logFieldChannelType, logFieldTerm, logFieldLeaderId, logFieldIndex} {
+		assert.Contains(t, keys, key)
+	}
+}
+
+func TestChannelLogFields(t *testing.T) {
+	lg := &recordLog{Log: wklog.NewWKLog("test"), entries: map[string][]zap.Field{}}
+	c := &channel{
+		key:         wkutil.ChannelToKey("test", 2),
+		channelId:   "test",
+		channelType: 2,
+		opts:        NewOptions(WithMessageLogStorage(&appendFailStorage{})),
+		Log:         lg,
+		cfg:         wkdb.ChannelClusterConfig{ChannelId: "test", ChannelType: 2, LeaderId: 1, Term: 3},
+	}
+
+	prevLevel := wklog.Level()
+	defer wklog.SetLevel(prevLevel)
+
+	// 不输出Debug日志时应用日志不构造字段（不加频道锁）
+	wklog.SetLevel(zap.InfoLevel)
+	c.mu.Lock()
+	_, err := c.ApplyLogs(5, 8)
+	c.mu.Unlock()
+	assert.NoError(t, err)
+	assert.NotContains(t, lg.entries, "apply logs")
+
+	wklog.SetLevel(zap.DebugLevel)
+	_, err = c.ApplyLogs(5, 8)
+	assert.NoError(t, err)
+	assertChannelLogFields(t, lg.entries["apply logs"])
+
+	err = c.AppendLogs([]replica.Log{{Index: 9, Term: 3}})
+	assert.Error(t, err)
+	assertChannelLogFields(t, lg.entries["append logs failed"])
+
+	fields := c.logFields(logIndexField(9))
+	values := map[string]interface{}{}
+	for _, f := range fields {
+		switch f.Key {
+		case logFieldChannelId:
+			values[f.Key] = f.String
+		default:
+			values[f.Key] = f.Integer
+		}
+	}
+	assert.Equal(t, "test", values[logFieldChannelId])
+	assert.Equal(t, int64(2), values[logFieldChannelType])
+	assert.Equal(t, int64(3), values[logFieldTerm])
+	assert.Equal(t, int64(1), values[logFieldLeaderId])
+	assert.Equal(t, int64(9), values[logFieldIndex])
+}
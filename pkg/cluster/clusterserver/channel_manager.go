@@ -1,10 +1,15 @@
 package cluster
 
 import (
+	"bufio"
 	"context"
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"time"
 
 	"github.com/WuKongIM/WuKongIM/pkg/cluster/reactor"
 	"github.com/WuKongIM/WuKongIM/pkg/cluster/replica"
@@ -37,15 +42,59 @@ func newChannelManager(s *Server) *channelManager {
 		reactor.WithAutoSlowDownOn(true),
 		reactor.WithRequest(cm),
 		reactor.WithSubReactorNum(s.opts.ChannelReactorSubCount),
+		reactor.WithMaxApplyLag(s.opts.MaxApplyLag),
+		reactor.WithProposeAckTraceSampleRate(s.opts.ProposeAckTraceSampleRate),
+		reactor.WithApplyOrderingMode(cm.applyOrderingMode),
+		reactor.WithInlineApplyMaxLogs(s.opts.InlineApplyMaxLogs),
+		reactor.WithInlineApply(cm.inlineApply),
+		reactor.WithLogCacheMaxBytes(s.opts.LogCacheMaxBytes),
+		reactor.WithLogSyncLimitSize(uint64(s.opts.LogSyncLimitSizeOfEach)),
+		reactor.WithMaxReadyRounds(s.opts.maxHandleReadyCountOfBatch()),
+		reactor.WithSnapshotLogThreshold(s.opts.SnapshotLogThreshold),
+		reactor.WithIdleSweepPaused(s.opts.IdleSweepPaused),
+		reactor.WithMaxMessageSize(s.opts.MaxMessageSize),
+		reactor.WithProposeResultChannelBuffer(s.opts.ProposeResultChannelBuffer),
+		reactor.WithOnSnapshot(cm.onSnapshot),
+		reactor.WithOnLogsCompacted(cm.onLogsCompacted),
+		reactor.WithOnDegraded(func(handleKey string, reason string) {
+			cm.Error("channel is degraded", cm.logFields(handleKey, zap.String("reason", reason))...)
+			cm.addEvent(handleKey, ChannelEventDegraded, reason)
+		}),
 		reactor.WithOnHandlerRemove(func(h reactor.IHandler) {
 			if h.LeaderId() == cm.opts.NodeId {
 				trace.GlobalTrace.Metrics.Cluster().ChannelActiveCountAdd(-1)
 			}
+			if ch, ok := h.(*channel); ok {
+				s.electionStuck.remove(ch.channelId, ch.channelType)
+			}
 		}),
 	))
 	return cm
 }
 
+// 频道的日志应用顺序模式（按频道类型配置）
+func (c *channelManager) applyOrderingMode(handleKey string) reactor.ApplyOrderingMode {
+	if len(c.opts.ApplyOrderingModes) == 0 && len(c.opts.ChannelProfiles) == 0 {
+		return reactor.ApplyOrderingStrict
+	}
+	_, channelType := wkutil.ChannelFromlKey(handleKey)
+	return c.opts.channelProfile(channelType).ApplyOrdering
+}
+
+// 频道是否允许同步应用（按频道类型配置）
+func (c *channelManager) inlineApply(handleKey string) bool {
+	if len(c.opts.InlineApplyChannelTypes) == 0 {
+		return true
+	}
+	_, channelType := wkutil.ChannelFromlKey(handleKey)
+	for _, t := range c.opts.InlineApplyChannelTypes {
+		if t == channelType {
+			return true
+		}
+	}
+	return false
+}
+
 func (c *channelManager) start() error {
 	return c.channelReactor.Start()
 }
@@ -60,6 +109,39 @@ func (c *channelManager) add(ch *channel) {
 
 func (c *channelManager) remove(ch *channel) {
 	c.channelReactor.RemoveHandler(ch.key)
+	if ch.leaderFlapping.reset() {
+		trace.GlobalTrace.Metrics.Cluster().ChannelLeaderFlappingAdd(-1)
+	}
+	ch.events.add(ChannelEventDestroy, "")
+	c.s.destroyedChannelEvents.Add(ch.key, ch.events)
+}
+
+// removeIfCurrent 只有当前注册的处理者还是ch时才移除，返回是否移除
+func (c *channelManager) removeIfCurrent(ch *channel) bool {
+	if cur, ok := c.channelReactor.Handler(ch.key).(*channel); !ok || cur != ch {
+		return false
+	}
+	c.remove(ch)
+	return true
+}
+
+// 记录频道事件（频道不在本节点则忽略）
+func (c *channelManager) addEvent(handleKey string, typ string, detail string) {
+	if ch, ok := c.getWithHandleKey(handleKey).(*channel); ok && ch != nil {
+		ch.events.add(typ, detail)
+	}
+}
+
+// 频道在本节点的最近事件
+func (c *channelManager) events(channelId string, channelType uint8) []ChannelEvent {
+	key := wkutil.ChannelToKey(channelId, channelType)
+	if ch, ok := c.getWithHandleKey(key).(*channel); ok && ch != nil {
+		return ch.events.list()
+	}
+	if events, ok := c.s.destroyedChannelEvents.Get(key); ok {
+		return events.list()
+	}
+	return []ChannelEvent{}
 }
 
 func (c *channelManager) get(channelId string, channelType uint8) reactor.IHandler {
@@ -80,7 +162,181 @@ func (c *channelManager) getWithHandleKey(handleKey string) reactor.IHandler {
 }
 
 func (c *channelManager) proposeAndWait(ctx context.Context, channelId string, channelType uint8, logs []replica.Log) ([]reactor.ProposeResult, error) {
-	return c.channelReactor.ProposeAndWait(ctx, wkutil.ChannelToKey(channelId, channelType), logs)
+	ch, _ := c.get(channelId, channelType).(*channel)
+	profile := c.opts.channelProfile(channelType)
+	if ch != nil {
+		profile = ch.profile
+		ch.lastActivity.Store(time.Now())
+		if !ch.inflight.tryAcquire() {
+			return nil, ErrTooBusy
+		}
+		defer ch.inflight.release()
+	}
+	ctx, cancel := context.WithTimeout(ctx, profile.ProposeTimeout)
+	defer cancel()
+	if profile.ProposePriority == reactor.ProposePriorityHigh {
+		ctx = reactor.WithProposePriority(ctx, reactor.ProposePriorityHigh)
+	}
+
+	if c.s.proposeLimiter != nil {
+		if err := c.s.proposeLimiter.acquire(ctx, profile.ProposeTimeout); err != nil {
+			return nil, err
+		}
+		defer c.s.proposeLimiter.release()
+	}
+	if c.s.diskReadOnly() {
+		// 磁盘空间不足，拒绝提案，频道领导会转移到其他副本
+		trace.GlobalTrace.Metrics.Cluster().DiskFullRejectedCountAdd(1)
+		return nil, ErrDiskFull
+	}
+	if c.opts.DisableProposeOnUnappliedConfig {
+		// 配置变更未生效时暂停提案，等配置生效后再按新的副本集合提案
+		if ch != nil {
+			if err := ch.waitConfigApplied(ctx); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if c.s.writeLimiter != nil {
+		if err := c.s.writeLimiter.waitLogs(ctx, logs, profile.ProposeTimeout); err != nil {
+			return nil, err
+		}
+	}
+	for _, lg := range logs {
+		trace.GlobalTrace.Metrics.Cluster().ProposeLogSizeRecord(channelType, int64(len(lg.Data)))
+	}
+	logs, err := c.s.offloadLargeLogs(logs)
+	if err != nil {
+		return nil, err
+	}
+	handleKey := wkutil.ChannelToKey(channelId, channelType)
+	clusterMetrics := trace.GlobalTrace.Metrics.Cluster()
+	if !clusterMetrics.IsChannelDebug(channelId, channelType) {
+		results, err := c.channelReactor.ProposeAndWait(ctx, handleKey, logs)
+		c.onProposeErr(handleKey, err)
+		return results, err
+	}
+
+	// 调试中的频道，单独统计提案数量、提交延迟、排队数量和应用落后
+	start := time.Now()
+	clusterMetrics.ChannelDebugProposeQueueDepthAdd(channelId, channelType, 1)
+	results, err := c.channelReactor.ProposeAndWait(ctx, handleKey, logs)
+	c.onProposeErr(handleKey, err)
+	clusterMetrics.ChannelDebugProposeQueueDepthAdd(channelId, channelType, -1)
+	if err == nil {
+		clusterMetrics.ChannelDebugProposeCommitted(channelId, channelType, int64(len(logs)), time.Since(start))
+	}
+	if lagInfo, ok := c.channelReactor.ApplyLag(handleKey); ok {
+		clusterMetrics.ChannelDebugApplyLagSet(channelId, channelType, int64(lagInfo.Lag))
+	}
+	return results, err
+}
+
+func (c *channelManager) onProposeErr(handleKey string, err error) {
+	if errors.Is(err, context.DeadlineExceeded) {
+		c.Warn("propose commit timeout", c.logFields(handleKey)...)
+		c.addEvent(handleKey, ChannelEventCommitTimeout, "")
+	}
+}
+
+// channelSnapshotPath 频道快照文件的路径
+func channelSnapshotPath(dataDir string, handleKey string) string {
+	return path.Join(dataDir, "snapshots", url.PathEscape(handleKey)+".snap")
+}
+
+// openSnapshotBase 打开频道上一次的快照文件（被压缩掉的日志从这里读取），没有快照文件返回nil
+func openSnapshotBase(p string) (*os.File, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return f, nil
+}
+
+// onSnapshot 频道已应用的日志达到快照阈值，把[1, appliedIndex]的日志写成快照文件，写成功后压缩已经在快照里的日志；
+// 已有快照文件时只追加新应用的日志，没有或快照文件不完整时重新生成（先写临时文件再改名，不会留下不完整的快照）
+// 存储不支持压缩时日志一直在存储里，不需要快照文件
+func (c *channelManager) onSnapshot(handleKey string, appliedIndex uint64) error {
+	if _, ok := c.opts.MessageLogStorage.(logCompactor); !ok {
+		return nil
+	}
+	ch, ok := c.getWithHandleKey(handleKey).(*channel)
+	if !ok || ch == nil {
+		return nil
+	}
+	lastTerm, err := ch.logTerm(appliedIndex)
+	if err != nil {
+		return err
+	}
+	p := channelSnapshotPath(c.opts.DataDir, handleKey)
+	if err := os.MkdirAll(path.Dir(p), 0755); err != nil {
+		return err
+	}
+	err = c.appendSnapshotFile(p, handleKey, appliedIndex, lastTerm)
+	if errors.Is(err, ErrSnapshotCorrupted) {
+		c.Warn("channel snapshot file corrupted, rebuild", c.logFields(handleKey, zap.Error(err))...)
+		err = c.createSnapshotFile(p, handleKey, appliedIndex, lastTerm)
+	}
+	if err != nil {
+		return err
+	}
+	if wklog.DebugEnabled() {
+		c.Debug("channel snapshot", c.logFields(handleKey, logIndexField(appliedIndex))...)
+	}
+
+	if err := ch.compactTo(appliedIndex); err != nil {
+		c.Warn("compact channel logs failed", c.logFields(handleKey, zap.Error(err), logIndexField(appliedIndex))...)
+	}
+	return nil
+}
+
+// appendSnapshotFile 把新应用的日志追加到已有的快照文件，没有快照文件时生成一个
+func (c *channelManager) appendSnapshotFile(p string, handleKey string, lastIndex uint64, lastTerm uint32) error {
+	f, err := os.OpenFile(p, os.O_RDWR, 0644)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return c.createSnapshotFile(p, handleKey, lastIndex, lastTerm)
+		}
+		return err
+	}
+	err = appendShardSnapshot(c.opts.MessageLogStorage, handleKey, f, lastIndex, lastTerm, uint64(c.opts.LogSyncLimitSizeOfEach))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// createSnapshotFile 从存储生成完整的快照文件（存储里的日志已经被压缩时返回ErrSnapshotLogsCompacted）
+func (c *channelManager) createSnapshotFile(p string, handleKey string, lastIndex uint64, lastTerm uint32) error {
+	tmp := p + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	err = writeShardSnapshotTo(c.opts.MessageLogStorage, handleKey, nil, w, lastIndex, lastTerm, uint64(c.opts.LogSyncLimitSizeOfEach))
+	if err == nil {
+		err = w.Flush()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, p)
+}
+
+// removeSnapshotFile 删除频道的快照文件（本节点不再保存频道的数据时调用，频道只是从内存里回收时不能删除，被压缩的日志还在快照里）
+func (c *channelManager) removeSnapshotFile(handleKey string) {
+	err := os.Remove(channelSnapshotPath(c.opts.DataDir, handleKey))
+	if err != nil && !os.IsNotExist(err) {
+		c.Warn("remove channel snapshot file failed", c.logFields(handleKey, zap.Error(err))...)
+	}
 }
 
 func (c *channelManager) addMessage(m reactor.Message) {
@@ -139,6 +395,8 @@ func (c *channelManager) GetLeaderTermStartIndex(req reactor.LeaderTermStartInde
 	if err != nil {
 		return 0, err
 	}
+	trace.GlobalTrace.Metrics.Cluster().MsgLeaderTermStartIndexReqOutgoingCountAdd(trace.ClusterKindChannel, 1)
+	trace.GlobalTrace.Metrics.Cluster().MsgLeaderTermStartIndexReqOutgoingBytesAdd(trace.ClusterKindChannel, int64(len(reqBytes)))
 	resp, err := c.request(req.LeaderId, "/channel/leaderTermStartIndex", reqBytes)
 	if err != nil {
 		return 0, err
@@ -146,6 +404,8 @@ func (c *channelManager) GetLeaderTermStartIndex(req reactor.LeaderTermStartInde
 	if resp.Status != proto.Status_OK {
 		return 0, fmt.Errorf("get leader term start index failed, status: %v", resp.Status)
 	}
+	trace.GlobalTrace.Metrics.Cluster().MsgLeaderTermStartIndexRespIncomingCountAdd(trace.ClusterKindChannel, 1)
+	trace.GlobalTrace.Metrics.Cluster().MsgLeaderTermStartIndexRespIncomingBytesAdd(trace.ClusterKindChannel, int64(len(resp.Body)))
 	if len(resp.Body) > 0 {
 		return binary.BigEndian.Uint64(resp.Body), nil
 	}
@@ -163,7 +423,113 @@ func (c *channelManager) AppendLogBatch(reqs []reactor.AppendLogReq) error {
 	// 	}
 
 	// }
-	return c.opts.MessageLogStorage.AppendLogBatch(reqs)
+	newReqs := make([]reactor.AppendLogReq, 0, len(reqs))
+	for i, req := range reqs {
+		ch, _ := c.getWithHandleKey(req.HandleKey).(*channel)
+		// 过滤掉已经存储过的日志（重复投递的追加请求），和已存储的日志冲突的请求单独失败，不影响其他频道
+		logs, err := c.filterAppendedLogs(ch, req.HandleKey, req.Logs)
+		if err != nil {
+			c.Error("filter appended logs failed", c.logFields(req.HandleKey, zap.Error(err), appendLogReqIndexField(req))...)
+			c.addEvent(req.HandleKey, ChannelEventAppendError, err.Error())
+			reqs[i].Err = err
+			continue
+		}
+		if len(logs) == 0 {
+			continue
+		}
+		if logs, err = c.s.resolveBlobLogs(req.HandleKey, logs); err != nil {
+			reqs[i].Err = err
+			continue
+		}
+		req.Logs = logs
+		newReqs = append(newReqs, req)
+	}
+	if len(newReqs) == 0 {
+		return nil
+	}
+	if err := c.opts.MessageLogStorage.AppendLogBatch(newReqs); err != nil {
+		c.s.onAppendErr(err)
+		for _, req := range newReqs {
+			if ch, ok := c.getWithHandleKey(req.HandleKey).(*channel); ok && ch != nil {
+				ch.resetStoredLastIndex()
+			}
+			c.Error("append log batch failed", c.logFields(req.HandleKey, zap.Error(err), appendLogReqIndexField(req))...)
+			c.addEvent(req.HandleKey, ChannelEventAppendError, err.Error())
+		}
+		return err
+	}
+	now := time.Now()
+	for _, req := range newReqs {
+		if ch, ok := c.getWithHandleKey(req.HandleKey).(*channel); ok && ch != nil {
+			ch.lastActivity.Store(now)
+			ch.setStoredLastIndex(req.Logs[len(req.Logs)-1].Index)
+		}
+	}
+	if c.s.proposeAuditor != nil {
+		c.s.proposeAuditor.record("channel", newReqs)
+	}
+	return nil
+}
+
+// filterAppendedLogs 过滤掉频道已经存储过的日志，存储的最后日志下标优先用频道缓存的，避免每次追加都读存储
+func (c *channelManager) filterAppendedLogs(ch *channel, handleKey string, logs []replica.Log) ([]replica.Log, error) {
+	if len(logs) == 0 {
+		return logs, nil
+	}
+	var (
+		lastIndex uint64
+		ok        bool
+	)
+	if ch != nil {
+		lastIndex, ok = ch.storedLastIndex()
+	}
+	if !ok {
+		var err error
+		if lastIndex, err = c.opts.MessageLogStorage.LastIndex(handleKey); err != nil {
+			return nil, err
+		}
+		if ch != nil {
+			ch.setStoredLastIndex(lastIndex)
+		}
+	}
+	return filterAppendedLogs(c.opts.MessageLogStorage, handleKey, lastIndex, logs)
+}
+
+// filterAppendedLogs 过滤掉已经存储过的日志（lastIndex为存储里最后一条日志的下标），使重复投递的追加请求是幂等的
+// 已存储的日志任期一致则跳过，不一致说明和已存储的日志冲突，返回ErrLogTermConflict（冲突的日志应该先截断再追加）
+func filterAppendedLogs(storage IShardLogStorage, handleKey string, lastIndex uint64, logs []replica.Log) ([]replica.Log, error) {
+	if len(logs) == 0 {
+		return logs, nil
+	}
+	if logs[0].Index > lastIndex {
+		return logs, nil
+	}
+	endIndex := logs[len(logs)-1].Index
+	if endIndex > lastIndex {
+		endIndex = lastIndex
+	}
+	storedLogs, err := storage.Logs(handleKey, logs[0].Index, endIndex+1, 0)
+	if err != nil {
+		return nil, err
+	}
+	storedTerms := make(map[uint64]uint32, len(storedLogs))
+	for _, lg := range storedLogs {
+		storedTerms[lg.Index] = lg.Term
+	}
+	for i, lg := range logs {
+		if lg.Index > lastIndex {
+			return logs[i:], nil
+		}
+		term, ok := storedTerms[lg.Index]
+		if !ok {
+			// 本地没有这条日志（日志不连续），不能判断是否重复，交给存储处理
+			return logs[i:], nil
+		}
+		if term != lg.Term {
+			return nil, fmt.Errorf("%w: index %d stored term %d append term %d", ErrLogTermConflict, lg.Index, term, lg.Term)
+		}
+	}
+	return nil, nil
 }
 
 func (c *channelManager) request(toNodeId uint64, path string, body []byte) (*proto.Response, error) {
@@ -0,0 +1,89 @@
+package cluster
+
+import (
+	"time"
+
+	"github.com/WuKongIM/WuKongIM/pkg/cluster/reactor"
+)
+
+// ChannelProfile 频道类型的提案配置（超时、重试、并发提案数、应用顺序），不同频道类型对延迟和可靠性的要求不同，
+// 例如控制类频道希望快速失败，批量类频道可以容忍更长的等待
+type ChannelProfile struct {
+	// ProposeTimeout 提案超时时间，0表示使用Options.ProposeTimeout
+	ProposeTimeout time.Duration
+	// ProposeRetryOnNotLeader 提案遇到不是领导（例如正在选举）时是否按指数退避重试
+	ProposeRetryOnNotLeader bool
+	// MaxInflightProposes 频道最多同时进行中的提案数量，超过直接返回ErrTooBusy，0表示不限制
+	MaxInflightProposes int
+	// ApplyOrdering 日志应用顺序模式
+	ApplyOrdering reactor.ApplyOrderingMode
+	// ProposePriority 提案优先级，高优先级的提案排在还没追加的普通提案前面
+	ProposePriority reactor.ProposePriority
+}
+
+var (
+	// ChannelProfileControl 控制类频道：提案很少，要求快速失败，由调用方决定是否重试
+	ChannelProfileControl = ChannelProfile{
+		ProposeTimeout:          time.Second * 3,
+		ProposeRetryOnNotLeader: false,
+		MaxInflightProposes:     64,
+		ApplyOrdering:           reactor.ApplyOrderingStrict,
+		ProposePriority:         reactor.ProposePriorityHigh,
+	}
+	// ChannelProfileBulk 批量类频道：提案多，可以容忍更长的等待，选举期间重试而不是直接失败
+	ChannelProfileBulk = ChannelProfile{
+		ProposeTimeout:          time.Second * 30,
+		ProposeRetryOnNotLeader: true,
+		MaxInflightProposes:     0,
+		ApplyOrdering:           reactor.ApplyOrderingStrict,
+		ProposePriority:         reactor.ProposePriorityNormal,
+	}
+)
+
+// channelProfile 频道类型的配置，没有配置的频道类型使用全局配置（ProposeTimeout、ProposeRetryOnNotLeader、ApplyOrderingModes）
+func (o *Options) channelProfile(channelType uint8) ChannelProfile {
+	profile, ok := o.ChannelProfiles[channelType]
+	if !ok {
+		return ChannelProfile{
+			ProposeTimeout:          o.ProposeTimeout,
+			ProposeRetryOnNotLeader: o.ProposeRetryOnNotLeader,
+			ApplyOrdering:           o.ApplyOrderingModes[channelType],
+		}
+	}
+	if profile.ProposeTimeout <= 0 {
+		profile.ProposeTimeout = o.ProposeTimeout
+	}
+	return profile
+}
+
+// channelInflight 频道进行中的提案数量限制
+type channelInflight struct {
+	sem chan struct{}
+}
+
+func newChannelInflight(max int) *channelInflight {
+	if max <= 0 {
+		return nil
+	}
+	return &channelInflight{sem: make(chan struct{}, max)}
+}
+
+// tryAcquire 获取一个提案名额，没有名额返回false，nil表示不限制
+func (c *channelInflight) tryAcquire() bool {
+	if c == nil {
+		return true
+	}
+	select {
+	case c.sem <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+func (c *channelInflight) release() {
+	if c == nil {
+		return
+	}
+	<-c.sem
+}
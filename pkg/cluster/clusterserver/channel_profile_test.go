@@ -0,0 +1,160 @@
+package cluster
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/WuKongIM/WuKongIM/pkg/cluster/reactor"
+	"github.com/WuKongIM/WuKongIM/pkg/cluster/replica"
+	"github.com/WuKongIM/WuKongIM/pkg/wklog"
+	lru "github.com/hashicorp/golang-lru/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+// 不同类型的频道创建时选择各自的配置，没有配置的频道类型使用全局配置
+func TestChannelProfile(t *testing.T) {
+	const (
+		controlType uint8 = 10
+		bulkType    uint8 = 11
+		customType  uint8 = 12
+		defaultType uint8 = 2
+	)
+	destroyedChannelEvents, err := lru.New[string, *channelEvents](10)
+	assert.NoError(t, err)
+	storage := NewPebbleShardLogStorage(t.TempDir(), 1)
+	assert.NoError(t, storage.Open())
+	defer storage.Close()
+	s := &Server{
+		opts: NewOptions(
+			WithNodeId(1),
+			WithMessageLogStorage(storage),
+			WithProposeTimeout(time.Second*10),
+			WithProposeRetryOnNotLeader(true, 0),
+			WithApplyOrderingMode(defaultType, reactor.ApplyOrderingRelaxed),
+			WithChannelProfiles(map[uint8]ChannelProfile{controlType: ChannelProfileControl, bulkType: ChannelProfileBulk}),
+			WithChannelProfile(customType, ChannelProfile{MaxInflightProposes: 1, ApplyOrdering: reactor.ApplyOrderingRelaxed}),
+		),
+		destroyedChannelEvents: destroyedChannelEvents,
+	}
+
+	control := newChannel("control", controlType, s)
+	assert.Equal(t, time.Second*3, control.profile.ProposeTimeout)
+	assert.False(t, control.profile.ProposeRetryOnNotLeader)
+	assert.NotNil(t, control.inflight)
+	assert.Equal(t, reactor.ProposePriorityHigh, control.profile.ProposePriority)
+
+	bulk := newChannel("bulk", bulkType, s)
+	assert.Equal(t, time.Second*30, bulk.profile.ProposeTimeout)
+	assert.True(t, bulk.profile.ProposeRetryOnNotLeader)
+	assert.Nil(t, bulk.inflight)
+	assert.Equal(t, reactor.ProposePriorityNormal, bulk.profile.ProposePriority)
+
+	// 没有设置超时的使用全局的提案超时
+	custom := newChannel("custom", customType, s)
+	assert.Equal(t, time.Second*10, custom.profile.ProposeTimeout)
+	assert.False(t, custom.profile.ProposeRetryOnNotLeader)
+	assert.Equal(t, reactor.ApplyOrderingRelaxed, custom.profile.ApplyOrdering)
+
+	// 没有配置的频道类型使用全局配置
+	def := newChannel("default", defaultType, s)
+	assert.Equal(t, time.Second*10, def.profile.ProposeTimeout)
+	assert.True(t, def.profile.ProposeRetryOnNotLeader)
+	assert.Equal(t, reactor.ApplyOrderingRelaxed, def.profile.ApplyOrdering)
+	assert.Nil(t, def.inflight)
+
+	cm := &channelManager{opts: s.opts, s: s}
+	assert.Equal(t, reactor.ApplyOrderingStrict, cm.applyOrderingMode(control.key))
+	assert.Equal(t, reactor.ApplyOrderingRelaxed, cm.applyOrderingMode(custom.key))
+	assert.Equal(t, reactor.ApplyOrderingRelaxed, cm.applyOrderingMode(def.key))
+}
+
+// 转发给领导的提案带上优先级，旧版本的请求没有优先级时按普通提案处理
+func TestChannelProposeReqPriority(t *testing.T) {
+	req := &ChannelProposeReq{
+		ChannelId:   "test",
+		ChannelType: 2,
+		Logs:        []replica.Log{{Id: 1, Index: 1, Term: 1, Data: []byte("hello")}},
+		Priority:    uint8(reactor.ProposePriorityHigh),
+	}
+	data, err := req.Marshal()
+	assert.NoError(t, err)
+	decoded := &ChannelProposeReq{}
+	assert.NoError(t, decoded.Unmarshal(data))
+	assert.Equal(t, reactor.ProposePriorityHigh, reactor.ProposePriority(decoded.Priority))
+	assert.Equal(t, "hello", string(decoded.Logs[0].Data))
+
+	old := &ChannelProposeReq{}
+	assert.NoError(t, old.Unmarshal(data[:len(data)-1]))
+	assert.Equal(t, reactor.ProposePriorityNormal, reactor.ProposePriority(old.Priority))
+}
+
+// 频道进行中的提案超过配置的数量时直接拒绝
+func TestChannelProfileMaxInflight(t *testing.T) {
+	destroyedChannelEvents, err := lru.New[string, *channelEvents](10)
+	assert.NoError(t, err)
+	storage := NewPebbleShardLogStorage(t.TempDir(), 1)
+	assert.NoError(t, storage.Open())
+	defer storage.Close()
+	s := &Server{
+		opts: NewOptions(
+			WithNodeId(1),
+			WithMessageLogStorage(storage),
+			WithChannelProfile(2, ChannelProfile{MaxInflightProposes: 1}),
+		),
+		destroyedChannelEvents: destroyedChannelEvents,
+		Log:                    wklog.NewWKLog("test"),
+	}
+	cm := &channelManager{
+		channelReactor: reactor.New(reactor.NewOptions(reactor.WithReactorType(reactor.ReactorTypeChannel))),
+		opts:           s.opts,
+		s:              s,
+		Log:            wklog.NewWKLog("test"),
+	}
+	s.channelManager = cm
+	ch := newChannel("test", 2, s)
+	cm.add(ch)
+
+	// 名额被占用时拒绝
+	assert.True(t, ch.inflight.tryAcquire())
+	_, err = cm.proposeAndWait(context.Background(), "test", 2, []replica.Log{{Id: 1, Data: []byte("hello")}})
+	assert.Equal(t, ErrTooBusy, err)
+	ch.inflight.release()
+	assert.True(t, ch.inflight.tryAcquire())
+	ch.inflight.release()
+}
+
+// 频道日志交给OnChannelApply应用，宽松模式下多段日志并行应用
+func TestChannelApplyLogs(t *testing.T) {
+	shardNo := "2&snapshot"
+	storage := newTestSnapshotStorage(t, shardNo, 100)
+	defer storage.Close()
+
+	ch := newSnapshotTestChannel(t, 2, storage, replica.RoleFollower)
+	var (
+		mu      sync.Mutex
+		applied []uint64
+	)
+	ch.opts.OnChannelApply = func(channelId string, channelType uint8, logs []replica.Log) error {
+		assert.Equal(t, "snapshot", channelId)
+		assert.Equal(t, uint8(2), channelType)
+		mu.Lock()
+		for _, lg := range logs {
+			applied = append(applied, lg.Index)
+		}
+		mu.Unlock()
+		return nil
+	}
+	size, err := ch.ApplyLogs(1, 11)
+	assert.NoError(t, err)
+	assert.Len(t, applied, 10)
+	assert.Greater(t, size, uint64(0))
+
+	// 应用失败返回错误，交给reactor重试
+	ch.opts.OnChannelApply = func(channelId string, channelType uint8, logs []replica.Log) error {
+		return ErrChannelNotFound
+	}
+	_, err = ch.ApplyLogs(11, 21)
+	assert.ErrorIs(t, err, ErrChannelNotFound)
+}
@@ -0,0 +1,140 @@
+package cluster
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/WuKongIM/WuKongIM/pkg/cluster/icluster"
+	"github.com/WuKongIM/WuKongIM/pkg/cluster/reactor"
+	"github.com/WuKongIM/WuKongIM/pkg/cluster/replica"
+	"github.com/WuKongIM/WuKongIM/pkg/wkdb"
+	"github.com/WuKongIM/WuKongIM/pkg/wklog"
+	"github.com/bwmarrin/snowflake"
+	lru "github.com/hashicorp/golang-lru/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+// newProposeTestServer 只启动频道reactor的服务，newLeader添加本节点为领导的频道
+func newProposeTestServer(t *testing.T) (*Server, func(channelId string, replicas []uint64) *channel) {
+	setupTestTrace(t)
+
+	destroyedChannelEvents, err := lru.New[string, *channelEvents](10)
+	assert.NoError(t, err)
+	storage := NewPebbleShardLogStorage(t.TempDir(), 1)
+	assert.NoError(t, storage.Open())
+	t.Cleanup(func() { storage.Close() })
+	logIdGen, err := snowflake.NewNode(1)
+	assert.NoError(t, err)
+	s := &Server{
+		opts:                   NewOptions(WithNodeId(1), WithMessageLogStorage(storage), WithMaxMessageSize(1024)),
+		destroyedChannelEvents: destroyedChannelEvents,
+		logIdGen:               logIdGen,
+		Log:                    wklog.NewWKLog("test"),
+	}
+	cm := &channelManager{
+		opts: s.opts,
+		s:    s,
+		Log:  wklog.NewWKLog("test"),
+	}
+	cm.channelReactor = reactor.New(reactor.NewOptions(reactor.WithNodeId(1), reactor.WithSubReactorNum(1), reactor.WithReactorType(reactor.ReactorTypeChannel), reactor.WithRequest(cm), reactor.WithSend(func(m reactor.Message) {})))
+	s.channelManager = cm
+	assert.NoError(t, cm.channelReactor.Start())
+	t.Cleanup(cm.channelReactor.Stop)
+
+	newLeader := func(channelId string, replicas []uint64) *channel {
+		ch := newChannel(channelId, 2, s)
+		assert.NoError(t, ch.Step(replica.Message{
+			MsgType: replica.MsgInitResp,
+			Config:  replica.Config{Role: replica.RoleLeader, Term: 2, Leader: 1, Replicas: replicas, Version: 1},
+		}))
+		ch.cfg = wkdb.ChannelClusterConfig{ChannelId: channelId, ChannelType: 2, LeaderId: 1, Term: 2, Replicas: replicas}
+		cm.add(ch)
+		return ch
+	}
+	return s, newLeader
+}
+
+// 批量提案返回每条数据的结果，不能提案的数据单独失败，其余的数据一起提交
+func TestChannelProposeBatchWithResults(t *testing.T) {
+	_, newLeader := newProposeTestServer(t)
+
+	single := newLeader("single", []uint64{1})
+	results, err := single.proposeBatchWithResults(context.Background(), [][]byte{[]byte("a"), nil, make([]byte, 2048), []byte("b")}, time.Second*5)
+	assert.NoError(t, err)
+	assert.Len(t, results, 4)
+	assert.NoError(t, results[0].Err)
+	assert.ErrorIs(t, results[1].Err, reactor.ErrEmptyPayload)
+	assert.ErrorIs(t, results[2].Err, reactor.ErrMessageTooLarge)
+	assert.NoError(t, results[3].Err)
+	assert.NotZero(t, results[0].Index)
+	assert.Equal(t, results[0].Index+1, results[3].Index)
+	assert.Zero(t, results[1].Index)
+
+	// 多数副本没有确认，可以提案的数据都以同一个错误失败
+	multi := newLeader("multi", []uint64{1, 2, 3})
+	results, err = multi.proposeBatchWithResults(context.Background(), [][]byte{[]byte("a"), nil}, time.Millisecond*100)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.ErrorIs(t, results[0].Err, context.DeadlineExceeded)
+	assert.ErrorIs(t, results[1].Err, reactor.ErrEmptyPayload)
+}
+
+// 转发给领导的批量提案同样按条返回结果：检查不通过的单独失败，没有返回结果的日志算提案失败
+func TestProposeEntries(t *testing.T) {
+	s := &Server{opts: NewOptions(WithMaxMessageSize(1024))}
+	logs := []replica.Log{{Id: 1, Data: []byte("a")}, {Id: 2}, {Id: 3, Data: make([]byte, 2048)}, {Id: 4, Data: []byte("b")}}
+
+	var proposed []uint64
+	results, err := proposeEntries(logs, s.checkProposeData, func(logs []replica.Log) ([]icluster.ProposeResult, error) {
+		for _, lg := range logs {
+			proposed = append(proposed, lg.Id)
+		}
+		return []icluster.ProposeResult{reactor.ProposeResult{Id: 1, Index: 7}}, nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []uint64{1, 4}, proposed)
+	assert.Len(t, results, 4)
+	assert.Equal(t, icluster.ProposeEntryResult{Id: 1, Index: 7}, results[0])
+	assert.ErrorIs(t, results[1].Err, reactor.ErrEmptyPayload)
+	assert.ErrorIs(t, results[2].Err, reactor.ErrMessageTooLarge)
+	assert.ErrorIs(t, results[3].Err, ErrProposeFailed)
+
+	results, err = proposeEntries(logs, s.checkProposeData, func(logs []replica.Log) ([]icluster.ProposeResult, error) {
+		return nil, ErrNotLeader
+	})
+	assert.ErrorIs(t, err, ErrNotLeader)
+	assert.ErrorIs(t, results[0].Err, ErrNotLeader)
+	assert.ErrorIs(t, results[1].Err, reactor.ErrEmptyPayload)
+	assert.ErrorIs(t, results[3].Err, ErrNotLeader)
+}
+
+// 调用方取消ctx后，进行中的提案马上返回ctx的错误，不用等到提案超时
+func TestChannelProposeCancel(t *testing.T) {
+	s, newLeader := newProposeTestServer(t)
+	ch := newLeader("cancel", []uint64{1, 2, 3}) // 没有其他副本确认，提案一直等待
+
+	assertCanceled := func(propose func(ctx context.Context) error) {
+		ctx, cancel := context.WithCancel(context.Background())
+		errC := make(chan error, 1)
+		go func() {
+			errC <- propose(ctx)
+		}()
+		time.Sleep(time.Millisecond * 50)
+		cancel()
+		select {
+		case err := <-errC:
+			assert.ErrorIs(t, err, context.Canceled)
+		case <-time.After(time.Second):
+			t.Fatal("propose not canceled")
+		}
+	}
+
+	assertCanceled(func(ctx context.Context) error {
+		_, err := s.channelManager.proposeAndWait(ctx, "cancel", 2, []replica.Log{{Id: 1, Data: []byte("hello")}})
+		return err
+	})
+	assertCanceled(func(ctx context.Context) error {
+		_, err := ch.proposeBatchWithResults(ctx, [][]byte{[]byte("hello")}, time.Second*10)
+		return err
+	})
+}
@@ -0,0 +1,83 @@
+package cluster
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/WuKongIM/WuKongIM/pkg/cluster/reactor"
+	"github.com/WuKongIM/WuKongIM/pkg/cluster/replica"
+	"github.com/WuKongIM/WuKongIM/pkg/wkdb"
+	"github.com/WuKongIM/WuKongIM/pkg/wklog"
+	lru "github.com/hashicorp/golang-lru/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChannelReadIndex(t *testing.T) {
+	setupTestTrace(t)
+
+	destroyedChannelEvents, err := lru.New[string, *channelEvents](10)
+	assert.NoError(t, err)
+	storage := NewPebbleShardLogStorage(t.TempDir(), 1)
+	assert.NoError(t, storage.Open())
+	defer storage.Close()
+	s := &Server{
+		opts:                   NewOptions(WithNodeId(1), WithMessageLogStorage(storage)),
+		destroyedChannelEvents: destroyedChannelEvents,
+		Log:                    wklog.NewWKLog("test"),
+	}
+	cm := &channelManager{
+		channelReactor: reactor.New(reactor.NewOptions(reactor.WithNodeId(1), reactor.WithSubReactorNum(1), reactor.WithReactorType(reactor.ReactorTypeChannel), reactor.WithSend(func(m reactor.Message) {}))),
+		opts:           s.opts,
+		s:              s,
+		Log:            wklog.NewWKLog("test"),
+	}
+	s.channelManager = cm
+	assert.NoError(t, cm.channelReactor.Start())
+	defer cm.channelReactor.Stop()
+
+	newLeader := func(channelId string, replicas []uint64) *channel {
+		ch := newChannel(channelId, 2, s)
+		assert.NoError(t, ch.Step(replica.Message{
+			MsgType: replica.MsgInitResp,
+			Config:  replica.Config{Role: replica.RoleLeader, Term: 2, Leader: 1, Replicas: replicas, Version: 1},
+		}))
+		ch.cfg = wkdb.ChannelClusterConfig{ChannelId: channelId, ChannelType: 2, LeaderId: 1, Term: 2, Replicas: replicas}
+		cm.add(ch)
+		return ch
+	}
+
+	// 单副本的领导直接返回
+	single := newLeader("single", []uint64{1})
+	_, err = single.ReadIndex(context.Background(), time.Second)
+	assert.NoError(t, err)
+
+	// 多数副本没有确认，超时
+	multi := newLeader("multi", []uint64{1, 2, 3})
+	_, err = multi.ReadIndex(context.Background(), time.Millisecond*50)
+	assert.Equal(t, context.DeadlineExceeded, err)
+
+	// 不是领导，返回当前领导
+	follower := newChannel("follower", 2, s)
+	follower.cfg = wkdb.ChannelClusterConfig{ChannelId: "follower", ChannelType: 2, LeaderId: 2, Term: 2, Replicas: []uint64{1, 2, 3}}
+	_, err = follower.ReadIndex(context.Background(), time.Second)
+	assert.ErrorIs(t, err, ErrNotLeader)
+	var notLeader *NotLeaderError
+	assert.ErrorAs(t, err, &notLeader)
+	assert.Equal(t, uint64(2), notLeader.LeaderId)
+
+	// 确认期间放弃了领导权
+	errC := make(chan error, 1)
+	go func() {
+		_, err := multi.ReadIndex(context.Background(), time.Second*5)
+		errC <- err
+	}()
+	time.Sleep(time.Millisecond * 50)
+	assert.NoError(t, multi.StepDown(2))
+	select {
+	case err := <-errC:
+		assert.ErrorIs(t, err, ErrNotLeader)
+	case <-time.After(time.Second * 2):
+		t.Fatal("read index not failed after step down")
+	}
+}
@@ -0,0 +1,81 @@
+package cluster
+
+import (
+	"context"
+	"time"
+
+	"github.com/WuKongIM/WuKongIM/pkg/cluster/reactor"
+	"github.com/WuKongIM/WuKongIM/pkg/trace"
+	"go.uber.org/zap"
+)
+
+// channelReapMaxPerTick 每次回收最多处理的频道数量，剩余的留到下一轮回收
+const channelReapMaxPerTick = 64
+
+// channelReapLoop 定时回收长时间不活跃的频道
+func (s *Server) channelReapLoop() {
+	tk := time.NewTicker(s.opts.ChannelInactiveTimeout / 2)
+	defer tk.Stop()
+	for {
+		select {
+		case <-tk.C:
+			s.reapInactiveChannels(time.Now())
+		case <-s.stopper.ShouldStop():
+			return
+		}
+	}
+}
+
+// reapInactiveChannels 回收超过ChannelInactiveTimeout没有提案和追加日志的频道，返回成功回收的数量
+// 空闲回收暂停时不回收，有未提交日志的领导频道和恢复中的频道不回收
+// 每轮最多回收channelReapMaxPerTick个频道，且总耗时不超过一个回收周期，避免阻塞下一轮回收
+func (s *Server) reapInactiveChannels(now time.Time) int {
+	if s.channelManager.channelReactor.IdleSweepPaused() {
+		return 0
+	}
+	channels := make([]*channel, 0)
+	s.channelManager.channelReactor.IteratorHandler(func(h reactor.IHandler) bool {
+		if len(channels) >= channelReapMaxPerTick {
+			return false
+		}
+		ch, ok := h.(*channel)
+		if !ok || now.Sub(ch.lastActivity.Load()) < s.opts.ChannelInactiveTimeout {
+			return true
+		}
+		if ch.destroying.Load() || ch.recovering() || ch.hasPendingCommit() {
+			return true
+		}
+		channels = append(channels, ch)
+		return true
+	})
+	if len(channels) == 0 {
+		return 0
+	}
+
+	parentCtx := s.cancelCtx
+	if parentCtx == nil {
+		parentCtx = context.Background()
+	}
+	tickCtx, tickCancel := context.WithTimeout(parentCtx, s.opts.ChannelInactiveTimeout/2)
+	defer tickCancel()
+
+	reaped := 0
+	for _, ch := range channels {
+		if s.stopped.Load() || tickCtx.Err() != nil {
+			break
+		}
+		ctx, cancel := context.WithTimeout(tickCtx, s.opts.ReqTimeout)
+		err := ch.gracefulDestroy(ctx)
+		cancel()
+		if err != nil {
+			s.Warn("reap inactive channel failed", zap.Error(err), zap.String("channelId", ch.channelId), zap.Uint8("channelType", ch.channelType))
+			continue
+		}
+		reaped++
+	}
+	if reaped > 0 {
+		trace.GlobalTrace.Metrics.Cluster().ChannelReapedCountAdd(int64(reaped))
+		s.Info("reap inactive channels", zap.Int("count", reaped), zap.Int("candidates", len(channels)), zap.Duration("inactiveTimeout", s.opts.ChannelInactiveTimeout))
+	}
+	return reaped
+}
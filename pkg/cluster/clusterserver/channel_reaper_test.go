@@ -0,0 +1,104 @@
+package cluster
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/WuKongIM/WuKongIM/pkg/cluster/reactor"
+	"github.com/WuKongIM/WuKongIM/pkg/cluster/replica"
+	"github.com/WuKongIM/WuKongIM/pkg/keylock"
+	"github.com/WuKongIM/WuKongIM/pkg/wkdb"
+	"github.com/WuKongIM/WuKongIM/pkg/wklog"
+	lru "github.com/hashicorp/golang-lru/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+// 长时间不活跃的频道被回收，活跃的频道和有未提交日志的领导频道不回收，空闲回收暂停时不回收
+func TestReapInactiveChannels(t *testing.T) {
+	setupTestTrace(t)
+
+	destroyedChannelEvents, err := lru.New[string, *channelEvents](10)
+	assert.NoError(t, err)
+	storage := NewPebbleShardLogStorage(t.TempDir(), 1)
+	assert.NoError(t, storage.Open())
+	defer storage.Close()
+	s := &Server{
+		opts:                   NewOptions(WithNodeId(1), WithMessageLogStorage(storage), WithChannelInactiveTimeout(time.Minute)),
+		destroyedChannelEvents: destroyedChannelEvents,
+		channelKeyLock:         keylock.NewKeyLock(),
+		Log:                    wklog.NewWKLog("test"),
+	}
+	cm := &channelManager{
+		channelReactor: reactor.New(reactor.NewOptions(reactor.WithNodeId(1), reactor.WithReactorType(reactor.ReactorTypeChannel))),
+		opts:           s.opts,
+		s:              s,
+		Log:            wklog.NewWKLog("test"),
+	}
+	s.channelManager = cm
+
+	newLeader := func(channelId string) *channel {
+		ch := newChannel(channelId, 2, s)
+		assert.NoError(t, ch.Step(replica.Message{
+			MsgType: replica.MsgInitResp,
+			Config:  replica.Config{Role: replica.RoleLeader, Term: 2, Leader: 1, Replicas: []uint64{1, 2, 3}, Version: 1},
+		}))
+		ch.cfg = wkdb.ChannelClusterConfig{ChannelId: channelId, ChannelType: 2, LeaderId: 1, Term: 2, Replicas: []uint64{1, 2, 3}}
+		cm.add(ch)
+		return ch
+	}
+
+	idle := newLeader("idle")
+	active := newLeader("active")
+	pending := newLeader("pending")
+	assert.NoError(t, pending.Step(pending.rc.NewProposeMessage([]byte("hello"))))
+	assert.True(t, pending.hasPendingCommit())
+
+	now := time.Now().Add(time.Minute * 2)
+	active.lastActivity.Store(now)
+
+	// 空闲回收暂停时不回收
+	cm.channelReactor.PauseIdleSweep()
+	assert.Equal(t, 0, s.reapInactiveChannels(now))
+	assert.True(t, cm.channelReactor.ExistHandler(idle.key))
+	cm.channelReactor.ResumeIdleSweep()
+
+	assert.Equal(t, 1, s.reapInactiveChannels(now))
+	assert.False(t, cm.channelReactor.ExistHandler(idle.key))
+	assert.True(t, cm.channelReactor.ExistHandler(active.key))
+	assert.True(t, cm.channelReactor.ExistHandler(pending.key))
+}
+
+// 每轮最多回收channelReapMaxPerTick个频道，剩余的留到下一轮
+func TestReapInactiveChannelsMaxPerTick(t *testing.T) {
+	setupTestTrace(t)
+
+	destroyedChannelEvents, err := lru.New[string, *channelEvents](10)
+	assert.NoError(t, err)
+	storage := NewPebbleShardLogStorage(t.TempDir(), 1)
+	assert.NoError(t, storage.Open())
+	defer storage.Close()
+	s := &Server{
+		opts:                   NewOptions(WithNodeId(1), WithMessageLogStorage(storage), WithChannelInactiveTimeout(time.Minute)),
+		destroyedChannelEvents: destroyedChannelEvents,
+		channelKeyLock:         keylock.NewKeyLock(),
+		Log:                    wklog.NewWKLog("test"),
+	}
+	cm := &channelManager{
+		channelReactor: reactor.New(reactor.NewOptions(reactor.WithNodeId(1), reactor.WithReactorType(reactor.ReactorTypeChannel))),
+		opts:           s.opts,
+		s:              s,
+		Log:            wklog.NewWKLog("test"),
+	}
+	s.channelManager = cm
+
+	total := channelReapMaxPerTick + 10
+	for i := 0; i < total; i++ {
+		cm.add(newChannel(fmt.Sprintf("idle%d", i), 2, s))
+	}
+
+	now := time.Now().Add(time.Minute * 2)
+	assert.Equal(t, channelReapMaxPerTick, s.reapInactiveChannels(now))
+	assert.Equal(t, total-channelReapMaxPerTick, s.reapInactiveChannels(now))
+	assert.Equal(t, 0, s.reapInactiveChannels(now))
+}
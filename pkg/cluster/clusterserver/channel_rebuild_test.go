@@ -0,0 +1,69 @@
+package cluster
+
+import (
+	"context"
+	"testing"
+
+	"github.com/WuKongIM/WuKongIM/pkg/cluster/reactor"
+	"github.com/WuKongIM/WuKongIM/pkg/cluster/replica"
+	"github.com/WuKongIM/WuKongIM/pkg/wkutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// 日志损坏的追随者丢弃本地数据后从领导恢复：先安装快照，剩下的日志正常同步，追上重建时领导的日志后退出恢复状态
+func TestChannelRebuildRecover(t *testing.T) {
+	setupTestTrace(t)
+
+	shardNo := wkutil.ChannelToKey("snapshot", 2)
+	leaderStorage := newTestSnapshotStorage(t, shardNo, 100)
+	defer leaderStorage.Close()
+	assert.NoError(t, leaderStorage.SetAppliedIndex(shardNo, 80))
+	leader := newSnapshotTestChannel(t, 1, leaderStorage, replica.RoleLeader)
+	var fetches int
+	fetch := snapshotFetcher(t, leader, &fetches)
+
+	// 追随者的日志任期和领导冲突（newTestSnapshotStorage按一半日志划分任期，30条以后和领导不一致）
+	followerStorage := newTestSnapshotStorage(t, shardNo, 60)
+	defer followerStorage.Close()
+	assert.NoError(t, followerStorage.SetAppliedIndex(shardNo, 20))
+	corrupted := newSnapshotTestChannel(t, 2, followerStorage, replica.RoleFollower)
+	assert.ErrorIs(t, corrupted.installSnapshot(context.Background(), fetch), ErrLogTermConflict)
+	corrupted.s.channelManager.remove(corrupted)
+
+	// 丢弃本地数据后重新创建频道
+	assert.NoError(t, resetShardLogs(followerStorage, shardNo))
+	follower := newSnapshotTestChannel(t, 2, followerStorage, replica.RoleFollower)
+	follower.beginRecover(100)
+	assert.True(t, follower.recovering())
+	assert.True(t, follower.DebugState().Recovering)
+	assert.Equal(t, uint64(100), follower.DebugState().RecoverTarget)
+
+	// 安装快照后还没追上
+	assert.NoError(t, follower.installSnapshot(context.Background(), fetch))
+	assert.True(t, follower.recovering())
+
+	// 同步剩下的日志后恢复完成
+	logs, err := leaderStorage.Logs(shardNo, 81, 101, 0)
+	assert.NoError(t, err)
+	assert.NoError(t, follower.s.channelManager.AppendLogBatch([]reactor.AppendLogReq{{HandleKey: shardNo, Logs: logs}}))
+	assert.False(t, follower.recovering())
+	assert.False(t, follower.DebugState().Recovering)
+
+	leaderLogs, err := leaderStorage.Logs(shardNo, 1, 0, 0)
+	assert.NoError(t, err)
+	followerLogs, err := followerStorage.Logs(shardNo, 1, 0, 0)
+	assert.NoError(t, err)
+	assert.Len(t, followerLogs, len(leaderLogs))
+	for i, lg := range followerLogs {
+		assert.Equal(t, leaderLogs[i].Index, lg.Index)
+		assert.Equal(t, leaderLogs[i].Term, lg.Term)
+		assert.Equal(t, leaderLogs[i].Data, lg.Data)
+	}
+
+	var types []string
+	for _, event := range follower.events.list() {
+		types = append(types, event.Type)
+	}
+	assert.Contains(t, types, ChannelEventRecovering)
+	assert.Contains(t, types, ChannelEventRecovered)
+}
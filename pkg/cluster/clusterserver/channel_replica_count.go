@@ -0,0 +1,178 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/WuKongIM/WuKongIM/pkg/wkdb"
+	"github.com/WuKongIM/WuKongIM/pkg/wkutil"
+	"go.uber.org/zap"
+)
+
+// 调整副本数量时，检查当前步骤是否完成的间隔
+const replicaCountCheckInterval = time.Millisecond * 200
+
+// nextReplicaCountStep 计算把频道副本数量调整到target的下一步，每步只变更一个成员（单步成员变更）
+// 增加副本：把一个在线的新节点加入学习者，学习者追上领导的日志后由领导转为跟随者（见learnerTo），学习者不参与投票，所以增加过程中法定数量不变
+// 减少副本：移除一个不是领导的跟随者（优先移除离线的），移除后在线的副本数量必须满足新副本集合的法定数量
+// 有迁移在进行中时等待迁移完成，changed表示返回的配置需要提案，done表示副本数量已达到目标
+func nextReplicaCountStep(cfg wkdb.ChannelClusterConfig, target int, candidates []uint64, online func(nodeId uint64) bool) (newCfg wkdb.ChannelClusterConfig, changed bool, done bool, err error) {
+	if target <= 0 {
+		return cfg, false, false, ErrInvalidReplicaCount
+	}
+	if cfg.MigrateFrom != 0 || cfg.MigrateTo != 0 { // 上一步（或其他迁移）还没完成
+		return cfg, false, false, nil
+	}
+
+	newCfg = cfg.Clone()
+	replicaCount := len(cfg.Replicas)
+	switch {
+	case replicaCount == target:
+		if int(cfg.ReplicaMaxCount) == target {
+			return cfg, false, true, nil
+		}
+		newCfg.ReplicaMaxCount = uint16(target)
+		newCfg.ConfVersion = uint64(time.Now().UnixNano())
+		return newCfg, true, true, nil
+	case replicaCount < target:
+		var newReplicaId uint64
+		for _, nodeId := range candidates {
+			if wkutil.ArrayContainsUint64(cfg.Replicas, nodeId) || !online(nodeId) {
+				continue
+			}
+			newReplicaId = nodeId
+			break
+		}
+		if newReplicaId == 0 {
+			return cfg, false, false, ErrNoReplicaCandidate
+		}
+		newCfg.ReplicaMaxCount = uint16(target)
+		newCfg.MigrateFrom = newReplicaId
+		newCfg.MigrateTo = newReplicaId
+		if !wkutil.ArrayContainsUint64(newCfg.Learners, newReplicaId) {
+			newCfg.Learners = append(newCfg.Learners, newReplicaId)
+		}
+	default:
+		var removeId uint64
+		for _, replicaId := range cfg.Replicas {
+			if replicaId == cfg.LeaderId {
+				continue
+			}
+			if removeId == 0 || !online(replicaId) {
+				removeId = replicaId
+			}
+			if !online(replicaId) {
+				break
+			}
+		}
+		if removeId == 0 {
+			return cfg, false, false, ErrReplicaQuorumUnsafe
+		}
+		newCfg.Replicas = wkutil.RemoveUint64(newCfg.Replicas, removeId)
+		onlineCount := 0
+		for _, replicaId := range newCfg.Replicas {
+			if online(replicaId) {
+				onlineCount++
+			}
+		}
+		if onlineCount < len(newCfg.Replicas)/2+1 {
+			return cfg, false, false, fmt.Errorf("%w: online %d of replicas %v", ErrReplicaQuorumUnsafe, onlineCount, newCfg.Replicas)
+		}
+		newCfg.ReplicaMaxCount = uint16(target)
+	}
+	newCfg.ConfVersion = uint64(time.Now().UnixNano())
+	return newCfg, true, false, nil
+}
+
+// ChangeChannelReplicaCount 调整已存在频道的副本数量，必须在频道所属槽的领导节点上调用
+// 每一步的配置都通过槽的日志提交，直到副本数量达到目标或ctx结束
+func (s *Server) ChangeChannelReplicaCount(ctx context.Context, channelId string, channelType uint8, target int) error {
+	if target <= 0 {
+		return ErrInvalidReplicaCount
+	}
+	channelKey := wkutil.ChannelToKey(channelId, channelType)
+	if _, loaded := s.replicaCountChanging.LoadOrStore(channelKey, struct{}{}); loaded {
+		return ErrReplicaCountChanging
+	}
+	defer s.replicaCountChanging.Delete(channelKey)
+
+	tk := time.NewTicker(replicaCountCheckInterval)
+	defer tk.Stop()
+	for {
+		cfg, err := s.getChannelClusterConfig(channelId, channelType)
+		if err != nil {
+			return err
+		}
+		candidates := make([]uint64, 0)
+		for _, node := range s.clusterEventServer.AllowVoteAndJoinedNodes() {
+			candidates = append(candidates, node.Id)
+		}
+		newCfg, changed, done, err := nextReplicaCountStep(cfg, target, candidates, s.replicaOnline)
+		if err != nil {
+			s.Error("ChangeChannelReplicaCount: next step failed", zap.Error(err), zap.String("channelId", channelId), zap.Uint8("channelType", channelType), zap.Int("target", target), zap.Uint64s("replicas", cfg.Replicas))
+			return err
+		}
+		if changed {
+			s.Info("ChangeChannelReplicaCount: step", zap.String("channelId", channelId), zap.Uint8("channelType", channelType), zap.Int("target", target), zap.Uint64s("replicas", newCfg.Replicas), zap.Uint64s("learners", newCfg.Learners))
+			if err = s.proposeReplicaCountStep(ctx, cfg, newCfg); err != nil {
+				return err
+			}
+		}
+		if done {
+			return nil
+		}
+		select {
+		case <-tk.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (s *Server) replicaOnline(nodeId uint64) bool {
+	return nodeId == s.opts.NodeId || s.clusterEventServer.NodeOnline(nodeId)
+}
+
+// 提交副本数量调整的一步，并通知相关的节点
+func (s *Server) proposeReplicaCountStep(ctx context.Context, oldCfg, newCfg wkdb.ChannelClusterConfig) error {
+	// 频道领导在本节点，配置提案期间就算作变更中（提交后会在下面切换配置）
+	ch, _ := s.channelManager.get(newCfg.ChannelId, newCfg.ChannelType).(*channel)
+	if ch != nil && ch.isLeader() && newCfg.LeaderId == s.opts.NodeId {
+		ch.beginConfigChange(newCfg.ConfVersion)
+	} else {
+		ch = nil
+	}
+	timeoutCtx, cancel := context.WithTimeout(ctx, s.opts.ReqTimeout)
+	defer cancel()
+	err := s.opts.ChannelClusterStorage.Propose(timeoutCtx, newCfg)
+	if err != nil {
+		if ch != nil {
+			ch.abortConfigChange(newCfg.ConfVersion)
+		}
+		s.Error("proposeReplicaCountStep: propose failed", zap.Error(err), zap.String("channelId", newCfg.ChannelId), zap.Uint8("channelType", newCfg.ChannelType))
+		return err
+	}
+	s.clusterCfgCache.Add(wkutil.ChannelToKey(newCfg.ChannelId, newCfg.ChannelType), newCfg)
+
+	// 通知频道领导、新加入的学习者、被移除的副本（就算发送失败也没问题，频道领导会间隔比对自己与槽领导的配置）
+	notifyIds := []uint64{newCfg.LeaderId}
+	if newCfg.MigrateTo != 0 {
+		notifyIds = append(notifyIds, newCfg.MigrateTo)
+	}
+	for _, replicaId := range oldCfg.Replicas {
+		if !wkutil.ArrayContainsUint64(newCfg.Replicas, replicaId) {
+			notifyIds = append(notifyIds, replicaId)
+		}
+	}
+	for _, nodeId := range notifyIds {
+		if nodeId == s.opts.NodeId {
+			s.UpdateChannelClusterConfig(newCfg)
+			continue
+		}
+		if err = s.SendChannelClusterConfigUpdate(newCfg.ChannelId, newCfg.ChannelType, nodeId); err != nil {
+			s.Warn("proposeReplicaCountStep: sendChannelClusterConfigUpdate failed", zap.Error(err), zap.Uint64("nodeId", nodeId), zap.String("channelId", newCfg.ChannelId), zap.Uint8("channelType", newCfg.ChannelType))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package cluster
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/WuKongIM/WuKongIM/pkg/wkdb"
+	"github.com/WuKongIM/WuKongIM/pkg/wkutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// 持续写入的情况下把频道从3个副本增加到5个副本
+func TestReplicaCountGrowUnderWrites(t *testing.T) {
+	cfg := wkdb.ChannelClusterConfig{
+		ChannelId:       "test",
+		ChannelType:     2,
+		ReplicaMaxCount: 3,
+		Replicas:        []uint64{1, 2, 3},
+		LeaderId:        1,
+		Term:            1,
+	}
+	candidates := []uint64{1, 2, 3, 4, 5}
+	online := func(nodeId uint64) bool { return true }
+
+	var (
+		leaderLastIndex uint64 = 1000
+		learnerIndex    uint64
+		learnerMinGap   uint64 = 100
+		done            bool
+		changed         bool
+		err             error
+	)
+	for i := 0; i < 1000 && !done; i++ {
+		// 写入一直在进行
+		leaderLastIndex += 50
+
+		// 学习者追赶日志，追上后领导把学习者转为跟随者
+		if cfg.MigrateTo != 0 {
+			learnerIndex += 200
+			if learnerIndex+learnerMinGap >= leaderLastIndex {
+				cfg.Learners = wkutil.RemoveUint64(cfg.Learners, cfg.MigrateTo)
+				cfg.Replicas = append(cfg.Replicas, cfg.MigrateTo)
+				cfg.MigrateFrom = 0
+				cfg.MigrateTo = 0
+			}
+		}
+
+		var newCfg wkdb.ChannelClusterConfig
+		newCfg, changed, done, err = nextReplicaCountStep(cfg, 5, candidates, online)
+		assert.NoError(t, err)
+		if changed {
+			// 单步变更，每次最多只有一个学习者
+			assert.LessOrEqual(t, len(newCfg.Learners), 1)
+			// 增加过程中参与投票的副本不会减少
+			assert.GreaterOrEqual(t, len(newCfg.Replicas), len(cfg.Replicas))
+			if newCfg.MigrateTo != 0 {
+				learnerIndex = 0
+			}
+			cfg = newCfg
+		}
+	}
+	assert.True(t, done)
+	assert.Len(t, cfg.Replicas, 5)
+	assert.Len(t, cfg.Learners, 0)
+	assert.Equal(t, uint16(5), cfg.ReplicaMaxCount)
+	assert.Equal(t, uint64(1), cfg.LeaderId)
+}
+
+func TestReplicaCountShrink(t *testing.T) {
+	cfg := wkdb.ChannelClusterConfig{
+		ReplicaMaxCount: 5,
+		Replicas:        []uint64{1, 2, 3, 4, 5},
+		LeaderId:        1,
+	}
+	offline := map[uint64]bool{4: true}
+	online := func(nodeId uint64) bool { return !offline[nodeId] }
+
+	// 优先移除离线的跟随者，不会移除领导
+	newCfg, changed, done, err := nextReplicaCountStep(cfg, 3, nil, online)
+	assert.NoError(t, err)
+	assert.True(t, changed)
+	assert.False(t, done)
+	assert.Equal(t, []uint64{1, 2, 3, 5}, newCfg.Replicas)
+
+	newCfg, _, _, err = nextReplicaCountStep(newCfg, 3, nil, online)
+	assert.NoError(t, err)
+	assert.Len(t, newCfg.Replicas, 3)
+	assert.Contains(t, newCfg.Replicas, uint64(1))
+
+	_, changed, done, err = nextReplicaCountStep(newCfg, 3, nil, online)
+	assert.NoError(t, err)
+	assert.False(t, changed)
+	assert.True(t, done)
+
+	// 移除后在线的副本不满足法定数量
+	cfg = wkdb.ChannelClusterConfig{
+		ReplicaMaxCount: 3,
+		Replicas:        []uint64{1, 2, 3},
+		LeaderId:        1,
+	}
+	offline = map[uint64]bool{2: true, 3: true}
+	_, _, _, err = nextReplicaCountStep(cfg, 2, nil, online)
+	assert.True(t, errors.Is(err, ErrReplicaQuorumUnsafe))
+
+	_, _, _, err = nextReplicaCountStep(cfg, 0, nil, online)
+	assert.Equal(t, ErrInvalidReplicaCount, err)
+
+	// 迁移中等待
+	cfg.MigrateFrom = 2
+	cfg.MigrateTo = 4
+	_, changed, done, err = nextReplicaCountStep(cfg, 5, nil, online)
+	assert.NoError(t, err)
+	assert.False(t, changed)
+	assert.False(t, done)
+}
@@ -0,0 +1,303 @@
+package cluster
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/WuKongIM/WuKongIM/pkg/cluster/replica"
+	"github.com/WuKongIM/WuKongIM/pkg/wklog"
+	"github.com/WuKongIM/WuKongIM/pkg/wkserver"
+	"github.com/WuKongIM/WuKongIM/pkg/wkutil"
+	"go.uber.org/zap"
+)
+
+// snapshotInstallRetryInterval 副本忙时重新安装快照的间隔
+const snapshotInstallRetryInterval = time.Millisecond * 50
+
+// writeSnapshotChunk 把频道已应用日志的快照（[1, appliedIndex]的日志，格式见snapshot.go）里从from开始的一段写入w，
+// 每段最多LogSyncLimitSizeOfEach字节的日志
+func (c *channel) writeSnapshotChunk(from uint64, w io.Writer) error {
+	appliedIndex, err := c.opts.MessageLogStorage.AppliedIndex(c.key)
+	if err != nil {
+		return err
+	}
+	lastTerm, err := c.logTerm(appliedIndex)
+	if err != nil {
+		return err
+	}
+	baseFile, err := openSnapshotBase(channelSnapshotPath(c.opts.DataDir, c.key))
+	if err != nil {
+		return err
+	}
+	var base io.Reader
+	if baseFile != nil {
+		defer baseFile.Close()
+		base = bufio.NewReader(baseFile)
+	}
+	return writeShardSnapshotChunk(c.opts.MessageLogStorage, c.key, base, w, from, appliedIndex, lastTerm, uint64(c.opts.LogSyncLimitSizeOfEach))
+}
+
+// logTerm 获取日志的任期，日志已经被压缩时从领导任期开始下标的记录里查找
+func (c *channel) logTerm(index uint64) (uint32, error) {
+	if index == 0 {
+		return 0, nil
+	}
+	storage := c.opts.MessageLogStorage
+	logs, err := storage.Logs(c.key, index, index+1, 0)
+	if err != nil {
+		return 0, err
+	}
+	if len(logs) > 0 {
+		return logs[0].Term, nil
+	}
+	term, err := storage.LeaderLastTerm(c.key)
+	if err != nil {
+		return 0, err
+	}
+	for ; term > 0; term-- {
+		startIndex, err := storage.LeaderTermStartIndex(c.key, term)
+		if err != nil {
+			return 0, err
+		}
+		if startIndex != 0 && startIndex <= index {
+			return term, nil
+		}
+	}
+	return 0, ErrSnapshotLogsCompacted
+}
+
+// compactTo 删除index和index之前的日志（日志已经写入快照），不会删除还没有应用的日志，
+// 领导还会保留配置里的副本和学习者还没同步到的日志（同步进度未知的副本不压缩）
+func (c *channel) compactTo(index uint64) error {
+	compactor, ok := c.opts.MessageLogStorage.(logCompactor)
+	if !ok {
+		return nil
+	}
+	appliedIndex, err := c.opts.MessageLogStorage.AppliedIndex(c.key)
+	if err != nil {
+		return err
+	}
+	if index > appliedIndex {
+		index = appliedIndex
+	}
+	if c.isLeader() {
+		cfg := c.cfg
+		c.rcMu.Lock()
+		for _, nodeIds := range [][]uint64{cfg.Replicas, cfg.Learners} {
+			for _, nodeId := range nodeIds {
+				if nodeId == c.opts.NodeId {
+					continue
+				}
+				if last := c.rc.GetReplicaLastLog(nodeId); last < index {
+					index = last
+				}
+			}
+		}
+		c.rcMu.Unlock()
+	}
+	if index == 0 {
+		return nil
+	}
+	if err := compactor.CompactTo(c.key, index); err != nil {
+		return err
+	}
+	if wklog.DebugEnabled() {
+		c.Debug("compact logs", c.logFields(logIndexField(index))...)
+	}
+	return nil
+}
+
+// installSnapshot 从已应用下标的下一条开始分段拉取领导的快照并安装（只能在非领导副本上安装），fetch返回从from开始的一段快照
+// 安装期间副本不存储也不应用日志，快照的日志和复制的日志一样经过频道reactor写入存储（本地已有的日志会跳过，任期冲突返回ErrLogTermConflict），
+// 全部写入后推进已应用下标和副本的日志进度；副本正在存储或应用日志时在改动存储之前返回replica.ErrReplicaBusy
+func (c *channel) installSnapshot(ctx context.Context, fetch func(from uint64) ([]byte, error)) error {
+	c.rcMu.Lock()
+	err := c.rc.BeginInstallSnapshot()
+	c.rcMu.Unlock()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		c.rcMu.Lock()
+		c.rc.EndInstallSnapshot()
+		c.rcMu.Unlock()
+	}()
+
+	appliedIndex, err := c.opts.MessageLogStorage.AppliedIndex(c.key)
+	if err != nil {
+		return err
+	}
+	var (
+		header SnapshotHeader
+		from   = appliedIndex + 1
+	)
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if c.isLeader() {
+			return replica.ErrIsLeader
+		}
+		data, err := fetch(from)
+		if err != nil {
+			return err
+		}
+		var logs []replica.Log
+		header, logs, err = readShardSnapshotChunk(bytes.NewReader(data), c.key, from)
+		if err != nil {
+			return err
+		}
+		if len(logs) == 0 {
+			break
+		}
+		if err := c.s.channelManager.channelReactor.AppendSnapshotLogs(c.key, logs); err != nil {
+			return err
+		}
+		from = logs[len(logs)-1].Index + 1
+		if from > header.LastIndex {
+			break
+		}
+	}
+	if header.LastIndex <= appliedIndex {
+		return nil
+	}
+	if err := c.opts.MessageLogStorage.SetAppliedIndex(c.key, header.LastIndex); err != nil {
+		return err
+	}
+
+	c.rcMu.Lock()
+	err = c.rc.InstallSnapshot(replica.Snapshot{
+		LastIndex: header.LastIndex,
+		LastTerm:  header.LastTerm,
+	})
+	c.rcMu.Unlock()
+	if err != nil {
+		return err
+	}
+	c.lastActivity.Store(time.Now())
+	c.Info("install snapshot", c.logFields(logIndexField(header.LastIndex), zap.Uint64("oldAppliedIndex", appliedIndex))...)
+	return nil
+}
+
+func (s *Server) handleChannelSnapshot(c *wkserver.Context) {
+	req := &ChannelSnapshotReq{}
+	if err := req.Unmarshal(c.Body()); err != nil {
+		s.Error("unmarshal ChannelSnapshotReq failed", zap.Error(err))
+		c.WriteErr(err)
+		return
+	}
+	handler := s.channelManager.get(req.ChannelId, req.ChannelType)
+	if handler == nil {
+		c.WriteErr(ErrChannelNotFound)
+		return
+	}
+	ch := handler.(*channel)
+	if !ch.isLeader() {
+		c.WriteErr(ErrNotIsLeader)
+		return
+	}
+	buff := bytes.NewBuffer(nil)
+	if err := ch.writeSnapshotChunk(req.StartIndex, buff); err != nil {
+		s.Error("write channel snapshot failed", zap.Error(err), zap.String("channelId", req.ChannelId), zap.Uint8("channelType", req.ChannelType), zap.Uint64("startIndex", req.StartIndex))
+		c.WriteErr(err)
+		return
+	}
+	c.Write(buff.Bytes())
+}
+
+// InstallChannelSnapshot 远远落后于领导的副本从领导分段拉取快照并安装，不需要逐条同步所有日志
+// 副本正在存储或应用日志时（replica.ErrReplicaBusy）每隔一段时间重试，直到ctx结束
+func (s *Server) InstallChannelSnapshot(ctx context.Context, channelId string, channelType uint8) error {
+	handler := s.channelManager.get(channelId, channelType)
+	if handler == nil {
+		return ErrChannelNotFound
+	}
+	ch := handler.(*channel)
+	fetch := func(from uint64) ([]byte, error) {
+		leaderId := ch.LeaderId()
+		if leaderId == 0 {
+			return nil, ErrNoLeader
+		}
+		if leaderId == s.opts.NodeId {
+			return nil, replica.ErrIsLeader
+		}
+		node := s.nodeManager.node(leaderId)
+		if node == nil {
+			return nil, ErrNodeNotFound
+		}
+		return node.requestChannelSnapshot(ctx, &ChannelSnapshotReq{
+			ChannelId:   channelId,
+			ChannelType: channelType,
+			StartIndex:  from,
+		})
+	}
+	tick := time.NewTicker(snapshotInstallRetryInterval)
+	defer tick.Stop()
+	for {
+		err := ch.installSnapshot(ctx, fetch)
+		if !errors.Is(err, replica.ErrReplicaBusy) {
+			return err
+		}
+		select {
+		case <-tick.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// onLogsCompacted 领导要同步给副本的日志已经被压缩，通知副本从领导拉取快照安装（同一个副本同时只通知一次）
+func (c *channelManager) onLogsCompacted(handleKey string, to uint64) {
+	if to == 0 || to == c.opts.NodeId {
+		return
+	}
+	key := fmt.Sprintf("%s:%d", handleKey, to)
+	if _, loaded := c.s.snapshotNotifying.LoadOrStore(key, struct{}{}); loaded {
+		return
+	}
+	go func() {
+		defer c.s.snapshotNotifying.Delete(key)
+		node := c.s.nodeManager.node(to)
+		if node == nil {
+			c.Warn("notify install snapshot failed, node not found", c.logFields(handleKey, zap.Uint64("to", to))...)
+			return
+		}
+		channelId, channelType := wkutil.ChannelFromlKey(handleKey)
+		timeoutCtx, cancel := context.WithTimeout(c.s.cancelCtx, c.opts.ReqTimeout)
+		defer cancel()
+		err := node.requestChannelInstallSnapshot(timeoutCtx, &ChannelSnapshotReq{
+			ChannelId:   channelId,
+			ChannelType: channelType,
+		})
+		if err != nil {
+			c.Warn("notify install snapshot failed", c.logFields(handleKey, zap.Error(err), zap.Uint64("to", to))...)
+		}
+	}()
+}
+
+// handleChannelInstallSnapshot 领导通知本节点的副本安装快照，异步安装（同一个频道同时只安装一次）
+func (s *Server) handleChannelInstallSnapshot(c *wkserver.Context) {
+	req := &ChannelSnapshotReq{}
+	if err := req.Unmarshal(c.Body()); err != nil {
+		s.Error("unmarshal ChannelSnapshotReq failed", zap.Error(err))
+		c.WriteErr(err)
+		return
+	}
+	handleKey := wkutil.ChannelToKey(req.ChannelId, req.ChannelType)
+	if _, loaded := s.snapshotInstalling.LoadOrStore(handleKey, struct{}{}); !loaded {
+		go func() {
+			defer s.snapshotInstalling.Delete(handleKey)
+			timeoutCtx, cancel := context.WithTimeout(s.cancelCtx, s.opts.ReqTimeout)
+			defer cancel()
+			if err := s.InstallChannelSnapshot(timeoutCtx, req.ChannelId, req.ChannelType); err != nil {
+				s.Warn("install channel snapshot failed", zap.Error(err), zap.String("channelId", req.ChannelId), zap.Uint8("channelType", req.ChannelType))
+			}
+		}()
+	}
+	c.WriteOk()
+}
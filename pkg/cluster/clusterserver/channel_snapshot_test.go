@@ -0,0 +1,291 @@
+package cluster
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"testing"
+
+	"github.com/WuKongIM/WuKongIM/pkg/cluster/reactor"
+	"github.com/WuKongIM/WuKongIM/pkg/cluster/replica"
+	"github.com/WuKongIM/WuKongIM/pkg/wkdb"
+	"github.com/WuKongIM/WuKongIM/pkg/wklog"
+	"github.com/WuKongIM/WuKongIM/pkg/wkutil"
+	lru "github.com/hashicorp/golang-lru/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+// newSnapshotTestChannel 用storage创建一个频道，role为副本的角色（领导为节点1）
+func newSnapshotTestChannel(t *testing.T, nodeId uint64, storage *PebbleShardLogStorage, role replica.Role) *channel {
+	destroyedChannelEvents, err := lru.New[string, *channelEvents](10)
+	assert.NoError(t, err)
+	s := &Server{
+		opts:                   NewOptions(WithNodeId(nodeId), WithMessageLogStorage(storage), WithDataDir(t.TempDir()), WithLogSyncLimitSizeOfEach(256)),
+		destroyedChannelEvents: destroyedChannelEvents,
+		Log:                    wklog.NewWKLog("test"),
+	}
+	cm := &channelManager{
+		opts: s.opts,
+		s:    s,
+		Log:  wklog.NewWKLog("test"),
+	}
+	cm.channelReactor = reactor.New(reactor.NewOptions(reactor.WithNodeId(nodeId), reactor.WithSubReactorNum(1), reactor.WithReactorType(reactor.ReactorTypeChannel), reactor.WithRequest(cm)))
+	s.channelManager = cm
+	ch := newChannel("snapshot", 2, s)
+	assert.NoError(t, ch.Step(replica.Message{
+		MsgType: replica.MsgInitResp,
+		Config:  replica.Config{Role: role, Term: 2, Leader: 1, Replicas: []uint64{1, 2}, Version: 1},
+	}))
+	ch.cfg = wkdb.ChannelClusterConfig{ChannelId: "snapshot", ChannelType: 2, LeaderId: 1, Term: 2, Replicas: []uint64{1, 2}}
+	cm.add(ch)
+	return ch
+}
+
+// snapshotFetcher 从src分段读取快照，fetches记录拉取的次数
+func snapshotFetcher(t *testing.T, src *channel, fetches *int) func(from uint64) ([]byte, error) {
+	return func(from uint64) ([]byte, error) {
+		*fetches++
+		buff := bytes.NewBuffer(nil)
+		if err := src.writeSnapshotChunk(from, buff); err != nil {
+			return nil, err
+		}
+		return buff.Bytes(), nil
+	}
+}
+
+func TestChannelSnapshot(t *testing.T) {
+	setupTestTrace(t)
+
+	shardNo := wkutil.ChannelToKey("snapshot", 2)
+	leaderStorage := newTestSnapshotStorage(t, shardNo, 100)
+	defer leaderStorage.Close()
+	assert.NoError(t, leaderStorage.SetAppliedIndex(shardNo, 80))
+
+	leader := newSnapshotTestChannel(t, 1, leaderStorage, replica.RoleLeader)
+	var fetches int
+	fetch := snapshotFetcher(t, leader, &fetches)
+
+	// 领导不能安装快照
+	assert.ErrorIs(t, leader.installSnapshot(context.Background(), fetch), replica.ErrIsLeader)
+
+	assertInstalled := func(storage *PebbleShardLogStorage, follower *channel) {
+		logs, err := storage.Logs(shardNo, 1, 0, 0)
+		assert.NoError(t, err)
+		assert.Len(t, logs, 80)
+		for i, lg := range logs {
+			assert.Equal(t, uint64(i+1), lg.Index)
+		}
+		appliedIndex, err := storage.AppliedIndex(shardNo)
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(80), appliedIndex)
+		termStartIndex, err := storage.LeaderTermStartIndex(shardNo, 2)
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(51), termStartIndex)
+		assert.Equal(t, uint64(80), follower.rc.LastLogIndex())
+		assert.Equal(t, uint64(80), follower.rc.CommittedIndex())
+	}
+
+	t.Run("empty follower", func(t *testing.T) {
+		storage := NewPebbleShardLogStorage(t.TempDir(), 1)
+		assert.NoError(t, storage.Open())
+		defer storage.Close()
+
+		follower := newSnapshotTestChannel(t, 2, storage, replica.RoleFollower)
+		fetches = 0
+		assert.NoError(t, follower.installSnapshot(context.Background(), fetch))
+		assertInstalled(storage, follower)
+		assert.Greater(t, fetches, 1) // 分段拉取
+
+		// 重复安装什么也不做
+		assert.NoError(t, follower.installSnapshot(context.Background(), fetch))
+		assertInstalled(storage, follower)
+	})
+
+	t.Run("partially caught up follower", func(t *testing.T) {
+		storage := newTestSnapshotStorage(t, shardNo, 30)
+		defer storage.Close()
+		// newTestSnapshotStorage按一半日志划分任期，这里重写成和领导一致
+		assert.NoError(t, storage.TruncateLogTo(shardNo, 1))
+		leaderLogs, err := leaderStorage.Logs(shardNo, 1, 31, 0)
+		assert.NoError(t, err)
+		assert.NoError(t, storage.AppendLogs(shardNo, leaderLogs))
+		assert.NoError(t, storage.SetLeaderTermStartIndex(shardNo, 1, 1))
+		assert.NoError(t, storage.SetAppliedIndex(shardNo, 20))
+
+		follower := newSnapshotTestChannel(t, 2, storage, replica.RoleFollower)
+		assert.NoError(t, follower.installSnapshot(context.Background(), fetch))
+		assertInstalled(storage, follower)
+		termStartIndex, err := storage.LeaderTermStartIndex(shardNo, 1)
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(1), termStartIndex)
+	})
+
+	t.Run("corrupted snapshot", func(t *testing.T) {
+		storage := NewPebbleShardLogStorage(t.TempDir(), 1)
+		assert.NoError(t, storage.Open())
+		defer storage.Close()
+
+		follower := newSnapshotTestChannel(t, 2, storage, replica.RoleFollower)
+		bad := func(from uint64) ([]byte, error) {
+			data, err := fetch(from)
+			if err != nil {
+				return nil, err
+			}
+			data[len(data)-1] ^= 0xff
+			return data, nil
+		}
+		assert.ErrorIs(t, follower.installSnapshot(context.Background(), bad), ErrSnapshotCorrupted)
+		appliedIndex, err := storage.AppliedIndex(shardNo)
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(0), appliedIndex)
+	})
+
+	t.Run("busy follower", func(t *testing.T) {
+		storage := NewPebbleShardLogStorage(t.TempDir(), 1)
+		assert.NoError(t, storage.Open())
+		defer storage.Close()
+
+		// 副本正在存储同步到的日志，安装快照在改动存储之前失败
+		follower := newSnapshotTestChannel(t, 2, storage, replica.RoleFollower)
+		_ = follower.rc.Ready()
+		assert.NoError(t, follower.rc.Step(replica.Message{MsgType: replica.MsgSyncResp, From: 1, To: 2, Term: 2, Index: 1, Logs: []replica.Log{{Index: 1, Term: 1, Data: []byte("hello snapshot")}}}))
+		_ = follower.rc.Ready()
+		fetches = 0
+		assert.ErrorIs(t, follower.installSnapshot(context.Background(), fetch), replica.ErrReplicaBusy)
+		assert.Equal(t, 0, fetches)
+		logs, err := storage.Logs(shardNo, 1, 0, 0)
+		assert.NoError(t, err)
+		assert.Len(t, logs, 0)
+	})
+}
+
+// 快照写成功后压缩已经在快照里的日志，之后的快照从上一次的快照文件里补上被压缩的日志
+func TestChannelCompactAfterSnapshot(t *testing.T) {
+	setupTestTrace(t)
+
+	shardNo := wkutil.ChannelToKey("snapshot", 2)
+	storage := newTestSnapshotStorage(t, shardNo, 100)
+	defer storage.Close()
+	assert.NoError(t, storage.SetLeaderTermStartIndex(shardNo, 1, 1))
+	assert.NoError(t, storage.SetLeaderTermStartIndex(shardNo, 2, 51))
+	assert.NoError(t, storage.SetAppliedIndex(shardNo, 80))
+
+	follower := newSnapshotTestChannel(t, 2, storage, replica.RoleFollower)
+	cm := follower.s.channelManager
+
+	assert.NoError(t, cm.onSnapshot(shardNo, 80))
+	logs, err := storage.Logs(shardNo, 1, 0, 0)
+	assert.NoError(t, err)
+	assert.Len(t, logs, 20)
+	assert.Equal(t, uint64(81), logs[0].Index)
+
+	// 不能压缩还没应用的日志
+	assert.ErrorIs(t, storage.CompactTo(shardNo, 90), ErrCompactNotApplied)
+
+	// 同步给副本的日志已经被压缩，副本只能安装快照
+	_, err = follower.getLogs(1, 101, 0)
+	assert.ErrorIs(t, err, replica.ErrCompacted)
+	_, err = follower.getLogs(20, 40, 0)
+	assert.ErrorIs(t, err, replica.ErrCompacted)
+	logs, err = follower.getLogs(81, 101, 0)
+	assert.NoError(t, err)
+	assert.Len(t, logs, 20)
+
+	// 被压缩的日志从快照文件里读取
+	dst := NewPebbleShardLogStorage(t.TempDir(), 1)
+	assert.NoError(t, dst.Open())
+	defer dst.Close()
+	other := newSnapshotTestChannel(t, 3, dst, replica.RoleFollower)
+	var fetches int
+	assert.NoError(t, other.installSnapshot(context.Background(), snapshotFetcher(t, follower, &fetches)))
+	dstLogs, err := dst.Logs(shardNo, 1, 0, 0)
+	assert.NoError(t, err)
+	assert.Len(t, dstLogs, 80)
+
+	// 再次快照时只把新应用的日志追加到快照文件
+	assert.NoError(t, cm.onSnapshot(shardNo, 80))
+	assert.NoError(t, storage.SetAppliedIndex(shardNo, 90))
+	assert.NoError(t, cm.onSnapshot(shardNo, 90))
+	logs, err = storage.Logs(shardNo, 1, 0, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(91), logs[0].Index)
+	f, err := os.Open(channelSnapshotPath(follower.opts.DataDir, shardNo))
+	assert.NoError(t, err)
+	defer f.Close()
+	restored := NewPebbleShardLogStorage(t.TempDir(), 1)
+	assert.NoError(t, restored.Open())
+	defer restored.Close()
+	header, err := restoreShardSnapshot(restored, shardNo, f, t.TempDir())
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(90), header.LastIndex) // 只到已应用的日志
+	restoredLogs, err := restored.Logs(shardNo, 1, 0, 0)
+	assert.NoError(t, err)
+	assert.Len(t, restoredLogs, 90)
+
+	// 没有上一次的快照，被压缩的日志找不回来
+	assert.NoError(t, os.Remove(channelSnapshotPath(follower.opts.DataDir, shardNo)))
+	err = follower.writeSnapshotChunk(1, bytes.NewBuffer(nil))
+	assert.ErrorIs(t, err, ErrSnapshotLogsCompacted)
+}
+
+// 领导不会压缩副本还没有同步到的日志
+func TestChannelCompactToKeepsLaggingReplicaLogs(t *testing.T) {
+	setupTestTrace(t)
+
+	shardNo := wkutil.ChannelToKey("snapshot", 2)
+	storage := newTestSnapshotStorage(t, shardNo, 100)
+	defer storage.Close()
+	assert.NoError(t, storage.SetAppliedIndex(shardNo, 80))
+
+	leader := newSnapshotTestChannel(t, 1, storage, replica.RoleLeader)
+	assert.NoError(t, leader.compactTo(80))
+	logs, err := storage.Logs(shardNo, 1, 0, 0)
+	assert.NoError(t, err)
+	assert.Len(t, logs, 100)
+}
+
+// 不支持压缩的存储（例如消息存储）
+type testNoCompactStorage struct {
+	IShardLogStorage
+}
+
+// 存储不支持压缩时不写快照文件，日志一直保留在存储里
+func TestChannelSnapshotSkipNoCompactStorage(t *testing.T) {
+	shardNo := wkutil.ChannelToKey("snapshot", 2)
+	storage := newTestSnapshotStorage(t, shardNo, 100)
+	defer storage.Close()
+	assert.NoError(t, storage.SetAppliedIndex(shardNo, 80))
+
+	opts := NewOptions(WithMessageLogStorage(testNoCompactStorage{storage}), WithDataDir(t.TempDir()))
+	cm := &channelManager{
+		opts: opts,
+		s:    &Server{opts: opts},
+		Log:  wklog.NewWKLog("test"),
+	}
+	assert.NoError(t, cm.onSnapshot(shardNo, 80))
+	_, err := os.Stat(channelSnapshotPath(opts.DataDir, shardNo))
+	assert.True(t, os.IsNotExist(err))
+	logs, err := storage.Logs(shardNo, 1, 0, 0)
+	assert.NoError(t, err)
+	assert.Len(t, logs, 100)
+}
+
+// 本节点不再是频道的副本时删除频道的快照文件
+func TestChannelRemoveSnapshotFile(t *testing.T) {
+	setupTestTrace(t)
+
+	shardNo := wkutil.ChannelToKey("snapshot", 2)
+	storage := newTestSnapshotStorage(t, shardNo, 100)
+	defer storage.Close()
+	assert.NoError(t, storage.SetAppliedIndex(shardNo, 80))
+
+	follower := newSnapshotTestChannel(t, 2, storage, replica.RoleFollower)
+	assert.NoError(t, follower.s.channelManager.onSnapshot(shardNo, 80))
+	p := channelSnapshotPath(follower.opts.DataDir, shardNo)
+	_, err := os.Stat(p)
+	assert.NoError(t, err)
+
+	assert.NoError(t, follower.switchConfig(wkdb.ChannelClusterConfig{ChannelId: "snapshot", ChannelType: 2, LeaderId: 1, Term: 3, Replicas: []uint64{1, 3}}))
+	_, err = os.Stat(p)
+	assert.True(t, os.IsNotExist(err))
+}
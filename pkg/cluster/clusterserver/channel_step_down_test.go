@@ -0,0 +1,99 @@
+package cluster
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/WuKongIM/WuKongIM/pkg/cluster/reactor"
+	"github.com/WuKongIM/WuKongIM/pkg/cluster/replica"
+	"github.com/WuKongIM/WuKongIM/pkg/wkdb"
+	"github.com/WuKongIM/WuKongIM/pkg/wklog"
+	"github.com/WuKongIM/WuKongIM/pkg/wkutil"
+	lru "github.com/hashicorp/golang-lru/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+// 领导放弃领导权后，等待中的提案返回不是领导，之后的提案直接拒绝
+func TestChannelStepDown(t *testing.T) {
+	setupTestTrace(t)
+
+	destroyedChannelEvents, err := lru.New[string, *channelEvents](10)
+	assert.NoError(t, err)
+	storage := NewPebbleShardLogStorage(t.TempDir(), 1)
+	assert.NoError(t, storage.Open())
+	defer storage.Close()
+	s := &Server{
+		opts:                   NewOptions(WithNodeId(1), WithMessageLogStorage(storage)),
+		destroyedChannelEvents: destroyedChannelEvents,
+		Log:                    wklog.NewWKLog("test"),
+	}
+	// 不启动reactor，提案一直等待提交
+	cm := &channelManager{
+		channelReactor: reactor.New(reactor.NewOptions(reactor.WithNodeId(1), reactor.WithReactorType(reactor.ReactorTypeChannel))),
+		opts:           s.opts,
+		s:              s,
+		Log:            wklog.NewWKLog("test"),
+	}
+	s.channelManager = cm
+
+	ch := newChannel("test", 2, s)
+	assert.NoError(t, ch.Step(replica.Message{
+		MsgType: replica.MsgInitResp,
+		Config:  replica.Config{Role: replica.RoleLeader, Term: 2, Leader: 1, Replicas: []uint64{1, 2, 3}, Version: 1},
+	}))
+	ch.cfg = wkdb.ChannelClusterConfig{ChannelId: "test", ChannelType: 2, LeaderId: 1, Term: 2, Replicas: []uint64{1, 2, 3}}
+	cm.add(ch)
+
+	errC := make(chan error, 1)
+	go func() {
+		_, err := cm.proposeAndWait(context.Background(), "test", 2, []replica.Log{{Id: 1, Data: []byte("hello")}})
+		errC <- err
+	}()
+	select {
+	case err := <-errC:
+		t.Fatal("propose should wait for commit", err)
+	case <-time.After(time.Millisecond * 50):
+	}
+
+	// 过期的任期不处理
+	assert.ErrorIs(t, ch.StepDown(1), replica.ErrTermMismatch)
+	assert.True(t, ch.isLeader())
+
+	assert.NoError(t, ch.StepDown(2))
+	assert.False(t, ch.isLeader())
+	select {
+	case err := <-errC:
+		assert.Equal(t, reactor.ErrNotLeader, err)
+	case <-time.After(time.Second):
+		t.Fatal("pending propose not failed after step down")
+	}
+
+	_, err = cm.proposeAndWait(context.Background(), "test", 2, []replica.Log{{Id: 2, Data: []byte("hello")}})
+	assert.Equal(t, reactor.ErrNotLeader, err)
+
+	// 已经不是领导了
+	assert.Equal(t, ErrNotLeader, ch.StepDown(2))
+}
+
+// 转移领导只能转移给频道副本，并且要等目标副本追上已提交的日志
+func TestChannelTransferLeadership(t *testing.T) {
+	setupTestTrace(t)
+
+	shardNo := wkutil.ChannelToKey("snapshot", 2)
+	storage := newTestSnapshotStorage(t, shardNo, 10)
+	defer storage.Close()
+	assert.NoError(t, storage.SetAppliedIndex(shardNo, 10))
+
+	leader := newSnapshotTestChannel(t, 1, storage, replica.RoleLeader)
+	assert.NoError(t, leader.transferLeadership(context.Background(), 1))
+	assert.ErrorIs(t, leader.transferLeadership(context.Background(), 3), ErrTransferTargetNotReplica)
+
+	// 副本2还没有同步过日志，一直等到ctx结束
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+	assert.ErrorIs(t, leader.transferLeadership(ctx, 2), context.DeadlineExceeded)
+
+	follower := newSnapshotTestChannel(t, 2, storage, replica.RoleFollower)
+	assert.ErrorIs(t, follower.transferLeadership(context.Background(), 1), ErrNotLeader)
+}
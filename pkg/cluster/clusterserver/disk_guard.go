@@ -0,0 +1,240 @@
+package cluster
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/WuKongIM/WuKongIM/pkg/cluster/reactor"
+	"github.com/WuKongIM/WuKongIM/pkg/trace"
+	"github.com/WuKongIM/WuKongIM/pkg/wkdb"
+	"github.com/WuKongIM/WuKongIM/pkg/wkserver"
+	"github.com/WuKongIM/WuKongIM/pkg/wkutil"
+	"go.uber.org/atomic"
+	"go.uber.org/zap"
+)
+
+// 剩余空间恢复到DiskMinFreeBytes之上这么多后才退出只读，避免在阈值附近反复切换
+const diskRecoverMargin = 64 * 1024 * 1024
+
+// diskGuard 数据目录所在磁盘的空间保护
+// 剩余空间不足（或追加日志遇到磁盘已满）时节点进入只读：拒绝频道提案，继续提供读取和日志同步，
+// 并把本节点领导的频道转移给其他副本，由磁盘空间充足的节点继续提供写入
+type diskGuard struct {
+	readOnly atomic.Bool
+	freeFnc  func(path string) (uint64, error) // 获取磁盘剩余空间，为nil时使用diskFreeBytes（测试时替换）
+}
+
+func (d *diskGuard) freeBytes(path string) (uint64, error) {
+	if d.freeFnc != nil {
+		return d.freeFnc(path)
+	}
+	return diskFreeBytes(path)
+}
+
+// isDiskFullErr 是否是磁盘已满导致的错误（存储层的错误不一定保留了错误链，所以也按错误信息判断）
+func isDiskFullErr(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, syscall.ENOSPC) || errors.Is(err, ErrDiskFull) {
+		return true
+	}
+	return strings.Contains(err.Error(), syscall.ENOSPC.Error())
+}
+
+// diskReadOnly 节点是否因为磁盘空间不足处于只读
+func (s *Server) diskReadOnly() bool {
+	return s.diskGuard.readOnly.Load()
+}
+
+// onAppendErr 追加日志失败，磁盘已满时进入只读（不崩溃，追加请求会被拒绝后重试）
+func (s *Server) onAppendErr(err error) {
+	if isDiskFullErr(err) {
+		s.enterDiskReadOnly("append failed: " + err.Error())
+	}
+}
+
+func (s *Server) enterDiskReadOnly(reason string) {
+	if !s.diskGuard.readOnly.CompareAndSwap(false, true) {
+		return
+	}
+	s.Warn("disk is nearly full, enter read-only", zap.String("reason", reason), zap.String("dataDir", s.opts.DataDir))
+	trace.GlobalTrace.Metrics.Cluster().DiskReadOnlySet(true)
+	if s.stopped.Load() {
+		return
+	}
+	go s.stepDownChannelLeaders()
+}
+
+func (s *Server) leaveDiskReadOnly(freeBytes uint64) {
+	if !s.diskGuard.readOnly.CompareAndSwap(true, false) {
+		return
+	}
+	s.Info("disk space recovered, leave read-only", zap.Uint64("freeBytes", freeBytes), zap.String("dataDir", s.opts.DataDir))
+	trace.GlobalTrace.Metrics.Cluster().DiskReadOnlySet(false)
+}
+
+// checkDisk 检查磁盘剩余空间，返回错误表示无法获取剩余空间
+func (s *Server) checkDisk() error {
+	freeBytes, err := s.diskGuard.freeBytes(s.opts.DataDir)
+	if err != nil {
+		return err
+	}
+	trace.GlobalTrace.Metrics.Cluster().DiskFreeBytesSet(int64(freeBytes))
+	if s.opts.DiskMinFreeBytes > 0 && freeBytes < s.opts.DiskMinFreeBytes {
+		s.enterDiskReadOnly("free bytes below DiskMinFreeBytes")
+	} else if freeBytes >= s.opts.DiskMinFreeBytes+diskRecoverMargin {
+		s.leaveDiskReadOnly(freeBytes)
+	}
+	return nil
+}
+
+func (s *Server) diskCheckLoop() {
+	tk := time.NewTicker(s.opts.DiskCheckInterval)
+	defer tk.Stop()
+	for {
+		select {
+		case <-tk.C:
+			if err := s.checkDisk(); err != nil {
+				// 平台不支持时不再检查，其他错误（比如数据目录暂时不可访问）记录后下次继续检查
+				if errors.Is(err, ErrDiskFreeBytesUnsupported) {
+					s.Warn("get disk free bytes unsupported, stop checking disk", zap.Error(err), zap.String("dataDir", s.opts.DataDir))
+					return
+				}
+				s.Warn("get disk free bytes failed", zap.Error(err), zap.String("dataDir", s.opts.DataDir))
+			}
+		case <-s.stopper.ShouldStop():
+			return
+		}
+	}
+}
+
+// stepDownChannelLeaders 请求把本节点领导的频道转移给其他副本
+func (s *Server) stepDownChannelLeaders() {
+	channels := make([]*channel, 0)
+	s.channelManager.channelReactor.IteratorHandler(func(h reactor.IHandler) bool {
+		if ch, ok := h.(*channel); ok && ch.isLeader() {
+			channels = append(channels, ch)
+		}
+		return true
+	})
+	if len(channels) == 0 {
+		return
+	}
+	s.Info("step down channel leaders for disk full", zap.Int("channels", len(channels)))
+	for _, ch := range channels {
+		if !s.diskReadOnly() || s.stopped.Load() {
+			return
+		}
+		if err := s.requestChannelLeaderStepDown(s.cancelCtx, ch.channelId, ch.channelType, 0); err != nil {
+			s.Warn("step down channel leader failed", zap.Error(err), zap.String("channelId", ch.channelId), zap.Uint8("channelType", ch.channelType))
+			continue
+		}
+		ch.events.add(ChannelEventLeaderStepDown, "disk full")
+	}
+}
+
+// requestChannelLeaderStepDown 请求频道所属槽的领导把频道领导转移给其他副本（to为0时由槽领导选择）
+func (s *Server) requestChannelLeaderStepDown(ctx context.Context, channelId string, channelType uint8, to uint64) error {
+	slotLeaderId, err := s.SlotLeaderIdOfChannel(channelId, channelType)
+	if err != nil {
+		return err
+	}
+	timeoutCtx, cancel := context.WithTimeout(ctx, s.opts.ReqTimeout)
+	defer cancel()
+	if slotLeaderId == s.opts.NodeId {
+		return s.stepDownChannelLeader(timeoutCtx, channelId, channelType, s.opts.NodeId, to)
+	}
+	node := s.nodeManager.node(slotLeaderId)
+	if node == nil {
+		return ErrNodeNotFound
+	}
+	return node.requestChannelLeaderStepDown(timeoutCtx, &ChannelLeaderStepDownReq{
+		ChannelId:   channelId,
+		ChannelType: channelType,
+		LeaderId:    s.opts.NodeId,
+		To:          to,
+	})
+}
+
+func (s *Server) handleChannelLeaderStepDown(c *wkserver.Context) {
+	req := &ChannelLeaderStepDownReq{}
+	if err := req.Unmarshal(c.Body()); err != nil {
+		s.Error("unmarshal ChannelLeaderStepDownReq failed", zap.Error(err))
+		c.WriteErr(err)
+		return
+	}
+	timeoutCtx, cancel := context.WithTimeout(s.cancelCtx, s.opts.ReqTimeout)
+	defer cancel()
+	if err := s.stepDownChannelLeader(timeoutCtx, req.ChannelId, req.ChannelType, req.LeaderId, req.To); err != nil {
+		s.Error("stepDownChannelLeader failed", zap.Error(err), zap.String("channelId", req.ChannelId), zap.Uint8("channelType", req.ChannelType), zap.Uint64("leaderId", req.LeaderId))
+		c.WriteErr(err)
+		return
+	}
+	c.WriteOk()
+}
+
+// stepDownChannelLeader 在槽领导上把频道领导从leaderId迁移到to（to为0时迁移到一个在线的跟随者），迁移由频道领导在跟随者追上日志后完成（见FollowerToLeader）
+func (s *Server) stepDownChannelLeader(ctx context.Context, channelId string, channelType uint8, leaderId uint64, to uint64) error {
+	cfg, err := s.getChannelClusterConfig(channelId, channelType)
+	if err != nil {
+		return err
+	}
+	if cfg.LeaderId != leaderId { // 领导已经变了
+		return nil
+	}
+	if cfg.MigrateFrom != 0 || cfg.MigrateTo != 0 {
+		if to != 0 && cfg.MigrateFrom == leaderId && cfg.MigrateTo == to { // 重复的请求
+			return nil
+		}
+		return ErrChannelMigrating
+	}
+	newCfg, err := nextStepDownConfig(cfg, to, s.replicaOnline)
+	if err != nil {
+		return err
+	}
+	err = s.opts.ChannelClusterStorage.Propose(ctx, newCfg)
+	if err != nil {
+		return err
+	}
+	s.clusterCfgCache.Add(wkutil.ChannelToKey(channelId, channelType), newCfg)
+	s.Info("step down channel leader", zap.String("channelId", channelId), zap.Uint8("channelType", channelType), zap.Uint64("from", newCfg.MigrateFrom), zap.Uint64("to", newCfg.MigrateTo))
+
+	// 通知频道领导（就算发送失败也没问题，频道领导会间隔比对自己与槽领导的配置）
+	if leaderId == s.opts.NodeId {
+		s.UpdateChannelClusterConfig(newCfg)
+		return nil
+	}
+	return s.SendChannelClusterConfigUpdate(channelId, channelType, leaderId)
+}
+
+// nextStepDownConfig 把领导迁移到to（to为0时迁移到第一个在线的跟随者）
+func nextStepDownConfig(cfg wkdb.ChannelClusterConfig, to uint64, online func(nodeId uint64) bool) (wkdb.ChannelClusterConfig, error) {
+	var followerId uint64
+	if to != 0 {
+		if to == cfg.LeaderId || !wkutil.ArrayContainsUint64(cfg.Replicas, to) {
+			return cfg, ErrTransferTargetNotReplica
+		}
+		if online(to) {
+			followerId = to
+		}
+	} else {
+		for _, replicaId := range cfg.Replicas {
+			if replicaId != cfg.LeaderId && online(replicaId) {
+				followerId = replicaId
+				break
+			}
+		}
+	}
+	if followerId == 0 {
+		return cfg, ErrNoReplicaCandidate
+	}
+	newCfg := cfg.Clone()
+	newCfg.MigrateFrom = cfg.LeaderId
+	newCfg.MigrateTo = followerId
+	newCfg.ConfVersion = uint64(time.Now().UnixNano())
+	return newCfg, nil
+}
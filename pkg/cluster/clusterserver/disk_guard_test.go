@@ -0,0 +1,168 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/WuKongIM/WuKongIM/pkg/cluster/reactor"
+	"github.com/WuKongIM/WuKongIM/pkg/cluster/replica"
+	"github.com/WuKongIM/WuKongIM/pkg/wkdb"
+	"github.com/WuKongIM/WuKongIM/pkg/wklog"
+	"github.com/lni/goutils/syncutil"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/atomic"
+)
+
+// 模拟磁盘已满的存储，追加日志返回ENOSPC
+type diskFullStorage struct {
+	*PebbleShardLogStorage
+	full bool
+}
+
+func (d *diskFullStorage) AppendLogBatch(reqs []reactor.AppendLogReq) error {
+	if d.full {
+		return fmt.Errorf("write wal: %w", syscall.ENOSPC)
+	}
+	return d.PebbleShardLogStorage.AppendLogBatch(reqs)
+}
+
+// 追加日志遇到磁盘已满不崩溃，节点进入只读拒绝提案，空间恢复后退出只读
+func TestDiskFullOnAppend(t *testing.T) {
+	setupTestTrace(t)
+
+	pebbleStorage := NewPebbleShardLogStorage(t.TempDir(), 1)
+	err := pebbleStorage.Open()
+	assert.NoError(t, err)
+	defer pebbleStorage.Close()
+	storage := &diskFullStorage{PebbleShardLogStorage: pebbleStorage, full: true}
+
+	s := &Server{
+		opts: NewOptions(WithMessageLogStorage(storage), WithDiskGuard(1024, 0)),
+		Log:  wklog.NewWKLog("test"),
+	}
+	cm := &channelManager{
+		channelReactor: reactor.New(reactor.NewOptions(reactor.WithReactorType(reactor.ReactorTypeChannel))),
+		opts:           s.opts,
+		s:              s,
+		Log:            wklog.NewWKLog("test"),
+	}
+	s.channelManager = cm
+
+	reqs := []reactor.AppendLogReq{{HandleKey: "test-2", Logs: []replica.Log{{Id: 1, Index: 1, Term: 1, Data: []byte("hello")}}}}
+	assert.NotPanics(t, func() {
+		err = cm.AppendLogBatch(reqs)
+	})
+	assert.ErrorIs(t, err, syscall.ENOSPC)
+	assert.True(t, s.diskReadOnly())
+
+	// 只读时拒绝提案
+	_, err = cm.proposeAndWait(context.Background(), "test", 2, []replica.Log{{Id: 2, Data: []byte("world")}})
+	assert.ErrorIs(t, err, ErrDiskFull)
+
+	// 剩余空间没有超过恢复余量，继续只读
+	s.diskGuard.freeFnc = func(path string) (uint64, error) {
+		return 2048, nil
+	}
+	assert.NoError(t, s.checkDisk())
+	assert.True(t, s.diskReadOnly())
+
+	// 空间恢复，退出只读，追加日志成功
+	s.diskGuard.freeFnc = func(path string) (uint64, error) {
+		return 1024 + diskRecoverMargin, nil
+	}
+	assert.NoError(t, s.checkDisk())
+	assert.False(t, s.diskReadOnly())
+	storage.full = false
+	assert.NoError(t, cm.AppendLogBatch(reqs))
+
+	// 剩余空间低于阈值，提前进入只读
+	s.diskGuard.freeFnc = func(path string) (uint64, error) {
+		return 512, nil
+	}
+	assert.NoError(t, s.checkDisk())
+	assert.True(t, s.diskReadOnly())
+}
+
+// 获取剩余空间失败只记录日志，下次继续检查；平台不支持时停止检查
+func TestDiskCheckLoopKeepsTicking(t *testing.T) {
+	setupTestTrace(t)
+
+	s := &Server{
+		opts:    NewOptions(WithDiskGuard(1024, time.Millisecond*10)),
+		stopper: syncutil.NewStopper(),
+		Log:     wklog.NewWKLog("test"),
+	}
+	s.channelManager = &channelManager{
+		channelReactor: reactor.New(reactor.NewOptions(reactor.WithReactorType(reactor.ReactorTypeChannel))),
+		opts:           s.opts,
+		s:              s,
+		Log:            wklog.NewWKLog("test"),
+	}
+	var calls atomic.Int32
+	s.diskGuard.freeFnc = func(path string) (uint64, error) {
+		if calls.Inc() <= 2 {
+			return 0, syscall.EIO
+		}
+		return 512, nil
+	}
+	s.stopper.RunWorker(s.diskCheckLoop)
+	defer s.stopper.Stop()
+	assert.Eventually(t, s.diskReadOnly, time.Second, time.Millisecond*10)
+
+	// 平台不支持，循环退出
+	unsupported := &Server{
+		opts:    NewOptions(WithDiskGuard(1024, time.Millisecond*10)),
+		stopper: syncutil.NewStopper(),
+		Log:     wklog.NewWKLog("test"),
+	}
+	unsupported.diskGuard.freeFnc = func(path string) (uint64, error) {
+		return 0, ErrDiskFreeBytesUnsupported
+	}
+	doneC := make(chan struct{})
+	go func() {
+		unsupported.diskCheckLoop()
+		close(doneC)
+	}()
+	select {
+	case <-doneC:
+	case <-time.After(time.Second):
+		t.Fatal("disk check loop not stopped")
+	}
+}
+
+func TestIsDiskFullErr(t *testing.T) {
+	assert.True(t, isDiskFullErr(fmt.Errorf("append: %w", syscall.ENOSPC)))
+	assert.True(t, isDiskFullErr(fmt.Errorf("pebble: %s", syscall.ENOSPC.Error())))
+	assert.True(t, isDiskFullErr(ErrDiskFull))
+	assert.False(t, isDiskFullErr(ErrLogTermConflict))
+	assert.False(t, isDiskFullErr(nil))
+}
+
+// 让出领导时迁移到第一个在线的跟随者
+func TestNextStepDownConfig(t *testing.T) {
+	cfg := wkdb.ChannelClusterConfig{ChannelId: "test", ChannelType: 2, LeaderId: 1, Replicas: []uint64{1, 2, 3}}
+	online := func(nodeId uint64) bool {
+		return nodeId != 2
+	}
+	newCfg, err := nextStepDownConfig(cfg, 0, online)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1), newCfg.MigrateFrom)
+	assert.Equal(t, uint64(3), newCfg.MigrateTo)
+	assert.Equal(t, []uint64{1, 2, 3}, newCfg.Replicas)
+	assert.Equal(t, uint64(0), cfg.MigrateTo) // 不修改原配置
+
+	_, err = nextStepDownConfig(cfg, 0, func(nodeId uint64) bool { return nodeId == 1 })
+	assert.ErrorIs(t, err, ErrNoReplicaCandidate)
+
+	// 指定新领导
+	newCfg, err = nextStepDownConfig(cfg, 3, func(nodeId uint64) bool { return true })
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(3), newCfg.MigrateTo)
+	_, err = nextStepDownConfig(cfg, 4, online)
+	assert.ErrorIs(t, err, ErrTransferTargetNotReplica)
+	_, err = nextStepDownConfig(cfg, 2, online)
+	assert.ErrorIs(t, err, ErrNoReplicaCandidate)
+}
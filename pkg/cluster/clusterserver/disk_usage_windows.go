@@ -0,0 +1,9 @@
+//go:build windows
+// +build windows
+
+package cluster
+
+// diskFreeBytes windows下暂不支持检查磁盘剩余空间（追加日志遇到磁盘已满时仍会进入只读）
+func diskFreeBytes(path string) (uint64, error) {
+	return 0, ErrDiskFreeBytesUnsupported
+}
@@ -0,0 +1,119 @@
+package cluster
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/WuKongIM/WuKongIM/pkg/trace"
+	"github.com/WuKongIM/WuKongIM/pkg/wkserver"
+	"go.uber.org/atomic"
+	"go.uber.org/zap"
+)
+
+// electionPause 网络维护期间暂停新的选举（配置节点选举、槽领导选举、频道领导选举），已有的领导继续服务
+// 暂停有时间限制，到期自动恢复，最长不超过ElectionPauseMaxDuration
+type electionPause struct {
+	until atomic.Int64 // 暂停到的时间（unix纳秒），0表示没有暂停
+}
+
+// remaining 暂停剩余的时间，没有暂停或已到期返回0
+func (e *electionPause) remaining(now time.Time) time.Duration {
+	until := e.until.Load()
+	if until == 0 {
+		return 0
+	}
+	remaining := time.Unix(0, until).Sub(now)
+	if remaining <= 0 {
+		return 0
+	}
+	return remaining
+}
+
+// electionPaused 是否暂停了选举，到期后自动恢复
+func (s *Server) electionPaused() bool {
+	if s.electionPause.remaining(time.Now()) > 0 {
+		return true
+	}
+	if until := s.electionPause.until.Load(); until != 0 && s.electionPause.until.CompareAndSwap(until, 0) {
+		s.Info("election pause expired, resume elections")
+		trace.GlobalTrace.Metrics.Cluster().ElectionPauseUntilSet(time.Time{})
+	}
+	return false
+}
+
+// ElectionPauseRemaining 本节点暂停选举剩余的时间，0表示没有暂停
+func (s *Server) ElectionPauseRemaining() time.Duration {
+	return s.electionPause.remaining(time.Now())
+}
+
+// pauseElectionsLocal 暂停本节点的选举d时间（不超过ElectionPauseMaxDuration），d不大于0表示恢复选举，返回实际暂停的时间
+// ElectionPauseMaxDuration不大于0表示不允许暂停，这时暂停请求返回ErrElectionPauseDisabled（恢复选举不受影响）
+func (s *Server) pauseElectionsLocal(d time.Duration) (time.Duration, error) {
+	if d <= 0 {
+		if s.electionPause.until.Swap(0) != 0 {
+			s.Info("resume elections")
+		}
+		trace.GlobalTrace.Metrics.Cluster().ElectionPauseUntilSet(time.Time{})
+		return 0, nil
+	}
+	if s.opts.ElectionPauseMaxDuration <= 0 {
+		s.Warn("election pause is disabled, ignore pause", zap.Duration("duration", d))
+		return 0, ErrElectionPauseDisabled
+	}
+	if d > s.opts.ElectionPauseMaxDuration {
+		s.Warn("election pause duration exceeds the max, use the max", zap.Duration("duration", d), zap.Duration("max", s.opts.ElectionPauseMaxDuration))
+		d = s.opts.ElectionPauseMaxDuration
+	}
+	until := time.Now().Add(d)
+	s.electionPause.until.Store(until.UnixNano())
+	trace.GlobalTrace.Metrics.Cluster().ElectionPauseUntilSet(until)
+	s.Info("pause elections for maintenance", zap.Duration("duration", d))
+	return d, nil
+}
+
+// PauseElections 在集群所有节点上暂停新的选举d时间（网络维护期间避免领导频繁变更），已有的领导继续服务
+// 每个节点暂停的时间都限制在ElectionPauseMaxDuration内，到期自动恢复；通知不到的节点返回错误（已通知到的节点保持暂停）
+func (s *Server) PauseElections(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return s.ResumeElections(ctx)
+	}
+	if _, err := s.pauseElectionsLocal(d); err != nil {
+		return err
+	}
+	return s.broadcastElectionPause(ctx, d)
+}
+
+// ResumeElections 在集群所有节点上恢复选举
+func (s *Server) ResumeElections(ctx context.Context) error {
+	_, _ = s.pauseElectionsLocal(0)
+	return s.broadcastElectionPause(ctx, 0)
+}
+
+func (s *Server) broadcastElectionPause(ctx context.Context, d time.Duration) error {
+	var errs []error
+	for _, n := range s.nodeManager.nodes() {
+		if n.id == s.opts.NodeId {
+			continue
+		}
+		if err := n.requestElectionPause(ctx, &ElectionPauseReq{Duration: d}); err != nil {
+			s.Warn("request election pause failed", zap.Error(err), zap.Uint64("nodeId", n.id), zap.Duration("duration", d))
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (s *Server) handleElectionPause(c *wkserver.Context) {
+	req := &ElectionPauseReq{}
+	if err := req.Unmarshal(c.Body()); err != nil {
+		s.Error("unmarshal ElectionPauseReq failed", zap.Error(err))
+		c.WriteErr(err)
+		return
+	}
+	if _, err := s.pauseElectionsLocal(req.Duration); err != nil {
+		c.WriteErr(err)
+		return
+	}
+	c.WriteOk()
+}
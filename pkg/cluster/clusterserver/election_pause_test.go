@@ -0,0 +1,81 @@
+package cluster
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/WuKongIM/WuKongIM/pkg/wkdb"
+	"github.com/WuKongIM/WuKongIM/pkg/wklog"
+	"github.com/stretchr/testify/assert"
+)
+
+// 暂停期间不发起新的选举，超过最长暂停时间后自动恢复
+func TestElectionPause(t *testing.T) {
+	setupTestTrace(t)
+
+	opts := NewOptions(WithNodeId(1), WithElectionPauseMaxDuration(time.Millisecond*100))
+	s := &Server{
+		opts:        opts,
+		nodeManager: newNodeManager(opts),
+		Log:         wklog.NewWKLog("test"),
+	}
+
+	// 暂停时间超过上限，按上限暂停
+	err := s.PauseElections(context.Background(), time.Hour)
+	assert.NoError(t, err)
+	assert.True(t, s.electionPaused())
+	assert.LessOrEqual(t, s.ElectionPauseRemaining(), time.Millisecond*100)
+
+	_, err = s.electionChannelLeader(context.Background(), wkdb.ChannelClusterConfig{ChannelId: "test", ChannelType: 2})
+	assert.ErrorIs(t, err, ErrElectionPaused)
+	assert.NoError(t, s.onSlotElection(nil))
+
+	// 到期自动恢复
+	assert.Eventually(t, func() bool {
+		return !s.electionPaused()
+	}, time.Second, time.Millisecond*10)
+	assert.Equal(t, time.Duration(0), s.ElectionPauseRemaining())
+
+	// 手动恢复
+	err = s.PauseElections(context.Background(), time.Minute)
+	assert.NoError(t, err)
+	assert.True(t, s.electionPaused())
+	err = s.ResumeElections(context.Background())
+	assert.NoError(t, err)
+	assert.False(t, s.electionPaused())
+}
+
+// 最长暂停时间不大于0表示不允许暂停，暂停请求被拒绝，恢复选举不受影响
+func TestElectionPauseDisabled(t *testing.T) {
+	setupTestTrace(t)
+
+	opts := NewOptions(WithNodeId(1), WithElectionPauseMaxDuration(0))
+	s := &Server{
+		opts:        opts,
+		nodeManager: newNodeManager(opts),
+		Log:         wklog.NewWKLog("test"),
+	}
+
+	err := s.PauseElections(context.Background(), time.Minute)
+	assert.ErrorIs(t, err, ErrElectionPauseDisabled)
+	assert.False(t, s.electionPaused())
+
+	// 其他节点广播过来的暂停也被拒绝
+	d, err := s.pauseElectionsLocal(time.Minute)
+	assert.ErrorIs(t, err, ErrElectionPauseDisabled)
+	assert.Equal(t, time.Duration(0), d)
+	assert.False(t, s.electionPaused())
+
+	assert.NoError(t, s.ResumeElections(context.Background()))
+}
+
+func TestElectionPauseReq(t *testing.T) {
+	req := &ElectionPauseReq{Duration: time.Minute}
+	data, err := req.Marshal()
+	assert.NoError(t, err)
+
+	resp := &ElectionPauseReq{}
+	assert.NoError(t, resp.Unmarshal(data))
+	assert.Equal(t, req.Duration, resp.Duration)
+}
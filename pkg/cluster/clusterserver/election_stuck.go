@@ -0,0 +1,177 @@
+package cluster
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/WuKongIM/WuKongIM/pkg/wkdb"
+	"github.com/WuKongIM/WuKongIM/pkg/wkutil"
+)
+
+// 选举卡住的频道状态
+const electionStuckStatus = "election-stuck"
+
+// 选举卡住后第一次退避的间隔
+const electionStuckMinBackoff = time.Second
+
+// electionStuckInfo 选举卡住的频道信息
+type electionStuckInfo struct {
+	ChannelId      string `json:"channel_id"`
+	ChannelType    uint8  `json:"channel_type"`
+	Status         string `json:"status"`
+	Failures       int    `json:"failures"`        // 连续选举失败次数
+	FirstFailAt    int64  `json:"first_fail_at"`   // 第一次选举失败的时间（毫秒）
+	LastErr        string `json:"last_err"`        // 最近一次选举失败的错误
+	SuspectedCause string `json:"suspected_cause"` // 疑似原因
+	NextAttemptAt  int64  `json:"next_attempt_at"` // 下次允许选举的时间（毫秒）
+}
+
+type electionStuckState struct {
+	channelId      string
+	channelType    uint8
+	failures       int
+	firstFailAt    time.Time
+	lastErr        error
+	suspectedCause string
+	stuck          bool
+	backoff        time.Duration
+	nextAttemptAt  time.Time
+}
+
+// electionStuck 检测一直选不出领导的频道（比如副本配置错误导致永远达不到法定数量）
+// 连续选举失败超过threshold后认为选举卡住了：大声打印日志（带疑似原因），并按指数退避限制选举的频率，避免一直空转
+type electionStuck struct {
+	mu         sync.Mutex
+	channels   map[string]*electionStuckState
+	threshold  time.Duration // 连续选举失败多久算卡住，0表示不检测
+	maxBackoff time.Duration // 最大退避间隔
+}
+
+func newElectionStuck(threshold, maxBackoff time.Duration) *electionStuck {
+	if maxBackoff < electionStuckMinBackoff {
+		maxBackoff = electionStuckMinBackoff
+	}
+	return &electionStuck{
+		channels:   make(map[string]*electionStuckState),
+		threshold:  threshold,
+		maxBackoff: maxBackoff,
+	}
+}
+
+// allow 是否允许频道发起选举，选举卡住且还在退避中时返回ErrElectionBackoff
+func (e *electionStuck) allow(channelId string, channelType uint8, now time.Time) error {
+	if e == nil || e.threshold <= 0 {
+		return nil
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	st := e.channels[wkutil.ChannelToKey(channelId, channelType)]
+	if st == nil || !st.stuck || !now.Before(st.nextAttemptAt) {
+		return nil
+	}
+	return fmt.Errorf("%w: next attempt after %s, cause: %s", ErrElectionBackoff, st.nextAttemptAt.Sub(now).Truncate(time.Millisecond), st.suspectedCause)
+}
+
+// failed 记录选举失败，返回是否是这次失败导致频道被判定为选举卡住
+func (e *electionStuck) failed(channelId string, channelType uint8, err error, cause string, now time.Time) bool {
+	if e == nil || e.threshold <= 0 {
+		return false
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	key := wkutil.ChannelToKey(channelId, channelType)
+	st := e.channels[key]
+	if st == nil {
+		st = &electionStuckState{channelId: channelId, channelType: channelType, firstFailAt: now}
+		e.channels[key] = st
+	}
+	st.failures++
+	st.lastErr = err
+	st.suspectedCause = cause
+
+	becameStuck := false
+	if !st.stuck {
+		if now.Sub(st.firstFailAt) < e.threshold {
+			return false
+		}
+		st.stuck = true
+		becameStuck = true
+	}
+	// 指数退避
+	if st.backoff == 0 {
+		st.backoff = electionStuckMinBackoff
+	} else {
+		st.backoff *= 2
+	}
+	if st.backoff > e.maxBackoff {
+		st.backoff = e.maxBackoff
+	}
+	st.nextAttemptAt = now.Add(st.backoff)
+	return becameStuck
+}
+
+// succeeded 频道有了领导（本节点选举成功、其他节点选出了领导或者领导一直在线），清除失败记录和卡住状态
+func (e *electionStuck) succeeded(channelId string, channelType uint8) {
+	e.remove(channelId, channelType)
+}
+
+// remove 频道从本节点移除，删除频道的记录
+func (e *electionStuck) remove(channelId string, channelType uint8) {
+	if e == nil || e.threshold <= 0 {
+		return
+	}
+	e.mu.Lock()
+	delete(e.channels, wkutil.ChannelToKey(channelId, channelType))
+	e.mu.Unlock()
+}
+
+// list 选举卡住的频道
+func (e *electionStuck) list() []*electionStuckInfo {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	infos := make([]*electionStuckInfo, 0)
+	for _, st := range e.channels {
+		if !st.stuck {
+			continue
+		}
+		lastErr := ""
+		if st.lastErr != nil {
+			lastErr = st.lastErr.Error()
+		}
+		infos = append(infos, &electionStuckInfo{
+			ChannelId:      st.channelId,
+			ChannelType:    st.channelType,
+			Status:         electionStuckStatus,
+			Failures:       st.failures,
+			FirstFailAt:    st.firstFailAt.UnixMilli(),
+			LastErr:        lastErr,
+			SuspectedCause: st.suspectedCause,
+			NextAttemptAt:  st.nextAttemptAt.UnixMilli(),
+		})
+	}
+	return infos
+}
+
+// 选举失败的疑似原因
+func (s *Server) electionFailedCause(cfg wkdb.ChannelClusterConfig, err error) string {
+	offlines := make([]uint64, 0)
+	for _, replicaId := range cfg.Replicas {
+		if replicaId != s.opts.NodeId && !s.clusterEventServer.NodeOnline(replicaId) {
+			offlines = append(offlines, replicaId)
+		}
+	}
+	switch err {
+	case ErrNotEnoughReplicas:
+		if len(offlines) > 0 {
+			return fmt.Sprintf("unreachable peers %v of replicas %v", offlines, cfg.Replicas)
+		}
+		return fmt.Sprintf("config mismatch, replicas %v online but not enough replied channel log info", cfg.Replicas)
+	case ErrNoLeader:
+		return fmt.Sprintf("config mismatch, no replica of %v eligible for leader", cfg.Replicas)
+	}
+	if len(offlines) > 0 {
+		return fmt.Sprintf("unreachable peers %v of replicas %v", offlines, cfg.Replicas)
+	}
+	return err.Error()
+}
@@ -0,0 +1,65 @@
+package cluster
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestElectionStuck(t *testing.T) {
+	e := newElectionStuck(time.Second*10, time.Second*4)
+	now := time.Now()
+
+	// 阈值内的失败，不算卡住，也不退避
+	assert.False(t, e.failed("g1", 2, ErrNotEnoughReplicas, "unreachable peers [2 3]", now))
+	assert.NoError(t, e.allow("g1", 2, now))
+	assert.Len(t, e.list(), 0)
+
+	// 超过阈值，判定为卡住并开始退避
+	now = now.Add(time.Second * 11)
+	assert.True(t, e.failed("g1", 2, ErrNotEnoughReplicas, "unreachable peers [2 3]", now))
+	err := e.allow("g1", 2, now)
+	assert.True(t, errors.Is(err, ErrElectionBackoff))
+	infos := e.list()
+	assert.Len(t, infos, 1)
+	assert.Equal(t, electionStuckStatus, infos[0].Status)
+	assert.Equal(t, 2, infos[0].Failures)
+	assert.Equal(t, "unreachable peers [2 3]", infos[0].SuspectedCause)
+
+	// 退避结束后允许再次选举，再次失败退避加倍，不超过最大退避
+	now = now.Add(time.Second)
+	assert.NoError(t, e.allow("g1", 2, now))
+	assert.False(t, e.failed("g1", 2, ErrNotEnoughReplicas, "unreachable peers [2 3]", now))
+	assert.Error(t, e.allow("g1", 2, now.Add(time.Millisecond*1500)))
+	assert.NoError(t, e.allow("g1", 2, now.Add(time.Second*2)))
+	for i := 0; i < 5; i++ {
+		e.failed("g1", 2, ErrNotEnoughReplicas, "", now)
+	}
+	assert.NoError(t, e.allow("g1", 2, now.Add(time.Second*4)))
+
+	// 其他频道不受影响
+	assert.NoError(t, e.allow("g2", 2, now))
+
+	// 选举成功后清除
+	e.succeeded("g1", 2)
+	assert.NoError(t, e.allow("g1", 2, now))
+	assert.Len(t, e.list(), 0)
+
+	// 还没判定为卡住的失败记录，频道从本节点移除时删除
+	e.failed("g3", 2, ErrNoLeader, "", now)
+	assert.Len(t, e.channels, 1)
+	e.remove("g3", 2)
+	assert.Len(t, e.channels, 0)
+
+	// 阈值为0不检测
+	e = newElectionStuck(0, 0)
+	assert.False(t, e.failed("g1", 2, ErrNoLeader, "", now.Add(time.Hour)))
+	assert.NoError(t, e.allow("g1", 2, now))
+
+	// 没有初始化（测试里的Server）时忽略
+	var nilStuck *electionStuck
+	nilStuck.succeeded("g1", 2)
+	assert.NoError(t, nilStuck.allow("g1", 2, now))
+}
@@ -0,0 +1,50 @@
+package cluster
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// 转发提案使用转发超时，而不是本地提案超时
+func TestForwardContextUsesForwardTimeout(t *testing.T) {
+	cancelCtx, cancelFnc := context.WithCancel(context.Background())
+	s := &Server{
+		opts:      NewOptions(WithProposeTimeout(time.Second), WithForwardTimeout(time.Second*3)),
+		cancelCtx: cancelCtx,
+	}
+
+	ctx, cancel := s.forwardContext(context.Background(), s.opts.ProposeTimeout)
+	deadline, ok := ctx.Deadline()
+	assert.True(t, ok)
+	assert.Greater(t, time.Until(deadline), s.opts.ProposeTimeout)
+	assert.LessOrEqual(t, time.Until(deadline), time.Second*3)
+	cancel()
+
+	// 没有设置转发超时，默认比本地提案超时长
+	s.opts.ForwardTimeout = 0
+	ctx, cancel = s.forwardContext(context.Background(), s.opts.ProposeTimeout)
+	deadline, _ = ctx.Deadline()
+	assert.Greater(t, time.Until(deadline), s.opts.ProposeTimeout)
+	cancel()
+
+	// 调用方的ctx更短，以调用方为准
+	callerCtx, callerCancel := context.WithTimeout(context.Background(), time.Millisecond*100)
+	defer callerCancel()
+	ctx, cancel = s.forwardContext(callerCtx, s.opts.ProposeTimeout)
+	deadline, _ = ctx.Deadline()
+	assert.LessOrEqual(t, time.Until(deadline), time.Millisecond*100)
+	cancel()
+
+	// 服务停止，转发随之取消
+	ctx, cancel = s.forwardContext(context.Background(), s.opts.ProposeTimeout)
+	defer cancel()
+	cancelFnc()
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("forward context not canceled after server stop")
+	}
+}
@@ -0,0 +1,83 @@
+package cluster
+
+import (
+	"sync"
+	"time"
+
+	"github.com/WuKongIM/WuKongIM/pkg/cluster/reactor"
+	"github.com/WuKongIM/WuKongIM/pkg/cluster/replica"
+	"github.com/WuKongIM/WuKongIM/pkg/trace"
+	"github.com/WuKongIM/WuKongIM/pkg/wknet"
+	"go.uber.org/zap"
+)
+
+// connKeyInboundLimiter 连接上保存入站限速器的key，连接关闭后限速器跟着释放
+const connKeyInboundLimiter = "clusterInboundLimiter"
+
+// inboundLimiter 单个节点连接接收副本消息的限速（按消息数量的令牌桶），一个节点发送再多的消息也只消耗自己的额度，不会挤占其他节点
+type inboundLimiter struct {
+	*tokenBucket
+
+	mu             sync.Mutex
+	throttledUntil time.Time // 关键消息超过限速后连接被限流到这个时间，期间丢弃这个连接的所有可丢弃消息
+}
+
+func newInboundLimiter(rate int, burst int) *inboundLimiter {
+	if burst <= 0 {
+		burst = rate
+	}
+	return &inboundLimiter{
+		tokenBucket: newTokenBucket(float64(rate), float64(burst), false),
+	}
+}
+
+// allow 消息是否可以处理，droppable为消息超过限速时是否可以丢弃
+// 关键消息超过限速也会处理（透支令牌），并返回throttled为true表示连接进入限流，直到透支的令牌恢复
+func (l *inboundLimiter) allow(now time.Time, droppable bool) (ok bool, throttled bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if droppable && now.Before(l.throttledUntil) {
+		return false, false
+	}
+	if _, ok := l.reserve(now, 1, 0); ok {
+		return true, false
+	}
+	if droppable {
+		return false, false
+	}
+	l.throttledUntil = now.Add(l.take(now, 1))
+	return true, true
+}
+
+// inboundDroppable 超过限速时可以丢弃的消息（丢弃后对方会重发，或下一次心跳会恢复）
+func inboundDroppable(msgType replica.MsgType) bool {
+	switch msgType {
+	case replica.MsgPing, replica.MsgPong, replica.MsgSyncReq, replica.MsgConfigReq:
+		return true
+	}
+	return false
+}
+
+// allowInbound 从节点连接收到的槽或频道消息是否可以处理（开启InboundMessageRate时才按连接限速）
+func (s *Server) allowInbound(c wknet.Conn, kind trace.ClusterKind, msg reactor.Message) bool {
+	if s.opts.InboundMessageRate <= 0 {
+		return true
+	}
+	// 同一个连接的消息是在连接的事件循环里顺序处理的，不会并发创建限速器
+	l, _ := c.Value(connKeyInboundLimiter).(*inboundLimiter)
+	if l == nil {
+		l = newInboundLimiter(s.opts.InboundMessageRate, s.opts.InboundMessageBurst)
+		c.SetValue(connKeyInboundLimiter, l)
+	}
+	ok, throttled := l.allow(time.Now(), inboundDroppable(msg.MsgType))
+	if !ok {
+		trace.GlobalTrace.Metrics.Cluster().InboundDroppedCountAdd(kind, 1)
+		return false
+	}
+	if throttled {
+		trace.GlobalTrace.Metrics.Cluster().InboundThrottledCountAdd(kind, 1)
+		s.Debug("inbound message rate exceeded, throttle conn", zap.String("uid", c.UID()), zap.String("msgType", msg.MsgType.String()), zap.String("handlerKey", msg.HandlerKey))
+	}
+	return true
+}
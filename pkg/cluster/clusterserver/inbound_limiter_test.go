@@ -0,0 +1,102 @@
+package cluster
+
+import (
+	"testing"
+	"time"
+
+	"github.com/WuKongIM/WuKongIM/pkg/cluster/reactor"
+	"github.com/WuKongIM/WuKongIM/pkg/cluster/replica"
+	"github.com/WuKongIM/WuKongIM/pkg/trace"
+	"github.com/WuKongIM/WuKongIM/pkg/wklog"
+	"github.com/WuKongIM/WuKongIM/pkg/wknet"
+	"github.com/stretchr/testify/assert"
+)
+
+type testInboundConn struct {
+	wknet.Conn
+	uid    string
+	values map[string]interface{}
+}
+
+func newTestInboundConn(uid string) *testInboundConn {
+	return &testInboundConn{uid: uid, values: make(map[string]interface{})}
+}
+
+func (t *testInboundConn) UID() string {
+	return t.uid
+}
+
+func (t *testInboundConn) SetValue(key string, value interface{}) {
+	t.values[key] = value
+}
+
+func (t *testInboundConn) Value(key string) interface{} {
+	return t.values[key]
+}
+
+func TestInboundLimiterAllow(t *testing.T) {
+	l := newInboundLimiter(10, 2)
+	now := l.last
+
+	// 突发数量内都可以处理
+	ok, throttled := l.allow(now, true)
+	assert.True(t, ok)
+	assert.False(t, throttled)
+	ok, _ = l.allow(now, true)
+	assert.True(t, ok)
+
+	// 超过限速，可丢弃的消息丢弃
+	ok, throttled = l.allow(now, true)
+	assert.False(t, ok)
+	assert.False(t, throttled)
+
+	// 关键消息不丢弃，连接进入限流
+	ok, throttled = l.allow(now, false)
+	assert.True(t, ok)
+	assert.True(t, throttled)
+
+	// 限流期间，令牌恢复了也丢弃可丢弃的消息
+	ok, _ = l.allow(now.Add(time.Millisecond*150), true)
+	assert.False(t, ok)
+
+	// 限流结束后恢复
+	ok, throttled = l.allow(now.Add(time.Millisecond*300), true)
+	assert.True(t, ok)
+	assert.False(t, throttled)
+}
+
+// 一个节点大量发送消息，只会丢弃它自己的消息，其他节点的消息不受影响
+func TestInboundFloodNotStarveOthers(t *testing.T) {
+	setupTestTrace(t)
+
+	s := &Server{
+		opts: NewOptions(WithNodeId(1), WithInboundMessageRate(100, 100)),
+		Log:  wklog.NewWKLog("test"),
+	}
+	flooder := newTestInboundConn("2")
+	normal := newTestInboundConn("3")
+
+	syncReq := reactor.Message{HandlerKey: "test", Message: replica.Message{MsgType: replica.MsgSyncReq}}
+	voteReq := reactor.Message{HandlerKey: "test", Message: replica.Message{MsgType: replica.MsgVoteReq}}
+
+	var flooderAllowed, normalAllowed int
+	for i := 0; i < 10000; i++ {
+		if s.allowInbound(flooder, trace.ClusterKindChannel, syncReq) {
+			flooderAllowed++
+		}
+		// 其他节点正常频率的消息
+		if i%200 == 0 && s.allowInbound(normal, trace.ClusterKindChannel, syncReq) {
+			normalAllowed++
+		}
+	}
+	assert.Less(t, flooderAllowed, 200)
+	assert.Equal(t, 50, normalAllowed)
+
+	// 超过限速的关键消息也不会丢弃
+	assert.True(t, s.allowInbound(flooder, trace.ClusterKindChannel, voteReq))
+	assert.True(t, s.allowInbound(normal, trace.ClusterKindChannel, voteReq))
+
+	// 没有开启限速
+	s.opts.InboundMessageRate = 0
+	assert.True(t, s.allowInbound(flooder, trace.ClusterKindChannel, syncReq))
+}
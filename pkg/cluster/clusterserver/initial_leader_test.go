@@ -0,0 +1,83 @@
+package cluster
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/WuKongIM/WuKongIM/pkg/cluster/clusterconfig/pb"
+	"github.com/WuKongIM/WuKongIM/pkg/cluster/replica"
+	"github.com/WuKongIM/WuKongIM/pkg/wklog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewChannelClusterConfigWithInitialLeaderHint(t *testing.T) {
+	nodes := []*pb.Node{{Id: 1}, {Id: 2}, {Id: 3}, {Id: 4}}
+
+	// 没有提示，默认槽领导（当前节点）为领导
+	s := &Server{opts: NewOptions(WithNodeId(1), WithChannelMaxReplicaCount(3)), Log: wklog.NewWKLog("test")}
+	cfg := s.newChannelClusterConfig("test", 2, 3, nodes)
+	assert.Equal(t, uint64(1), cfg.LeaderId)
+	assert.Equal(t, uint32(1), cfg.Term)
+	assert.Len(t, cfg.Replicas, 3)
+
+	// 提示请求创建配置的节点直接成为领导，不需要再选举
+	var hintRequester uint64
+	s.opts.InitialLeaderHint = func(channelId string, channelType uint8, requester uint64) uint64 {
+		hintRequester = requester
+		return requester
+	}
+	cfg = s.newChannelClusterConfig("test", 2, 3, nodes)
+	assert.Equal(t, uint64(3), hintRequester)
+	assert.Equal(t, uint64(3), cfg.LeaderId)
+	assert.Equal(t, uint32(1), cfg.Term)
+	assert.Len(t, cfg.Replicas, 3)
+	assert.Equal(t, uint64(3), cfg.Replicas[0])
+	assert.Contains(t, cfg.Replicas, uint64(1))
+
+	// 提示的节点不是允许投票的节点，回退为槽领导
+	s.opts.InitialLeaderHint = func(channelId string, channelType uint8, requester uint64) uint64 {
+		return 9
+	}
+	cfg = s.newChannelClusterConfig("test", 2, 3, nodes)
+	assert.Equal(t, uint64(1), cfg.LeaderId)
+	assert.Equal(t, uint64(1), cfg.Replicas[0])
+}
+
+// 新频道的第一条消息：提示请求节点为领导时，请求节点直接在本地提交，不需要转发给槽领导
+func TestInitialLeaderHintTimeToFirstCommit(t *testing.T) {
+	s, _ := newProposeTestServer(t) // 请求节点（节点1）
+	nodes := []*pb.Node{{Id: 1}, {Id: 2}, {Id: 3}}
+	slotLeader := &Server{opts: NewOptions(WithNodeId(3), WithChannelMaxReplicaCount(1)), Log: wklog.NewWKLog("test")}
+
+	// 没有提示，槽领导是频道领导，请求节点的第一条消息需要先转发给槽领导
+	cfg := slotLeader.newChannelClusterConfig("first", 2, s.opts.NodeId, nodes)
+	assert.Equal(t, uint64(3), cfg.LeaderId)
+	assert.NotContains(t, cfg.Replicas, s.opts.NodeId)
+
+	// 提示请求节点为领导
+	slotLeader.opts.InitialLeaderHint = func(channelId string, channelType uint8, requester uint64) uint64 {
+		return requester
+	}
+	cfg = slotLeader.newChannelClusterConfig("first", 2, s.opts.NodeId, nodes)
+	assert.Equal(t, s.opts.NodeId, cfg.LeaderId)
+	assert.Equal(t, []uint64{s.opts.NodeId}, cfg.Replicas)
+
+	ch := newChannel(cfg.ChannelId, cfg.ChannelType, s)
+	assert.NoError(t, ch.Step(replica.Message{
+		MsgType: replica.MsgInitResp,
+		Config:  replica.Config{Role: replica.RoleLeader, Term: cfg.Term, Leader: cfg.LeaderId, Replicas: cfg.Replicas, Version: 1},
+	}))
+	ch.cfg = cfg
+	s.channelManager.add(ch)
+
+	start := time.Now()
+	results, err := ch.proposeBatchWithResults(context.Background(), [][]byte{[]byte("first")}, time.Second*5)
+	timeToFirstCommit := time.Since(start)
+	assert.NoError(t, err)
+	assert.NoError(t, results[0].Err)
+	assert.Equal(t, uint64(1), results[0].Index)
+	// 没有选举和转发，第一条消息在一次本地提交内完成（远小于一次请求超时）
+	assert.Less(t, timeToFirstCommit, s.opts.ReqTimeout)
+	t.Logf("time to first commit with initial leader hint: %s", timeToFirstCommit)
+}
@@ -0,0 +1,96 @@
+package cluster
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/WuKongIM/WuKongIM/pkg/trace"
+	"github.com/WuKongIM/WuKongIM/pkg/wkutil"
+	"go.uber.org/atomic"
+	"go.uber.org/zap"
+)
+
+type leaderChange struct {
+	prevLeader uint64
+	leader     uint64
+	at         time.Time
+}
+
+// leaderFlapping 记录频道最近的领导变更，窗口内变更次数达到阈值认为领导在频繁变更（flapping），
+// 例如某个节点的网络时好时坏，领导在几个节点之间来回切换，频道没有完全不可用但延迟会变差
+type leaderFlapping struct {
+	mu       sync.Mutex
+	changes  []leaderChange
+	flapping atomic.Bool
+}
+
+// record 记录一次领导变更，返回是否处于频繁变更状态、窗口内担任过领导的节点以及状态是否发生变化
+func (l *leaderFlapping) record(prevLeader, leader uint64, now time.Time, threshold int, window time.Duration) (flapping bool, nodes []uint64, changed bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.changes = append(l.changes, leaderChange{prevLeader: prevLeader, leader: leader, at: now})
+	i := 0
+	for i < len(l.changes) && now.Sub(l.changes[i].at) > window {
+		i++
+	}
+	l.changes = append(l.changes[:0], l.changes[i:]...)
+
+	flapping = len(l.changes) >= threshold
+	changed = flapping != l.flapping.Load()
+	l.flapping.Store(flapping)
+	if !flapping {
+		return false, nil, changed
+	}
+	for _, change := range l.changes {
+		for _, nodeId := range []uint64{change.prevLeader, change.leader} {
+			if nodeId != 0 && !wkutil.ArrayContainsUint64(nodes, nodeId) {
+				nodes = append(nodes, nodeId)
+			}
+		}
+	}
+	return true, nodes, changed
+}
+
+// expire 最近一次领导变更已经超过窗口时间，退出频繁变更状态，返回状态是否发生变化
+func (l *leaderFlapping) expire(now time.Time, window time.Duration) bool {
+	if !l.flapping.Load() {
+		return false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.changes) > 0 && now.Sub(l.changes[len(l.changes)-1].at) <= window {
+		return false
+	}
+	l.changes = nil
+	l.flapping.Store(false)
+	return true
+}
+
+// reset 频道移除时清空，返回之前是否处于频繁变更状态
+func (l *leaderFlapping) reset() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.changes = nil
+	return l.flapping.Swap(false)
+}
+
+// onLeaderChange 频道领导发生变更，记录变更次数，进入频繁变更状态时报警
+func (c *channel) onLeaderChange(prevLeader, leader uint64) (bool, []uint64) {
+	clusterMetrics := trace.GlobalTrace.Metrics.Cluster()
+	clusterMetrics.ChannelLeaderChangeCountAdd(1)
+	if c.opts.LeaderFlappingThreshold <= 0 {
+		return false, nil
+	}
+	flapping, nodes, changed := c.leaderFlapping.record(prevLeader, leader, time.Now(), c.opts.LeaderFlappingThreshold, c.opts.LeaderFlappingWindow)
+	if changed && flapping {
+		clusterMetrics.ChannelLeaderFlappingCountAdd(1)
+		clusterMetrics.ChannelLeaderFlappingAdd(1)
+		c.Warn("channel leader is flapping", c.logFields(zap.Int("threshold", c.opts.LeaderFlappingThreshold), zap.Duration("window", c.opts.LeaderFlappingWindow), zap.Uint64s("nodes", nodes))...)
+		c.events.add(ChannelEventLeaderFlapping, fmt.Sprintf("nodes %v", nodes))
+	} else if changed {
+		clusterMetrics.ChannelLeaderFlappingAdd(-1)
+	}
+	return flapping, nodes
+}
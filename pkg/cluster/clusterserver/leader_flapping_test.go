@@ -0,0 +1,86 @@
+package cluster
+
+import (
+	"testing"
+	"time"
+
+	"github.com/WuKongIM/WuKongIM/pkg/cluster/replica"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLeaderFlappingRecord(t *testing.T) {
+	var l leaderFlapping
+	now := time.Now()
+	window := time.Minute
+
+	flapping, _, changed := l.record(1, 2, now, 3, window)
+	assert.False(t, flapping)
+	assert.False(t, changed)
+	_, _, _ = l.record(2, 1, now.Add(time.Second), 3, window)
+
+	// 窗口内第三次变更，进入频繁变更状态
+	flapping, nodes, changed := l.record(1, 3, now.Add(time.Second*2), 3, window)
+	assert.True(t, flapping)
+	assert.True(t, changed)
+	assert.ElementsMatch(t, []uint64{1, 2, 3}, nodes)
+
+	// 窗口外的变更不计数
+	flapping, _, changed = l.record(3, 1, now.Add(time.Minute+time.Second*10), 3, window)
+	assert.False(t, flapping)
+	assert.True(t, changed)
+
+	// 一直没有变更，超过窗口后退出频繁变更状态
+	_, _, _ = l.record(1, 2, now.Add(time.Minute+time.Second*11), 3, window)
+	flapping, _, _ = l.record(2, 1, now.Add(time.Minute+time.Second*12), 3, window)
+	assert.True(t, flapping)
+	assert.False(t, l.expire(now.Add(time.Minute*2), window))
+	assert.True(t, l.expire(now.Add(time.Minute*3), window))
+	assert.False(t, l.flapping.Load())
+
+	assert.False(t, l.reset())
+}
+
+// 频道领导在几个节点之间来回切换，领导变更事件带上Flapping标记
+func TestChannelLeaderFlapping(t *testing.T) {
+	setupTestTrace(t)
+
+	s := &Server{
+		opts:          NewOptions(WithNodeId(1), WithLeaderFlapping(4, time.Minute), WithElectionObserverCallback(func(event LeaderChangeEvent) {})),
+		leaderChangeC: make(chan LeaderChangeEvent, 10),
+	}
+	ch := newTestConfigChangeChannel(s)
+
+	cfgOf := func(leader uint64) replica.Config {
+		role := replica.RoleFollower
+		if leader == 1 {
+			role = replica.RoleLeader
+		}
+		return replica.Config{Leader: leader, Role: role, Replicas: []uint64{1, 2, 3}}
+	}
+	leaders := []uint64{2, 1, 3, 1, 2, 1}
+	for i := 1; i < len(leaders); i++ {
+		ch.onReplicaConfigChange(cfgOf(leaders[i-1]), cfgOf(leaders[i]))
+	}
+
+	// 本节点成为领导了3次：2->1、3->1、2->1，第4次变更后进入频繁变更状态
+	events := make([]LeaderChangeEvent, 0)
+	for len(s.leaderChangeC) > 0 {
+		events = append(events, <-s.leaderChangeC)
+	}
+	assert.Len(t, events, 3)
+	assert.False(t, events[0].Flapping)
+	assert.False(t, events[1].Flapping)
+	assert.True(t, events[2].Flapping)
+	assert.ElementsMatch(t, []uint64{1, 2, 3}, events[2].FlappingNodes)
+
+	var found bool
+	for _, event := range ch.events.list() {
+		if event.Type == ChannelEventLeaderFlapping {
+			found = true
+		}
+	}
+	assert.True(t, found)
+
+	// 频道移除后清空状态
+	assert.True(t, ch.leaderFlapping.reset())
+}
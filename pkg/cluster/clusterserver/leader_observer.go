@@ -0,0 +1,111 @@
+package cluster
+
+import (
+	"time"
+
+	"github.com/WuKongIM/WuKongIM/pkg/cluster/clusterconfig/pb"
+	"github.com/WuKongIM/WuKongIM/pkg/cluster/replica"
+	"go.uber.org/zap"
+)
+
+// LeaderChangeReason 领导变更的原因
+type LeaderChangeReason uint8
+
+const (
+	// LeaderChangeReasonUnknown 未知
+	LeaderChangeReasonUnknown LeaderChangeReason = iota
+	// LeaderChangeReasonElection 原领导离线或不存在，选举产生新领导
+	LeaderChangeReasonElection
+	// LeaderChangeReasonTransfer 领导转移（迁移或主动转移领导，目标副本追上日志后切换）
+	LeaderChangeReasonTransfer
+	// LeaderChangeReasonTimeout 原领导超时（离线）后选举产生新领导
+	LeaderChangeReasonTimeout
+	// LeaderChangeReasonAppoint 指定槽领导（TransferSlotLeader），在发起指定的节点上产生
+	LeaderChangeReasonAppoint
+	// LeaderChangeReasonManual 手动转移频道领导（TransferChannelLeader或迁移接口），在发起的节点上产生
+	LeaderChangeReasonManual
+)
+
+func (r LeaderChangeReason) String() string {
+	switch r {
+	case LeaderChangeReasonElection:
+		return "election"
+	case LeaderChangeReasonTransfer:
+		return "transfer"
+	case LeaderChangeReasonTimeout:
+		return "timeout"
+	case LeaderChangeReasonAppoint:
+		return "appoint"
+	case LeaderChangeReasonManual:
+		return "manual"
+	}
+	return "unknown"
+}
+
+// LeaderChangeEvent 领导变更事件
+// 选举和转移的结果在新领导节点上产生，指定和手动转移在发起的节点上产生（Leader为目标节点，转移完成后新领导节点还会产生一个转移事件）
+type LeaderChangeEvent struct {
+	NodeId      uint64             // 产生事件的节点
+	ShardType   ShardType          // 分区类型（槽或频道）
+	SlotId      uint32             // 槽id（ShardType为ShardTypeSlot时有效）
+	ChannelId   string             // 频道id（ShardType为ShardTypeChannel时有效）
+	ChannelType uint8              // 频道类型（ShardType为ShardTypeChannel时有效）
+	PrevLeader  uint64             // 之前的领导
+	Leader      uint64             // 新的领导
+	Term        uint32             // 新领导的任期（指定和手动转移为发起时的任期）
+	Reason      LeaderChangeReason // 变更原因
+	Time        time.Time          // 变更时间
+
+	// Flapping 频道领导在窗口时间内变更次数达到阈值（领导频繁变更），只对频道有效
+	Flapping bool
+	// FlappingNodes 领导频繁变更时，窗口内担任过领导的节点
+	FlappingNodes []uint64
+}
+
+// 通知领导变更，不阻塞调用方，队列满了则丢弃
+func (s *Server) notifyLeaderChange(event LeaderChangeEvent) {
+	if s.opts.OnLeaderChange == nil {
+		return
+	}
+	if event.NodeId == 0 {
+		event.NodeId = s.opts.NodeId
+	}
+	select {
+	case s.leaderChangeC <- event:
+	default:
+		s.Warn("leader change event queue is full, drop event", zap.Uint8("shardType", uint8(event.ShardType)), zap.Uint32("slotId", event.SlotId), zap.String("channelId", event.ChannelId), zap.Uint64("leader", event.Leader))
+	}
+}
+
+func (s *Server) leaderChangeLoop() {
+	for {
+		select {
+		case event := <-s.leaderChangeC:
+			s.opts.OnLeaderChange(event)
+		case <-s.stopper.ShouldStop():
+			return
+		}
+	}
+}
+
+// 槽领导变更的原因
+func slotLeaderChangeReason(oldSlot, newSlot *pb.Slot) LeaderChangeReason {
+	if oldSlot.MigrateTo != 0 && oldSlot.MigrateTo == newSlot.Leader {
+		return LeaderChangeReasonTransfer
+	}
+	if oldSlot.Status == pb.SlotStatus_SlotStatusCandidate { // 原领导离线后槽变为候选状态
+		return LeaderChangeReasonTimeout
+	}
+	return LeaderChangeReasonUnknown
+}
+
+// 频道领导变更的原因
+func channelLeaderChangeReason(oldCfg, newCfg replica.Config) LeaderChangeReason {
+	if oldCfg.MigrateTo != 0 && oldCfg.MigrateTo == newCfg.Leader {
+		return LeaderChangeReasonTransfer
+	}
+	if oldCfg.Leader != 0 { // 原领导离线后重新选举
+		return LeaderChangeReasonTimeout
+	}
+	return LeaderChangeReasonElection
+}
@@ -0,0 +1,88 @@
+package cluster
+
+import (
+	"testing"
+	"time"
+
+	"github.com/WuKongIM/WuKongIM/pkg/cluster/clusterconfig/pb"
+	"github.com/WuKongIM/WuKongIM/pkg/cluster/replica"
+	"github.com/WuKongIM/WuKongIM/pkg/wklog"
+	"github.com/lni/goutils/syncutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSlotLeaderChangeReason(t *testing.T) {
+	// 迁移领导
+	reason := slotLeaderChangeReason(&pb.Slot{Leader: 1, MigrateFrom: 1, MigrateTo: 2}, &pb.Slot{Leader: 2})
+	assert.Equal(t, LeaderChangeReasonTransfer, reason)
+
+	// 原领导离线后选举
+	reason = slotLeaderChangeReason(&pb.Slot{Leader: 1, Status: pb.SlotStatus_SlotStatusCandidate}, &pb.Slot{Leader: 2})
+	assert.Equal(t, LeaderChangeReasonTimeout, reason)
+
+	// 其他
+	reason = slotLeaderChangeReason(&pb.Slot{Leader: 0}, &pb.Slot{Leader: 2})
+	assert.Equal(t, LeaderChangeReasonUnknown, reason)
+}
+
+func TestChannelLeaderChangeReason(t *testing.T) {
+	reason := channelLeaderChangeReason(replica.Config{Leader: 1, MigrateFrom: 1, MigrateTo: 2}, replica.Config{Leader: 2})
+	assert.Equal(t, LeaderChangeReasonTransfer, reason)
+
+	reason = channelLeaderChangeReason(replica.Config{Leader: 1}, replica.Config{Leader: 2})
+	assert.Equal(t, LeaderChangeReasonTimeout, reason)
+
+	reason = channelLeaderChangeReason(replica.Config{}, replica.Config{Leader: 2})
+	assert.Equal(t, LeaderChangeReasonElection, reason)
+}
+
+func TestLeaderChangeReasonString(t *testing.T) {
+	assert.Equal(t, "election", LeaderChangeReasonElection.String())
+	assert.Equal(t, "transfer", LeaderChangeReasonTransfer.String())
+	assert.Equal(t, "timeout", LeaderChangeReasonTimeout.String())
+	assert.Equal(t, "appoint", LeaderChangeReasonAppoint.String())
+	assert.Equal(t, "manual", LeaderChangeReasonManual.String())
+	assert.Equal(t, "unknown", LeaderChangeReasonUnknown.String())
+}
+
+func TestNotifyLeaderChangeNotBlock(t *testing.T) {
+	block := make(chan struct{})
+	received := make(chan LeaderChangeEvent, 1)
+	s := &Server{
+		opts: NewOptions(WithElectionObserverCallback(func(event LeaderChangeEvent) {
+			select {
+			case received <- event:
+			default:
+			}
+			<-block
+		})),
+		leaderChangeC: make(chan LeaderChangeEvent, 1),
+		stopper:       syncutil.NewStopper(),
+		Log:           wklog.NewWKLog("test"),
+	}
+	s.stopper.RunWorker(s.leaderChangeLoop)
+	defer s.stopper.Stop()
+	defer close(block)
+
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 10; i++ {
+			s.notifyLeaderChange(LeaderChangeEvent{ShardType: ShardTypeSlot, SlotId: uint32(i), Leader: 1})
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("notifyLeaderChange blocked")
+	}
+
+	select {
+	case event := <-received:
+		assert.Equal(t, uint32(0), event.SlotId)
+		assert.Equal(t, ShardTypeSlot, event.ShardType)
+	case <-time.After(time.Second):
+		t.Fatal("callback not called")
+	}
+}
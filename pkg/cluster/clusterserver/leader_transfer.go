@@ -0,0 +1,130 @@
+package cluster
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/WuKongIM/WuKongIM/pkg/cluster/reactor"
+	"go.uber.org/zap"
+)
+
+// slotTransfer 本节点作为旧领导发起的计划中的槽领导转移
+type slotTransfer struct {
+	to             uint64    // 新领导
+	committedIndex uint64    // 开始转移时本节点的已提交下标，本地应用到这里后才用本地数据提供读取
+	deadline       time.Time // 宽限到期时间
+}
+
+// leaderTransfers 计划中的领导转移，转移期间旧领导继续提供读取，直到新领导确认或宽限到期
+type leaderTransfers struct {
+	mu    sync.Mutex
+	slots map[uint32]*slotTransfer
+	grace time.Duration
+}
+
+func newLeaderTransfers(grace time.Duration) *leaderTransfers {
+	return &leaderTransfers{
+		slots: make(map[uint32]*slotTransfer),
+		grace: grace,
+	}
+}
+
+func (l *leaderTransfers) begin(slotId uint32, to uint64, committedIndex uint64, now time.Time) {
+	if l.grace <= 0 {
+		return
+	}
+	l.mu.Lock()
+	l.slots[slotId] = &slotTransfer{to: to, committedIndex: committedIndex, deadline: now.Add(l.grace)}
+	l.mu.Unlock()
+}
+
+// get 获取槽正在进行的转移，宽限到期后自动结束
+func (l *leaderTransfers) get(slotId uint32, now time.Time) (slotTransfer, bool) {
+	if l.grace <= 0 {
+		return slotTransfer{}, false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	st := l.slots[slotId]
+	if st == nil {
+		return slotTransfer{}, false
+	}
+	if !now.Before(st.deadline) {
+		delete(l.slots, slotId)
+		return slotTransfer{}, false
+	}
+	return *st, true
+}
+
+// canServeRead 转移期间本节点能否用本地数据提供读取：本地要应用到开始转移时的已提交下标，否则可能读不到转移前已经提交的数据
+func (l *leaderTransfers) canServeRead(slotId uint32, appliedIndex uint64, now time.Time) bool {
+	st, ok := l.get(slotId, now)
+	return ok && appliedIndex >= st.committedIndex
+}
+
+// end 新领导已确认（或转移取消），结束转移
+func (l *leaderTransfers) end(slotId uint32) {
+	if l.grace <= 0 {
+		return
+	}
+	l.mu.Lock()
+	delete(l.slots, slotId)
+	l.mu.Unlock()
+}
+
+// 领导转移期间会短暂出现的错误
+func isTransferErr(err error) bool {
+	return isNotLeaderErr(err) || errors.Is(err, reactor.ErrPausePropopose) || (err != nil && err.Error() == reactor.ErrPausePropopose.Error())
+}
+
+// servingTransferRead 本节点是否是正在转移领导的旧领导并且本地已应用到开始转移时的已提交下标，是则可以继续用本地数据提供读取
+func (s *Server) servingTransferRead(slotId uint32) bool {
+	info, ok := s.slotManager.slotReactor.IndexInfo(SlotIdToKey(slotId))
+	if !ok {
+		return false
+	}
+	return s.leaderTransfers.canServeRead(slotId, info.AppliedIndex, time.Now())
+}
+
+// slotTransferring 槽是否正在转移领导（本节点是旧领导，或者槽配置里领导正在迁移）
+func (s *Server) slotTransferring(slotId uint32) bool {
+	if _, ok := s.leaderTransfers.get(slotId, time.Now()); ok {
+		return true
+	}
+	slot := s.clusterEventServer.Slot(slotId)
+	return slot != nil && slot.MigrateFrom != 0 && slot.MigrateFrom == slot.Leader && slot.MigrateTo != 0
+}
+
+// retryDuringSlotTransfer 执行fnc，开启了LeaderTransferGrace并且槽正在转移领导时，遇到不是领导的错误会短暂排队重试（最多LeaderTransferGrace），而不是直接失败
+func (s *Server) retryDuringSlotTransfer(ctx context.Context, slotId uint32, fnc func() error) error {
+	err := fnc()
+	if s.opts.LeaderTransferGrace <= 0 || !isTransferErr(err) || !s.slotTransferring(slotId) {
+		return err
+	}
+	deadline := time.Now().Add(s.opts.LeaderTransferGrace)
+	backoff := proposeRetryInitBackoff
+	retryCount := 0
+	for isTransferErr(err) && time.Now().Before(deadline) {
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			s.Warn("retry during slot transfer timeout", zap.Uint32("slotId", slotId), zap.Int("retryCount", retryCount), zap.Error(err))
+			return err
+		case <-s.stopper.ShouldStop():
+			timer.Stop()
+			return ErrStopped
+		}
+		retryCount++
+		err = fnc()
+
+		backoff *= 2
+		if backoff > s.opts.ProposeRetryMaxBackoff {
+			backoff = s.opts.ProposeRetryMaxBackoff
+		}
+	}
+	return err
+}
@@ -0,0 +1,118 @@
+package cluster
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/WuKongIM/WuKongIM/pkg/cluster/clusterconfig/pb"
+	"github.com/WuKongIM/WuKongIM/pkg/wklog"
+	"github.com/lni/goutils/syncutil"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/atomic"
+)
+
+func TestLeaderTransfersServeRead(t *testing.T) {
+	now := time.Now()
+	l := newLeaderTransfers(time.Second)
+	l.begin(1, 2, 100, now)
+	st, ok := l.get(1, now.Add(time.Millisecond*500))
+	assert.True(t, ok)
+	assert.Equal(t, uint64(2), st.to)
+	assert.Equal(t, uint64(100), st.committedIndex)
+
+	// 本地还没应用到开始转移时的已提交下标，不能用本地数据读取
+	assert.False(t, l.canServeRead(1, 99, now))
+	assert.True(t, l.canServeRead(1, 100, now))
+	assert.True(t, l.canServeRead(1, 120, now))
+
+	// 新领导确认
+	l.end(1)
+	assert.False(t, l.canServeRead(1, 100, now))
+	_, ok = l.get(1, now)
+	assert.False(t, ok)
+
+	// 宽限到期
+	l.begin(1, 2, 100, now)
+	_, ok = l.get(1, now.Add(time.Second))
+	assert.False(t, ok)
+
+	// 没有开启
+	l = newLeaderTransfers(0)
+	l.begin(1, 2, 100, now)
+	_, ok = l.get(1, now)
+	assert.False(t, ok)
+}
+
+// 测量领导转移期间提案的不可用时间（开启和不开启LeaderTransferGrace）
+func TestLeaderTransferAvailabilityGap(t *testing.T) {
+	takeover := time.Millisecond * 150 // 新领导接管需要的时间
+
+	measure := func(grace time.Duration) (failures int, gap time.Duration) {
+		s := &Server{
+			opts:            NewOptions(WithLeaderTransferGrace(grace), WithProposeRetryOnNotLeader(false, time.Millisecond*20)),
+			stopper:         syncutil.NewStopper(),
+			Log:             wklog.NewWKLog("test"),
+			leaderTransfers: newLeaderTransfers(grace),
+		}
+		defer s.stopper.Stop()
+
+		start := time.Now()
+		s.leaderTransfers.begin(1, 2, 100, start)
+		var ready atomic.Bool
+		time.AfterFunc(takeover, func() {
+			ready.Store(true)
+		})
+		propose := func() error {
+			if !ready.Load() {
+				return ErrNotLeader
+			}
+			return nil
+		}
+
+		var firstFail, lastFail time.Time
+		for time.Since(start) < takeover*2 {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			err := s.retryDuringSlotTransfer(ctx, 1, propose)
+			cancel()
+			if err != nil {
+				if firstFail.IsZero() {
+					firstFail = time.Now()
+				}
+				lastFail = time.Now()
+				failures++
+			}
+			time.Sleep(time.Millisecond * 5)
+		}
+		if failures > 0 {
+			gap = lastFail.Sub(firstFail)
+		}
+		return failures, gap
+	}
+
+	failures, gap := measure(0)
+	t.Logf("without grace: failures=%d gap=%s", failures, gap)
+	assert.Greater(t, failures, 0)
+	assert.Greater(t, gap, takeover/2)
+
+	failures, gap = measure(time.Second)
+	t.Logf("with grace: failures=%d gap=%s", failures, gap)
+	assert.Equal(t, 0, failures)
+	assert.Equal(t, time.Duration(0), gap)
+}
+
+// 停机前只转移本节点领导并且没有在迁移的槽，目标是第一个在线的其他副本
+func TestSlotHandoffTargets(t *testing.T) {
+	slots := []*pb.Slot{
+		{Id: 1, Leader: 1, Replicas: []uint64{1, 2, 3}},
+		{Id: 2, Leader: 1, Replicas: []uint64{1, 3}},
+		{Id: 3, Leader: 2, Replicas: []uint64{1, 2, 3}},
+		{Id: 4, Leader: 1, Replicas: []uint64{1, 2, 3}, MigrateFrom: 1, MigrateTo: 2},
+		{Id: 5, Leader: 1, Replicas: []uint64{1}},
+	}
+	online := func(nodeId uint64) bool {
+		return nodeId != 3
+	}
+	targets := slotHandoffTargets(slots, 1, online)
+	assert.Equal(t, map[uint32]uint64{1: 2, 2: 0, 5: 0}, targets)
+}
@@ -47,8 +47,43 @@ var (
 	ErrSlotLeaderNotFound           = errors.New("slot leader not found")
 	ErrEmptyRequest                 = errors.New("empty request")
 	ErrChannelClusterConfigNotFound = errors.New("channel cluster config not found")
+	ErrRebuildOnLeader              = errors.New("can not rebuild channel on leader")
+	ErrNotChannelReplica            = errors.New("current node is not channel replica")
+	ErrChannelCreateRateLimited     = errors.New("channel create rate limited")
+	ErrWriteRateLimited             = errors.New("write rate limited")
+	ErrTooBusy                      = errors.New("too many concurrent proposes")
+	ErrAppointConflict              = errors.New("appoint conflict, another leader appoint won in the same term")
+	ErrLogTermConflict              = errors.New("log term conflict with stored log")
+	ErrElectionBackoff              = errors.New("channel election stuck, backoff")
+	ErrInvalidReplicaCount          = errors.New("invalid replica count")
+	ErrNoReplicaCandidate           = errors.New("no online node can be added as replica")
+	ErrReplicaQuorumUnsafe          = errors.New("remove replica would break quorum")
+	ErrReplicaCountChanging         = errors.New("replica count change is in progress")
+	ErrChannelConfigChanging        = errors.New("channel config change is not applied")
+	ErrDiskFull                     = errors.New("disk is nearly full, node is read-only")
+	ErrDiskFreeBytesUnsupported     = errors.New("disk free bytes is not supported on this platform")
+	ErrChannelMigrating             = errors.New("channel migrate is in progress")
+	ErrElectionPaused               = errors.New("election is paused for maintenance")
+	ErrElectionPauseDisabled        = errors.New("election pause is disabled, ElectionPauseMaxDuration is 0")
+	ErrCompactNotApplied            = errors.New("compact index is greater than applied index")
+	ErrTransferTargetNotReplica     = errors.New("transfer target is not channel replica")
+	ErrLogIndexOutOfRange           = errors.New("log index out of range")
+	ErrChannelDestroying            = errors.New("channel is being destroyed")
 )
 
+// NotLeaderError 本节点不是领导，LeaderId为本节点知道的当前领导（0表示没有领导），errors.Is(err, ErrNotLeader)为true
+type NotLeaderError struct {
+	LeaderId uint64
+}
+
+func (e *NotLeaderError) Error() string {
+	return fmt.Sprintf("not leader, leader is %d", e.LeaderId)
+}
+
+func (e *NotLeaderError) Is(target error) bool {
+	return target == ErrNotLeader
+}
+
 const (
 	MsgUnknown          = iota
 	MsgSlotMsg          // 槽消息
@@ -347,12 +382,134 @@ func (c *ChannelClusterConfigReq) Unmarshal(data []byte) error {
 	return nil
 }
 
+type BlobReq struct {
+	HandleKey string // 频道key
+	Index     uint64 // 日志下标（blob被清理时，从此下标的日志重建）
+	Hash      []byte // blob的sha256
+}
+
+func (b *BlobReq) Marshal() ([]byte, error) {
+	enc := wkproto.NewEncoder()
+	defer enc.End()
+	enc.WriteString(b.HandleKey)
+	enc.WriteUint64(b.Index)
+	enc.WriteBinary(b.Hash)
+	return enc.Bytes(), nil
+}
+
+func (b *BlobReq) Unmarshal(data []byte) error {
+	dec := wkproto.NewDecoder(data)
+	var err error
+	if b.HandleKey, err = dec.String(); err != nil {
+		return err
+	}
+	if b.Index, err = dec.Uint64(); err != nil {
+		return err
+	}
+	if b.Hash, err = dec.BinaryAll(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// ChannelLeaderStepDownReq 频道领导请求槽领导把频道领导转移给其他副本
+type ChannelLeaderStepDownReq struct {
+	ChannelId   string // 频道id
+	ChannelType uint8  // 频道类型
+	LeaderId    uint64 // 请求让出领导的节点
+	To          uint64 // 指定的新领导，0表示由槽领导选择一个在线的跟随者
+}
+
+func (c *ChannelLeaderStepDownReq) Marshal() ([]byte, error) {
+	enc := wkproto.NewEncoder()
+	defer enc.End()
+	enc.WriteString(c.ChannelId)
+	enc.WriteUint8(c.ChannelType)
+	enc.WriteUint64(c.LeaderId)
+	enc.WriteUint64(c.To)
+	return enc.Bytes(), nil
+}
+
+func (c *ChannelLeaderStepDownReq) Unmarshal(data []byte) error {
+	dec := wkproto.NewDecoder(data)
+	var err error
+	if c.ChannelId, err = dec.String(); err != nil {
+		return err
+	}
+	if c.ChannelType, err = dec.Uint8(); err != nil {
+		return err
+	}
+	if c.LeaderId, err = dec.Uint64(); err != nil {
+		return err
+	}
+	if dec.Len() > 0 { // 兼容旧版本没有To的请求
+		if c.To, err = dec.Uint64(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ChannelSnapshotReq 副本向频道领导请求快照（快照按段拉取，每次请求从StartIndex开始的一段）
+type ChannelSnapshotReq struct {
+	ChannelId   string // 频道id
+	ChannelType uint8  // 频道类型
+	StartIndex  uint64 // 这一段快照的第一条日志下标
+}
+
+func (c *ChannelSnapshotReq) Marshal() ([]byte, error) {
+	enc := wkproto.NewEncoder()
+	defer enc.End()
+	enc.WriteString(c.ChannelId)
+	enc.WriteUint8(c.ChannelType)
+	enc.WriteUint64(c.StartIndex)
+	return enc.Bytes(), nil
+}
+
+func (c *ChannelSnapshotReq) Unmarshal(data []byte) error {
+	dec := wkproto.NewDecoder(data)
+	var err error
+	if c.ChannelId, err = dec.String(); err != nil {
+		return err
+	}
+	if c.ChannelType, err = dec.Uint8(); err != nil {
+		return err
+	}
+	if c.StartIndex, err = dec.Uint64(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// ElectionPauseReq 通知节点暂停或恢复选举（网络维护）
+type ElectionPauseReq struct {
+	Duration time.Duration // 暂停多久（由各节点按自己的时钟计算结束时间，并限制在ElectionPauseMaxDuration内），0表示恢复选举
+}
+
+func (e *ElectionPauseReq) Marshal() ([]byte, error) {
+	enc := wkproto.NewEncoder()
+	defer enc.End()
+	enc.WriteInt64(int64(e.Duration))
+	return enc.Bytes(), nil
+}
+
+func (e *ElectionPauseReq) Unmarshal(data []byte) error {
+	dec := wkproto.NewDecoder(data)
+	duration, err := dec.Int64()
+	if err != nil {
+		return err
+	}
+	e.Duration = time.Duration(duration)
+	return nil
+}
+
 type ChannelProposeReq struct {
 	ChannelId   string        // 频道id
 	ChannelType uint8         // 频道类型
 	Logs        []replica.Log // 数据
 	TraceID     trace.TraceID
 	SpanID      trace.SpanID
+	Priority    uint8 // 提案优先级（reactor.ProposePriority）
 }
 
 func (c *ChannelProposeReq) Marshal() ([]byte, error) {
@@ -370,6 +527,7 @@ func (c *ChannelProposeReq) Marshal() ([]byte, error) {
 	}
 	enc.WriteBytes(c.TraceID[:])
 	enc.WriteBytes(c.SpanID[:])
+	enc.WriteUint8(c.Priority)
 	return enc.Bytes(), nil
 }
 
@@ -411,6 +569,11 @@ func (c *ChannelProposeReq) Unmarshal(data []byte) error {
 		return err
 	}
 	copy(c.SpanID[:], spanIDBytes)
+	if dec.Len() > 0 { // 兼容旧版本没有Priority的请求
+		if c.Priority, err = dec.Uint8(); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
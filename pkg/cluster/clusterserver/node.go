@@ -44,7 +44,7 @@ func newNode(id uint64, uid string, addr string, opts *Options) *node {
 		maxMessageBatchSize: opts.MaxMessageBatchSize,
 		Log:                 wklog.NewWKLog(fmt.Sprintf("nodeClient[%d]", id)),
 		sendQueue: sendQueue{
-			ch: make(chan *proto.Message, opts.SendQueueLength),
+			ch: make(chan sendQueueItem, opts.SendQueueLength),
 			rl: NewRateLimiter(opts.MaxSendQueueSize),
 		},
 	}
@@ -80,13 +80,13 @@ func (n *node) send(msg *proto.Message) error {
 		n.Error("sendQueue is rateLimited")
 		return errRateLimited
 	}
-	n.sendQueue.increase(msg)
+	item := n.sendQueue.increase(msg)
 
 	select {
-	case n.sendQueue.ch <- msg:
+	case n.sendQueue.ch <- item:
 		return nil
 	default:
-		n.sendQueue.decrease(msg)
+		n.sendQueue.decrease(item)
 		n.Error("sendQueue is full", zap.Int("length", len(n.sendQueue.ch)))
 		return errChanIsFull
 	}
@@ -99,23 +99,23 @@ func (n *node) processMessages() {
 	var err error
 	for {
 		select {
-		case msg := <-n.sendQueue.ch:
+		case item := <-n.sendQueue.ch:
 
+			n.sendQueue.decrease(item)
 			if n.client.ConnectStatus() != client.CONNECTED {
 				continue
 			}
 
-			n.sendQueue.decrease(msg)
-			size += uint64(msg.Size())
-			msgs = append(msgs, msg)
+			size += uint64(item.msg.Size())
+			msgs = append(msgs, item.msg)
 
 			// 取出所有消息并取出的消息总大小不超过maxMessageBatchSize
 			for done := false; !done && size < n.maxMessageBatchSize; {
 				select {
-				case msg = <-n.sendQueue.ch:
-					n.sendQueue.decrease(msg)
-					size += uint64(msg.Size())
-					msgs = append(msgs, msg)
+				case item = <-n.sendQueue.ch:
+					n.sendQueue.decrease(item)
+					size += uint64(item.msg.Size())
+					msgs = append(msgs, item.msg)
 				case <-n.stopper.ShouldStop():
 					return
 				default:
@@ -124,11 +124,13 @@ func (n *node) processMessages() {
 			}
 			trace.GlobalTrace.Metrics.System().IntranetOutgoingAdd(int64(size))
 
+			n.sendQueue.inflightBytes.Store(int64(size))
 			if err = n.sendBatch(msgs); err != nil {
 				if n.client.ConnectStatus() == client.CONNECTED { // 只有连接状态下才打印错误日志
 					n.Error("sendBatch is failed", zap.Error(err))
 				}
 			}
+			n.sendQueue.inflightBytes.Store(0)
 			size = 0
 			msgs = msgs[:0]
 			time.Sleep(time.Millisecond * 2)
@@ -192,10 +194,14 @@ func (n *node) requestChannelProposeMessage(ctx context.Context, req *ChannelPro
 	if err != nil {
 		return nil, err
 	}
+	trace.GlobalTrace.Metrics.Cluster().ForwardProposeCountAdd(1)
+	trace.GlobalTrace.Metrics.Cluster().ForwardProposeBytesAdd(int64(len(data)))
 	resp, err := n.client.RequestWithContext(ctx, "/channel/proposeMessage", data)
 	if err != nil {
 		return nil, err
 	}
+	trace.GlobalTrace.Metrics.Cluster().ForwardProposeRespCountAdd(1)
+	trace.GlobalTrace.Metrics.Cluster().ForwardProposeRespBytesAdd(int64(len(resp.Body)))
 	if resp.Status != proto.Status_OK {
 		if len(resp.Body) > 0 {
 			return nil, errors.New(string(resp.Body))
@@ -210,6 +216,96 @@ func (n *node) requestChannelProposeMessage(ctx context.Context, req *ChannelPro
 	return proposeMessageResp, nil
 }
 
+func (n *node) requestBlob(ctx context.Context, req *BlobReq) ([]byte, error) {
+	data, err := req.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	resp, err := n.client.RequestWithContext(ctx, "/channel/blob", data)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Status != proto.Status_OK {
+		if len(resp.Body) > 0 {
+			return nil, errors.New(string(resp.Body))
+		}
+		return nil, fmt.Errorf("requestBlob is failed, status:%d", resp.Status)
+	}
+	return resp.Body, nil
+}
+
+func (n *node) requestChannelSnapshot(ctx context.Context, req *ChannelSnapshotReq) ([]byte, error) {
+	data, err := req.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	resp, err := n.client.RequestWithContext(ctx, "/channel/snapshot", data)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Status != proto.Status_OK {
+		if len(resp.Body) > 0 {
+			return nil, errors.New(string(resp.Body))
+		}
+		return nil, fmt.Errorf("requestChannelSnapshot is failed, status:%d", resp.Status)
+	}
+	return resp.Body, nil
+}
+
+func (n *node) requestChannelInstallSnapshot(ctx context.Context, req *ChannelSnapshotReq) error {
+	data, err := req.Marshal()
+	if err != nil {
+		return err
+	}
+	resp, err := n.client.RequestWithContext(ctx, "/channel/installSnapshot", data)
+	if err != nil {
+		return err
+	}
+	if resp.Status != proto.Status_OK {
+		if len(resp.Body) > 0 {
+			return errors.New(string(resp.Body))
+		}
+		return fmt.Errorf("requestChannelInstallSnapshot is failed, status:%d", resp.Status)
+	}
+	return nil
+}
+
+func (n *node) requestChannelLeaderStepDown(ctx context.Context, req *ChannelLeaderStepDownReq) error {
+	data, err := req.Marshal()
+	if err != nil {
+		return err
+	}
+	resp, err := n.client.RequestWithContext(ctx, "/channel/leaderStepDown", data)
+	if err != nil {
+		return err
+	}
+	if resp.Status != proto.Status_OK {
+		if len(resp.Body) > 0 {
+			return errors.New(string(resp.Body))
+		}
+		return fmt.Errorf("requestChannelLeaderStepDown is failed, status:%d", resp.Status)
+	}
+	return nil
+}
+
+func (n *node) requestElectionPause(ctx context.Context, req *ElectionPauseReq) error {
+	data, err := req.Marshal()
+	if err != nil {
+		return err
+	}
+	resp, err := n.client.RequestWithContext(ctx, "/cluster/electionPause", data)
+	if err != nil {
+		return err
+	}
+	if resp.Status != proto.Status_OK {
+		if len(resp.Body) > 0 {
+			return errors.New(string(resp.Body))
+		}
+		return fmt.Errorf("requestElectionPause is failed, status:%d", resp.Status)
+	}
+	return nil
+}
+
 func (n *node) requestSlotLogInfo(ctx context.Context, req *SlotLogInfoReq) (*SlotLogInfoResp, error) {
 	data, err := req.Marshal()
 	if err != nil {
@@ -268,24 +364,54 @@ func (n *node) requestClusterJoin(ctx context.Context, req *ClusterJoinReq) (*Cl
 	return clusterJoinResp, err
 }
 
+type sendQueueItem struct {
+	msg        *proto.Message
+	size       int
+	enqueuedAt int64 // 入队时间（纳秒）
+}
+
 type sendQueue struct {
-	ch    chan *proto.Message
+	ch    chan sendQueueItem
 	rl    *RateLimiter
 	count atomic.Int64
+
+	inflightBytes atomic.Int64 // 已从队列取出，正在发送的消息大小
+	// 队列中最早的消息的入队时间（纳秒），0表示队列为空
+	// 出队后剩下的消息一定不早于刚出队的消息，所以用刚出队的消息的入队时间近似，等待时间只会偏大不会偏小
+	oldestEnqueuedAt atomic.Int64
 }
 
 func (sq *sendQueue) rateLimited() bool {
 	return sq.rl.RateLimited()
 }
 
-func (sq *sendQueue) increase(msg *proto.Message) {
-	size := msg.Size()
-	sq.rl.Increase(uint64(size))
+func (sq *sendQueue) increase(msg *proto.Message) sendQueueItem {
+	item := sendQueueItem{msg: msg, size: msg.Size(), enqueuedAt: time.Now().UnixNano()}
+	sq.rl.Increase(uint64(item.size))
 	sq.count.Inc()
+	sq.oldestEnqueuedAt.CompareAndSwap(0, item.enqueuedAt)
+	return item
+}
+
+func (sq *sendQueue) decrease(item sendQueueItem) {
+	if sq.count.Dec() <= 0 {
+		sq.oldestEnqueuedAt.Store(0)
+	} else {
+		sq.oldestEnqueuedAt.Store(item.enqueuedAt)
+	}
+	sq.rl.Decrease(uint64(item.size))
 }
 
-func (sq *sendQueue) decrease(msg *proto.Message) {
-	size := msg.Size()
-	sq.count.Dec()
-	sq.rl.Decrease(uint64(size))
+// stat 发送队列的状态
+func (sq *sendQueue) stat(nodeId uint64) trace.PeerSendQueueStat {
+	st := trace.PeerSendQueueStat{
+		NodeId:        nodeId,
+		Depth:         sq.count.Load(),
+		QueuedBytes:   int64(sq.rl.Get()),
+		InflightBytes: sq.inflightBytes.Load(),
+	}
+	if enqueuedAt := sq.oldestEnqueuedAt.Load(); enqueuedAt > 0 && st.Depth > 0 {
+		st.OldestAge = time.Duration(time.Now().UnixNano() - enqueuedAt)
+	}
+	return st
 }
@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"sync"
+
+	"github.com/WuKongIM/WuKongIM/pkg/trace"
 )
 
 type nodeManager struct {
@@ -47,6 +49,17 @@ func (n *nodeManager) nodes() []*node {
 	return nodes
 }
 
+// sendQueueStats 每个节点的发送队列状态
+func (n *nodeManager) sendQueueStats() []trace.PeerSendQueueStat {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	stats := make([]trace.PeerSendQueueStat, 0, len(n.nodeMap))
+	for _, node := range n.nodeMap {
+		stats = append(stats, node.sendQueue.stat(node.id))
+	}
+	return stats
+}
+
 func (n *nodeManager) stop() {
 	n.mu.Lock()
 	defer n.mu.Unlock()
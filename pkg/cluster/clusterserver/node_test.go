@@ -0,0 +1,46 @@
+package cluster
+
+import (
+	"testing"
+	"time"
+
+	"github.com/WuKongIM/WuKongIM/pkg/wkserver/proto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSendQueueStat(t *testing.T) {
+	sq := sendQueue{
+		ch: make(chan sendQueueItem, 10),
+		rl: NewRateLimiter(0),
+	}
+	st := sq.stat(2)
+	assert.Equal(t, uint64(2), st.NodeId)
+	assert.Equal(t, int64(0), st.Depth)
+	assert.Equal(t, time.Duration(0), st.OldestAge)
+
+	msg := &proto.Message{MsgType: 1, Content: []byte("hello")}
+	first := sq.increase(msg)
+	sq.ch <- first
+	time.Sleep(time.Millisecond * 20)
+	second := sq.increase(msg)
+	sq.ch <- second
+
+	st = sq.stat(2)
+	assert.Equal(t, int64(2), st.Depth)
+	assert.Equal(t, int64(msg.Size()*2), st.QueuedBytes)
+	assert.GreaterOrEqual(t, st.OldestAge, time.Millisecond*20)
+
+	// 出队后剩下的消息的等待时间不早于刚出队的消息
+	sq.decrease(<-sq.ch)
+	st = sq.stat(2)
+	assert.Equal(t, int64(1), st.Depth)
+	assert.Equal(t, int64(msg.Size()), st.QueuedBytes)
+	assert.GreaterOrEqual(t, st.OldestAge, time.Millisecond*20)
+
+	// 队列清空
+	sq.decrease(<-sq.ch)
+	st = sq.stat(2)
+	assert.Equal(t, int64(0), st.Depth)
+	assert.Equal(t, int64(0), st.QueuedBytes)
+	assert.Equal(t, time.Duration(0), st.OldestAge)
+}
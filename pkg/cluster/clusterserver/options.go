@@ -42,6 +42,8 @@ type Options struct {
 	MaxSendQueueSize uint64
 	// MaxMessageBatchSize 节点之间每次发送消息的最大大小（单位字节）
 	MaxMessageBatchSize uint64
+	// MaxMessageSize 槽和频道单条副本消息的最大大小（单位字节），收到超过的消息在进入队列前丢弃，发送的同步响应超过时拆分（剩下的日志下次同步），单条日志就超过的提案被拒绝，0表示不限制
+	MaxMessageSize uint64
 	// ReceiveQueueLength 副本接收队列的长度。
 	ReceiveQueueLength uint64
 	// LazyFreeCycle defines how often should entry queue and message queue
@@ -59,6 +61,8 @@ type Options struct {
 	// MessageLogStorage 消息日志存储
 	MessageLogStorage IShardLogStorage
 	OnSlotApply       func(slotId uint32, logs []replica.Log) error
+	// OnChannelApply 频道的日志提交后应用（按频道类型的应用顺序模式，宽松模式下同一个频道的多段日志会并行调用），nil表示频道日志不需要应用
+	OnChannelApply func(channelId string, channelType uint8, logs []replica.Log) error
 	// Send 发送消息
 	Send func(shardType ShardType, m reactor.Message)
 	// ChannelElectionPoolSize 频道选举协程池大小(意味着同时在选举的频道数量)
@@ -78,6 +82,126 @@ type Options struct {
 	// LearnerMinLogGap  学习者最小日志差距（ 当日志差距小于这个值时，可以认为已学习达到要求）
 	LearnerMinLogGap uint64
 
+	// LargeLogThreshold 频道日志数据超过这个大小（字节）时，数据存到本地blob，日志里只复制引用，0表示不开启
+	LargeLogThreshold uint64
+	// BlobRetention blob未被使用的保留时间，超过会被清理（blob可以从日志存储重建）
+	BlobRetention time.Duration
+
+	// ChannelCreateRate 节点每秒最多创建多少个频道（令牌桶），0表示不限制
+	ChannelCreateRate int
+	// ChannelCreateBurst 频道创建允许的突发数量，0表示和ChannelCreateRate一致
+	ChannelCreateBurst int
+	// ChannelCreateMaxWait 超过频道创建速率时，请求最多排队等待的时间，0表示直接拒绝
+	ChannelCreateMaxWait time.Duration
+
+	// MaxWriteBytesPerSecond 节点每秒最多提案写入的字节数（所有频道和槽共享），超过时提案会等待，等待超过ProposeTimeout则拒绝，0表示不限制
+	MaxWriteBytesPerSecond int
+
+	// MaxConcurrentProposes 节点最多同时进行中的提案数量（所有频道和槽共享），限制突发流量时的协程数量和CPU占用，0表示不限制
+	MaxConcurrentProposes int
+	// ProposeConcurrencyMode 超过MaxConcurrentProposes时的处理方式，默认直接拒绝（ErrTooBusy）
+	ProposeConcurrencyMode ProposeConcurrencyMode
+
+	// InboundMessageRate 每个节点连接每秒最多接收多少条槽和频道的副本消息（令牌桶），避免一个异常的节点发送大量消息压垮消息队列，0表示不限制
+	// 超过限速时可以丢弃的消息（心跳、同步请求等，对方会重发）直接丢弃，关键消息（选举、同步响应等）不丢弃，但连接会被限流一段时间（期间丢弃它的所有可丢弃消息）
+	InboundMessageRate int
+	// InboundMessageBurst 每个节点连接允许的突发消息数量，0表示和InboundMessageRate一致
+	InboundMessageBurst int
+
+	// MaxApplyLag 频道已提交未应用的日志数量超过这个值时，领导暂停新的提案直到应用追上，0表示不限制
+	MaxApplyLag uint64
+
+	// ProposeRetryOnNotLeader 频道提案遇到不是领导（例如正在选举）时，是否按指数退避重试直到ctx超时，默认关闭直接返回错误
+	ProposeRetryOnNotLeader bool
+	// ProposeRetryMaxBackoff 提案重试的最大退避间隔
+	ProposeRetryMaxBackoff time.Duration
+
+	// ForwardTimeout 转发提案（本节点不是频道领导，把提案转发给领导）的超时时间，0表示ProposeTimeout+2秒
+	// 转发比本地提案多一次往返网络，所以要比本地提案超时（ProposeTimeout）长一些，避免领导还在等待提交时转发方就先超时了
+	// 转发同时受调用方ctx的限制，调用方ctx先到期则转发也随之取消
+	ForwardTimeout time.Duration
+
+	// LeaderTransferGrace 计划的槽领导转移（TransferSlotLeader）的宽限时间，0表示不开启
+	// 开启后转移期间旧领导知道自己是在转移而不是故障，在新领导确认（或宽限时间到期）前继续用本地已提交的数据提供读取
+	// 转移期间遇到不是领导的提案会短暂排队重试（最多宽限时间），而不是直接失败
+	LeaderTransferGrace time.Duration
+
+	// DisableProposeOnUnappliedConfig 频道配置变更（例如副本变化）还没有提交并生效时，暂停频道的提案直到配置生效（或提案超时），
+	// 避免用户的写入按旧的副本集合确认，和成员变更交错
+	DisableProposeOnUnappliedConfig bool
+
+	// SnapshotLogThreshold 频道距离上次快照已应用的日志数量达到这个值时做一次快照（写到DataDir/snapshots下），0表示不按日志数量触发
+	SnapshotLogThreshold uint64
+
+	// ProposeAckTraceSampleRate 频道提案副本确认跟踪的采样率（0-1），0表示不开启
+	ProposeAckTraceSampleRate float64
+
+	// ElectionStuckThreshold 频道连续选举失败（一直没有领导）超过这个时间认为选举卡住了，之后按指数退避限制选举频率，并在管理接口中标记为election-stuck，0表示不检测
+	ElectionStuckThreshold time.Duration
+	// ElectionStuckMaxBackoff 选举卡住后的最大退避间隔
+	ElectionStuckMaxBackoff time.Duration
+
+	// ElectionPauseMaxDuration 网络维护时暂停选举的最长时间，超过后自动恢复选举（避免忘记恢复导致永远不能故障转移）
+	ElectionPauseMaxDuration time.Duration
+
+	// ShutdownFlushTimeout 停止时等待已追加的日志存储完成的最长时间（避免已提交但还没存储的日志在重启后丢失），0表示不等待
+	ShutdownFlushTimeout time.Duration
+	// ShutdownHandoffTimeout 停止时把本节点领导的槽转移给其他在线副本的最长等待时间（避免停机后槽要等选举才能恢复），0表示不转移
+	ShutdownHandoffTimeout time.Duration
+
+	// LeaderFlappingThreshold 频道领导在LeaderFlappingWindow内变更次数达到这个值认为领导在频繁变更（flapping），
+	// 会上报指标并在领导变更回调里带上Flapping标记和涉及的节点，0表示不检测
+	LeaderFlappingThreshold int
+	// LeaderFlappingWindow 领导频繁变更的统计窗口
+	LeaderFlappingWindow time.Duration
+
+	// IdleHeartbeatTick 频道领导超过这个tick数没有提案认为频道空闲，空闲频道的心跳间隔放大IdleHeartbeatMultiple倍，
+	// 减少大量空闲频道的后台心跳流量，0表示不调整
+	IdleHeartbeatTick int
+	// IdleHeartbeatMultiple 空闲频道心跳间隔的倍数
+	IdleHeartbeatMultiple int
+
+	// DiskMinFreeBytes 数据目录所在磁盘的剩余空间低于这个值时节点进入只读（拒绝频道提案，继续提供读取和日志同步），
+	// 并把本节点领导的频道转移给其他副本，0表示不按剩余空间检查（追加日志遇到磁盘已满时仍会进入只读）
+	DiskMinFreeBytes uint64
+	// DiskCheckInterval 检查磁盘剩余空间的间隔，剩余空间恢复后退出只读
+	DiskCheckInterval time.Duration
+
+	// IdleSweepPaused 启动时暂停空闲频道的回收（批量导入等运维操作期间使用），可以通过管理接口恢复
+	IdleSweepPaused bool
+	// ChannelInactiveTimeout 频道超过这个时间没有提案和追加日志则被回收（有未提交日志的领导频道除外），0表示不按时间回收
+	ChannelInactiveTimeout time.Duration
+
+	// ApplyOrderingModes 频道类型对应的日志应用顺序模式，没有配置的频道类型严格按顺序应用
+	// 消息之间相互独立的频道类型可以配置为宽松模式（reactor.ApplyOrderingRelaxed），分段并行应用提高吞吐
+	ApplyOrderingModes map[uint8]reactor.ApplyOrderingMode
+
+	// ChannelProfiles 频道类型对应的提案配置（提案超时、不是领导时重试、并发提案数、应用顺序），频道创建时按类型选择，
+	// 配置了的频道类型以这里为准（覆盖ProposeTimeout、ProposeRetryOnNotLeader、ApplyOrderingModes），内置ChannelProfileControl、ChannelProfileBulk
+	ChannelProfiles map[uint8]ChannelProfile
+
+	// InlineApplyMaxLogs 频道一次要应用的日志数量不超过这个值时直接在reactor里同步应用（低流量频道省去交给应用协程池的开销），
+	// 超过的（繁忙频道）仍然异步应用，0表示不开启
+	InlineApplyMaxLogs uint64
+	// InlineApplyChannelTypes 允许同步应用的频道类型，为空表示所有频道类型
+	InlineApplyChannelTypes []uint8
+
+	// MaxHandleReadyCountOfBatch 频道reactor每个循环最多处理几轮ready，超过后先处理排队的提案和消息再继续
+	// 调大可以提高繁忙时的吞吐，调小可以降低提案和消息的等待延迟，不大于0时使用默认值
+	MaxHandleReadyCountOfBatch int
+
+	// ProposeResultChannelBuffer 槽和频道最多同时排队通知的提案结果数量，满了时由应用协程直接通知，
+	// 等待结果的一方处理再慢也不会阻塞日志的应用，不大于0表示不限制
+	ProposeResultChannelBuffer int
+
+	// LogCacheMaxBytes 每个频道在内存里缓存最近存储的日志的最大字节数，追随者短暂断开重连后同步时直接从内存返回，不用再读存储，0表示不缓存
+	LogCacheMaxBytes uint64
+
+	// ProposeAuditPath 提案审计文件路径，不为空时将每条追加的日志（分区key、下标、任期、数据的sha256等）异步写到此文件，默认关闭
+	ProposeAuditPath string
+	// ProposeAuditQueueSize 提案审计的异步队列大小，队列满了会丢弃审计记录
+	ProposeAuditQueueSize int
+
 	DB wkdb.DB
 
 	SlotDbShardNum int // 槽位数据库分片数量
@@ -87,6 +211,7 @@ type Options struct {
 	TickInterval          time.Duration // 分布式tick间隔
 	HeartbeatIntervalTick int           // 心跳间隔tick
 	ElectionIntervalTick  int           // 选举间隔tick
+	ElectionGraceTick     int           // 跟随者与领导失联超过选举超时后，再等待领导恢复的tick次数（避免领导短暂停顿引起不必要的选举），0表示不等待
 
 	ChannelReactorSubCount int // 频道reactor sub的数量
 	SlotReactorSubCount    int // 槽reactor sub的数量
@@ -94,6 +219,22 @@ type Options struct {
 	PongMaxTick int // 节点超过多少tick没有回应心跳就认为是掉线
 
 	Auth auth.AuthConfig
+
+	// InitialLeaderHint 新频道的初始领导提示，requester为请求创建频道配置的节点（收到创建请求的节点），返回0表示没有提示
+	// 只在频道分布式配置第一次创建时（没有任何日志）生效，提示的节点不是允许投票的在线节点时回退为默认的槽领导
+	InitialLeaderHint func(channelId string, channelType uint8, requester uint64) uint64
+
+	// ReplicaSetPolicy 新频道选择副本节点的策略
+	ReplicaSetPolicy ReplicaSetPolicy
+	// NodeDomains 节点所在的故障域（机架、可用区等），key为节点id，没有配置的节点自成一个故障域，所有节点的配置需要一致
+	NodeDomains map[uint64]string
+
+	// OnLeaderChange 本节点成为槽或频道的领导时回调（用于审计等），在独立的协程里异步调用，不会阻塞分布式处理
+	OnLeaderChange func(event LeaderChangeEvent)
+
+	// ProposeHook 频道提案追加前对每条日志的数据调用（例如校验大小、加上服务端时间戳），返回错误则拒绝整批提案，返回的数据替换原数据
+	// 只在收到提案的节点上调用一次（转发给频道领导的提案不会再调用），不持有任何锁，nil表示不调用
+	ProposeHook func(channelId string, channelType uint8, data []byte) ([]byte, error)
 }
 
 func NewOptions(opt ...Option) *Options {
@@ -103,8 +244,22 @@ func NewOptions(opt ...Option) *Options {
 		DataDir:                    "clusterdata",
 		ReqTimeout:                 10 * time.Second,
 		ProposeTimeout:             10 * time.Second,
+		MaxHandleReadyCountOfBatch: defaultMaxHandleReadyCountOfBatch,
+		ProposeResultChannelBuffer: 10000,
+		ProposeRetryMaxBackoff:     time.Millisecond * 500,
+		ElectionStuckThreshold:     time.Second * 30,
+		ElectionStuckMaxBackoff:    time.Second * 30,
+		ElectionPauseMaxDuration:   time.Minute * 30,
+		ShutdownFlushTimeout:       time.Second * 5,
+		ShutdownHandoffTimeout:     time.Second * 10,
+		LeaderFlappingThreshold:    5,
+		LeaderFlappingWindow:       time.Minute,
+		IdleHeartbeatMultiple:      4,
+		DiskMinFreeBytes:           1024 * 1024 * 1024, // 1G
+		DiskCheckInterval:          time.Second * 10,
 		SendQueueLength:            1024 * 10,
 		MaxMessageBatchSize:        64 * 1024 * 1024, // 64M
+		MaxMessageSize:             32 * 1024 * 1024, // 32M
 		ReceiveQueueLength:         1024,
 		LazyFreeCycle:              1,
 		InitialTaskQueueCap:        24,
@@ -116,6 +271,11 @@ func NewOptions(opt ...Option) *Options {
 		ChannelLoadPoolSize:        1000,
 		LeaderTransferMinLogGap:    20,
 		LearnerMinLogGap:           100,
+		LargeLogThreshold:          0,
+		BlobRetention:              10 * time.Minute,
+		ChannelCreateRate:          0,
+		ChannelCreateMaxWait:       time.Second,
+		ProposeAuditQueueSize:      1024 * 10,
 		PageSize:                   20,
 
 		TickInterval:          150 * time.Millisecond,
@@ -164,6 +324,13 @@ func WithOnSlotApply(fn func(slotId uint32, logs []replica.Log) error) Option {
 	}
 }
 
+// WithOnChannelApply 设置频道日志的应用函数
+func WithOnChannelApply(fn func(channelId string, channelType uint8, logs []replica.Log) error) Option {
+	return func(o *Options) {
+		o.OnChannelApply = fn
+	}
+}
+
 func WithLogSyncLimitSizeOfEach(size int) Option {
 	return func(o *Options) {
 		o.LogSyncLimitSizeOfEach = size
@@ -182,6 +349,13 @@ func WithMaxMessageBatchSize(size uint64) Option {
 	}
 }
 
+// WithMaxMessageSize 设置槽和频道单条副本消息的最大大小
+func WithMaxMessageSize(size uint64) Option {
+	return func(o *Options) {
+		o.MaxMessageSize = size
+	}
+}
+
 func WithReceiveQueueLength(length uint64) Option {
 	return func(o *Options) {
 		o.ReceiveQueueLength = length
@@ -336,6 +510,12 @@ func WithHeartbeatIntervalTick(interval int) Option {
 	}
 }
 
+func WithElectionGraceTick(tick int) Option {
+	return func(o *Options) {
+		o.ElectionGraceTick = tick
+	}
+}
+
 func WithElectionIntervalTick(interval int) Option {
 	return func(o *Options) {
 		o.ElectionIntervalTick = interval
@@ -378,6 +558,301 @@ func WithLearnerMinLogGap(gap uint64) Option {
 	}
 }
 
+func WithLargeLogThreshold(threshold uint64) Option {
+	return func(o *Options) {
+		o.LargeLogThreshold = threshold
+	}
+}
+
+func WithBlobRetention(retention time.Duration) Option {
+	return func(o *Options) {
+		o.BlobRetention = retention
+	}
+}
+
+// WithChannelCreateRate 设置频道创建的速率限制，rate为每秒创建数量，burst为允许的突发数量，maxWait为超过速率时最多排队等待的时间
+func WithChannelCreateRate(rate int, burst int, maxWait time.Duration) Option {
+	return func(o *Options) {
+		o.ChannelCreateRate = rate
+		o.ChannelCreateBurst = burst
+		o.ChannelCreateMaxWait = maxWait
+	}
+}
+
+// WithMaxWriteBytesPerSecond 设置节点每秒最多提案写入的字节数
+func WithMaxWriteBytesPerSecond(n int) Option {
+	return func(o *Options) {
+		o.MaxWriteBytesPerSecond = n
+	}
+}
+
+// WithMaxConcurrentProposes 设置节点最多同时进行中的提案数量
+func WithMaxConcurrentProposes(n int) Option {
+	return func(o *Options) {
+		o.MaxConcurrentProposes = n
+	}
+}
+
+// WithProposeConcurrencyMode 设置超过最大并发提案数量时的处理方式
+func WithProposeConcurrencyMode(mode ProposeConcurrencyMode) Option {
+	return func(o *Options) {
+		o.ProposeConcurrencyMode = mode
+	}
+}
+
+// WithInboundMessageRate 设置每个节点连接接收副本消息的速率限制，rate为每秒消息数量，burst为允许的突发数量
+func WithInboundMessageRate(rate int, burst int) Option {
+	return func(o *Options) {
+		o.InboundMessageRate = rate
+		o.InboundMessageBurst = burst
+	}
+}
+
+// WithMaxApplyLag 设置频道最大的应用落后日志数量
+func WithMaxApplyLag(lag uint64) Option {
+	return func(o *Options) {
+		o.MaxApplyLag = lag
+	}
+}
+
+// WithForwardTimeout 设置转发提案给领导的超时时间，一般比ProposeTimeout长一些
+func WithForwardTimeout(timeout time.Duration) Option {
+	return func(o *Options) {
+		o.ForwardTimeout = timeout
+	}
+}
+
+// WithLeaderTransferGrace 设置计划的槽领导转移期间继续提供读取和提案排队的宽限时间
+func WithLeaderTransferGrace(grace time.Duration) Option {
+	return func(o *Options) {
+		o.LeaderTransferGrace = grace
+	}
+}
+
+// WithDisableProposeOnUnappliedConfig 设置频道配置变更未生效时是否暂停提案
+func WithDisableProposeOnUnappliedConfig(on bool) Option {
+	return func(o *Options) {
+		o.DisableProposeOnUnappliedConfig = on
+	}
+}
+
+// WithSnapshotLogThreshold 设置按日志数量触发频道快照的阈值
+func WithSnapshotLogThreshold(n uint64) Option {
+	return func(o *Options) {
+		o.SnapshotLogThreshold = n
+	}
+}
+
+// WithProposeRetryOnNotLeader 设置频道提案遇到不是领导时是否重试，maxBackoff为最大退避间隔，0表示使用默认值
+func WithProposeRetryOnNotLeader(on bool, maxBackoff time.Duration) Option {
+	return func(o *Options) {
+		o.ProposeRetryOnNotLeader = on
+		if maxBackoff > 0 {
+			o.ProposeRetryMaxBackoff = maxBackoff
+		}
+	}
+}
+
+// WithElectionStuck 设置频道选举卡住的检测阈值和最大退避间隔，threshold为0表示不检测
+func WithElectionStuck(threshold, maxBackoff time.Duration) Option {
+	return func(o *Options) {
+		o.ElectionStuckThreshold = threshold
+		if maxBackoff > 0 {
+			o.ElectionStuckMaxBackoff = maxBackoff
+		}
+	}
+}
+
+// WithElectionPauseMaxDuration 设置网络维护时暂停选举的最长时间
+func WithElectionPauseMaxDuration(d time.Duration) Option {
+	return func(o *Options) {
+		o.ElectionPauseMaxDuration = d
+	}
+}
+
+// WithShutdownFlushTimeout 设置停止时等待已追加的日志存储完成的最长时间，0表示不等待
+func WithShutdownFlushTimeout(timeout time.Duration) Option {
+	return func(o *Options) {
+		o.ShutdownFlushTimeout = timeout
+	}
+}
+
+// WithShutdownHandoffTimeout 设置停止时转移本节点槽领导的最长等待时间，0表示不转移
+func WithShutdownHandoffTimeout(timeout time.Duration) Option {
+	return func(o *Options) {
+		o.ShutdownHandoffTimeout = timeout
+	}
+}
+
+// WithLeaderFlapping 设置频道领导频繁变更的检测阈值和统计窗口，threshold为0表示不检测
+func WithLeaderFlapping(threshold int, window time.Duration) Option {
+	return func(o *Options) {
+		o.LeaderFlappingThreshold = threshold
+		if window > 0 {
+			o.LeaderFlappingWindow = window
+		}
+	}
+}
+
+// WithIdleHeartbeat 设置空闲频道的判定tick数和心跳间隔倍数，idleTick为0表示不调整
+func WithIdleHeartbeat(idleTick int, multiple int) Option {
+	return func(o *Options) {
+		o.IdleHeartbeatTick = idleTick
+		if multiple > 0 {
+			o.IdleHeartbeatMultiple = multiple
+		}
+	}
+}
+
+// WithDiskGuard 设置进入只读的磁盘最小剩余空间和检查间隔，minFreeBytes为0表示不按剩余空间检查
+func WithDiskGuard(minFreeBytes uint64, checkInterval time.Duration) Option {
+	return func(o *Options) {
+		o.DiskMinFreeBytes = minFreeBytes
+		if checkInterval > 0 {
+			o.DiskCheckInterval = checkInterval
+		}
+	}
+}
+
+// WithIdleSweepPaused 设置启动时是否暂停空闲频道的回收
+func WithIdleSweepPaused(paused bool) Option {
+	return func(o *Options) {
+		o.IdleSweepPaused = paused
+	}
+}
+
+// WithChannelInactiveTimeout 设置频道不活跃多久后被回收，0表示不按时间回收
+func WithChannelInactiveTimeout(timeout time.Duration) Option {
+	return func(o *Options) {
+		o.ChannelInactiveTimeout = timeout
+	}
+}
+
+// WithApplyOrderingMode 设置频道类型的日志应用顺序模式
+func WithApplyOrderingMode(channelType uint8, mode reactor.ApplyOrderingMode) Option {
+	return func(o *Options) {
+		if o.ApplyOrderingModes == nil {
+			o.ApplyOrderingModes = make(map[uint8]reactor.ApplyOrderingMode)
+		}
+		o.ApplyOrderingModes[channelType] = mode
+	}
+}
+
+// WithRelaxedApplyChannelType 设置宽松顺序应用日志的频道类型
+func WithRelaxedApplyChannelType(channelTypes ...uint8) Option {
+	return func(o *Options) {
+		for _, channelType := range channelTypes {
+			WithApplyOrderingMode(channelType, reactor.ApplyOrderingRelaxed)(o)
+		}
+	}
+}
+
+// WithChannelProfile 设置频道类型的提案配置
+func WithChannelProfile(channelType uint8, profile ChannelProfile) Option {
+	return func(o *Options) {
+		if o.ChannelProfiles == nil {
+			o.ChannelProfiles = make(map[uint8]ChannelProfile)
+		}
+		o.ChannelProfiles[channelType] = profile
+	}
+}
+
+// WithMaxHandleReadyCountOfBatch 设置频道reactor每个循环最多处理几轮ready
+func WithMaxHandleReadyCountOfBatch(n int) Option {
+	return func(o *Options) {
+		o.MaxHandleReadyCountOfBatch = n
+	}
+}
+
+// WithLogCacheMaxBytes 设置每个频道缓存最近存储的日志的最大字节数
+func WithLogCacheMaxBytes(maxBytes uint64) Option {
+	return func(o *Options) {
+		o.LogCacheMaxBytes = maxBytes
+	}
+}
+
+// WithInlineApplyMaxLogs 设置频道同步应用的日志数量阈值
+func WithInlineApplyMaxLogs(n uint64) Option {
+	return func(o *Options) {
+		o.InlineApplyMaxLogs = n
+	}
+}
+
+// WithInlineApplyChannelType 添加允许同步应用的频道类型
+func WithInlineApplyChannelType(channelTypes ...uint8) Option {
+	return func(o *Options) {
+		o.InlineApplyChannelTypes = append(o.InlineApplyChannelTypes, channelTypes...)
+	}
+}
+
+// WithChannelProfiles 批量设置频道类型的提案配置
+func WithChannelProfiles(profiles map[uint8]ChannelProfile) Option {
+	return func(o *Options) {
+		for channelType, profile := range profiles {
+			if o.ChannelProfiles == nil {
+				o.ChannelProfiles = make(map[uint8]ChannelProfile)
+			}
+			o.ChannelProfiles[channelType] = profile
+		}
+	}
+}
+
+// WithProposeResultChannelBuffer 设置最多同时排队通知的提案结果数量
+func WithProposeResultChannelBuffer(size int) Option {
+	return func(o *Options) {
+		o.ProposeResultChannelBuffer = size
+	}
+}
+
+// WithProposeAckTraceSampleRate 设置频道提案副本确认跟踪的采样率，被采样的提案会在提案span上记录每个副本确认的顺序和耗时
+func WithProposeAckTraceSampleRate(rate float64) Option {
+	return func(o *Options) {
+		o.ProposeAckTraceSampleRate = rate
+	}
+}
+
+// WithProposeAudit 开启提案审计，path为审计文件路径
+func WithProposeAudit(path string) Option {
+	return func(o *Options) {
+		o.ProposeAuditPath = path
+	}
+}
+
+// WithReplicaSetPolicy 设置新频道选择副本节点的策略
+func WithReplicaSetPolicy(policy ReplicaSetPolicy) Option {
+	return func(o *Options) {
+		o.ReplicaSetPolicy = policy
+	}
+}
+
+// WithNodeDomains 设置节点所在的故障域，配合ReplicaSetPolicySpreadDomains把副本分散到不同的故障域
+func WithNodeDomains(nodeDomains map[uint64]string) Option {
+	return func(o *Options) {
+		o.NodeDomains = nodeDomains
+	}
+}
+
+// WithInitialLeaderHint 设置新频道的初始领导提示，让新频道直接以提示的节点为领导，不需要再选举
+func WithInitialLeaderHint(f func(channelId string, channelType uint8, requester uint64) uint64) Option {
+	return func(o *Options) {
+		o.InitialLeaderHint = f
+	}
+}
+
+// WithProposeHook 设置频道提案的数据钩子，用于统一校验或转换提案数据
+func WithProposeHook(f func(channelId string, channelType uint8, data []byte) ([]byte, error)) Option {
+	return func(o *Options) {
+		o.ProposeHook = f
+	}
+}
+
+// WithElectionObserverCallback 设置领导变更的回调
+func WithElectionObserverCallback(f func(event LeaderChangeEvent)) Option {
+	return func(o *Options) {
+		o.OnLeaderChange = f
+	}
+}
+
 func WithPongMaxTick(tick int) Option {
 	return func(o *Options) {
 		o.PongMaxTick = tick
@@ -395,3 +870,22 @@ func WithAuth(auth auth.AuthConfig) Option {
 		o.Auth = auth
 	}
 }
+
+// 频道reactor每个循环默认最多处理ready的轮数
+const defaultMaxHandleReadyCountOfBatch = 50
+
+// 频道reactor每个循环最多处理ready的轮数，不大于0时使用默认值
+func (o *Options) maxHandleReadyCountOfBatch() int {
+	if o.MaxHandleReadyCountOfBatch <= 0 {
+		return defaultMaxHandleReadyCountOfBatch
+	}
+	return o.MaxHandleReadyCountOfBatch
+}
+
+// 转发提案的超时时间，proposeTimeout为领导本地提案的超时时间
+func (o *Options) forwardTimeout(proposeTimeout time.Duration) time.Duration {
+	if o.ForwardTimeout > 0 {
+		return o.ForwardTimeout
+	}
+	return proposeTimeout + 2*time.Second
+}
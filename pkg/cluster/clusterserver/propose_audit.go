@@ -0,0 +1,144 @@
+package cluster
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/WuKongIM/WuKongIM/pkg/cluster/reactor"
+	"github.com/WuKongIM/WuKongIM/pkg/wklog"
+	"go.uber.org/atomic"
+	"go.uber.org/zap"
+)
+
+// 提案审计，将每条追加的日志（分区key、下标、任期、日志id、数据的sha256、时间）以文本行的方式追加到独立的文件里，
+// 每行格式：时间\t分区类型\t分区key\t下标\t任期\t日志id\tsha256
+// 用于排查已提交但状态不对的问题（离线grep即可还原什么时候提案了什么），默认关闭。
+// 写入是异步的，队列满了会丢弃记录，不会阻塞提案和追加。
+
+type proposeAuditRecord struct {
+	kind      string // slot or channel
+	handleKey string
+	index     uint64
+	term      uint32
+	id        uint64
+	hash      [sha256.Size]byte
+	time      time.Time
+}
+
+type proposeAuditor struct {
+	path    string
+	recordC chan proposeAuditRecord
+	dropped atomic.Uint64
+	file    *os.File
+	w       *bufio.Writer
+	wklog.Log
+}
+
+func newProposeAuditor(path string, queueSize int) *proposeAuditor {
+	return &proposeAuditor{
+		path:    path,
+		recordC: make(chan proposeAuditRecord, queueSize),
+		Log:     wklog.NewWKLog("proposeAuditor"),
+	}
+}
+
+func (p *proposeAuditor) open() error {
+	if err := os.MkdirAll(filepath.Dir(p.path), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(p.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	p.file = f
+	p.w = bufio.NewWriterSize(f, 64*1024)
+	return nil
+}
+
+// record 记录追加的日志，不阻塞
+func (p *proposeAuditor) record(kind string, reqs []reactor.AppendLogReq) {
+	now := time.Now()
+	for _, req := range reqs {
+		for _, lg := range req.Logs {
+			rec := proposeAuditRecord{
+				kind:      kind,
+				handleKey: req.HandleKey,
+				index:     lg.Index,
+				term:      lg.Term,
+				id:        lg.Id,
+				hash:      sha256.Sum256(lg.Data),
+				time:      now,
+			}
+			select {
+			case p.recordC <- rec:
+			default:
+				if p.dropped.Inc()%1000 == 1 {
+					p.Warn("propose audit queue is full, drop record", zap.Uint64("dropped", p.dropped.Load()))
+				}
+			}
+		}
+	}
+}
+
+func (p *proposeAuditor) loop(stopC chan struct{}) {
+	tk := time.NewTicker(time.Second)
+	defer tk.Stop()
+	buf := make([]byte, 0, 256)
+	for {
+		select {
+		case rec := <-p.recordC:
+			buf = p.write(buf, rec)
+		case <-tk.C:
+			p.flush()
+		case <-stopC:
+			for {
+				select {
+				case rec := <-p.recordC:
+					buf = p.write(buf, rec)
+				default:
+					p.flush()
+					if err := p.file.Close(); err != nil {
+						p.Warn("close propose audit file failed", zap.Error(err))
+					}
+					return
+				}
+			}
+		}
+	}
+}
+
+func (p *proposeAuditor) write(buf []byte, rec proposeAuditRecord) []byte {
+	buf = formatProposeAuditRecord(buf[:0], rec)
+	if _, err := p.w.Write(buf); err != nil {
+		p.Warn("write propose audit failed", zap.Error(err))
+	}
+	return buf
+}
+
+func (p *proposeAuditor) flush() {
+	if err := p.w.Flush(); err != nil {
+		p.Warn("flush propose audit failed", zap.Error(err))
+	}
+}
+
+func formatProposeAuditRecord(buf []byte, rec proposeAuditRecord) []byte {
+	buf = rec.time.UTC().AppendFormat(buf, time.RFC3339Nano)
+	buf = append(buf, '\t')
+	buf = append(buf, rec.kind...)
+	buf = append(buf, '\t')
+	buf = append(buf, rec.handleKey...)
+	buf = append(buf, '\t')
+	buf = strconv.AppendUint(buf, rec.index, 10)
+	buf = append(buf, '\t')
+	buf = strconv.AppendUint(buf, uint64(rec.term), 10)
+	buf = append(buf, '\t')
+	buf = strconv.AppendUint(buf, rec.id, 10)
+	buf = append(buf, '\t')
+	buf = hex.AppendEncode(buf, rec.hash[:])
+	return append(buf, '\n')
+}
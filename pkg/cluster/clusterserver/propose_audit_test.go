@@ -0,0 +1,74 @@
+package cluster
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/WuKongIM/WuKongIM/pkg/cluster/reactor"
+	"github.com/WuKongIM/WuKongIM/pkg/cluster/replica"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProposeAuditor(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit", "propose.log")
+	a := newProposeAuditor(path, 100)
+	err := a.open()
+	assert.NoError(t, err)
+
+	stopC := make(chan struct{})
+	doneC := make(chan struct{})
+	go func() {
+		a.loop(stopC)
+		close(doneC)
+	}()
+
+	a.record("channel", []reactor.AppendLogReq{
+		{
+			HandleKey: "test&1",
+			Logs: []replica.Log{
+				{Id: 101, Index: 1, Term: 2, Data: []byte("hello")},
+				{Id: 102, Index: 2, Term: 2, Data: []byte("world")},
+			},
+		},
+	})
+	close(stopC)
+	<-doneC
+
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	assert.Len(t, lines, 2)
+
+	fields := strings.Split(lines[0], "\t")
+	assert.Len(t, fields, 7)
+	_, err = time.Parse(time.RFC3339Nano, fields[0])
+	assert.NoError(t, err)
+	sum := sha256.Sum256([]byte("hello"))
+	assert.Equal(t, []string{"channel", "test&1", "1", "2", "101", hex.EncodeToString(sum[:])}, fields[1:])
+}
+
+func TestProposeAuditorNotBlock(t *testing.T) {
+	a := newProposeAuditor(filepath.Join(t.TempDir(), "propose.log"), 1)
+
+	logs := make([]replica.Log, 0, 10)
+	for i := uint64(1); i <= 10; i++ {
+		logs = append(logs, replica.Log{Id: i, Index: i, Term: 1})
+	}
+	done := make(chan struct{})
+	go func() {
+		// 没有消费者，队列满了也不能阻塞
+		a.record("slot", []reactor.AppendLogReq{{HandleKey: "1", Logs: logs}})
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("record blocked")
+	}
+	assert.Equal(t, uint64(9), a.dropped.Load())
+}
@@ -0,0 +1,24 @@
+package cluster
+
+import (
+	"github.com/WuKongIM/WuKongIM/pkg/cluster/replica"
+	"go.uber.org/zap"
+)
+
+// applyProposeHook 对提案的每条日志调用ProposeHook，返回替换数据后的日志（不修改调用方的logs）
+func (s *Server) applyProposeHook(channelId string, channelType uint8, logs []replica.Log) ([]replica.Log, error) {
+	if s.opts.ProposeHook == nil {
+		return logs, nil
+	}
+	newLogs := make([]replica.Log, len(logs))
+	for i, lg := range logs {
+		data, err := s.opts.ProposeHook(channelId, channelType, lg.Data)
+		if err != nil {
+			s.Debug("propose rejected by hook", channelLogFields(channelId, channelType, zap.Uint64("logId", lg.Id), zap.Error(err))...)
+			return nil, err
+		}
+		lg.Data = data
+		newLogs[i] = lg
+	}
+	return newLogs, nil
+}
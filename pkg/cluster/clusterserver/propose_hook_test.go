@@ -0,0 +1,50 @@
+package cluster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/WuKongIM/WuKongIM/pkg/cluster/replica"
+	"github.com/WuKongIM/WuKongIM/pkg/wklog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProposeHook(t *testing.T) {
+	errTooLarge := errors.New("content too large")
+	s := &Server{
+		opts: NewOptions(WithProposeHook(func(channelId string, channelType uint8, data []byte) ([]byte, error) {
+			if len(data) > 5 {
+				return nil, errTooLarge
+			}
+			return []byte(fmt.Sprintf("%s:%d:%s", channelId, channelType, data)), nil
+		})),
+		Log: wklog.NewWKLog("test"),
+	}
+
+	// 拒绝提案，不会再加载频道和提案
+	_, err := s.ProposeChannelMessages(context.Background(), "test", 2, []replica.Log{
+		{Id: 1, Data: []byte("hello")},
+		{Id: 2, Data: []byte("hello world")},
+	})
+	assert.ErrorIs(t, err, errTooLarge)
+
+	// 转换提案数据，不修改调用方的日志
+	logs := []replica.Log{
+		{Id: 1, Data: []byte("hello")},
+		{Id: 2, Data: []byte("world")},
+	}
+	newLogs, err := s.applyProposeHook("test", 2, logs)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("test:2:hello"), newLogs[0].Data)
+	assert.Equal(t, []byte("test:2:world"), newLogs[1].Data)
+	assert.Equal(t, uint64(2), newLogs[1].Id)
+	assert.Equal(t, []byte("hello"), logs[0].Data)
+
+	// 没有设置钩子
+	s.opts.ProposeHook = nil
+	newLogs, err = s.applyProposeHook("test", 2, logs)
+	assert.NoError(t, err)
+	assert.Equal(t, logs, newLogs)
+}
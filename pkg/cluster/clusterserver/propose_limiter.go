@@ -0,0 +1,80 @@
+package cluster
+
+import (
+	"context"
+	"time"
+
+	"github.com/WuKongIM/WuKongIM/pkg/trace"
+)
+
+// ProposeConcurrencyMode 超过节点最大并发提案数量时的处理方式
+type ProposeConcurrencyMode int
+
+const (
+	// ProposeConcurrencyReject 直接拒绝，返回ErrTooBusy（默认）
+	ProposeConcurrencyReject ProposeConcurrencyMode = iota
+	// ProposeConcurrencyBlock 等待其他提案完成，等待超过maxWait返回ErrTooBusy
+	ProposeConcurrencyBlock
+)
+
+func (m ProposeConcurrencyMode) String() string {
+	switch m {
+	case ProposeConcurrencyReject:
+		return "reject"
+	case ProposeConcurrencyBlock:
+		return "block"
+	}
+	return "unknown"
+}
+
+// proposeLimiter 节点级别的并发提案限制（信号量），所有频道和槽的提案共享，极端突发流量时限制同时等待提交的协程数量
+type proposeLimiter struct {
+	sem  chan struct{}
+	mode ProposeConcurrencyMode
+}
+
+func newProposeLimiter(max int, mode ProposeConcurrencyMode) *proposeLimiter {
+	return &proposeLimiter{
+		sem:  make(chan struct{}, max),
+		mode: mode,
+	}
+}
+
+// acquire 获取一个提案名额，成功后必须调用release归还
+// 拒绝模式下没有名额直接返回ErrTooBusy，等待模式下最多等待maxWait
+func (l *proposeLimiter) acquire(ctx context.Context, maxWait time.Duration) error {
+	select {
+	case l.sem <- struct{}{}:
+		trace.GlobalTrace.Metrics.Cluster().ProposeConcurrencyAdd(1)
+		return nil
+	default:
+	}
+	if l.mode != ProposeConcurrencyBlock || maxWait <= 0 {
+		trace.GlobalTrace.Metrics.Cluster().ProposeTooBusyCountAdd(1)
+		return ErrTooBusy
+	}
+
+	tm := time.NewTimer(maxWait)
+	defer tm.Stop()
+	select {
+	case l.sem <- struct{}{}:
+		trace.GlobalTrace.Metrics.Cluster().ProposeConcurrencyAdd(1)
+		return nil
+	case <-tm.C:
+		trace.GlobalTrace.Metrics.Cluster().ProposeTooBusyCountAdd(1)
+		return ErrTooBusy
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// release 归还提案名额
+func (l *proposeLimiter) release() {
+	<-l.sem
+	trace.GlobalTrace.Metrics.Cluster().ProposeConcurrencyAdd(-1)
+}
+
+// inflight 当前进行中的提案数量
+func (l *proposeLimiter) inflight() int {
+	return len(l.sem)
+}
@@ -0,0 +1,127 @@
+package cluster
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/WuKongIM/WuKongIM/pkg/cluster/reactor"
+	"github.com/WuKongIM/WuKongIM/pkg/cluster/replica"
+	"github.com/WuKongIM/WuKongIM/pkg/wklog"
+	"github.com/WuKongIM/WuKongIM/pkg/wkutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProposeLimiter(t *testing.T) {
+	setupTestTrace(t)
+
+	// 拒绝模式，超过上限直接拒绝
+	l := newProposeLimiter(2, ProposeConcurrencyReject)
+	assert.NoError(t, l.acquire(context.Background(), time.Second))
+	assert.NoError(t, l.acquire(context.Background(), time.Second))
+	assert.Equal(t, ErrTooBusy, l.acquire(context.Background(), time.Second))
+	l.release()
+	assert.Equal(t, 1, l.inflight())
+	assert.NoError(t, l.acquire(context.Background(), time.Second))
+
+	// 等待模式，其他提案完成后获取到名额
+	l = newProposeLimiter(1, ProposeConcurrencyBlock)
+	assert.NoError(t, l.acquire(context.Background(), time.Second))
+	go func() {
+		time.Sleep(time.Millisecond * 20)
+		l.release()
+	}()
+	assert.NoError(t, l.acquire(context.Background(), time.Second))
+
+	// 等待超时
+	assert.Equal(t, ErrTooBusy, l.acquire(context.Background(), time.Millisecond*20))
+
+	// 等待时ctx取消
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*20)
+	defer cancel()
+	assert.ErrorIs(t, l.acquire(ctx, time.Second), context.DeadlineExceeded)
+	assert.Equal(t, 1, l.inflight())
+	l.release()
+	assert.Equal(t, 0, l.inflight())
+}
+
+// 提案完成（包括超时）后归还名额
+func TestProposeLimiterReleaseOnDone(t *testing.T) {
+	setupTestTrace(t)
+
+	s := &Server{
+		opts: NewOptions(WithNodeId(1), WithMaxConcurrentProposes(1)),
+		Log:  wklog.NewWKLog("test"),
+	}
+	s.proposeLimiter = newProposeLimiter(s.opts.MaxConcurrentProposes, s.opts.ProposeConcurrencyMode)
+	channelReactor := reactor.New(reactor.NewOptions(reactor.WithNodeId(1), reactor.WithReactorType(reactor.ReactorTypeChannel)))
+	assert.NoError(t, channelReactor.Start())
+	defer channelReactor.Stop()
+	cm := &channelManager{
+		channelReactor: channelReactor,
+		opts:           s.opts,
+		s:              s,
+		Log:            wklog.NewWKLog("test"),
+	}
+	s.channelManager = cm
+
+	logs := []replica.Log{{Id: 1, Data: []byte("hello")}}
+
+	// 名额被占用时拒绝
+	assert.NoError(t, s.proposeLimiter.acquire(context.Background(), 0))
+	_, err := cm.proposeAndWait(context.Background(), "test", 2, logs)
+	assert.Equal(t, ErrTooBusy, err)
+	s.proposeLimiter.release()
+
+	// 提案完成后归还名额（频道不在本节点，直接返回）
+	_, err = cm.proposeAndWait(context.Background(), "test", 2, logs)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, s.proposeLimiter.inflight())
+
+	// 提案超时后归还名额（领导一直不提交）
+	channelReactor.AddHandler(wkutil.ChannelToKey("test", 2), &testNoCommitHandler{})
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*20)
+	defer cancel()
+	_, err = cm.proposeAndWait(ctx, "test", 2, logs)
+	assert.Error(t, err)
+	assert.Equal(t, 0, s.proposeLimiter.inflight())
+}
+
+// 接收提案但一直不提交的领导
+type testNoCommitHandler struct {
+	reactor.IHandler
+}
+
+func (t *testNoCommitHandler) AppliedIndex() (uint64, error) {
+	return 0, nil
+}
+
+func (t *testNoCommitHandler) Tick() {
+}
+
+func (t *testNoCommitHandler) LeaderId() uint64 {
+	return 1
+}
+
+func (t *testNoCommitHandler) PausePropopose() bool {
+	return false
+}
+
+func (t *testNoCommitHandler) SpeedLevel() replica.SpeedLevel {
+	return replica.LevelFast
+}
+
+func (t *testNoCommitHandler) SetSpeedLevel(level replica.SpeedLevel) {
+}
+
+func (t *testNoCommitHandler) LastLogIndexAndTerm() (uint64, uint32) {
+	return 0, 1
+}
+
+func (t *testNoCommitHandler) HasReady() bool {
+	return false
+}
+
+func (t *testNoCommitHandler) Step(m replica.Message) error {
+	return nil
+}
@@ -0,0 +1,68 @@
+package cluster
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/WuKongIM/WuKongIM/pkg/cluster/reactor"
+	"github.com/WuKongIM/WuKongIM/pkg/trace"
+	"go.uber.org/zap"
+)
+
+// 提案重试的初始退避间隔
+const proposeRetryInitBackoff = time.Millisecond * 10
+
+// 不是领导的错误（本节点或远程节点返回的）
+var notLeaderErrs = []error{
+	ErrNotLeader,
+	ErrNotIsLeader,
+	ErrOldChannelClusterConfig,
+	reactor.ErrNotLeader,
+}
+
+func isNotLeaderErr(err error) bool {
+	if err == nil {
+		return false
+	}
+	for _, e := range notLeaderErrs {
+		// 远程节点返回的错误只有错误信息
+		if errors.Is(err, e) || err.Error() == e.Error() {
+			return true
+		}
+	}
+	return false
+}
+
+// retryOnNotLeader 执行fnc，如果开启了重试（on）并且fnc返回不是领导的错误（领导选举中），则按指数退避重试，直到成功或ctx结束
+func (s *Server) retryOnNotLeader(ctx context.Context, on bool, fnc func() error) error {
+	err := fnc()
+	if !on {
+		return err
+	}
+	backoff := proposeRetryInitBackoff
+	retryCount := 0
+	for isNotLeaderErr(err) {
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			s.Warn("propose retry on not leader timeout", zap.Int("retryCount", retryCount), zap.Error(err))
+			return err
+		case <-s.stopper.ShouldStop():
+			timer.Stop()
+			return ErrStopped
+		}
+		retryCount++
+		trace.GlobalTrace.Metrics.Cluster().ProposeNotLeaderRetryCountAdd(1)
+
+		err = fnc()
+
+		backoff *= 2
+		if backoff > s.opts.ProposeRetryMaxBackoff {
+			backoff = s.opts.ProposeRetryMaxBackoff
+		}
+	}
+	return err
+}
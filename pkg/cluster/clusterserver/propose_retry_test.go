@@ -0,0 +1,76 @@
+package cluster
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/WuKongIM/WuKongIM/pkg/wklog"
+	"github.com/lni/goutils/syncutil"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/atomic"
+)
+
+func TestIsNotLeaderErr(t *testing.T) {
+	assert.True(t, isNotLeaderErr(ErrNotLeader))
+	assert.True(t, isNotLeaderErr(errors.New(ErrOldChannelClusterConfig.Error()))) // 远程节点返回的错误
+	assert.False(t, isNotLeaderErr(ErrStopped))
+	assert.False(t, isNotLeaderErr(nil))
+}
+
+// 提案过程中发生选举，选举完成后重试成功
+func TestRetryOnNotLeaderDuringElection(t *testing.T) {
+	setupTestTrace(t)
+
+	s := &Server{
+		opts:    NewOptions(WithProposeRetryOnNotLeader(true, time.Millisecond*20)),
+		stopper: syncutil.NewStopper(),
+		Log:     wklog.NewWKLog("test"),
+	}
+	defer s.stopper.Stop()
+
+	var elected atomic.Bool
+	time.AfterFunc(time.Millisecond*100, func() {
+		elected.Store(true)
+	})
+
+	var calls atomic.Int32
+	propose := func() error {
+		calls.Inc()
+		if !elected.Load() {
+			return ErrNotLeader
+		}
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*2)
+	defer cancel()
+	err := s.retryOnNotLeader(ctx, s.opts.ProposeRetryOnNotLeader, propose)
+	assert.NoError(t, err)
+	assert.Greater(t, calls.Load(), int32(1))
+
+	// 没有开启重试，直接返回错误
+	s.opts.ProposeRetryOnNotLeader = false
+	elected.Store(false)
+	calls.Store(0)
+	err = s.retryOnNotLeader(ctx, s.opts.ProposeRetryOnNotLeader, propose)
+	assert.Equal(t, ErrNotLeader, err)
+	assert.Equal(t, int32(1), calls.Load())
+
+	// 选举一直没完成，超时后返回最后的错误
+	s.opts.ProposeRetryOnNotLeader = true
+	timeoutCtx, timeoutCancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer timeoutCancel()
+	err = s.retryOnNotLeader(timeoutCtx, s.opts.ProposeRetryOnNotLeader, propose)
+	assert.Equal(t, ErrNotLeader, err)
+
+	// 其他错误不重试
+	calls.Store(0)
+	err = s.retryOnNotLeader(ctx, s.opts.ProposeRetryOnNotLeader, func() error {
+		calls.Inc()
+		return ErrStopped
+	})
+	assert.Equal(t, ErrStopped, err)
+	assert.Equal(t, int32(1), calls.Load())
+}
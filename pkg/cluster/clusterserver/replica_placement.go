@@ -0,0 +1,86 @@
+package cluster
+
+import (
+	"strconv"
+
+	"github.com/WuKongIM/WuKongIM/pkg/wkutil"
+	"go.uber.org/zap"
+)
+
+// ReplicaSetPolicy 新频道选择副本节点的策略
+type ReplicaSetPolicy int
+
+const (
+	// ReplicaSetPolicyRandom 在允许投票的在线节点中随机选择（默认）
+	ReplicaSetPolicyRandom ReplicaSetPolicy = iota
+	// ReplicaSetPolicySpreadDomains 优先把副本分散到不同的故障域（机架、可用区等），每个故障域都有副本后才在同一个故障域里选择多个
+	ReplicaSetPolicySpreadDomains
+)
+
+func (p ReplicaSetPolicy) String() string {
+	switch p {
+	case ReplicaSetPolicyRandom:
+		return "random"
+	case ReplicaSetPolicySpreadDomains:
+		return "spreadDomains"
+	}
+	return "unknown"
+}
+
+// nodeDomain 节点所在的故障域，没有配置的节点自成一个故障域
+func (o *Options) nodeDomain(nodeId uint64) string {
+	if domain := o.NodeDomains[nodeId]; domain != "" {
+		return domain
+	}
+	return "node-" + strconv.FormatUint(nodeId, 10)
+}
+
+// spreadReplicas 从候选节点里补齐副本到count个，先选择故障域还没有副本的节点，所有故障域都有副本后再按候选顺序补齐
+// replicaIds为已经选定的副本（例如领导），候选节点的顺序即优先级
+func spreadReplicas(replicaIds []uint64, candidates []uint64, count int, domain func(nodeId uint64) string) []uint64 {
+	usedDomains := make(map[string]struct{}, count)
+	for _, replicaId := range replicaIds {
+		usedDomains[domain(replicaId)] = struct{}{}
+	}
+	for _, nodeId := range candidates {
+		if len(replicaIds) >= count {
+			return replicaIds
+		}
+		if wkutil.ArrayContainsUint64(replicaIds, nodeId) {
+			continue
+		}
+		if _, ok := usedDomains[domain(nodeId)]; ok {
+			continue
+		}
+		replicaIds = append(replicaIds, nodeId)
+		usedDomains[domain(nodeId)] = struct{}{}
+	}
+	// 故障域不够，同一个故障域里放多个副本
+	for _, nodeId := range candidates {
+		if len(replicaIds) >= count {
+			break
+		}
+		if wkutil.ArrayContainsUint64(replicaIds, nodeId) {
+			continue
+		}
+		replicaIds = append(replicaIds, nodeId)
+	}
+	return replicaIds
+}
+
+// checkReplicaSetPolicy 检查故障域配置，故障域数量少于副本数量时无法保证每个副本在不同的故障域
+func (s *Server) checkReplicaSetPolicy() {
+	if s.opts.ReplicaSetPolicy != ReplicaSetPolicySpreadDomains {
+		return
+	}
+	domains := make(map[string]struct{})
+	for nodeId := range s.opts.InitNodes {
+		domains[s.opts.nodeDomain(nodeId)] = struct{}{}
+	}
+	for nodeId := range s.opts.NodeDomains {
+		domains[s.opts.nodeDomain(nodeId)] = struct{}{}
+	}
+	if len(domains) < s.opts.ChannelMaxReplicaCount {
+		s.Warn("failure domains are fewer than channel replica count, some replicas will share a domain", zap.Int("domains", len(domains)), zap.Int("channelMaxReplicaCount", s.opts.ChannelMaxReplicaCount))
+	}
+}
@@ -0,0 +1,44 @@
+package cluster
+
+import (
+	"testing"
+
+	"github.com/WuKongIM/WuKongIM/pkg/cluster/clusterconfig/pb"
+	"github.com/WuKongIM/WuKongIM/pkg/wklog"
+	"github.com/stretchr/testify/assert"
+)
+
+// 有足够的故障域时，副本分散在不同的故障域
+func TestNewChannelClusterConfigSpreadDomains(t *testing.T) {
+	nodes := []*pb.Node{{Id: 1}, {Id: 2}, {Id: 3}, {Id: 4}, {Id: 5}, {Id: 6}}
+	nodeDomains := map[uint64]string{1: "az1", 2: "az1", 3: "az2", 4: "az2", 5: "az3", 6: "az3"}
+	s := &Server{opts: NewOptions(WithNodeId(1), WithChannelMaxReplicaCount(3), WithReplicaSetPolicy(ReplicaSetPolicySpreadDomains), WithNodeDomains(nodeDomains)), Log: wklog.NewWKLog("test")}
+
+	for i := 0; i < 100; i++ {
+		cfg := s.newChannelClusterConfig("test", 2, 1, nodes)
+		assert.Equal(t, uint64(1), cfg.Replicas[0])
+		assert.Len(t, cfg.Replicas, 3)
+		domains := make(map[string]struct{})
+		for _, replicaId := range cfg.Replicas {
+			domains[nodeDomains[replicaId]] = struct{}{}
+		}
+		assert.Len(t, domains, 3, "replicas: %v", cfg.Replicas)
+	}
+}
+
+func TestSpreadReplicas(t *testing.T) {
+	nodeDomains := map[uint64]string{1: "az1", 2: "az1", 3: "az1", 4: "az2"}
+	opts := NewOptions(WithNodeDomains(nodeDomains))
+
+	// 故障域不够时，先每个故障域一个，再在同一个故障域里补齐
+	replicas := spreadReplicas([]uint64{1}, []uint64{2, 3, 4}, 3, opts.nodeDomain)
+	assert.Equal(t, []uint64{1, 4, 2}, replicas)
+
+	// 没有配置故障域的节点自成一个故障域
+	replicas = spreadReplicas([]uint64{1}, []uint64{2, 5, 4}, 3, opts.nodeDomain)
+	assert.Equal(t, []uint64{1, 5, 4}, replicas)
+
+	// 候选节点不够
+	replicas = spreadReplicas([]uint64{1}, []uint64{1, 4}, 3, opts.nodeDomain)
+	assert.Equal(t, []uint64{1, 4}, replicas)
+}
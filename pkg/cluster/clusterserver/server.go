@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"path"
 	"strconv"
 	"strings"
@@ -51,8 +52,17 @@ type Server struct {
 	onMessageFnc           func(fromNodeId uint64, msg *proto.Message) // 上层处理消息的函数
 	logIdGen               *snowflake.Node                             // 日志id生成
 	slotStorage            *PebbleShardLogStorage
-	apiPrefix              string    // api前缀
-	uptime                 time.Time // 服务器启动时间
+	blobStore              *blobStore                 // 大日志数据存储（开启LargeLogThreshold时才有）
+	blobHashCache          *lru.Cache[string, []byte] // 同步日志的blob sha256缓存，避免每次同步都重新计算
+	blobFetchPool          *ants.Pool                 // 追随者获取blob的协程池
+	channelCreateLimiter   *channelCreateLimiter      // 频道创建限速（开启ChannelCreateRate时才有）
+	proposeAuditor         *proposeAuditor            // 提案审计（开启ProposeAuditPath时才有）
+	writeLimiter           *writeLimiter              // 节点写入限速（开启MaxWriteBytesPerSecond时才有）
+	proposeLimiter         *proposeLimiter            // 节点并发提案限制（开启MaxConcurrentProposes时才有）
+	appointArbiter         *appointArbiter            // 手动指定槽领导的仲裁
+	leaderChangeC          chan LeaderChangeEvent     // 领导变更事件
+	apiPrefix              string                     // api前缀
+	uptime                 time.Time                  // 服务器启动时间
 	wklog.Log
 
 	stopped atomic.Bool
@@ -60,6 +70,15 @@ type Server struct {
 	stopper *syncutil.Stopper
 
 	clusterCfgCache *lru.Cache[string, wkdb.ChannelClusterConfig]
+	// 已从本节点移除的频道的最近事件（频道移除后还能查看移除前发生了什么）
+	destroyedChannelEvents *lru.Cache[string, *channelEvents]
+	electionStuck          *electionStuck   // 选举卡住的频道检测
+	replicaCountChanging   sync.Map         // 正在调整副本数量的频道
+	snapshotNotifying      sync.Map         // 正在通知落后副本安装快照（领导，key为频道key和副本节点id）
+	snapshotInstalling     sync.Map         // 正在安装快照的频道（副本）
+	leaderTransfers        *leaderTransfers // 本节点作为旧领导发起的计划中的领导转移
+	diskGuard              diskGuard        // 磁盘空间保护
+	electionPause          electionPause    // 维护期间暂停选举
 }
 
 func New(opts *Options) *Server {
@@ -71,12 +90,21 @@ func New(opts *Options) *Server {
 		channelKeyLock: keylock.NewKeyLock(),
 		channelLoadMap: make(map[string]struct{}),
 		stopper:        syncutil.NewStopper(),
+		leaderChangeC:  make(chan LeaderChangeEvent, 1024),
 	}
 	var err error
 	s.clusterCfgCache, err = lru.New[string, wkdb.ChannelClusterConfig](1000)
 	if err != nil {
 		s.Panic("new clusterCfgCache failed", zap.Error(err))
 	}
+	s.destroyedChannelEvents, err = lru.New[string, *channelEvents](1000)
+	if err != nil {
+		s.Panic("new destroyedChannelEvents failed", zap.Error(err))
+	}
+
+	s.electionStuck = newElectionStuck(opts.ElectionStuckThreshold, opts.ElectionStuckMaxBackoff)
+	s.leaderTransfers = newLeaderTransfers(opts.LeaderTransferGrace)
+	s.checkReplicaSetPolicy()
 
 	s.slotManager = newSlotManager(s)
 	s.channelManager = newChannelManager(s)
@@ -86,6 +114,38 @@ func New(opts *Options) *Server {
 		opts.SlotLogStorage = s.slotStorage
 	}
 
+	if opts.ChannelCreateRate > 0 {
+		s.channelCreateLimiter = newChannelCreateLimiter(opts.ChannelCreateRate, opts.ChannelCreateBurst)
+	}
+
+	s.appointArbiter = newAppointArbiter()
+
+	if opts.MaxWriteBytesPerSecond > 0 {
+		s.writeLimiter = newWriteLimiter(opts.MaxWriteBytesPerSecond)
+	}
+
+	if opts.MaxConcurrentProposes > 0 {
+		s.proposeLimiter = newProposeLimiter(opts.MaxConcurrentProposes, opts.ProposeConcurrencyMode)
+	}
+
+	if opts.ProposeAuditPath != "" {
+		s.proposeAuditor = newProposeAuditor(opts.ProposeAuditPath, opts.ProposeAuditQueueSize)
+	}
+
+	if opts.LargeLogThreshold > 0 {
+		s.blobStore = newBlobStore(path.Join(opts.DataDir, "blobs"))
+		s.blobHashCache, err = lru.New[string, []byte](blobHashCacheSize)
+		if err != nil {
+			s.Panic("new blobHashCache failed", zap.Error(err))
+		}
+		s.blobFetchPool, err = ants.NewPool(blobFetchPoolSize, ants.WithNonblocking(true), ants.WithPanicHandler(func(err interface{}) {
+			s.Panic("blob获取协程池崩溃", zap.Any("err", err), zap.Stack("stack"))
+		}))
+		if err != nil {
+			s.Panic("new blobFetchPool failed", zap.Error(err))
+		}
+	}
+
 	logIdGen, err := snowflake.NewNode(int64(opts.NodeId))
 	if err != nil {
 		s.Panic("new logIdGen failed", zap.Error(err))
@@ -126,6 +186,8 @@ func New(opts *Options) *Server {
 		clusterevent.WithApiServerAddr(opts.ApiServerAddr),
 		clusterevent.WithCluster(s),
 		clusterevent.WithElectionIntervalTick(opts.ElectionIntervalTick),
+		clusterevent.WithElectionGraceTick(opts.ElectionGraceTick),
+		clusterevent.WithElectionPaused(s.electionPaused),
 		clusterevent.WithHeartbeatIntervalTick(opts.HeartbeatIntervalTick),
 		clusterevent.WithTickInterval(opts.TickInterval),
 		clusterevent.WithPongMaxTick(opts.PongMaxTick),
@@ -171,6 +233,42 @@ func (s *Server) Start() error {
 
 	s.channelKeyLock.StartCleanLoop()
 
+	if s.blobStore != nil {
+		err = s.blobStore.open()
+		if err != nil {
+			return err
+		}
+		s.stopper.RunWorker(s.blobCleanLoop)
+	}
+
+	if s.proposeAuditor != nil {
+		err = s.proposeAuditor.open()
+		if err != nil {
+			return err
+		}
+		s.stopper.RunWorker(func() {
+			s.proposeAuditor.loop(s.stopper.ShouldStop())
+		})
+	}
+
+	if s.opts.OnLeaderChange != nil {
+		s.stopper.RunWorker(s.leaderChangeLoop)
+	}
+
+	if s.opts.ChannelInactiveTimeout > 0 {
+		s.stopper.RunWorker(s.channelReapLoop)
+	}
+
+	if s.opts.DiskCheckInterval > 0 {
+		if err = s.checkDisk(); err != nil {
+			s.Warn("get disk free bytes failed, disk is not checked", zap.Error(err), zap.String("dataDir", s.opts.DataDir))
+		} else {
+			s.stopper.RunWorker(s.diskCheckLoop)
+		}
+	}
+
+	trace.GlobalTrace.Metrics.Cluster().PeerSendQueueSource(s.nodeManager.sendQueueStats)
+
 	nodes := s.clusterEventServer.Nodes()
 	if len(nodes) > 0 {
 		for _, node := range nodes {
@@ -238,8 +336,29 @@ func (s *Server) Start() error {
 
 func (s *Server) Stop() {
 
+	// 停止前把本节点领导的槽转移给其他副本，停机期间槽不用等选举
+	if s.opts.ShutdownHandoffTimeout > 0 {
+		timeoutCtx, cancel := context.WithTimeout(context.Background(), s.opts.ShutdownHandoffTimeout)
+		if err := s.HandoffSlotLeaders(timeoutCtx); err != nil {
+			s.Warn("handoff slot leaders before stop failed", zap.Error(err))
+		}
+		cancel()
+	}
+
+	// 停止前等待已追加的日志存储完成，避免已提交但还没存储的日志在重启后丢失
+	if s.opts.ShutdownFlushTimeout > 0 {
+		timeoutCtx, cancel := context.WithTimeout(context.Background(), s.opts.ShutdownFlushTimeout)
+		if err := s.FlushAll(timeoutCtx); err != nil {
+			s.Warn("flush pending appends before stop failed", zap.Error(err))
+		}
+		cancel()
+	}
+
 	s.stopped.Store(true)
 	s.cancelFnc()
+	if s.blobFetchPool != nil {
+		s.blobFetchPool.Release()
+	}
 	s.stopper.Stop()
 	s.nodeManager.stop()
 	s.channelElectionManager.stop()
@@ -252,11 +371,31 @@ func (s *Server) Stop() {
 
 }
 
+// FlushAll 等待本节点槽和频道已追加的日志都存储完成（存储时同步落盘），ctx结束前没有完成返回reactor.ErrFlushTimeout
+func (s *Server) FlushAll(ctx context.Context) error {
+	if err := s.slotManager.slotReactor.FlushAll(ctx); err != nil {
+		return err
+	}
+	return s.channelManager.channelReactor.FlushAll(ctx)
+}
+
 // 提案频道分布式配置
 func (s *Server) ProposeChannelClusterConfig(ctx context.Context, cfg wkdb.ChannelClusterConfig) error {
 	return s.opts.ChannelClusterStorage.Propose(ctx, cfg)
 }
 
+// ExportChannelClusterConfigs 导出本节点所有的频道分布式配置（按id顺序，基于快照读取），用于备份和迁移频道拓扑，返回导出的数量
+func (s *Server) ExportChannelClusterConfigs(w io.Writer) (int, error) {
+	return wkdb.ExportChannelClusterConfigs(s.opts.DB, w)
+}
+
+// ImportChannelClusterConfigs 导入ExportChannelClusterConfigs导出的频道分布式配置，每条配置都会提案到频道所在的槽，返回导入的数量
+func (s *Server) ImportChannelClusterConfigs(ctx context.Context, r io.Reader) (int, error) {
+	return wkdb.ImportChannelClusterConfigs(r, func(cfg wkdb.ChannelClusterConfig) error {
+		return s.ProposeChannelClusterConfig(ctx, cfg)
+	})
+}
+
 // 获取分布式配置
 func (s *Server) GetConfig() *pb.Config {
 	return s.clusterEventServer.Config()
@@ -268,6 +407,133 @@ func (s *Server) MigrateSlot(slotId uint32, fromNodeId, toNodeId uint64) error {
 	return s.clusterEventServer.ProposeMigrateSlot(slotId, fromNodeId, toNodeId)
 }
 
+// TransferSlotLeader 将槽的领导转移给指定的副本节点（只能在槽领导节点上调用）
+// 目标副本的日志追上领导后才会真正切换领导，追赶期间领导会暂停提案
+func (s *Server) TransferSlotLeader(slotId uint32, toNodeId uint64) error {
+	slot := s.clusterEventServer.Slot(slotId)
+	if slot == nil {
+		return ErrSlotNotExist
+	}
+	if slot.Leader != s.opts.NodeId {
+		return ErrSlotNotIsLeader
+	}
+	if toNodeId == 0 || toNodeId == slot.Leader {
+		return fmt.Errorf("invalid transfer target[%d]", toNodeId)
+	}
+	if !wkutil.ArrayContainsUint64(slot.Replicas, toNodeId) {
+		return fmt.Errorf("transfer target[%d] not in replicas", toNodeId)
+	}
+	if !s.clusterEventServer.NodeOnline(toNodeId) {
+		return fmt.Errorf("transfer target[%d] is offline", toNodeId)
+	}
+	if slot.MigrateFrom != 0 || slot.MigrateTo != 0 {
+		return fmt.Errorf("slot[%d] is migrating", slotId)
+	}
+
+	// 同一任期同时有多个指定不同目标的请求时，只有一个能胜出
+	proceed, err := s.appointArbiter.arbitrate(slotId, slot.Term, toNodeId)
+	if err != nil {
+		s.Warn("transfer slot leader conflict", zap.Error(err), zap.Uint32("slotId", slotId), zap.Uint32("term", slot.Term), zap.Uint64("to", toNodeId))
+		return err
+	}
+	if !proceed {
+		return nil
+	}
+	defer s.appointArbiter.finish(slotId, slot.Term)
+
+	s.Info("transfer slot leader", zap.Uint32("slotId", slotId), zap.Uint64("from", slot.Leader), zap.Uint64("to", toNodeId))
+	if info, ok := s.slotManager.slotReactor.IndexInfo(SlotIdToKey(slotId)); ok {
+		s.leaderTransfers.begin(slotId, toNodeId, info.CommittedIndex, time.Now())
+	}
+	err = s.clusterEventServer.ProposeMigrateSlot(slotId, slot.Leader, toNodeId)
+	if err != nil {
+		s.leaderTransfers.end(slotId)
+		return err
+	}
+	s.notifyLeaderChange(LeaderChangeEvent{
+		ShardType:  ShardTypeSlot,
+		SlotId:     slotId,
+		PrevLeader: slot.Leader,
+		Leader:     toNodeId,
+		Term:       slot.Term,
+		Reason:     LeaderChangeReasonAppoint,
+		Time:       time.Now(),
+	})
+	return nil
+}
+
+// TransferChannelLeader 将频道的领导平滑转移给指定的副本节点（只能在频道领导节点上调用），新领导生效或ctx结束后返回
+func (s *Server) TransferChannelLeader(ctx context.Context, channelId string, channelType uint8, toNodeId uint64) error {
+	handler := s.channelManager.get(channelId, channelType)
+	if handler == nil {
+		return ErrChannelNotFound
+	}
+	return handler.(*channel).transferLeadership(ctx, toNodeId)
+}
+
+// HandoffSlotLeaders 将本节点领导的槽全部转移给其他在线副本，并等待转移完成（停机维护前调用，Stop时也会调用，见ShutdownHandoffTimeout）
+// 没有在线副本的槽不转移，某个槽转移失败不影响其他槽，等发起转移的槽都完成后返回第一个错误
+func (s *Server) HandoffSlotLeaders(ctx context.Context) error {
+	targets := slotHandoffTargets(s.clusterEventServer.Slots(), s.opts.NodeId, s.clusterEventServer.NodeOnline)
+	var firstErr error
+	for slotId, toNodeId := range targets {
+		if toNodeId == 0 {
+			s.Warn("handoff slot leader: no online replica", zap.Uint32("slotId", slotId))
+			delete(targets, slotId)
+			continue
+		}
+		err := s.TransferSlotLeader(slotId, toNodeId)
+		if err != nil {
+			s.Error("handoff slot leader failed", zap.Error(err), zap.Uint32("slotId", slotId), zap.Uint64("to", toNodeId))
+			if firstErr == nil {
+				firstErr = err
+			}
+			delete(targets, slotId)
+		}
+	}
+
+	tk := time.NewTicker(time.Millisecond * 200)
+	defer tk.Stop()
+	for len(targets) > 0 {
+		for slotId := range targets {
+			slot := s.clusterEventServer.Slot(slotId)
+			if slot == nil || slot.Leader != s.opts.NodeId {
+				delete(targets, slotId)
+			}
+		}
+		if len(targets) == 0 {
+			break
+		}
+		select {
+		case <-tk.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-s.stopper.ShouldStop():
+			return ErrStopped
+		}
+	}
+	return firstErr
+}
+
+// slotHandoffTargets 本节点领导的（没有在迁移的）槽和转移的目标节点（第一个在线的其他副本，没有在线副本时为0）
+func slotHandoffTargets(slots []*pb.Slot, nodeId uint64, online func(nodeId uint64) bool) map[uint32]uint64 {
+	targets := make(map[uint32]uint64)
+	for _, slot := range slots {
+		if slot.Leader != nodeId || slot.MigrateFrom != 0 || slot.MigrateTo != 0 {
+			continue
+		}
+		var toNodeId uint64
+		for _, replicaId := range slot.Replicas {
+			if replicaId != nodeId && online(replicaId) {
+				toNodeId = replicaId
+				break
+			}
+		}
+		targets[slot.Id] = toNodeId
+	}
+	return targets
+}
+
 func (s *Server) AddSlotMessage(m reactor.Message) {
 
 	// 统计引入的消息
@@ -300,6 +566,11 @@ func (s *Server) AddChannelMessage(m reactor.Message) {
 	// 获取或创建频道处理者
 	_ = s.getOrCreateChannelHandler(m.HandlerKey)
 
+	if missing := s.missingBlobLogs(m); len(missing) > 0 {
+		s.addChannelSyncRespWithBlobs(m, missing)
+		return
+	}
+
 	s.channelManager.addMessage(m)
 
 	// s.channelLoadMapLock.RLock()
@@ -417,6 +688,8 @@ func (s *Server) send(shardType ShardType, m reactor.Message) {
 	case MsgTypeChannel:
 		trace.GlobalTrace.Metrics.Cluster().MessageOutgoingBytesAdd(trace.ClusterKindChannel, int64(msg.Size()))
 		trace.GlobalTrace.Metrics.Cluster().MessageOutgoingCountAdd(trace.ClusterKindChannel, 1)
+		_, channelType := wkutil.ChannelFromlKey(m.HandlerKey)
+		trace.GlobalTrace.Metrics.Cluster().ChannelTypeMessageOutgoingBytesAdd(channelType, int64(msg.Size()))
 	case MsgTypeSlot:
 		trace.GlobalTrace.Metrics.Cluster().MessageOutgoingBytesAdd(trace.ClusterKindSlot, int64(msg.Size()))
 		trace.GlobalTrace.Metrics.Cluster().MessageOutgoingCountAdd(trace.ClusterKindSlot, 1)
@@ -472,6 +745,9 @@ func (s *Server) onMessage(c wknet.Conn, m *proto.Message) {
 		}
 		trace.GlobalTrace.Metrics.Cluster().MessageIncomingCountAdd(trace.ClusterKindSlot, 1)
 		trace.GlobalTrace.Metrics.Cluster().MessageIncomingBytesAdd(trace.ClusterKindSlot, msgSize)
+		if !s.allowInbound(c, trace.ClusterKindSlot, msg) {
+			return
+		}
 		s.AddSlotMessage(msg)
 	case MsgTypeChannel:
 		msg, err := reactor.UnmarshalMessage(m.Content)
@@ -481,6 +757,11 @@ func (s *Server) onMessage(c wknet.Conn, m *proto.Message) {
 		}
 		trace.GlobalTrace.Metrics.Cluster().MessageIncomingCountAdd(trace.ClusterKindChannel, 1)
 		trace.GlobalTrace.Metrics.Cluster().MessageIncomingBytesAdd(trace.ClusterKindChannel, msgSize)
+		_, channelType := wkutil.ChannelFromlKey(msg.HandlerKey)
+		trace.GlobalTrace.Metrics.Cluster().ChannelTypeMessageIncomingBytesAdd(channelType, msgSize)
+		if !s.allowInbound(c, trace.ClusterKindChannel, msg) {
+			return
+		}
 		s.AddChannelMessage(msg)
 
 	case MsgTypeChannelClusterConfigUpdate: // 频道配置更新
@@ -14,8 +14,10 @@ import (
 
 	"github.com/WuKongIM/WuKongIM/pkg/auth"
 	"github.com/WuKongIM/WuKongIM/pkg/auth/resource"
+	"github.com/WuKongIM/WuKongIM/pkg/cluster/reactor"
 	"github.com/WuKongIM/WuKongIM/pkg/cluster/replica"
 	"github.com/WuKongIM/WuKongIM/pkg/network"
+	"github.com/WuKongIM/WuKongIM/pkg/trace"
 	"github.com/WuKongIM/WuKongIM/pkg/wkdb"
 	"github.com/WuKongIM/WuKongIM/pkg/wkhttp"
 	"github.com/WuKongIM/WuKongIM/pkg/wkutil"
@@ -37,6 +39,7 @@ func (s *Server) ServerAPI(route *wkhttp.WKHttp, prefix string) {
 	route.GET(s.formatPath("/slots/:id/config"), s.slotClusterConfigGet)                               // 槽分布式配置
 	route.GET(s.formatPath("/slots/:id/channels"), s.slotChannelsGet)                                  // 获取某个槽的所有频道信息
 	route.POST(s.formatPath("/slots/:id/migrate"), s.slotMigrate)                                      // 迁移槽
+	route.POST(s.formatPath("/slots/:id/transfer"), s.slotLeaderTransfer)                              // 转移槽领导
 	route.GET(s.formatPath("/info"), s.clusterInfoGet)                                                 // 获取集群信息
 	route.GET(s.formatPath("/messages"), s.messageSearch)                                              // 搜索消息
 	route.GET(s.formatPath("/channels"), s.channelSearch)                                              // 频道搜索
@@ -47,12 +50,25 @@ func (s *Server) ServerAPI(route *wkhttp.WKHttp, prefix string) {
 	route.GET(s.formatPath("/devices"), s.deviceSearch)                                                // 设备搜索
 	route.GET(s.formatPath("/conversations"), s.conversationSearch)                                    // 搜索最近会话消息
 	route.POST(s.formatPath("/channels/:channel_id/:channel_type/migrate"), s.channelMigrate)          // 迁移频道
+	route.POST(s.formatPath("/channels/:channel_id/:channel_type/replicas"), s.channelReplicaCount)    // 调整频道的副本数量
 	route.GET(s.formatPath("/channels/:channel_id/:channel_type/config"), s.channelClusterConfig)      // 获取频道的分布式配置
 	route.POST(s.formatPath("/channels/:channel_id/:channel_type/start"), s.channelStart)              // 开始频道
 	route.POST(s.formatPath("/channels/:channel_id/:channel_type/stop"), s.channelStop)                // 停止频道
+	route.POST(s.formatPath("/channels/:channel_id/:channel_type/rebuild"), s.channelRebuild)          // 清空本节点的频道数据并从领导重新同步
+	route.POST(s.formatPath("/channels/:channel_id/:channel_type/debug"), s.channelDebug)              // 开启或关闭频道的调试监控
+	route.GET(s.formatPath("/debugChannels"), s.debugChannelsGet)                                      // 获取本节点开启了调试监控的频道
+	route.GET(s.formatPath("/electionStuckChannels"), s.electionStuckChannelsGet)                      // 获取本节点（作为槽领导）选举卡住的频道
+	route.GET(s.formatPath("/idleSweep"), s.idleSweepGet)                                              // 获取本节点空闲频道回收的状态
+	route.POST(s.formatPath("/idleSweep"), s.idleSweepSet)                                             // 暂停或恢复本节点空闲频道的回收
+	route.GET(s.formatPath("/electionPause"), s.electionPauseGet)                                      // 获取本节点选举暂停的状态
+	route.POST(s.formatPath("/electionPause"), s.electionPauseSet)                                     // 暂停或恢复集群的选举（网络维护）
+	route.GET(s.formatPath("/channelIndexes"), s.channelIndexesGet)                                    // 一次获取本节点所有频道的提交、应用和最新日志下标
 	route.POST(s.formatPath("/channel/status"), s.channelStatus)                                       // 获取频道状态
 	route.GET(s.formatPath("/channels/:channel_id/:channel_type/replicas"), s.channelReplicas)         // 获取频道副本信息
 	route.GET(s.formatPath("/channels/:channel_id/:channel_type/localReplica"), s.channelLocalReplica) // 获取频道在本节点的副本信息
+	route.GET(s.formatPath("/channels/:channel_id/:channel_type/events"), s.channelLocalEvents)        // 获取频道在本节点最近发生的事件
+	route.GET(s.formatPath("/channels/:channel_id/:channel_type/reactorSub"), s.channelReactorSub)     // 获取本节点处理频道的reactor sub及其负载
+	route.GET(s.formatPath("/channels/:channel_id/:channel_type/debugState"), s.channelDebugState)     // 获取频道在本节点的内部状态（副本的角色、任期、各副本同步进度等）
 
 	route.GET(s.formatPath("/logs"), s.clusterLogs) // 获取节点日志
 
@@ -553,6 +569,51 @@ func (s *Server) slotMigrate(c *wkhttp.Context) {
 
 }
 
+func (s *Server) slotLeaderTransfer(c *wkhttp.Context) {
+	var req struct {
+		To uint64 `json:"to"` // 转移的目标节点
+	}
+
+	if !s.opts.Auth.HasPermissionWithContext(c, resource.Slot.Migrate, auth.ActionWrite) {
+		c.ResponseStatus(http.StatusUnauthorized)
+		return
+	}
+
+	bodyBytes, err := BindJSON(&req, c)
+	if err != nil {
+		s.Error("bind json error", zap.Error(err))
+		c.ResponseError(err)
+		return
+	}
+	id := wkutil.ParseUint32(c.Param("id"))
+
+	slot := s.clusterEventServer.Slot(id)
+	if slot == nil {
+		s.Error("slot not found", zap.Uint32("slotId", id))
+		c.ResponseError(errors.New("slot not found"))
+		return
+	}
+
+	if slot.Leader != s.opts.NodeId {
+		node := s.clusterEventServer.Node(slot.Leader)
+		if node == nil {
+			s.Error("leader not found", zap.Uint64("leaderId", slot.Leader))
+			c.ResponseError(errors.New("leader not found"))
+			return
+		}
+		c.ForwardWithBody(fmt.Sprintf("%s%s", node.ApiServerAddr, c.Request.URL.Path), bodyBytes)
+		return
+	}
+
+	err = s.TransferSlotLeader(id, req.To)
+	if err != nil {
+		s.Error("slotLeaderTransfer: TransferSlotLeader error", zap.Error(err))
+		c.ResponseError(err)
+		return
+	}
+	c.ResponseOK()
+}
+
 func (s *Server) clusterInfoGet(c *wkhttp.Context) {
 
 	leaderId := s.clusterEventServer.LeaderId()
@@ -1799,6 +1860,18 @@ func (s *Server) channelMigrate(c *wkhttp.Context) {
 		return
 	}
 	s.clusterCfgCache.Add(wkutil.ChannelToKey(channelId, channelType), newClusterConfig)
+	if req.MigrateFrom == clusterConfig.LeaderId { // 迁移的是领导
+		s.notifyLeaderChange(LeaderChangeEvent{
+			ShardType:   ShardTypeChannel,
+			ChannelId:   channelId,
+			ChannelType: channelType,
+			PrevLeader:  clusterConfig.LeaderId,
+			Leader:      req.MigrateTo,
+			Term:        clusterConfig.Term,
+			Reason:      LeaderChangeReasonManual,
+			Time:        time.Now(),
+		})
+	}
 
 	// 如果频道领导不是当前节点，则发送最新配置给频道领导 （这里就算发送失败也没问题，因为频道领导会间隔比对自己与槽领导的配置）
 	if newClusterConfig.LeaderId != s.opts.NodeId {
@@ -1825,6 +1898,63 @@ func (s *Server) channelMigrate(c *wkhttp.Context) {
 
 }
 
+// 调整频道的副本数量，在槽领导上后台逐步执行，进度可以通过频道的分布式配置查看
+func (s *Server) channelReplicaCount(c *wkhttp.Context) {
+	var req struct {
+		ReplicaCount int `json:"replica_count"` // 目标副本数量
+	}
+	bodyBytes, err := BindJSON(&req, c)
+	if err != nil {
+		s.Error("BindJSON error", zap.Error(err))
+		c.ResponseError(err)
+		return
+	}
+	if req.ReplicaCount <= 0 {
+		c.ResponseError(ErrInvalidReplicaCount)
+		return
+	}
+	channelId := c.Param("channel_id")
+	channelType := wkutil.ParseUint8(c.Param("channel_type"))
+
+	// 获取频道所属槽领导的id
+	nodeId, err := s.SlotLeaderIdOfChannel(channelId, channelType)
+	if err != nil {
+		s.Error("channelReplicaCount: LeaderIdOfChannel error", zap.Error(err))
+		c.ResponseError(err)
+		return
+	}
+	if nodeId != s.opts.NodeId {
+		c.ForwardWithBody(fmt.Sprintf("%s%s", s.clusterEventServer.Node(nodeId).ApiServerAddr, c.Request.URL.Path), bodyBytes)
+		return
+	}
+
+	if _, ok := s.replicaCountChanging.Load(wkutil.ChannelToKey(channelId, channelType)); ok {
+		c.ResponseError(ErrReplicaCountChanging)
+		return
+	}
+	clusterConfig, err := s.getChannelClusterConfig(channelId, channelType)
+	if err != nil {
+		s.Error("channelReplicaCount: getChannelClusterConfig error", zap.Error(err))
+		c.ResponseError(err)
+		return
+	}
+	if req.ReplicaCount > len(clusterConfig.Replicas) && req.ReplicaCount > s.clusterEventServer.AllowVoteAndJoinedNodeCount() {
+		c.ResponseError(fmt.Errorf("%w: replica count %d exceeds node count %d", ErrInvalidReplicaCount, req.ReplicaCount, s.clusterEventServer.AllowVoteAndJoinedNodeCount()))
+		return
+	}
+
+	go func() {
+		err := s.ChangeChannelReplicaCount(s.cancelCtx, channelId, channelType, req.ReplicaCount)
+		if err != nil {
+			s.Error("channelReplicaCount: change replica count failed", zap.Error(err), zap.String("channelId", channelId), zap.Uint8("channelType", channelType), zap.Int("replicaCount", req.ReplicaCount))
+			return
+		}
+		s.Info("channelReplicaCount: change replica count done", zap.String("channelId", channelId), zap.Uint8("channelType", channelType), zap.Int("replicaCount", req.ReplicaCount))
+	}()
+
+	c.ResponseOK()
+}
+
 func (s *Server) channelClusterConfig(c *wkhttp.Context) {
 
 	start := time.Now()
@@ -1938,11 +2068,233 @@ func (s *Server) channelStop(c *wkhttp.Context) {
 
 	handler := s.channelManager.get(channelId, channelType)
 	if handler != nil {
-		s.channelManager.remove(handler.(*channel))
+		// 等待进行中的提案完成后再停止，避免这些提案被误判为失败，后台等待，不阻塞请求
+		ch := handler.(*channel)
+		go func() {
+			timeoutCtx, cancel := context.WithTimeout(s.cancelCtx, s.opts.ReqTimeout)
+			defer cancel()
+			if err := ch.gracefulDestroy(timeoutCtx); err != nil && !errors.Is(err, ErrChannelDestroying) {
+				s.Warn("stop channel failed", zap.Error(err), zap.String("channelId", channelId), zap.Uint8("channelType", channelType))
+			}
+		}()
+	}
+	c.ResponseOK()
+}
+
+func (s *Server) channelRebuild(c *wkhttp.Context) {
+
+	if !s.opts.Auth.HasPermissionWithContext(c, resource.ClusterChannel.Rebuild, auth.ActionWrite) {
+		c.ResponseStatus(http.StatusUnauthorized)
+		return
+	}
+
+	channelId := c.Param("channel_id")
+	channelType := wkutil.ParseUint8(c.Param("channel_type"))
+
+	timeoutCtx, cancel := context.WithTimeout(s.cancelCtx, s.opts.ReqTimeout)
+	defer cancel()
+	err := s.RebuildChannel(timeoutCtx, channelId, channelType)
+	if err != nil {
+		s.Error("RebuildChannel error", zap.Error(err), zap.String("channelId", channelId), zap.Uint8("channelType", channelType))
+		c.ResponseError(err)
+		return
+	}
+	c.ResponseOK()
+}
+
+// 开启或关闭频道的调试监控（统计在频道领导节点上进行）
+// 开关会同步到频道的所有副本节点，领导变更后新的领导节点继续统计
+func (s *Server) channelDebug(c *wkhttp.Context) {
+	if !s.opts.Auth.HasPermissionWithContext(c, resource.ClusterChannel.Debug, auth.ActionWrite) {
+		c.ResponseStatus(http.StatusUnauthorized)
+		return
+	}
+	var req channelDebugReq
+	if _, err := BindJSON(&req, c); err != nil {
+		s.Error("BindJSON error", zap.Error(err))
+		c.ResponseError(err)
+		return
+	}
+
+	channelId := c.Param("channel_id")
+	channelType := wkutil.ParseUint8(c.Param("channel_type"))
+
+	// 其他节点同步过来的，只修改本节点
+	if req.Local {
+		s.setChannelDebug(channelId, channelType, req.On)
+		c.ResponseOK()
+		return
+	}
+
+	cfg, err := s.loadOnlyChannelClusterConfig(channelId, channelType)
+	if err != nil {
+		s.Error("loadOnlyChannelClusterConfig error", zap.Error(err))
+		c.ResponseError(err)
+		return
+	}
+
+	timeoutCtx, cancel := context.WithTimeout(s.cancelCtx, s.opts.ReqTimeout)
+	defer cancel()
+	requestGroup, _ := errgroup.WithContext(timeoutCtx)
+	headers := c.CopyRequestHeader(c.Request)
+	for _, nodeId := range channelDebugNodes(cfg, s.opts.NodeId) {
+		if nodeId == s.opts.NodeId {
+			continue
+		}
+		requestGroup.Go(func(nId uint64) func() error {
+			return func() error {
+				return s.requestChannelDebug(nId, channelId, channelType, req.On, headers)
+			}
+		}(nodeId))
+	}
+	s.setChannelDebug(channelId, channelType, req.On)
+	if err := requestGroup.Wait(); err != nil {
+		s.Error("requestChannelDebug error", zap.Error(err), zap.String("channelId", channelId), zap.Uint8("channelType", channelType))
+		c.ResponseError(err)
+		return
+	}
+	c.ResponseOK()
+}
+
+type channelDebugReq struct {
+	On    bool `json:"on"`
+	Local bool `json:"local"` // 只修改接收请求的节点（同步给副本节点时使用）
+}
+
+// channelDebugNodes 需要同步调试开关的节点：频道的所有副本和学习者，以及当前节点
+func channelDebugNodes(cfg wkdb.ChannelClusterConfig, nodeId uint64) []uint64 {
+	nodeIds := make([]uint64, 0, len(cfg.Replicas)+len(cfg.Learners)+1)
+	nodeIds = append(nodeIds, nodeId)
+	for _, id := range cfg.Replicas {
+		if !wkutil.ArrayContainsUint64(nodeIds, id) {
+			nodeIds = append(nodeIds, id)
+		}
+	}
+	for _, id := range cfg.Learners {
+		if !wkutil.ArrayContainsUint64(nodeIds, id) {
+			nodeIds = append(nodeIds, id)
+		}
+	}
+	return nodeIds
+}
+
+func (s *Server) setChannelDebug(channelId string, channelType uint8, on bool) {
+	if on {
+		trace.GlobalTrace.Metrics.Cluster().ChannelDebugOn(channelId, channelType)
+	} else {
+		trace.GlobalTrace.Metrics.Cluster().ChannelDebugOff(channelId, channelType)
+	}
+	s.Info("channel debug metrics changed", zap.String("channelId", channelId), zap.Uint8("channelType", channelType), zap.Bool("on", on))
+}
+
+func (s *Server) requestChannelDebug(nodeId uint64, channelId string, channelType uint8, on bool, headers map[string]string) error {
+	node := s.clusterEventServer.Node(nodeId)
+	if node == nil {
+		s.Error("requestChannelDebug failed, node not found", zap.Uint64("nodeId", nodeId))
+		return errors.New("node not found")
+	}
+	fullUrl := fmt.Sprintf("%s%s", node.ApiServerAddr, s.formatPath(fmt.Sprintf("/channels/%s/%d/debug", channelId, channelType)))
+	resp, err := network.Post(fullUrl, []byte(wkutil.ToJSON(channelDebugReq{On: on, Local: true})), headers)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("requestChannelDebug failed, nodeId: %d, status code: %d", nodeId, resp.StatusCode)
+	}
+	return nil
+}
+
+func (s *Server) debugChannelsGet(c *wkhttp.Context) {
+	if !s.opts.Auth.HasPermissionWithContext(c, resource.ClusterChannel.Debug, auth.ActionRead) {
+		c.ResponseStatus(http.StatusUnauthorized)
+		return
+	}
+	c.JSON(http.StatusOK, trace.GlobalTrace.Metrics.Cluster().ChannelDebugChannels())
+}
+
+func (s *Server) channelIndexesGet(c *wkhttp.Context) {
+	channelType := wkutil.ParseUint8(c.Query("channel_type")) // 按频道类型过滤，0表示不过滤
+
+	var filter func(handleKey string) bool
+	if channelType != 0 {
+		filter = func(handleKey string) bool {
+			_, chType := wkutil.ChannelFromlKey(handleKey)
+			return chType == channelType
+		}
+	}
+	infos := s.channelManager.channelReactor.IndexInfos(filter)
+	resps := make([]*channelIndexResp, 0, len(infos))
+	for _, info := range infos {
+		resps = append(resps, newChannelIndexResp(info))
+	}
+	c.JSON(http.StatusOK, resps)
+}
+
+func (s *Server) idleSweepGet(c *wkhttp.Context) {
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"node_id":  s.opts.NodeId,
+		"paused":   s.IdleSweepPaused(),
+		"channels": s.channelManager.channelReactor.HandlerLen(),
+	})
+}
+
+func (s *Server) idleSweepSet(c *wkhttp.Context) {
+	if !s.opts.Auth.HasPermissionWithContext(c, resource.ClusterChannel.IdleSweep, auth.ActionWrite) {
+		c.ResponseStatus(http.StatusUnauthorized)
+		return
+	}
+	var req struct {
+		Paused bool `json:"paused"`
+	}
+	if err := c.BindJSON(&req); err != nil {
+		s.Error("BindJSON error", zap.Error(err))
+		c.ResponseError(err)
+		return
+	}
+	if req.Paused {
+		s.PauseIdleSweep()
+	} else {
+		s.ResumeIdleSweep()
+	}
+	c.ResponseOK()
+}
+
+func (s *Server) electionPauseGet(c *wkhttp.Context) {
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"node_id":           s.opts.NodeId,
+		"paused":            s.electionPaused(),
+		"remaining_seconds": int64(s.ElectionPauseRemaining().Seconds()),
+		"max_seconds":       int64(s.opts.ElectionPauseMaxDuration.Seconds()),
+	})
+}
+
+func (s *Server) electionPauseSet(c *wkhttp.Context) {
+	if !s.opts.Auth.HasPermissionWithContext(c, resource.Cluster.ElectionPause, auth.ActionWrite) {
+		c.ResponseStatus(http.StatusUnauthorized)
+		return
+	}
+	var req struct {
+		Seconds int64 `json:"seconds"` // 暂停的秒数（不超过ElectionPauseMaxDuration），0表示恢复选举
+	}
+	if err := c.BindJSON(&req); err != nil {
+		s.Error("BindJSON error", zap.Error(err))
+		c.ResponseError(err)
+		return
+	}
+	timeoutCtx, cancel := context.WithTimeout(s.cancelCtx, s.opts.ReqTimeout)
+	defer cancel()
+	if err := s.PauseElections(timeoutCtx, time.Duration(req.Seconds)*time.Second); err != nil {
+		s.Error("PauseElections error", zap.Error(err), zap.Int64("seconds", req.Seconds))
+		c.ResponseError(err)
+		return
 	}
 	c.ResponseOK()
 }
 
+func (s *Server) electionStuckChannelsGet(c *wkhttp.Context) {
+	c.JSON(http.StatusOK, s.electionStuck.list())
+}
+
 func (s *Server) channelStatus(c *wkhttp.Context) {
 	var req struct {
 		Channels []channelBase `json:"channels"`
@@ -2165,10 +2517,87 @@ func (s *Server) channelLocalReplica(c *wkhttp.Context) {
 		LastMsgSeq:  lastMsgSeq,
 		LastMsgTime: lastTime,
 	}
+	if running {
+		lagInfo, ok := s.channelManager.channelReactor.ApplyLag(wkutil.ChannelToKey(channelId, channelType))
+		if ok {
+			resp.CommittedIndex = lagInfo.CommittedIndex
+			resp.AppliedIndex = lagInfo.AppliedIndex
+			resp.ApplyLag = lagInfo.Lag
+			resp.ProposeThrottled = wkutil.BoolToInt(lagInfo.Throttled)
+		}
+	}
 
 	c.JSON(http.StatusOK, resp)
 }
 
+func (s *Server) channelLocalEvents(c *wkhttp.Context) {
+	channelId := c.Param("channel_id")
+	channelType := wkutil.ParseUint8(c.Param("channel_type"))
+
+	c.JSON(http.StatusOK, s.channelManager.events(channelId, channelType))
+}
+
+func (s *Server) channelReactorSub(c *wkhttp.Context) {
+	channelId := c.Param("channel_id")
+	channelType := wkutil.ParseUint8(c.Param("channel_type"))
+
+	info := s.channelManager.channelReactor.SubInfo(wkutil.ChannelToKey(channelId, channelType))
+	c.JSON(http.StatusOK, &channelReactorSubResp{
+		channelBase: channelBase{
+			ChannelId:   channelId,
+			ChannelType: channelType,
+		},
+		SubIndex:        info.SubIndex,
+		Exist:           info.Exist,
+		HandlerCount:    info.HandlerCount,
+		StepQueueLen:    info.StepQueueLen,
+		ProposeQueueLen: info.ProposeQueueLen,
+	})
+}
+
+func (s *Server) channelDebugState(c *wkhttp.Context) {
+	channelId := c.Param("channel_id")
+	channelType := wkutil.ParseUint8(c.Param("channel_type"))
+
+	state, ok := s.channelManager.debugState(channelId, channelType)
+	if !ok {
+		c.ResponseError(ErrChannelNotFound)
+		return
+	}
+	c.JSON(http.StatusOK, state)
+}
+
+type channelReactorSubResp struct {
+	channelBase
+	SubIndex        int  `json:"sub_index"`         // 所属reactor sub的下标
+	Exist           bool `json:"exist"`             // 频道是否在本节点运行（不在时sub_index是频道将会被分配到的sub）
+	HandlerCount    int  `json:"handler_count"`     // sub上的频道数量
+	StepQueueLen    int  `json:"step_queue_len"`    // sub待处理的消息数量
+	ProposeQueueLen int  `json:"propose_queue_len"` // sub待处理的提案数量
+}
+
+type channelIndexResp struct {
+	channelBase
+	CommittedIndex uint64 `json:"committed_index"` // 已提交的日志下标
+	AppliedIndex   uint64 `json:"applied_index"`   // 已应用的日志下标
+	LastLogIndex   uint64 `json:"last_log_index"`  // 最新日志下标
+	Degraded       bool   `json:"degraded"`        // 是否处于异常状态
+}
+
+func newChannelIndexResp(info reactor.IndexInfo) *channelIndexResp {
+	channelId, channelType := wkutil.ChannelFromlKey(info.HandleKey)
+	return &channelIndexResp{
+		channelBase: channelBase{
+			ChannelId:   channelId,
+			ChannelType: channelType,
+		},
+		CommittedIndex: info.CommittedIndex,
+		AppliedIndex:   info.AppliedIndex,
+		LastLogIndex:   info.LastLogIndex,
+		Degraded:       info.Degraded,
+	}
+}
+
 type channelBase struct {
 	ChannelId   string `json:"channel_id"`
 	ChannelType uint8  `json:"channel_type"`
@@ -2182,10 +2611,14 @@ type channelStatusResp struct {
 }
 
 type channelReplicaResp struct {
-	ReplicaId   uint64 `json:"replica_id"`    // 副本节点id
-	Running     int    `json:"running"`       // 是否运行中
-	LastMsgSeq  uint64 `json:"last_msg_seq"`  // 最新消息序号
-	LastMsgTime uint64 `json:"last_msg_time"` // 最新消息时间
+	ReplicaId        uint64 `json:"replica_id"`        // 副本节点id
+	Running          int    `json:"running"`           // 是否运行中
+	LastMsgSeq       uint64 `json:"last_msg_seq"`      // 最新消息序号
+	LastMsgTime      uint64 `json:"last_msg_time"`     // 最新消息时间
+	CommittedIndex   uint64 `json:"committed_index"`   // 已提交的日志下标
+	AppliedIndex     uint64 `json:"applied_index"`     // 已应用的日志下标
+	ApplyLag         uint64 `json:"apply_lag"`         // 已提交未应用的日志数量
+	ProposeThrottled int    `json:"propose_throttled"` // 是否因为应用落后暂停了提案
 }
 
 type channelReplicaDetailResp struct {
@@ -10,6 +10,7 @@ import (
 	"github.com/WuKongIM/WuKongIM/pkg/cluster/icluster"
 	"github.com/WuKongIM/WuKongIM/pkg/cluster/reactor"
 	"github.com/WuKongIM/WuKongIM/pkg/cluster/replica"
+	"github.com/WuKongIM/WuKongIM/pkg/trace"
 	"github.com/WuKongIM/WuKongIM/pkg/wkdb"
 	"github.com/WuKongIM/WuKongIM/pkg/wkserver"
 	"github.com/WuKongIM/WuKongIM/pkg/wkserver/proto"
@@ -142,7 +143,20 @@ func (s *Server) ProposeChannelMessages(ctx context.Context, channelId string, c
 	if s.stopped.Load() {
 		return nil, ErrStopped
 	}
+	logs, err := s.applyProposeHook(channelId, channelType, logs)
+	if err != nil {
+		return nil, err
+	}
+	var results []icluster.ProposeResult
+	err = s.retryOnNotLeader(ctx, s.opts.channelProfile(channelType).ProposeRetryOnNotLeader, func() error {
+		var err error
+		results, err = s.proposeChannelMessages(ctx, channelId, channelType, logs)
+		return err
+	})
+	return results, err
+}
 
+func (s *Server) proposeChannelMessages(ctx context.Context, channelId string, channelType uint8, logs []replica.Log) ([]icluster.ProposeResult, error) {
 	// 加载或创建频道
 	ch, err := s.loadOrCreateChannel(ctx, channelId, channelType)
 	if err != nil {
@@ -153,7 +167,7 @@ func (s *Server) ProposeChannelMessages(ctx context.Context, channelId string, c
 		results []reactor.ProposeResult
 	)
 	if !ch.isLeader() { // 如果当前节点不是频道的领导者，向频道的领导者发送提案请求
-		resp, err := s.requestChannelProposeMessage(ch.leaderId(), channelId, channelType, logs)
+		resp, err := s.requestChannelProposeMessage(ctx, ch.leaderId(), channelId, channelType, logs)
 		if err != nil {
 			return nil, err
 		}
@@ -172,7 +186,71 @@ func (s *Server) ProposeChannelMessages(ctx context.Context, channelId string, c
 	return iresults, nil
 }
 
+// ProposeChannelMessagesWithResults 批量提交消息到指定的channel，返回每条消息的提案结果（和logs一一对应）
+// 不能提案的消息（空数据、超过最大消息大小）单独失败，其余的消息一起提交或一起失败，这一批提案失败时返回它的错误
+func (s *Server) ProposeChannelMessagesWithResults(ctx context.Context, channelId string, channelType uint8, logs []replica.Log) ([]icluster.ProposeEntryResult, error) {
+	if s.stopped.Load() {
+		return nil, ErrStopped
+	}
+	logs, err := s.applyProposeHook(channelId, channelType, logs)
+	if err != nil {
+		return nil, err
+	}
+	var results []icluster.ProposeEntryResult
+	err = s.retryOnNotLeader(ctx, s.opts.channelProfile(channelType).ProposeRetryOnNotLeader, func() error {
+		var err error
+		results, err = s.proposeChannelMessagesWithResults(ctx, channelId, channelType, logs)
+		return err
+	})
+	return results, err
+}
+
+func (s *Server) proposeChannelMessagesWithResults(ctx context.Context, channelId string, channelType uint8, logs []replica.Log) ([]icluster.ProposeEntryResult, error) {
+	// 加载或创建频道
+	ch, err := s.loadOrCreateChannel(ctx, channelId, channelType)
+	if err != nil {
+		return nil, err
+	}
+	if ch.isLeader() { // 如果当前节点是频道的领导者，直接提案
+		return ch.proposeLogsWithResults(ctx, logs, 0)
+	}
+	// 如果当前节点不是频道的领导者，向频道的领导者发送提案请求
+	return proposeEntries(logs, s.checkProposeData, func(logs []replica.Log) ([]icluster.ProposeResult, error) {
+		resp, err := s.requestChannelProposeMessage(ctx, ch.leaderId(), channelId, channelType, logs)
+		if err != nil {
+			return nil, err
+		}
+		iresults := make([]icluster.ProposeResult, len(resp.ProposeResults))
+		for i, result := range resp.ProposeResults {
+			iresults[i] = result
+		}
+		return iresults, nil
+	})
+}
+
+// checkProposeData 检查单条日志是否可以提案
+func (s *Server) checkProposeData(lg replica.Log) error {
+	if len(lg.Data) == 0 {
+		return reactor.ErrEmptyPayload
+	}
+	// 开启了blob存储时大数据会转存到blob，不受最大消息大小限制
+	if s.blobStore != nil {
+		return nil
+	}
+	return reactor.CheckLogSize(s.opts.MaxMessageSize, lg)
+}
+
 func (s *Server) ProposeToSlot(ctx context.Context, slotId uint32, logs []replica.Log) ([]icluster.ProposeResult, error) {
+	var results []icluster.ProposeResult
+	err := s.retryDuringSlotTransfer(ctx, slotId, func() error {
+		var err error
+		results, err = s.proposeToSlot(ctx, slotId, logs)
+		return err
+	})
+	return results, err
+}
+
+func (s *Server) proposeToSlot(ctx context.Context, slotId uint32, logs []replica.Log) ([]icluster.ProposeResult, error) {
 
 	slot := s.clusterEventServer.Slot(slotId)
 	if slot == nil {
@@ -197,6 +275,8 @@ func (s *Server) ProposeToSlot(ctx context.Context, slotId uint32, logs []replic
 		if err != nil {
 			return nil, err
 		}
+		// 通过新领导提案成功，说明新领导已经接管，旧领导不再用本地数据提供读取
+		s.leaderTransfers.end(slotId)
 		results = resp.ProposeResults
 	} else {
 		results, err = s.slotManager.proposeAndWait(ctx, slotId, logs)
@@ -258,11 +338,19 @@ func (s *Server) loadOrCreateChannelClusterConfig(ctx context.Context, channelId
 	s.channelKeyLock.Lock(channelId)
 	defer s.channelKeyLock.Unlock(channelId)
 
-	return s.loadOrCreateChannelClusterConfigNoLock(ctx, channelId, channelType)
+	return s.loadOrCreateChannelClusterConfigNoLock(ctx, channelId, channelType, s.opts.NodeId)
 }
 
-// 加载或创建频道分布式配置
-func (s *Server) loadOrCreateChannelClusterConfigNoLock(ctx context.Context, channelId string, channelType uint8) (wkdb.ChannelClusterConfig, bool, error) {
+// loadOrCreateChannelClusterConfigFrom 加载或创建节点requester请求的频道分布式配置（requester传给InitialLeaderHint）
+func (s *Server) loadOrCreateChannelClusterConfigFrom(ctx context.Context, channelId string, channelType uint8, requester uint64) (wkdb.ChannelClusterConfig, bool, error) {
+	s.channelKeyLock.Lock(channelId)
+	defer s.channelKeyLock.Unlock(channelId)
+
+	return s.loadOrCreateChannelClusterConfigNoLock(ctx, channelId, channelType, requester)
+}
+
+// 加载或创建频道分布式配置，requester为请求配置的节点
+func (s *Server) loadOrCreateChannelClusterConfigNoLock(ctx context.Context, channelId string, channelType uint8, requester uint64) (wkdb.ChannelClusterConfig, bool, error) {
 
 	// s.Info("======================loadOrCreateChannelClusterConfigNoLock start======================", zap.String("channelId", channelId), zap.Uint8("channelType", channelType))
 
@@ -344,7 +432,7 @@ func (s *Server) loadOrCreateChannelClusterConfigNoLock(ctx context.Context, cha
 	// 如果频道的分布式配置不存在，则创建一个新的分布式配置
 	if err == wkdb.ErrNotFound {
 		s.Debug("create channel cluster config", zap.String("channelId", channelId), zap.Uint8("channelType", channelType))
-		clusterCfg, err = s.createChannelClusterConfig(channelId, channelType)
+		clusterCfg, err = s.createChannelClusterConfig(channelId, channelType, requester)
 		if err != nil {
 			s.Error("createChannelClusterConfig failed", zap.Error(err), zap.String("channelId", channelId), zap.Uint8("channelType", channelType))
 			return wkdb.EmptyChannelClusterConfig, false, err
@@ -365,6 +453,9 @@ func (s *Server) loadOrCreateChannelClusterConfigNoLock(ctx context.Context, cha
 			return wkdb.EmptyChannelClusterConfig, needProposeCfg, ErrEmptyChannelClusterConfig
 		}
 		needProposeCfg = true
+	} else {
+		// 领导在线，之前的选举失败（比如由其他槽领导选出了领导）不再算卡住
+		s.electionStuck.succeeded(channelId, channelType)
 	}
 
 	if wkdb.IsEmptyChannelClusterConfig(clusterCfg) {
@@ -450,7 +541,18 @@ func (s *Server) loadOrCreateChannel(ctx context.Context, channelId string, chan
 		}
 	}()
 
-	clusterCfg, changed, err := s.loadOrCreateChannelClusterConfigNoLock(ctx, channelId, channelType)
+	// 新频道需要经过创建限速
+	if s.channelCreateLimiter != nil && s.channelManager.get(channelId, channelType) == nil {
+		if err := s.channelCreateLimiter.wait(ctx, s.opts.ChannelCreateMaxWait); err != nil {
+			if err == ErrChannelCreateRateLimited {
+				trace.GlobalTrace.Metrics.Cluster().ChannelCreateRejectedCountAdd(1)
+			}
+			s.Warn("create channel limited", zap.Error(err), zap.String("channelId", channelId), zap.Uint8("channelType", channelType))
+			return nil, err
+		}
+	}
+
+	clusterCfg, changed, err := s.loadOrCreateChannelClusterConfigNoLock(ctx, channelId, channelType, s.opts.NodeId)
 	if err != nil {
 		s.Error("loadOrCreateChannelClusterConfig failed", zap.Error(err), zap.String("channelId", channelId), zap.Uint8("channelType", channelType))
 		return nil, err
@@ -463,6 +565,7 @@ func (s *Server) loadOrCreateChannel(ctx context.Context, channelId string, chan
 	if channelHandler == nil {
 		ch = newChannel(channelId, channelType, s)
 		s.channelManager.add(ch)
+		trace.GlobalTrace.Metrics.Cluster().ChannelCreateCountAdd(1)
 		switchCfg = true
 	} else {
 		ch = channelHandler.(*channel)
@@ -489,12 +592,122 @@ func (s *Server) loadOrCreateChannel(ctx context.Context, channelId string, chan
 	return ch, nil
 }
 
+// PauseIdleSweep 暂停本节点空闲频道的回收，暂停期间频道不会因为空闲被销毁（批量导入大量频道前调用，避免频道被反复销毁和重建）
+func (s *Server) PauseIdleSweep() {
+	s.channelManager.channelReactor.PauseIdleSweep()
+}
+
+// ResumeIdleSweep 恢复本节点空闲频道的回收
+func (s *Server) ResumeIdleSweep() {
+	s.channelManager.channelReactor.ResumeIdleSweep()
+}
+
+// IdleSweepPaused 本节点空闲频道的回收是否已暂停
+func (s *Server) IdleSweepPaused() bool {
+	return s.channelManager.channelReactor.IdleSweepPaused()
+}
+
+// RebuildChannel 清空本节点频道的日志和已应用下标，然后重新从领导安装快照并同步（本地数据损坏时使用，不能在领导节点上执行）
+// 追上重建开始时领导的最后日志之前，频道处于恢复状态（见ChannelDebugState.Recovering）
+func (s *Server) RebuildChannel(ctx context.Context, channelId string, channelType uint8) error {
+	if s.stopped.Load() {
+		return ErrStopped
+	}
+	cfg, err := s.loadOnlyChannelClusterConfig(channelId, channelType)
+	if err != nil {
+		return err
+	}
+	if cfg.LeaderId == 0 {
+		return ErrNoLeader
+	}
+	if cfg.LeaderId == s.opts.NodeId {
+		return ErrRebuildOnLeader
+	}
+	if !wkutil.ArrayContainsUint64(cfg.Replicas, s.opts.NodeId) && !wkutil.ArrayContainsUint64(cfg.Learners, s.opts.NodeId) {
+		return ErrNotChannelReplica
+	}
+	// 恢复要追上的日志下标（重建开始时领导的最后日志下标）
+	target, err := s.requestChannelLastLogIndex(ctx, cfg.LeaderId, channelId, channelType)
+	if err != nil {
+		s.Error("rebuild channel: get leader last log index failed", zap.Error(err), zap.String("channelId", channelId), zap.Uint8("channelType", channelType), zap.Uint64("leaderId", cfg.LeaderId))
+		return err
+	}
+
+	s.channelKeyLock.Lock(channelId)
+	handler := s.channelManager.get(channelId, channelType)
+	if handler != nil {
+		s.channelManager.remove(handler.(*channel))
+	}
+	handleKey := wkutil.ChannelToKey(channelId, channelType)
+	err = resetShardLogs(s.opts.MessageLogStorage, handleKey)
+	if err == nil {
+		s.channelManager.removeSnapshotFile(handleKey)
+	}
+	s.channelKeyLock.Unlock(channelId)
+	if err != nil {
+		s.Error("rebuild channel: reset logs failed", zap.Error(err), zap.String("channelId", channelId), zap.Uint8("channelType", channelType))
+		return err
+	}
+
+	s.Info("rebuild channel: local logs discarded, recovering from leader", zap.String("channelId", channelId), zap.Uint8("channelType", channelType), zap.Uint64("leaderId", cfg.LeaderId))
+
+	// 重新创建频道，先从领导安装快照，剩下的日志由副本正常同步，追上target前频道处于恢复状态
+	ch, err := s.loadOrCreateChannel(ctx, channelId, channelType)
+	if err != nil {
+		s.Error("rebuild channel: loadOrCreateChannel failed", zap.Error(err), zap.String("channelId", channelId), zap.Uint8("channelType", channelType))
+		return err
+	}
+	ch.beginRecover(target)
+	if err = s.InstallChannelSnapshot(ctx, channelId, channelType); err != nil {
+		// 安装快照失败不影响恢复，副本会逐条同步领导的日志
+		s.Warn("rebuild channel: install snapshot failed, recover by sync", zap.Error(err), zap.String("channelId", channelId), zap.Uint8("channelType", channelType))
+	}
+	return nil
+}
+
+// requestChannelLastLogIndex 获取频道在节点nodeId上存储的最后日志下标
+func (s *Server) requestChannelLastLogIndex(ctx context.Context, nodeId uint64, channelId string, channelType uint8) (uint64, error) {
+	node := s.nodeManager.node(nodeId)
+	if node == nil {
+		return 0, ErrNodeNotFound
+	}
+	resps, err := node.requestChannelLastLogInfo(ctx, ChannelLastLogInfoReqSet{{ChannelId: channelId, ChannelType: channelType}})
+	if err != nil {
+		return 0, err
+	}
+	for _, resp := range resps {
+		if resp.ChannelId == channelId && resp.ChannelType == channelType {
+			return resp.LogIndex, nil
+		}
+	}
+	return 0, ErrChannelNotFound
+}
+
+// 清空分区的日志、已应用下标和领导任期记录（先重置已应用下标，截断日志不允许截断已应用的日志）
+func resetShardLogs(storage IShardLogStorage, shardNo string) error {
+	err := storage.SetAppliedIndex(shardNo, 0)
+	if err != nil {
+		return err
+	}
+	err = storage.TruncateLogTo(shardNo, 1)
+	if err != nil {
+		return err
+	}
+	return storage.DeleteLeaderTermStartIndexGreaterThanTerm(shardNo, 0)
+}
+
 // 创建一个频道的分布式配置
-func (s *Server) createChannelClusterConfig(channelId string, channelType uint8) (wkdb.ChannelClusterConfig, error) {
+func (s *Server) createChannelClusterConfig(channelId string, channelType uint8, requester uint64) (wkdb.ChannelClusterConfig, error) {
 	allowVoteNodes := s.clusterEventServer.AllowVoteAndJoinedNodes() // 获取允许投票的在线节点
 	if len(allowVoteNodes) == 0 {
 		return wkdb.EmptyChannelClusterConfig, ErrNoAllowVoteNode
 	}
+	return s.newChannelClusterConfig(channelId, channelType, requester, allowVoteNodes), nil
+}
+
+// 根据允许投票的节点生成一个新的频道分布式配置，requester为请求创建配置的节点
+func (s *Server) newChannelClusterConfig(channelId string, channelType uint8, requester uint64, allowVoteNodes []*pb.Node) wkdb.ChannelClusterConfig {
+	leaderId := s.initialChannelLeader(channelId, channelType, requester, allowVoteNodes)
 
 	createdAt := time.Now()
 	updatedAt := time.Now()
@@ -503,12 +716,12 @@ func (s *Server) createChannelClusterConfig(channelId string, channelType uint8)
 		ChannelType:     channelType,
 		ReplicaMaxCount: uint16(s.opts.ChannelMaxReplicaCount),
 		Term:            1,
-		LeaderId:        s.opts.NodeId,
+		LeaderId:        leaderId,
 		CreatedAt:       &createdAt,
 		UpdatedAt:       &updatedAt,
 	}
 	replicaIds := make([]uint64, 0, s.opts.ChannelMaxReplicaCount)
-	replicaIds = append(replicaIds, s.opts.NodeId) // 默认当前节点是领导，所以加入到副本列表中
+	replicaIds = append(replicaIds, leaderId) // 领导必须在副本列表中
 
 	// 随机选择副本
 	newAllowVoteNodes := make([]*pb.Node, 0, len(allowVoteNodes))
@@ -517,8 +730,22 @@ func (s *Server) createChannelClusterConfig(channelId string, channelType uint8)
 		newAllowVoteNodes[i], newAllowVoteNodes[j] = newAllowVoteNodes[j], newAllowVoteNodes[i]
 	})
 
+	if s.opts.ReplicaSetPolicy == ReplicaSetPolicySpreadDomains {
+		candidates := make([]uint64, 0, len(newAllowVoteNodes)+1)
+		candidates = append(candidates, s.opts.NodeId) // 当前节点是槽领导，同一个故障域里优先作为副本
+		for _, allowVoteNode := range newAllowVoteNodes {
+			candidates = append(candidates, allowVoteNode.Id)
+		}
+		clusterConfig.Replicas = spreadReplicas(replicaIds, candidates, s.opts.ChannelMaxReplicaCount, s.opts.nodeDomain)
+		return clusterConfig
+	}
+
+	if leaderId != s.opts.NodeId && s.opts.ChannelMaxReplicaCount > 1 {
+		replicaIds = append(replicaIds, s.opts.NodeId) // 当前节点是槽领导，优先作为副本
+	}
+
 	for _, allowVoteNode := range newAllowVoteNodes {
-		if allowVoteNode.Id == s.opts.NodeId {
+		if wkutil.ArrayContainsUint64(replicaIds, allowVoteNode.Id) {
 			continue
 		}
 		if len(replicaIds) >= int(s.opts.ChannelMaxReplicaCount) {
@@ -528,7 +755,25 @@ func (s *Server) createChannelClusterConfig(channelId string, channelType uint8)
 
 	}
 	clusterConfig.Replicas = replicaIds
-	return clusterConfig, nil
+	return clusterConfig
+}
+
+// 新频道的初始领导，如果设置了InitialLeaderHint并且提示的节点是允许投票的在线节点，则直接使用提示的节点作为领导，否则默认当前节点（槽领导）
+func (s *Server) initialChannelLeader(channelId string, channelType uint8, requester uint64, allowVoteNodes []*pb.Node) uint64 {
+	if s.opts.InitialLeaderHint == nil {
+		return s.opts.NodeId
+	}
+	hint := s.opts.InitialLeaderHint(channelId, channelType, requester)
+	if hint == 0 || hint == s.opts.NodeId {
+		return s.opts.NodeId
+	}
+	for _, node := range allowVoteNodes {
+		if node.Id == hint {
+			return hint
+		}
+	}
+	s.Warn("initial leader hint is not allow vote node, fallback to slot leader", zap.Uint64("hint", hint), zap.Uint64("requester", requester), zap.String("channelId", channelId), zap.Uint8("channelType", channelType))
+	return s.opts.NodeId
 }
 
 // func (s *Server) updateClusterConfigIfNeed(clusterCfg wkdb.ChannelClusterConfig) (wkdb.ChannelClusterConfig, bool, error) {
@@ -602,6 +847,18 @@ func (s *Server) electionChannelLeader(ctx context.Context, cfg wkdb.ChannelClus
 		}
 	}()
 
+	// 维护期间暂停选举
+	if s.electionPaused() {
+		return wkdb.EmptyChannelClusterConfig, ErrElectionPaused
+	}
+
+	// 选举卡住的频道，退避期间不再发起选举
+	if err := s.electionStuck.allow(cfg.ChannelId, cfg.ChannelType, time.Now()); err != nil {
+		return wkdb.EmptyChannelClusterConfig, err
+	}
+
+	trace.GlobalTrace.Metrics.Cluster().ChannelElectionCountAdd(cfg.ChannelType, 1)
+
 	resultC := make(chan electionResp, 1)
 	req := electionReq{
 		cfg:     cfg,
@@ -610,19 +867,28 @@ func (s *Server) electionChannelLeader(ctx context.Context, cfg wkdb.ChannelClus
 	// 向选举管理器提交选举请求
 	err := s.channelElectionManager.addElectionReq(req)
 	if err != nil {
+		trace.GlobalTrace.Metrics.Cluster().ChannelElectionFailCountAdd(cfg.ChannelType, 1)
 		return wkdb.EmptyChannelClusterConfig, err
 	}
 
 	select {
 	case resp := <-resultC:
 		if resp.err != nil {
+			trace.GlobalTrace.Metrics.Cluster().ChannelElectionFailCountAdd(cfg.ChannelType, 1)
 			s.Info("electionChannelLeader failed", zap.Error(err), zap.String("channelId", cfg.ChannelId), zap.Uint8("channelType", cfg.ChannelType), zap.Uint64("leaderId", resp.cfg.LeaderId), zap.Uint32("term", resp.cfg.Term))
+			cause := s.electionFailedCause(cfg, resp.err)
+			if s.electionStuck.failed(cfg.ChannelId, cfg.ChannelType, resp.err, cause, time.Now()) {
+				s.Error("channel election stuck, backoff election", zap.String("channelId", cfg.ChannelId), zap.Uint8("channelType", cfg.ChannelType), zap.Error(resp.err), zap.String("suspectedCause", cause), zap.Uint64s("replicas", cfg.Replicas), zap.Duration("threshold", s.opts.ElectionStuckThreshold))
+			}
 		} else {
 			s.Info("electionChannelLeader success", zap.String("channelId", cfg.ChannelId), zap.Uint8("channelType", cfg.ChannelType), zap.Uint64("leaderId", resp.cfg.LeaderId), zap.Uint32("term", resp.cfg.Term))
+			trace.GlobalTrace.Metrics.Cluster().ChannelElectionSuccessCountAdd(cfg.ChannelType, 1)
+			s.electionStuck.succeeded(cfg.ChannelId, cfg.ChannelType)
 		}
 
 		return resp.cfg, resp.err
 	case <-ctx.Done():
+		trace.GlobalTrace.Metrics.Cluster().ChannelElectionFailCountAdd(cfg.ChannelType, 1)
 		return wkdb.EmptyChannelClusterConfig, ctx.Err()
 	case <-s.stopper.ShouldStop():
 		return wkdb.EmptyChannelClusterConfig, ErrStopped
@@ -680,7 +946,8 @@ func (s *Server) loadOnlyChannelClusterConfig(channelId string, channelType uint
 		clusterConfig wkdb.ChannelClusterConfig
 		err           error
 	)
-	if slot.Leader == s.opts.NodeId {
+	// 计划的领导转移期间，旧领导继续用本地已提交的数据提供读取，直到新领导确认
+	if slot.Leader == s.opts.NodeId || s.servingTransferRead(slotId) {
 		clusterConfig, err = s.opts.ChannelClusterStorage.Get(channelId, channelType)
 		if err != nil && err != wkdb.ErrNotFound {
 			return wkdb.EmptyChannelClusterConfig, err
@@ -703,19 +970,30 @@ func (s *Server) loadOnlyChannelClusterConfig(channelId string, channelType uint
 	return clusterConfig, nil
 }
 
-func (s *Server) requestChannelProposeMessage(to uint64, channelId string, channelType uint8, logs []replica.Log) (*ChannelProposeResp, error) {
+// 转发提案的ctx，超时时间为ForwardTimeout，调用方ctx取消或服务停止时也会取消
+func (s *Server) forwardContext(ctx context.Context, proposeTimeout time.Duration) (context.Context, context.CancelFunc) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, s.opts.forwardTimeout(proposeTimeout))
+	stop := context.AfterFunc(s.cancelCtx, cancel)
+	return timeoutCtx, func() {
+		stop()
+		cancel()
+	}
+}
+
+func (s *Server) requestChannelProposeMessage(ctx context.Context, to uint64, channelId string, channelType uint8, logs []replica.Log) (*ChannelProposeResp, error) {
 	node := s.nodeManager.node(to)
 	if node == nil {
 		s.Error("node is not found", zap.Uint64("nodeID", to))
 		return nil, ErrNodeNotFound
 	}
-	timeoutCtx, cancel := context.WithTimeout(s.cancelCtx, s.opts.ReqTimeout)
+	timeoutCtx, cancel := s.forwardContext(ctx, s.opts.channelProfile(channelType).ProposeTimeout)
+	defer cancel()
 	resp, err := node.requestChannelProposeMessage(timeoutCtx, &ChannelProposeReq{
 		ChannelId:   channelId,
 		ChannelType: channelType,
 		Logs:        logs,
+		Priority:    uint8(reactor.ProposeContextPriority(ctx)),
 	})
-	defer cancel()
 	if err != nil {
 		s.Error("requestChannelProposeMessage failed", zap.Error(err), zap.String("channelId", channelId), zap.Uint8("channelType", channelType), zap.Int("logs", len(logs)))
 		return nil, err
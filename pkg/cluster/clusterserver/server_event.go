@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/WuKongIM/WuKongIM/pkg/cluster/clusterconfig/pb"
+	"github.com/WuKongIM/WuKongIM/pkg/trace"
 	"github.com/WuKongIM/WuKongIM/pkg/wkutil"
 	"go.uber.org/zap"
 	"golang.org/x/sync/errgroup"
@@ -30,6 +31,11 @@ func (s *Server) onSlotElection(slots []*pb.Slot) error {
 		s.Info("server stopped")
 		return nil
 	}
+	// 维护期间暂停选举，暂停结束后槽领导仍然离线会再次触发选举
+	if s.electionPaused() {
+		s.Debug("elections paused, skip slot election", zap.Int("slots", len(slots)))
+		return nil
+	}
 	err := s.handleSlotElection(slots)
 	if err != nil {
 		s.Error("handleSlotElection failed", zap.Error(err))
@@ -147,6 +153,18 @@ func (s *Server) handleClusterConfigSlotChange(cfg *pb.Config) error {
 		}
 
 		if !cfgSlot.Equal(slot.st) {
+			if cfgSlot.Leader == s.opts.NodeId && slot.st.Leader != cfgSlot.Leader { // 成为槽领导
+				s.leaderTransfers.end(cfgSlot.Id) // 重新成为领导，之前的转移已经结束
+				s.notifyLeaderChange(LeaderChangeEvent{
+					ShardType:  ShardTypeSlot,
+					SlotId:     cfgSlot.Id,
+					PrevLeader: slot.st.Leader,
+					Leader:     cfgSlot.Leader,
+					Term:       cfgSlot.Term,
+					Reason:     slotLeaderChangeReason(slot.st, cfgSlot),
+					Time:       time.Now(),
+				})
+			}
 			s.addOrUpdateSlot(cfgSlot)
 		}
 	}
@@ -188,6 +206,18 @@ func (s *Server) handleSlotElection(slots []*pb.Slot) error {
 		return nil
 	}
 
+	// 选出了新领导并且提案成功的槽算选举成功，其余的（包括中途出错的）都算选举失败
+	electedCount := 0
+	trace.GlobalTrace.Metrics.Cluster().SlotElectionCountAdd(int64(len(electionSlotIds)))
+	defer func() {
+		if electedCount > 0 {
+			trace.GlobalTrace.Metrics.Cluster().SlotElectionSuccessCountAdd(int64(electedCount))
+		}
+		if failedCount := len(electionSlotIds) - electedCount; failedCount > 0 {
+			trace.GlobalTrace.Metrics.Cluster().SlotElectionFailCountAdd(int64(failedCount))
+		}
+	}()
+
 	// 获取槽在各个副本上的日志信息
 	slotInfoResps, err := s.requestSlotInfos(electionSlotLeaderMap)
 	if err != nil {
@@ -254,6 +284,7 @@ func (s *Server) handleSlotElection(slots []*pb.Slot) error {
 			s.Error("handle slot election failed, propose slot failed", zap.Error(err))
 			return err
 		}
+		electedCount = len(newSlots)
 	}
 	return nil
 }
@@ -9,6 +9,7 @@ import (
 
 	"github.com/WuKongIM/WuKongIM/pkg/cluster/clusterconfig/pb"
 	"github.com/WuKongIM/WuKongIM/pkg/cluster/reactor"
+	"github.com/WuKongIM/WuKongIM/pkg/trace"
 	"github.com/WuKongIM/WuKongIM/pkg/wkserver"
 	"github.com/WuKongIM/WuKongIM/pkg/wkutil"
 	"go.uber.org/zap"
@@ -34,6 +35,35 @@ func (s *Server) setRoutes() {
 
 	// 获取槽日志信息
 	s.netServer.Route("/slot/logInfo", s.handleSlotLogInfo)
+
+	// 获取大日志的blob数据
+	s.netServer.Route("/channel/blob", s.handleBlob)
+
+	// 频道领导请求让出领导（例如磁盘空间不足）
+	s.netServer.Route("/channel/leaderStepDown", s.handleChannelLeaderStepDown)
+	s.netServer.Route("/cluster/electionPause", s.handleElectionPause)
+
+	// 远远落后的副本从频道领导拉取快照
+	s.netServer.Route("/channel/snapshot", s.handleChannelSnapshot)
+	// 频道领导通知落后到已压缩日志之前的副本安装快照
+	s.netServer.Route("/channel/installSnapshot", s.handleChannelInstallSnapshot)
+}
+
+func (s *Server) handleBlob(c *wkserver.Context) {
+	req := &BlobReq{}
+	err := req.Unmarshal(c.Body())
+	if err != nil {
+		s.Error("unmarshal BlobReq failed", zap.Error(err))
+		c.WriteErr(err)
+		return
+	}
+	data, err := s.loadBlob(req.HandleKey, req.Index, req.Hash)
+	if err != nil {
+		s.Error("loadBlob failed", zap.Error(err), zap.String("handleKey", req.HandleKey), zap.Uint64("index", req.Index))
+		c.WriteErr(err)
+		return
+	}
+	c.Write(data)
 }
 
 func (s *Server) handleChannelLastLogInfo(c *wkserver.Context) {
@@ -121,9 +151,16 @@ func (s *Server) handleClusterconfig(c *wkserver.Context) {
 		return
 	}
 
+	from, err := s.getFrom(c)
+	if err != nil {
+		s.Error("handleClusterconfig: get from node failed", zap.Error(err))
+		c.WriteErr(err)
+		return
+	}
+
 	timeoutCtx, cancel := context.WithTimeout(s.cancelCtx, s.opts.ReqTimeout)
 	defer cancel()
-	clusterConfig, _, err := s.loadOrCreateChannelClusterConfig(timeoutCtx, req.ChannelId, req.ChannelType)
+	clusterConfig, _, err := s.loadOrCreateChannelClusterConfigFrom(timeoutCtx, req.ChannelId, req.ChannelType, from)
 	if err != nil {
 		s.Error("handleClusterconfig: loadOrCreateChannelClusterConfig failed", zap.Error(err))
 		c.WriteErr(err)
@@ -161,29 +198,33 @@ func (s *Server) handleProposeMessage(c *wkserver.Context) {
 	// 获取频道集群
 	ch, err := s.loadOrCreateChannel(s.cancelCtx, req.ChannelId, req.ChannelType)
 	if err != nil {
-		s.Error("fetchChannel failed", zap.Error(err))
+		s.Error("fetchChannel failed", channelLogFields(req.ChannelId, req.ChannelType, zap.Error(err))...)
 		c.WriteErr(err)
 		return
 	}
 	if ch == nil {
-		s.Error("channel not found", zap.String("channelId", req.ChannelId), zap.Uint8("channelType", req.ChannelType))
+		s.Error("channel not found", channelLogFields(req.ChannelId, req.ChannelType)...)
 		c.WriteErr(ErrChannelNotFound)
 		return
 	}
 
 	if !ch.isLeader() {
 		if ch.leaderId() == from {
-			s.Error("leaderId is from,handleProposeMessage failed", zap.Uint64("leaderId", ch.leaderId()), zap.Uint64("from", from))
+			s.Error("leaderId is from,handleProposeMessage failed", ch.logFields(zap.Uint64("from", from))...)
 			c.WriteErr(errors.New("leaderId is from"))
 			return
 		}
-		s.Error("not is leader,handleProposeMessage failed", zap.Uint64("leaderId", ch.leaderId()), zap.String("channelId", req.ChannelId), zap.Uint8("channelType", req.ChannelType))
+		s.Error("not is leader,handleProposeMessage failed", ch.logFields()...)
 		c.WriteErr(ErrOldChannelClusterConfig)
 		return
 	}
-	results, err := s.channelManager.proposeAndWait(s.cancelCtx, req.ChannelId, req.ChannelType, req.Logs)
+	ctx := s.cancelCtx
+	if reactor.ProposePriority(req.Priority) == reactor.ProposePriorityHigh { // 转发的节点要求的优先级
+		ctx = reactor.WithProposePriority(ctx, reactor.ProposePriorityHigh)
+	}
+	results, err := s.channelManager.proposeAndWait(ctx, req.ChannelId, req.ChannelType, req.Logs)
 	if err != nil {
-		s.Error("proposeAndWait failed", zap.Error(err))
+		s.Error("proposeAndWait failed", ch.logFields(zap.Error(err), zap.Int("logCount", len(req.Logs)))...)
 		c.WriteErr(err)
 		return
 	}
@@ -310,6 +351,9 @@ func (s *Server) handleClusterJoin(c *wkserver.Context) {
 }
 
 func (s *Server) handleSlotLeaderTermStartIndex(c *wkserver.Context) {
+	trace.GlobalTrace.Metrics.Cluster().MsgLeaderTermStartIndexReqIncomingCountAdd(trace.ClusterKindSlot, 1)
+	trace.GlobalTrace.Metrics.Cluster().MsgLeaderTermStartIndexReqIncomingBytesAdd(trace.ClusterKindSlot, int64(len(c.Body())))
+
 	req := &reactor.LeaderTermStartIndexReq{}
 	err := req.Unmarshal(c.Body())
 	if err != nil {
@@ -347,10 +391,15 @@ func (s *Server) handleSlotLeaderTermStartIndex(c *wkserver.Context) {
 		}
 		binary.BigEndian.PutUint64(resultBytes, lastIndex)
 	}
+	trace.GlobalTrace.Metrics.Cluster().MsgLeaderTermStartIndexRespOutgoingCountAdd(trace.ClusterKindSlot, 1)
+	trace.GlobalTrace.Metrics.Cluster().MsgLeaderTermStartIndexRespOutgoingBytesAdd(trace.ClusterKindSlot, int64(len(resultBytes)))
 	c.Write(resultBytes)
 }
 
 func (s *Server) handleChannelLeaderTermStartIndex(c *wkserver.Context) {
+	trace.GlobalTrace.Metrics.Cluster().MsgLeaderTermStartIndexReqIncomingCountAdd(trace.ClusterKindChannel, 1)
+	trace.GlobalTrace.Metrics.Cluster().MsgLeaderTermStartIndexReqIncomingBytesAdd(trace.ClusterKindChannel, int64(len(c.Body())))
+
 	req := &reactor.LeaderTermStartIndexReq{}
 	err := req.Unmarshal(c.Body())
 	if err != nil {
@@ -388,6 +437,8 @@ func (s *Server) handleChannelLeaderTermStartIndex(c *wkserver.Context) {
 		}
 		binary.BigEndian.PutUint64(resultBytes, lastIndex)
 	}
+	trace.GlobalTrace.Metrics.Cluster().MsgLeaderTermStartIndexRespOutgoingCountAdd(trace.ClusterKindChannel, 1)
+	trace.GlobalTrace.Metrics.Cluster().MsgLeaderTermStartIndexRespOutgoingBytesAdd(trace.ClusterKindChannel, int64(len(resultBytes)))
 	c.Write(resultBytes)
 }
 
@@ -164,6 +164,7 @@ func (s *slot) ApplyLogs(startIndex, endIndex uint64) (uint64, error) {
 		for _, log := range logs {
 			appliedSize += uint64(log.LogSize())
 		}
+		s.s.slotManager.slotReactor.FillProposeValues(s.key, logs)
 
 		err = s.opts.OnSlotApply(s.st.Id, logs)
 		if err != nil {
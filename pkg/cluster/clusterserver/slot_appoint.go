@@ -0,0 +1,55 @@
+package cluster
+
+import (
+	"sync"
+)
+
+// 手动指定槽领导（TransferSlotLeader）的仲裁
+// 同一个槽同一个任期内可能同时收到多个指定不同目标节点的请求，如果都去提案迁移会导致谁是领导不明确。
+// 这里每个槽同一任期只允许一个指定生效：先到达的请求胜出，执行期间同一任期指定其他节点的请求返回ErrAppointConflict，
+// 指定相同节点的重复请求不再执行；胜出的请求执行结束（不论成功失败）后移除仲裁记录，map里只保留正在执行的指定。
+
+type slotAppoint struct {
+	term uint32
+	to   uint64
+}
+
+type appointArbiter struct {
+	mu       sync.Mutex
+	appoints map[uint32]*slotAppoint
+}
+
+func newAppointArbiter() *appointArbiter {
+	return &appointArbiter{
+		appoints: make(map[uint32]*slotAppoint),
+	}
+}
+
+// arbitrate 仲裁指定请求，返回是否需要由当前请求去执行指定，返回true时执行结束后必须调用finish
+// 相同目标的重复请求返回false和nil（已有请求在执行），输掉仲裁的返回ErrAppointConflict
+func (a *appointArbiter) arbitrate(slotId uint32, term uint32, to uint64) (bool, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	ap := a.appoints[slotId]
+	if ap != nil && ap.term >= term {
+		if ap.term == term && ap.to == to {
+			return false, nil
+		}
+		return false, ErrAppointConflict
+	}
+	a.appoints[slotId] = &slotAppoint{
+		term: term,
+		to:   to,
+	}
+	return true, nil
+}
+
+// finish 胜出的请求执行结束，移除仲裁记录
+func (a *appointArbiter) finish(slotId uint32, term uint32) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	ap := a.appoints[slotId]
+	if ap != nil && ap.term == term {
+		delete(a.appoints, slotId)
+	}
+}
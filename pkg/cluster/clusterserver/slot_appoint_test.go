@@ -0,0 +1,86 @@
+package cluster
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAppointArbiterConcurrentConflict(t *testing.T) {
+	a := newAppointArbiter()
+
+	// 先到达的请求胜出
+	proceed, err := a.arbitrate(1, 5, 4)
+	assert.NoError(t, err)
+	assert.True(t, proceed)
+
+	targets := []uint64{2, 4, 3, 4}
+	proceeds := make([]bool, len(targets))
+	errs := make([]error, len(targets))
+
+	var wg sync.WaitGroup
+	for i, to := range targets {
+		wg.Add(1)
+		go func(i int, to uint64) {
+			defer wg.Done()
+			proceeds[i], errs[i] = a.arbitrate(1, 5, to)
+		}(i, to)
+	}
+	wg.Wait()
+
+	// 执行期间指定其他节点被拒绝，重复指定相同节点不再执行
+	for i, to := range targets {
+		assert.False(t, proceeds[i])
+		if to == 4 {
+			assert.NoError(t, errs[i])
+		} else {
+			assert.Equal(t, ErrAppointConflict, errs[i])
+		}
+	}
+
+	// 旧任期被拒绝
+	_, err = a.arbitrate(1, 4, 2)
+	assert.Equal(t, ErrAppointConflict, err)
+
+	// 执行结束后移除仲裁记录
+	a.finish(1, 5)
+	assert.Empty(t, a.appoints)
+
+	// 新任期可以重新指定
+	proceed, err = a.arbitrate(1, 6, 2)
+	assert.NoError(t, err)
+	assert.True(t, proceed)
+	a.finish(1, 6)
+	assert.Empty(t, a.appoints)
+}
+
+// 同时发起的冲突指定只有一个胜出
+func TestAppointArbiterSingleWinner(t *testing.T) {
+	a := newAppointArbiter()
+
+	targets := []uint64{2, 3, 4, 5}
+	proceeds := make([]bool, len(targets))
+	errs := make([]error, len(targets))
+	var wg sync.WaitGroup
+	for i, to := range targets {
+		wg.Add(1)
+		go func(i int, to uint64) {
+			defer wg.Done()
+			proceeds[i], errs[i] = a.arbitrate(1, 5, to)
+		}(i, to)
+	}
+	wg.Wait()
+
+	winners := 0
+	for i := range targets {
+		if proceeds[i] {
+			winners++
+			assert.NoError(t, errs[i])
+			assert.Equal(t, targets[i], a.appoints[1].to)
+		} else {
+			assert.Equal(t, ErrAppointConflict, errs[i])
+		}
+	}
+	assert.Equal(t, 1, winners)
+}
@@ -7,6 +7,7 @@ import (
 
 	"github.com/WuKongIM/WuKongIM/pkg/cluster/reactor"
 	"github.com/WuKongIM/WuKongIM/pkg/cluster/replica"
+	"github.com/WuKongIM/WuKongIM/pkg/trace"
 	"github.com/WuKongIM/WuKongIM/pkg/wkserver/proto"
 )
 
@@ -32,6 +33,8 @@ func newSlotManager(s *Server) *slotManager {
 		reactor.WithReactorType(reactor.ReactorTypeSlot),
 		reactor.WithRequest(sm),
 		reactor.WithSubReactorNum(s.opts.SlotReactorSubCount),
+		reactor.WithMaxMessageSize(s.opts.MaxMessageSize),
+		reactor.WithProposeResultChannelBuffer(s.opts.ProposeResultChannelBuffer),
 	))
 
 	return sm
@@ -47,6 +50,17 @@ func (s *slotManager) stop() {
 }
 
 func (s *slotManager) proposeAndWait(ctx context.Context, slotId uint32, logs []replica.Log) ([]reactor.ProposeResult, error) {
+	if s.s.proposeLimiter != nil {
+		if err := s.s.proposeLimiter.acquire(ctx, s.opts.ProposeTimeout); err != nil {
+			return nil, err
+		}
+		defer s.s.proposeLimiter.release()
+	}
+	if s.s.writeLimiter != nil {
+		if err := s.s.writeLimiter.waitLogs(ctx, logs, s.opts.ProposeTimeout); err != nil {
+			return nil, err
+		}
+	}
 	return s.slotReactor.ProposeAndWait(ctx, SlotIdToKey(slotId), logs)
 }
 
@@ -119,6 +133,8 @@ func (s *slotManager) GetLeaderTermStartIndex(req reactor.LeaderTermStartIndexRe
 	if req.LeaderId == s.opts.NodeId { // 如果是自己，直接返回0，0表示不需要解决冲突
 		return 0, nil
 	}
+	trace.GlobalTrace.Metrics.Cluster().MsgLeaderTermStartIndexReqOutgoingCountAdd(trace.ClusterKindSlot, 1)
+	trace.GlobalTrace.Metrics.Cluster().MsgLeaderTermStartIndexReqOutgoingBytesAdd(trace.ClusterKindSlot, int64(len(reqBytes)))
 	resp, err := s.request(req.LeaderId, "/slot/leaderTermStartIndex", reqBytes)
 	if err != nil {
 		return 0, err
@@ -126,6 +142,8 @@ func (s *slotManager) GetLeaderTermStartIndex(req reactor.LeaderTermStartIndexRe
 	if resp.Status != proto.Status_OK {
 		return 0, fmt.Errorf("get leader term start index failed, status: %v", resp.Status)
 	}
+	trace.GlobalTrace.Metrics.Cluster().MsgLeaderTermStartIndexRespIncomingCountAdd(trace.ClusterKindSlot, 1)
+	trace.GlobalTrace.Metrics.Cluster().MsgLeaderTermStartIndexRespIncomingBytesAdd(trace.ClusterKindSlot, int64(len(resp.Body)))
 	if len(resp.Body) > 0 {
 		return binary.BigEndian.Uint64(resp.Body), nil
 	}
@@ -134,7 +152,13 @@ func (s *slotManager) GetLeaderTermStartIndex(req reactor.LeaderTermStartIndexRe
 
 func (s *slotManager) AppendLogBatch(reqs []reactor.AppendLogReq) error {
 
-	return s.opts.SlotLogStorage.AppendLogBatch(reqs)
+	if err := s.opts.SlotLogStorage.AppendLogBatch(reqs); err != nil {
+		return err
+	}
+	if s.s.proposeAuditor != nil {
+		s.s.proposeAuditor.record("slot", reqs)
+	}
+	return nil
 }
 
 func (s *slotManager) request(toNodeId uint64, path string, body []byte) (*proto.Response, error) {
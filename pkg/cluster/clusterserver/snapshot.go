@@ -0,0 +1,669 @@
+package cluster
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"os"
+
+	"github.com/WuKongIM/WuKongIM/pkg/cluster/replica"
+)
+
+// 快照格式（所有整数都是大端）:
+//
+//	header:  magic(6) | version(2) | shardNoLen(2) | shardNo | lastIndex(8) | lastTerm(4) | crc32(4)
+//	frame:   type(1) | payloadLen(4) | payload | crc32(4)
+//	         logs帧payload:    logCount(4) | [logLen(4) | log]...
+//	         trailer帧payload: frameCount(4) | logCount(8) | 所有logs帧payload的crc32(4)
+//
+// 快照按帧流式写入和读取，不需要把整个快照放到内存里；每一帧都有自己的校验和，
+// 结尾帧记录了帧数量和整体校验和，用于发现传输中途被截断或损坏的快照。
+
+var (
+	snapshotMagic = []byte{'W', 'K', 'S', 'N', 'A', 'P'}
+)
+
+const (
+	snapshotVersion uint16 = 1
+
+	snapshotFrameTypeLogs    uint8 = 1
+	snapshotFrameTypeTrailer uint8 = 2
+
+	// snapshotMaxFrameSize 单帧最大大小，防止损坏的长度字段导致分配过大的内存
+	snapshotMaxFrameSize = 256 * 1024 * 1024
+	// snapshotTrailerFrameSize 结尾帧的大小
+	snapshotTrailerFrameSize = 1 + 4 + 16 + 4
+)
+
+var (
+	ErrSnapshotCorrupted          = errors.New("snapshot corrupted")
+	ErrSnapshotUnsupportedVersion = errors.New("snapshot unsupported version")
+	ErrSnapshotShardMismatch      = errors.New("snapshot shard mismatch")
+	ErrSnapshotShardNotEmpty      = errors.New("snapshot restore shard not empty")
+	ErrSnapshotLogsCompacted      = errors.New("snapshot logs compacted")
+)
+
+// SnapshotHeader 快照头
+type SnapshotHeader struct {
+	Version   uint16
+	ShardNo   string
+	LastIndex uint64 // 快照内最后一条日志的下标
+	LastTerm  uint32 // 快照内最后一条日志的任期
+}
+
+type snapshotWriter struct {
+	w          io.Writer
+	total      uint32 // 所有logs帧payload的校验和
+	frameCount uint32
+	logCount   uint64
+	closed     bool
+}
+
+func newSnapshotWriter(w io.Writer, header SnapshotHeader) (*snapshotWriter, error) {
+	if _, err := w.Write(encodeSnapshotHeader(header)); err != nil {
+		return nil, err
+	}
+	return &snapshotWriter{
+		w: w,
+	}, nil
+}
+
+func encodeSnapshotHeader(header SnapshotHeader) []byte {
+	buf := make([]byte, 0, len(snapshotMagic)+2+2+len(header.ShardNo)+8+4+4)
+	buf = append(buf, snapshotMagic...)
+	buf = binary.BigEndian.AppendUint16(buf, snapshotVersion)
+	buf = binary.BigEndian.AppendUint16(buf, uint16(len(header.ShardNo)))
+	buf = append(buf, header.ShardNo...)
+	buf = binary.BigEndian.AppendUint64(buf, header.LastIndex)
+	buf = binary.BigEndian.AppendUint32(buf, header.LastTerm)
+	buf = binary.BigEndian.AppendUint32(buf, crc32.ChecksumIEEE(buf))
+	return buf
+}
+
+// writeLogs 写入一个日志帧
+func (s *snapshotWriter) writeLogs(logs []replica.Log) error {
+	if len(logs) == 0 {
+		return nil
+	}
+	size := 4
+	for _, lg := range logs {
+		size += 4 + lg.LogSize()
+	}
+	payload := make([]byte, 0, size)
+	payload = binary.BigEndian.AppendUint32(payload, uint32(len(logs)))
+	for _, lg := range logs {
+		data, err := lg.Marshal()
+		if err != nil {
+			return err
+		}
+		payload = binary.BigEndian.AppendUint32(payload, uint32(len(data)))
+		payload = append(payload, data...)
+	}
+	if err := s.writeFrame(snapshotFrameTypeLogs, payload); err != nil {
+		return err
+	}
+	s.total = crc32.Update(s.total, crc32.IEEETable, payload)
+	s.frameCount++
+	s.logCount += uint64(len(logs))
+	return nil
+}
+
+// close 写入结尾帧，快照只有写入了结尾帧才是完整的
+func (s *snapshotWriter) close() error {
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	payload := make([]byte, 0, 16)
+	payload = binary.BigEndian.AppendUint32(payload, s.frameCount)
+	payload = binary.BigEndian.AppendUint64(payload, s.logCount)
+	payload = binary.BigEndian.AppendUint32(payload, s.total)
+	return s.writeFrame(snapshotFrameTypeTrailer, payload)
+}
+
+func (s *snapshotWriter) writeFrame(frameType uint8, payload []byte) error {
+	buf := make([]byte, 0, 1+4+len(payload)+4)
+	buf = append(buf, frameType)
+	buf = binary.BigEndian.AppendUint32(buf, uint32(len(payload)))
+	buf = append(buf, payload...)
+	buf = binary.BigEndian.AppendUint32(buf, crc32.ChecksumIEEE(payload))
+	_, err := s.w.Write(buf)
+	return err
+}
+
+type snapshotReader struct {
+	r          *bufio.Reader
+	header     SnapshotHeader
+	total      hash.Hash32
+	frameCount uint32
+	logCount   uint64
+	done       bool
+}
+
+func newSnapshotReader(r io.Reader) (*snapshotReader, error) {
+	br := bufio.NewReader(r)
+	fixed := make([]byte, len(snapshotMagic)+2+2)
+	if _, err := io.ReadFull(br, fixed); err != nil {
+		return nil, snapshotReadErr(err)
+	}
+	if string(fixed[:len(snapshotMagic)]) != string(snapshotMagic) {
+		return nil, ErrSnapshotCorrupted
+	}
+	version := binary.BigEndian.Uint16(fixed[len(snapshotMagic):])
+	if version != snapshotVersion {
+		return nil, fmt.Errorf("%w: %d", ErrSnapshotUnsupportedVersion, version)
+	}
+	shardNoLen := int(binary.BigEndian.Uint16(fixed[len(snapshotMagic)+2:]))
+	rest := make([]byte, shardNoLen+8+4+4)
+	if _, err := io.ReadFull(br, rest); err != nil {
+		return nil, snapshotReadErr(err)
+	}
+	sum := binary.BigEndian.Uint32(rest[len(rest)-4:])
+	crc := crc32.Update(crc32.ChecksumIEEE(fixed), crc32.IEEETable, rest[:len(rest)-4])
+	if sum != crc {
+		return nil, ErrSnapshotCorrupted
+	}
+	return &snapshotReader{
+		r: br,
+		header: SnapshotHeader{
+			Version:   version,
+			ShardNo:   string(rest[:shardNoLen]),
+			LastIndex: binary.BigEndian.Uint64(rest[shardNoLen:]),
+			LastTerm:  binary.BigEndian.Uint32(rest[shardNoLen+8:]),
+		},
+		total: crc32.NewIEEE(),
+	}, nil
+}
+
+// next 读取下一帧的日志，读到并校验通过结尾帧后返回io.EOF
+func (s *snapshotReader) next() ([]replica.Log, error) {
+	if s.done {
+		return nil, io.EOF
+	}
+	frameType, payload, err := s.readFrame()
+	if err != nil {
+		return nil, err
+	}
+	switch frameType {
+	case snapshotFrameTypeLogs:
+		logs, err := decodeSnapshotLogs(payload)
+		if err != nil {
+			return nil, err
+		}
+		_, _ = s.total.Write(payload)
+		s.frameCount++
+		s.logCount += uint64(len(logs))
+		return logs, nil
+	case snapshotFrameTypeTrailer:
+		if len(payload) != 16 {
+			return nil, ErrSnapshotCorrupted
+		}
+		frameCount := binary.BigEndian.Uint32(payload)
+		logCount := binary.BigEndian.Uint64(payload[4:])
+		total := binary.BigEndian.Uint32(payload[12:])
+		if frameCount != s.frameCount || logCount != s.logCount || total != s.total.Sum32() {
+			return nil, ErrSnapshotCorrupted
+		}
+		s.done = true
+		return nil, io.EOF
+	}
+	return nil, ErrSnapshotCorrupted
+}
+
+func (s *snapshotReader) readFrame() (uint8, []byte, error) {
+	head := make([]byte, 5)
+	if _, err := io.ReadFull(s.r, head); err != nil {
+		return 0, nil, snapshotReadErr(err)
+	}
+	size := binary.BigEndian.Uint32(head[1:])
+	if size > snapshotMaxFrameSize {
+		return 0, nil, ErrSnapshotCorrupted
+	}
+	data := make([]byte, size+4)
+	if _, err := io.ReadFull(s.r, data); err != nil {
+		return 0, nil, snapshotReadErr(err)
+	}
+	payload := data[:size]
+	if binary.BigEndian.Uint32(data[size:]) != crc32.ChecksumIEEE(payload) {
+		return 0, nil, ErrSnapshotCorrupted
+	}
+	return head[0], payload, nil
+}
+
+func decodeSnapshotLogs(payload []byte) ([]replica.Log, error) {
+	if len(payload) < 4 {
+		return nil, ErrSnapshotCorrupted
+	}
+	count := binary.BigEndian.Uint32(payload)
+	payload = payload[4:]
+	logs := make([]replica.Log, 0, min(int(count), len(payload)/24))
+	for i := uint32(0); i < count; i++ {
+		if len(payload) < 4 {
+			return nil, ErrSnapshotCorrupted
+		}
+		size := binary.BigEndian.Uint32(payload)
+		payload = payload[4:]
+		if uint32(len(payload)) < size {
+			return nil, ErrSnapshotCorrupted
+		}
+		var lg replica.Log
+		if err := lg.Unmarshal(payload[:size]); err != nil {
+			return nil, ErrSnapshotCorrupted
+		}
+		logs = append(logs, lg)
+		payload = payload[size:]
+	}
+	if len(payload) != 0 {
+		return nil, ErrSnapshotCorrupted
+	}
+	return logs, nil
+}
+
+// 快照提前结束（被截断）也算损坏
+func snapshotReadErr(err error) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return ErrSnapshotCorrupted
+	}
+	return err
+}
+
+// writeShardSnapshot 将分区的日志以快照格式流式写入w，每帧最多frameSize字节的日志
+// 已经被压缩掉的日志从base（上一次的快照，可以为nil）里读取
+func writeShardSnapshot(storage IShardLogStorage, shardNo string, base io.Reader, w io.Writer, frameSize uint64) error {
+	lastIndex, lastTerm, err := storage.LastIndexAndTerm(shardNo)
+	if err != nil {
+		return err
+	}
+	return writeShardSnapshotTo(storage, shardNo, base, w, lastIndex, lastTerm, frameSize)
+}
+
+// writeShardSnapshotTo 将分区[1, lastIndex]的日志以快照格式流式写入w
+func writeShardSnapshotTo(storage IShardLogStorage, shardNo string, base io.Reader, w io.Writer, lastIndex uint64, lastTerm uint32, frameSize uint64) error {
+	sw, err := newSnapshotWriter(w, SnapshotHeader{
+		ShardNo:   shardNo,
+		LastIndex: lastIndex,
+		LastTerm:  lastTerm,
+	})
+	if err != nil {
+		return err
+	}
+	next := uint64(1) // 下一条要写入的日志下标
+	// 存储里[next, to)的日志已经被压缩，从base里补上
+	fillFromBase := func(to uint64) error {
+		if base == nil {
+			return ErrSnapshotLogsCompacted
+		}
+		next, err = copySnapshotLogs(base, shardNo, sw, next, to)
+		base = nil
+		if err != nil {
+			return err
+		}
+		if next != to {
+			return ErrSnapshotLogsCompacted
+		}
+		return nil
+	}
+	for next <= lastIndex {
+		logs, err := storage.Logs(shardNo, next, lastIndex+1, frameSize)
+		if err != nil {
+			return err
+		}
+		if len(logs) == 0 {
+			break
+		}
+		if logs[0].Index != next {
+			if err := fillFromBase(logs[0].Index); err != nil {
+				return err
+			}
+		}
+		if err := sw.writeLogs(logs); err != nil {
+			return err
+		}
+		next = logs[len(logs)-1].Index + 1
+	}
+	if next <= lastIndex {
+		if err := fillFromBase(lastIndex + 1); err != nil {
+			return err
+		}
+	}
+	return sw.close()
+}
+
+// appendShardSnapshot 把分区(快照的lastIndex, lastIndex]的日志追加到快照文件f（需要读写打开），每次只写新增的日志：
+// 去掉旧的结尾帧，追加日志帧和新的结尾帧，最后更新头；快照文件不完整（例如上次追加时进程退出）时返回ErrSnapshotCorrupted
+func appendShardSnapshot(storage IShardLogStorage, shardNo string, f *os.File, lastIndex uint64, lastTerm uint32, frameSize uint64) error {
+	stat, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	sr, err := newSnapshotReader(io.NewSectionReader(f, 0, stat.Size()))
+	if err != nil {
+		return err
+	}
+	if sr.header.ShardNo != shardNo {
+		return ErrSnapshotShardMismatch
+	}
+	if lastIndex <= sr.header.LastIndex {
+		return nil
+	}
+	headerSize := int64(len(encodeSnapshotHeader(sr.header)))
+	trailerOffset := stat.Size() - snapshotTrailerFrameSize
+	if trailerOffset < headerSize {
+		return ErrSnapshotCorrupted
+	}
+	trailer := make([]byte, snapshotTrailerFrameSize)
+	if _, err := f.ReadAt(trailer, trailerOffset); err != nil {
+		return snapshotReadErr(err)
+	}
+	payload := trailer[5 : 5+16]
+	if trailer[0] != snapshotFrameTypeTrailer || binary.BigEndian.Uint32(trailer[1:]) != 16 || binary.BigEndian.Uint32(trailer[5+16:]) != crc32.ChecksumIEEE(payload) {
+		return ErrSnapshotCorrupted
+	}
+	sw := &snapshotWriter{
+		frameCount: binary.BigEndian.Uint32(payload),
+		logCount:   binary.BigEndian.Uint64(payload[4:]),
+		total:      binary.BigEndian.Uint32(payload[12:]),
+	}
+	// 快照的日志从1开始连续，日志数量就是最后一条日志的下标
+	if sw.logCount != sr.header.LastIndex {
+		return ErrSnapshotCorrupted
+	}
+
+	if err := f.Truncate(trailerOffset); err != nil {
+		return err
+	}
+	if _, err := f.Seek(trailerOffset, io.SeekStart); err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	sw.w = w
+	next := sr.header.LastIndex + 1
+	for next <= lastIndex {
+		logs, err := storage.Logs(shardNo, next, lastIndex+1, frameSize)
+		if err != nil {
+			return err
+		}
+		if len(logs) == 0 || logs[0].Index != next {
+			return ErrSnapshotLogsCompacted
+		}
+		if err := sw.writeLogs(logs); err != nil {
+			return err
+		}
+		next = logs[len(logs)-1].Index + 1
+	}
+	if err := sw.close(); err != nil {
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	_, err = f.WriteAt(encodeSnapshotHeader(SnapshotHeader{
+		ShardNo:   shardNo,
+		LastIndex: lastIndex,
+		LastTerm:  lastTerm,
+	}), 0)
+	return err
+}
+
+// writeShardSnapshotChunk 把分区从from开始最多frameSize字节的日志（至少一条）写成一段快照，
+// 段的头是整个快照的lastIndex和lastTerm，只有一个日志帧；from超过lastIndex时只有头和结尾帧
+// 快照按段拉取，领导和副本都不需要把整个快照放到内存里；已经被压缩掉的日志从base（上一次的快照，可以为nil）里读取
+func writeShardSnapshotChunk(storage IShardLogStorage, shardNo string, base io.Reader, w io.Writer, from, lastIndex uint64, lastTerm uint32, frameSize uint64) error {
+	sw, err := newSnapshotWriter(w, SnapshotHeader{
+		ShardNo:   shardNo,
+		LastIndex: lastIndex,
+		LastTerm:  lastTerm,
+	})
+	if err != nil {
+		return err
+	}
+	if from <= lastIndex {
+		logs, err := storage.Logs(shardNo, from, lastIndex+1, frameSize)
+		if err != nil {
+			return err
+		}
+		if len(logs) == 0 || logs[0].Index != from {
+			to := lastIndex + 1
+			if len(logs) > 0 {
+				to = logs[0].Index
+			}
+			if logs, err = readSnapshotLogs(base, shardNo, from, to, frameSize); err != nil {
+				return err
+			}
+		}
+		if err := sw.writeLogs(logs); err != nil {
+			return err
+		}
+	}
+	return sw.close()
+}
+
+// readSnapshotLogs 从快照base里读取[from, to)里最多limitSize字节的日志（至少一条）
+func readSnapshotLogs(base io.Reader, shardNo string, from, to, limitSize uint64) ([]replica.Log, error) {
+	if base == nil {
+		return nil, ErrSnapshotLogsCompacted
+	}
+	sr, err := newSnapshotReader(base)
+	if err != nil {
+		return nil, err
+	}
+	if sr.header.ShardNo != shardNo {
+		return nil, ErrSnapshotShardMismatch
+	}
+	var (
+		result []replica.Log
+		size   uint64
+	)
+	for {
+		logs, err := sr.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, lg := range logs {
+			if lg.Index < from {
+				continue
+			}
+			if lg.Index >= to || (limitSize > 0 && len(result) > 0 && size+uint64(lg.LogSize()) > limitSize) {
+				return result, nil
+			}
+			if lg.Index != from+uint64(len(result)) {
+				return nil, ErrSnapshotCorrupted
+			}
+			result = append(result, lg)
+			size += uint64(lg.LogSize())
+		}
+	}
+	if len(result) == 0 {
+		return nil, ErrSnapshotLogsCompacted
+	}
+	return result, nil
+}
+
+// readShardSnapshotChunk 读取并校验一段快照，段里的日志必须从from开始连续并且不超过快照的lastIndex
+func readShardSnapshotChunk(r io.Reader, shardNo string, from uint64) (SnapshotHeader, []replica.Log, error) {
+	sr, err := newSnapshotReader(r)
+	if err != nil {
+		return SnapshotHeader{}, nil, err
+	}
+	if sr.header.ShardNo != shardNo {
+		return sr.header, nil, ErrSnapshotShardMismatch
+	}
+	var result []replica.Log
+	for {
+		logs, err := sr.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return sr.header, nil, err
+		}
+		for _, lg := range logs {
+			if lg.Index != from+uint64(len(result)) || lg.Index > sr.header.LastIndex {
+				return sr.header, nil, ErrSnapshotCorrupted
+			}
+			result = append(result, lg)
+		}
+	}
+	return sr.header, result, nil
+}
+
+// copySnapshotLogs 把快照base里[from, to)的日志写入sw，返回下一条要写入的日志下标
+func copySnapshotLogs(base io.Reader, shardNo string, sw *snapshotWriter, from, to uint64) (uint64, error) {
+	sr, err := newSnapshotReader(base)
+	if err != nil {
+		return from, err
+	}
+	if sr.header.ShardNo != shardNo {
+		return from, ErrSnapshotShardMismatch
+	}
+	next := from
+	for next < to {
+		logs, err := sr.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return next, err
+		}
+		var batch []replica.Log
+		for _, lg := range logs {
+			if lg.Index < next || lg.Index >= to {
+				continue
+			}
+			if lg.Index != next {
+				return next, ErrSnapshotCorrupted
+			}
+			batch = append(batch, lg)
+			next++
+		}
+		if len(batch) > 0 {
+			if err := sw.writeLogs(batch); err != nil {
+				return next, err
+			}
+		}
+	}
+	return next, nil
+}
+
+// restoreShardSnapshot 从快照流式恢复分区的日志（分区必须是空的）
+// 快照先流式写到stagingDir（为空时使用系统临时目录）下的暂存文件并完整校验，校验通过后才写入存储，
+// 损坏或被截断的快照不会改动存储；快照的第一条日志可以不是1（来源已经压缩了前面的日志），这时已应用下标设置为第一条日志的前一条
+func restoreShardSnapshot(storage IShardLogStorage, shardNo string, r io.Reader, stagingDir string) (SnapshotHeader, error) {
+	lastIndex, err := storage.LastIndex(shardNo)
+	if err != nil {
+		return SnapshotHeader{}, err
+	}
+	if lastIndex != 0 {
+		return SnapshotHeader{}, ErrSnapshotShardNotEmpty
+	}
+
+	staging, err := os.CreateTemp(stagingDir, "snapshot-restore-*")
+	if err != nil {
+		return SnapshotHeader{}, err
+	}
+	defer func() {
+		_ = staging.Close()
+		_ = os.Remove(staging.Name())
+	}()
+
+	// 暂存并校验
+	sr, err := newSnapshotReader(io.TeeReader(r, staging))
+	if err != nil {
+		return SnapshotHeader{}, err
+	}
+	if sr.header.ShardNo != shardNo {
+		return sr.header, ErrSnapshotShardMismatch
+	}
+	if _, err = walkSnapshotLogs(sr, nil); err != nil {
+		return sr.header, err
+	}
+
+	// 从暂存文件写入存储
+	if _, err = staging.Seek(0, io.SeekStart); err != nil {
+		return sr.header, err
+	}
+	sr, err = newSnapshotReader(staging)
+	if err != nil {
+		return SnapshotHeader{}, err
+	}
+	err = restoreSnapshotLogs(storage, shardNo, sr)
+	if err != nil {
+		if resetErr := resetShardLogs(storage, shardNo); resetErr != nil {
+			return sr.header, errors.Join(err, resetErr)
+		}
+		return sr.header, err
+	}
+	return sr.header, nil
+}
+
+func restoreSnapshotLogs(storage IShardLogStorage, shardNo string, sr *snapshotReader) error {
+	var lastTerm uint32
+	firstIndex, err := walkSnapshotLogs(sr, func(logs []replica.Log) error {
+		for _, lg := range logs {
+			if lg.Term > lastTerm {
+				if err := storage.SetLeaderTermStartIndex(shardNo, lg.Term, lg.Index); err != nil {
+					return err
+				}
+				lastTerm = lg.Term
+			}
+		}
+		return storage.AppendLogs(shardNo, logs)
+	})
+	if err != nil {
+		return err
+	}
+	if firstIndex > 1 {
+		return storage.SetAppliedIndex(shardNo, firstIndex-1)
+	}
+	return nil
+}
+
+// walkSnapshotLogs 按帧读取快照的日志并交给fn（fn可以为nil，只校验），日志必须连续、任期不递减，并且和快照头的lastIndex、lastTerm一致，
+// 返回快照第一条日志的下标（快照没有日志时为0）
+func walkSnapshotLogs(sr *snapshotReader, fn func(logs []replica.Log) error) (uint64, error) {
+	var (
+		firstIndex uint64
+		lastIndex  uint64
+		lastTerm   uint32
+	)
+	for {
+		logs, err := sr.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+		for _, lg := range logs {
+			if firstIndex == 0 {
+				if lg.Index == 0 {
+					return 0, ErrSnapshotCorrupted
+				}
+				firstIndex = lg.Index
+			} else if lg.Index != lastIndex+1 {
+				return 0, ErrSnapshotCorrupted
+			}
+			if lg.Term < lastTerm {
+				return 0, ErrSnapshotCorrupted
+			}
+			lastIndex = lg.Index
+			lastTerm = lg.Term
+		}
+		if fn != nil {
+			if err := fn(logs); err != nil {
+				return 0, err
+			}
+		}
+	}
+	if lastIndex != sr.header.LastIndex || lastTerm != sr.header.LastTerm {
+		return 0, ErrSnapshotCorrupted
+	}
+	return firstIndex, nil
+}
@@ -0,0 +1,286 @@
+package cluster
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net/url"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/WuKongIM/WuKongIM/pkg/cluster/replica"
+	"github.com/WuKongIM/WuKongIM/pkg/wkutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestSnapshotStorage(t *testing.T, shardNo string, count int) *PebbleShardLogStorage {
+	storage := NewPebbleShardLogStorage(t.TempDir(), 1)
+	err := storage.Open()
+	assert.NoError(t, err)
+
+	logs := make([]replica.Log, 0, count)
+	for i := 1; i <= count; i++ {
+		term := uint32(1)
+		if i > count/2 {
+			term = 2
+		}
+		logs = append(logs, replica.Log{Id: uint64(i), Index: uint64(i), Term: term, Data: []byte("hello snapshot")})
+	}
+	err = storage.AppendLogs(shardNo, logs)
+	assert.NoError(t, err)
+	return storage
+}
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	shardNo := "test-1"
+	src := newTestSnapshotStorage(t, shardNo, 100)
+	defer src.Close()
+
+	buf := bytes.NewBuffer(nil)
+	err := writeShardSnapshot(src, shardNo, nil, buf, 256) // 小帧，产生多个帧
+	assert.NoError(t, err)
+
+	dst := NewPebbleShardLogStorage(t.TempDir(), 1)
+	err = dst.Open()
+	assert.NoError(t, err)
+	defer dst.Close()
+
+	header, err := restoreShardSnapshot(dst, shardNo, buf, t.TempDir())
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(100), header.LastIndex)
+	assert.Equal(t, uint32(2), header.LastTerm)
+
+	srcLogs, err := src.Logs(shardNo, 1, 0, 0)
+	assert.NoError(t, err)
+	dstLogs, err := dst.Logs(shardNo, 1, 0, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, len(srcLogs), len(dstLogs))
+	for i := range srcLogs {
+		assert.Equal(t, srcLogs[i].Index, dstLogs[i].Index)
+		assert.Equal(t, srcLogs[i].Term, dstLogs[i].Term)
+		assert.Equal(t, srcLogs[i].Data, dstLogs[i].Data)
+	}
+
+	startIndex, err := dst.LeaderTermStartIndex(shardNo, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(51), startIndex)
+}
+
+func TestSnapshotRestoreCorrupted(t *testing.T) {
+	shardNo := "test-1"
+	src := newTestSnapshotStorage(t, shardNo, 100)
+	defer src.Close()
+
+	buf := bytes.NewBuffer(nil)
+	err := writeShardSnapshot(src, shardNo, nil, buf, 256)
+	assert.NoError(t, err)
+	data := buf.Bytes()
+
+	restore := func(data []byte) error {
+		dst := NewPebbleShardLogStorage(t.TempDir(), 1)
+		err := dst.Open()
+		assert.NoError(t, err)
+		defer dst.Close()
+
+		_, err = restoreShardSnapshot(dst, shardNo, bytes.NewReader(data), t.TempDir())
+
+		// 失败时不能留下部分数据
+		lastIndex, lerr := dst.LastIndex(shardNo)
+		assert.NoError(t, lerr)
+		assert.Equal(t, uint64(0), lastIndex)
+		return err
+	}
+
+	// 中间的数据被修改
+	corrupted := append([]byte(nil), data...)
+	corrupted[len(corrupted)/2] ^= 0xff
+	assert.ErrorIs(t, restore(corrupted), ErrSnapshotCorrupted)
+
+	// 传输中途被截断（丢失结尾帧）
+	assert.ErrorIs(t, restore(data[:len(data)-10]), ErrSnapshotCorrupted)
+
+	// 丢失整个结尾帧
+	trailerLen := 1 + 4 + 16 + 4
+	assert.ErrorIs(t, restore(data[:len(data)-trailerLen]), ErrSnapshotCorrupted)
+
+	// 不支持的版本
+	unsupported := append([]byte(nil), data...)
+	binary.BigEndian.PutUint16(unsupported[len(snapshotMagic):], snapshotVersion+1)
+	assert.ErrorIs(t, restore(unsupported), ErrSnapshotUnsupportedVersion)
+}
+
+func TestSnapshotRestoreShardMismatch(t *testing.T) {
+	src := newTestSnapshotStorage(t, "test-1", 10)
+	defer src.Close()
+
+	buf := bytes.NewBuffer(nil)
+	err := writeShardSnapshot(src, "test-1", nil, buf, 0)
+	assert.NoError(t, err)
+
+	_, err = restoreShardSnapshot(src, "test-2", buf, t.TempDir())
+	assert.ErrorIs(t, err, ErrSnapshotShardMismatch)
+}
+
+// 来源已经压缩了前面的日志，快照从大于1的下标开始
+func TestSnapshotRestoreBaseIndex(t *testing.T) {
+	shardNo := "test-1"
+	buf := bytes.NewBuffer(nil)
+	w, err := newSnapshotWriter(buf, SnapshotHeader{ShardNo: shardNo, LastIndex: 100, LastTerm: 3})
+	assert.NoError(t, err)
+	logs := make([]replica.Log, 0, 50)
+	for i := 51; i <= 100; i++ {
+		term := uint32(2)
+		if i > 80 {
+			term = 3
+		}
+		logs = append(logs, replica.Log{Id: uint64(i), Index: uint64(i), Term: term, Data: []byte("hello snapshot")})
+	}
+	assert.NoError(t, w.writeLogs(logs[:20]))
+	assert.NoError(t, w.writeLogs(logs[20:]))
+	assert.NoError(t, w.close())
+
+	dst := NewPebbleShardLogStorage(t.TempDir(), 1)
+	assert.NoError(t, dst.Open())
+	defer dst.Close()
+
+	stagingDir := t.TempDir()
+	header, err := restoreShardSnapshot(dst, shardNo, buf, stagingDir)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(100), header.LastIndex)
+
+	lastIndex, err := dst.LastIndex(shardNo)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(100), lastIndex)
+	appliedIndex, err := dst.AppliedIndex(shardNo)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(50), appliedIndex)
+	startIndex, err := dst.LeaderTermStartIndex(shardNo, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(81), startIndex)
+	dstLogs, err := dst.Logs(shardNo, 51, 0, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 50, len(dstLogs))
+	assert.Equal(t, uint64(51), dstLogs[0].Index)
+
+	// 暂存文件恢复后被删除
+	entries, err := os.ReadDir(stagingDir)
+	assert.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+// 日志不连续或者和快照头不一致的快照在写入存储前被拒绝
+func TestSnapshotRestoreInvalidLogs(t *testing.T) {
+	shardNo := "test-1"
+	restore := func(header SnapshotHeader, logs []replica.Log) error {
+		buf := bytes.NewBuffer(nil)
+		w, err := newSnapshotWriter(buf, header)
+		assert.NoError(t, err)
+		assert.NoError(t, w.writeLogs(logs))
+		assert.NoError(t, w.close())
+
+		dst := NewPebbleShardLogStorage(t.TempDir(), 1)
+		assert.NoError(t, dst.Open())
+		defer dst.Close()
+		_, err = restoreShardSnapshot(dst, shardNo, buf, t.TempDir())
+
+		lastIndex, lerr := dst.LastIndex(shardNo)
+		assert.NoError(t, lerr)
+		assert.Equal(t, uint64(0), lastIndex)
+		return err
+	}
+
+	// 下标不连续
+	err := restore(SnapshotHeader{ShardNo: shardNo, LastIndex: 12, LastTerm: 1}, []replica.Log{
+		{Index: 10, Term: 1}, {Index: 12, Term: 1},
+	})
+	assert.ErrorIs(t, err, ErrSnapshotCorrupted)
+
+	// 最后一条日志和快照头不一致
+	err = restore(SnapshotHeader{ShardNo: shardNo, LastIndex: 12, LastTerm: 1}, []replica.Log{
+		{Index: 10, Term: 1}, {Index: 11, Term: 1},
+	})
+	assert.ErrorIs(t, err, ErrSnapshotCorrupted)
+
+	// 任期回退
+	err = restore(SnapshotHeader{ShardNo: shardNo, LastIndex: 11, LastTerm: 1}, []replica.Log{
+		{Index: 10, Term: 2}, {Index: 11, Term: 1},
+	})
+	assert.ErrorIs(t, err, ErrSnapshotCorrupted)
+}
+
+// 按日志数量触发的频道快照写到数据目录下，并且可以恢复
+func TestChannelManagerOnSnapshot(t *testing.T) {
+	setupTestTrace(t)
+
+	shardNo := wkutil.ChannelToKey("snapshot", 2)
+	src := newTestSnapshotStorage(t, shardNo, 100)
+	defer src.Close()
+	assert.NoError(t, src.SetAppliedIndex(shardNo, 100))
+
+	ch := newSnapshotTestChannel(t, 2, src, replica.RoleFollower)
+	err := ch.s.channelManager.onSnapshot(shardNo, 100)
+	assert.NoError(t, err)
+
+	f, err := os.Open(path.Join(ch.opts.DataDir, "snapshots", url.PathEscape(shardNo)+".snap"))
+	assert.NoError(t, err)
+	defer f.Close()
+
+	dst := NewPebbleShardLogStorage(t.TempDir(), 1)
+	err = dst.Open()
+	assert.NoError(t, err)
+	defer dst.Close()
+	header, err := restoreShardSnapshot(dst, shardNo, f, t.TempDir())
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(100), header.LastIndex)
+}
+
+// 快照文件只追加新增的日志，追加后和一次写完的快照一样
+func TestSnapshotAppend(t *testing.T) {
+	shardNo := "test-1"
+	src := newTestSnapshotStorage(t, shardNo, 100)
+	defer src.Close()
+
+	p := path.Join(t.TempDir(), "test.snap")
+	f, err := os.Create(p)
+	assert.NoError(t, err)
+	assert.NoError(t, writeShardSnapshotTo(src, shardNo, nil, f, 50, 1, 256))
+	assert.NoError(t, f.Close())
+
+	f, err = os.OpenFile(p, os.O_RDWR, 0644)
+	assert.NoError(t, err)
+	assert.NoError(t, appendShardSnapshot(src, shardNo, f, 100, 2, 256))
+	// 没有新增的日志什么也不做
+	assert.NoError(t, appendShardSnapshot(src, shardNo, f, 80, 2, 256))
+	assert.NoError(t, f.Close())
+
+	data, err := os.ReadFile(p)
+	assert.NoError(t, err)
+	dst := NewPebbleShardLogStorage(t.TempDir(), 1)
+	assert.NoError(t, dst.Open())
+	defer dst.Close()
+	header, err := restoreShardSnapshot(dst, shardNo, bytes.NewReader(data), t.TempDir())
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(100), header.LastIndex)
+	assert.Equal(t, uint32(2), header.LastTerm)
+	srcLogs, err := src.Logs(shardNo, 1, 0, 0)
+	assert.NoError(t, err)
+	dstLogs, err := dst.Logs(shardNo, 1, 0, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, len(srcLogs), len(dstLogs))
+	for i := range srcLogs {
+		assert.Equal(t, srcLogs[i].Index, dstLogs[i].Index)
+		assert.Equal(t, srcLogs[i].Term, dstLogs[i].Term)
+		assert.Equal(t, srcLogs[i].Data, dstLogs[i].Data)
+	}
+
+	// 结尾帧损坏（例如上次追加时进程退出）
+	half := bytes.NewBuffer(nil)
+	assert.NoError(t, writeShardSnapshotTo(src, shardNo, nil, half, 50, 1, 256))
+	data = half.Bytes()
+	data[len(data)-1] ^= 0xff
+	assert.NoError(t, os.WriteFile(p, data, 0644))
+	f, err = os.OpenFile(p, os.O_RDWR, 0644)
+	assert.NoError(t, err)
+	defer f.Close()
+	assert.ErrorIs(t, appendShardSnapshot(src, shardNo, f, 100, 2, 256), ErrSnapshotCorrupted)
+}
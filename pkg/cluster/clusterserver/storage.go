@@ -51,6 +51,12 @@ type IShardLogStorage interface {
 	Close() error
 }
 
+// logCompactor 支持按快照压缩日志的存储（存储没有实现时不做快照也不压缩，日志一直保留在存储里）
+type logCompactor interface {
+	// CompactTo 压缩日志，删除index和index之前的日志（日志已经写入快照），index不能大于已应用的下标
+	CompactTo(shardNo string, index uint64) error
+}
+
 type MemoryShardLogStorage struct {
 	storage                 map[string][]replica.Log
 	leaderTermStartIndexMap map[string]map[uint32]uint64
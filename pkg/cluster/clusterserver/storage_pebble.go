@@ -211,6 +211,21 @@ func (p *PebbleShardLogStorage) TruncateLogTo(shardNo string, index uint64) erro
 	return p.saveMaxIndex(shardNo, index-1)
 }
 
+// CompactTo 压缩日志
+func (p *PebbleShardLogStorage) CompactTo(shardNo string, index uint64) error {
+	if index == 0 {
+		return nil
+	}
+	appliedIdx, err := p.AppliedIndex(shardNo)
+	if err != nil {
+		return err
+	}
+	if index > appliedIdx {
+		return ErrCompactNotApplied
+	}
+	return p.shardDB(shardNo).DeleteRange(key.NewLogKey(shardNo, 0), key.NewLogKey(shardNo, index+1), p.wo)
+}
+
 // func (p *PebbleShardLogStorage) realLastIndex(shardNo string) (uint64, error) {
 // 	iter := p.db.NewIter(&pebble.IterOptions{
 // 		LowerBound: key.NewLogKey(shardNo, 0),
@@ -0,0 +1,114 @@
+package cluster
+
+import (
+	"testing"
+
+	"github.com/WuKongIM/WuKongIM/pkg/cluster/reactor"
+	"github.com/WuKongIM/WuKongIM/pkg/cluster/replica"
+	"github.com/WuKongIM/WuKongIM/pkg/wklog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResetShardLogs(t *testing.T) {
+	storage := NewPebbleShardLogStorage(t.TempDir(), 1)
+	err := storage.Open()
+	assert.NoError(t, err)
+	defer storage.Close()
+
+	shardNo := "test-2"
+
+	// 模拟一个本地数据已损坏的副本
+	logs := make([]replica.Log, 0, 5)
+	for i := uint64(1); i <= 5; i++ {
+		logs = append(logs, replica.Log{Id: i, Index: i, Term: 1, Data: []byte("hello")})
+	}
+	err = storage.AppendLogs(shardNo, logs)
+	assert.NoError(t, err)
+	err = storage.SetAppliedIndex(shardNo, 5)
+	assert.NoError(t, err)
+	err = storage.SetLeaderTermStartIndex(shardNo, 1, 1)
+	assert.NoError(t, err)
+
+	err = resetShardLogs(storage, shardNo)
+	assert.NoError(t, err)
+
+	lastIndex, err := storage.LastIndex(shardNo)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0), lastIndex)
+
+	appliedIndex, err := storage.AppliedIndex(shardNo)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0), appliedIndex)
+
+	lastTerm, err := storage.LeaderLastTerm(shardNo)
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(0), lastTerm)
+
+	leftLogs, err := storage.Logs(shardNo, 1, 0, 0)
+	assert.NoError(t, err)
+	assert.Len(t, leftLogs, 0)
+}
+
+// 重复投递已经追加过的日志，不会重复写入也不会覆盖
+func TestChannelAppendLogBatchIdempotent(t *testing.T) {
+	storage := NewPebbleShardLogStorage(t.TempDir(), 1)
+	err := storage.Open()
+	assert.NoError(t, err)
+	defer storage.Close()
+
+	c := &channelManager{
+		channelReactor: reactor.New(reactor.NewOptions(reactor.WithReactorType(reactor.ReactorTypeChannel))),
+		opts:           NewOptions(WithMessageLogStorage(storage)),
+		s:              &Server{},
+		Log:            wklog.NewWKLog("test"),
+	}
+	shardNo := "test-2"
+
+	newLogs := func(start, end uint64, term uint32, data string) []replica.Log {
+		logs := make([]replica.Log, 0, end-start+1)
+		for i := start; i <= end; i++ {
+			logs = append(logs, replica.Log{Id: i, Index: i, Term: term, Data: []byte(data)})
+		}
+		return logs
+	}
+
+	err = c.AppendLogBatch([]reactor.AppendLogReq{{HandleKey: shardNo, Logs: newLogs(1, 5, 1, "hello")}})
+	assert.NoError(t, err)
+
+	// 重复投递同一批日志
+	err = c.AppendLogBatch([]reactor.AppendLogReq{{HandleKey: shardNo, Logs: newLogs(1, 5, 1, "redelivered")}})
+	assert.NoError(t, err)
+
+	// 部分重复
+	err = c.AppendLogBatch([]reactor.AppendLogReq{{HandleKey: shardNo, Logs: newLogs(4, 7, 1, "hello")}})
+	assert.NoError(t, err)
+
+	lastIndex, err := storage.LastIndex(shardNo)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(7), lastIndex)
+
+	logs, err := storage.Logs(shardNo, 1, 8, 0)
+	assert.NoError(t, err)
+	assert.Len(t, logs, 7)
+	for i, lg := range logs {
+		assert.Equal(t, uint64(i+1), lg.Index)
+		assert.Equal(t, "hello", string(lg.Data)) // 已存储的日志没有被覆盖
+	}
+
+	// 任期不一致，冲突的请求单独失败，同一批里其他频道的日志照常追加
+	otherShardNo := "test-3"
+	reqs := []reactor.AppendLogReq{
+		{HandleKey: shardNo, Logs: newLogs(6, 8, 2, "conflict")},
+		{HandleKey: otherShardNo, Logs: newLogs(1, 3, 1, "hello")},
+	}
+	err = c.AppendLogBatch(reqs)
+	assert.NoError(t, err)
+	assert.ErrorIs(t, reqs[0].Err, ErrLogTermConflict)
+	assert.NoError(t, reqs[1].Err)
+	lastIndex, err = storage.LastIndex(shardNo)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(7), lastIndex)
+	lastIndex, err = storage.LastIndex(otherShardNo)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(3), lastIndex)
+}
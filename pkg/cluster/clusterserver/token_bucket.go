@@ -0,0 +1,111 @@
+package cluster
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// tokenBucket 令牌桶，频道创建和写入等节点级别的限速共用
+type tokenBucket struct {
+	rate  float64 // 每秒产生的令牌数
+	burst float64 // 桶容量
+	// overdraft 是否允许透支：桶里的令牌不为负时可以一次预留超过剩余数量（甚至超过桶容量）的令牌，由之后的预留等待偿还
+	overdraft bool
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64, burst float64, overdraft bool) *tokenBucket {
+	return &tokenBucket{
+		rate:      rate,
+		burst:     burst,
+		overdraft: overdraft,
+		tokens:    burst,
+		last:      time.Now(),
+	}
+}
+
+// refillLocked 按流逝的时间补充令牌，最多补满一个桶
+func (b *tokenBucket) refillLocked(now time.Time) {
+	if now.After(b.last) {
+		b.tokens += now.Sub(b.last).Seconds() * b.rate
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+		b.last = now
+	}
+}
+
+// reserve 预留n个令牌，返回需要等待的时间，如果需要等待的时间超过maxWait则不预留并返回false
+func (b *tokenBucket) reserve(now time.Time, n int, maxWait time.Duration) (time.Duration, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refillLocked(now)
+
+	tokens := b.tokens - float64(n)
+	deficit := tokens
+	if b.overdraft { // 只等待之前透支的令牌恢复
+		deficit = b.tokens
+	}
+	var wait time.Duration
+	if deficit < 0 {
+		wait = time.Duration(-deficit / b.rate * float64(time.Second))
+	}
+	if wait > maxWait {
+		return 0, false
+	}
+	b.tokens = tokens
+	return wait, true
+}
+
+// wait 预留n个令牌并等待到可用，返回等待的时间，需要等待的时间超过maxWait时不预留并返回false
+// ctx结束时归还预留的令牌并返回ctx的错误
+func (b *tokenBucket) wait(ctx context.Context, n int, maxWait time.Duration) (time.Duration, bool, error) {
+	wait, ok := b.reserve(time.Now(), n, maxWait)
+	if !ok {
+		return 0, false, nil
+	}
+	if wait <= 0 {
+		return 0, true, nil
+	}
+	tm := time.NewTimer(wait)
+	defer tm.Stop()
+	select {
+	case <-tm.C:
+		return wait, true, nil
+	case <-ctx.Done():
+		b.cancel(n)
+		return wait, true, ctx.Err()
+	}
+}
+
+// take 直接取走n个令牌，不足时透支（最多透支一个桶），返回令牌恢复到n个需要的时间
+func (b *tokenBucket) take(now time.Time, n int) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refillLocked(now)
+
+	b.tokens -= float64(n)
+	if b.tokens < -b.burst {
+		b.tokens = -b.burst
+	}
+	if b.tokens >= float64(n) {
+		return 0
+	}
+	return time.Duration((float64(n) - b.tokens) / b.rate * float64(time.Second))
+}
+
+// cancel 归还预留的n个令牌
+func (b *tokenBucket) cancel(n int) {
+	b.mu.Lock()
+	b.tokens += float64(n)
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.mu.Unlock()
+}
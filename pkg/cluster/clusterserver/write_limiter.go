@@ -0,0 +1,46 @@
+package cluster
+
+import (
+	"context"
+	"time"
+
+	"github.com/WuKongIM/WuKongIM/pkg/cluster/replica"
+	"github.com/WuKongIM/WuKongIM/pkg/trace"
+)
+
+// writeLimiter 节点级别的写入限速（按字节的令牌桶），所有频道和槽的提案共享，避免个别频道写爆共享的磁盘
+// 桶容量为一秒的写入量，单次提案超过桶容量时允许透支，由后续的提案等待偿还
+type writeLimiter struct {
+	*tokenBucket
+}
+
+func newWriteLimiter(bytesPerSecond int) *writeLimiter {
+	return &writeLimiter{
+		tokenBucket: newTokenBucket(float64(bytesPerSecond), float64(bytesPerSecond), true),
+	}
+}
+
+// wait 等待n个字节的写入额度，最多等待maxWait，超过则返回ErrWriteRateLimited
+func (l *writeLimiter) wait(ctx context.Context, n int, maxWait time.Duration) error {
+	wait, ok, err := l.tokenBucket.wait(ctx, n, maxWait)
+	if !ok || wait > 0 {
+		trace.GlobalTrace.Metrics.Cluster().WriteThrottledCountAdd(1)
+	}
+	if !ok {
+		return ErrWriteRateLimited
+	}
+	if err != nil {
+		return err
+	}
+	trace.GlobalTrace.Metrics.Cluster().WriteBytesAdd(int64(n))
+	return nil
+}
+
+// waitLogs 等待日志的写入额度
+func (l *writeLimiter) waitLogs(ctx context.Context, logs []replica.Log, maxWait time.Duration) error {
+	size := 0
+	for _, lg := range logs {
+		size += lg.LogSize()
+	}
+	return l.wait(ctx, size, maxWait)
+}
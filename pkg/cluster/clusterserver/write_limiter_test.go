@@ -0,0 +1,38 @@
+package cluster
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteLimiterReserve(t *testing.T) {
+	l := newWriteLimiter(1000)
+	now := l.last
+
+	// 额度内不需要等待
+	wait, ok := l.reserve(now, 600, 0)
+	assert.True(t, ok)
+	assert.Equal(t, time.Duration(0), wait)
+
+	// 还有额度时允许透支
+	wait, ok = l.reserve(now, 600, 0)
+	assert.True(t, ok)
+	assert.Equal(t, time.Duration(0), wait)
+
+	// 已透支，不允许等待则拒绝
+	_, ok = l.reserve(now, 100, 0)
+	assert.False(t, ok)
+
+	// 允许等待则等待透支的额度恢复
+	wait, ok = l.reserve(now, 100, time.Second)
+	assert.True(t, ok)
+	assert.Equal(t, 200*time.Millisecond, wait)
+
+	// 取消后归还额度
+	l.cancel(100)
+	wait, ok = l.reserve(now.Add(time.Second), 100, 0)
+	assert.True(t, ok)
+	assert.Equal(t, time.Duration(0), wait)
+}
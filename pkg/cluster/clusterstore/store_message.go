@@ -19,6 +19,32 @@ func (s *Store) AppendMessages(ctx context.Context, channelId string, channelTyp
 	if len(msgs) == 0 {
 		return nil, nil
 	}
+	logs, err := messagesToLogs(msgs)
+	if err != nil {
+		return nil, err
+	}
+
+	results, err := s.opts.Cluster.ProposeChannelMessages(ctx, channelId, channelType, logs)
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// AppendMessagesWithResults 批量追加消息，返回每条消息的结果（和msgs一一对应，日志id为消息id），
+// 不能追加的消息（例如超过最大消息大小）单独失败，调用方可以只重试失败的消息
+func (s *Store) AppendMessagesWithResults(ctx context.Context, channelId string, channelType uint8, msgs []wkdb.Message) ([]icluster.ProposeEntryResult, error) {
+	if len(msgs) == 0 {
+		return nil, nil
+	}
+	logs, err := messagesToLogs(msgs)
+	if err != nil {
+		return nil, err
+	}
+	return s.opts.Cluster.ProposeChannelMessagesWithResults(ctx, channelId, channelType, logs)
+}
+
+func messagesToLogs(msgs []wkdb.Message) ([]replica.Log, error) {
 	logs := make([]replica.Log, len(msgs))
 	for i, msg := range msgs {
 		data, err := msg.Marshal()
@@ -30,12 +56,7 @@ func (s *Store) AppendMessages(ctx context.Context, channelId string, channelTyp
 			Data: data,
 		}
 	}
-
-	results, err := s.opts.Cluster.ProposeChannelMessages(ctx, channelId, channelType, logs)
-	if err != nil {
-		return nil, err
-	}
-	return results, nil
+	return logs, nil
 }
 
 func (s *Store) LoadNextRangeMsgs(channelID string, channelType uint8, startMessageSeq, endMessageSeq uint64, limit int) ([]wkdb.Message, error) {
@@ -61,6 +61,9 @@ type Cluster interface {
 type Propose interface {
 	// ProposeChannelMessages 批量提交消息到指定的channel
 	ProposeChannelMessages(ctx context.Context, channelId string, channelType uint8, logs []replica.Log) ([]ProposeResult, error)
+	// ProposeChannelMessagesWithResults 批量提交消息到指定的channel，返回每条消息的提案结果（和logs一一对应），
+	// 不能提案的消息单独失败，其余的消息一起提交或一起失败，调用方可以只重试失败的消息
+	ProposeChannelMessagesWithResults(ctx context.Context, channelId string, channelType uint8, logs []replica.Log) ([]ProposeEntryResult, error)
 	// ProposeToSlots 提案日志到指定的槽
 	ProposeToSlot(ctx context.Context, slotId uint32, logs []replica.Log) ([]ProposeResult, error)
 	// ProposeDataToSlot 提案数据到指定的槽
@@ -71,3 +74,10 @@ type ProposeResult interface {
 	LogId() uint64    // 日志Id
 	LogIndex() uint64 // 日志下标
 }
+
+// ProposeEntryResult 批量提案中一条日志的提案结果
+type ProposeEntryResult struct {
+	Id    uint64 // 日志id
+	Index uint64 // 提交后的日志下标，失败时为0
+	Err   error  // 这条日志的错误，nil表示已提交
+}
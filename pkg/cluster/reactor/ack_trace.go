@@ -0,0 +1,133 @@
+package reactor
+
+import (
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/WuKongIM/WuKongIM/pkg/trace"
+)
+
+// replicaAck 副本对提案的确认
+type replicaAck struct {
+	replicaId uint64
+	cost      time.Duration // 从提案到副本确认（已存储）的耗时
+}
+
+type proposeAcks struct {
+	start     time.Time
+	lastIndex uint64 // 提案的最后一条日志下标，0表示还没分配下标
+	acks      []replicaAck
+}
+
+// ackTracer 记录被采样的提案，每个副本确认的顺序和耗时，用于找出拖慢提交的副本
+// 跟踪中的提案数量达到maxPending（高水位）时不再跟踪新的提案（保护内存，跟踪的完整性没有节点稳定重要），降到一半以下后恢复跟踪
+type ackTracer struct {
+	mu         sync.Mutex
+	proposes   map[string]*proposeAcks
+	maxPending int  // 最多同时跟踪的提案数量，0表示不限制
+	shedding   bool // 是否正在丢弃新的跟踪
+}
+
+func newAckTracer(maxPending int) *ackTracer {
+	return &ackTracer{
+		proposes:   make(map[string]*proposeAcks),
+		maxPending: maxPending,
+	}
+}
+
+// sampled 是否采样此次提案
+func (a *ackTracer) sampled(rate float64) bool {
+	if rate <= 0 {
+		return false
+	}
+	return rate >= 1 || rand.Float64() < rate
+}
+
+// start 开始跟踪提案，返回false表示跟踪的提案太多，此次提案不跟踪
+func (a *ackTracer) start(waitKey string) bool {
+	a.mu.Lock()
+	if a.maxPending > 0 {
+		if a.shedding && len(a.proposes) <= a.maxPending/2 {
+			a.shedding = false
+		}
+		if a.shedding || len(a.proposes) >= a.maxPending {
+			a.shedding = true
+			a.mu.Unlock()
+			trace.GlobalTrace.Metrics.Cluster().ProposeAckTraceDroppedCountAdd(1)
+			return false
+		}
+	}
+	a.proposes[waitKey] = &proposeAcks{start: time.Now()}
+	a.mu.Unlock()
+	return true
+}
+
+// pending 跟踪中的提案数量
+func (a *ackTracer) pending() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return len(a.proposes)
+}
+
+// didPropose 提案的日志已分配下标
+func (a *ackTracer) didPropose(waitKey string, lastIndex uint64) {
+	a.mu.Lock()
+	if p := a.proposes[waitKey]; p != nil {
+		p.lastIndex = lastIndex
+	}
+	a.mu.Unlock()
+}
+
+// ack 副本已存储到index的日志
+func (a *ackTracer) ack(replicaId uint64, index uint64) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if len(a.proposes) == 0 {
+		return
+	}
+	now := time.Now()
+	for _, p := range a.proposes {
+		if p.lastIndex == 0 || index < p.lastIndex {
+			continue
+		}
+		acked := false
+		for _, ack := range p.acks {
+			if ack.replicaId == replicaId {
+				acked = true
+				break
+			}
+		}
+		if !acked {
+			p.acks = append(p.acks, replicaAck{replicaId: replicaId, cost: now.Sub(p.start)})
+		}
+	}
+}
+
+// finish 结束提案的跟踪，返回按确认顺序排列的副本确认
+func (a *ackTracer) finish(waitKey string) []replicaAck {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	p := a.proposes[waitKey]
+	if p == nil {
+		return nil
+	}
+	delete(a.proposes, waitKey)
+	return p.acks
+}
+
+// formatAcks 格式化副本确认，格式：副本id:耗时(微秒),副本id:耗时(微秒)...
+func formatAcks(acks []replicaAck) string {
+	var b strings.Builder
+	for i, ack := range acks {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		b.WriteString(strconv.FormatUint(ack.replicaId, 10))
+		b.WriteString(":")
+		b.WriteString(strconv.FormatInt(ack.cost.Microseconds(), 10))
+	}
+	return b.String()
+}
@@ -0,0 +1,86 @@
+package reactor
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/WuKongIM/WuKongIM/pkg/cluster/replica"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAckTracer(t *testing.T) {
+	a := newAckTracer(0)
+	assert.False(t, a.sampled(0))
+	assert.True(t, a.sampled(1))
+
+	a.start("1")
+	// 还没分配下标的确认忽略
+	a.ack(1, 10)
+	a.didPropose("1", 10)
+
+	a.ack(1, 10) // 领导本地存储
+	time.Sleep(time.Millisecond * 2)
+	a.ack(3, 9)  // 副本3还没同步到
+	a.ack(2, 10) // 副本2确认
+	a.ack(2, 11) // 重复的确认忽略
+	a.ack(3, 12) // 副本3最后确认（拖慢提交的副本）
+
+	acks := a.finish("1")
+	assert.Len(t, acks, 3)
+	assert.Equal(t, uint64(1), acks[0].replicaId)
+	assert.Equal(t, uint64(2), acks[1].replicaId)
+	assert.Equal(t, uint64(3), acks[2].replicaId)
+	assert.True(t, acks[2].cost >= acks[0].cost)
+
+	s := formatAcks(acks)
+	assert.True(t, strings.HasPrefix(s, "1:"))
+	assert.Equal(t, 3, len(strings.Split(s, ",")))
+
+	assert.Nil(t, a.finish("1"))
+	assert.Len(t, a.proposes, 0)
+}
+
+func TestReactorSubTraceAck(t *testing.T) {
+	r := &ReactorSub{opts: NewOptions(WithNodeId(1))}
+	h := &handler{ackTracer: newAckTracer(0)}
+	h.ackTracer.start("1")
+	h.ackTracer.didPropose("1", 5)
+
+	r.traceAck(h, replica.Message{MsgType: replica.MsgStoreAppendResp, Index: 5})
+	r.traceAck(h, replica.Message{MsgType: replica.MsgStoreAppendResp, Index: 5, Reject: true})
+
+	acks := h.ackTracer.finish("1")
+	assert.Len(t, acks, 1)
+	assert.Equal(t, uint64(1), acks[0].replicaId)
+}
+
+func TestAckTracerMaxPending(t *testing.T) {
+	setupTestTrace(t)
+
+	a := newAckTracer(100)
+	// 大量提案涌入，跟踪的数量不超过高水位
+	dropped := 0
+	for i := 0; i < 1000; i++ {
+		if !a.start(strconv.Itoa(i)) {
+			dropped++
+		}
+		a.ack(2, uint64(i))
+	}
+	assert.Equal(t, 100, a.pending())
+	assert.Equal(t, 900, dropped)
+
+	// 没降到一半以下之前，继续丢弃
+	for i := 0; i < 40; i++ {
+		a.finish(strconv.Itoa(i))
+	}
+	assert.False(t, a.start("new1"))
+
+	// 降到一半以下后恢复跟踪
+	for i := 40; i < 50; i++ {
+		a.finish(strconv.Itoa(i))
+	}
+	assert.True(t, a.start("new2"))
+	assert.Equal(t, 51, a.pending())
+}
@@ -0,0 +1,62 @@
+package reactor
+
+import (
+	"testing"
+
+	"github.com/WuKongIM/WuKongIM/pkg/cluster/replica"
+	"github.com/stretchr/testify/assert"
+)
+
+type testReadyHandler struct {
+	IHandler
+	rd replica.Ready
+}
+
+func (t *testReadyHandler) AppliedIndex() (uint64, error) {
+	return 0, nil
+}
+
+func (t *testReadyHandler) HasReady() bool {
+	return len(t.rd.Messages) > 0
+}
+
+func (t *testReadyHandler) Ready() replica.Ready {
+	rd := t.rd
+	t.rd = replica.Ready{}
+	return rd
+}
+
+func (t *testReadyHandler) LastLogIndexAndTerm() (uint64, uint32) {
+	return 10, 1
+}
+
+// 已应用下标超过已提交下标，分区标记为异常，不再应用日志
+func TestApplyAnomalyDegraded(t *testing.T) {
+	var degradedKey, degradedReason string
+	r := New(NewOptions(WithSubReactorNum(1), WithOnDegraded(func(handleKey string, reason string) {
+		degradedKey = handleKey
+		degradedReason = reason
+	})))
+	th := &testReadyHandler{}
+	r.AddHandler("test", th)
+	h := r.handler("test")
+	sub := r.reactorSub("test")
+
+	info, _ := r.IndexInfo("test")
+	assert.False(t, info.Degraded)
+
+	th.rd = replica.Ready{Messages: []replica.Message{{
+		MsgType:        replica.MsgApplyLogs,
+		To:             r.opts.NodeId,
+		ApplyingIndex:  8,
+		AppliedIndex:   8,
+		CommittedIndex: 5,
+	}}}
+	sub.handleReady(h)
+
+	info, _ = r.IndexInfo("test")
+	assert.True(t, info.Degraded)
+	assert.Equal(t, "test", degradedKey)
+	assert.Contains(t, degradedReason, "applied index 8")
+	assert.Empty(t, r.processApplyLogC) // 没有当成正常的应用请求
+}
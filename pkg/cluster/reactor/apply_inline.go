@@ -0,0 +1,20 @@
+package reactor
+
+// applyInline 应用请求是否直接在sub协程里处理（不交给应用协程池）
+// 只有严格顺序模式下日志数量不超过InlineApplyMaxLogs的应用才同步处理，应用结果直接交给处理者，已应用下标和提交等待的语义与异步应用一致
+// 同步应用期间sub协程被占用，只适合应用很快的分区（通过InlineApply按分区选择）
+func (r *Reactor) applyInline(req *applyLogReq) bool {
+	if r.opts.InlineApplyMaxLogs == 0 {
+		return false
+	}
+	if req.committedIndex-req.appyingIndex > r.opts.InlineApplyMaxLogs {
+		return false
+	}
+	if r.applyOrderingMode(req.h.key) != ApplyOrderingStrict {
+		return false
+	}
+	if r.opts.InlineApply != nil && !r.opts.InlineApply(req.h.key) {
+		return false
+	}
+	return true
+}
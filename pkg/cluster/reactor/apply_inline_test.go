@@ -0,0 +1,228 @@
+package reactor
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/WuKongIM/WuKongIM/pkg/cluster/replica"
+	"github.com/stretchr/testify/assert"
+)
+
+// 单副本的处理者，提案追加后立即提交，已提交的日志通过MsgApplyLogs应用
+type testInlineApplyHandler struct {
+	IHandler
+	mu       sync.Mutex
+	inited   bool
+	applying bool
+	last     uint64
+	applied  uint64
+	onApply  func() // 应用结果回到处理者后调用
+}
+
+func (t *testInlineApplyHandler) AppliedIndex() (uint64, error) {
+	return 0, nil
+}
+
+func (t *testInlineApplyHandler) Tick() {
+}
+
+func (t *testInlineApplyHandler) LeaderId() uint64 {
+	return 1
+}
+
+func (t *testInlineApplyHandler) PausePropopose() bool {
+	return false
+}
+
+func (t *testInlineApplyHandler) SpeedLevel() replica.SpeedLevel {
+	return replica.LevelFast
+}
+
+func (t *testInlineApplyHandler) SetSpeedLevel(level replica.SpeedLevel) {
+}
+
+func (t *testInlineApplyHandler) LastLogIndexAndTerm() (uint64, uint32) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.last, 1
+}
+
+func (t *testInlineApplyHandler) isInited() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.inited
+}
+
+func (t *testInlineApplyHandler) appliedIndex() uint64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.applied
+}
+
+// commit 提交一条日志（模拟低流量频道偶尔来一条消息）
+func (t *testInlineApplyHandler) commit() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.last++
+}
+
+func (t *testInlineApplyHandler) HasReady() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return !t.inited || (!t.applying && t.applied < t.last)
+}
+
+func (t *testInlineApplyHandler) Ready() replica.Ready {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.inited {
+		t.inited = true
+		return replica.Ready{}
+	}
+	t.applying = true
+	return replica.Ready{
+		Messages: []replica.Message{{MsgType: replica.MsgApplyLogs, AppliedIndex: t.applied, ApplyingIndex: t.applied, CommittedIndex: t.last}},
+	}
+}
+
+func (t *testInlineApplyHandler) ApplyLogs(startIndex, endIndex uint64) (uint64, error) {
+	return endIndex - startIndex, nil
+}
+
+func (t *testInlineApplyHandler) Step(m replica.Message) error {
+	t.mu.Lock()
+	switch m.MsgType {
+	case replica.MsgPropose:
+		t.last += uint64(len(m.Logs))
+	case replica.MsgApplyLogsResp:
+		t.applying = false
+		t.applied = m.Index
+	}
+	onApply := t.onApply
+	t.mu.Unlock()
+	if m.MsgType == replica.MsgApplyLogsResp && onApply != nil {
+		onApply()
+	}
+	return nil
+}
+
+func TestApplyInlineThreshold(t *testing.T) {
+	r := New(NewOptions(WithInlineApplyMaxLogs(4), WithApplyOrderingMode(func(handleKey string) ApplyOrderingMode {
+		if handleKey == "relaxed" {
+			return ApplyOrderingRelaxed
+		}
+		return ApplyOrderingStrict
+	}), WithInlineApply(func(handleKey string) bool {
+		return handleKey != "busy"
+	})))
+	req := func(key string, applyingIndex, committedIndex uint64) *applyLogReq {
+		return &applyLogReq{h: &handler{key: key}, appyingIndex: applyingIndex, committedIndex: committedIndex}
+	}
+	assert.True(t, r.applyInline(req("ch1", 10, 14)))
+	assert.False(t, r.applyInline(req("ch1", 10, 15))) // 超过阈值交给应用协程池
+	assert.False(t, r.applyInline(req("relaxed", 10, 11)))
+	assert.False(t, r.applyInline(req("busy", 10, 11)))
+
+	// 默认关闭
+	r = New(NewOptions())
+	assert.False(t, r.applyInline(req("ch1", 10, 11)))
+}
+
+// 同步应用和异步应用的提交等待、已应用下标一致
+func TestApplyInlineProposeAndWait(t *testing.T) {
+	setupTestTrace(t)
+
+	for _, inlineMaxLogs := range []uint64{0, 100} {
+		r := New(NewOptions(WithSubReactorNum(1), WithNodeId(1), WithInlineApplyMaxLogs(inlineMaxLogs)))
+		assert.NoError(t, r.Start())
+
+		h := &testInlineApplyHandler{}
+		r.AddHandler("ch1", h)
+		assert.Eventually(t, h.isInited, time.Second, time.Millisecond)
+
+		for i := 1; i <= 10; i++ {
+			results, err := r.ProposeAndWait(context.Background(), "ch1", []replica.Log{{Id: uint64(i), Data: []byte("hello")}})
+			assert.NoError(t, err)
+			assert.Len(t, results, 1)
+			assert.Equal(t, uint64(i), results[0].Index)
+		}
+		assert.Eventually(t, func() bool {
+			return h.appliedIndex() == 10
+		}, time.Second, time.Millisecond)
+		r.Stop()
+	}
+}
+
+// 一轮ready里同步应用的频道超过stepC的容量，应用结果不经过stepC，不会把stepC写满
+func TestApplyInlineManyHandlers(t *testing.T) {
+	setupTestTrace(t)
+
+	r := New(NewOptions(WithSubReactorNum(1), WithNodeId(1), WithInlineApplyMaxLogs(8)))
+
+	const handlerCount = 2000
+	handlers := make([]*testInlineApplyHandler, 0, handlerCount)
+	for i := 0; i < handlerCount; i++ {
+		h := &testInlineApplyHandler{last: 1}
+		r.AddHandler(fmt.Sprintf("ch%d", i), h)
+		handlers = append(handlers, h)
+	}
+	assert.NoError(t, r.Start())
+	defer r.Stop()
+
+	assert.Eventually(t, func() bool {
+		for _, h := range handlers {
+			if h.appliedIndex() != 1 {
+				return false
+			}
+		}
+		return true
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+// 大量低流量频道，同步应用和交给应用协程池的对比
+func BenchmarkApplyInlineVsAsync(b *testing.B) {
+	setupTestTrace(b)
+
+	const channelCount = 1000
+	for _, bc := range []struct {
+		name          string
+		inlineMaxLogs uint64
+	}{{"async", 0}, {"inline", 8}} {
+		b.Run(bc.name, func(b *testing.B) {
+			r := New(NewOptions(WithSubReactorNum(16), WithNodeId(1), WithInlineApplyMaxLogs(bc.inlineMaxLogs)))
+			if err := r.Start(); err != nil {
+				b.Fatal(err)
+			}
+			defer r.Stop()
+
+			var wg sync.WaitGroup
+			handlers := make([]*testInlineApplyHandler, 0, channelCount)
+			keys := make([]string, 0, channelCount)
+			for i := 0; i < channelCount; i++ {
+				h := &testInlineApplyHandler{onApply: wg.Done}
+				key := fmt.Sprintf("ch%d", i)
+				r.AddHandler(key, h)
+				handlers = append(handlers, h)
+				keys = append(keys, key)
+			}
+			for _, h := range handlers {
+				for !h.isInited() {
+					time.Sleep(time.Millisecond)
+				}
+			}
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				wg.Add(channelCount)
+				for j, h := range handlers {
+					h.commit()
+					r.Step(keys[j], replica.Message{MsgType: replica.MsgBeat}) // 唤醒sub处理ready
+				}
+				wg.Wait()
+			}
+		})
+	}
+}
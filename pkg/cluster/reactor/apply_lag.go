@@ -0,0 +1,112 @@
+package reactor
+
+import (
+	"context"
+	"sync"
+
+	"go.uber.org/atomic"
+)
+
+// applyLag 跟踪已提交和已应用的日志下标，应用落后太多时暂停提案，避免已提交未应用的日志在内存里无限堆积
+type applyLag struct {
+	committedIndex atomic.Uint64 // 已提交的日志下标
+	appliedIndex   atomic.Uint64 // 已应用的日志下标
+	throttled      atomic.Bool   // 是否因为应用落后而暂停了提案
+
+	mu    sync.Mutex
+	waitC chan struct{} // 等待应用追上的通知
+}
+
+// lag 已提交但未应用的日志数量
+func (a *applyLag) lag() uint64 {
+	committed := a.committedIndex.Load()
+	applied := a.appliedIndex.Load()
+	if committed <= applied {
+		return 0
+	}
+	return committed - applied
+}
+
+func (a *applyLag) didCommit(index uint64) {
+	if index > a.committedIndex.Load() {
+		a.committedIndex.Store(index)
+	}
+}
+
+// didApply 应用进度更新，落后数量降到maxLag以内时唤醒等待的提案
+func (a *applyLag) didApply(index uint64, maxLag uint64) {
+	if index > a.appliedIndex.Load() {
+		a.appliedIndex.Store(index)
+	}
+	if !a.throttled.Load() || a.lag() > maxLag {
+		return
+	}
+	a.mu.Lock()
+	if a.waitC != nil {
+		close(a.waitC)
+		a.waitC = nil
+	}
+	a.throttled.Store(false)
+	a.mu.Unlock()
+}
+
+// waitCatchUp 应用落后超过maxLag时等待应用追上，maxLag为0表示不限制
+func (a *applyLag) waitCatchUp(ctx context.Context, maxLag uint64) error {
+	if maxLag == 0 {
+		return nil
+	}
+	for a.lag() > maxLag {
+		a.mu.Lock()
+		a.throttled.Store(true)
+		if a.lag() <= maxLag { // 先标记再检查一次，避免错过唤醒
+			a.mu.Unlock()
+			return nil
+		}
+		if a.waitC == nil {
+			a.waitC = make(chan struct{})
+		}
+		waitC := a.waitC
+		a.mu.Unlock()
+
+		select {
+		case <-waitC:
+		case <-ctx.Done():
+			return ErrApplyLagThrottled
+		}
+	}
+	return nil
+}
+
+func (a *applyLag) reset() {
+	a.committedIndex.Store(0)
+	a.appliedIndex.Store(0)
+	a.mu.Lock()
+	if a.waitC != nil {
+		close(a.waitC)
+		a.waitC = nil
+	}
+	a.throttled.Store(false)
+	a.mu.Unlock()
+}
+
+// ApplyLagInfo 应用落后信息
+type ApplyLagInfo struct {
+	CommittedIndex uint64 // 已提交的日志下标
+	AppliedIndex   uint64 // 已应用的日志下标
+	Lag            uint64 // 已提交未应用的日志数量
+	Throttled      bool   // 是否因为应用落后暂停了提案
+}
+
+// ApplyLag 获取handler的应用落后信息
+func (r *Reactor) ApplyLag(key string) (ApplyLagInfo, bool) {
+	h := r.handler(key)
+	if h == nil {
+		return ApplyLagInfo{}, false
+	}
+	return ApplyLagInfo{
+		CommittedIndex: h.applyLag.committedIndex.Load(),
+		AppliedIndex:   h.applyLag.appliedIndex.Load(),
+		Lag:            h.applyLag.lag(),
+		Throttled:      h.applyLag.throttled.Load(),
+	}, true
+}
@@ -0,0 +1,87 @@
+package reactor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplyLagThrottle(t *testing.T) {
+	var a applyLag
+	maxLag := uint64(10)
+
+	// 没有落后，不需要等待
+	a.didCommit(5)
+	err := a.waitCatchUp(context.Background(), maxLag)
+	assert.NoError(t, err)
+	assert.False(t, a.throttled.Load())
+
+	// 提交了很多日志，但是应用很慢
+	a.didCommit(100)
+	assert.Equal(t, uint64(100), a.lag())
+
+	// 模拟慢的应用
+	go func() {
+		for i := uint64(10); i <= 100; i += 10 {
+			time.Sleep(time.Millisecond * 10)
+			a.didApply(i, maxLag)
+		}
+	}()
+
+	start := time.Now()
+	err = a.waitCatchUp(context.Background(), maxLag)
+	assert.NoError(t, err)
+	assert.True(t, time.Since(start) >= time.Millisecond*50)
+	assert.LessOrEqual(t, a.lag(), maxLag)
+	assert.False(t, a.throttled.Load())
+}
+
+func TestApplyLagThrottleTimeout(t *testing.T) {
+	var a applyLag
+	a.didCommit(100)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*20)
+	defer cancel()
+	err := a.waitCatchUp(ctx, 10)
+	assert.Equal(t, ErrApplyLagThrottled, err)
+	assert.True(t, a.throttled.Load())
+
+	// 不限制
+	err = a.waitCatchUp(context.Background(), 0)
+	assert.NoError(t, err)
+}
+
+// 重启后的处理者，存储里已经应用过日志
+type testRestartHandler struct {
+	IHandler
+	applied uint64
+	last    uint64
+}
+
+func (t *testRestartHandler) AppliedIndex() (uint64, error) {
+	return t.applied, nil
+}
+
+func (t *testRestartHandler) LastLogIndexAndTerm() (uint64, uint32) {
+	return t.last, 1
+}
+
+// 重启后应用进度从存储里的已应用下标开始，不会把之前应用过的日志当成落后
+func TestApplyLagSeededOnRestart(t *testing.T) {
+	r := New(NewOptions(WithSubReactorNum(1), WithNodeId(1), WithMaxApplyLag(50)))
+	r.AddHandler("test", &testRestartHandler{applied: 1000, last: 1020})
+
+	info, ok := r.ApplyLag("test")
+	assert.True(t, ok)
+	assert.Equal(t, uint64(1000), info.CommittedIndex)
+	assert.Equal(t, uint64(1000), info.AppliedIndex)
+	assert.Equal(t, uint64(0), info.Lag)
+
+	// 重启后第一次提交，只计算没有应用的部分
+	h := r.handler("test")
+	h.applyLag.didCommit(1020)
+	assert.Equal(t, uint64(20), h.applyLag.lag())
+	assert.NoError(t, h.applyLag.waitCatchUp(context.Background(), r.opts.MaxApplyLag))
+}
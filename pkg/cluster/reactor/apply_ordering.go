@@ -0,0 +1,186 @@
+package reactor
+
+import (
+	"github.com/WuKongIM/WuKongIM/pkg/cluster/replica"
+	"go.uber.org/zap"
+)
+
+// ApplyOrderingMode 日志应用的顺序模式
+type ApplyOrderingMode int
+
+const (
+	// ApplyOrderingStrict 严格按日志顺序应用（默认），状态机需要这种模式
+	ApplyOrderingStrict ApplyOrderingMode = iota
+	// ApplyOrderingRelaxed 日志之间相互独立，不要求顺序，分段并行应用提高吞吐
+	// 已应用下标只推进到连续应用完成的前缀，保证重启恢复时不会跳过没有应用的日志
+	ApplyOrderingRelaxed
+)
+
+// 宽松模式下每段最少的日志数量，避免日志很少时也拆成很多段
+const relaxedApplyMinBatch = 16
+
+func (a ApplyOrderingMode) String() string {
+	switch a {
+	case ApplyOrderingStrict:
+		return "strict"
+	case ApplyOrderingRelaxed:
+		return "relaxed"
+	}
+	return "unknown"
+}
+
+func (r *Reactor) applyOrderingMode(handleKey string) ApplyOrderingMode {
+	if r.opts.ApplyOrderingMode == nil {
+		return ApplyOrderingStrict
+	}
+	return r.opts.ApplyOrderingMode(handleKey)
+}
+
+// 宽松模式应用日志
+func (r *Reactor) processApplyLogRelaxed(req *applyLogReq) {
+	startIndex := req.appyingIndex + 1
+	endIndex := req.committedIndex + 1
+
+	if !r.opts.IsCommittedAfterApplied {
+		// 提交日志
+		req.h.didCommit(startIndex, endIndex)
+	}
+
+	appliedEnd, appliedSize, remain, err := applyRelaxed(startIndex, endIndex, r.opts.ApplyRelaxedPoolSize, relaxedApplyMinBatch, req.h.relaxedApplied, req.h.handler.ApplyLogs, func(prefixEnd uint64) {
+		req.h.applyLag.didApply(prefixEnd-1, r.opts.MaxApplyLag)
+	})
+	req.h.relaxedApplied = remain
+	if err != nil {
+		r.Error("relaxed apply logs failed", zap.Error(err), zap.String("handler", req.h.key), zap.Uint64("startIndex", startIndex), zap.Uint64("endIndex", endIndex), zap.Uint64("appliedEnd", appliedEnd))
+	}
+	if appliedEnd <= startIndex { // 一条都没有应用成功
+		r.Step(req.h.key, replica.Message{
+			MsgType: replica.MsgApplyLogsResp,
+			Reject:  true,
+		})
+		return
+	}
+
+	if r.opts.IsCommittedAfterApplied {
+		// 提交日志
+		req.h.didCommit(startIndex, appliedEnd)
+	}
+
+	// 已应用的日志不再需要提案元数据
+	req.h.removeProposeValues(startIndex, appliedEnd)
+
+	r.maybeSnapshot(req.h, req.appyingIndex, appliedEnd-1)
+
+	// 只上报连续应用完成的前缀，剩下的日志下次重新应用
+	r.Step(req.h.key, replica.Message{
+		MsgType:     replica.MsgApplyLogsResp,
+		Index:       appliedEnd - 1,
+		AppliedSize: appliedSize,
+	})
+}
+
+type applyRelaxedResult struct {
+	seg  int
+	size uint64
+	err  error
+}
+
+// applyRange 宽松模式下的一段日志[start, end)
+type applyRange struct {
+	start uint64
+	end   uint64
+	size  uint64 // 这段日志应用的数据大小
+}
+
+// applyRelaxed 把[startIndex,endIndex)分成最多poolSize段并行应用，done里已经应用完成的段（按下标排序）不再应用
+// 每完成一段，如果连续完成的前缀推进了，调用onPrefix(前缀的结束下标，不包含)，前缀只会单调递增
+// 返回连续应用完成的前缀的结束下标（不包含）、这些日志应用的数据大小和前缀之后已经应用完成的段，
+// 中间某段失败时前缀停在失败的段之前，之后已经应用完成的段下次传回done，不会重复应用
+func applyRelaxed(startIndex, endIndex uint64, poolSize int, minBatch uint64, done []applyRange, apply func(startIndex, endIndex uint64) (uint64, error), onPrefix func(prefixEnd uint64)) (uint64, uint64, []applyRange, error) {
+	if endIndex <= startIndex {
+		return startIndex, 0, nil, nil
+	}
+	if poolSize <= 0 {
+		poolSize = 1
+	}
+	total := endIndex - startIndex
+	batch := (total + uint64(poolSize) - 1) / uint64(poolSize)
+	if batch < minBatch {
+		batch = minBatch
+	}
+
+	// 分段，已经应用完成的段原样保留
+	var (
+		segs    []applyRange
+		results []*applyRelaxedResult
+		next    = startIndex
+	)
+	split := func(end uint64) {
+		for s := next; s < end; s += batch {
+			segs = append(segs, applyRange{start: s, end: min(s+batch, end)})
+			results = append(results, nil)
+		}
+		next = end
+	}
+	for _, d := range done {
+		if d.start < next || d.start >= endIndex {
+			continue
+		}
+		split(d.start)
+		seg := applyRange{start: d.start, end: min(d.end, endIndex), size: d.size}
+		results = append(results, &applyRelaxedResult{seg: len(segs), size: d.size})
+		segs = append(segs, seg)
+		next = seg.end
+	}
+	split(endIndex)
+
+	resultC := make(chan applyRelaxedResult, len(segs))
+	running := 0
+	for i, seg := range segs {
+		if results[i] != nil {
+			continue
+		}
+		running++
+		go func(seg int, s, e uint64) {
+			size, err := apply(s, e)
+			resultC <- applyRelaxedResult{seg: seg, size: size, err: err}
+		}(i, seg.start, seg.end)
+	}
+
+	var (
+		prefix   = 0 // 连续完成的段数
+		size     uint64
+		firstErr error
+	)
+	advance := func() {
+		advanced := false
+		for prefix < len(segs) && results[prefix] != nil && results[prefix].err == nil {
+			size += results[prefix].size
+			prefix++
+			advanced = true
+		}
+		if advanced && onPrefix != nil {
+			onPrefix(segs[prefix-1].end)
+		}
+	}
+	advance()
+	for i := 0; i < running; i++ {
+		res := <-resultC
+		results[res.seg] = &res
+		if res.err != nil && firstErr == nil {
+			firstErr = res.err
+		}
+		advance()
+	}
+
+	var remain []applyRange
+	for i := prefix; i < len(segs); i++ {
+		if results[i] != nil && results[i].err == nil {
+			remain = append(remain, applyRange{start: segs[i].start, end: segs[i].end, size: results[i].size})
+		}
+	}
+	if prefix == 0 {
+		return startIndex, 0, remain, firstErr
+	}
+	return segs[prefix-1].end, size, remain, firstErr
+}
@@ -0,0 +1,113 @@
+package reactor
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/atomic"
+)
+
+// 宽松模式并行应用，已应用下标单调递增
+func TestApplyRelaxed(t *testing.T) {
+	var (
+		mu            sync.Mutex
+		applied       = make(map[uint64]bool)
+		running       atomic.Int32
+		maxConcurrent atomic.Int32
+		concurrent    = make(chan struct{})
+		concurrentOne sync.Once
+	)
+	apply := func(startIndex, endIndex uint64) (uint64, error) {
+		n := running.Inc()
+		defer running.Dec()
+		for {
+			m := maxConcurrent.Load()
+			if n <= m || maxConcurrent.CAS(m, n) {
+				break
+			}
+		}
+		if n >= 2 {
+			concurrentOne.Do(func() { close(concurrent) })
+		}
+		// 等到有两段同时在应用（并行），不依赖耗时
+		select {
+		case <-concurrent:
+		case <-time.After(time.Second * 5):
+		}
+		mu.Lock()
+		for i := startIndex; i < endIndex; i++ {
+			applied[i] = true
+		}
+		mu.Unlock()
+		return endIndex - startIndex, nil
+	}
+
+	var prefixes []uint64
+	appliedEnd, size, remain, err := applyRelaxed(1, 201, 8, 16, nil, apply, func(prefixEnd uint64) {
+		prefixes = append(prefixes, prefixEnd)
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(201), appliedEnd)
+	assert.Equal(t, uint64(200), size)
+	assert.Empty(t, remain)
+	assert.Len(t, applied, 200)
+	assert.GreaterOrEqual(t, maxConcurrent.Load(), int32(2))
+
+	// 已应用前缀单调递增，且每次前缀内的日志都已应用
+	assert.NotEmpty(t, prefixes)
+	for i := 1; i < len(prefixes); i++ {
+		assert.Greater(t, prefixes[i], prefixes[i-1])
+	}
+	assert.Equal(t, uint64(201), prefixes[len(prefixes)-1])
+}
+
+// 中间的段应用失败，已应用下标停在失败的段之前，之后已经应用完成的段重试时不再应用
+func TestApplyRelaxedSegmentFailed(t *testing.T) {
+	errApply := errors.New("apply failed")
+	var (
+		mu      sync.Mutex
+		applies = make(map[uint64]int)
+		failed  atomic.Bool
+	)
+	failed.Store(true)
+	apply := func(startIndex, endIndex uint64) (uint64, error) {
+		if failed.Load() && startIndex <= 50 && endIndex > 50 {
+			return 0, errApply
+		}
+		mu.Lock()
+		for i := startIndex; i < endIndex; i++ {
+			applies[i]++
+		}
+		mu.Unlock()
+		return endIndex - startIndex, nil
+	}
+	var lastPrefix uint64
+	appliedEnd, _, remain, err := applyRelaxed(1, 101, 4, 1, nil, apply, func(prefixEnd uint64) {
+		assert.Greater(t, prefixEnd, lastPrefix)
+		lastPrefix = prefixEnd
+	})
+	assert.Equal(t, errApply, err)
+	assert.Equal(t, uint64(26), appliedEnd) // 每段25条，第二段（26~50）失败
+	assert.Equal(t, uint64(26), lastPrefix)
+	assert.Equal(t, []applyRange{{start: 51, end: 76, size: 25}, {start: 76, end: 101, size: 25}}, remain)
+
+	// 重试时只应用失败的段
+	failed.Store(false)
+	appliedEnd, size, remain, err := applyRelaxed(26, 101, 4, 1, remain, apply, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(101), appliedEnd)
+	assert.Equal(t, uint64(75), size)
+	assert.Empty(t, remain)
+	for i := uint64(1); i < 101; i++ {
+		assert.Equal(t, 1, applies[i], "index %d", i)
+	}
+
+	// 第一段就失败，一条都没有应用
+	failed.Store(true)
+	appliedEnd, _, _, err = applyRelaxed(50, 60, 4, 16, nil, apply, nil)
+	assert.Equal(t, errApply, err)
+	assert.Equal(t, uint64(50), appliedEnd)
+}
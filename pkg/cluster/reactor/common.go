@@ -15,6 +15,15 @@ var (
 	ErrReactorSubStopped = errors.New("reactor sub stopped")
 	ErrNotLeader         = errors.New("not leader")
 	ErrPausePropopose    = errors.New("pause propose")
+	ErrApplyLagThrottled = errors.New("propose throttled, apply lag too large")
+	ErrEmptyPayload      = errors.New("propose log data is empty")
+	ErrProposeDropped    = errors.New("propose dropped")
+	ErrHandlerRemoved    = errors.New("handler removed")
+	ErrHandlerNotFound   = errors.New("handler not found")
+	ErrFlushTimeout      = errors.New("flush pending appends timeout")
+	ErrMessageTooLarge   = errors.New("message too large")
+	// ErrProposeIndexNotContiguous 一批提案分配到的日志下标不连续（不应该出现，出现说明下标分配有bug）
+	ErrProposeIndexNotContiguous = errors.New("propose log indexes not contiguous")
 )
 
 var hashPool = sync.Pool{
@@ -0,0 +1,60 @@
+package reactor
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// flushCheckInterval FlushAll检查日志是否已存储的间隔
+const flushCheckInterval = time.Millisecond * 10
+
+// FlushAll 等待所有处理者已追加的日志都存储完成（存储追加日志时是同步落盘的），用于停止前保证已提交但还没存储的日志不会丢失
+// 只等待调用时reactor已经处理过的日志，之后新追加的日志不等待；ctx结束前没有全部存储完成返回ErrFlushTimeout
+func (r *Reactor) FlushAll(ctx context.Context) error {
+	targets := make(map[string]uint64)
+	r.mu.RLock()
+	subs := r.subReactors
+	r.mu.RUnlock()
+	for _, sub := range subs {
+		sub.handlers.iterator(func(h *handler) bool {
+			if lastIndex := h.lastIndex.Load(); lastIndex > h.storedIndex.Load() {
+				targets[h.key] = lastIndex
+			}
+			return true
+		})
+	}
+	if len(targets) == 0 {
+		return nil
+	}
+
+	tick := time.NewTicker(flushCheckInterval)
+	defer tick.Stop()
+	for {
+		for key, target := range targets {
+			h := r.handler(key)
+			if h == nil || h.storedTo(target) { // 处理者已移除的不再等待
+				delete(targets, key)
+			}
+		}
+		if len(targets) == 0 {
+			return nil
+		}
+		select {
+		case <-tick.C:
+		case <-ctx.Done():
+			r.Warn("flush pending appends timeout", zap.Int("pending", len(targets)))
+			return fmt.Errorf("%w: %d handlers pending", ErrFlushTimeout, len(targets))
+		case <-r.stopper.ShouldStop():
+			return ErrReactorSubStopped
+		}
+	}
+}
+
+// storedTo 日志是否已存储到index（日志被截断后以最新的日志下标为准）
+func (h *handler) storedTo(index uint64) bool {
+	storedIndex := h.storedIndex.Load()
+	return storedIndex >= index || storedIndex >= h.lastIndex.Load()
+}
@@ -0,0 +1,226 @@
+package reactor
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/WuKongIM/WuKongIM/pkg/cluster/replica"
+	"github.com/stretchr/testify/assert"
+)
+
+// 追加日志后由存储异步写入的处理者（和副本一样，添加后先处理一次初始化的ready才开始追加日志）
+type testStoreHandler struct {
+	IHandler
+	mu       sync.Mutex
+	inited   bool
+	logs     []replica.Log
+	storing  bool
+	storaged uint64
+}
+
+func (t *testStoreHandler) AppliedIndex() (uint64, error) {
+	return 0, nil
+}
+
+func (t *testStoreHandler) isInited() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.inited
+}
+
+func (t *testStoreHandler) Tick() {
+}
+
+func (t *testStoreHandler) LeaderId() uint64 {
+	return 1
+}
+
+func (t *testStoreHandler) SpeedLevel() replica.SpeedLevel {
+	return replica.LevelFast
+}
+
+func (t *testStoreHandler) SetSpeedLevel(level replica.SpeedLevel) {
+}
+
+func (t *testStoreHandler) SetLeaderTermStartIndex(term uint32, index uint64) error {
+	return nil
+}
+
+func (t *testStoreHandler) LastLogIndexAndTerm() (uint64, uint32) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return uint64(len(t.logs)), 1
+}
+
+func (t *testStoreHandler) HasReady() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return !t.inited || (!t.storing && t.storaged < uint64(len(t.logs)))
+}
+
+func (t *testStoreHandler) Ready() replica.Ready {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.inited {
+		t.inited = true
+		return replica.Ready{}
+	}
+	t.storing = true
+	return replica.Ready{
+		Messages: []replica.Message{{MsgType: replica.MsgStoreAppend, Logs: t.logs[t.storaged:]}},
+	}
+}
+
+func (t *testStoreHandler) Step(m replica.Message) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	switch m.MsgType {
+	case replica.MsgPropose:
+		for _, lg := range m.Logs {
+			lg.Index = uint64(len(t.logs)) + 1
+			lg.Term = 1
+			t.logs = append(t.logs, lg)
+		}
+	case replica.MsgStoreAppendResp:
+		t.storing = false
+		if !m.Reject {
+			t.storaged = m.Index
+		}
+	}
+	return nil
+}
+
+// 存储很慢的请求，gate不为nil时等gate关闭后才写入
+type testSlowStoreRequest struct {
+	IRequest
+	mu     sync.Mutex
+	stored map[string]uint64
+	gate   chan struct{}
+}
+
+func (t *testSlowStoreRequest) AppendLogBatch(reqs []AppendLogReq) error {
+	if t.gate != nil {
+		<-t.gate
+	}
+	time.Sleep(time.Millisecond * 20)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, req := range reqs {
+		t.stored[req.HandleKey] = req.Logs[len(req.Logs)-1].Index
+	}
+	return nil
+}
+
+func (t *testSlowStoreRequest) storedIndex(key string) uint64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.stored[key]
+}
+
+// FlushAll返回时所有已追加的日志都已存储
+func TestFlushAll(t *testing.T) {
+	req := &testSlowStoreRequest{stored: make(map[string]uint64)}
+	r := New(NewOptions(WithSubReactorNum(2), WithNodeId(1), WithRequest(req)))
+	assert.NoError(t, r.Start())
+	defer r.Stop()
+
+	keys := []string{"ch1", "ch2", "ch3"}
+	for _, key := range keys {
+		h := &testStoreHandler{}
+		r.AddHandler(key, h)
+		assert.Eventually(t, h.isInited, time.Second, time.Millisecond)
+	}
+	for i := 0; i < 10; i++ {
+		for _, key := range keys {
+			r.Step(key, replica.Message{MsgType: replica.MsgPropose, Logs: []replica.Log{{Id: uint64(i + 1), Data: []byte("hello")}}})
+		}
+	}
+	// 等reactor处理完提案（日志已追加但还没存储完）
+	assert.Eventually(t, func() bool {
+		for _, key := range keys {
+			info, _ := r.IndexInfo(key)
+			if info.LastLogIndex != 10 {
+				return false
+			}
+		}
+		return true
+	}, time.Second, time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	assert.NoError(t, r.FlushAll(ctx))
+	for _, key := range keys {
+		assert.Equal(t, uint64(10), req.storedIndex(key))
+		assert.Equal(t, uint64(10), r.handler(key).storedIndex.Load())
+	}
+
+	// 没有待存储的日志直接返回
+	assert.NoError(t, r.FlushAll(ctx))
+}
+
+// 存储一直没有完成，超时返回ErrFlushTimeout
+func TestFlushAllTimeout(t *testing.T) {
+	req := &testSlowStoreRequest{stored: make(map[string]uint64), gate: make(chan struct{})}
+	r := New(NewOptions(WithSubReactorNum(1), WithNodeId(1), WithRequest(req)))
+	assert.NoError(t, r.Start())
+	defer r.Stop()
+
+	h := &testStoreHandler{}
+	r.AddHandler("ch1", h)
+	assert.Eventually(t, h.isInited, time.Second, time.Millisecond)
+	r.Step("ch1", replica.Message{MsgType: replica.MsgPropose, Logs: []replica.Log{{Id: 1, Data: []byte("hello")}}})
+	assert.Eventually(t, func() bool {
+		info, _ := r.IndexInfo("ch1")
+		return info.LastLogIndex == 1
+	}, time.Second, time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+	assert.ErrorIs(t, r.FlushAll(ctx), ErrFlushTimeout)
+	close(req.gate)
+
+	ctx2, cancel2 := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel2()
+	assert.NoError(t, r.FlushAll(ctx2))
+	assert.Equal(t, uint64(1), req.storedIndex("ch1"))
+}
+
+// 单个请求追加失败的存储
+type testRejectStoreRequest struct {
+	IRequest
+	rejectKey string
+}
+
+func (t *testRejectStoreRequest) AppendLogBatch(reqs []AppendLogReq) error {
+	for i, req := range reqs {
+		if req.HandleKey == t.rejectKey {
+			reqs[i].Err = errors.New("conflict")
+		}
+	}
+	return nil
+}
+
+// 批量里单个请求失败时只拒绝这个请求，其他请求照常返回存储成功
+func TestStoreAppendRejectSingleReq(t *testing.T) {
+	r := New(NewOptions(WithSubReactorNum(1), WithNodeId(1), WithRequest(&testRejectStoreRequest{rejectKey: "bad"})))
+	r.AddHandler("bad", &testStoreHandler{})
+	r.AddHandler("good", &testStoreHandler{})
+	sub := r.reactorSub("bad")
+
+	r.processStoreAppend([]AppendLogReq{
+		{HandleKey: "bad", Logs: []replica.Log{{Index: 1, Term: 1}}},
+		{HandleKey: "good", Logs: []replica.Log{{Index: 1, Term: 1}}},
+	})
+
+	resps := make(map[string]replica.Message)
+	for len(sub.stepC) > 0 {
+		req := <-sub.stepC
+		resps[req.handlerKey] = req.msg
+	}
+	assert.True(t, resps["bad"].Reject)
+	assert.False(t, resps["good"].Reject)
+	assert.Equal(t, uint64(1), resps["good"].Index)
+}
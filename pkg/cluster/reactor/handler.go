@@ -3,11 +3,14 @@ package reactor
 import (
 	"fmt"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/WuKongIM/WuKongIM/pkg/cluster/replica"
+	"github.com/WuKongIM/WuKongIM/pkg/trace"
 	"github.com/WuKongIM/WuKongIM/pkg/wklog"
 	"go.uber.org/atomic"
+	"go.uber.org/zap"
 )
 
 type IHandler interface {
@@ -80,11 +83,28 @@ type handler struct {
 	handler  IHandler
 	msgQueue *MessageQueue
 
-	lastIndex atomic.Uint64 // 当前频道最后一条日志索引
+	lastIndex   atomic.Uint64 // 当前频道最后一条日志索引（reactor sub处理ready时更新）
+	storedIndex atomic.Uint64 // 已存储的最后一条日志索引（reactor sub收到存储成功的返回时更新）
 
 	proposeWait *proposeWait // 提案等待
+	ackTracer   *ackTracer   // 采样提案的副本确认跟踪
 
-	proposeIntervalTick int // 提案间隔tick数量
+	readIndexWait *readIndexWait // 线性一致读等待
+
+	proposeValuesMu sync.RWMutex
+	proposeValues   map[uint64]map[string]string // 日志下标对应的提案元数据，应用后删除
+
+	proposeIntervalTick atomic.Int64 // 提案间隔tick数量（提案协程会重置）
+
+	applyLag applyLag // 应用落后情况
+
+	snapshot snapshotTrigger // 按日志数量触发快照
+
+	relaxedApplied []applyRange // 宽松模式下已经应用完成、但还没有连续到已应用下标的段（下次应用时跳过）
+
+	logCache logCache // 最近存储的日志，同步日志时优先从这里获取
+
+	degraded atomic.Bool // 是否处于异常状态（例如已应用下标超过已提交下标），异常后不再应用日志，需要人工介入
 
 	hardState replica.HardState
 
@@ -113,11 +133,49 @@ func (h *handler) init(key string, handler IHandler, r *Reactor) {
 	h.key = key
 	h.handler = handler
 	h.msgQueue = r.newMessageQueue()
-	h.lastIndex.Store(0)
+	// 从存储加载的日志都已经存储，之后追加和截断日志时更新
+	lastIndex, _ := handler.LastLogIndexAndTerm()
+	h.lastIndex.Store(lastIndex)
+	h.storedIndex.Store(lastIndex)
 
 	h.proposeWait = newProposeWait(fmt.Sprintf("[%d]%s", r.opts.NodeId, key))
+	h.proposeWait.submit = r.submitProposeResult
+	kind := r.opts.clusterKind()
+	h.proposeWait.waitersAdd = func(v int64) {
+		trace.GlobalTrace.Metrics.Cluster().CommitWaitersAdd(kind, v)
+	}
+	h.proposeWait.waitGapRecord = func(v int64) {
+		trace.GlobalTrace.Metrics.Cluster().CommitWaitGapRecord(kind, v)
+	}
+	h.ackTracer = newAckTracer(r.opts.ProposeAckTraceMaxPending)
+	h.readIndexWait = newReadIndexWait()
+	h.logCache.init(r.opts.LogCacheMaxBytes)
 	h.sync.syncTimeout = 5 * time.Second
+	h.initApplyLag()
+
+}
 
+// initApplyLag 用存储里的已应用下标初始化应用进度，重启后不会从0开始计算应用落后
+// 和副本初始化一致，已提交下标从已应用下标开始（不超过最后一条日志），之后由MsgApplyLogs推进
+func (h *handler) initApplyLag() {
+	appliedIndex, err := h.handler.AppliedIndex()
+	if err != nil {
+		h.Warn("get applied index failed", zap.Error(err))
+		return
+	}
+	if lastIndex := h.lastIndex.Load(); appliedIndex > lastIndex {
+		appliedIndex = lastIndex
+	}
+	h.applyLag.didCommit(appliedIndex)
+	h.applyLag.didApply(appliedIndex, 0)
+}
+
+// setLastIndex 更新最后一条日志下标，日志被截断（或追加了覆盖旧日志的新日志）时已存储下标也不能超过最后一条日志
+func (h *handler) setLastIndex(lastIndex uint64) {
+	h.lastIndex.Store(lastIndex)
+	if lastIndex < h.storedIndex.Load() {
+		h.storedIndex.Store(lastIndex)
+	}
 }
 
 func (h *handler) reset() {
@@ -127,7 +185,19 @@ func (h *handler) reset() {
 	h.msgQueue = nil
 	h.msgQueue = nil
 	h.proposeWait = nil
-	h.proposeIntervalTick = 0
+	h.ackTracer = nil
+	h.readIndexWait = nil
+	h.proposeValuesMu.Lock()
+	h.proposeValues = nil
+	h.proposeValuesMu.Unlock()
+	h.proposeIntervalTick.Store(0)
+	h.storedIndex.Store(0)
+	h.lastIndex.Store(0)
+	h.applyLag.reset()
+	h.snapshot.reset()
+	h.relaxedApplied = nil
+	h.logCache.reset()
+	h.degraded.Store(false)
 	h.resetSync()
 	h.hardState = replica.HardState{}
 }
@@ -151,12 +221,73 @@ func (h *handler) hasReady() bool {
 func (h *handler) setHardState(hd replica.HardState) {
 	h.hardState = hd
 	h.handler.SetHardState(hd)
+	if hd.LeaderId != h.r.opts.NodeId { // 不再是领导（变为追随者），还没应用的提案元数据不会再用到
+		h.clearProposeValues()
+	}
 }
 
 func (h *handler) didPropose(key string, logId uint64, logIndex uint64) {
 	h.proposeWait.didPropose(key, logId, logIndex)
 }
 
+func (h *handler) didProposeBatch(key string, firstLogIndex uint64) {
+	h.proposeWait.didProposeBatch(key, firstLogIndex)
+}
+
+func (h *handler) dropPropose(key string) {
+	h.proposeWait.drop(key)
+}
+
+func (h *handler) rejectPropose(key string, err error) {
+	h.proposeWait.reject(key, err)
+}
+
+func (h *handler) setProposeValues(logIndex uint64, values map[string]string) {
+	h.proposeValuesMu.Lock()
+	defer h.proposeValuesMu.Unlock()
+	if h.proposeValues == nil {
+		h.proposeValues = make(map[uint64]map[string]string)
+	}
+	h.proposeValues[logIndex] = values
+}
+
+func (h *handler) getProposeValues(logIndex uint64) map[string]string {
+	h.proposeValuesMu.RLock()
+	defer h.proposeValuesMu.RUnlock()
+	return h.proposeValues[logIndex]
+}
+
+func (h *handler) fillProposeValues(logs []replica.Log) {
+	h.proposeValuesMu.RLock()
+	defer h.proposeValuesMu.RUnlock()
+	if len(h.proposeValues) == 0 {
+		return
+	}
+	for i := range logs {
+		logs[i].Values = h.proposeValues[logs[i].Index]
+	}
+}
+
+func (h *handler) clearProposeValues() {
+	h.proposeValuesMu.Lock()
+	defer h.proposeValuesMu.Unlock()
+	h.proposeValues = nil
+}
+
+// 删除[startLogIndex,endLogIndex)之间的提案元数据
+func (h *handler) removeProposeValues(startLogIndex uint64, endLogIndex uint64) {
+	h.proposeValuesMu.Lock()
+	defer h.proposeValuesMu.Unlock()
+	if len(h.proposeValues) == 0 {
+		return
+	}
+	for index := range h.proposeValues {
+		if index >= startLogIndex && index < endLogIndex {
+			delete(h.proposeValues, index)
+		}
+	}
+}
+
 func (h *handler) didCommit(startLogIndex uint64, endLogIndex uint64) {
 	h.proposeWait.didCommit(startLogIndex, endLogIndex)
 }
@@ -178,7 +309,7 @@ func (h *handler) lastLogIndexAndTerm() (uint64, uint32) {
 
 func (h *handler) tick() {
 	h.handler.Tick()
-	h.proposeIntervalTick++
+	h.proposeIntervalTick.Inc()
 
 	if h.r.opts.AutoSlowDownOn {
 		if h.syncTimeoutTick >= h.r.opts.SyncTimeoutMaxTick { // 同步超时超过指定次数，则停止同步
@@ -189,7 +320,7 @@ func (h *handler) tick() {
 }
 
 func (h *handler) resetProposeIntervalTick() {
-	h.proposeIntervalTick = 0
+	h.proposeIntervalTick.Store(0)
 	h.resetSlowDown()
 }
 
@@ -235,30 +366,31 @@ func (h *handler) resetSlowDown() {
 func (h *handler) shouldSlowDown() bool {
 
 	speedLevel := h.speedLevel()
+	proposeIntervalTick := int(h.proposeIntervalTick.Load())
 
 	switch speedLevel {
 	case replica.LevelFast:
-		if h.proposeIntervalTick > LevelFastTick {
+		if proposeIntervalTick > LevelFastTick {
 			return true
 		}
 		return false
 	case replica.LevelNormal:
-		if h.proposeIntervalTick > LevelNormal {
+		if proposeIntervalTick > LevelNormal {
 			return true
 		}
 		return false
 	case replica.LevelMiddle:
-		if h.proposeIntervalTick > LevelMiddle {
+		if proposeIntervalTick > LevelMiddle {
 			return true
 		}
 		return false
 	case replica.LevelSlow:
-		if h.proposeIntervalTick > LevelSlow {
+		if proposeIntervalTick > LevelSlow {
 			return true
 		}
 		return false
 	case replica.LevelSlowest:
-		if h.proposeIntervalTick > LevelSlowest {
+		if proposeIntervalTick > LevelSlowest {
 			return true
 		}
 		return false
@@ -269,7 +401,7 @@ func (h *handler) shouldSlowDown() bool {
 }
 
 func (h *handler) shouldDestroy() bool {
-	return h.proposeIntervalTick > LevelDestroy
+	return int(h.proposeIntervalTick.Load()) > LevelDestroy
 }
 
 func (h *handler) isLeader() bool {
@@ -0,0 +1,15 @@
+package reactor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/WuKongIM/WuKongIM/pkg/trace"
+)
+
+// setupTestTrace 测试期间替换全局trace，测试结束后恢复
+func setupTestTrace(t testing.TB, opts ...trace.Option) {
+	prevTrace := trace.GlobalTrace
+	trace.SetGlobalTrace(trace.New(context.Background(), trace.NewOptions(opts...)))
+	t.Cleanup(func() { trace.SetGlobalTrace(prevTrace) })
+}
@@ -0,0 +1,47 @@
+package reactor
+
+// IndexInfo 分区的日志下标信息
+type IndexInfo struct {
+	HandleKey      string
+	CommittedIndex uint64 // 已提交的日志下标
+	AppliedIndex   uint64 // 已应用的日志下标
+	LastLogIndex   uint64 // 最后一条日志下标
+	Degraded       bool   // 是否处于异常状态
+}
+
+func (h *handler) indexInfo() IndexInfo {
+	return IndexInfo{
+		HandleKey:      h.key,
+		CommittedIndex: h.applyLag.committedIndex.Load(),
+		AppliedIndex:   h.applyLag.appliedIndex.Load(),
+		LastLogIndex:   h.lastIndex.Load(),
+		Degraded:       h.degraded.Load(),
+	}
+}
+
+// IndexInfo 获取handler的日志下标信息
+func (r *Reactor) IndexInfo(key string) (IndexInfo, bool) {
+	h := r.handler(key)
+	if h == nil {
+		return IndexInfo{}, false
+	}
+	return h.indexInfo(), true
+}
+
+// IndexInfos 一次获取所有handler的日志下标信息（只读原子变量，不经过reactor的事件循环，开销很小），filter为nil表示不过滤
+func (r *Reactor) IndexInfos(filter func(handleKey string) bool) []IndexInfo {
+	r.mu.RLock()
+	subs := r.subReactors
+	r.mu.RUnlock()
+
+	infos := make([]IndexInfo, 0)
+	for _, sub := range subs {
+		sub.handlers.iterator(func(h *handler) bool {
+			if filter == nil || filter(h.key) {
+				infos = append(infos, h.indexInfo())
+			}
+			return true
+		})
+	}
+	return infos
+}
@@ -0,0 +1,86 @@
+package reactor
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/WuKongIM/WuKongIM/pkg/cluster/replica"
+	"github.com/stretchr/testify/assert"
+)
+
+// 重启后的处理者，存储里已应用到applied，已提交到committed的日志等待应用
+type testIndexHandler struct {
+	IHandler
+	applied   uint64
+	committed uint64
+	lastIndex uint64
+}
+
+func (t *testIndexHandler) AppliedIndex() (uint64, error) {
+	return t.applied, nil
+}
+
+func (t *testIndexHandler) HasReady() bool {
+	return true
+}
+
+func (t *testIndexHandler) Ready() replica.Ready {
+	return replica.Ready{
+		Messages: []replica.Message{{MsgType: replica.MsgApplyLogs, AppliedIndex: t.applied, ApplyingIndex: t.applied, CommittedIndex: t.committed}},
+	}
+}
+
+func (t *testIndexHandler) ApplyLogs(startIndex, endIndex uint64) (uint64, error) {
+	return endIndex - startIndex, nil
+}
+
+func (t *testIndexHandler) LastLogIndexAndTerm() (uint64, uint32) {
+	return t.lastIndex, 1
+}
+
+// 批量获取的日志下标和逐个获取的一致，落后是reactor处理提交和应用后的真实落后
+func TestIndexInfos(t *testing.T) {
+	setupTestTrace(t)
+
+	r := New(NewOptions(WithSubReactorNum(4)))
+	keys := make([]string, 0)
+	for i := 1; i <= 10; i++ {
+		key := fmt.Sprintf("ch%d-%d", i, i%2+1)
+		keys = append(keys, key)
+		r.AddHandler(key, &testIndexHandler{applied: uint64(i * 5), committed: uint64(i * 8), lastIndex: uint64(i * 10)})
+		// 处理ready，已提交的日志交给应用协程池（还没应用）
+		r.reactorSub(key).handleReady(r.handler(key))
+	}
+
+	infos := r.IndexInfos(nil)
+	assert.Len(t, infos, len(keys))
+	for _, info := range infos {
+		one, ok := r.IndexInfo(info.HandleKey)
+		assert.True(t, ok)
+		assert.Equal(t, one, info)
+	}
+	info, _ := r.IndexInfo("ch3-2")
+	assert.Equal(t, uint64(30), info.LastLogIndex)
+	assert.Equal(t, uint64(24), info.CommittedIndex)
+	assert.Equal(t, uint64(15), info.AppliedIndex)
+
+	// 应用完成后不再落后
+	for range keys {
+		r.processApplyLog(<-r.processApplyLogC)
+	}
+	for _, info := range r.IndexInfos(nil) {
+		assert.Equal(t, info.CommittedIndex, info.AppliedIndex)
+	}
+	info, _ = r.IndexInfo("ch3-2")
+	assert.Equal(t, uint64(24), info.AppliedIndex)
+
+	// 过滤
+	infos = r.IndexInfos(func(handleKey string) bool {
+		return strings.HasSuffix(handleKey, "-2")
+	})
+	assert.Len(t, infos, 5)
+
+	_, ok := r.IndexInfo("notexist")
+	assert.False(t, ok)
+}
@@ -0,0 +1,107 @@
+package reactor
+
+import (
+	"sync"
+
+	"github.com/WuKongIM/WuKongIM/pkg/cluster/replica"
+)
+
+// logCache 分区最近存储的日志，追随者短暂断开重连后同步的日志大多是领导刚写入的，直接从内存返回，不用再读存储
+// 按字节数限制缓存大小（日志大小差别很大，按条数限制时大消息会占用过多内存），超过时淘汰最旧的日志
+// 只缓存连续的日志，日志被截断时同步删除缓存里被截断的部分，保证和存储里的内容一致
+type logCache struct {
+	mu       sync.Mutex
+	maxBytes uint64        // 最多缓存的日志字节数，0表示不缓存
+	logs     []replica.Log // 缓存的日志，按下标递增
+	bytes    uint64        // 缓存的日志字节数
+}
+
+func (c *logCache) init(maxBytes uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.maxBytes = maxBytes
+}
+
+func (c *logCache) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.maxBytes = 0
+	c.logs = nil
+	c.bytes = 0
+}
+
+// append 追加已存储的日志，和缓存里的日志不连续时先清空缓存
+func (c *logCache) append(logs []replica.Log) {
+	if len(logs) == 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.maxBytes == 0 {
+		return
+	}
+	if len(c.logs) > 0 && logs[0].Index != c.logs[len(c.logs)-1].Index+1 {
+		c.logs, c.bytes = nil, 0
+	}
+	for _, log := range logs {
+		c.logs = append(c.logs, log)
+		c.bytes += uint64(log.LogSize())
+	}
+	// 淘汰最旧的日志直到不超过字节限制（单条超过限制的日志也不缓存）
+	evict := 0
+	for evict < len(c.logs) && c.bytes > c.maxBytes {
+		c.bytes -= uint64(c.logs[evict].LogSize())
+		evict++
+	}
+	if evict > 0 {
+		c.logs = append([]replica.Log(nil), c.logs[evict:]...)
+	}
+}
+
+// get 获取[startIndex, endIndex)范围内最多limitSize字节的日志（至少一条，0表示不限制），startIndex不在缓存里返回false（需要从存储获取）
+// 缓存里的日志不够时只返回缓存里有的部分（最新的日志都在缓存里，存储里也不会有更多）
+func (c *logCache) get(startIndex, endIndex uint64, limitSize uint64) ([]replica.Log, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.logs) == 0 || startIndex >= endIndex {
+		return nil, false
+	}
+	first, last := c.logs[0].Index, c.logs[len(c.logs)-1].Index
+	if startIndex < first || startIndex > last {
+		return nil, false
+	}
+	if endIndex > last+1 {
+		endIndex = last + 1
+	}
+	logs := make([]replica.Log, 0, endIndex-startIndex)
+	var size uint64
+	for _, log := range c.logs[startIndex-first : endIndex-first] {
+		size += uint64(log.LogSize())
+		if limitSize > 0 && len(logs) > 0 && size > limitSize {
+			break
+		}
+		logs = append(logs, log)
+	}
+	return logs, true
+}
+
+// truncateFrom 删除下标大于等于index的日志（和存储的截断保持一致）
+func (c *logCache) truncateFrom(index uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.logs) == 0 {
+		return
+	}
+	first, last := c.logs[0].Index, c.logs[len(c.logs)-1].Index
+	if index > last {
+		return
+	}
+	if index <= first {
+		c.logs, c.bytes = nil, 0
+		return
+	}
+	for _, log := range c.logs[index-first:] {
+		c.bytes -= uint64(log.LogSize())
+	}
+	c.logs = c.logs[:index-first]
+}
@@ -0,0 +1,199 @@
+package reactor
+
+import (
+	"testing"
+
+	"github.com/WuKongIM/WuKongIM/pkg/cluster/replica"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/atomic"
+)
+
+func testCacheLogs(startIndex, endIndex uint64) []replica.Log {
+	logs := make([]replica.Log, 0, endIndex-startIndex)
+	for i := startIndex; i < endIndex; i++ {
+		logs = append(logs, replica.Log{Index: i, Term: 1, Data: []byte("hello")})
+	}
+	return logs
+}
+
+func logIndexes(logs []replica.Log) []uint64 {
+	indexes := make([]uint64, 0, len(logs))
+	for _, log := range logs {
+		indexes = append(indexes, log.Index)
+	}
+	return indexes
+}
+
+func TestLogCache(t *testing.T) {
+	logSize := uint64(testCacheLogs(1, 2)[0].LogSize())
+	var c logCache
+	c.init(4 * logSize) // 最多缓存4条
+
+	_, ok := c.get(1, 2, 0)
+	assert.False(t, ok)
+
+	c.append(testCacheLogs(1, 4))
+	logs, ok := c.get(2, 10, 0) // 只返回缓存里有的部分
+	assert.True(t, ok)
+	assert.Equal(t, []uint64{2, 3}, logIndexes(logs))
+
+	// 超过字节限制后淘汰最旧的日志
+	c.append(testCacheLogs(4, 7))
+	_, ok = c.get(2, 5, 0)
+	assert.False(t, ok)
+	logs, ok = c.get(3, 6, 0)
+	assert.True(t, ok)
+	assert.Equal(t, []uint64{3, 4, 5}, logIndexes(logs))
+	assert.Equal(t, 4*logSize, c.bytes)
+
+	// 按同步大小限制返回，至少返回一条
+	logs, ok = c.get(3, 7, 2*logSize)
+	assert.True(t, ok)
+	assert.Equal(t, []uint64{3, 4}, logIndexes(logs))
+	logs, ok = c.get(3, 7, 1)
+	assert.True(t, ok)
+	assert.Equal(t, []uint64{3}, logIndexes(logs))
+
+	// 截断后被截断的日志不再返回
+	c.truncateFrom(5)
+	logs, ok = c.get(3, 7, 0)
+	assert.True(t, ok)
+	assert.Equal(t, []uint64{3, 4}, logIndexes(logs))
+	assert.Equal(t, 2*logSize, c.bytes)
+	_, ok = c.get(5, 7, 0)
+	assert.False(t, ok)
+
+	// 不连续的日志清空缓存
+	c.append(testCacheLogs(10, 12))
+	_, ok = c.get(3, 5, 0)
+	assert.False(t, ok)
+	logs, ok = c.get(10, 12, 0)
+	assert.True(t, ok)
+	assert.Equal(t, []uint64{10, 11}, logIndexes(logs))
+
+	// 一次追加超过限制只保留最新的
+	c.append(testCacheLogs(12, 20))
+	logs, ok = c.get(16, 20, 0)
+	assert.True(t, ok)
+	assert.Equal(t, []uint64{16, 17, 18, 19}, logIndexes(logs))
+
+	c.truncateFrom(1)
+	_, ok = c.get(16, 20, 0)
+	assert.False(t, ok)
+	assert.Equal(t, uint64(0), c.bytes)
+
+	// 单条超过限制的日志不缓存
+	var small logCache
+	small.init(logSize - 1)
+	small.append(testCacheLogs(1, 2))
+	_, ok = small.get(1, 2, 0)
+	assert.False(t, ok)
+
+	// 不缓存
+	var disabled logCache
+	disabled.append(testCacheLogs(1, 4))
+	_, ok = disabled.get(1, 4, 0)
+	assert.False(t, ok)
+}
+
+// 缓存命中时也按同步大小限制返回，和从存储获取一致
+func TestGetAndMergeLogsCacheLimitSize(t *testing.T) {
+	setupTestTrace(t)
+
+	logs := testCacheLogs(1, 11)
+	logSize := uint64(logs[0].LogSize())
+	r := New(NewOptions(WithLogCacheMaxBytes(100*logSize), WithLogSyncLimitSize(3*logSize)))
+	sh := &testLogStorageHandler{logs: logs}
+	r.AddHandler("ch1", sh)
+	h := r.handler("ch1")
+	h.logCache.append(logs)
+
+	result, err := r.getAndMergeLogs(&getLogReq{h: h, startIndex: 1, lastIndex: 10})
+	assert.NoError(t, err)
+	assert.Equal(t, []uint64{1, 2, 3}, logIndexes(result))
+	assert.Equal(t, int64(0), sh.reads.Load())
+}
+
+// 从存储读取日志并统计读取次数的处理者
+type testLogStorageHandler struct {
+	IHandler
+	logs  []replica.Log
+	reads atomic.Int64
+}
+
+func (t *testLogStorageHandler) AppliedIndex() (uint64, error) {
+	return 0, nil
+}
+
+func (t *testLogStorageHandler) LastLogIndexAndTerm() (uint64, uint32) {
+	return 0, 0
+}
+
+func (t *testLogStorageHandler) GetLogs(startLogIndex, endLogIndex uint64) ([]replica.Log, error) {
+	t.reads.Inc()
+	if endLogIndex > uint64(len(t.logs))+1 {
+		endLogIndex = uint64(len(t.logs)) + 1
+	}
+	logs := make([]replica.Log, endLogIndex-startLogIndex)
+	copy(logs, t.logs[startLogIndex-1:endLogIndex-1])
+	return logs, nil
+}
+
+// 追随者短暂断开后重连，只需要同步最近的少量日志
+func BenchmarkSyncGetRecentLogs(b *testing.B) {
+	const (
+		logCount = 10000
+		behind   = 32 // 追随者落后的日志数量
+	)
+	for _, bc := range []struct {
+		name          string
+		cacheMaxBytes uint64
+	}{{"storage", 0}, {"cache", 64 * 1024}} {
+		b.Run(bc.name, func(b *testing.B) {
+			r := New(NewOptions(WithLogCacheMaxBytes(bc.cacheMaxBytes)))
+			sh := &testLogStorageHandler{logs: testCacheLogs(1, logCount+1)}
+			r.AddHandler("ch1", sh)
+			h := r.handler("ch1")
+			for i := 0; i < logCount; i += 100 {
+				h.logCache.append(sh.logs[i : i+100])
+			}
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				logs, err := r.getAndMergeLogs(&getLogReq{h: h, startIndex: logCount - behind + 1, lastIndex: logCount})
+				if err != nil || len(logs) != behind {
+					b.Fatal("get logs failed", err, len(logs))
+				}
+			}
+			b.ReportMetric(float64(sh.reads.Load())/float64(b.N), "storageReads/op")
+		})
+	}
+}
+
+// 日志已经被压缩的处理者
+type testCompactedLogHandler struct {
+	testLogStorageHandler
+}
+
+func (t *testCompactedLogHandler) GetLogs(startLogIndex, endLogIndex uint64) ([]replica.Log, error) {
+	return nil, replica.ErrCompacted
+}
+
+func TestProcessGetLogCompacted(t *testing.T) {
+	setupTestTrace(t)
+
+	var (
+		compactedKey string
+		compactedTo  uint64
+	)
+	r := New(NewOptions(WithOnLogsCompacted(func(handleKey string, to uint64) {
+		compactedKey = handleKey
+		compactedTo = to
+	})))
+	r.AddHandler("ch1", &testCompactedLogHandler{})
+	h := r.handler("ch1")
+
+	r.processGetLog(&getLogReq{h: h, startIndex: 1, lastIndex: 10, to: 2})
+	assert.Equal(t, "ch1", compactedKey)
+	assert.Equal(t, uint64(2), compactedTo)
+}
@@ -0,0 +1,65 @@
+package reactor
+
+import (
+	"fmt"
+
+	"github.com/WuKongIM/WuKongIM/pkg/cluster/replica"
+	"go.uber.org/zap"
+)
+
+// checkMessageSize 检查收到的消息是否超过MaxMessageSize，超过的消息不能进入队列（单条过大的消息会一直占着接收队列）
+func (r *Reactor) checkMessageSize(m Message) error {
+	if r.opts.MaxMessageSize == 0 {
+		return nil
+	}
+	if size := uint64(m.Size()); size > r.opts.MaxMessageSize {
+		return fmt.Errorf("%w: size %d exceeds max %d", ErrMessageTooLarge, size, r.opts.MaxMessageSize)
+	}
+	return nil
+}
+
+// checkProposeLogSize 单条日志放进同步响应后就超过MaxMessageSize的不能提案，
+// 这样的日志同步响应没法拆分，发出去追随者也会丢弃，追随者会一直卡在这条日志上
+func (r *ReactorSub) checkProposeLogSize(logs []replica.Log) error {
+	for _, lg := range logs {
+		if err := CheckLogSize(r.opts.MaxMessageSize, lg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CheckLogSize 检查单条日志单独放进同步响应时是否超过maxMessageSize，maxMessageSize为0时不限制
+func CheckLogSize(maxMessageSize uint64, lg replica.Log) error {
+	if maxMessageSize == 0 {
+		return nil
+	}
+	if size := uint64(replica.Message{}.Size()) + 4 + uint64(lg.LogSize()); size > maxMessageSize {
+		return fmt.Errorf("%w: log size %d exceeds max %d", ErrMessageTooLarge, size, maxMessageSize)
+	}
+	return nil
+}
+
+// fitSyncResp 同步响应超过MaxMessageSize时只保留前面能放下的日志，剩下的日志追随者追加完这一批后会马上再次同步获取
+// 第一条日志就放不下时返回false，这样的响应接收方一定会丢弃，不发送
+func (r *ReactorSub) fitSyncResp(handler *handler, m replica.Message) (replica.Message, bool) {
+	if uint64(m.Size()) <= r.opts.MaxMessageSize {
+		return m, true
+	}
+	size := uint64(replica.Message{}.Size())
+	count := 0
+	for _, lg := range m.Logs {
+		size += 4 + uint64(lg.LogSize())
+		if size > r.opts.MaxMessageSize {
+			break
+		}
+		count++
+	}
+	if count == 0 {
+		r.Error("log exceeds max message size, can not split, drop sync resp", zap.String("handler", handler.key), zap.Uint64("to", m.To), zap.Uint64("index", m.Logs[0].Index), zap.Int("logSize", m.Logs[0].LogSize()), zap.Uint64("maxMessageSize", r.opts.MaxMessageSize))
+		return m, false
+	}
+	r.Debug("split oversize sync resp", zap.String("handler", handler.key), zap.Uint64("to", m.To), zap.Int("logs", len(m.Logs)), zap.Int("send", count))
+	m.Logs = m.Logs[:count:count]
+	return m, true
+}
@@ -0,0 +1,156 @@
+package reactor
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/WuKongIM/WuKongIM/pkg/cluster/replica"
+	"github.com/stretchr/testify/assert"
+)
+
+// 记录收到的消息，有待发送的消息时通过ready发出去
+type testMessageHandler struct {
+	IHandler
+	mu      sync.Mutex
+	stepped []replica.Message
+	pending []replica.Message
+}
+
+func (t *testMessageHandler) AppliedIndex() (uint64, error) {
+	return 0, nil
+}
+
+func (t *testMessageHandler) Tick() {
+}
+
+func (t *testMessageHandler) LeaderId() uint64 {
+	return 1
+}
+
+func (t *testMessageHandler) SpeedLevel() replica.SpeedLevel {
+	return replica.LevelFast
+}
+
+func (t *testMessageHandler) SetSpeedLevel(level replica.SpeedLevel) {
+}
+
+func (t *testMessageHandler) LastLogIndexAndTerm() (uint64, uint32) {
+	return 0, 1
+}
+
+func (t *testMessageHandler) HasReady() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.pending) > 0
+}
+
+func (t *testMessageHandler) Ready() replica.Ready {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	msgs := t.pending
+	t.pending = nil
+	return replica.Ready{Messages: msgs}
+}
+
+func (t *testMessageHandler) Step(m replica.Message) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.stepped = append(t.stepped, m)
+	return nil
+}
+
+func (t *testMessageHandler) steppedLen() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.stepped)
+}
+
+func testLogs(n int, dataSize int) []replica.Log {
+	logs := make([]replica.Log, 0, n)
+	for i := 0; i < n; i++ {
+		logs = append(logs, replica.Log{Id: uint64(i + 1), Index: uint64(i + 1), Term: 1, Data: bytes.Repeat([]byte("a"), dataSize)})
+	}
+	return logs
+}
+
+// 超过最大大小的消息在进入队列前丢弃，不影响后面的消息
+func TestAddMessageTooLarge(t *testing.T) {
+	setupTestTrace(t)
+
+	r := New(NewOptions(WithSubReactorNum(1), WithNodeId(1), WithMaxMessageSize(1024)))
+	th := &testMessageHandler{}
+	r.AddHandler("test", th)
+	sub := r.reactorSub("test")
+	assert.NoError(t, sub.Start())
+	defer sub.Stop()
+
+	oversize := Message{HandlerKey: "test", Message: replica.Message{MsgType: replica.MsgSyncResp, From: 2, Logs: testLogs(1, 2048)}}
+	assert.ErrorIs(t, r.checkMessageSize(oversize), ErrMessageTooLarge)
+	r.AddMessage(oversize)
+
+	small := Message{HandlerKey: "test", Message: replica.Message{MsgType: replica.MsgSyncResp, From: 2, Logs: testLogs(1, 100)}}
+	assert.NoError(t, r.checkMessageSize(small))
+	r.AddMessage(small)
+
+	assert.Eventually(t, func() bool {
+		return th.steppedLen() == 1
+	}, time.Second, time.Millisecond)
+	time.Sleep(time.Millisecond * 20)
+	th.mu.Lock()
+	assert.Len(t, th.stepped, 1)
+	assert.Len(t, th.stepped[0].Logs[0].Data, 100)
+	th.mu.Unlock()
+}
+
+// 发送超过最大大小的同步响应时只带上能放下的日志
+func TestSendSyncRespSplit(t *testing.T) {
+	var (
+		mu   sync.Mutex
+		sent []Message
+	)
+	maxSize := uint64(4096)
+	r := New(NewOptions(WithSubReactorNum(1), WithNodeId(1), WithMaxMessageSize(maxSize), WithSend(func(m Message) {
+		mu.Lock()
+		sent = append(sent, m)
+		mu.Unlock()
+	})))
+	th := &testMessageHandler{}
+	logs := testLogs(10, 1000)
+	th.pending = []replica.Message{{MsgType: replica.MsgSyncResp, From: 1, To: 2, Index: 1, Logs: logs}}
+	r.AddHandler("test", th)
+	sub := r.reactorSub("test")
+	assert.NoError(t, sub.Start())
+	defer sub.Stop()
+
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(sent) == 1
+	}, time.Second, time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	m := sent[0]
+	assert.LessOrEqual(t, uint64(m.Size()), maxSize)
+	assert.Len(t, m.Logs, 3)
+	assert.Equal(t, logs[:3], m.Logs)
+	assert.Equal(t, uint64(1), m.Index)
+
+	// 单条日志超过限制没法拆分，不发送（接收方一定会丢弃）
+	_, ok := sub.fitSyncResp(r.handler("test"), replica.Message{MsgType: replica.MsgSyncResp, To: 2, Logs: testLogs(2, 5000)})
+	assert.False(t, ok)
+}
+
+// 单条日志放进同步响应就超过最大大小的不能提案
+func TestProposeLogTooLarge(t *testing.T) {
+	setupTestTrace(t)
+
+	for _, reactorType := range []ReactorType{ReactorTypeSlot, ReactorTypeChannel} {
+		sub := NewReactorSub(0, &Reactor{opts: NewOptions(WithReactorType(reactorType), WithMaxMessageSize(4096))})
+		_, err := sub.proposeAndWait(context.Background(), "test", []replica.Log{{Id: 1, Data: make([]byte, 100)}, {Id: 2, Data: make([]byte, 4096)}})
+		assert.ErrorIs(t, err, ErrMessageTooLarge)
+	}
+}
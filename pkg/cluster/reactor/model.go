@@ -6,4 +6,5 @@ import "github.com/WuKongIM/WuKongIM/pkg/cluster/replica"
 type AppendLogReq struct {
 	HandleKey string
 	Logs      []replica.Log
+	Err       error // 这个请求单独失败的原因（AppendLogBatch设置），批量里的其他请求照常追加
 }
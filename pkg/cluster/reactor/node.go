@@ -118,5 +118,7 @@ func (h *handlerList) iterator(f func(h *handler) bool) {
 }
 
 func (h *handlerList) len() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
 	return h.count
 }
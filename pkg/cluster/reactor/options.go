@@ -42,6 +42,11 @@ type Options struct {
 	// ReceiveQueueLength 处理者接收队列的长度。
 	ReceiveQueueLength uint64
 
+	// MaxMessageSize 单条消息的最大字节数，收到超过的消息在进入队列前丢弃（ErrMessageTooLarge），
+	// 发送超过的同步响应时只带上能放下的日志，剩下的日志由追随者下一次同步获取，
+	// 单条日志就放不下的提案直接返回ErrMessageTooLarge，0表示不限制
+	MaxMessageSize uint64
+
 	// LazyFreeCycle defines how often should entry queue and message queue
 	// to be freed.
 	LazyFreeCycle uint64
@@ -73,6 +78,12 @@ type Options struct {
 	Event struct {
 		// OnHandlerRemove handler被移除事件
 		OnHandlerRemove func(h IHandler)
+		// OnSnapshot 分区需要做快照（appliedIndex为触发快照时已应用的日志下标）
+		OnSnapshot func(handleKey string, appliedIndex uint64) error
+		// OnDegraded 分区进入异常状态（例如已应用下标超过已提交下标）
+		OnDegraded func(handleKey string, reason string)
+		// OnLogsCompacted 领导要同步给副本to的日志已经被压缩（副本只能通过安装快照追上）
+		OnLogsCompacted func(handleKey string, to uint64)
 	}
 
 	// ProposeTimeout 提案超时
@@ -82,25 +93,71 @@ type Options struct {
 
 	// SyncTimeoutMaxTick 同步超时最大tick次数
 	SyncTimeoutMaxTick int
+
+	// MaxApplyLag 已提交未应用的日志数量超过这个值时，领导暂停新的提案直到应用追上，0表示不限制
+	MaxApplyLag uint64
+
+	// ProposeAckTraceSampleRate 提案副本确认跟踪的采样率（0-1），被采样的提案会在提案span上记录每个副本确认的顺序和耗时，0表示不开启
+	ProposeAckTraceSampleRate float64
+	// ProposeAckTraceMaxPending 每个分区最多同时跟踪副本确认的提案数量，超过后丢弃新的跟踪直到降到一半以下，0表示不限制
+	ProposeAckTraceMaxPending int
+
+	// AllowEmptyPayload 是否允许提案数据为空的日志
+	// 默认不允许：批量里只要有一条日志数据为空，整批提案返回ErrEmptyPayload（空日志会白占一个日志下标，应用时也无法区分）
+	// 允许时空日志作为无操作的标记日志，照常分配下标和提交，应用时需要自行跳过
+	AllowEmptyPayload bool
+
+	// ApplyOrderingMode 分区的日志应用顺序模式，nil表示全部严格按顺序应用
+	ApplyOrderingMode func(handleKey string) ApplyOrderingMode
+	// ApplyRelaxedPoolSize 宽松顺序模式下一次应用最多并行的段数
+	ApplyRelaxedPoolSize int
+
+	// SnapshotLogThreshold 距离上次快照已应用的日志数量达到这个值时触发快照，0表示不按日志数量触发
+	SnapshotLogThreshold uint64
+
+	// InlineApplyMaxLogs 一次要应用的日志数量不超过这个值时直接在reactor sub协程里应用（低流量分区省去交给应用协程池的调度开销），
+	// 超过的（繁忙分区）仍然交给应用协程池，0表示全部交给应用协程池。同步应用会占用sub协程，应用钩子耗时长的分区不要开启
+	InlineApplyMaxLogs uint64
+	// InlineApply 哪些分区允许同步应用（例如按频道类型），nil表示都允许
+	InlineApply func(handleKey string) bool
+
+	// ProposeResultChannelBuffer 最多同时排队通知的提案结果数量（通知协程池大小），满了时在应用协程里直接通知（等待结果的chan有缓冲，通知不会阻塞），
+	// 和其他任务分开，避免任务池被占满时应用协程阻塞在提交通知上，不大于0表示不限制
+	ProposeResultChannelBuffer int
+
+	// LogCacheMaxBytes 每个分区在内存里缓存最近存储的日志的最大字节数，追随者短暂断开后同步时优先从缓存获取，0表示不缓存
+	LogCacheMaxBytes uint64
+	// LogSyncLimitSize 每次同步从缓存获取的日志最大字节数（和从存储获取时的限制一致），0表示不限制
+	LogSyncLimitSize uint64
+
+	// MaxReadyRounds sub每个循环最多处理几轮ready，超过后先处理排队的提案和消息再继续，避免繁忙时提案和消息一直得不到处理，0表示处理到没有ready为止
+	MaxReadyRounds int
+
+	// IdleSweepPaused 启动时是否暂停空闲回收（开启AutoSlowDownOn时，速度降为停止的处理者会被移除），可通过Reactor.ResumeIdleSweep恢复
+	IdleSweepPaused bool
 }
 
 func NewOptions(opt ...Option) *Options {
 	opts := &Options{
-		SubReactorNum:             128,
-		TickInterval:              time.Millisecond * 150,
-		ReceiveQueueLength:        128,
-		LazyFreeCycle:             1,
-		InitialTaskQueueCap:       100,
-		TaskPoolSize:              100000,
-		MaxProposeLogCount:        1000,
-		EnableLazyCatchUp:         true,
-		IsCommittedAfterApplied:   false,
-		AutoSlowDownOn:            false,
-		LeaderTimeoutMaxTick:      25,
-		AppendLogWorkerNum:        2,
-		ProposeTimeout:            time.Second * 30,
-		SlowdownCheckIntervalTick: 10,
-		SyncTimeoutMaxTick:        10,
+		SubReactorNum:              128,
+		TickInterval:               time.Millisecond * 150,
+		ReceiveQueueLength:         128,
+		LazyFreeCycle:              1,
+		InitialTaskQueueCap:        100,
+		TaskPoolSize:               100000,
+		MaxProposeLogCount:         1000,
+		EnableLazyCatchUp:          true,
+		IsCommittedAfterApplied:    false,
+		AutoSlowDownOn:             false,
+		LeaderTimeoutMaxTick:       25,
+		AppendLogWorkerNum:         2,
+		ProposeTimeout:             time.Second * 30,
+		SlowdownCheckIntervalTick:  10,
+		SyncTimeoutMaxTick:         10,
+		MaxApplyLag:                0,
+		ProposeAckTraceMaxPending:  10000,
+		ApplyRelaxedPoolSize:       8,
+		ProposeResultChannelBuffer: 10000,
 	}
 
 	for _, o := range opt {
@@ -142,6 +199,13 @@ func WithMaxReceiveQueueSize(size uint64) Option {
 	}
 }
 
+// WithMaxMessageSize 设置单条消息的最大字节数
+func WithMaxMessageSize(size uint64) Option {
+	return func(o *Options) {
+		o.MaxMessageSize = size
+	}
+}
+
 func WithReceiveQueueLength(length uint64) Option {
 	return func(o *Options) {
 		o.ReceiveQueueLength = length
@@ -208,6 +272,24 @@ func WithOnHandlerRemove(f func(h IHandler)) Option {
 	}
 }
 
+func WithOnSnapshot(f func(handleKey string, appliedIndex uint64) error) Option {
+	return func(o *Options) {
+		o.Event.OnSnapshot = f
+	}
+}
+
+func WithOnDegraded(f func(handleKey string, reason string)) Option {
+	return func(o *Options) {
+		o.Event.OnDegraded = f
+	}
+}
+
+func WithOnLogsCompacted(f func(handleKey string, to uint64)) Option {
+	return func(o *Options) {
+		o.Event.OnLogsCompacted = f
+	}
+}
+
 func WithRequest(req IRequest) Option {
 	return func(o *Options) {
 		o.Request = req
@@ -237,3 +319,87 @@ func WithSyncTimeoutMaxTick(tick int) Option {
 		o.SyncTimeoutMaxTick = tick
 	}
 }
+
+func WithMaxApplyLag(lag uint64) Option {
+	return func(o *Options) {
+		o.MaxApplyLag = lag
+	}
+}
+
+func WithProposeAckTraceSampleRate(rate float64) Option {
+	return func(o *Options) {
+		o.ProposeAckTraceSampleRate = rate
+	}
+}
+
+func WithAllowEmptyPayload(allow bool) Option {
+	return func(o *Options) {
+		o.AllowEmptyPayload = allow
+	}
+}
+
+func WithApplyOrderingMode(f func(handleKey string) ApplyOrderingMode) Option {
+	return func(o *Options) {
+		o.ApplyOrderingMode = f
+	}
+}
+
+func WithApplyRelaxedPoolSize(size int) Option {
+	return func(o *Options) {
+		o.ApplyRelaxedPoolSize = size
+	}
+}
+
+func WithProposeAckTraceMaxPending(max int) Option {
+	return func(o *Options) {
+		o.ProposeAckTraceMaxPending = max
+	}
+}
+
+func WithSnapshotLogThreshold(n uint64) Option {
+	return func(o *Options) {
+		o.SnapshotLogThreshold = n
+	}
+}
+
+func WithIdleSweepPaused(paused bool) Option {
+	return func(o *Options) {
+		o.IdleSweepPaused = paused
+	}
+}
+
+func WithInlineApplyMaxLogs(n uint64) Option {
+	return func(o *Options) {
+		o.InlineApplyMaxLogs = n
+	}
+}
+
+func WithInlineApply(f func(handleKey string) bool) Option {
+	return func(o *Options) {
+		o.InlineApply = f
+	}
+}
+
+func WithLogCacheMaxBytes(maxBytes uint64) Option {
+	return func(o *Options) {
+		o.LogCacheMaxBytes = maxBytes
+	}
+}
+
+func WithLogSyncLimitSize(size uint64) Option {
+	return func(o *Options) {
+		o.LogSyncLimitSize = size
+	}
+}
+
+func WithProposeResultChannelBuffer(size int) Option {
+	return func(o *Options) {
+		o.ProposeResultChannelBuffer = size
+	}
+}
+
+func WithMaxReadyRounds(n int) Option {
+	return func(o *Options) {
+		o.MaxReadyRounds = n
+	}
+}
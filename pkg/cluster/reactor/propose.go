@@ -1,6 +1,8 @@
 package reactor
 
 import (
+	"fmt"
+
 	"github.com/WuKongIM/WuKongIM/pkg/cluster/replica"
 )
 
@@ -8,13 +10,19 @@ type proposeReq struct {
 	logs    []replica.Log
 	handler *handler
 	waitKey string
+	values  map[string]string // 提案元数据
+	term    uint32            // 检查领导时的任期，追加时任期变了说明领导变更过，拒绝提案
+	wait    *proposeWait      // 提案的等待（处理者移除后会被重置复用，不能再通过handler访问）
 }
 
-func newProposeReq(handler *handler, waitKey string, logs []replica.Log) proposeReq {
+func newProposeReq(handler *handler, waitKey string, logs []replica.Log, values map[string]string, term uint32) proposeReq {
 	return proposeReq{
 		logs:    logs,
 		handler: handler,
 		waitKey: waitKey,
+		values:  values,
+		term:    term,
+		wait:    handler.proposeWait,
 	}
 }
 
@@ -31,3 +39,13 @@ func (p ProposeResult) LogId() uint64 {
 func (p ProposeResult) LogIndex() uint64 {
 	return p.Index
 }
+
+// checkProposeIndexContiguous 检查一批提案结果的下标是否连续
+func checkProposeIndexContiguous(items []ProposeResult) error {
+	for i := 1; i < len(items); i++ {
+		if items[i].Index != items[0].Index+uint64(i) {
+			return fmt.Errorf("%w: index %d at %d, first index %d", ErrProposeIndexNotContiguous, items[i].Index, i, items[0].Index)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+package reactor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/WuKongIM/WuKongIM/pkg/cluster/replica"
+	"github.com/stretchr/testify/assert"
+)
+
+// 领导处理者，追加日志后直接提交
+type testCommitHandler struct {
+	IHandler
+	mu      sync.Mutex
+	logs    []replica.Log
+	h       *handler
+	stepErr error
+}
+
+func (t *testCommitHandler) AppliedIndex() (uint64, error) {
+	return 0, nil
+}
+
+func (t *testCommitHandler) HasReady() bool {
+	return false
+}
+
+func (t *testCommitHandler) Tick() {
+}
+
+func (t *testCommitHandler) SetSpeedLevel(level replica.SpeedLevel) {
+}
+
+func (t *testCommitHandler) LeaderId() uint64 {
+	return 1
+}
+
+func (t *testCommitHandler) PausePropopose() bool {
+	return false
+}
+
+func (t *testCommitHandler) LastLogIndexAndTerm() (uint64, uint32) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return uint64(len(t.logs)), 1
+}
+
+func (t *testCommitHandler) Step(m replica.Message) error {
+	if m.MsgType != replica.MsgPropose {
+		return nil
+	}
+	t.mu.Lock()
+	if t.stepErr != nil {
+		t.mu.Unlock()
+		return t.stepErr
+	}
+	startIndex := uint64(len(t.logs)) + 1
+	t.logs = append(t.logs, m.Logs...)
+	endIndex := uint64(len(t.logs)) + 1
+	t.mu.Unlock()
+	t.h.didCommit(startIndex, endIndex)
+	return nil
+}
+
+func newTestCommitReactor(t *testing.T, opts ...Option) (*ReactorSub, *testCommitHandler) {
+	opts = append([]Option{WithSubReactorNum(1), WithNodeId(1), WithProposeTimeout(time.Second * 5)}, opts...)
+	r := New(NewOptions(opts...))
+	th := &testCommitHandler{}
+	r.AddHandler("test", th)
+	th.h = r.handler("test")
+	sub := r.reactorSub("test")
+	err := sub.Start()
+	assert.NoError(t, err)
+	return sub, th
+}
+
+// 并发提案，每一批提案的下标都是连续的，并且和日志的顺序一致
+func TestProposeIndexContiguous(t *testing.T) {
+	setupTestTrace(t)
+
+	sub, th := newTestCommitReactor(t)
+	defer sub.Stop()
+
+	var (
+		wg        sync.WaitGroup
+		proposers = 20
+		batches   = 50
+		idGen     uint64
+		idMu      sync.Mutex
+	)
+	nextIds := func(n int) []replica.Log {
+		idMu.Lock()
+		defer idMu.Unlock()
+		logs := make([]replica.Log, 0, n)
+		for i := 0; i < n; i++ {
+			idGen++
+			logs = append(logs, replica.Log{Id: idGen, Data: []byte(fmt.Sprintf("data-%d", idGen))})
+		}
+		return logs
+	}
+	for p := 0; p < proposers; p++ {
+		wg.Add(1)
+		go func(p int) {
+			defer wg.Done()
+			for b := 0; b < batches; b++ {
+				logs := nextIds(1 + (p+b)%10)
+				results, err := sub.proposeAndWait(context.Background(), "test", logs)
+				if !assert.NoError(t, err) {
+					return
+				}
+				assert.Len(t, results, len(logs))
+				for i, result := range results {
+					assert.Equal(t, logs[i].Id, result.Id)
+					assert.Equal(t, results[0].Index+uint64(i), result.Index)
+				}
+			}
+		}(p)
+	}
+	wg.Wait()
+
+	// 领导上的日志下标没有空洞和重复
+	th.mu.Lock()
+	defer th.mu.Unlock()
+	for i, lg := range th.logs {
+		assert.Equal(t, uint64(i+1), lg.Index)
+	}
+}
+
+// 追加失败的提案立即返回，下标不会被下一批提案的提交误认为已提交
+func TestProposeDroppedOnStepError(t *testing.T) {
+	setupTestTrace(t)
+
+	sub, th := newTestCommitReactor(t)
+	defer sub.Stop()
+
+	th.mu.Lock()
+	th.stepErr = errors.New("step failed")
+	th.mu.Unlock()
+	_, err := sub.proposeAndWait(context.Background(), "test", []replica.Log{{Id: 1, Data: []byte("hello")}})
+	assert.ErrorIs(t, err, ErrProposeDropped)
+	assert.False(t, th.h.proposeWait.exist("1"))
+
+	th.mu.Lock()
+	th.stepErr = nil
+	th.mu.Unlock()
+	results, err := sub.proposeAndWait(context.Background(), "test", []replica.Log{{Id: 2, Data: []byte("world")}})
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1), results[0].Index)
+}
+
+func TestCheckProposeIndexContiguous(t *testing.T) {
+	assert.NoError(t, checkProposeIndexContiguous([]ProposeResult{{Index: 5}, {Index: 6}, {Index: 7}}))
+	err := checkProposeIndexContiguous([]ProposeResult{{Index: 5}, {Index: 7}})
+	assert.ErrorIs(t, err, ErrProposeIndexNotContiguous)
+}
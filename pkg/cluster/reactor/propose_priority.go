@@ -0,0 +1,30 @@
+package reactor
+
+import "context"
+
+// ProposePriority 提案优先级
+type ProposePriority int
+
+const (
+	// ProposePriorityNormal 普通提案（用户消息等）
+	ProposePriorityNormal ProposePriority = iota
+	// ProposePriorityHigh 高优先级提案（配置变更、撤回、冻结等控制消息），在同一个领导上会排在还没追加的普通提案前面
+	ProposePriorityHigh
+)
+
+type proposePriorityKey struct{}
+
+// WithProposePriority 设置提案的优先级
+// 高优先级的提案会先于排队中（还没有分配日志下标）的普通提案追加，已经追加的日志不受影响，所以不会影响日志的安全性
+func WithProposePriority(ctx context.Context, priority ProposePriority) context.Context {
+	return context.WithValue(ctx, proposePriorityKey{}, priority)
+}
+
+// ProposeContextPriority 获取上下文中的提案优先级
+func ProposeContextPriority(ctx context.Context) ProposePriority {
+	if ctx == nil {
+		return ProposePriorityNormal
+	}
+	priority, _ := ctx.Value(proposePriorityKey{}).(ProposePriority)
+	return priority
+}
@@ -0,0 +1,94 @@
+package reactor
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/WuKongIM/WuKongIM/pkg/cluster/replica"
+	"github.com/stretchr/testify/assert"
+)
+
+type testProposeHandler struct {
+	IHandler
+	mu     sync.Mutex
+	logs   []replica.Log
+	stepC  chan struct{}
+	expect int
+}
+
+func (t *testProposeHandler) AppliedIndex() (uint64, error) {
+	return 0, nil
+}
+
+func (t *testProposeHandler) HasReady() bool {
+	return false
+}
+
+func (t *testProposeHandler) Tick() {
+}
+
+func (t *testProposeHandler) LeaderId() uint64 {
+	return 1
+}
+
+func (t *testProposeHandler) LastLogIndexAndTerm() (uint64, uint32) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return uint64(len(t.logs)), 1
+}
+
+func (t *testProposeHandler) Step(m replica.Message) error {
+	t.mu.Lock()
+	t.logs = append(t.logs, m.Logs...)
+	done := len(t.logs) == t.expect
+	t.mu.Unlock()
+	if done {
+		close(t.stepC)
+	}
+	return nil
+}
+
+func TestProposeContextPriority(t *testing.T) {
+	assert.Equal(t, ProposePriorityNormal, ProposeContextPriority(context.Background()))
+	ctx := WithProposePriority(context.Background(), ProposePriorityHigh)
+	assert.Equal(t, ProposePriorityHigh, ProposeContextPriority(ctx))
+}
+
+// 高优先级的提案先于排队中的普通提案追加
+func TestProposePriorityJumpQueue(t *testing.T) {
+	normalCount := 100
+	r := New(NewOptions(WithSubReactorNum(1), WithNodeId(1)))
+	th := &testProposeHandler{stepC: make(chan struct{}), expect: normalCount + 1}
+	r.AddHandler("test", th)
+	h := r.handler("test")
+	sub := r.reactorSub("test")
+
+	// 领导上积压了大量普通提案
+	for i := 1; i <= normalCount; i++ {
+		sub.proposeC <- newProposeReq(h, "normal", []replica.Log{{Id: uint64(i), Data: []byte("normal")}}, nil, 1)
+	}
+	// 控制消息
+	sub.proposeHighC <- newProposeReq(h, "high", []replica.Log{{Id: 1000, Data: []byte("config")}}, nil, 1)
+
+	err := sub.Start()
+	assert.NoError(t, err)
+	defer sub.Stop()
+
+	select {
+	case <-th.stepC:
+	case <-time.After(time.Second * 5):
+		t.Fatal("propose timeout")
+	}
+
+	th.mu.Lock()
+	defer th.mu.Unlock()
+	assert.Equal(t, uint64(1000), th.logs[0].Id)
+	assert.Equal(t, uint64(1), th.logs[0].Index)
+	// 普通提案之间的顺序不变
+	for i := 1; i <= normalCount; i++ {
+		assert.Equal(t, uint64(i), th.logs[i].Id)
+		assert.Equal(t, uint64(i+1), th.logs[i].Index)
+	}
+}
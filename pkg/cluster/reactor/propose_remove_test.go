@@ -0,0 +1,201 @@
+package reactor
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/WuKongIM/WuKongIM/pkg/cluster/replica"
+	"github.com/stretchr/testify/assert"
+)
+
+// 追加提案但一直不提交的领导
+type testHoldHandler struct {
+	IHandler
+	mu    sync.Mutex
+	last  uint64
+	steps int
+}
+
+func (t *testHoldHandler) AppliedIndex() (uint64, error) {
+	return 0, nil
+}
+
+func (t *testHoldHandler) HasReady() bool {
+	return false
+}
+
+func (t *testHoldHandler) Tick() {
+}
+
+func (t *testHoldHandler) SpeedLevel() replica.SpeedLevel {
+	return replica.LevelFast
+}
+
+func (t *testHoldHandler) SetSpeedLevel(level replica.SpeedLevel) {
+}
+
+func (t *testHoldHandler) LeaderId() uint64 {
+	return 1
+}
+
+func (t *testHoldHandler) PausePropopose() bool {
+	return false
+}
+
+func (t *testHoldHandler) LastLogIndexAndTerm() (uint64, uint32) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.last, 1
+}
+
+func (t *testHoldHandler) Step(m replica.Message) error {
+	if m.MsgType != replica.MsgPropose {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.last += uint64(len(m.Logs))
+	t.steps++
+	return nil
+}
+
+func (t *testHoldHandler) lastIndex() uint64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.last
+}
+
+func assertProposeWaitEmpty(t *testing.T, pw *proposeWait) {
+	pw.mu.RLock()
+	defer pw.mu.RUnlock()
+	assert.Empty(t, pw.proposeWaitMap)
+	assert.Empty(t, pw.proposeResultMap)
+	assert.Empty(t, pw.rejectErrMap)
+}
+
+// 移除处理者时，已追加等待提交的提案立即失败，不用等到提案超时
+func TestRemoveHandlerFailsAppendedWaits(t *testing.T) {
+	setupTestTrace(t)
+
+	r := New(NewOptions(WithSubReactorNum(1), WithNodeId(1), WithProposeTimeout(time.Minute)))
+	assert.NoError(t, r.Start())
+	defer r.Stop()
+
+	th := &testHoldHandler{}
+	r.AddHandler("test", th)
+	pw := r.handler("test").proposeWait
+
+	const count = 10
+	errC := make(chan error, count)
+	for i := 1; i <= count; i++ {
+		go func(id uint64) {
+			_, err := r.ProposeAndWait(context.Background(), "test", []replica.Log{{Id: id, Data: []byte("hello")}})
+			errC <- err
+		}(uint64(i))
+	}
+	assert.Eventually(t, func() bool {
+		return th.lastIndex() == count
+	}, time.Second, time.Millisecond)
+
+	r.RemoveHandler("test")
+	for i := 0; i < count; i++ {
+		select {
+		case err := <-errC:
+			assert.Equal(t, ErrHandlerRemoved, err)
+		case <-time.After(time.Second):
+			t.Fatal("propose wait not failed after handler removed")
+		}
+	}
+	assertProposeWaitEmpty(t, pw)
+}
+
+// 采样跟踪中的提案等待期间处理者被移除，结束跟踪时不能访问已重置的处理者
+func TestRemoveHandlerWithAckTraceSampled(t *testing.T) {
+	setupTestTrace(t)
+
+	r := New(NewOptions(WithSubReactorNum(1), WithNodeId(1), WithProposeTimeout(time.Minute), WithProposeAckTraceSampleRate(1)))
+	assert.NoError(t, r.Start())
+	defer r.Stop()
+
+	th := &testHoldHandler{}
+	r.AddHandler("test", th)
+
+	errC := make(chan error, 1)
+	go func() {
+		_, err := r.ProposeAndWait(context.Background(), "test", []replica.Log{{Id: 1, Data: []byte("hello")}})
+		errC <- err
+	}()
+	assert.Eventually(t, func() bool {
+		return th.lastIndex() == 1
+	}, time.Second, time.Millisecond)
+
+	r.RemoveHandler("test")
+	select {
+	case err := <-errC:
+		assert.Equal(t, ErrHandlerRemoved, err)
+	case <-time.After(time.Second):
+		t.Fatal("propose wait not failed after handler removed")
+	}
+}
+
+// 提案还在队列里（没有追加）时处理者被移除，等待立即失败，之后不会再追加
+func TestRemoveHandlerFailsQueuedWaits(t *testing.T) {
+	setupTestTrace(t)
+
+	// 不启动sub，提案一直留在队列里
+	r := New(NewOptions(WithSubReactorNum(1), WithNodeId(1), WithProposeTimeout(time.Minute)))
+	th := &testHoldHandler{}
+	r.AddHandler("test", th)
+	sub := r.reactorSub("test")
+	pw := r.handler("test").proposeWait
+
+	const count = 10
+	errC := make(chan error, count)
+	for i := 1; i <= count; i++ {
+		go func(id uint64) {
+			_, err := r.ProposeAndWait(context.Background(), "test", []replica.Log{{Id: id, Data: []byte("hello")}})
+			errC <- err
+		}(uint64(i))
+	}
+	assert.Eventually(t, func() bool {
+		return len(sub.proposeC) == count
+	}, time.Second, time.Millisecond)
+
+	sub.removeHandler("test")
+	for i := 0; i < count; i++ {
+		select {
+		case err := <-errC:
+			assert.Equal(t, ErrHandlerRemoved, err)
+		case <-time.After(time.Second):
+			t.Fatal("propose wait not failed after handler removed")
+		}
+	}
+
+	for len(sub.proposeC) > 0 {
+		sub.handlePropose(<-sub.proposeC)
+	}
+	assert.Equal(t, 0, th.steps)
+	assertProposeWaitEmpty(t, pw)
+
+	// 移除后添加的等待直接失败
+	waitC := pw.add("11", []uint64{11})
+	_, ok := <-waitC
+	assert.False(t, ok)
+	assert.Equal(t, ErrHandlerRemoved, pw.takeRejectErr("11"))
+	assertProposeWaitEmpty(t, pw)
+}
+
+// 重复移除同一个处理者（例如优雅销毁后又移除）不会出错
+func TestRemoveHandlerTwice(t *testing.T) {
+	r := New(NewOptions(WithSubReactorNum(1), WithNodeId(1)))
+	r.AddHandler("test", &testHoldHandler{})
+	sub := r.reactorSub("test")
+
+	assert.NotNil(t, sub.removeHandler("test"))
+	assert.NotPanics(t, func() {
+		assert.Nil(t, sub.removeHandler("test"))
+		r.RemoveHandler("test")
+	})
+}
@@ -0,0 +1,38 @@
+package reactor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/WuKongIM/WuKongIM/pkg/cluster/replica"
+	"github.com/stretchr/testify/assert"
+)
+
+// 通知提案结果的协程池被占满（消费者很慢），应用不会阻塞，提案结果在应用协程里直接通知
+func TestProposeResultPoolFull(t *testing.T) {
+	setupTestTrace(t)
+
+	r := New(NewOptions(WithSubReactorNum(1), WithNodeId(1), WithProposeResultChannelBuffer(1)))
+	assert.NoError(t, r.Start())
+	defer r.Stop()
+
+	block := make(chan struct{})
+	defer close(block)
+	assert.NoError(t, r.resultPool.Submit(func() { <-block }))
+
+	h := &testInlineApplyHandler{}
+	r.AddHandler("ch1", h)
+	assert.Eventually(t, h.isInited, time.Second, time.Millisecond)
+
+	for i := 1; i <= 10; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		results, err := r.ProposeAndWait(ctx, "ch1", []replica.Log{{Id: uint64(i), Data: []byte("hello")}})
+		cancel()
+		assert.NoError(t, err)
+		assert.Len(t, results, 1)
+	}
+	assert.Eventually(t, func() bool {
+		return h.appliedIndex() == 10
+	}, time.Second, time.Millisecond)
+}
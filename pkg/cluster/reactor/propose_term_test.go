@@ -0,0 +1,200 @@
+package reactor
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/WuKongIM/WuKongIM/pkg/cluster/replica"
+	"github.com/stretchr/testify/assert"
+)
+
+// 任期会变化的领导处理者，收到MsgChangeRole时任期加一（在reactor sub里处理，和真实的领导变更一样）
+type testTermHandler struct {
+	testCommitHandler
+	term      uint32
+	leaderId  uint64
+	wrongTerm int // 追加的日志任期和当前任期不一致的数量
+}
+
+func (t *testTermHandler) LeaderId() uint64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.leaderId
+}
+
+func (t *testTermHandler) LastLogIndexAndTerm() (uint64, uint32) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return uint64(len(t.logs)), t.term
+}
+
+func (t *testTermHandler) Step(m replica.Message) error {
+	switch m.MsgType {
+	case replica.MsgChangeRole:
+		t.mu.Lock()
+		t.term++
+		t.mu.Unlock()
+		return nil
+	case replica.MsgPropose:
+		t.mu.Lock()
+		for _, lg := range m.Logs {
+			if lg.Term != t.term {
+				t.wrongTerm++
+			}
+		}
+		t.mu.Unlock()
+	}
+	return t.testCommitHandler.Step(m)
+}
+
+func newTestTermReactor() (*ReactorSub, *testTermHandler) {
+	r := New(NewOptions(WithSubReactorNum(1), WithNodeId(1), WithProposeTimeout(time.Second*5)))
+	th := &testTermHandler{term: 1, leaderId: 1}
+	r.AddHandler("test", th)
+	th.h = r.handler("test")
+	return r.reactorSub("test"), th
+}
+
+// 提案排队期间任期变了或不再是领导，提案被拒绝，不会用新的任期追加
+func TestProposeRejectedOnTermChange(t *testing.T) {
+	setupTestTrace(t)
+
+	sub, th := newTestTermReactor()
+	h := th.h
+
+	logs := []replica.Log{{Id: 1, Data: []byte("hello")}}
+	waitC := h.addWait("1", []uint64{1})
+	th.term = 2
+	sub.handlePropose(newProposeReq(h, "1", logs, nil, 1))
+	_, ok := <-waitC
+	assert.False(t, ok)
+	assert.Equal(t, ErrNotLeader, h.proposeWait.takeRejectErr("1"))
+	assert.Nil(t, h.proposeWait.takeRejectErr("1"))
+
+	// 任期没变但领导变了
+	waitC = h.addWait("2", []uint64{2})
+	th.leaderId = 2
+	sub.handlePropose(newProposeReq(h, "2", []replica.Log{{Id: 2, Data: []byte("hello")}}, nil, 2))
+	_, ok = <-waitC
+	assert.False(t, ok)
+	assert.Equal(t, ErrNotLeader, h.proposeWait.takeRejectErr("2"))
+	assert.Empty(t, th.logs)
+
+	// 任期和领导都没变，正常追加
+	th.leaderId = 1
+	waitC = h.addWait("3", []uint64{3})
+	sub.handlePropose(newProposeReq(h, "3", []replica.Log{{Id: 3, Data: []byte("hello")}}, nil, 2))
+	items := <-waitC
+	assert.Equal(t, uint64(1), items[0].Index)
+	assert.Equal(t, uint32(2), th.logs[0].Term)
+}
+
+// 提案和任期变更并发，追加的日志任期都是追加时的任期，被拒绝的提案返回ErrNotLeader
+func TestProposeConcurrentTermChange(t *testing.T) {
+	setupTestTrace(t)
+
+	sub, th := newTestTermReactor()
+	assert.NoError(t, sub.Start())
+	defer sub.Stop()
+
+	stopC := make(chan struct{})
+	var changeWg sync.WaitGroup
+	changeWg.Add(1)
+	go func() {
+		defer changeWg.Done()
+		for {
+			select {
+			case <-stopC:
+				return
+			case <-time.After(time.Millisecond):
+				sub.step("test", replica.Message{MsgType: replica.MsgChangeRole})
+			}
+		}
+	}()
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		accepted int
+		rejected int
+	)
+	for p := 0; p < 10; p++ {
+		wg.Add(1)
+		go func(p int) {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				id := uint64(p*1000 + i + 1)
+				_, err := sub.proposeAndWait(context.Background(), "test", []replica.Log{{Id: id, Data: []byte("hello")}})
+				mu.Lock()
+				if err == nil {
+					accepted++
+				} else {
+					assert.True(t, errors.Is(err, ErrNotLeader), err)
+					rejected++
+				}
+				mu.Unlock()
+			}
+		}(p)
+	}
+	wg.Wait()
+	close(stopC)
+	changeWg.Wait()
+
+	th.mu.Lock()
+	defer th.mu.Unlock()
+	assert.Equal(t, 0, th.wrongTerm)
+	assert.Equal(t, accepted, len(th.logs))
+	assert.Equal(t, 1000, accepted+rejected)
+}
+
+// 等待超时和提案被拒绝同时发生，等待者走超时返回时拒绝的原因也被删除，不会残留
+func TestProposeRejectRacesTimeout(t *testing.T) {
+	setupTestTrace(t)
+
+	// 拒绝后等待者没有取走原因，而是走了超时返回
+	pw := newProposeWait("test")
+	pw.add("1", []uint64{1})
+	pw.reject("1", ErrNotLeader)
+	pw.remove("1")
+	assertProposeWaitEmpty(t, pw)
+
+	// 提案不会被处理（reactor没有启动），超时的同时不停地拒绝
+	r := New(NewOptions(WithSubReactorNum(1), WithNodeId(1), WithProposeTimeout(time.Millisecond*20)))
+	th := &testTermHandler{term: 1, leaderId: 1}
+	r.AddHandler("test", th)
+	h := r.handler("test")
+	sub := r.reactorSub("test")
+
+	stopC := make(chan struct{})
+	doneC := make(chan struct{})
+	go func() {
+		defer close(doneC)
+		for {
+			select {
+			case <-stopC:
+				return
+			default:
+			}
+			for i := 1; i <= 20; i++ {
+				h.rejectPropose(strconv.Itoa(i), ErrNotLeader)
+			}
+		}
+	}()
+	var wg sync.WaitGroup
+	for i := 1; i <= 20; i++ {
+		wg.Add(1)
+		go func(id uint64) {
+			defer wg.Done()
+			_, err := sub.proposeAndWait(context.Background(), "test", []replica.Log{{Id: id, Data: []byte("hello")}})
+			assert.Error(t, err)
+		}(uint64(i))
+	}
+	wg.Wait()
+	close(stopC)
+	<-doneC
+	assertProposeWaitEmpty(t, h.proposeWait)
+}
@@ -0,0 +1,47 @@
+package reactor
+
+import (
+	"context"
+)
+
+const (
+	// MaxProposeValuesCount 提案元数据最多的键值对数量
+	MaxProposeValuesCount = 8
+	// MaxProposeValueLen 提案元数据单个键或值的最大长度（超过会被截断）
+	MaxProposeValueLen = 128
+)
+
+type proposeValuesKey struct{}
+
+// WithProposeContextValues 将请求元数据（比如请求id，用户id）附加到提案的上下文中
+// 元数据会记录到提案的trace span上，并且在领导节点应用日志时可以通过Reactor.ProposeValues获取
+// 为了避免日志和trace膨胀，最多保留MaxProposeValuesCount个键值对，超过MaxProposeValueLen的键或值会被截断
+func WithProposeContextValues(ctx context.Context, values map[string]string) context.Context {
+	if len(values) == 0 {
+		return ctx
+	}
+	bounded := make(map[string]string, min(len(values), MaxProposeValuesCount))
+	for k, v := range values {
+		if len(bounded) >= MaxProposeValuesCount {
+			break
+		}
+		bounded[truncateProposeValue(k)] = truncateProposeValue(v)
+	}
+	return context.WithValue(ctx, proposeValuesKey{}, bounded)
+}
+
+// ProposeContextValues 获取上下文中的提案元数据
+func ProposeContextValues(ctx context.Context) map[string]string {
+	if ctx == nil {
+		return nil
+	}
+	values, _ := ctx.Value(proposeValuesKey{}).(map[string]string)
+	return values
+}
+
+func truncateProposeValue(v string) string {
+	if len(v) > MaxProposeValueLen {
+		return v[:MaxProposeValueLen]
+	}
+	return v
+}
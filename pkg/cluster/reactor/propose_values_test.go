@@ -0,0 +1,111 @@
+package reactor
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/WuKongIM/WuKongIM/pkg/cluster/replica"
+	"github.com/WuKongIM/WuKongIM/pkg/trace"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestWithProposeContextValues(t *testing.T) {
+	ctx := WithProposeContextValues(context.Background(), map[string]string{
+		"requestId": "req-1",
+		"uid":       strings.Repeat("u", MaxProposeValueLen+10),
+	})
+	values := ProposeContextValues(ctx)
+	assert.Equal(t, "req-1", values["requestId"])
+	assert.Equal(t, MaxProposeValueLen, len(values["uid"]))
+
+	values = make(map[string]string)
+	for i := 0; i < MaxProposeValuesCount*2; i++ {
+		values[strconv.Itoa(i)] = "v"
+	}
+	ctx = WithProposeContextValues(context.Background(), values)
+	assert.Equal(t, MaxProposeValuesCount, len(ProposeContextValues(ctx)))
+
+	assert.Nil(t, ProposeContextValues(context.Background()))
+}
+
+func TestHandlerProposeValues(t *testing.T) {
+	h := &handler{}
+	values := map[string]string{"requestId": "req-1"}
+	h.setProposeValues(1, values)
+	h.setProposeValues(2, values)
+	h.setProposeValues(3, values)
+
+	assert.Equal(t, values, h.getProposeValues(2))
+
+	// 应用[1,3)后只剩下标3
+	h.removeProposeValues(1, 3)
+	assert.Nil(t, h.getProposeValues(1))
+	assert.Nil(t, h.getProposeValues(2))
+	assert.Equal(t, values, h.getProposeValues(3))
+}
+
+// 已提案还没应用的元数据可以填到应用的日志里，不再是领导后清空
+func TestHandlerFillAndClearProposeValues(t *testing.T) {
+	r := New(NewOptions(WithSubReactorNum(1), WithNodeId(1)))
+	r.AddHandler("test", &testHardStateHandler{})
+	h := r.handler("test")
+	values := map[string]string{"requestId": "req-1"}
+	h.setProposeValues(2, values)
+
+	logs := []replica.Log{{Index: 1}, {Index: 2}}
+	r.FillProposeValues("test", logs)
+	assert.Nil(t, logs[0].Values)
+	assert.Equal(t, values, logs[1].Values)
+
+	// 还是领导不清空
+	h.setHardState(replica.HardState{LeaderId: 1, Term: 1})
+	assert.Equal(t, values, h.getProposeValues(2))
+
+	// 变为追随者后清空
+	h.setHardState(replica.HardState{LeaderId: 2, Term: 2})
+	assert.Nil(t, h.getProposeValues(2))
+}
+
+// 提案元数据记录在提案的span上
+func TestProposeValuesOnSpan(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	prevProvider := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	defer otel.SetTracerProvider(prevProvider)
+
+	setupTestTrace(t, trace.WithTraceOn(true))
+
+	sub, _ := newTestCommitReactor(t)
+	defer sub.Stop()
+
+	ctx := WithProposeContextValues(context.Background(), map[string]string{"requestId": "req-1", "uid": "u1"})
+	_, err := sub.proposeAndWait(ctx, "test", []replica.Log{{Id: 1, Data: []byte("hello")}})
+	assert.NoError(t, err)
+
+	var attrs map[string]string
+	for _, span := range recorder.Ended() {
+		if span.Name() != "proposeAndWait" {
+			continue
+		}
+		attrs = make(map[string]string)
+		for _, attr := range span.Attributes() {
+			attrs[string(attr.Key)] = attr.Value.Emit()
+		}
+	}
+	assert.NotNil(t, attrs)
+	assert.Equal(t, "req-1", attrs["requestId"])
+	assert.Equal(t, "u1", attrs["uid"])
+	assert.Equal(t, "test", attrs["handleKey"])
+}
+
+type testHardStateHandler struct {
+	testCommitHandler
+}
+
+func (t *testHardStateHandler) SetHardState(hd replica.HardState) {
+}
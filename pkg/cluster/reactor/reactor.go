@@ -5,11 +5,14 @@ import (
 	"fmt"
 	"runtime/debug"
 	"sync"
+	"time"
 
 	"github.com/WuKongIM/WuKongIM/pkg/cluster/replica"
+	"github.com/WuKongIM/WuKongIM/pkg/trace"
 	"github.com/WuKongIM/WuKongIM/pkg/wklog"
 	"github.com/lni/goutils/syncutil"
 	"github.com/panjf2000/ants/v2"
+	"go.uber.org/atomic"
 	"go.uber.org/zap"
 )
 
@@ -18,6 +21,7 @@ type Reactor struct {
 	opts        *Options
 	mu          sync.RWMutex
 	taskPool    *ants.Pool
+	resultPool  *ants.Pool // 通知提案结果的协程池（非阻塞，池满时由调用者直接通知）
 	wklog.Log
 
 	processInitC              chan *initReq              // 处理频道初始化
@@ -32,6 +36,8 @@ type Reactor struct {
 	stopper *syncutil.Stopper
 
 	request IRequest
+
+	idleSweepPaused atomic.Bool // 是否暂停空闲回收
 }
 
 func New(opts *Options) *Reactor {
@@ -59,6 +65,15 @@ func New(opts *Options) *Reactor {
 		r.Panic("create task pool error", zap.Error(err))
 	}
 	r.taskPool = taskPool
+	resultPool, err := ants.NewPool(opts.ProposeResultChannelBuffer, ants.WithNonblocking(true), ants.WithPanicHandler(func(err interface{}) {
+		stack := debug.Stack()
+		r.Panic("通知提案结果失败", zap.Any("error", err), zap.String("stack", string(stack)))
+	}))
+	if err != nil {
+		r.Panic("create propose result pool error", zap.Error(err))
+	}
+	r.resultPool = resultPool
+	r.idleSweepPaused.Store(opts.IdleSweepPaused)
 
 	for i := 0; i < int(r.opts.SubReactorNum); i++ {
 		sub := r.newReactorSub(i)
@@ -104,11 +119,38 @@ func (r *Reactor) Stop() {
 	r.stopper.Stop()
 
 }
+
+// PauseIdleSweep 暂停空闲回收，暂停期间空闲的处理者不会被移除（例如批量导入前，避免即将用到的频道被反复销毁和重建）
+func (r *Reactor) PauseIdleSweep() {
+	if r.idleSweepPaused.CompareAndSwap(false, true) {
+		r.Info("idle sweep paused")
+	}
+}
+
+// ResumeIdleSweep 恢复空闲回收
+func (r *Reactor) ResumeIdleSweep() {
+	if r.idleSweepPaused.CompareAndSwap(true, false) {
+		r.Info("idle sweep resumed")
+	}
+}
+
+// IdleSweepPaused 空闲回收是否已暂停
+func (r *Reactor) IdleSweepPaused() bool {
+	return r.idleSweepPaused.Load()
+}
+
 func (r *Reactor) ProposeAndWait(ctx context.Context, handleKey string, logs []replica.Log) ([]ProposeResult, error) {
 	sub := r.reactorSub(handleKey)
 	return sub.proposeAndWait(ctx, handleKey, logs)
 }
 
+// ReadIndex 线性一致读，领导确认多数副本仍然认可自己是领导后，返回可以安全读到的日志下标（读之前提交的写入都不会超过这个下标）
+// 不是领导（或确认期间失去了领导权）返回ErrNotLeader
+func (r *Reactor) ReadIndex(ctx context.Context, handleKey string) (uint64, error) {
+	sub := r.reactorSub(handleKey)
+	return sub.readIndex(ctx, handleKey)
+}
+
 func (r *Reactor) AddHandler(key string, handler IHandler) {
 	h := getHandlerFromPool()
 	h.init(key, handler, r)
@@ -133,6 +175,44 @@ func (r *Reactor) Handler(key string) IHandler {
 	return h.handler
 }
 
+// FillProposeValues 把日志的提案元数据填到logs[i].Values（只在领导节点应用日志前有效，见WithProposeContextValues）
+func (r *Reactor) FillProposeValues(key string, logs []replica.Log) {
+	h := r.handler(key)
+	if h == nil {
+		return
+	}
+	h.fillProposeValues(logs)
+}
+
+// RejectProposes 让分区所有等待中的提案返回err（例如领导放弃了领导权，不能再等待这些提案的提交结果）
+func (r *Reactor) RejectProposes(key string, err error) {
+	h := r.handler(key)
+	if h == nil {
+		return
+	}
+	h.proposeWait.rejectAll(err)
+}
+
+// WaitProposesDrained 等待分区所有进行中的提案完成（提交或失败），ctx到期时返回ctx的错误，分区不存在直接返回
+// 调用方需要先停止新的提案（例如暂停提案），否则可能一直等不到
+func (r *Reactor) WaitProposesDrained(ctx context.Context, key string) error {
+	h := r.handler(key)
+	if h == nil {
+		return nil
+	}
+	pw := h.proposeWait
+	tick := time.NewTicker(time.Millisecond * 10)
+	defer tick.Stop()
+	for pw.pending() > 0 && !pw.isClosed() {
+		select {
+		case <-tick.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
 func (r *Reactor) handler(key string) *handler {
 	sub := r.reactorSub(key)
 	h := sub.handler(key)
@@ -159,6 +239,11 @@ func (r *Reactor) ExistHandler(key string) bool {
 
 func (r *Reactor) AddMessage(m Message) {
 	sub := r.reactorSub(m.HandlerKey)
+	if err := r.checkMessageSize(m); err != nil {
+		r.Warn("drop message", zap.Error(err), zap.String("handlerKey", m.HandlerKey), zap.String("msgType", m.MsgType.String()), zap.Uint64("from", m.From), zap.Uint64("index", m.Index))
+		trace.GlobalTrace.Metrics.Cluster().MessageTooLargeDroppedCountAdd(sub.clusterKind(), 1)
+		return
+	}
 	sub.addMessage(m)
 }
 
@@ -1,7 +1,11 @@
 package reactor
 
 import (
+	"errors"
+	"fmt"
+
 	"github.com/WuKongIM/WuKongIM/pkg/cluster/replica"
+	"github.com/WuKongIM/WuKongIM/pkg/trace"
 	"go.uber.org/zap"
 )
 
@@ -214,6 +218,8 @@ func (r *Reactor) handleLeaderTermStartIndexResp(handler *handler, index uint64,
 		r.Error("truncate log failed", zap.Error(err), zap.String("handlerKey", handler.key), zap.Uint64("index", index))
 		return 0, err
 	}
+	handler.logCache.truncateFrom(truncateIndex)
+	handler.setLastIndex(truncateIndex - 1)
 	return truncateIndex, nil
 }
 
@@ -274,19 +280,27 @@ func (r *Reactor) processStoreAppend(reqs []AppendLogReq) {
 	}
 
 	for _, req := range reqs {
-		for _, log := range req.Logs {
-			handler := r.handler(req.HandleKey)
-			if handler != nil && log.Term > handler.getLastLeaderTerm() {
-				handler.setLastLeaderTerm(log.Term)
-				err = handler.handler.SetLeaderTermStartIndex(log.Term, log.Index)
-				if err != nil {
-					r.Error("set leader term start index failed", zap.Error(err), zap.String("handlerKey", req.HandleKey), zap.Uint32("term", log.Term), zap.Uint64("index", log.Index))
-				}
-			}
+		if req.Err != nil {
+			continue
+		}
+		if handler := r.handler(req.HandleKey); handler != nil {
+			r.updateLeaderTermStartIndex(handler, req.Logs)
 		}
 	}
 
 	for _, req := range reqs {
+		if req.Err != nil {
+			r.Error("append logs failed", zap.Error(req.Err), zap.String("handlerKey", req.HandleKey))
+			r.Step(req.HandleKey, replica.Message{
+				MsgType: replica.MsgStoreAppendResp,
+				Reject:  true,
+			})
+			continue
+		}
+
+		if handler := r.handler(req.HandleKey); handler != nil {
+			handler.logCache.append(req.Logs)
+		}
 
 		lastLog := req.Logs[len(req.Logs)-1]
 		r.Step(req.HandleKey, replica.Message{
@@ -297,6 +311,48 @@ func (r *Reactor) processStoreAppend(reqs []AppendLogReq) {
 
 }
 
+// updateLeaderTermStartIndex 日志里有新的领导任期时记录任期开始的第一条日志下标
+func (r *Reactor) updateLeaderTermStartIndex(h *handler, logs []replica.Log) {
+	for _, log := range logs {
+		if log.Term > h.getLastLeaderTerm() {
+			h.setLastLeaderTerm(log.Term)
+			err := h.handler.SetLeaderTermStartIndex(log.Term, log.Index)
+			if err != nil {
+				r.Error("set leader term start index failed", zap.Error(err), zap.String("handlerKey", h.key), zap.Uint32("term", log.Term), zap.Uint64("index", log.Index))
+			}
+		}
+	}
+}
+
+// AppendSnapshotLogs 安装快照时把快照里的日志追加到存储（和复制日志走同一个存储入口，并更新领导任期的开始下标）
+// 调用者需要先通过副本的BeginInstallSnapshot保证副本没有正在存储和应用的日志
+func (r *Reactor) AppendSnapshotLogs(key string, logs []replica.Log) error {
+	if len(logs) == 0 {
+		return nil
+	}
+	h := r.handler(key)
+	if h == nil {
+		return ErrHandlerNotFound
+	}
+	// 快照里可能有本地已经存储过的日志，先用存储里最新的领导任期，避免把已有任期的开始下标改掉
+	lastTerm, err := h.handler.LeaderLastTerm()
+	if err != nil {
+		return err
+	}
+	if lastTerm > h.getLastLeaderTerm() {
+		h.setLastLeaderTerm(lastTerm)
+	}
+	reqs := []AppendLogReq{{HandleKey: key, Logs: logs}}
+	if err := r.request.AppendLogBatch(reqs); err != nil {
+		return err
+	}
+	if reqs[0].Err != nil {
+		return reqs[0].Err
+	}
+	r.updateLeaderTermStartIndex(h, logs)
+	return nil
+}
+
 // =================================== 获取日志 ===================================
 
 func (r *Reactor) addGetLogReq(req *getLogReq) {
@@ -323,6 +379,9 @@ func (r *Reactor) processGetLog(req *getLogReq) {
 	logs, err := r.getAndMergeLogs(req)
 	if err != nil {
 		r.Error("get logs failed", zap.Error(err))
+		if errors.Is(err, replica.ErrCompacted) && r.opts.Event.OnLogsCompacted != nil {
+			r.opts.Event.OnLogsCompacted(req.h.key, req.to)
+		}
 		r.Step(req.h.key, replica.Message{
 			MsgType: replica.MsgSyncGetResp,
 			Reject:  true,
@@ -353,10 +412,15 @@ func (r *Reactor) getAndMergeLogs(req *getLogReq) ([]replica.Log, error) {
 
 	var resultLogs []replica.Log
 	if startIndex <= req.lastIndex {
-		logs, err := req.h.handler.GetLogs(startIndex, req.lastIndex+1)
-		if err != nil {
-			r.Error("get logs error", zap.Error(err), zap.Uint64("startIndex", startIndex), zap.Uint64("lastIndex", req.lastIndex))
-			return nil, err
+		// 最近存储的日志优先从缓存获取，缓存里没有的再从存储获取（两者都按同步大小限制返回）
+		logs, ok := req.h.logCache.get(startIndex, req.lastIndex+1, r.opts.LogSyncLimitSize)
+		if !ok {
+			var err error
+			logs, err = req.h.handler.GetLogs(startIndex, req.lastIndex+1)
+			if err != nil {
+				r.Error("get logs error", zap.Error(err), zap.Uint64("startIndex", startIndex), zap.Uint64("lastIndex", req.lastIndex))
+				return nil, err
+			}
 		}
 
 		startLogLen := len(logs)
@@ -421,6 +485,16 @@ func (r *Reactor) processApplyLogLoop() {
 
 func (r *Reactor) processApplyLog(req *applyLogReq) {
 
+	if r.applyOrderingMode(req.h.key) == ApplyOrderingRelaxed {
+		r.processApplyLogRelaxed(req)
+		return
+	}
+
+	r.Step(req.h.key, r.applyLogs(req))
+}
+
+// applyLogs 严格按顺序应用日志，返回需要交给处理者的应用结果
+func (r *Reactor) applyLogs(req *applyLogReq) replica.Message {
 	if !r.opts.IsCommittedAfterApplied {
 		// 提交日志
 		req.h.didCommit(req.appyingIndex+1, req.committedIndex+1)
@@ -429,11 +503,10 @@ func (r *Reactor) processApplyLog(req *applyLogReq) {
 	appliedSize, err := req.h.handler.ApplyLogs(req.appyingIndex+1, req.committedIndex+1)
 	if err != nil {
 		r.Panic("apply logs failed", zap.Error(err))
-		r.Step(req.h.key, replica.Message{
+		return replica.Message{
 			MsgType: replica.MsgApplyLogsResp,
 			Reject:  true,
-		})
-		return
+		}
 	}
 
 	if r.opts.IsCommittedAfterApplied {
@@ -441,12 +514,31 @@ func (r *Reactor) processApplyLog(req *applyLogReq) {
 		req.h.didCommit(req.appyingIndex+1, req.committedIndex+1)
 	}
 
-	r.Step(req.h.key, replica.Message{
+	// 已应用的日志不再需要提案元数据
+	req.h.removeProposeValues(req.appyingIndex+1, req.committedIndex+1)
+
+	req.h.applyLag.didApply(req.committedIndex, r.opts.MaxApplyLag)
+
+	r.maybeSnapshot(req.h, req.appyingIndex, req.committedIndex)
+
+	return replica.Message{
 		MsgType:     replica.MsgApplyLogsResp,
 		Index:       req.committedIndex,
 		AppliedSize: appliedSize,
-	})
+	}
+}
 
+// markApplyAnomaly 已应用下标超过已提交下标，标记分区异常
+// 不回复应用结果，副本一直处于应用中，之后不会再应用日志，避免在错误的状态上继续应用
+func (r *Reactor) markApplyAnomaly(h *handler, m replica.Message) {
+	if !h.degraded.CompareAndSwap(false, true) {
+		return
+	}
+	reason := fmt.Sprintf("applied index %d is ahead of committed index %d", m.AppliedIndex, m.CommittedIndex)
+	r.Error("applied index is ahead of committed index, handler is degraded", zap.String("handler", h.key), zap.Uint64("appliedIndex", m.AppliedIndex), zap.Uint64("committedIndex", m.CommittedIndex), zap.Uint64("applyingIndex", m.ApplyingIndex))
+	if r.opts.Event.OnDegraded != nil {
+		r.opts.Event.OnDegraded(h.key, reason)
+	}
 }
 
 type applyLogReq struct {
@@ -553,3 +645,14 @@ type followerToLeaderReq struct {
 	h          *handler
 	followerId uint64
 }
+
+// =================================== 提案结果通知 ===================================
+
+// submitProposeResult 在通知协程池里执行提案结果通知，池满时返回错误由调用者（应用协程）直接通知，不会阻塞等待池空闲
+func (r *Reactor) submitProposeResult(f func()) error {
+	err := r.resultPool.Submit(f)
+	if err != nil {
+		trace.GlobalTrace.Metrics.Cluster().ProposeResultBackpressureCountAdd(r.opts.clusterKind(), 1)
+	}
+	return err
+}
@@ -10,12 +10,14 @@ import (
 	"github.com/WuKongIM/WuKongIM/pkg/cluster/replica"
 	"github.com/WuKongIM/WuKongIM/pkg/trace"
 	"github.com/WuKongIM/WuKongIM/pkg/wklog"
+	"github.com/WuKongIM/WuKongIM/pkg/wkutil"
 	"github.com/lni/goutils/syncutil"
 	"go.uber.org/atomic"
 	"go.uber.org/zap"
 )
 
 type ReactorSub struct {
+	index    int // 在reactor中的下标
 	stopper  *syncutil.Stopper
 	opts     *Options
 	handlers *handlerList // 当前处理者集合
@@ -25,25 +27,29 @@ type ReactorSub struct {
 	wklog.Log
 
 	tmpHandlers []*handler
-
-	avdanceC chan struct{}
-	stepC    chan stepReq
-	proposeC chan proposeReq
-	mr       *Reactor
-	stopped  atomic.Bool
+	readyMsgs   []replica.Message // handleReady期间复用的消息缓存（只在本协程使用）
+
+	avdanceC     chan struct{}
+	stepC        chan stepReq
+	proposeC     chan proposeReq
+	proposeHighC chan proposeReq // 高优先级的提案（控制消息），会先于proposeC里的提案追加
+	mr           *Reactor
+	stopped      atomic.Bool
 }
 
 func NewReactorSub(index int, mr *Reactor) *ReactorSub {
 	return &ReactorSub{
-		mr:          mr,
-		stopper:     syncutil.NewStopper(),
-		opts:        mr.opts,
-		handlers:    newHandlerList(),
-		Log:         wklog.NewWKLog(fmt.Sprintf("ReactorSub[%s:%d:%d]", mr.opts.ReactorType.String(), mr.opts.NodeId, index)),
-		tmpHandlers: make([]*handler, 0, 1000),
-		avdanceC:    make(chan struct{}, 1),
-		stepC:       make(chan stepReq, 1024),
-		proposeC:    make(chan proposeReq, 1024),
+		index:        index,
+		mr:           mr,
+		stopper:      syncutil.NewStopper(),
+		opts:         mr.opts,
+		handlers:     newHandlerList(),
+		Log:          wklog.NewWKLog(fmt.Sprintf("ReactorSub[%s:%d:%d]", mr.opts.ReactorType.String(), mr.opts.NodeId, index)),
+		tmpHandlers:  make([]*handler, 0, 1000),
+		avdanceC:     make(chan struct{}, 1),
+		stepC:        make(chan stepReq, 1024),
+		proposeC:     make(chan proposeReq, 1024),
+		proposeHighC: make(chan proposeReq, 1024),
 	}
 }
 
@@ -78,6 +84,12 @@ func (r *ReactorSub) run() {
 				r.Info("ReactorSub: step handler not exist", zap.String("handlerKey", req.handlerKey), zap.String("msgType", req.msg.MsgType.String()), zap.Uint64("from", req.msg.From))
 				continue
 			}
+			if r.opts.ProposeAckTraceSampleRate > 0 {
+				r.traceAck(handler, req.msg)
+			}
+			if req.msg.MsgType == replica.MsgStoreAppendResp && !req.msg.Reject {
+				handler.storedIndex.Store(req.msg.Index)
+			}
 			err := handler.handler.Step(req.msg)
 			if err != nil {
 				r.Error("step message failed", zap.Error(err))
@@ -89,20 +101,12 @@ func (r *ReactorSub) run() {
 					req.resultC <- nil
 				}
 			}
+		case req := <-r.proposeHighC:
+			r.handlePropose(req)
 		case req := <-r.proposeC:
-
-			lastLogIndex, term := req.handler.lastLogIndexAndTerm()
-			for i := 0; i < len(req.logs); i++ {
-				lg := req.logs[i]
-				lg.Index = lastLogIndex + 1 + uint64(i)
-				lg.Term = term
-				req.logs[i] = lg
-				req.handler.didPropose(req.waitKey, lg.Id, lg.Index)
-			}
-			err := req.handler.handler.Step(replica.NewProposeMessageWithLogs(r.opts.NodeId, term, req.logs))
-			if err != nil {
-				r.Error("step propose message failed", zap.Error(err))
-			}
+			// 高优先级的提案先追加
+			r.handleHighPriorityProposes()
+			r.handlePropose(req)
 
 		// case handler := <-r.storeAppendRespC:
 		// 	err := handler.handler.Step(replica.NewMsgStoreAppendResp(r.opts.NodeId, handler.lastIndex.Load()))
@@ -119,6 +123,58 @@ func (r *ReactorSub) run() {
 
 }
 
+// 处理排队中的高优先级提案
+func (r *ReactorSub) handleHighPriorityProposes() {
+	for {
+		select {
+		case req := <-r.proposeHighC:
+			r.handlePropose(req)
+		default:
+			return
+		}
+	}
+}
+
+// handlePropose 给一批提案分配下标并追加，只在sub的协程里执行，所以同一个处理者的批次之间是串行的，
+// 一批日志的下标一定是连续的[lastLogIndex+1, lastLogIndex+len(logs)]
+func (r *ReactorSub) handlePropose(req proposeReq) {
+	if req.wait.isClosed() { // 排队期间处理者被移除了，等待已经失败
+		return
+	}
+	lastLogIndex, term := req.handler.lastLogIndexAndTerm()
+	// 提案排队期间领导变更过（不再是领导或任期变了），不能用新的任期追加旧任期检查通过的提案
+	if !req.handler.isLeader() || term != req.term {
+		r.Warn("leader changed before propose appended, reject", zap.String("handler", req.handler.key), zap.Uint32("proposeTerm", req.term), zap.Uint32("term", term), zap.Uint64("leader", req.handler.leaderId()))
+		req.handler.rejectPropose(req.waitKey, ErrNotLeader)
+		return
+	}
+	for i := 0; i < len(req.logs); i++ {
+		lg := req.logs[i]
+		lg.Index = lastLogIndex + 1 + uint64(i)
+		lg.Term = term
+		req.logs[i] = lg
+		if len(req.values) > 0 {
+			req.handler.setProposeValues(lg.Index, req.values)
+		}
+	}
+	req.handler.didProposeBatch(req.waitKey, lastLogIndex+1)
+	if r.opts.ProposeAckTraceSampleRate > 0 && len(req.logs) > 0 {
+		req.handler.ackTracer.didPropose(req.waitKey, req.logs[len(req.logs)-1].Index)
+	}
+	err := req.handler.handler.Step(replica.NewProposeMessageWithLogs(r.opts.NodeId, term, req.logs))
+	if err != nil {
+		r.Error("step propose message failed", zap.Error(err))
+		// 没有追加成功的下标会分配给下一批提案，不能继续等待，否则下一批提交时会被当成这批提交了
+		req.handler.dropPropose(req.waitKey)
+		return
+	}
+	if newLastLogIndex, _ := req.handler.lastLogIndexAndTerm(); newLastLogIndex != lastLogIndex+uint64(len(req.logs)) {
+		r.Error("propose log indexes not contiguous", zap.String("handler", req.handler.key), zap.Uint64("lastLogIndex", lastLogIndex), zap.Int("logs", len(req.logs)), zap.Uint64("newLastLogIndex", newLastLogIndex))
+		req.handler.dropPropose(req.waitKey)
+	}
+}
+
+// proposeAndWait 提案并等待提交，返回的结果和logs的顺序一致，并且下标是连续的（results[i].Index == results[0].Index+i）
 func (r *ReactorSub) proposeAndWait(ctx context.Context, handleKey string, logs []replica.Log) ([]ProposeResult, error) {
 	if r.stopped.Load() {
 		return nil, ErrReactorSubStopped
@@ -126,6 +182,16 @@ func (r *ReactorSub) proposeAndWait(ctx context.Context, handleKey string, logs
 	if len(logs) == 0 {
 		return nil, errors.New("proposeAndWait: logs is empty")
 	}
+	if !r.opts.AllowEmptyPayload {
+		for _, log := range logs {
+			if len(log.Data) == 0 {
+				return nil, ErrEmptyPayload
+			}
+		}
+	}
+	if err := r.checkProposeLogSize(logs); err != nil {
+		return nil, err
+	}
 	// -------------------- 延迟统计 --------------------
 	startTime := time.Now()
 	defer func() {
@@ -142,6 +208,24 @@ func (r *ReactorSub) proposeAndWait(ctx context.Context, handleKey string, logs
 			}
 		}
 	}()
+	// -------------------- 提案链路 --------------------
+	values := ProposeContextValues(ctx)
+	var span trace.Span
+	if r.opts.ReactorType == ReactorTypeChannel {
+		channelId, channelType := wkutil.ChannelFromlKey(handleKey)
+		_, span = trace.GlobalTrace.StartChannelSpan(ctx, "proposeAndWait", channelId, channelType)
+	} else {
+		_, span = trace.GlobalTrace.StartSpan(ctx, "proposeAndWait")
+	}
+	defer span.End()
+	span.SetString("clusterKind", r.clusterKind().String())
+	span.SetString("handleKey", handleKey)
+	span.SetInt("logCount", len(logs))
+	span.SetUint64("lastLogId", logs[len(logs)-1].Id)
+	for k, v := range values {
+		span.SetString(k, v)
+	}
+
 	// -------------------- 初始化提案数据 --------------------
 	handler := r.handlers.get(handleKey)
 	if handler == nil {
@@ -153,10 +237,28 @@ func (r *ReactorSub) proposeAndWait(ctx context.Context, handleKey string, logs
 		return nil, ErrPausePropopose
 	}
 
+	// 应用落后太多，等待应用追上后再提案
+	if r.opts.MaxApplyLag > 0 && handler.applyLag.lag() > r.opts.MaxApplyLag {
+		r.Warn("apply lag too large, throttle propose", zap.String("handler", handler.key), zap.Uint64("applyLag", handler.applyLag.lag()), zap.Uint64("maxApplyLag", r.opts.MaxApplyLag))
+		throttleCtx, throttleCancel := context.WithTimeout(ctx, r.opts.ProposeTimeout)
+		err := handler.applyLag.waitCatchUp(throttleCtx, r.opts.MaxApplyLag)
+		throttleCancel()
+		if err != nil {
+			trace.GlobalTrace.Metrics.Cluster().ProposeThrottledCountAdd(r.clusterKind(), 1)
+			return nil, err
+		}
+	}
+
+	// 任期和领导一起检查，前后任期不一致说明检查期间领导变更了
+	_, term := handler.lastLogIndexAndTerm()
 	if !handler.isLeader() {
 		r.Error("not leader", zap.String("handler", handler.key), zap.Uint64("leader", handler.leaderId()))
 		return nil, ErrNotLeader
 	}
+	if _, currentTerm := handler.lastLogIndexAndTerm(); currentTerm != term {
+		r.Warn("term changed while checking leader", zap.String("handler", handler.key), zap.Uint32("term", term), zap.Uint32("currentTerm", currentTerm))
+		return nil, ErrNotLeader
+	}
 
 	ids := make([]uint64, 0, len(logs))
 	for _, log := range logs {
@@ -168,36 +270,61 @@ func (r *ReactorSub) proposeAndWait(ctx context.Context, handleKey string, logs
 	defer cancel()
 
 	// -------------------- 获得等待提交提案的句柄 --------------------
-	waitC := handler.addWait(waitKey, ids)
+	// 处理者移除后会被重置复用，之后都通过pw访问这次提案的等待
+	pw := handler.proposeWait
+	waitC := pw.add(waitKey, ids)
+	// 不论以哪种方式返回都清理等待（超时和拒绝同时发生时，拒绝的原因也一起删除）
+	defer pw.remove(waitKey)
+
+	// 采样的提案，记录每个副本确认的顺序和耗时（和pw一样先取出来，处理者移除后ackTracer会被置空）
+	at := handler.ackTracer
+	if at.sampled(r.opts.ProposeAckTraceSampleRate) && at.start(waitKey) {
+		defer func() {
+			acks := at.finish(waitKey)
+			span.SetInt("replicaAckCount", len(acks))
+			span.SetString("replicaAcks", formatAcks(acks))
+		}()
+	}
 
 	// -------------------- 添加提案请求 --------------------
-	req := newProposeReq(handler, waitKey, logs)
+	req := newProposeReq(handler, waitKey, logs, values, term)
+	proposeC := r.proposeC
+	if ProposeContextPriority(ctx) == ProposePriorityHigh {
+		proposeC = r.proposeHighC
+	}
 	select {
-	case r.proposeC <- req:
+	case proposeC <- req:
 	case <-timeoutCtx.Done():
-		if !handler.proposeWait.exist(waitKey) {
-			r.Panic("proposeAndWait: propose wait not exist", zap.String("waitKey", waitKey), zap.String("handler", handler.key))
-		}
-		handler.removeWait(waitKey)
-		trace.GlobalTrace.Metrics.Cluster().ProposeFailedCountAdd(trace.ClusterKindChannel, 1)
+		// 排队期间等待可能已经被拒绝（比如领导放弃了领导权），等待由defer统一清理
+		trace.GlobalTrace.Metrics.Cluster().ProposeFailedCountAdd(r.clusterKind(), 1)
 		return nil, timeoutCtx.Err()
 	case <-r.stopper.ShouldStop():
-		handler.removeWait(waitKey)
-		trace.GlobalTrace.Metrics.Cluster().ProposeFailedCountAdd(trace.ClusterKindChannel, 1)
+		trace.GlobalTrace.Metrics.Cluster().ProposeFailedCountAdd(r.clusterKind(), 1)
 		return nil, ErrReactorSubStopped
 	}
 
 	// -------------------- 等待提案结果 --------------------
+	proposedAt := time.Now()
 	select {
-	case items := <-waitC:
+	case items, ok := <-waitC:
+		if !ok {
+			trace.GlobalTrace.Metrics.Cluster().ProposeFailedCountAdd(r.clusterKind(), 1)
+			if err := pw.takeRejectErr(waitKey); err != nil {
+				return nil, err
+			}
+			return nil, ErrProposeDropped
+		}
+		if err := checkProposeIndexContiguous(items); err != nil {
+			r.Error("proposeAndWait: propose results not contiguous", zap.Error(err), zap.String("handler", handler.key), zap.String("waitKey", waitKey))
+			return nil, err
+		}
+		trace.GlobalTrace.Metrics.Cluster().ObserveProposeCommitLatency(r.clusterKind(), time.Since(proposedAt).Seconds())
 		return items, nil
 	case <-timeoutCtx.Done():
-		handler.removeWait(waitKey)
-		trace.GlobalTrace.Metrics.Cluster().ProposeFailedCountAdd(trace.ClusterKindChannel, 1)
+		trace.GlobalTrace.Metrics.Cluster().ProposeFailedCountAdd(r.clusterKind(), 1)
 		return nil, timeoutCtx.Err()
 	case <-r.stopper.ShouldStop():
-		handler.removeWait(waitKey)
-		trace.GlobalTrace.Metrics.Cluster().ProposeFailedCountAdd(trace.ClusterKindChannel, 1)
+		trace.GlobalTrace.Metrics.Cluster().ProposeFailedCountAdd(r.clusterKind(), 1)
 		return nil, ErrReactorSubStopped
 	}
 
@@ -222,6 +349,20 @@ func (r *ReactorSub) proposeAndWait(ctx context.Context, handleKey string, logs
 // 	return nil
 // }
 
+// traceAck 副本的确认（领导收到追随者的同步请求说明追随者已存储了之前的日志，本地存储完成说明领导已存储）
+func (r *ReactorSub) traceAck(handler *handler, msg replica.Message) {
+	switch msg.MsgType {
+	case replica.MsgSyncReq:
+		if msg.Index > 0 && handler.isLeader() {
+			handler.ackTracer.ack(msg.From, msg.Index-1)
+		}
+	case replica.MsgStoreAppendResp:
+		if !msg.Reject {
+			handler.ackTracer.ack(r.opts.NodeId, msg.Index)
+		}
+	}
+}
+
 func (r *ReactorSub) step(handlerKey string, msg replica.Message) {
 
 	select {
@@ -271,7 +412,12 @@ func (r *ReactorSub) advance() {
 func (r *ReactorSub) readyEvents() {
 	hasEvent := true
 
-	for hasEvent && !r.stopped.Load() {
+	for rounds := 0; hasEvent && !r.stopped.Load(); rounds++ {
+		if r.opts.MaxReadyRounds > 0 && rounds >= r.opts.MaxReadyRounds {
+			// 还有ready没处理，先处理排队的提案和消息，然后马上回来继续
+			r.advance()
+			return
+		}
 		hasEvent = false
 
 		r.handlers.readHandlers(&r.tmpHandlers)
@@ -331,6 +477,7 @@ func (r *ReactorSub) handleReady(handler *handler) bool {
 		return false
 	}
 	rd := handler.ready()
+
 	if replica.IsEmptyReady(rd) {
 		return false
 	}
@@ -350,7 +497,14 @@ func (r *ReactorSub) handleReady(handler *handler) bool {
 
 	// }
 
-	for _, m := range rd.Messages {
+	// rd.Messages的所有权：
+	// 1. rd.Messages是处理者（副本）的消息缓存，副本下一次Step或Ready时会复用底层数组，所以只在本次handleReady期间有效，
+	//    下面同步Step（例如MsgVoteResp）追加的消息也会覆盖它，因此先拷贝到本协程复用的readyMsgs里再分发（不产生分配）
+	// 2. 交给异步阶段（addXxxReq、Send）的只能是按值拷贝的字段，不能持有readyMsgs或其中元素的指针；
+	//    消息里的Logs是副本日志的只读切片（容量已截断，不会被后续追加覆盖），可以跨阶段持有
+	msgs := append(r.readyMsgs[:0], rd.Messages...)
+
+	for _, m := range msgs {
 
 		if m.To == r.opts.NodeId { // 处理本地节点消息
 			if m.MsgType == replica.MsgVoteResp {
@@ -391,6 +545,9 @@ func (r *ReactorSub) handleReady(handler *handler) bool {
 				leaderId:       handler.leaderId(),
 			})
 		case replica.MsgStoreAppend: // 追加日志
+			if len(m.Logs) > 0 {
+				handler.setLastIndex(m.Logs[len(m.Logs)-1].Index)
+			}
 			r.mr.addStoreAppendReq(AppendLogReq{
 				HandleKey: handler.key,
 				Logs:      m.Logs,
@@ -405,11 +562,25 @@ func (r *ReactorSub) handleReady(handler *handler) bool {
 				to:         m.From,
 			})
 		case replica.MsgApplyLogs: // 应用日志
-			r.mr.addApplyLogReq(&applyLogReq{
+			if m.AppliedIndex > m.CommittedIndex { // 已应用下标超过已提交下标，不可能出现的状态（存储损坏或bug），不能当成没有日志需要应用
+				r.mr.markApplyAnomaly(handler, m)
+				continue
+			}
+			handler.applyLag.didCommit(m.CommittedIndex)
+			trace.GlobalTrace.Metrics.Cluster().ApplyLagRecord(r.clusterKind(), int64(handler.applyLag.lag()))
+			req := &applyLogReq{
 				h:              handler,
 				appyingIndex:   m.ApplyingIndex,
 				committedIndex: m.CommittedIndex,
-			})
+			}
+			if r.mr.applyInline(req) {
+				// 同步应用的结果直接交给处理者，不能再经过stepC（处理ready期间不消费stepC，大量频道同步应用会把stepC写满）
+				if err := handler.handler.Step(r.mr.applyLogs(req)); err != nil {
+					r.Error("step apply logs resp failed", zap.Error(err), zap.String("handler", handler.key))
+				}
+			} else {
+				r.mr.addApplyLogReq(req)
+			}
 		case replica.MsgLearnerToFollower: // 学习者转追随者
 			r.mr.addLearnerToFollowerReq(&learnerToFollowerReq{
 				h:         handler,
@@ -429,12 +600,21 @@ func (r *ReactorSub) handleReady(handler *handler) bool {
 		case replica.MsgSpeedLevelChange:
 			// fmt.Println("MsgSpeedLevelChange---------------->", handler.key, m.SpeedLevel.String())
 
+		case replica.MsgReadIndexResp: // 线性一致读结果
+			handler.readIndexWait.done(m.Index, m.CommittedIndex, m.Reject)
+
 		case replica.MsgSyncTimeout:
 			handler.syncTimeoutTick++
 			r.Info("sync timeout", zap.String("handler", handler.key), zap.Uint64("leader", handler.leaderId()), zap.Uint64("index", m.Index))
 
 		default:
 			if m.To != 0 && m.To != r.opts.NodeId {
+				if r.opts.MaxMessageSize > 0 && m.MsgType == replica.MsgSyncResp {
+					var ok bool
+					if m, ok = r.fitSyncResp(handler, m); !ok {
+						continue
+					}
+				}
 				// 发送消息
 				r.opts.Send(Message{
 					HandlerKey: handler.key,
@@ -443,6 +623,8 @@ func (r *ReactorSub) handleReady(handler *handler) bool {
 			}
 		}
 	}
+	clear(msgs) // 不持有Logs等引用，避免已处理的日志不能被回收
+	r.readyMsgs = msgs[:0]
 
 	return true
 }
@@ -459,7 +641,7 @@ func (r *ReactorSub) tick() {
 				handler.slowDown()
 			}
 
-			if handler.speedLevel() == replica.LevelStop && handler.shouldDestroy() { // 如果速度将为停止并可销毁
+			if handler.speedLevel() == replica.LevelStop && handler.shouldDestroy() && !r.mr.IdleSweepPaused() { // 如果速度将为停止并可销毁（空闲回收暂停时不销毁）
 				r.Debug("remove handler, speed stop", zap.String("handler", handler.key))
 				r.needRemoveKeys = append(r.needRemoveKeys, handler.key)
 			}
@@ -483,6 +665,13 @@ func (r *ReactorSub) addHandler(handler *handler) {
 
 func (r *ReactorSub) removeHandler(key string) *handler {
 	hd := r.handlers.remove(key)
+	if hd == nil { // 已经被移除了（重复移除）
+		return nil
+	}
+	hd.proposeWait.close(ErrHandlerRemoved)
+	if hd.readIndexWait != nil {
+		hd.readIndexWait.close(ErrHandlerRemoved)
+	}
 	if r.opts.Event.OnHandlerRemove != nil {
 		r.opts.Event.OnHandlerRemove(hd.handler)
 	}
@@ -518,3 +707,19 @@ type stepReq struct {
 	msg        replica.Message
 	resultC    chan error
 }
+
+func (r *ReactorSub) clusterKind() trace.ClusterKind {
+	return r.opts.clusterKind()
+}
+
+func (o *Options) clusterKind() trace.ClusterKind {
+	switch o.ReactorType {
+	case ReactorTypeSlot:
+		return trace.ClusterKindSlot
+	case ReactorTypeChannel:
+		return trace.ClusterKindChannel
+	case ReactorTypeConfig:
+		return trace.ClusterKindConfig
+	}
+	return trace.ClusterKindUnknown
+}
@@ -0,0 +1,135 @@
+package reactor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/WuKongIM/WuKongIM/pkg/cluster/replica"
+	"github.com/stretchr/testify/assert"
+)
+
+// 批量提案里有空数据的日志
+func TestProposeEmptyPayload(t *testing.T) {
+	setupTestTrace(t)
+
+	logs := []replica.Log{
+		{Id: 1, Data: []byte("hello")},
+		{Id: 2, Data: []byte{}},
+		{Id: 3, Data: []byte("world")},
+	}
+
+	// 默认拒绝整批提案
+	r := NewReactorSub(0, &Reactor{opts: NewOptions()})
+	_, err := r.proposeAndWait(context.Background(), "test", logs)
+	assert.Equal(t, ErrEmptyPayload, err)
+
+	// 允许空数据的日志（作为无操作的标记日志），整批提案被提交
+	sub, th := newTestCommitReactor(t, WithAllowEmptyPayload(true))
+	defer sub.Stop()
+	results, err := sub.proposeAndWait(context.Background(), "test", logs)
+	assert.NoError(t, err)
+	assert.Len(t, results, len(logs))
+	for i, result := range results {
+		assert.Equal(t, logs[i].Id, result.Id)
+		assert.Equal(t, uint64(i+1), result.Index)
+	}
+	th.mu.Lock()
+	defer th.mu.Unlock()
+	assert.Len(t, th.logs, len(logs))
+	assert.Empty(t, th.logs[1].Data)
+}
+
+// 空闲的领导处理者，速度等级可以被降低
+type testIdleHandler struct {
+	IHandler
+	level replica.SpeedLevel
+}
+
+func (t *testIdleHandler) AppliedIndex() (uint64, error) {
+	return 0, nil
+}
+
+func (t *testIdleHandler) LastLogIndexAndTerm() (uint64, uint32) {
+	return 0, 0
+}
+
+func (t *testIdleHandler) Tick() {
+}
+
+func (t *testIdleHandler) LeaderId() uint64 {
+	return 1
+}
+
+func (t *testIdleHandler) SpeedLevel() replica.SpeedLevel {
+	return t.level
+}
+
+func (t *testIdleHandler) SetSpeedLevel(level replica.SpeedLevel) {
+	t.level = level
+}
+
+// 空闲回收暂停期间，空闲再久的处理者也不会被移除
+func TestIdleSweepPaused(t *testing.T) {
+	removed := 0
+	r := New(NewOptions(WithSubReactorNum(1), WithNodeId(1), WithAutoSlowDownOn(true), WithIdleSweepPaused(true), WithOnHandlerRemove(func(h IHandler) {
+		removed++
+	})))
+	keys := []string{"ch1", "ch2", "ch3"}
+	for _, key := range keys {
+		r.AddHandler(key, &testIdleHandler{level: replica.LevelFast})
+	}
+	sub := r.subReactors[0]
+	assert.True(t, r.IdleSweepPaused())
+
+	for i := 0; i < LevelDestroy*2; i++ {
+		sub.tick()
+	}
+	for _, key := range keys {
+		assert.True(t, r.ExistHandler(key))
+		assert.Equal(t, replica.LevelStop, r.handler(key).speedLevel())
+	}
+	assert.Equal(t, 0, removed)
+
+	// 恢复后空闲的处理者被移除
+	r.ResumeIdleSweep()
+	assert.False(t, r.IdleSweepPaused())
+	sub.tick()
+	for _, key := range keys {
+		assert.False(t, r.ExistHandler(key))
+	}
+	assert.Equal(t, len(keys), removed)
+
+	// 再次暂停后新加入的处理者不会被移除
+	r.PauseIdleSweep()
+	r.AddHandler("ch4", &testIdleHandler{level: replica.LevelFast})
+	for i := 0; i < LevelDestroy*2; i++ {
+		sub.tick()
+	}
+	assert.True(t, r.ExistHandler("ch4"))
+}
+
+// 一直有ready的处理者，每个循环最多处理配置的轮数，剩下的通知下一个循环继续
+func TestMaxReadyRounds(t *testing.T) {
+	sent := 0
+	r := New(NewOptions(WithSubReactorNum(1), WithNodeId(1), WithMaxReadyRounds(3), WithSend(func(m Message) {
+		sent++
+	})))
+	th := &reuseMsgsHandler{
+		nextMsgs: func() []replica.Message {
+			return []replica.Message{{MsgType: replica.MsgPing, To: 2}}
+		},
+	}
+	r.AddHandler("test", th)
+	sub := r.reactorSub("test")
+
+	sub.readyEvents()
+	assert.Equal(t, 3, sent)
+	select {
+	case <-sub.avdanceC:
+	default:
+		t.Fatal("expected advance after max ready rounds")
+	}
+
+	sub.readyEvents()
+	assert.Equal(t, 6, sent)
+}
@@ -0,0 +1,146 @@
+package reactor
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/WuKongIM/WuKongIM/pkg/cluster/replica"
+)
+
+type readIndexResult struct {
+	index uint64
+	err   error
+}
+
+// readIndexWait 等待副本返回线性一致读的结果
+type readIndexWait struct {
+	mu        sync.Mutex
+	seq       uint64
+	waits     map[uint64]chan readIndexResult
+	closedErr error // 处理者移除后不为nil，所有等待和之后添加的等待都以这个错误失败
+}
+
+func newReadIndexWait() *readIndexWait {
+	return &readIndexWait{
+		waits: make(map[uint64]chan readIndexResult),
+	}
+}
+
+// add 添加一个等待，返回请求id
+func (w *readIndexWait) add() (uint64, chan readIndexResult) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	waitC := make(chan readIndexResult, 1)
+	if w.closedErr != nil {
+		waitC <- readIndexResult{err: w.closedErr}
+		return 0, waitC
+	}
+	w.seq++
+	w.waits[w.seq] = waitC
+	return w.seq, waitC
+}
+
+func (w *readIndexWait) done(id uint64, index uint64, reject bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	waitC, ok := w.waits[id]
+	if !ok {
+		return
+	}
+	delete(w.waits, id)
+	if reject {
+		waitC <- readIndexResult{err: ErrNotLeader}
+		return
+	}
+	waitC <- readIndexResult{index: index}
+}
+
+func (w *readIndexWait) remove(id uint64) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	delete(w.waits, id)
+}
+
+// close 所有等待以err失败，之后添加的等待也直接失败
+func (w *readIndexWait) close(err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.closedErr = err
+	for id, waitC := range w.waits {
+		waitC <- readIndexResult{err: err}
+		delete(w.waits, id)
+	}
+}
+
+// readIndexApplyCheckInterval 线性一致读等待本地应用到读下标的检查间隔
+const readIndexApplyCheckInterval = time.Millisecond
+
+// readIndex 发起线性一致读，等待领导确认多数副本仍然认可自己是领导，并且本地已经应用到读下标，返回可以安全读到的日志下标
+func (r *ReactorSub) readIndex(ctx context.Context, handleKey string) (uint64, error) {
+	if r.stopped.Load() {
+		return 0, ErrReactorSubStopped
+	}
+	handler := r.handlers.get(handleKey)
+	if handler == nil {
+		return 0, ErrHandlerNotFound
+	}
+	if !handler.isLeader() {
+		return 0, ErrNotLeader
+	}
+
+	// 处理者移除后会被重置复用，之后都通过rw访问这次读的等待
+	rw := handler.readIndexWait
+	id, waitC := rw.add()
+	defer rw.remove(id)
+
+	select {
+	case r.stepC <- stepReq{
+		handlerKey: handleKey,
+		msg:        replica.Message{MsgType: replica.MsgReadIndex, Index: id},
+	}:
+	case res := <-waitC:
+		return res.index, res.err
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	case <-r.stopper.ShouldStop():
+		return 0, ErrReactorSubStopped
+	}
+
+	var res readIndexResult
+	select {
+	case res = <-waitC:
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	case <-r.stopper.ShouldStop():
+		return 0, ErrReactorSubStopped
+	}
+	if res.err != nil {
+		return 0, res.err
+	}
+	if err := r.waitApplied(ctx, handleKey, handler, res.index); err != nil {
+		return 0, err
+	}
+	return res.index, nil
+}
+
+// waitApplied 等待处理者应用到index，处理者被移除返回ErrHandlerRemoved
+func (r *ReactorSub) waitApplied(ctx context.Context, handleKey string, h *handler, index uint64) error {
+	tk := time.NewTicker(readIndexApplyCheckInterval)
+	defer tk.Stop()
+	for {
+		if r.handlers.get(handleKey) != h { // 处理者移除后会被重置复用
+			return ErrHandlerRemoved
+		}
+		if h.applyLag.appliedIndex.Load() >= index {
+			return nil
+		}
+		select {
+		case <-tk.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-r.stopper.ShouldStop():
+			return ErrReactorSubStopped
+		}
+	}
+}
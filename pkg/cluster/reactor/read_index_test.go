@@ -0,0 +1,182 @@
+package reactor
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/WuKongIM/WuKongIM/pkg/cluster/replica"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/atomic"
+)
+
+// 收到读请求后按配置返回读结果的领导，hold为true时不返回
+type testReadIndexHandler struct {
+	IHandler
+	mu      sync.Mutex
+	msgs    []replica.Message
+	leader  atomic.Uint64
+	reject  bool
+	hold    bool
+	index   uint64
+	applied *uint64 // 已应用的下标，nil表示已经应用到index
+}
+
+func (t *testReadIndexHandler) AppliedIndex() (uint64, error) {
+	if t.applied != nil {
+		return *t.applied, nil
+	}
+	return t.index, nil
+}
+
+func (t *testReadIndexHandler) HasReady() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.msgs) > 0
+}
+
+func (t *testReadIndexHandler) Ready() replica.Ready {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	rd := replica.Ready{Messages: t.msgs}
+	t.msgs = nil
+	return rd
+}
+
+func (t *testReadIndexHandler) Step(m replica.Message) error {
+	if m.MsgType != replica.MsgReadIndex || t.hold {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.msgs = append(t.msgs, replica.Message{MsgType: replica.MsgReadIndexResp, From: 1, To: 1, Index: m.Index, CommittedIndex: t.index, Reject: t.reject})
+	return nil
+}
+
+func (t *testReadIndexHandler) LastLogIndexAndTerm() (uint64, uint32) {
+	return t.index, 1
+}
+
+func (t *testReadIndexHandler) LeaderId() uint64 {
+	return t.leader.Load()
+}
+
+func (t *testReadIndexHandler) Tick() {
+}
+
+func (t *testReadIndexHandler) SpeedLevel() replica.SpeedLevel {
+	return replica.LevelFast
+}
+
+func (t *testReadIndexHandler) SetSpeedLevel(level replica.SpeedLevel) {
+}
+
+func TestReadIndex(t *testing.T) {
+	r := New(NewOptions(WithSubReactorNum(1), WithNodeId(1)))
+	assert.NoError(t, r.Start())
+	defer r.Stop()
+
+	th := &testReadIndexHandler{index: 42}
+	th.leader.Store(1)
+	r.AddHandler("ok", th)
+	index, err := r.ReadIndex(context.Background(), "ok")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(42), index)
+
+	// 确认期间失去领导权
+	rejected := &testReadIndexHandler{index: 42, reject: true}
+	rejected.leader.Store(1)
+	r.AddHandler("reject", rejected)
+	_, err = r.ReadIndex(context.Background(), "reject")
+	assert.Equal(t, ErrNotLeader, err)
+
+	// 不是领导
+	follower := &testReadIndexHandler{}
+	follower.leader.Store(2)
+	r.AddHandler("follower", follower)
+	_, err = r.ReadIndex(context.Background(), "follower")
+	assert.Equal(t, ErrNotLeader, err)
+
+	_, err = r.ReadIndex(context.Background(), "notfound")
+	assert.Equal(t, ErrHandlerNotFound, err)
+
+	// 一直没有确认，按ctx超时
+	hold := &testReadIndexHandler{hold: true}
+	hold.leader.Store(1)
+	r.AddHandler("hold", hold)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+	_, err = r.ReadIndex(ctx, "hold")
+	assert.Equal(t, context.DeadlineExceeded, err)
+	rw := r.handler("hold").readIndexWait
+	rw.mu.Lock()
+	assert.Empty(t, rw.waits)
+	rw.mu.Unlock()
+}
+
+// 本地还没有应用到读下标时等应用追上再返回
+func TestReadIndexWaitApplied(t *testing.T) {
+	r := New(NewOptions(WithSubReactorNum(1), WithNodeId(1)))
+	assert.NoError(t, r.Start())
+	defer r.Stop()
+
+	applied := uint64(40)
+	th := &testReadIndexHandler{index: 42, applied: &applied}
+	th.leader.Store(1)
+	r.AddHandler("test", th)
+
+	resultC := make(chan uint64, 1)
+	go func() {
+		index, err := r.ReadIndex(context.Background(), "test")
+		assert.NoError(t, err)
+		resultC <- index
+	}()
+	select {
+	case <-resultC:
+		t.Fatal("read index returned before applied")
+	case <-time.After(time.Millisecond * 50):
+	}
+
+	r.handler("test").applyLag.didApply(42, 0)
+	select {
+	case index := <-resultC:
+		assert.Equal(t, uint64(42), index)
+	case <-time.After(time.Second):
+		t.Fatal("read index not returned after applied")
+	}
+
+	// 等待应用期间处理者被移除
+	notApplied := uint64(0)
+	removed := &testReadIndexHandler{index: 42, applied: &notApplied}
+	removed.leader.Store(1)
+	r.AddHandler("removed", removed)
+	errC := make(chan error, 1)
+	go func() {
+		_, err := r.ReadIndex(context.Background(), "removed")
+		errC <- err
+	}()
+	time.Sleep(time.Millisecond * 20)
+	r.reactorSub("removed").removeHandler("removed")
+	select {
+	case err := <-errC:
+		assert.Equal(t, ErrHandlerRemoved, err)
+	case <-time.After(time.Second):
+		t.Fatal("read index not failed after handler removed")
+	}
+}
+
+// 处理者移除后，等待中的读和之后的读都直接失败
+func TestReadIndexWaitClose(t *testing.T) {
+	rw := newReadIndexWait()
+	id, waitC := rw.add()
+	assert.Equal(t, uint64(1), id)
+	rw.close(ErrHandlerRemoved)
+	res := <-waitC
+	assert.Equal(t, ErrHandlerRemoved, res.err)
+
+	_, waitC = rw.add()
+	res = <-waitC
+	assert.Equal(t, ErrHandlerRemoved, res.err)
+	assert.Empty(t, rw.waits)
+}
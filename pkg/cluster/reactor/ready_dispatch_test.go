@@ -0,0 +1,144 @@
+package reactor
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/WuKongIM/WuKongIM/pkg/cluster/replica"
+	"github.com/stretchr/testify/assert"
+)
+
+// 跟副本一样复用消息缓存的处理者：Ready返回msgs后只把长度清零，Step追加的消息会覆盖上一次Ready的底层数组
+type reuseMsgsHandler struct {
+	IHandler
+	msgs     []replica.Message
+	onStep   func(m replica.Message) []replica.Message
+	nextMsgs func() []replica.Message
+}
+
+func (t *reuseMsgsHandler) AppliedIndex() (uint64, error) {
+	return 0, nil
+}
+
+func (t *reuseMsgsHandler) HasReady() bool {
+	return len(t.msgs) > 0 || t.nextMsgs != nil
+}
+
+func (t *reuseMsgsHandler) Ready() replica.Ready {
+	if t.nextMsgs != nil {
+		t.msgs = append(t.msgs, t.nextMsgs()...)
+	}
+	rd := replica.Ready{Messages: t.msgs}
+	t.msgs = t.msgs[:0]
+	return rd
+}
+
+func (t *reuseMsgsHandler) Step(m replica.Message) error {
+	if t.onStep != nil {
+		t.msgs = append(t.msgs, t.onStep(m)...)
+	}
+	return nil
+}
+
+func (t *reuseMsgsHandler) LastLogIndexAndTerm() (uint64, uint32) {
+	return 10, 1
+}
+
+// 处理ready时同步Step追加的消息不会覆盖还没有分发的消息
+func TestReadyMessagesOwnership(t *testing.T) {
+	var sent []uint64
+	r := New(NewOptions(WithSubReactorNum(1), WithNodeId(1), WithSend(func(m Message) {
+		sent = append(sent, m.To)
+	})))
+	th := &reuseMsgsHandler{
+		onStep: func(m replica.Message) []replica.Message {
+			return []replica.Message{{MsgType: replica.MsgPing, To: 4}, {MsgType: replica.MsgPing, To: 5}}
+		},
+	}
+	r.AddHandler("test", th)
+	h := r.handler("test")
+	sub := r.reactorSub("test")
+
+	th.msgs = append(th.msgs,
+		replica.Message{MsgType: replica.MsgVoteResp, From: 2, To: 1},
+		replica.Message{MsgType: replica.MsgPing, To: 2},
+		replica.Message{MsgType: replica.MsgPing, To: 3},
+	)
+	assert.True(t, sub.handleReady(h))
+	assert.Equal(t, []uint64{2, 3}, sent)
+
+	// Step追加的消息在下一次ready里发送
+	assert.True(t, sub.handleReady(h))
+	assert.Equal(t, []uint64{2, 3, 4, 5}, sent)
+}
+
+// 异步阶段持有发送的消息时，处理者复用消息缓存不会产生数据竞争（配合-race运行）
+func TestReadyMessagesAsyncHandoff(t *testing.T) {
+	sendC := make(chan Message, 1024)
+	r := New(NewOptions(WithSubReactorNum(1), WithNodeId(1), WithSend(func(m Message) {
+		sendC <- m
+	})))
+	var index uint64
+	th := &reuseMsgsHandler{
+		nextMsgs: func() []replica.Message {
+			index++
+			return []replica.Message{
+				{MsgType: replica.MsgSyncResp, To: 2, Index: index, Logs: []replica.Log{{Index: index, Data: []byte("hello")}}},
+				{MsgType: replica.MsgSyncResp, To: 3, Index: index, Logs: []replica.Log{{Index: index, Data: []byte("world")}}},
+			}
+		},
+	}
+	r.AddHandler("test", th)
+	h := r.handler("test")
+	sub := r.reactorSub("test")
+
+	const count = 1000
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < count*2; i++ {
+			m := <-sendC
+			assert.Equal(t, m.Index, m.Logs[0].Index)
+			assert.Equal(t, m.Index, uint64(i/2+1))
+		}
+	}()
+	for i := 0; i < count; i++ {
+		sub.handleReady(h)
+	}
+	wg.Wait()
+}
+
+// 只发送消息的ready分发不产生分配
+func TestReadyDispatchAllocs(t *testing.T) {
+	r, h, sub := newBenchReadyReactor()
+	allocs := testing.AllocsPerRun(100, func() {
+		sub.handleReady(h)
+	})
+	assert.Equal(t, float64(0), allocs)
+	r.RemoveHandler("test")
+}
+
+func BenchmarkHandleReady(b *testing.B) {
+	_, h, sub := newBenchReadyReactor()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		sub.handleReady(h)
+	}
+}
+
+func newBenchReadyReactor() (*Reactor, *handler, *ReactorSub) {
+	r := New(NewOptions(WithSubReactorNum(1), WithNodeId(1), WithSend(func(m Message) {})))
+	msgs := make([]replica.Message, 0, 8)
+	for i := 0; i < 8; i++ {
+		msgs = append(msgs, replica.Message{MsgType: replica.MsgPing, To: uint64(i + 2)})
+	}
+	th := &reuseMsgsHandler{
+		nextMsgs: func() []replica.Message {
+			return msgs
+		},
+	}
+	r.AddHandler("test", th)
+	return r, r.handler("test"), r.reactorSub("test")
+}
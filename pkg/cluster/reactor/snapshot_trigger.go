@@ -0,0 +1,66 @@
+package reactor
+
+import (
+	"go.uber.org/atomic"
+	"go.uber.org/zap"
+)
+
+// snapshotTrigger 按日志数量触发快照
+// 距离上次快照已应用的日志数量达到阈值时触发，写入多的分区快照更频繁，空闲的分区不会做多余的快照
+type snapshotTrigger struct {
+	lastIndex atomic.Uint64 // 上次快照时已应用的日志下标
+	running   atomic.Bool   // 是否正在做快照
+	started   atomic.Bool   // 是否已经确定起点
+}
+
+// shouldSnapshot 应用日志后判断是否需要做快照，需要时标记为正在做快照
+// prevApplied为这次应用前的已应用下标，第一次判断时以它为起点（重启后不会立马对历史日志做快照）
+func (s *snapshotTrigger) shouldSnapshot(prevApplied, applied uint64, threshold uint64) bool {
+	if threshold == 0 {
+		return false
+	}
+	if s.started.CompareAndSwap(false, true) {
+		s.lastIndex.Store(prevApplied)
+	}
+	lastIndex := s.lastIndex.Load()
+	if applied <= lastIndex || applied-lastIndex < threshold {
+		return false
+	}
+	return s.running.CompareAndSwap(false, true)
+}
+
+func (s *snapshotTrigger) done(appliedIndex uint64, success bool) {
+	if success {
+		s.lastIndex.Store(appliedIndex)
+	}
+	s.running.Store(false)
+}
+
+func (s *snapshotTrigger) reset() {
+	s.lastIndex.Store(0)
+	s.running.Store(false)
+	s.started.Store(false)
+}
+
+// maybeSnapshot 应用日志后检查是否达到快照阈值，达到则异步做快照（不阻塞日志应用）
+func (r *Reactor) maybeSnapshot(h *handler, prevApplied, applied uint64) {
+	onSnapshot := r.opts.Event.OnSnapshot
+	if onSnapshot == nil {
+		return
+	}
+	if !h.snapshot.shouldSnapshot(prevApplied, applied, r.opts.SnapshotLogThreshold) {
+		return
+	}
+	key := h.key
+	err := r.taskPool.Submit(func() {
+		err := onSnapshot(key, applied)
+		if err != nil {
+			r.Warn("snapshot failed", zap.Error(err), zap.String("handler", key), zap.Uint64("appliedIndex", applied))
+		}
+		h.snapshot.done(applied, err == nil)
+	})
+	if err != nil {
+		r.Warn("submit snapshot task failed", zap.Error(err), zap.String("handler", key))
+		h.snapshot.done(applied, false)
+	}
+}
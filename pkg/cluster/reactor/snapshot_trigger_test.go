@@ -0,0 +1,71 @@
+package reactor
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testApplyHandler struct {
+	IHandler
+}
+
+func (t *testApplyHandler) AppliedIndex() (uint64, error) {
+	return 0, nil
+}
+
+func (t *testApplyHandler) LastLogIndexAndTerm() (uint64, uint32) {
+	return 0, 0
+}
+
+func (t *testApplyHandler) ApplyLogs(startIndex, endIndex uint64) (uint64, error) {
+	return endIndex - startIndex, nil
+}
+
+// 写入多的分区按日志数量触发快照，空闲的分区不触发
+func TestSnapshotLogThreshold(t *testing.T) {
+	setupTestTrace(t)
+
+	var mu sync.Mutex
+	snapshots := make(map[string][]uint64)
+	r := New(NewOptions(WithSubReactorNum(1), WithSnapshotLogThreshold(100), WithOnSnapshot(func(handleKey string, appliedIndex uint64) error {
+		mu.Lock()
+		snapshots[handleKey] = append(snapshots[handleKey], appliedIndex)
+		mu.Unlock()
+		return nil
+	})))
+	r.AddHandler("busy", &testApplyHandler{})
+	r.AddHandler("idle", &testApplyHandler{})
+
+	apply := func(key string, batch int, count int) {
+		h := r.handler(key)
+		applied := uint64(0)
+		for i := 0; i < count; i++ {
+			r.processApplyLog(&applyLogReq{h: h, appyingIndex: applied, committedIndex: applied + uint64(batch)})
+			applied += uint64(batch)
+			assert.Eventually(t, func() bool { return !h.snapshot.running.Load() }, time.Second, time.Millisecond)
+		}
+	}
+	apply("busy", 50, 20)
+	apply("idle", 5, 2)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []uint64{100, 200, 300, 400, 500, 600, 700, 800, 900, 1000}, snapshots["busy"])
+	assert.Empty(t, snapshots["idle"])
+}
+
+// 第一次判断以应用前的下标为起点，快照失败时下次继续触发
+func TestSnapshotTriggerShouldSnapshot(t *testing.T) {
+	var s snapshotTrigger
+	assert.False(t, s.shouldSnapshot(1000, 1050, 100)) // 重启后不对历史日志做快照
+	assert.True(t, s.shouldSnapshot(1050, 1100, 100))
+	assert.False(t, s.shouldSnapshot(1100, 1300, 100)) // 正在做快照
+	s.done(1100, false)
+	assert.True(t, s.shouldSnapshot(1100, 1300, 100))
+	s.done(1300, true)
+	assert.False(t, s.shouldSnapshot(1300, 1350, 100))
+	assert.False(t, s.shouldSnapshot(1350, 1500, 0))
+}
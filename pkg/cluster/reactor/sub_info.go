@@ -0,0 +1,24 @@
+package reactor
+
+// SubInfo 处理者所属的reactor sub以及sub当前的负载，用于排查热点sub（问题分区是否和其他热点分区在同一个sub上）
+type SubInfo struct {
+	HandleKey       string
+	SubIndex        int  // 所属sub的下标
+	Exist           bool // 处理者是否在这个sub上
+	HandlerCount    int  // sub上的处理者数量
+	StepQueueLen    int  // sub待处理的step消息数量
+	ProposeQueueLen int  // sub待处理的提案数量（包含高优先级的提案）
+}
+
+// SubInfo 获取处理者所属的sub信息，处理者不存在时返回的是它将会被分配到的sub
+func (r *Reactor) SubInfo(key string) SubInfo {
+	sub := r.reactorSub(key)
+	return SubInfo{
+		HandleKey:       key,
+		SubIndex:        sub.index,
+		Exist:           sub.existHandler(key),
+		HandlerCount:    sub.handlerLen(),
+		StepQueueLen:    len(sub.stepC),
+		ProposeQueueLen: len(sub.proposeC) + len(sub.proposeHighC),
+	}
+}
@@ -0,0 +1,35 @@
+package reactor
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// 查询到的sub和添加处理者时分配的sub一致
+func TestSubInfo(t *testing.T) {
+	r := New(NewOptions(WithSubReactorNum(4)))
+	for i := 0; i < 20; i++ {
+		r.AddHandler(fmt.Sprintf("test-%d", i), &testReadyHandler{})
+	}
+
+	for i := 0; i < 20; i++ {
+		key := fmt.Sprintf("test-%d", i)
+		info := r.SubInfo(key)
+		assert.True(t, info.Exist)
+
+		owner := -1
+		for idx, sub := range r.subReactors {
+			if sub.existHandler(key) {
+				owner = idx
+			}
+		}
+		assert.Equal(t, owner, info.SubIndex)
+		assert.Equal(t, r.subReactors[owner].handlerLen(), info.HandlerCount)
+	}
+
+	// 不存在的处理者
+	info := r.SubInfo("notExist")
+	assert.False(t, info.Exist)
+}
@@ -15,7 +15,20 @@ type proposeWait struct {
 
 	proposeResultMap map[string][]ProposeResult
 	proposeWaitMap   map[string]chan []ProposeResult
+	rejectErrMap     map[string]error // 被拒绝的提案的原因，等待者取走后删除
+	closedErr        error            // 处理者移除后不为nil，所有等待和之后添加的等待都以这个错误失败
 	hasAdd           atomic.Bool
+
+	// 提交通知合并：通知等待者期间新提交的范围先合并到待通知的范围，由正在通知的协程一起处理
+	commitMu     sync.Mutex
+	commitStart  uint64 // 待通知的提交范围[commitStart, commitEnd)，commitEnd为0表示没有待通知的提交
+	commitEnd    uint64
+	notifying    bool               // 是否正在通知等待者
+	submit       func(func()) error // 异步执行提交通知，为nil时在调用didCommit的协程里通知
+	commitPasses atomic.Uint64      // 遍历等待者的次数
+
+	waitersAdd    func(v int64) // 等待者数量变化时调用（监控），为nil时不上报
+	waitGapRecord func(v int64) // 每次提交后还在等待的日志最大下标与已提交下标的差距（监控），为nil时不上报
 }
 
 func newProposeWait(key string) *proposeWait {
@@ -23,6 +36,7 @@ func newProposeWait(key string) *proposeWait {
 		Log:              wklog.NewWKLog(fmt.Sprintf("proposeWait[%s]", key)),
 		proposeWaitMap:   make(map[string]chan []ProposeResult),
 		proposeResultMap: make(map[string][]ProposeResult),
+		rejectErrMap:     make(map[string]error),
 	}
 }
 
@@ -35,6 +49,11 @@ func (m *proposeWait) add(key string, ids []uint64) chan []ProposeResult {
 		m.Panic("addWait ids is empty")
 	}
 	waitC := make(chan []ProposeResult, 1)
+	if m.closedErr != nil { // 处理者已移除，提案不会再被处理
+		m.rejectErrMap[key] = m.closedErr
+		close(waitC)
+		return waitC
+	}
 	items := make([]ProposeResult, len(ids))
 	for i, id := range ids {
 		items[i] = ProposeResult{
@@ -44,6 +63,9 @@ func (m *proposeWait) add(key string, ids []uint64) chan []ProposeResult {
 
 	// m.Debug("addWait", zap.String("key", key), zap.Int("ids", len(ids)))
 
+	if _, ok := m.proposeWaitMap[key]; !ok {
+		m.addWaiters(1)
+	}
 	m.proposeResultMap[key] = items
 	m.proposeWaitMap[key] = waitC
 
@@ -70,12 +92,92 @@ func (m *proposeWait) didPropose(key string, logId uint64, logIndex uint64) {
 	m.mu.Unlock()
 }
 
-// didCommit 提交[startLogIndex, endLogIndex)范围的消息
-func (m *proposeWait) didCommit(startLogIndex uint64, endLogIndex uint64) {
+// didProposeBatch 一批提案按顺序分配连续的下标[firstLogIndex, firstLogIndex+len(ids))，在同一个锁内完成，不会和其他批次交错
+func (m *proposeWait) didProposeBatch(key string, firstLogIndex uint64) {
+	if firstLogIndex == 0 {
+		m.Panic("didProposeBatch firstLogIndex is 0")
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	items := m.proposeResultMap[key]
+	for i := range items {
+		items[i].Index = firstLogIndex + uint64(i)
+	}
+}
+
+// drop 提案没有追加成功，通知等待者（关闭等待的chan）
+func (m *proposeWait) drop(key string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if waitC, ok := m.proposeWaitMap[key]; ok {
+		close(waitC)
+		m.addWaiters(-1)
+	}
+	delete(m.proposeResultMap, key)
+	delete(m.proposeWaitMap, key)
+}
+
+// reject 提案被拒绝，记录原因后通知等待者（关闭等待的chan），等待者通过takeRejectErr获取原因
+func (m *proposeWait) reject(key string, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	waitC, ok := m.proposeWaitMap[key]
+	if !ok {
+		return
+	}
+	m.rejectErrMap[key] = err
+	close(waitC)
+	m.addWaiters(-1)
+	delete(m.proposeResultMap, key)
+	delete(m.proposeWaitMap, key)
+}
+
+// close 处理者移除时让所有等待失败（不能只依赖等待者的超时，否则等待会一直留到超时），之后添加的等待也直接失败
+func (m *proposeWait) close(err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closedErr != nil {
+		return
+	}
+	m.closedErr = err
+	m.rejectAllLocked(err)
+}
 
+// rejectAll 拒绝所有等待中的提案（例如领导放弃了领导权），等待者通过takeRejectErr获取原因
+func (m *proposeWait) rejectAll(err error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
+	m.rejectAllLocked(err)
+}
 
+func (m *proposeWait) rejectAllLocked(err error) {
+	for key, waitC := range m.proposeWaitMap {
+		m.rejectErrMap[key] = err
+		close(waitC)
+	}
+	m.addWaiters(-int64(len(m.proposeWaitMap)))
+	m.proposeResultMap = make(map[string][]ProposeResult)
+	m.proposeWaitMap = make(map[string]chan []ProposeResult)
+}
+
+func (m *proposeWait) isClosed() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.closedErr != nil
+}
+
+// takeRejectErr 获取并删除提案被拒绝的原因，没有被拒绝返回nil
+func (m *proposeWait) takeRejectErr(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	err := m.rejectErrMap[key]
+	delete(m.rejectErrMap, key)
+	return err
+}
+
+// didCommit 提交[startLogIndex, endLogIndex)范围的消息
+// 提交下标快速连续推进时，多次提交合并成一次遍历等待者（提交是连续推进的，合并后的范围内的日志都已提交）
+func (m *proposeWait) didCommit(startLogIndex uint64, endLogIndex uint64) {
 	if startLogIndex == 0 {
 		m.Panic("didCommit startLogIndex is 0")
 	}
@@ -84,7 +186,54 @@ func (m *proposeWait) didCommit(startLogIndex uint64, endLogIndex uint64) {
 		m.Panic("didCommit endLogIndex is 0")
 	}
 
+	m.commitMu.Lock()
+	if m.commitEnd == 0 {
+		m.commitStart, m.commitEnd = startLogIndex, endLogIndex
+	} else {
+		m.commitStart = min(m.commitStart, startLogIndex)
+		m.commitEnd = max(m.commitEnd, endLogIndex)
+	}
+	if m.notifying { // 正在通知的协程会处理合并后的范围
+		m.commitMu.Unlock()
+		return
+	}
+	m.notifying = true
+	m.commitMu.Unlock()
+
+	if m.submit != nil {
+		if err := m.submit(m.notifyCommitted); err == nil {
+			return
+		}
+	}
+	m.notifyCommitted()
+}
+
+// notifyCommitted 通知待通知范围内的等待者，直到没有新的提交
+func (m *proposeWait) notifyCommitted() {
+	for {
+		m.commitMu.Lock()
+		startLogIndex, endLogIndex := m.commitStart, m.commitEnd
+		m.commitStart, m.commitEnd = 0, 0
+		if endLogIndex == 0 {
+			m.notifying = false
+			m.commitMu.Unlock()
+			return
+		}
+		m.commitMu.Unlock()
+
+		m.commit(startLogIndex, endLogIndex)
+	}
+}
+
+// commit 遍历等待者，标记[startLogIndex, endLogIndex)范围的日志已提交，全部提交的等待者返回结果
+func (m *proposeWait) commit(startLogIndex uint64, endLogIndex uint64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.commitPasses.Inc()
+
 	keysToDelete := make([]string, 0, 500)
+	var maxWaitIndex uint64 // 还在等待的日志的最大下标
 	for key, items := range m.proposeResultMap {
 		shouldCommit := true
 		for i, item := range items {
@@ -93,12 +242,13 @@ func (m *proposeWait) didCommit(startLogIndex uint64, endLogIndex uint64) {
 			}
 			if !items[i].committed {
 				shouldCommit = false
+				maxWaitIndex = max(maxWaitIndex, item.Index)
 			}
 		}
 		if shouldCommit {
 			m.Debug("didCommit", zap.String("key", key), zap.Uint64("startLogIndex", startLogIndex), zap.Uint64("endLogIndex", endLogIndex))
 			waitC := m.proposeWaitMap[key]
-			waitC <- items
+			waitC <- items // waitC有一个缓冲并且只发送一次，等待者处理再慢也不会阻塞通知
 			close(waitC)
 			keysToDelete = append(keysToDelete, key)
 
@@ -108,14 +258,39 @@ func (m *proposeWait) didCommit(startLogIndex uint64, endLogIndex uint64) {
 		delete(m.proposeResultMap, key)
 		delete(m.proposeWaitMap, key)
 	}
+	m.addWaiters(-int64(len(keysToDelete)))
+	if m.waitGapRecord != nil {
+		var gap uint64
+		if maxWaitIndex >= endLogIndex {
+			gap = maxWaitIndex - (endLogIndex - 1)
+		}
+		m.waitGapRecord(int64(gap))
+	}
 
 }
 
 func (m *proposeWait) remove(key string) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
+	if _, ok := m.proposeWaitMap[key]; ok { // 等待超时或被取消
+		m.addWaiters(-1)
+	}
 	delete(m.proposeResultMap, key)
 	delete(m.proposeWaitMap, key)
+	delete(m.rejectErrMap, key)
+}
+
+func (m *proposeWait) addWaiters(v int64) {
+	if v != 0 && m.waitersAdd != nil {
+		m.waitersAdd(v)
+	}
+}
+
+// pending 等待中的提案数量
+func (m *proposeWait) pending() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return len(m.proposeWaitMap)
 }
 
 func (m *proposeWait) exist(key string) bool {
@@ -3,6 +3,7 @@ package reactor
 import (
 	"strconv"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -41,6 +42,103 @@ func TestMessageWait(t *testing.T) {
 	}
 }
 
+// 快速连续的提交合并成一次遍历等待者，所有已提交的等待者都会收到通知
+func TestProposeWaitCommitCoalesced(t *testing.T) {
+	waiterCount := 1000
+	m := newProposeWait("test")
+	gate := make(chan struct{})
+	m.submit = func(f func()) error {
+		go func() {
+			<-gate // 模拟通知还没开始时又有新的提交
+			f()
+		}()
+		return nil
+	}
+	waitCs := make([]chan []ProposeResult, 0, waiterCount)
+	for i := 1; i <= waiterCount; i++ {
+		key := strconv.Itoa(i)
+		waitCs = append(waitCs, m.add(key, []uint64{uint64(i)}))
+		m.didPropose(key, uint64(i), uint64(i))
+	}
+	for i := 1; i <= waiterCount; i++ {
+		m.didCommit(uint64(i), uint64(i+1))
+	}
+	close(gate)
+
+	for i, waitC := range waitCs {
+		select {
+		case items := <-waitC:
+			assert.Equal(t, uint64(i+1), items[0].Index)
+		case <-time.After(time.Second):
+			t.Fatalf("waiter %d not signaled", i+1)
+		}
+	}
+	assert.Equal(t, uint64(1), m.commitPasses.Load())
+}
+
+// 等待者数量在加入时增加，提交、失败、拒绝、超时移除后减少；提交后上报还在等待的最大下标差距
+func TestProposeWaitWaitersMetrics(t *testing.T) {
+	m := newProposeWait("test")
+	var waiters int64
+	var gaps []int64
+	m.waitersAdd = func(v int64) { waiters += v }
+	m.waitGapRecord = func(v int64) { gaps = append(gaps, v) }
+
+	m.add("a", []uint64{1})
+	m.didPropose("a", 1, 1)
+	m.add("b", []uint64{2, 3})
+	m.didProposeBatch("b", 2)
+	m.add("c", []uint64{4})
+	m.add("d", []uint64{5})
+	m.add("e", []uint64{6})
+	assert.Equal(t, int64(5), waiters)
+
+	m.didCommit(1, 3) // a提交，b还在等待下标3
+	assert.Equal(t, int64(4), waiters)
+	assert.Equal(t, []int64{1}, gaps)
+
+	m.drop("c")
+	m.reject("d", ErrNotLeader)
+	m.remove("e") // 等待超时
+	m.remove("e") // 重复移除不会重复减少
+	assert.Equal(t, int64(1), waiters)
+
+	m.rejectAll(ErrNotLeader)
+	assert.Equal(t, int64(0), waiters)
+}
+
+// 大量等待者、提交下标逐条推进时唤醒等待者的开销（perCommit为每次提交都遍历等待者）
+func BenchmarkProposeWaitCommit(b *testing.B) {
+	waiterCount := 1000
+	run := func(b *testing.B, commit func(m *proposeWait, start, end uint64)) {
+		for n := 0; n < b.N; n++ {
+			m := newProposeWait("test")
+			m.submit = func(f func()) error {
+				go f()
+				return nil
+			}
+			waitCs := make([]chan []ProposeResult, 0, waiterCount)
+			for i := 1; i <= waiterCount; i++ {
+				key := strconv.Itoa(i)
+				waitCs = append(waitCs, m.add(key, []uint64{uint64(i)}))
+				m.didPropose(key, uint64(i), uint64(i))
+			}
+			for i := 1; i <= waiterCount; i++ {
+				commit(m, uint64(i), uint64(i+1))
+			}
+			for _, waitC := range waitCs {
+				<-waitC
+			}
+		}
+	}
+	b.Run("perCommit", func(b *testing.B) {
+		run(b, func(m *proposeWait, start, end uint64) { m.commit(start, end) })
+	})
+	b.Run("coalesced", func(b *testing.B) {
+		run(b, func(m *proposeWait, start, end uint64) { m.didCommit(start, end) })
+	})
+}
+
 func BenchmarkMessageWait(b *testing.B) {
 	messageIds := make([]uint64, 0)
 	m := newProposeWait("test")
@@ -67,3 +165,32 @@ func BenchmarkMessageWait(b *testing.B) {
 	// }
 
 }
+
+// 结果的消费者一直不读取，通知也不会阻塞提交
+func TestProposeWaitSlowConsumer(t *testing.T) {
+	m := newProposeWait("test")
+	waitCs := make([]chan []ProposeResult, 0, 100)
+	for i := 1; i <= 100; i++ {
+		key := strconv.Itoa(i)
+		waitCs = append(waitCs, m.add(key, []uint64{uint64(i)}))
+		m.didProposeBatch(key, uint64(i))
+	}
+
+	done := make(chan struct{})
+	go func() {
+		for i := 1; i <= 100; i++ {
+			m.didCommit(uint64(i), uint64(i+1))
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("didCommit blocked by slow consumer")
+	}
+
+	for i, waitC := range waitCs {
+		items := <-waitC
+		assert.Equal(t, uint64(i+1), items[0].Index)
+	}
+}
@@ -0,0 +1,90 @@
+package replica
+
+import "sort"
+
+// DebugState 副本内部状态的快照，用于排查共识卡住的问题（只能在驱动副本的协程里获取，或者和驱动副本互斥）
+type DebugState struct {
+	NodeId  uint64 `json:"node_id"`
+	Status  Status `json:"status"`   // 副本状态（0.未初始化 1.初始化中 2.日志冲突检查 3.准备就绪）
+	Role    string `json:"role"`     // 副本角色
+	Term    uint32 `json:"term"`     // 当前任期
+	Leader  uint64 `json:"leader"`   // 领导id
+	VoteFor uint64 `json:"vote_for"` // 投票给谁
+
+	ConfigVersion uint64   `json:"config_version"` // 配置版本
+	Replicas      []uint64 `json:"replicas"`       // 副本集合（不包含本节点）
+	Learners      []uint64 `json:"learners"`       // 学习者集合
+
+	LastLogIndex   uint64 `json:"last_log_index"`  // 最后一条日志下标
+	StoragedIndex  uint64 `json:"storaged_index"`  // 已存储的日志下标
+	CommittedIndex uint64 `json:"committed_index"` // 已提交的日志下标
+	ApplyingIndex  uint64 `json:"applying_index"`  // 正在应用的日志下标
+	AppliedIndex   uint64 `json:"applied_index"`   // 已应用的日志下标
+	Storaging      bool   `json:"storaging"`       // 是否正在存储日志
+	Applying       bool   `json:"applying"`        // 是否正在应用日志
+	Syncing        bool   `json:"syncing"`         // 是否正在同步日志（追随者）
+	NextIndex      uint64 `json:"next_index"`      // 下次追加（或同步）的日志下标
+	StopPropose    bool   `json:"stop_propose"`    // 是否停止了提案
+	RoleTransiting bool   `json:"role_transiting"` // 是否角色转换中
+
+	ElectionElapsed           int `json:"election_elapsed"`            // 选举计时器
+	RandomizedElectionTimeout int `json:"randomized_election_timeout"` // 本轮的选举超时tick数
+	HeartbeatElapsed          int `json:"heartbeat_elapsed"`           // 心跳计时器
+
+	SpeedLevel string `json:"speed_level"` // 速度等级
+
+	Peers []PeerDebugState `json:"peers"` // 其他副本的同步进度（领导才有）
+}
+
+// PeerDebugState 领导记录的某个副本的同步进度
+type PeerDebugState struct {
+	NodeId     uint64 `json:"node_id"`
+	MatchIndex uint64 `json:"match_index"` // 副本已经有的最后一条日志下标
+	NextIndex  uint64 `json:"next_index"`  // 副本下次来同步的日志下标
+	SyncTick   int    `json:"sync_tick"`   // 距离副本上次来同步的tick数
+}
+
+// DebugState 获取副本内部状态的快照
+func (r *Replica) DebugState() DebugState {
+	st := DebugState{
+		NodeId:                    r.nodeId,
+		Status:                    r.status,
+		Role:                      r.role.String(),
+		Term:                      r.term,
+		Leader:                    r.leader,
+		VoteFor:                   r.voteFor,
+		ConfigVersion:             r.cfg.Version,
+		Replicas:                  append([]uint64(nil), r.replicas...),
+		Learners:                  append([]uint64(nil), r.cfg.Learners...),
+		LastLogIndex:              r.replicaLog.lastLogIndex,
+		StoragedIndex:             r.replicaLog.storagedIndex,
+		CommittedIndex:            r.replicaLog.committedIndex,
+		ApplyingIndex:             r.replicaLog.applyingIndex,
+		AppliedIndex:              r.replicaLog.appliedIndex,
+		Storaging:                 r.replicaLog.storaging,
+		Applying:                  r.replicaLog.applying,
+		Syncing:                   r.syncing,
+		NextIndex:                 r.replicaLog.lastLogIndex + 1,
+		StopPropose:               r.stopPropose,
+		RoleTransiting:            r.isRoleTransitioning,
+		ElectionElapsed:           r.electionElapsed,
+		RandomizedElectionTimeout: r.randomizedElectionTimeout,
+		HeartbeatElapsed:          r.heartbeatElapsed,
+		SpeedLevel:                r.speedLevel.String(),
+	}
+	for nodeId, syncInfo := range r.lastSyncInfoMap {
+		peer := PeerDebugState{
+			NodeId:    nodeId,
+			NextIndex: syncInfo.LastSyncIndex,
+			SyncTick:  syncInfo.SyncTick,
+		}
+		if syncInfo.LastSyncIndex > 0 {
+			peer.MatchIndex = syncInfo.LastSyncIndex - 1
+		}
+		st.Peers = append(st.Peers, peer)
+	}
+	sort.Slice(st.Peers, func(i, j int) bool {
+		return st.Peers[i].NodeId < st.Peers[j].NodeId
+	})
+	return st
+}
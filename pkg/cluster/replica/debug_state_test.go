@@ -0,0 +1,56 @@
+package replica
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDebugState(t *testing.T) {
+	rc := New(1, WithElectionIntervalTick(10))
+	rc.status = StatusReady
+	rc.role = RoleLeader
+	rc.term = 3
+	rc.leader = 1
+	rc.voteFor = 1
+	rc.replicas = []uint64{2, 3}
+	rc.cfg = Config{Version: 5, Learners: []uint64{4}}
+	rc.replicaLog.lastLogIndex = 10
+	rc.replicaLog.storagedIndex = 10
+	rc.replicaLog.committedIndex = 8
+	rc.replicaLog.applyingIndex = 8
+	rc.replicaLog.appliedIndex = 7
+	rc.replicaLog.applying = true
+	rc.electionElapsed = 4
+	rc.randomizedElectionTimeout = 12
+	rc.lastSyncInfoMap[3] = &SyncInfo{LastSyncIndex: 6, SyncTick: 5}
+	rc.lastSyncInfoMap[2] = &SyncInfo{LastSyncIndex: 11, SyncTick: 1}
+	rc.lastSyncInfoMap[4] = &SyncInfo{}
+
+	st := rc.DebugState()
+	assert.Equal(t, uint64(1), st.NodeId)
+	assert.Equal(t, StatusReady, st.Status)
+	assert.Equal(t, RoleLeader.String(), st.Role)
+	assert.Equal(t, uint32(3), st.Term)
+	assert.Equal(t, uint64(1), st.Leader)
+	assert.Equal(t, uint64(1), st.VoteFor)
+	assert.Equal(t, uint64(5), st.ConfigVersion)
+	assert.Equal(t, []uint64{2, 3}, st.Replicas)
+	assert.Equal(t, []uint64{4}, st.Learners)
+	assert.Equal(t, uint64(10), st.LastLogIndex)
+	assert.Equal(t, uint64(11), st.NextIndex)
+	assert.Equal(t, uint64(8), st.CommittedIndex)
+	assert.Equal(t, uint64(7), st.AppliedIndex)
+	assert.True(t, st.Applying)
+	assert.Equal(t, 4, st.ElectionElapsed)
+	assert.Equal(t, 12, st.RandomizedElectionTimeout)
+	assert.Equal(t, []PeerDebugState{
+		{NodeId: 2, MatchIndex: 10, NextIndex: 11, SyncTick: 1},
+		{NodeId: 3, MatchIndex: 5, NextIndex: 6, SyncTick: 5},
+		{NodeId: 4},
+	}, st.Peers)
+
+	// 快照和副本的状态互不影响
+	st.Replicas[0] = 100
+	assert.Equal(t, uint64(2), rc.replicas[0])
+}
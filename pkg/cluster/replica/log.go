@@ -23,8 +23,9 @@ type replicaLog struct {
 
 	appliedIndex uint64 // 已应用的日志下标
 
-	storaging bool // 是否正在追加日志
-	applying  bool // 是否正在应用日志
+	storaging          bool // 是否正在追加日志
+	applying           bool // 是否正在应用日志
+	snapshotInstalling bool // 是否正在安装快照（安装期间不存储也不应用日志，快照的日志由安装者写入存储）
 }
 
 func newReplicaLog(opts *Options) *replicaLog {
@@ -91,7 +92,7 @@ func (r *replicaLog) storagedTo(index uint64) {
 
 // 是否有需要存储的日志
 func (r *replicaLog) hasStorage() bool {
-	if r.storaging {
+	if r.storaging || r.snapshotInstalling {
 		return false
 	}
 	return r.storagingIndex < r.lastLogIndex
@@ -108,13 +109,18 @@ func (r *replicaLog) nextStorageLogs() []Log {
 
 // 是否有需要应用的日志
 func (r *replicaLog) hasApply() bool {
-	if r.applying {
+	if r.applying || r.snapshotInstalling {
 		return false
 	}
 	i := min(r.storagedIndex, r.committedIndex)
 	return r.applyingIndex < i
 }
 
+// 已应用下标是否超过了已提交下标（正常情况下不可能出现）
+func (r *replicaLog) hasApplyAnomaly() bool {
+	return !r.applying && r.appliedIndex > r.committedIndex
+}
+
 func (r *replicaLog) committedTo(index uint64) {
 	if index < r.committedIndex {
 		r.Panic("commit index less than committed index", zap.Uint64("commitIndex", index), zap.Uint64("committedIndex", r.committedIndex))
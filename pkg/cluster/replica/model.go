@@ -50,6 +50,10 @@ const (
 	MsgSpeedLevelSet            // 设置速度
 	MsgSpeedLevelChange         // 速度变更
 	MsgChangeRole               // 变更角色
+	MsgReadIndex                // 线性一致读请求（本地，Index为请求id）
+	MsgReadIndexResp            // 线性一致读响应（本地，Index为请求id，CommittedIndex为可以读到的下标，Reject表示失败）
+	MsgReadIndexCheck           // 领导确认自己仍是领导（领导，Index为确认序号）
+	MsgReadIndexCheckResp       // 确认响应（追随者，Index为收到的确认序号）
 	MsgMaxValue
 )
 
@@ -119,6 +123,14 @@ func (m MsgType) String() string {
 		return "MsgChangeRole"
 	case MsgFollowerToLeader:
 		return "MsgFollowerToLeader"
+	case MsgReadIndex:
+		return "MsgReadIndex"
+	case MsgReadIndexResp:
+		return "MsgReadIndexResp"
+	case MsgReadIndexCheck:
+		return "MsgReadIndexCheck"
+	case MsgReadIndexCheckResp:
+		return "MsgReadIndexCheckResp"
 	default:
 		return fmt.Sprintf("MsgUnkown[%d]", m)
 	}
@@ -297,7 +309,8 @@ type Log struct {
 	Data  []byte // 日志数据
 
 	// 不参与编码
-	Time time.Time // 日志时间
+	Time   time.Time         // 日志时间
+	Values map[string]string // 提案元数据（见reactor.WithProposeContextValues），只在领导节点应用日志时有值
 }
 
 func (l *Log) Marshal() ([]byte, error) {
@@ -393,7 +406,9 @@ func IsEmptyHardState(hs HardState) bool {
 
 type Ready struct {
 	HardState HardState
-	Messages  []Message
+	// Messages 副本的消息缓存，下一次Step或Ready时会复用底层数组，只在调用方处理本次Ready期间有效，
+	// 需要跨阶段（异步）使用的消息必须按值拷贝，不能持有切片本身
+	Messages []Message
 }
 
 func IsEmptyReady(rd Ready) bool {
@@ -580,9 +595,14 @@ var (
 	ErrProposalDropped              = errors.New("replica proposal dropped")
 	ErrLeaderTermStartIndexNotFound = errors.New("leader term start index not found")
 	ErrCompacted                    = errors.New("log compacted")
+	ErrNotLeader                    = errors.New("replica not leader")
+	ErrTermMismatch                 = errors.New("term mismatch")
+	ErrIsLeader                     = errors.New("replica is leader")
+	ErrReplicaBusy                  = errors.New("replica is storing or applying logs")
 )
 
 type SyncInfo struct {
 	LastSyncIndex uint64 //最后一次来同步日志的下标（最新日志 + 1）
 	SyncTick      int    // 同步计时器
+	ReqIndex      uint64 // 最近一次同步请求的下标（学习者也记录），异步获取的日志返回时裁剪掉副本已经有的日志
 }
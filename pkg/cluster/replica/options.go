@@ -15,6 +15,15 @@ type Options struct {
 	HeartbeatIntervalTick int  // 心跳间隔tick次数, 就是tick触发几次算一次心跳，一般为1 一次tick算一次心跳
 	SyncIntervalTick      int  // 同步间隔tick次数, 超过此tick数则发起同步
 
+	// ElectionGraceTick 跟随者与领导失联超过选举超时后，再等待的tick次数，期间收到领导的消息（领导短暂停顿后恢复，比如GC、磁盘卡顿）则不发起选举，
+	// 只在失联前有领导时生效，每次失联最多等待一次，宽限结束还没恢复就正常选举，0表示不等待
+	ElectionGraceTick int
+
+	// IdleHeartbeatTick 领导超过这个tick数没有收到提案认为副本空闲，空闲时心跳间隔放大IdleHeartbeatMultiple倍，减少大量空闲副本的心跳流量，0表示不调整
+	IdleHeartbeatTick int
+	// IdleHeartbeatMultiple 空闲时心跳间隔的倍数，开启选举时放大后的间隔不超过选举间隔的一半（避免追随者误以为领导失联）
+	IdleHeartbeatMultiple int
+
 	MaxUncommittedLogSize      uint64  // 最大未提交的日志大小
 	SyncLimitSize              uint64  // 每次同步日志数据的最大大小（过小影响吞吐量，过大导致消息阻塞，默认为10M）
 	AckMode                    AckMode // AckMode
@@ -28,6 +37,15 @@ type Options struct {
 	RequestTimeoutTick int // 请求超时tick数
 
 	OnConfigChange func(oldCfg, newCfg Config) // 配置变更回调
+
+	// CandidateHealthy 候选人是否健康（比如最近心跳是否成功），返回false的候选人会被拒绝投票，避免选出一个不稳定（频繁掉线）的节点当领导，nil表示不检查
+	// 日志比本地旧的候选人无论是否健康都会被拒绝
+	CandidateHealthy func(nodeId uint64) bool
+	// MaxUnhealthyVoteRejects 没有领导时，因候选人不健康最多连续拒绝投票的次数，超过后不再检查健康（只检查日志），避免只剩不健康的节点能当选时一直选不出领导
+	MaxUnhealthyVoteRejects int
+
+	// ElectionPaused 返回true时不发起新的选举（例如网络维护期间），已有的领导继续服务，恢复后超时的副本立即发起选举，nil表示不暂停
+	ElectionPaused func() bool
 }
 
 func NewOptions() *Options {
@@ -45,6 +63,8 @@ func NewOptions() *Options {
 		FollowerToLeaderMinLogGap:  100,
 		LearnerToTimeoutTick:       10,
 		RequestTimeoutTick:         10,
+		MaxUnhealthyVoteRejects:    3,
+		IdleHeartbeatMultiple:      4,
 	}
 }
 
@@ -81,6 +101,27 @@ func WithElectionIntervalTick(tick int) Option {
 	}
 }
 
+func WithElectionGraceTick(tick int) Option {
+	return func(o *Options) {
+		o.ElectionGraceTick = tick
+	}
+}
+
+func WithElectionPaused(f func() bool) Option {
+	return func(o *Options) {
+		o.ElectionPaused = f
+	}
+}
+
+func WithIdleHeartbeat(idleTick int, multiple int) Option {
+	return func(o *Options) {
+		o.IdleHeartbeatTick = idleTick
+		if multiple > 0 {
+			o.IdleHeartbeatMultiple = multiple
+		}
+	}
+}
+
 func WithHeartbeatIntervalTick(tick int) Option {
 	return func(o *Options) {
 		o.HeartbeatIntervalTick = tick
@@ -182,3 +223,16 @@ func WithOnConfigChange(f func(oldCfg, newCfg Config)) Option {
 		o.OnConfigChange = f
 	}
 }
+
+// WithCandidateHealthy 设置候选人健康检查，投票时拒绝不健康的候选人
+func WithCandidateHealthy(f func(nodeId uint64) bool) Option {
+	return func(o *Options) {
+		o.CandidateHealthy = f
+	}
+}
+
+func WithMaxUnhealthyVoteRejects(count int) Option {
+	return func(o *Options) {
+		o.MaxUnhealthyVoteRejects = count
+	}
+}
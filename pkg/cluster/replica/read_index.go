@@ -0,0 +1,150 @@
+package replica
+
+import (
+	"go.uber.org/zap"
+)
+
+// readIndexStatus 等待多数副本确认的读请求
+type readIndexStatus struct {
+	id    uint64 // 请求id（MsgReadIndex的Index）
+	index uint64 // 确认后可以读到的下标
+	seq   uint64 // 请求对应的确认序号，副本确认了不小于这个序号的确认请求才算确认
+	tick  int    // 等待的tick数
+
+	confirmed bool // 多数副本是否已经确认
+}
+
+// stepReadIndex 处理线性一致读请求：领导记下当前的提交下标，向副本发起确认，多数副本确认本节点仍是领导并且这个下标已经提交后返回这个下标
+// 不是领导时直接返回失败（Reject）
+func (r *Replica) stepReadIndex(m Message) {
+	if !r.isLeader() {
+		r.send(r.newMsgReadIndexResp(m.Index, 0, true))
+		return
+	}
+	// 刚成为领导时提交下标可能还没追上之前任期已提交的日志，读到的下标不能小于成为领导时的最后一条日志下标，
+	// 这个下标本任期可能还没有提交，要等领导提交到这个下标（提交了本任期的日志）后才返回
+	index := max(r.replicaLog.committedIndex, r.readIndexMinIndex)
+	r.readIndexSeq++
+	r.readIndexQueue = append(r.readIndexQueue, readIndexStatus{
+		id:    m.Index,
+		index: index,
+		seq:   r.readIndexSeq,
+	})
+	if r.quorum() <= 1 {
+		r.readIndexQueue[len(r.readIndexQueue)-1].confirmed = true
+		r.updateLeaderCommittedIndex()
+		r.respondReadIndex()
+		return
+	}
+	for _, replicaId := range r.replicas {
+		if replicaId == r.nodeId {
+			continue
+		}
+		r.send(r.newMsgReadIndexCheck(replicaId, r.readIndexSeq))
+	}
+}
+
+// stepReadIndexCheckResp 副本确认，多数副本确认了的读请求返回
+func (r *Replica) stepReadIndexCheckResp(m Message) {
+	if !r.isReplica(m.From) || r.isLearner(m.From) {
+		return
+	}
+	if r.readIndexAcks == nil {
+		r.readIndexAcks = make(map[uint64]uint64)
+	}
+	if m.Index > r.readIndexAcks[m.From] {
+		r.readIndexAcks[m.From] = m.Index
+	}
+
+	// 确认序号是递增的，确认了后面的请求也就确认了前面的请求
+	for i, rs := range r.readIndexQueue {
+		if rs.confirmed {
+			continue
+		}
+		count := 1 // 本节点
+		for _, replicaId := range r.replicas {
+			if replicaId != r.nodeId && r.readIndexAcks[replicaId] >= rs.seq {
+				count++
+			}
+		}
+		if count < r.quorum() {
+			break
+		}
+		r.readIndexQueue[i].confirmed = true
+	}
+	r.respondReadIndex()
+}
+
+// respondReadIndex 多数副本已确认并且读下标已经提交的读请求返回（按顺序返回，前面的请求没返回后面的也不返回）
+func (r *Replica) respondReadIndex() {
+	responded := 0
+	for _, rs := range r.readIndexQueue {
+		if !rs.confirmed || rs.index > r.replicaLog.committedIndex {
+			break
+		}
+		r.send(r.newMsgReadIndexResp(rs.id, rs.index, false))
+		responded++
+	}
+	if responded > 0 {
+		r.readIndexQueue = append(r.readIndexQueue[:0], r.readIndexQueue[responded:]...)
+	}
+}
+
+// tickReadIndex 超过选举间隔还没有被多数副本确认（或读下标还没有提交）的读请求返回失败（例如和多数副本断开了），避免一直占用
+func (r *Replica) tickReadIndex() {
+	expired := 0
+	for i := range r.readIndexQueue {
+		r.readIndexQueue[i].tick++
+		if r.readIndexQueue[i].tick >= r.opts.ElectionIntervalTick {
+			expired = i + 1
+		}
+	}
+	if expired == 0 {
+		return
+	}
+	r.Warn("read index not confirmed by quorum", zap.Int("count", expired), zap.Uint32("term", r.term))
+	for _, rs := range r.readIndexQueue[:expired] {
+		r.send(r.newMsgReadIndexResp(rs.id, 0, true))
+	}
+	r.readIndexQueue = append(r.readIndexQueue[:0], r.readIndexQueue[expired:]...)
+}
+
+// rejectReadIndex 所有等待确认的读请求返回失败
+func (r *Replica) rejectReadIndex() {
+	for _, rs := range r.readIndexQueue {
+		r.send(r.newMsgReadIndexResp(rs.id, 0, true))
+	}
+	r.readIndexQueue = r.readIndexQueue[:0]
+	clear(r.readIndexAcks)
+}
+
+func (r *Replica) newMsgReadIndexResp(id uint64, index uint64, reject bool) Message {
+	return Message{
+		MsgType:        MsgReadIndexResp,
+		From:           r.nodeId,
+		To:             r.nodeId,
+		Index:          id,
+		CommittedIndex: index,
+		Reject:         reject,
+	}
+}
+
+func (r *Replica) newMsgReadIndexCheck(to uint64, seq uint64) Message {
+	return Message{
+		MsgType: MsgReadIndexCheck,
+		From:    r.nodeId,
+		To:      to,
+		Term:    r.term,
+		Index:   seq,
+	}
+}
+
+func (r *Replica) newMsgReadIndexCheckResp(to uint64, seq uint64) Message {
+	return Message{
+		MsgType: MsgReadIndexCheckResp,
+		From:    r.nodeId,
+		To:      to,
+		Term:    r.term,
+		Index:   seq,
+	}
+}
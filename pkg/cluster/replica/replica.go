@@ -44,11 +44,18 @@ type Replica struct {
 	// -------------------- election --------------------
 	electionElapsed           int // 选举计时器
 	heartbeatElapsed          int
+	proposeIdleTick           int // 领导距离上次提案的tick数
 	randomizedElectionTimeout int // 随机选举超时时间
 	tickFnc                   func()
 	voteFor                   uint64          // 投票给谁
 	votes                     map[uint64]bool // 投票记录
+	unhealthyVoteRejects      int             // 没有领导期间因候选人不健康连续拒绝投票的次数
 
+	// -------------------- read index --------------------
+	readIndexQueue    []readIndexStatus // 等待多数副本确认的读请求（按确认序号递增）
+	readIndexSeq      uint64            // 最近一次发出的确认序号
+	readIndexAcks     map[uint64]uint64 // 副本确认过的最大序号
+	readIndexMinIndex uint64            // 成为领导时的最后一条日志下标，之前任期提交的日志不会超过这个下标
 }
 
 func New(nodeId uint64, optList ...Option) *Replica {
@@ -104,7 +111,7 @@ func (r *Replica) HasReady() bool {
 		return true
 	}
 
-	if r.replicaLog.hasApply() {
+	if r.replicaLog.hasApply() || r.replicaLog.hasApplyAnomaly() {
 		return true
 	}
 
@@ -181,6 +188,11 @@ func (r *Replica) Ready() Ready {
 		newCommittedIndex := min(r.replicaLog.storagedIndex, r.replicaLog.committedIndex)
 		r.msgs = append(r.msgs, r.newApplyLogReqMsg(r.replicaLog.applyingIndex, r.replicaLog.appliedIndex, newCommittedIndex))
 		r.replicaLog.applying = true
+	} else if r.replicaLog.hasApplyAnomaly() {
+		// 已应用下标超过已提交下标（存储损坏或bug），交给上层处理，之后不再应用日志
+		r.Error("applied index is ahead of committed index", zap.Uint64("appliedIndex", r.replicaLog.appliedIndex), zap.Uint64("committedIndex", r.replicaLog.committedIndex))
+		r.msgs = append(r.msgs, r.newApplyLogReqMsg(r.replicaLog.applyingIndex, r.replicaLog.appliedIndex, r.replicaLog.committedIndex))
+		r.replicaLog.applying = true
 	}
 
 	rd.Messages = r.msgs
@@ -219,10 +231,37 @@ func (r *Replica) LastLogIndex() uint64 {
 	return r.replicaLog.lastLogIndex
 }
 
+// CommittedIndex 已提交的日志下标
+func (r *Replica) CommittedIndex() uint64 {
+	return r.replicaLog.committedIndex
+}
+
+// LeaderLastLogIndex 领导的最新日志下标，不是领导时返回ErrNotLeader（追随者的日志可能是落后的，需要以领导为准的调用方使用此方法）
+func (r *Replica) LeaderLastLogIndex() (uint64, error) {
+	if !r.isLeader() {
+		return 0, ErrNotLeader
+	}
+	return r.replicaLog.lastLogIndex, nil
+}
+
 func (r *Replica) Term() uint32 {
 	return r.term
 }
 
+// StepDown 领导放弃领导权（不转移给其他副本），成为当前任期没有领导的追随者，等待新的配置选出领导
+// term必须是当前任期，避免过期的请求让之后任期的领导放弃领导权
+func (r *Replica) StepDown(term uint32) error {
+	if !r.isLeader() {
+		return ErrNotLeader
+	}
+	if term != r.term {
+		return ErrTermMismatch
+	}
+	r.Info("step down", zap.Uint32("term", term))
+	r.becomeFollower(r.term, None)
+	return nil
+}
+
 func (r *Replica) switchConfig(cfg Config) {
 
 	if r.cfg.Version > cfg.Version {
@@ -334,9 +373,11 @@ func (r *Replica) becomeLeader(term uint32) {
 	r.tickFnc = r.tickHeartbeat
 	r.term = term
 	r.leader = r.nodeId
+	r.unhealthyVoteRejects = 0
 	r.role = RoleLeader
 
 	r.initLeaderInfo()
+	r.readIndexMinIndex = r.replicaLog.lastLogIndex
 
 	r.Info("become leader", zap.Uint32("term", r.term))
 
@@ -408,11 +449,14 @@ func (r *Replica) reset(term uint32) {
 	r.leader = None
 	r.electionElapsed = 0
 	r.heartbeatElapsed = 0
+	r.proposeIdleTick = 0
 	r.setSpeedLevel(LevelFast)
 	r.resetRandomizedElectionTimeout()
 
 	r.replicaLog.storaging = false
 	r.replicaLog.applying = false
+
+	r.rejectReadIndex() // 角色或任期变了，等待确认的读请求都失败（在清空msgs之后，响应不会被丢掉）
 }
 
 // 开始选举
@@ -447,6 +491,12 @@ func (r *Replica) tickElection() {
 
 	// r.Debug("electionElapsed--->", zap.Int("electionElapsed", r.electionElapsed))
 	if r.pastElectionTimeout() { // 超时开始进行选举
+		if r.inElectionGrace() { // 领导可能只是短暂停顿，宽限期内先不选举
+			return
+		}
+		if r.opts.ElectionPaused != nil && r.opts.ElectionPaused() { // 选举已暂停（网络维护）
+			return
+		}
 		r.electionElapsed = 0
 		err := r.Step(Message{
 			MsgType: MsgHup,
@@ -473,6 +523,9 @@ func (r *Replica) tickHeartbeat() {
 		}
 	}
 
+	r.proposeIdleTick++
+	r.tickReadIndex()
+
 	if r.opts.ElectionOn { // 是否开启自动选举
 		r.heartbeatElapsed++
 		r.electionElapsed++
@@ -481,7 +534,7 @@ func (r *Replica) tickHeartbeat() {
 			r.electionElapsed = 0
 		}
 
-		if r.heartbeatElapsed >= r.opts.HeartbeatIntervalTick {
+		if r.heartbeatElapsed >= r.heartbeatIntervalTick(r.opts.HeartbeatIntervalTick) {
 			r.heartbeatElapsed = 0
 			if err := r.Step(Message{From: r.opts.NodeId, To: All, MsgType: MsgBeat}); err != nil {
 				r.Debug("error occurred during checking sending heartbeat", zap.Error(err))
@@ -489,9 +542,10 @@ func (r *Replica) tickHeartbeat() {
 		}
 	} else {
 		// 如果某个副本在一段时间内没有发起同步请求，那么主动发起心跳
+		heartbeatIntervalTick := r.heartbeatIntervalTick(r.syncIntervalTick)
 		for nodeId, syncInfo := range r.lastSyncInfoMap {
 			syncInfo.SyncTick++
-			if syncInfo.SyncTick >= r.syncIntervalTick {
+			if syncInfo.SyncTick >= heartbeatIntervalTick {
 				syncInfo.SyncTick = 0
 				if err := r.Step(Message{From: r.opts.NodeId, To: nodeId, MsgType: MsgBeat}); err != nil {
 					r.Debug("error occurred during checking sending heartbeat", zap.Error(err))
@@ -502,10 +556,37 @@ func (r *Replica) tickHeartbeat() {
 
 }
 
+// heartbeatIntervalTick 领导的心跳间隔，副本空闲（一段时间没有提案）时放大间隔
+func (r *Replica) heartbeatIntervalTick(base int) int {
+	if r.opts.IdleHeartbeatTick <= 0 || r.proposeIdleTick < r.opts.IdleHeartbeatTick || r.opts.IdleHeartbeatMultiple <= 1 {
+		return base
+	}
+	interval := base * r.opts.IdleHeartbeatMultiple
+	if r.opts.ElectionOn && interval > r.opts.ElectionIntervalTick/2 {
+		interval = r.opts.ElectionIntervalTick / 2
+	}
+	if interval < base {
+		interval = base
+	}
+	return interval
+}
+
 func (r *Replica) pastElectionTimeout() bool {
 	return r.electionElapsed >= r.randomizedElectionTimeout
 }
 
+// inElectionGrace 跟随者与领导失联是否还在选举宽限期内
+// 宽限期从选举超时开始，最多ElectionGraceTick个tick，期间收到领导消息会重置electionElapsed，不会发起选举
+func (r *Replica) inElectionGrace() bool {
+	if r.opts.ElectionGraceTick <= 0 || r.role != RoleFollower || r.leader == None {
+		return false
+	}
+	if r.electionElapsed == r.randomizedElectionTimeout {
+		r.Info("lost leader contact, hold off election", zap.Uint64("leader", r.leader), zap.Uint32("term", r.term), zap.Int("graceTick", r.opts.ElectionGraceTick))
+	}
+	return r.electionElapsed < r.randomizedElectionTimeout+r.opts.ElectionGraceTick
+}
+
 func (r *Replica) resetRandomizedElectionTimeout() {
 	r.randomizedElectionTimeout = r.opts.ElectionIntervalTick + globalRand.Intn(r.opts.ElectionIntervalTick)
 }
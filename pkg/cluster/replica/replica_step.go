@@ -54,6 +54,8 @@ func (r *Replica) Step(m Message) error {
 				r.Info("lower config version, reject vote")
 			} else if m.Term < r.term {
 				r.Info("lower term, reject vote")
+			} else if !r.candidateHealthy(m.From) {
+				r.Info("candidate unhealthy, reject vote", zap.Int("unhealthyVoteRejects", r.unhealthyVoteRejects))
 			}
 			r.Info("reject vote", zap.Uint64("from", m.From), zap.Uint32("term", m.Term), zap.Uint64("index", m.Index))
 			r.send(r.newMsgVoteResp(m.From, r.term, true))
@@ -92,6 +94,8 @@ func (r *Replica) Step(m Message) error {
 		}
 	case MsgSpeedLevelSet: // 控制速度
 		r.setSpeedLevel(m.SpeedLevel)
+	case MsgReadIndex: // 线性一致读
+		r.stepReadIndex(m)
 	case MsgChangeRole: // 改变权限
 
 		switch m.Role {
@@ -133,6 +137,7 @@ func (r *Replica) stepLeader(m Message) error {
 		if !r.appendLog(m.Logs...) {
 			return ErrProposalDropped
 		}
+		r.proposeIdleTick = 0
 		if r.isSingleNode() || r.opts.AckMode == AckModeNone { // 单机
 			r.Debug("no ack", zap.Uint64("nodeId", r.nodeId), zap.Uint32("term", r.term), zap.Uint64("lastLogIndex", r.replicaLog.lastLogIndex), zap.Uint64("committedIndex", r.replicaLog.committedIndex))
 			r.updateLeaderCommittedIndex() // 更新领导的提交索引
@@ -155,10 +160,17 @@ func (r *Replica) stepLeader(m Message) error {
 
 	case MsgSyncGetResp:
 		if !m.Reject {
-			r.send(r.newMsgSyncResp(m.To, m.Index, m.Logs))
+			index, logs := r.trimSyncGetLogs(m.To, m.Index, m.Logs)
+			if index == 0 { // 副本已经有这些日志了（重试的同步请求被更新的请求超过），不再重复发送
+				return nil
+			}
+			r.send(r.newMsgSyncResp(m.To, index, logs))
 		}
 
 	case MsgSyncReq:
+		if syncInfo := r.lastSyncInfoMap[m.From]; syncInfo != nil {
+			syncInfo.ReqIndex = m.Index
+		}
 
 		lastIndex := r.replicaLog.lastLogIndex
 		if m.Index <= lastIndex {
@@ -226,6 +238,8 @@ func (r *Replica) stepLeader(m Message) error {
 		}
 	case MsgConfigReq: // 收到配置请求
 		r.send(r.newMsgConfigResp(m.From))
+	case MsgReadIndexCheckResp:
+		r.stepReadIndexCheckResp(m)
 	}
 	return nil
 }
@@ -235,6 +249,7 @@ func (r *Replica) stepFollower(m Message) error {
 	switch m.MsgType {
 	case MsgPing:
 		r.electionElapsed = 0
+		r.unhealthyVoteRejects = 0
 		if r.leader == None {
 			r.becomeFollower(m.Term, m.From)
 
@@ -248,6 +263,12 @@ func (r *Replica) stepFollower(m Message) error {
 		r.send(r.newPong(m.From))
 		// r.Debug("recv ping", zap.Uint64("nodeID", r.nodeID), zap.Uint32("term", m.Term), zap.Uint64("from", m.From), zap.Uint64("to", m.To), zap.Uint64("lastLogIndex", r.replicaLog.lastLogIndex), zap.Uint64("leaderCommittedIndex", m.CommittedIndex), zap.Uint64("committedIndex", r.replicaLog.committedIndex))
 		r.updateFollowCommittedIndex(m.CommittedIndex) // 更新提交索引
+	case MsgReadIndexCheck: // 领导确认自己仍是领导
+		if m.From != r.leader {
+			return nil
+		}
+		r.electionElapsed = 0
+		r.send(r.newMsgReadIndexCheckResp(m.From, m.Index))
 	case MsgLogConflictCheckResp: // 日志冲突检查返回
 		if !m.Reject {
 			// r.Info("follower: truncate log to", zap.Uint64("leader", r.leader), zap.Uint32("term", r.term), zap.Uint64("index", m.Index), zap.Uint64("lastIndex", r.replicaLog.lastLogIndex))
@@ -282,7 +303,7 @@ func (r *Replica) stepFollower(m Message) error {
 				r.Warn("log exist, no append", zap.Uint64("syncIndex", m.Index), zap.Uint64("leader", r.leader), zap.Uint64("maxLogIndex", m.Logs[len(m.Logs)-1].Index), zap.Uint64("localLastLogIndex", r.replicaLog.lastLogIndex))
 				return nil
 			}
-			if !r.appendLog(m.Logs...) {
+			if !r.appendLog(r.trimExistLogs(m.Logs)...) {
 				return ErrProposalDropped
 			}
 
@@ -342,7 +363,7 @@ func (r *Replica) stepLearner(m Message) error {
 				r.Warn("learner: log exist, no append", zap.Uint64("syncIndex", m.Index), zap.Uint64("leader", r.leader), zap.Uint64("maxLogIndex", m.Logs[len(m.Logs)-1].Index), zap.Uint64("localLastLogIndex", r.replicaLog.lastLogIndex))
 				return nil
 			}
-			if !r.appendLog(m.Logs...) {
+			if !r.appendLog(r.trimExistLogs(m.Logs)...) {
 				return ErrProposalDropped
 			}
 		} else {
@@ -468,6 +489,7 @@ func (r *Replica) updateLeaderCommittedIndex() bool {
 		r.replicaLog.committedIndex = newCommitted
 		updated = true
 		r.Debug("update leader committed index", zap.Uint64("lastIndex", r.replicaLog.lastLogIndex), zap.Uint32("term", r.term), zap.Uint64("committedIndex", r.replicaLog.committedIndex))
+		r.respondReadIndex() // 等待提交的读请求
 	}
 	return updated
 }
@@ -564,5 +586,53 @@ func (r *Replica) canVote(m Message) bool {
 		return false
 	}
 
+	if !r.candidateHealthy(m.From) { // 日志满足条件，但候选人不健康，拒绝投票（没有领导太久则放开）
+		if r.unhealthyVoteRejects < r.opts.MaxUnhealthyVoteRejects {
+			r.unhealthyVoteRejects++
+			return false
+		}
+		r.Warn("too many unhealthy vote rejects, vote for unhealthy candidate", zap.Uint64("candidate", m.From), zap.Int("unhealthyVoteRejects", r.unhealthyVoteRejects))
+	}
+
 	return true
 }
+
+// 候选人是否健康
+func (r *Replica) candidateHealthy(nodeId uint64) bool {
+	if r.opts.CandidateHealthy == nil {
+		return true
+	}
+	return r.opts.CandidateHealthy(nodeId)
+}
+
+// trimSyncGetLogs 异步获取的同步日志返回时，副本可能已经通过更新的同步请求报告了更大的下标（之前的同步超时重试或部分同步），
+// 只发送副本还没有的日志，返回的index为0表示副本已经有全部日志，不需要发送
+// 副本报告的下标比获取的起始下标小时（例如日志冲突截断后），按原样发送
+func (r *Replica) trimSyncGetLogs(to uint64, index uint64, logs []Log) (uint64, []Log) {
+	syncInfo := r.lastSyncInfoMap[to]
+	if syncInfo == nil || syncInfo.ReqIndex <= index || len(logs) == 0 {
+		return index, logs
+	}
+	if logs[len(logs)-1].Index < syncInfo.ReqIndex {
+		return 0, nil
+	}
+	if logs[0].Index >= syncInfo.ReqIndex {
+		return index, logs
+	}
+	return syncInfo.ReqIndex, logs[syncInfo.ReqIndex-logs[0].Index:]
+}
+
+// trimExistLogs 同步返回的日志和本地已有的日志部分重叠时（之前的同步超时重试或部分同步），裁剪掉本地已有的部分，只追加缺少的日志
+// 重叠部分的最后一条日志任期和本地不一致时不裁剪（由追加时的连续性检查拒绝）
+func (r *Replica) trimExistLogs(logs []Log) []Log {
+	lastLogIndex := r.replicaLog.lastLogIndex
+	if len(logs) == 0 || logs[0].Index > lastLogIndex {
+		return logs
+	}
+	_, lastLogTerm := r.replicaLog.lastIndexAndTerm()
+	overlap := lastLogIndex - logs[0].Index
+	if logs[overlap].Index != lastLogIndex || logs[overlap].Term != lastLogTerm {
+		return logs
+	}
+	return logs[overlap+1:]
+}
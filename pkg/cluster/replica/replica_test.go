@@ -292,6 +292,35 @@ func TestApplyLogs(t *testing.T) {
 
 }
 
+// 已应用下标超过已提交下标，交给上层处理，之后不再应用日志
+func TestApplyAnomaly(t *testing.T) {
+	r := New(1)
+	r.appendLog(Log{Index: 1, Term: 1, Data: []byte("hello")})
+	initReplica(r, Config{
+		Role: RoleLeader,
+		Term: 1,
+	}, t)
+	rd := r.Ready()
+	msg := getMsg(rd.Messages, MsgStoreAppend)
+	err := r.Step(Message{MsgType: MsgStoreAppendResp, Index: msg.Logs[len(msg.Logs)-1].Index})
+	assert.NoError(t, err)
+
+	// 模拟存储异常，已应用下标超过已提交下标
+	r.replicaLog.appliedIndex = 5
+	r.replicaLog.applyingIndex = 5
+
+	assert.True(t, r.HasReady())
+	rd = r.Ready()
+	msg = getMsg(rd.Messages, MsgApplyLogs)
+	assert.Equal(t, MsgApplyLogs, msg.MsgType)
+	assert.Equal(t, uint64(5), msg.AppliedIndex)
+	assert.Less(t, msg.CommittedIndex, msg.AppliedIndex)
+
+	// 只上报一次
+	rd = r.Ready()
+	assert.False(t, hasMsg(rd.Messages, MsgApplyLogs))
+}
+
 // 测试自动选举
 func TestElection(t *testing.T) {
 	var nodeId uint64 = 1
@@ -523,3 +552,438 @@ func TestFollowerToLeader(t *testing.T) {
 	assert.True(t, hasMsg(rd.Messages, MsgSyncResp))
 	assert.True(t, hasMsg(rd.Messages, MsgFollowerToLeader))
 }
+
+func TestLeaderLastLogIndex(t *testing.T) {
+	// 领导
+	r := New(1)
+	r.replicaLog.appendLog(Log{Index: 1, Term: 1, Data: []byte("hello")})
+	err := r.Step(Message{
+		MsgType: MsgInitResp,
+		Config: Config{
+			Role: RoleLeader,
+			Term: 1,
+		},
+	})
+	assert.NoError(t, err)
+
+	lastIndex, err := r.LeaderLastLogIndex()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1), lastIndex)
+
+	// 追随者
+	r = New(1)
+	r.replicaLog.appendLog(Log{Index: 1, Term: 1, Data: []byte("hello")})
+	err = r.Step(Message{
+		MsgType: MsgInitResp,
+		Config: Config{
+			Role:   RoleFollower,
+			Term:   1,
+			Leader: 2,
+		},
+	})
+	assert.NoError(t, err)
+
+	_, err = r.LeaderLastLogIndex()
+	assert.Equal(t, ErrNotLeader, err)
+	assert.Equal(t, uint64(1), r.LastLogIndex())
+}
+
+// 测试投票时拒绝不健康（频繁掉线）的候选人，投给稳定的候选人
+func TestElectionRejectUnhealthyCandidate(t *testing.T) {
+	var nodeId uint64 = 1
+	var flapping uint64 = 2
+	var stable uint64 = 3
+	r := New(nodeId, WithElectionOn(true), WithMaxUnhealthyVoteRejects(2), WithCandidateHealthy(func(id uint64) bool {
+		return id != flapping
+	}))
+	initReplica(r, Config{
+		Role:     RoleFollower,
+		Term:     1,
+		Replicas: []uint64{1, 2, 3},
+	}, t)
+	_ = r.Ready()
+
+	vote := func(from uint64, term uint32) bool {
+		err := r.Step(Message{
+			MsgType: MsgVoteReq,
+			From:    from,
+			To:      nodeId,
+			Term:    term,
+			Logs:    []Log{{Index: 0, Term: 0}},
+		})
+		assert.NoError(t, err)
+		rd := r.Ready()
+		for _, m := range rd.Messages {
+			if m.MsgType == MsgVoteResp && m.To == from {
+				return !m.Reject
+			}
+		}
+		t.Fatal("no vote resp")
+		return false
+	}
+
+	// 不健康的候选人被拒绝，稳定的候选人当选
+	assert.False(t, vote(flapping, 2))
+	assert.True(t, vote(stable, 3))
+
+	// 一直选不出领导时，超过最大拒绝次数后不再检查健康
+	assert.False(t, vote(flapping, 4))
+	assert.True(t, vote(flapping, 5))
+}
+
+// 领导短暂停顿后恢复，宽限期内不发起选举；领导一直没恢复，宽限期后正常选举
+func TestElectionGraceLeaderPause(t *testing.T) {
+	var nodeId uint64 = 1
+	var leaderId uint64 = 2
+	r := New(nodeId, WithElectionOn(true), WithElectionIntervalTick(10), WithElectionGraceTick(10))
+	initReplica(r, Config{
+		Role:     RoleFollower,
+		Term:     1,
+		Leader:   leaderId,
+		Replicas: []uint64{1, 2, 3},
+	}, t)
+	_ = r.Ready()
+
+	tickN := func(n int) {
+		for i := 0; i < n; i++ {
+			r.Tick()
+		}
+	}
+	campaigned := func() bool {
+		rd := r.Ready()
+		return hasMsg(rd.Messages, MsgVoteReq) || r.role == RoleCandidate
+	}
+
+	// 领导停顿超过选举超时，还在宽限期内
+	tickN(r.randomizedElectionTimeout + 5)
+	assert.False(t, campaigned())
+	assert.Equal(t, leaderId, r.leader)
+
+	// 领导恢复
+	err := r.Step(Message{MsgType: MsgPing, From: leaderId, To: nodeId, Term: 1})
+	assert.NoError(t, err)
+	tickN(r.randomizedElectionTimeout + 5)
+	assert.False(t, campaigned())
+	assert.Equal(t, uint32(1), r.term)
+
+	// 领导一直没有恢复，宽限期结束后发起选举
+	tickN(5)
+	assert.True(t, campaigned())
+	assert.Equal(t, uint32(2), r.term)
+}
+
+// 选举暂停期间跟随者与领导失联也不发起选举，恢复后立即选举
+func TestElectionPaused(t *testing.T) {
+	var nodeId uint64 = 1
+	paused := true
+	r := New(nodeId, WithElectionOn(true), WithElectionIntervalTick(10), WithElectionPaused(func() bool { return paused }))
+	initReplica(r, Config{
+		Role:     RoleFollower,
+		Term:     1,
+		Leader:   2,
+		Replicas: []uint64{1, 2, 3},
+	}, t)
+	_ = r.Ready()
+
+	for i := 0; i < r.randomizedElectionTimeout*5; i++ {
+		r.Tick()
+	}
+	rd := r.Ready()
+	assert.False(t, hasMsg(rd.Messages, MsgVoteReq))
+	assert.Equal(t, RoleFollower, r.role)
+	assert.Equal(t, uint32(1), r.term)
+
+	paused = false
+	r.Tick()
+	rd = r.Ready()
+	assert.True(t, hasMsg(rd.Messages, MsgVoteReq) || r.role == RoleCandidate)
+	assert.Equal(t, uint32(2), r.term)
+}
+
+// 空闲（一段时间没有提案）的领导降低心跳频率，活跃的领导按配置的间隔心跳
+func TestIdleHeartbeat(t *testing.T) {
+	newLeader := func(opts ...Option) *Replica {
+		r := New(1, opts...)
+		initReplica(r, Config{
+			Role:     RoleLeader,
+			Term:     1,
+			Leader:   1,
+			Replicas: []uint64{1, 2, 3},
+		}, t)
+		_ = r.Ready()
+		return r
+	}
+	countPings := func(r *Replica, ticks int, propose bool) int {
+		pings := 0
+		for i := 0; i < ticks; i++ {
+			if propose {
+				err := r.Propose([]byte("hello"))
+				assert.NoError(t, err)
+			}
+			r.Tick()
+			for _, m := range r.Ready().Messages {
+				if m.MsgType == MsgPing {
+					pings++
+				}
+			}
+		}
+		return pings
+	}
+
+	// 不开启选举（频道副本）：副本没有同步时领导主动心跳
+	active := countPings(newLeader(WithIdleHeartbeat(10, 4)), 100, true)
+	idle := countPings(newLeader(WithIdleHeartbeat(10, 4)), 100, false)
+	assert.Equal(t, 200, active) // 每个tick给两个副本各发一次心跳
+	assert.Less(t, idle, active/2)
+	assert.Greater(t, idle, 0)
+
+	// 不开启空闲心跳
+	assert.Equal(t, 200, countPings(newLeader(), 100, false))
+
+	// 开启选举：空闲心跳间隔不超过选举间隔的一半，追随者不会误判领导失联
+	r := newLeader(WithElectionOn(true), WithElectionIntervalTick(10), WithIdleHeartbeat(10, 100))
+	_ = countPings(r, 10, false)
+	assert.Equal(t, 5, r.heartbeatIntervalTick(r.opts.HeartbeatIntervalTick))
+	assert.Equal(t, 2*4, countPings(r, 20, false))
+}
+
+// 同步超时重试后（部分同步），领导不重复发送副本已经有的日志，副本也不丢弃部分重叠的同步返回
+func TestNoRedundantSyncAfterPartialSync(t *testing.T) {
+	newLogs := func(start, end uint64) []Log {
+		logs := make([]Log, 0, end-start+1)
+		for i := start; i <= end; i++ {
+			logs = append(logs, Log{Index: i, Term: 1, Data: []byte("hello")})
+		}
+		return logs
+	}
+	syncResps := func(msgs []Message) []Message {
+		resps := make([]Message, 0)
+		for _, m := range msgs {
+			if m.MsgType == MsgSyncResp {
+				resps = append(resps, m)
+			}
+		}
+		return resps
+	}
+
+	// 领导的日志都已存储，同步时需要异步获取日志
+	storage := NewMemoryStorage()
+	err := storage.AppendLog(newLogs(1, 10))
+	assert.NoError(t, err)
+	leader := New(1, WithStorage(storage), WithLastIndex(10))
+	initReplica(leader, Config{
+		Role:     RoleLeader,
+		Term:     1,
+		Leader:   1,
+		Replicas: []uint64{1, 2},
+	}, t)
+	_ = leader.Ready()
+
+	// 副本同步超时后重试，同一个下标的同步请求发了两次
+	for i := 0; i < 2; i++ {
+		err = leader.Step(Message{MsgType: MsgSyncReq, From: 2, To: 1, Term: 1, Index: 1})
+		assert.NoError(t, err)
+		assert.True(t, hasMsg(leader.Ready().Messages, MsgSyncGet))
+	}
+
+	// 第一次获取只返回了一部分（例如大小限制）
+	err = leader.Step(Message{MsgType: MsgSyncGetResp, To: 2, Index: 1, Logs: newLogs(1, 5)})
+	assert.NoError(t, err)
+	resps := syncResps(leader.Ready().Messages)
+	assert.Len(t, resps, 1)
+	assert.Len(t, resps[0].Logs, 5)
+
+	// 副本追加后报告新的下标
+	err = leader.Step(Message{MsgType: MsgSyncReq, From: 2, To: 1, Term: 1, Index: 6})
+	assert.NoError(t, err)
+	_ = leader.Ready()
+
+	// 重试请求的获取结果只发送副本缺少的日志
+	err = leader.Step(Message{MsgType: MsgSyncGetResp, To: 2, Index: 1, Logs: newLogs(1, 10)})
+	assert.NoError(t, err)
+	resps = syncResps(leader.Ready().Messages)
+	assert.Len(t, resps, 1)
+	assert.Equal(t, uint64(6), resps[0].Index)
+	assert.Equal(t, newLogs(6, 10), resps[0].Logs)
+
+	// 副本已经有全部日志，不再发送
+	err = leader.Step(Message{MsgType: MsgSyncReq, From: 2, To: 1, Term: 1, Index: 11})
+	assert.NoError(t, err)
+	_ = leader.Ready()
+	err = leader.Step(Message{MsgType: MsgSyncGetResp, To: 2, Index: 6, Logs: newLogs(6, 10)})
+	assert.NoError(t, err)
+	assert.Len(t, syncResps(leader.Ready().Messages), 0)
+
+	// 副本收到和本地部分重叠的同步返回，只追加缺少的日志
+	follower := New(2, WithSyncIntervalTick(1))
+	initReplica(follower, Config{
+		Role:   RoleFollower,
+		Term:   1,
+		Leader: 1,
+	}, t)
+	err = follower.Step(Message{MsgType: MsgSyncResp, From: 1, To: 2, Term: 1, Index: 1, Logs: newLogs(1, 5)})
+	assert.NoError(t, err)
+	err = follower.Step(Message{MsgType: MsgSyncResp, From: 1, To: 2, Term: 1, Index: 1, Logs: newLogs(1, 10)})
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(10), follower.replicaLog.lastLogIndex)
+	assert.Equal(t, newLogs(1, 10), follower.replicaLog.unstable.logs)
+}
+
+// 测试领导放弃领导权后成为没有领导的追随者，不再接受提案
+func TestStepDown(t *testing.T) {
+	r := New(1)
+	err := r.Step(Message{
+		MsgType: MsgInitResp,
+		Config: Config{
+			Role:     RoleLeader,
+			Term:     2,
+			Replicas: []uint64{1, 2, 3},
+		},
+	})
+	assert.NoError(t, err)
+	assert.True(t, r.isLeader())
+
+	// 过期的任期不处理
+	assert.Equal(t, ErrTermMismatch, r.StepDown(1))
+	assert.True(t, r.isLeader())
+
+	assert.NoError(t, r.StepDown(2))
+	assert.Equal(t, RoleFollower, r.role)
+	assert.Equal(t, None, r.leader)
+	assert.Equal(t, uint32(2), r.Term())
+
+	_, err = r.LeaderLastLogIndex()
+	assert.Equal(t, ErrNotLeader, err)
+	assert.Equal(t, ErrNotLeader, r.StepDown(2))
+}
+
+func TestReadIndex(t *testing.T) {
+	r := New(1)
+	err := r.Step(Message{
+		MsgType: MsgInitResp,
+		Config: Config{
+			Role:     RoleLeader,
+			Term:     2,
+			Replicas: []uint64{1, 2, 3},
+		},
+	})
+	assert.NoError(t, err)
+	_ = r.Ready()
+
+	assert.NoError(t, r.Step(Message{MsgType: MsgReadIndex, Index: 100}))
+	rd := r.Ready()
+	var checks []Message
+	for _, m := range rd.Messages {
+		if m.MsgType == MsgReadIndexCheck {
+			checks = append(checks, m)
+		}
+	}
+	assert.Equal(t, 2, len(checks))
+	assert.False(t, hasMsg(rd.Messages, MsgReadIndexResp))
+
+	// 多数副本（本节点+一个追随者）确认后返回
+	assert.NoError(t, r.Step(Message{MsgType: MsgReadIndexCheckResp, From: 2, To: 1, Term: 2, Index: checks[0].Index}))
+	rd = r.Ready()
+	resp := getMsg(rd.Messages, MsgReadIndexResp)
+	assert.Equal(t, uint64(100), resp.Index)
+	assert.False(t, resp.Reject)
+
+	// 领导放弃领导权，等待确认的读请求失败
+	assert.NoError(t, r.Step(Message{MsgType: MsgReadIndex, Index: 101}))
+	_ = r.Ready()
+	assert.NoError(t, r.StepDown(2))
+	rd = r.Ready()
+	resp = getMsg(rd.Messages, MsgReadIndexResp)
+	assert.Equal(t, uint64(101), resp.Index)
+	assert.True(t, resp.Reject)
+
+	// 不是领导直接失败
+	assert.NoError(t, r.Step(Message{MsgType: MsgReadIndex, Index: 102}))
+	rd = r.Ready()
+	resp = getMsg(rd.Messages, MsgReadIndexResp)
+	assert.Equal(t, uint64(102), resp.Index)
+	assert.True(t, resp.Reject)
+}
+
+// 刚成为领导时之前任期的日志还没有提交，多数副本确认后也要等提交到成为领导时的最后一条日志才返回
+func TestReadIndexWaitCommitted(t *testing.T) {
+	r := New(1, WithLastIndex(10), WithAppliedIndex(5))
+	err := r.Step(Message{
+		MsgType: MsgInitResp,
+		Config: Config{
+			Role:     RoleLeader,
+			Term:     2,
+			Replicas: []uint64{1, 2, 3},
+		},
+	})
+	assert.NoError(t, err)
+	_ = r.Ready()
+
+	assert.NoError(t, r.Step(Message{MsgType: MsgReadIndex, Index: 100}))
+	rd := r.Ready()
+	check := getMsg(rd.Messages, MsgReadIndexCheck)
+
+	// 多数副本确认了，但是下标10还没有提交
+	assert.NoError(t, r.Step(Message{MsgType: MsgReadIndexCheckResp, From: 2, To: 1, Term: 2, Index: check.Index}))
+	rd = r.Ready()
+	assert.False(t, hasMsg(rd.Messages, MsgReadIndexResp))
+
+	// 追随者同步到了最后一条日志，领导提交到10后返回
+	assert.NoError(t, r.Step(Message{MsgType: MsgSyncReq, From: 2, To: 1, Term: 2, Index: 11}))
+	rd = r.Ready()
+	resp := getMsg(rd.Messages, MsgReadIndexResp)
+	assert.Equal(t, uint64(100), resp.Index)
+	assert.Equal(t, uint64(10), resp.CommittedIndex)
+	assert.False(t, resp.Reject)
+}
+
+func TestReadIndexCheckFollower(t *testing.T) {
+	r := New(2)
+	err := r.Step(Message{
+		MsgType: MsgInitResp,
+		Config: Config{
+			Role:     RoleFollower,
+			Term:     2,
+			Leader:   1,
+			Replicas: []uint64{1, 2, 3},
+		},
+	})
+	assert.NoError(t, err)
+	_ = r.Ready()
+
+	assert.NoError(t, r.Step(Message{MsgType: MsgReadIndexCheck, From: 1, To: 2, Term: 2, Index: 5}))
+	rd := r.Ready()
+	resp := getMsg(rd.Messages, MsgReadIndexCheckResp)
+	assert.Equal(t, uint64(1), resp.To)
+	assert.Equal(t, uint64(5), resp.Index)
+	assert.Equal(t, uint32(2), resp.Term)
+
+	// 不是领导发来的确认不响应
+	assert.NoError(t, r.Step(Message{MsgType: MsgReadIndexCheck, From: 3, To: 2, Term: 2, Index: 6}))
+	rd = r.Ready()
+	assert.False(t, hasMsg(rd.Messages, MsgReadIndexCheckResp))
+}
+
+// 安装快照期间副本不存储也不应用日志，安装结束后恢复
+func TestBeginInstallSnapshot(t *testing.T) {
+	follower := New(2)
+	initReplica(follower, Config{
+		Role:   RoleFollower,
+		Term:   1,
+		Leader: 1,
+	}, t)
+	_ = follower.Ready()
+
+	assert.NoError(t, follower.BeginInstallSnapshot())
+	err := follower.Step(Message{MsgType: MsgSyncResp, From: 1, To: 2, Term: 1, Index: 1, Logs: []Log{{Index: 1, Term: 1, Data: []byte("a")}, {Index: 2, Term: 1, Data: []byte("b")}}, CommittedIndex: 2})
+	assert.NoError(t, err)
+	rd := follower.Ready()
+	assert.False(t, hasMsg(rd.Messages, MsgStoreAppend))
+
+	follower.EndInstallSnapshot()
+	rd = follower.Ready()
+	assert.True(t, hasMsg(rd.Messages, MsgStoreAppend))
+
+	// 正在存储日志时不能开始安装
+	assert.ErrorIs(t, follower.BeginInstallSnapshot(), ErrReplicaBusy)
+}
@@ -0,0 +1,59 @@
+package replica
+
+import "go.uber.org/zap"
+
+// Snapshot 副本的快照，快照内的日志都已经提交和应用
+type Snapshot struct {
+	LastIndex uint64 // 快照内最后一条日志的下标
+	LastTerm  uint32 // 快照内最后一条日志的任期
+	Data      []byte // 快照数据（格式由使用方决定）
+}
+
+// BeginInstallSnapshot 开始安装快照，之后副本不再存储和应用日志，直到InstallSnapshot或EndInstallSnapshot
+// 领导不能安装快照（返回ErrIsLeader），正在存储或应用日志时返回ErrReplicaBusy（稍后重试）
+func (r *Replica) BeginInstallSnapshot() error {
+	if r.isLeader() {
+		return ErrIsLeader
+	}
+	if r.replicaLog.storaging || r.replicaLog.applying {
+		return ErrReplicaBusy
+	}
+	r.replicaLog.snapshotInstalling = true
+	return nil
+}
+
+// EndInstallSnapshot 结束安装快照（安装失败时调用），副本恢复存储和应用日志
+func (r *Replica) EndInstallSnapshot() {
+	r.replicaLog.snapshotInstalling = false
+}
+
+// InstallSnapshot 快照的日志已经写入存储并应用后调用，把副本的日志、提交和应用下标推进到快照的位置
+// 领导不能安装快照（返回ErrIsLeader），正在存储或应用日志时返回ErrReplicaBusy（稍后重试），快照不比已应用的日志新时什么也不做
+func (r *Replica) InstallSnapshot(snap Snapshot) error {
+	if r.isLeader() {
+		return ErrIsLeader
+	}
+	if r.replicaLog.storaging || r.replicaLog.applying {
+		return ErrReplicaBusy
+	}
+	r.replicaLog.snapshotInstalling = false
+	if snap.LastIndex <= r.replicaLog.appliedIndex {
+		return nil
+	}
+
+	if snap.LastIndex >= r.replicaLog.lastLogIndex {
+		// 内存里还没存储的日志都在快照内，丢弃
+		r.replicaLog.unstable.truncateLogTo(r.replicaLog.unstable.offset)
+		r.replicaLog.updateLastIndex(snap.LastIndex)
+	} else if snap.LastIndex > r.replicaLog.storagedIndex {
+		r.replicaLog.unstable.appliedTo(snap.LastIndex)
+		r.replicaLog.storagedTo(snap.LastIndex)
+	}
+	if snap.LastIndex > r.replicaLog.committedIndex {
+		r.replicaLog.committedTo(snap.LastIndex)
+	}
+	r.replicaLog.appliedTo(snap.LastIndex)
+
+	r.Info("install snapshot", zap.Uint64("lastIndex", snap.LastIndex), zap.Uint32("lastTerm", snap.LastTerm), zap.Uint64("lastLogIndex", r.replicaLog.lastLogIndex))
+	return nil
+}
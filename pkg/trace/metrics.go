@@ -1,6 +1,10 @@
 package trace
 
-import "github.com/WuKongIM/WuKongIM/pkg/wkhttp"
+import (
+	"time"
+
+	"github.com/WuKongIM/WuKongIM/pkg/wkhttp"
+)
 
 type ClusterKind int
 
@@ -13,8 +17,22 @@ const (
 	ClusterKindChannel
 	// ClusterKindConfig 配置
 	ClusterKindConfig
+
+	clusterKindCount = iota // ClusterKind的数量
 )
 
+func (c ClusterKind) String() string {
+	switch c {
+	case ClusterKindSlot:
+		return "slot"
+	case ClusterKindChannel:
+		return "channel"
+	case ClusterKindConfig:
+		return "config"
+	}
+	return "unknown"
+}
+
 type IMetrics interface {
 	// System 系统监控
 	System() ISystemMetrics
@@ -170,6 +188,30 @@ type IAppMetrics interface {
 	// MessageLatencyOb 消息延迟
 	MessageLatencyOb(v int64)
 
+	// ChannelMessageCountAdd 频道消息数量（只有最繁忙的N个频道单独统计，其他频道合并到other，N由ChannelTopN配置）
+	ChannelMessageCountAdd(channelId string, channelType uint8, v int64)
+
+	// ForwardQueueDepthAdd 代理节点待转发给领导的消息数量
+	ForwardQueueDepthAdd(v int64)
+	// ForwardQueueOverflowCountAdd 转发队列满了的次数
+	ForwardQueueOverflowCountAdd(v int64)
+
+	// DeliverQueueDepthAdd 投递阶段排队中的消息数量（按频道类型）
+	DeliverQueueDepthAdd(channelType uint8, v int64)
+	// DeliverBackpressureCountAdd 投递队列满了，频道暂停投递的次数（按频道类型）
+	DeliverBackpressureCountAdd(channelType uint8, v int64)
+
+	// ChannelStepQueueFullCountAdd 频道reactor事件队列满了的次数
+	ChannelStepQueueFullCountAdd(v int64)
+
+	// SendackCoalescedWriteCountAdd 开启回执合并时，合并后写给连接的次数（一次写入包含同一个连接的多个回执包）
+	SendackCoalescedWriteCountAdd(v int64)
+	// SendackSavedWriteCountAdd 开启回执合并时，合并节省的写入次数（回执包数量减去合并后的写入次数）
+	SendackSavedWriteCountAdd(v int64)
+
+	// ChannelActionCountAdd 频道reactor处理的事件数量（按事件类型）
+	ChannelActionCountAdd(action string, v int64)
+
 	// PingBytesAdd ping流量
 	PingBytesAdd(v int64)
 	// PingCountAdd ping数量
@@ -338,13 +380,69 @@ type IClusterMetrics interface {
 
 	// ChannelActiveCountAdd 频道激活数量
 	ChannelActiveCountAdd(v int64)
-
-	// ChannelElectionCountAdd 频道选举次数
-	ChannelElectionCountAdd(v int64)
-	// ChannelElectionSuccessCountAdd 频道选举成功次数
-	ChannelElectionSuccessCountAdd(v int64)
-	// ChannelElectionFailCountAdd 频道选举失败次数
-	ChannelElectionFailCountAdd(v int64)
+	// ChannelCreateCountAdd 频道创建数量
+	ChannelCreateCountAdd(v int64)
+	// ChannelCreateRejectedCountAdd 因超过创建速率被拒绝的频道创建数量
+	ChannelCreateRejectedCountAdd(v int64)
+	// ChannelReapedCountAdd 因长时间不活跃被回收的频道数量
+	ChannelReapedCountAdd(v int64)
+
+	// WriteBytesAdd 节点提案写入的字节数量（rate后即为当前的写入速率）
+	WriteBytesAdd(v int64)
+	// WriteThrottledCountAdd 因超过节点写入速率被限流的提案次数
+	WriteThrottledCountAdd(v int64)
+
+	// ProposeConcurrencyAdd 节点正在进行中的提案数量
+	ProposeConcurrencyAdd(v int64)
+	// ProposeTooBusyCountAdd 因超过节点最大并发提案数被拒绝的提案次数
+	ProposeTooBusyCountAdd(v int64)
+
+	// ChannelDebugOn 开启频道的调试监控，单独统计此频道的提案数量、提交延迟、应用落后、提案排队数量（频道id作为属性）
+	ChannelDebugOn(channelId string, channelType uint8)
+	// ChannelDebugOff 关闭频道的调试监控，此频道的时间序列不再上报
+	ChannelDebugOff(channelId string, channelType uint8)
+	// IsChannelDebug 频道是否开启了调试监控
+	IsChannelDebug(channelId string, channelType uint8) bool
+	// ChannelDebugChannels 开启了调试监控的频道
+	ChannelDebugChannels() []DebugChannel
+	// ChannelDebugProposeCommitted 调试频道的提案已提交，count为日志数量，latency为提案到提交的延迟
+	ChannelDebugProposeCommitted(channelId string, channelType uint8, count int64, latency time.Duration)
+	// ChannelDebugProposeQueueDepthAdd 调试频道等待提交的提案数量
+	ChannelDebugProposeQueueDepthAdd(channelId string, channelType uint8, v int64)
+	// ChannelDebugApplyLagSet 调试频道已提交未应用的日志数量
+	ChannelDebugApplyLagSet(channelId string, channelType uint8, v int64)
+
+	// ProposeNotLeaderRetryCountAdd 提案遇到不是领导（选举中）后重试的次数
+	ProposeNotLeaderRetryCountAdd(v int64)
+	// ProposeAckTraceDroppedCountAdd 跟踪的提案太多，丢弃的提案副本确认跟踪数量
+	ProposeAckTraceDroppedCountAdd(v int64)
+
+	// ChannelLeaderChangeCountAdd 频道领导变更次数
+	ChannelLeaderChangeCountAdd(v int64)
+	// ChannelLeaderFlappingCountAdd 频道进入领导频繁变更（flapping）状态的次数
+	ChannelLeaderFlappingCountAdd(v int64)
+	// ChannelLeaderFlappingAdd 当前处于领导频繁变更状态的频道数量
+	ChannelLeaderFlappingAdd(v int64)
+
+	// PeerSendQueueSource 设置每个节点发送队列状态的来源，观测时调用，上报每个节点的队列深度、排队字节、发送中字节、最早消息的等待时间（节点id作为属性）
+	PeerSendQueueSource(f func() []PeerSendQueueStat)
+
+	// ProposeLogSizeRecord 记录提案日志数据的大小（按频道类型，需开启LogSizeMetricsOn）
+	ProposeLogSizeRecord(channelType uint8, size int64)
+
+	// ChannelTypeMessageIncomingBytesAdd 按频道类型统计的频道消息入口流量
+	ChannelTypeMessageIncomingBytesAdd(channelType uint8, v int64)
+	// ChannelTypeMessageOutgoingBytesAdd 按频道类型统计的频道消息出口流量
+	ChannelTypeMessageOutgoingBytesAdd(channelType uint8, v int64)
+	// ChannelTypeTrafficSnapshot 获取某一时刻各频道类型的消息流量总量（所有类型在同一时刻读取），供自定义导出器使用
+	ChannelTypeTrafficSnapshot() ChannelTypeTrafficSnapshot
+
+	// ChannelElectionCountAdd 频道选举次数（按频道类型）
+	ChannelElectionCountAdd(channelType uint8, v int64)
+	// ChannelElectionSuccessCountAdd 频道选举成功次数（按频道类型）
+	ChannelElectionSuccessCountAdd(channelType uint8, v int64)
+	// ChannelElectionFailCountAdd 频道选举失败次数（按频道类型）
+	ChannelElectionFailCountAdd(channelType uint8, v int64)
 
 	// SlotElectionCountAdd  槽位选举次数
 	SlotElectionCountAdd(v int64)
@@ -355,7 +453,40 @@ type IClusterMetrics interface {
 
 	// ProposeLatencyAdd 提案延迟统计
 	ProposeLatencyAdd(kind ClusterKind, v int64)
+	// ObserveProposeCommitLatency 提案从进入提案队列到提交的耗时（秒）
+	ObserveProposeCommitLatency(kind ClusterKind, seconds float64)
 
 	// ProposeFailedCountAdd 提案失败的次数
 	ProposeFailedCountAdd(kind ClusterKind, v int64)
+
+	// ApplyLagRecord 已提交未应用的日志数量统计
+	ApplyLagRecord(kind ClusterKind, v int64)
+	// ProposeThrottledCountAdd 因应用落后被限流的提案次数
+	ProposeThrottledCountAdd(kind ClusterKind, v int64)
+
+	// DiskFreeBytesSet 数据目录所在磁盘的剩余空间
+	DiskFreeBytesSet(v int64)
+	// DiskReadOnlySet 是否因为磁盘空间不足进入只读（拒绝提案）
+	DiskReadOnlySet(readOnly bool)
+	// DiskFullRejectedCountAdd 因为磁盘空间不足被拒绝的提案次数
+	DiskFullRejectedCountAdd(v int64)
+
+	// ElectionPauseUntilSet 暂停选举到什么时候（网络维护），零值表示没有暂停，观测时计算是否暂停和剩余时间
+	ElectionPauseUntilSet(until time.Time)
+
+	// InboundDroppedCountAdd 连接收到的消息超过限速被丢弃的数量
+	InboundDroppedCountAdd(kind ClusterKind, v int64)
+	// InboundThrottledCountAdd 连接收到的消息超过限速，关键消息不丢弃但连接被限流的次数
+	InboundThrottledCountAdd(kind ClusterKind, v int64)
+
+	// MessageTooLargeDroppedCountAdd 超过单条消息最大字节数被丢弃的消息数量
+	MessageTooLargeDroppedCountAdd(kind ClusterKind, v int64)
+
+	// ProposeResultBackpressureCountAdd 提案结果通知协程池已满，在应用协程里直接通知的次数
+	ProposeResultBackpressureCountAdd(kind ClusterKind, v int64)
+
+	// CommitWaitersAdd 等待提交的提案数量（加入等待时加1，提交、失败或超时后减1）
+	CommitWaitersAdd(kind ClusterKind, v int64)
+	// CommitWaitGapRecord 每次提交后还在等待的日志最大下标与已提交下标的差距，上报观测周期内的最大值
+	CommitWaitGapRecord(kind ClusterKind, v int64)
 }
@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"github.com/WuKongIM/WuKongIM/pkg/wklog"
+	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/metric"
 	"go.uber.org/atomic"
 	"go.uber.org/zap"
@@ -33,6 +34,21 @@ type appMetrics struct {
 	connPacketCount    atomic.Int64
 	connackPacketBytes atomic.Int64
 	connackPacketCount atomic.Int64
+
+	channelTopN *channelTopN // 频道消息数量topN统计
+
+	forwardQueueDepth         atomic.Int64 // 待转发的消息数量
+	forwardQueueOverflowCount atomic.Int64 // 转发队列满了的次数
+
+	channelStepQueueFullCount atomic.Int64 // 频道reactor事件队列满了的次数
+
+	deliverQueueDepth        metric.Int64UpDownCounter // 投递阶段排队中的消息数量
+	deliverBackpressureCount metric.Int64Counter       // 投递队列满了的次数
+
+	channelActionCount metric.Int64Counter // 频道reactor处理的事件数量
+
+	sendackCoalescedWriteCount metric.Int64Counter // 回执合并后写给连接的次数
+	sendackSavedWriteCount     metric.Int64Counter // 回执合并节省的写入次数
 }
 
 func newAppMetrics(opts *Options) *appMetrics {
@@ -42,6 +58,9 @@ func newAppMetrics(opts *Options) *appMetrics {
 		Log:  wklog.NewWKLog("appMetrics"),
 	}
 
+	a.channelTopN = newChannelTopN(opts.ChannelTopN, opts.ChannelTopNInterval)
+	a.channelTopN.register("app_channel_message_count")
+
 	connCount := NewInt64ObservableCounter("app_conn_count")
 	onlineUserCount := NewInt64ObservableCounter("app_online_user_count")
 	onlineDeviceCount := NewInt64ObservableCounter("app_online_device_count")
@@ -84,6 +103,25 @@ func newAppMetrics(opts *Options) *appMetrics {
 		obs.ObserveInt64(connackPacketCount, a.connackPacketCount.Load())
 		return nil
 	}, connCount, onlineUserCount, onlineDeviceCount, pingBytes, pingCount, pongBytes, pongCount, sendPacketBytes, sendPacketCount, sendackPacketBytes, sendackPacketCount, recvPacketBytes, recvPacketCount, recvackPacketBytes, recvackPacketCount, connPacketBytes, connPacketCount, connackPacketBytes, connackPacketCount)
+	forwardQueueDepth := NewInt64ObservableGauge("app_forward_queue_depth")
+	forwardQueueOverflowCount := NewInt64ObservableCounter("app_forward_queue_overflow_count")
+	RegisterCallback(func(ctx context.Context, obs metric.Observer) error {
+		obs.ObserveInt64(forwardQueueDepth, a.forwardQueueDepth.Load())
+		obs.ObserveInt64(forwardQueueOverflowCount, a.forwardQueueOverflowCount.Load())
+		return nil
+	}, forwardQueueDepth, forwardQueueOverflowCount)
+	channelStepQueueFullCount := NewInt64ObservableCounter("app_channel_step_queue_full_count")
+	RegisterCallback(func(ctx context.Context, obs metric.Observer) error {
+		obs.ObserveInt64(channelStepQueueFullCount, a.channelStepQueueFullCount.Load())
+		return nil
+	}, channelStepQueueFullCount)
+
+	a.deliverQueueDepth = NewInt64UpDownCounter("app_deliver_queue_depth")
+	a.deliverBackpressureCount = NewInt64Counter("app_deliver_backpressure_count")
+	a.channelActionCount = NewInt64Counter("app_channel_action_count")
+	a.sendackCoalescedWriteCount = NewInt64Counter("app_sendack_coalesced_write_count")
+	a.sendackSavedWriteCount = NewInt64Counter("app_sendack_saved_write_count")
+
 	var err error
 	a.messageLatency, err = meter.Int64Histogram("app_message_latency", metric.WithDescription("The latency of message processing in the app layer"), metric.WithUnit("ms"))
 	if err != nil {
@@ -107,6 +145,42 @@ func (a *appMetrics) MessageLatencyOb(v int64) {
 	a.messageLatency.Record(a.ctx, v)
 }
 
+func (a *appMetrics) ChannelMessageCountAdd(channelId string, channelType uint8, v int64) {
+	a.channelTopN.add(channelId, channelType, v)
+}
+
+func (a *appMetrics) ForwardQueueDepthAdd(v int64) {
+	a.forwardQueueDepth.Add(v)
+}
+
+func (a *appMetrics) ForwardQueueOverflowCountAdd(v int64) {
+	a.forwardQueueOverflowCount.Add(v)
+}
+
+func (a *appMetrics) DeliverQueueDepthAdd(channelType uint8, v int64) {
+	a.deliverQueueDepth.Add(a.ctx, v, metric.WithAttributes(attribute.Int("channelType", int(channelType))))
+}
+
+func (a *appMetrics) DeliverBackpressureCountAdd(channelType uint8, v int64) {
+	a.deliverBackpressureCount.Add(a.ctx, v, metric.WithAttributes(attribute.Int("channelType", int(channelType))))
+}
+
+func (a *appMetrics) ChannelStepQueueFullCountAdd(v int64) {
+	a.channelStepQueueFullCount.Add(v)
+}
+
+func (a *appMetrics) SendackCoalescedWriteCountAdd(v int64) {
+	a.sendackCoalescedWriteCount.Add(a.ctx, v)
+}
+
+func (a *appMetrics) SendackSavedWriteCountAdd(v int64) {
+	a.sendackSavedWriteCount.Add(a.ctx, v)
+}
+
+func (a *appMetrics) ChannelActionCountAdd(action string, v int64) {
+	a.channelActionCount.Add(a.ctx, v, metric.WithAttributes(attribute.String("action", action)))
+}
+
 func (a *appMetrics) PingBytesAdd(v int64) {
 	a.pingBytes.Add(v)
 }
@@ -0,0 +1,125 @@
+package trace
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// channelOtherBucket 未进入topN的频道统一归到此桶
+const channelOtherBucket = "other"
+
+type channelCount struct {
+	channelId   string
+	channelType uint8
+	count       int64
+}
+
+// channelTopN 频道级别的监控，只单独统计最繁忙的N个频道，其他频道合并到other桶里
+// 时间序列的数量上限为 N + 1（N个频道 + other），不会随频道数量增长
+type channelTopN struct {
+	n        int
+	interval time.Duration
+
+	mu           sync.Mutex
+	counts       map[channelKey]*channelCount // 当前统计窗口内各频道的计数
+	top          []channelCount               // 上一个统计窗口的topN频道
+	other        int64                        // 上一个统计窗口内other桶的计数
+	lastEvaluate time.Time
+}
+
+func newChannelTopN(n int, interval time.Duration) *channelTopN {
+	return &channelTopN{
+		n:            n,
+		interval:     interval,
+		counts:       make(map[channelKey]*channelCount),
+		lastEvaluate: time.Now(),
+	}
+}
+
+func (c *channelTopN) add(channelId string, channelType uint8, v int64) {
+	if c.n <= 0 {
+		return
+	}
+	key := channelKey{channelId: channelId, channelType: channelType}
+	c.mu.Lock()
+	cc := c.counts[key]
+	if cc == nil {
+		cc = &channelCount{
+			channelId:   channelId,
+			channelType: channelType,
+		}
+		c.counts[key] = cc
+	}
+	cc.count += v
+	c.mu.Unlock()
+}
+
+// evaluateIfNeed 统计窗口结束则重新计算topN
+func (c *channelTopN) evaluateIfNeed(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if now.Sub(c.lastEvaluate) < c.interval {
+		return
+	}
+	c.evaluate()
+	c.lastEvaluate = now
+}
+
+func (c *channelTopN) evaluate() {
+	all := make([]channelCount, 0, len(c.counts))
+	for _, cc := range c.counts {
+		all = append(all, *cc)
+	}
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].count > all[j].count
+	})
+	var other int64
+	if len(all) > c.n {
+		for _, cc := range all[c.n:] {
+			other += cc.count
+		}
+		all = all[:c.n]
+	}
+	c.top = all
+	c.other = other
+	c.counts = make(map[channelKey]*channelCount, len(c.counts))
+}
+
+func (c *channelTopN) observe(obs metric.Observer, gauge metric.Int64ObservableGauge) {
+	c.evaluateIfNeed(time.Now())
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, cc := range c.top {
+		obs.ObserveInt64(gauge, cc.count, metric.WithAttributes(
+			attribute.String("channel_id", cc.channelId),
+			attribute.Int("channel_type", int(cc.channelType)),
+		))
+	}
+	if len(c.top) > 0 {
+		obs.ObserveInt64(gauge, c.other, metric.WithAttributes(
+			attribute.String("channel_id", channelOtherBucket),
+		))
+	}
+}
+
+func (c *channelTopN) register(name string) {
+	if c.n <= 0 {
+		return
+	}
+	gauge := NewInt64ObservableGauge(name)
+	RegisterCallback(func(ctx context.Context, obs metric.Observer) error {
+		c.observe(obs, gauge)
+		return nil
+	}, gauge)
+}
+
+type channelKey struct {
+	channelId   string
+	channelType uint8
+}
@@ -0,0 +1,156 @@
+package trace
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.uber.org/atomic"
+)
+
+// DebugChannel 开启了调试监控的频道
+type DebugChannel struct {
+	ChannelId   string `json:"channel_id"`
+	ChannelType uint8  `json:"channel_type"`
+}
+
+type channelDebugStat struct {
+	proposeCount      atomic.Int64 // 提案日志数量
+	commitCount       atomic.Int64 // 提交次数
+	commitLatencySum  atomic.Int64 // 提交延迟总和（毫秒）
+	commitLatencyLast atomic.Int64 // 最近一次的提交延迟（毫秒）
+	applyLag          atomic.Int64 // 已提交未应用的日志数量
+	proposeQueueDepth atomic.Int64 // 等待提交的提案数量
+}
+
+// channelDebug 频道调试监控，只对指定的频道单独统计（频道id作为属性），用于排查某个频道的问题
+// 只统计开启的频道，时间序列的数量不会随频道数量增长；关闭后不再上报此频道，对应的时间序列随之消失
+type channelDebug struct {
+	on       atomic.Bool // 是否有开启调试的频道（快速判断，避免每次都加锁）
+	mu       sync.RWMutex
+	channels map[channelKey]*channelDebugStat
+
+	proposeCount      metric.Int64ObservableCounter
+	commitCount       metric.Int64ObservableCounter
+	commitLatencySum  metric.Int64ObservableCounter
+	commitLatencyLast metric.Int64ObservableGauge
+	applyLag          metric.Int64ObservableGauge
+	proposeQueueDepth metric.Int64ObservableGauge
+}
+
+func newChannelDebug(m metric.Meter) *channelDebug {
+	c := &channelDebug{
+		channels: make(map[channelKey]*channelDebugStat),
+	}
+	var err error
+	if c.proposeCount, err = m.Int64ObservableCounter("cluster_debug_channel_propose_count"); err != nil {
+		panic(err)
+	}
+	if c.commitCount, err = m.Int64ObservableCounter("cluster_debug_channel_commit_count"); err != nil {
+		panic(err)
+	}
+	if c.commitLatencySum, err = m.Int64ObservableCounter("cluster_debug_channel_commit_latency_sum", metric.WithUnit("ms")); err != nil {
+		panic(err)
+	}
+	if c.commitLatencyLast, err = m.Int64ObservableGauge("cluster_debug_channel_commit_latency", metric.WithUnit("ms")); err != nil {
+		panic(err)
+	}
+	if c.applyLag, err = m.Int64ObservableGauge("cluster_debug_channel_apply_lag"); err != nil {
+		panic(err)
+	}
+	if c.proposeQueueDepth, err = m.Int64ObservableGauge("cluster_debug_channel_propose_queue_depth"); err != nil {
+		panic(err)
+	}
+	_, err = m.RegisterCallback(func(ctx context.Context, obs metric.Observer) error {
+		c.observe(obs)
+		return nil
+	}, c.proposeCount, c.commitCount, c.commitLatencySum, c.commitLatencyLast, c.applyLag, c.proposeQueueDepth)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+func (c *channelDebug) enable(channelId string, channelType uint8) {
+	key := channelKey{channelId: channelId, channelType: channelType}
+	c.mu.Lock()
+	if _, ok := c.channels[key]; !ok {
+		c.channels[key] = &channelDebugStat{}
+	}
+	c.on.Store(true)
+	c.mu.Unlock()
+}
+
+func (c *channelDebug) disable(channelId string, channelType uint8) {
+	key := channelKey{channelId: channelId, channelType: channelType}
+	c.mu.Lock()
+	delete(c.channels, key)
+	c.on.Store(len(c.channels) > 0)
+	c.mu.Unlock()
+}
+
+func (c *channelDebug) list() []DebugChannel {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	channels := make([]DebugChannel, 0, len(c.channels))
+	for key := range c.channels {
+		channels = append(channels, DebugChannel{ChannelId: key.channelId, ChannelType: key.channelType})
+	}
+	return channels
+}
+
+func (c *channelDebug) stat(channelId string, channelType uint8) *channelDebugStat {
+	if !c.on.Load() {
+		return nil
+	}
+	c.mu.RLock()
+	st := c.channels[channelKey{channelId: channelId, channelType: channelType}]
+	c.mu.RUnlock()
+	return st
+}
+
+func (c *channelDebug) proposeCommitted(channelId string, channelType uint8, count int64, latency time.Duration) {
+	st := c.stat(channelId, channelType)
+	if st == nil {
+		return
+	}
+	st.proposeCount.Add(count)
+	st.commitCount.Inc()
+	st.commitLatencySum.Add(latency.Milliseconds())
+	st.commitLatencyLast.Store(latency.Milliseconds())
+}
+
+func (c *channelDebug) proposeQueueDepthAdd(channelId string, channelType uint8, v int64) {
+	st := c.stat(channelId, channelType)
+	if st == nil {
+		return
+	}
+	st.proposeQueueDepth.Add(v)
+}
+
+func (c *channelDebug) applyLagSet(channelId string, channelType uint8, v int64) {
+	st := c.stat(channelId, channelType)
+	if st == nil {
+		return
+	}
+	st.applyLag.Store(v)
+}
+
+func (c *channelDebug) observe(obs metric.Observer) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for key, st := range c.channels {
+		attrs := metric.WithAttributes(
+			attribute.String("channel_id", key.channelId),
+			attribute.Int("channel_type", int(key.channelType)),
+		)
+		obs.ObserveInt64(c.proposeCount, st.proposeCount.Load(), attrs)
+		obs.ObserveInt64(c.commitCount, st.commitCount.Load(), attrs)
+		obs.ObserveInt64(c.commitLatencySum, st.commitLatencySum.Load(), attrs)
+		obs.ObserveInt64(c.commitLatencyLast, st.commitLatencyLast.Load(), attrs)
+		obs.ObserveInt64(c.applyLag, st.applyLag.Load(), attrs)
+		obs.ObserveInt64(c.proposeQueueDepth, st.proposeQueueDepth.Load(), attrs)
+	}
+}
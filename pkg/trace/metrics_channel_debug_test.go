@@ -0,0 +1,74 @@
+package trace
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func collectChannelDebug(t *testing.T, reader *sdkmetric.ManualReader) map[string]metricdata.Aggregation {
+	var rm metricdata.ResourceMetrics
+	err := reader.Collect(context.Background(), &rm)
+	require.NoError(t, err)
+	result := make(map[string]metricdata.Aggregation)
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			result[m.Name] = m.Data
+		}
+	}
+	return result
+}
+
+func TestChannelDebug(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	provider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	defer func() {
+		_ = provider.Shutdown(context.Background())
+	}()
+
+	c := newChannelDebug(provider.Meter("test"))
+
+	// 没有开启调试的频道不统计
+	c.proposeCommitted("ch1", 2, 1, time.Millisecond*10)
+	require.Nil(t, c.stat("ch1", 2))
+
+	c.enable("ch1", 2)
+	c.proposeCommitted("ch1", 2, 3, time.Millisecond*10)
+	c.proposeCommitted("ch1", 2, 2, time.Millisecond*30)
+	c.proposeCommitted("ch2", 2, 1, time.Millisecond*10)
+	c.proposeQueueDepthAdd("ch1", 2, 1)
+	c.applyLagSet("ch1", 2, 5)
+	require.Equal(t, []DebugChannel{{ChannelId: "ch1", ChannelType: 2}}, c.list())
+
+	data := collectChannelDebug(t, reader)
+	proposeCount, ok := data["cluster_debug_channel_propose_count"].(metricdata.Sum[int64])
+	require.True(t, ok)
+	require.Len(t, proposeCount.DataPoints, 1)
+	require.Equal(t, int64(5), proposeCount.DataPoints[0].Value)
+	channelId, _ := proposeCount.DataPoints[0].Attributes.Value("channel_id")
+	require.Equal(t, "ch1", channelId.AsString())
+
+	latencySum := data["cluster_debug_channel_commit_latency_sum"].(metricdata.Sum[int64])
+	require.Equal(t, int64(40), latencySum.DataPoints[0].Value)
+	applyLag := data["cluster_debug_channel_apply_lag"].(metricdata.Gauge[int64])
+	require.Equal(t, int64(5), applyLag.DataPoints[0].Value)
+	queueDepth := data["cluster_debug_channel_propose_queue_depth"].(metricdata.Gauge[int64])
+	require.Equal(t, int64(1), queueDepth.DataPoints[0].Value)
+
+	// 关闭后时间序列被清除
+	c.disable("ch1", 2)
+	require.Len(t, c.list(), 0)
+	data = collectChannelDebug(t, reader)
+	for name, agg := range data {
+		switch v := agg.(type) {
+		case metricdata.Sum[int64]:
+			require.Len(t, v.DataPoints, 0, name)
+		case metricdata.Gauge[int64]:
+			require.Len(t, v.DataPoints, 0, name)
+		}
+	}
+}
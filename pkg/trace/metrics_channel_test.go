@@ -0,0 +1,40 @@
+package trace
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestChannelTopNEvaluate(t *testing.T) {
+	c := newChannelTopN(2, time.Minute)
+	c.add("ch1", 2, 10)
+	c.add("ch2", 2, 30)
+	c.add("ch3", 2, 20)
+	c.add("ch4", 1, 5)
+
+	c.evaluateIfNeed(c.lastEvaluate.Add(time.Minute))
+
+	require.Len(t, c.top, 2)
+	require.Equal(t, "ch2", c.top[0].channelId)
+	require.Equal(t, "ch3", c.top[1].channelId)
+	require.Equal(t, int64(15), c.other)
+	require.Len(t, c.counts, 0)
+}
+
+func TestChannelTopNNotEvaluateInInterval(t *testing.T) {
+	c := newChannelTopN(2, time.Minute)
+	c.add("ch1", 2, 10)
+
+	c.evaluateIfNeed(c.lastEvaluate.Add(time.Second))
+
+	require.Len(t, c.top, 0)
+	require.Len(t, c.counts, 1)
+}
+
+func TestChannelTopNDisabled(t *testing.T) {
+	c := newChannelTopN(0, time.Minute)
+	c.add("ch1", 2, 10)
+	require.Len(t, c.counts, 0)
+}
@@ -0,0 +1,75 @@
+package trace
+
+import (
+	"sync"
+	"time"
+
+	"go.uber.org/atomic"
+)
+
+// ChannelTypeTraffic 某个频道类型的消息流量（字节）
+type ChannelTypeTraffic struct {
+	ChannelType   uint8
+	IncomingBytes int64
+	OutgoingBytes int64
+}
+
+// ChannelTypeTrafficSnapshot 某一时刻所有频道类型的消息流量总量
+type ChannelTypeTrafficSnapshot struct {
+	At            time.Time
+	Traffics      []ChannelTypeTraffic // 只包含有流量的频道类型，按频道类型从小到大排列
+	IncomingBytes int64                // 所有频道类型的入口流量合计
+	OutgoingBytes int64                // 所有频道类型的出口流量合计
+}
+
+// Get 获取某个频道类型的流量，没有流量时返回零值
+func (s ChannelTypeTrafficSnapshot) Get(channelType uint8) ChannelTypeTraffic {
+	for _, traffic := range s.Traffics {
+		if traffic.ChannelType == channelType {
+			return traffic
+		}
+	}
+	return ChannelTypeTraffic{ChannelType: channelType}
+}
+
+// channelTypeTraffic 按频道类型统计的消息流量（影子计数，不上报otel），供自定义导出器读取快照
+// 累加时持有读锁，累加之间互不阻塞；快照时持有写锁，保证快照里所有频道类型的数据是同一时刻的
+type channelTypeTraffic struct {
+	mu       sync.RWMutex
+	incoming [256]atomic.Int64
+	outgoing [256]atomic.Int64
+}
+
+func (c *channelTypeTraffic) incomingAdd(channelType uint8, v int64) {
+	c.mu.RLock()
+	c.incoming[channelType].Add(v)
+	c.mu.RUnlock()
+}
+
+func (c *channelTypeTraffic) outgoingAdd(channelType uint8, v int64) {
+	c.mu.RLock()
+	c.outgoing[channelType].Add(v)
+	c.mu.RUnlock()
+}
+
+func (c *channelTypeTraffic) snapshot() ChannelTypeTrafficSnapshot {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	snapshot := ChannelTypeTrafficSnapshot{At: time.Now()}
+	for i := range c.incoming {
+		incoming := c.incoming[i].Load()
+		outgoing := c.outgoing[i].Load()
+		if incoming == 0 && outgoing == 0 {
+			continue
+		}
+		snapshot.Traffics = append(snapshot.Traffics, ChannelTypeTraffic{
+			ChannelType:   uint8(i),
+			IncomingBytes: incoming,
+			OutgoingBytes: outgoing,
+		})
+		snapshot.IncomingBytes += incoming
+		snapshot.OutgoingBytes += outgoing
+	}
+	return snapshot
+}
@@ -0,0 +1,68 @@
+package trace
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChannelTypeTrafficSnapshot(t *testing.T) {
+	var c channelTypeTraffic
+	c.incomingAdd(1, 100)
+	c.incomingAdd(2, 50)
+	c.outgoingAdd(1, 30)
+	c.incomingAdd(1, 20)
+	c.outgoingAdd(255, 7)
+
+	snapshot := c.snapshot()
+	assert.Equal(t, []ChannelTypeTraffic{
+		{ChannelType: 1, IncomingBytes: 120, OutgoingBytes: 30},
+		{ChannelType: 2, IncomingBytes: 50},
+		{ChannelType: 255, OutgoingBytes: 7},
+	}, snapshot.Traffics)
+	assert.Equal(t, int64(170), snapshot.IncomingBytes)
+	assert.Equal(t, int64(37), snapshot.OutgoingBytes)
+	assert.Equal(t, ChannelTypeTraffic{ChannelType: 3}, snapshot.Get(3))
+	assert.False(t, snapshot.At.IsZero())
+}
+
+// 每次累加同时给两个频道类型加上相同的流量，快照里两个类型的流量必须相等
+func TestChannelTypeTrafficSnapshotConsistent(t *testing.T) {
+	var (
+		c       channelTypeTraffic
+		wg      sync.WaitGroup
+		writers = 8
+		count   = 2000
+	)
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < count; j++ {
+				// 同一次累加里给两个类型加流量
+				c.mu.RLock()
+				c.incoming[1].Add(10)
+				c.incoming[2].Add(10)
+				c.mu.RUnlock()
+			}
+		}()
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	for {
+		snapshot := c.snapshot()
+		assert.Equal(t, snapshot.Get(1).IncomingBytes, snapshot.Get(2).IncomingBytes)
+		select {
+		case <-done:
+			snapshot = c.snapshot()
+			assert.Equal(t, int64(writers*count*10), snapshot.Get(1).IncomingBytes)
+			assert.Equal(t, int64(writers*count*20), snapshot.IncomingBytes)
+			return
+		default:
+		}
+	}
+}
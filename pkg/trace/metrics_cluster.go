@@ -2,22 +2,36 @@ package trace
 
 import (
 	"context"
+	"time"
 
 	"github.com/WuKongIM/WuKongIM/pkg/wklog"
+	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/metric"
 	"go.uber.org/atomic"
 	"go.uber.org/zap"
 )
 
+// proposeCommitSecondsBuckets 提案提交耗时的分桶（秒），IM场景下提案一般在几毫秒到几百毫秒内提交，超过1秒的都算慢
+var proposeCommitSecondsBuckets = []float64{0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+func newProposeCommitSecondsHistogram(m metric.Meter, name string) (metric.Float64Histogram, error) {
+	return m.Float64Histogram(
+		name,
+		metric.WithDescription("The time from a propose being queued to being committed"),
+		metric.WithUnit("s"),
+		metric.WithExplicitBucketBoundaries(proposeCommitSecondsBuckets...),
+	)
+}
+
 type clusterMetrics struct {
 	wklog.Log
 	ctx  context.Context
 	opts *Options
 	// message
-	messageIncomingBytes atomic.Int64
-	messageOutgoingBytes atomic.Int64
-	messageIncomingCount atomic.Int64
-	messageOutgoingCount atomic.Int64
+	messageIncomingBytes kindCounter
+	messageOutgoingBytes kindCounter
+	messageIncomingCount kindCounter
+	messageOutgoingCount kindCounter
 
 	channelMsgIncomingBytes atomic.Int64
 	channelMsgOutgoingBytes atomic.Int64
@@ -32,24 +46,69 @@ type clusterMetrics struct {
 	sendPacketOutgoingBytes atomic.Int64
 	sendPacketOutgoingCount atomic.Int64
 
+	// forward propose
+	forwardProposeBytes     atomic.Int64
+	forwardProposeCount     atomic.Int64
+	forwardProposeRespBytes atomic.Int64
+	forwardProposeRespCount atomic.Int64
+
+	// recvPacket
+	recvPacketIncomingBytes atomic.Int64
+	recvPacketIncomingCount atomic.Int64
+	recvPacketOutgoingBytes atomic.Int64
+	recvPacketOutgoingCount atomic.Int64
+
 	// channel
 	channelActiveCount metric.Int64UpDownCounter
 
+	channelCreateCount         metric.Int64Counter
+	channelCreateRejectedCount metric.Int64Counter
+	channelReapedCount         metric.Int64Counter
+
+	channelElectionCount        metric.Int64Counter
+	channelElectionSuccessCount metric.Int64Counter
+	channelElectionFailCount    metric.Int64Counter
+
+	slotElectionCount        metric.Int64Counter
+	slotElectionSuccessCount metric.Int64Counter
+	slotElectionFailCount    metric.Int64Counter
+
+	writeBytes          metric.Int64Counter
+	writeThrottledCount metric.Int64Counter
+
+	proposeConcurrency  metric.Int64UpDownCounter
+	proposeTooBusyCount metric.Int64Counter
+
+	proposeNotLeaderRetryCount  metric.Int64Counter
+	proposeAckTraceDroppedCount metric.Int64Counter
+
+	channelLeaderChangeCount   metric.Int64Counter
+	channelLeaderFlappingCount metric.Int64Counter
+	channelLeaderFlapping      metric.Int64UpDownCounter
+
+	channelDebug *channelDebug // 频道调试监控
+
+	peerSendQueue *peerSendQueue // 每个节点的发送队列监控
+
+	proposeLogSize *logSizeHistogram // 提案日志数据大小（开启LogSizeMetricsOn时才有）
+
+	channelTypeTraffic channelTypeTraffic // 按频道类型统计的消息流量（影子计数）
+
 	// channel log
-	channelLogIncomingBytes atomic.Int64
-	channelLogIncomingCount atomic.Int64
-	channelLogOutgoingBytes atomic.Int64
-	channelLogOutgoingCount atomic.Int64
+	channelLogIncomingBytes kindCounter
+	channelLogIncomingCount kindCounter
+	channelLogOutgoingBytes kindCounter
+	channelLogOutgoingCount kindCounter
 
 	// msg sync
-	msgSyncIncomingBytes        atomic.Int64
-	msgSyncOutgoingBytes        atomic.Int64
-	msgSyncIncomingCount        atomic.Int64
-	msgSyncOutgoingCount        atomic.Int64
-	msgSyncRespIncomingBytes    atomic.Int64
-	msgSyncRespOutgoingBytes    atomic.Int64
-	msgSyncRespIncomingCount    atomic.Int64
-	msgSyncRespOutgoingCount    atomic.Int64
+	msgSyncIncomingBytes        kindCounter
+	msgSyncOutgoingBytes        kindCounter
+	msgSyncIncomingCount        kindCounter
+	msgSyncOutgoingCount        kindCounter
+	msgSyncRespIncomingBytes    kindCounter
+	msgSyncRespOutgoingBytes    kindCounter
+	msgSyncRespIncomingCount    kindCounter
+	msgSyncRespOutgoingCount    kindCounter
 	channelMsgSyncIncomingCount atomic.Int64
 	channelMsgSyncOutgoingCount atomic.Int64
 	channelMsgSyncIncomingBytes atomic.Int64
@@ -60,16 +119,26 @@ type clusterMetrics struct {
 	slotMsgSyncOutgoingBytes    atomic.Int64
 
 	// cluster ping
-	clusterPingIncomingBytes atomic.Int64
-	clusterPingIncomingCount atomic.Int64
-	clusterPingOutgoingBytes atomic.Int64
-	clusterPingOutgoingCount atomic.Int64
+	clusterPingIncomingBytes kindCounter
+	clusterPingIncomingCount kindCounter
+	clusterPingOutgoingBytes kindCounter
+	clusterPingOutgoingCount kindCounter
 
 	// cluster pong
-	clusterPongIncomingBytes atomic.Int64
-	clusterPongIncomingCount atomic.Int64
-	clusterPongOutgoingBytes atomic.Int64
-	clusterPongOutgoingCount atomic.Int64
+	clusterPongIncomingBytes kindCounter
+	clusterPongIncomingCount kindCounter
+	clusterPongOutgoingBytes kindCounter
+	clusterPongOutgoingCount kindCounter
+
+	// leader term start index
+	leaderTermStartIndexReqIncomingBytes  kindCounter
+	leaderTermStartIndexReqIncomingCount  kindCounter
+	leaderTermStartIndexReqOutgoingBytes  kindCounter
+	leaderTermStartIndexReqOutgoingCount  kindCounter
+	leaderTermStartIndexRespIncomingBytes kindCounter
+	leaderTermStartIndexRespIncomingCount kindCounter
+	leaderTermStartIndexRespOutgoingBytes kindCounter
+	leaderTermStartIndexRespOutgoingCount kindCounter
 
 	// inbound flight
 	inboundFlightMessageCount metric.Int64UpDownCounter
@@ -87,6 +156,35 @@ type clusterMetrics struct {
 	channelProposeLatencyOver500ms  atomic.Int64 // 超过500ms的频道提案
 
 	slotProposeLatency metric.Int64Histogram
+
+	channelProposeCommitSeconds metric.Float64Histogram // 频道提案从进入提案队列到提交的耗时
+	slotProposeCommitSeconds    metric.Float64Histogram // 槽提案从进入提案队列到提交的耗时
+
+	// apply lag
+	channelApplyLag             metric.Int64Histogram
+	slotApplyLag                metric.Int64Histogram
+	channelProposeThrottleCount atomic.Int64 // 因应用落后被限流的频道提案数量
+	slotProposeThrottleCount    atomic.Int64 // 因应用落后被限流的槽提案数量
+
+	// disk
+	diskFreeBytes         atomic.Int64 // 数据目录所在磁盘的剩余空间
+	diskReadOnly          atomic.Int64 // 是否因为磁盘空间不足进入只读（1为只读）
+	diskFullRejectedCount atomic.Int64 // 因为磁盘空间不足被拒绝的提案次数
+
+	// election pause
+	electionPauseUntil atomic.Int64 // 暂停选举到的时间（unix纳秒），0表示没有暂停
+
+	// inbound limit
+	inboundDroppedCount   kindCounter // 连接收到的消息超过限速被丢弃的数量
+	inboundThrottledCount kindCounter // 连接收到的关键消息超过限速，连接被限流的次数
+
+	messageTooLargeDroppedCount kindCounter // 超过单条消息最大字节数被丢弃的消息数量
+
+	proposeResultBackpressureCount kindCounter // 提案结果通知协程池已满，在应用协程里直接通知的次数
+
+	// commit wait
+	channelCommitWaiters    metric.Int64UpDownCounter // 等待提交的频道提案数量
+	channelCommitWaitGapMax atomic.Int64              // 观测周期内等待提交的最大日志下标差距
 }
 
 func newClusterMetrics(opts *Options) IClusterMetrics {
@@ -111,10 +209,10 @@ func newClusterMetrics(opts *Options) IClusterMetrics {
 	messageConcurrency := NewInt64ObservableCounter("cluster_message_concurrency")
 
 	RegisterCallback(func(ctx context.Context, obs metric.Observer) error {
-		obs.ObserveInt64(msgIncomingBytes, c.messageIncomingBytes.Load())
-		obs.ObserveInt64(msgOutgoingBytes, c.messageOutgoingBytes.Load())
-		obs.ObserveInt64(msgIncomingCount, c.messageIncomingCount.Load())
-		obs.ObserveInt64(msgOutgoingCount, c.messageOutgoingCount.Load())
+		c.messageIncomingBytes.observe(obs, msgIncomingBytes)
+		c.messageOutgoingBytes.observe(obs, msgOutgoingBytes)
+		c.messageIncomingCount.observe(obs, msgIncomingCount)
+		c.messageOutgoingCount.observe(obs, msgOutgoingCount)
 		obs.ObserveInt64(messageConcurrency, c.messageConcurrency.Load())
 		obs.ObserveInt64(channelMsgIncomingBytes, c.channelMsgIncomingBytes.Load())
 		obs.ObserveInt64(channelMsgOutgoingBytes, c.channelMsgOutgoingBytes.Load())
@@ -135,6 +233,32 @@ func newClusterMetrics(opts *Options) IClusterMetrics {
 		obs.ObserveInt64(sendPacketOutgoingCount, c.sendPacketOutgoingCount.Load())
 		return nil
 	}, sendPacketIncomingBytes, sendPacketIncomingCount, sendPacketOutgoingBytes, sendPacketOutgoingCount)
+
+	// recvpacket
+	recvPacketIncomingBytes := NewInt64ObservableCounter("cluster_recvpacket_incoming_bytes")
+	recvPacketIncomingCount := NewInt64ObservableCounter("cluster_recvpacket_incoming_count")
+	recvPacketOutgoingBytes := NewInt64ObservableCounter("cluster_recvpacket_outgoing_bytes")
+	recvPacketOutgoingCount := NewInt64ObservableCounter("cluster_recvpacket_outgoing_count")
+	RegisterCallback(func(ctx context.Context, obs metric.Observer) error {
+		obs.ObserveInt64(recvPacketIncomingBytes, c.recvPacketIncomingBytes.Load())
+		obs.ObserveInt64(recvPacketIncomingCount, c.recvPacketIncomingCount.Load())
+		obs.ObserveInt64(recvPacketOutgoingBytes, c.recvPacketOutgoingBytes.Load())
+		obs.ObserveInt64(recvPacketOutgoingCount, c.recvPacketOutgoingCount.Load())
+		return nil
+	}, recvPacketIncomingBytes, recvPacketIncomingCount, recvPacketOutgoingBytes, recvPacketOutgoingCount)
+
+	// forward propose
+	forwardProposeBytes := NewInt64ObservableCounter("cluster_forward_propose_bytes")
+	forwardProposeCount := NewInt64ObservableCounter("cluster_forward_propose_count")
+	forwardProposeRespBytes := NewInt64ObservableCounter("cluster_forward_propose_resp_bytes")
+	forwardProposeRespCount := NewInt64ObservableCounter("cluster_forward_propose_resp_count")
+	RegisterCallback(func(ctx context.Context, obs metric.Observer) error {
+		obs.ObserveInt64(forwardProposeBytes, c.forwardProposeBytes.Load())
+		obs.ObserveInt64(forwardProposeCount, c.forwardProposeCount.Load())
+		obs.ObserveInt64(forwardProposeRespBytes, c.forwardProposeRespBytes.Load())
+		obs.ObserveInt64(forwardProposeRespCount, c.forwardProposeRespCount.Load())
+		return nil
+	}, forwardProposeBytes, forwardProposeCount, forwardProposeRespBytes, forwardProposeRespCount)
 	// channel log
 	channelLogIncomingBytes := NewInt64ObservableCounter("cluster_channel_log_incoming_bytes")
 	channelLogIncomingCount := NewInt64ObservableCounter("cluster_channel_log_incoming_count")
@@ -142,11 +266,35 @@ func newClusterMetrics(opts *Options) IClusterMetrics {
 	channelLogOutgoingCount := NewInt64ObservableCounter("cluster_channel_log_outgoing_count")
 
 	c.channelActiveCount = NewInt64UpDownCounter("cluster_channel_active_count")
+	c.channelCreateCount = NewInt64Counter("cluster_channel_create_count")
+	c.channelCreateRejectedCount = NewInt64Counter("cluster_channel_create_rejected_count")
+	c.channelReapedCount = NewInt64Counter("cluster_channel_reaped_count")
+	c.channelCommitWaiters = NewInt64UpDownCounter("cluster_channel_commit_waiters")
+	c.channelElectionCount = NewInt64Counter("cluster_channel_election_count")
+	c.channelElectionSuccessCount = NewInt64Counter("cluster_channel_election_success_count")
+	c.channelElectionFailCount = NewInt64Counter("cluster_channel_election_fail_count")
+	c.slotElectionCount = NewInt64Counter("cluster_slot_election_count")
+	c.slotElectionSuccessCount = NewInt64Counter("cluster_slot_election_success_count")
+	c.slotElectionFailCount = NewInt64Counter("cluster_slot_election_fail_count")
+	c.writeBytes = NewInt64Counter("cluster_write_bytes")
+	c.writeThrottledCount = NewInt64Counter("cluster_write_throttled_count")
+	c.proposeConcurrency = NewInt64UpDownCounter("cluster_propose_concurrency")
+	c.proposeTooBusyCount = NewInt64Counter("cluster_propose_too_busy_count")
+	c.proposeNotLeaderRetryCount = NewInt64Counter("cluster_propose_not_leader_retry_count")
+	c.proposeAckTraceDroppedCount = NewInt64Counter("cluster_propose_ack_trace_dropped_count")
+	c.channelLeaderChangeCount = NewInt64Counter("cluster_channel_leader_change_count")
+	c.channelLeaderFlappingCount = NewInt64Counter("cluster_channel_leader_flapping_count")
+	c.channelLeaderFlapping = NewInt64UpDownCounter("cluster_channel_leader_flapping")
+	c.channelDebug = newChannelDebug(meter)
+	c.peerSendQueue = newPeerSendQueue(meter)
+	if opts.LogSizeMetricsOn {
+		c.proposeLogSize = newLogSizeHistogram(meter)
+	}
 	RegisterCallback(func(ctx context.Context, obs metric.Observer) error {
-		obs.ObserveInt64(channelLogIncomingBytes, c.channelLogIncomingBytes.Load())
-		obs.ObserveInt64(channelLogIncomingCount, c.channelLogIncomingCount.Load())
-		obs.ObserveInt64(channelLogOutgoingBytes, c.channelLogOutgoingBytes.Load())
-		obs.ObserveInt64(channelLogOutgoingCount, c.channelLogOutgoingCount.Load())
+		c.channelLogIncomingBytes.observe(obs, channelLogIncomingBytes)
+		c.channelLogIncomingCount.observe(obs, channelLogIncomingCount)
+		c.channelLogOutgoingBytes.observe(obs, channelLogOutgoingBytes)
+		c.channelLogOutgoingCount.observe(obs, channelLogOutgoingCount)
 		return nil
 	}, channelLogIncomingBytes, channelLogIncomingCount, channelLogOutgoingBytes, channelLogOutgoingCount)
 
@@ -169,14 +317,14 @@ func newClusterMetrics(opts *Options) IClusterMetrics {
 	slotMsgSyncOutgoingCount := NewInt64ObservableCounter("cluster_slot_msg_sync_outgoing_count")
 
 	RegisterCallback(func(ctx context.Context, obs metric.Observer) error {
-		obs.ObserveInt64(msgSyncIncomingBytes, c.msgSyncIncomingBytes.Load())
-		obs.ObserveInt64(msgSyncOutgoingBytes, c.msgSyncOutgoingBytes.Load())
-		obs.ObserveInt64(msgSyncIncomingCount, c.msgSyncIncomingCount.Load())
-		obs.ObserveInt64(msgSyncOutgoingCount, c.msgSyncOutgoingCount.Load())
-		obs.ObserveInt64(msgSyncRespIncomingBytes, c.msgSyncRespIncomingBytes.Load())
-		obs.ObserveInt64(msgSyncRespOutgoingBytes, c.msgSyncRespOutgoingBytes.Load())
-		obs.ObserveInt64(msgSyncRespIncomingCount, c.msgSyncRespIncomingCount.Load())
-		obs.ObserveInt64(msgSyncRespOutgoingCount, c.msgSyncRespOutgoingCount.Load())
+		c.msgSyncIncomingBytes.observe(obs, msgSyncIncomingBytes)
+		c.msgSyncOutgoingBytes.observe(obs, msgSyncOutgoingBytes)
+		c.msgSyncIncomingCount.observe(obs, msgSyncIncomingCount)
+		c.msgSyncOutgoingCount.observe(obs, msgSyncOutgoingCount)
+		c.msgSyncRespIncomingBytes.observe(obs, msgSyncRespIncomingBytes)
+		c.msgSyncRespOutgoingBytes.observe(obs, msgSyncRespOutgoingBytes)
+		c.msgSyncRespIncomingCount.observe(obs, msgSyncRespIncomingCount)
+		c.msgSyncRespOutgoingCount.observe(obs, msgSyncRespOutgoingCount)
 		obs.ObserveInt64(channelMsgSyncIncomingBytes, c.channelMsgSyncIncomingBytes.Load())
 		obs.ObserveInt64(channelMsgSyncOutgoingBytes, c.channelMsgSyncOutgoingBytes.Load())
 		obs.ObserveInt64(channelMsgSyncIncomingCount, c.channelMsgSyncIncomingCount.Load())
@@ -195,10 +343,10 @@ func newClusterMetrics(opts *Options) IClusterMetrics {
 	clusterPingOutgoingCount := NewInt64ObservableCounter("cluster_msg_ping_outgoing_count")
 
 	RegisterCallback(func(ctx context.Context, obs metric.Observer) error {
-		obs.ObserveInt64(clusterPingIncomingBytes, c.clusterPingIncomingBytes.Load())
-		obs.ObserveInt64(clusterPingIncomingCount, c.clusterPingIncomingCount.Load())
-		obs.ObserveInt64(clusterPingOutgoingBytes, c.clusterPingOutgoingBytes.Load())
-		obs.ObserveInt64(clusterPingOutgoingCount, c.clusterPingOutgoingCount.Load())
+		c.clusterPingIncomingBytes.observe(obs, clusterPingIncomingBytes)
+		c.clusterPingIncomingCount.observe(obs, clusterPingIncomingCount)
+		c.clusterPingOutgoingBytes.observe(obs, clusterPingOutgoingBytes)
+		c.clusterPingOutgoingCount.observe(obs, clusterPingOutgoingCount)
 		return nil
 	}, clusterPingIncomingBytes, clusterPingIncomingCount, clusterPingOutgoingBytes, clusterPingOutgoingCount)
 
@@ -209,14 +357,36 @@ func newClusterMetrics(opts *Options) IClusterMetrics {
 	clusterPongOutgoingCount := NewInt64ObservableCounter("cluster_msg_pong_outgoing_count")
 
 	RegisterCallback(func(ctx context.Context, obs metric.Observer) error {
-		obs.ObserveInt64(clusterPongIncomingBytes, c.clusterPongIncomingBytes.Load())
-		obs.ObserveInt64(clusterPongIncomingCount, c.clusterPongIncomingCount.Load())
-		obs.ObserveInt64(clusterPongOutgoingBytes, c.clusterPongOutgoingBytes.Load())
-		obs.ObserveInt64(clusterPongOutgoingCount, c.clusterPongOutgoingCount.Load())
+		c.clusterPongIncomingBytes.observe(obs, clusterPongIncomingBytes)
+		c.clusterPongIncomingCount.observe(obs, clusterPongIncomingCount)
+		c.clusterPongOutgoingBytes.observe(obs, clusterPongOutgoingBytes)
+		c.clusterPongOutgoingCount.observe(obs, clusterPongOutgoingCount)
 
 		return nil
 	}, clusterPongIncomingBytes, clusterPongIncomingCount, clusterPongOutgoingBytes, clusterPongOutgoingCount)
 
+	// leader term start index
+	leaderTermStartIndexReqIncomingBytes := NewInt64ObservableCounter("cluster_msg_leader_term_start_index_req_incoming_bytes")
+	leaderTermStartIndexReqIncomingCount := NewInt64ObservableCounter("cluster_msg_leader_term_start_index_req_incoming_count")
+	leaderTermStartIndexReqOutgoingBytes := NewInt64ObservableCounter("cluster_msg_leader_term_start_index_req_outgoing_bytes")
+	leaderTermStartIndexReqOutgoingCount := NewInt64ObservableCounter("cluster_msg_leader_term_start_index_req_outgoing_count")
+	leaderTermStartIndexRespIncomingBytes := NewInt64ObservableCounter("cluster_msg_leader_term_start_index_resp_incoming_bytes")
+	leaderTermStartIndexRespIncomingCount := NewInt64ObservableCounter("cluster_msg_leader_term_start_index_resp_incoming_count")
+	leaderTermStartIndexRespOutgoingBytes := NewInt64ObservableCounter("cluster_msg_leader_term_start_index_resp_outgoing_bytes")
+	leaderTermStartIndexRespOutgoingCount := NewInt64ObservableCounter("cluster_msg_leader_term_start_index_resp_outgoing_count")
+
+	RegisterCallback(func(ctx context.Context, obs metric.Observer) error {
+		c.leaderTermStartIndexReqIncomingBytes.observe(obs, leaderTermStartIndexReqIncomingBytes)
+		c.leaderTermStartIndexReqIncomingCount.observe(obs, leaderTermStartIndexReqIncomingCount)
+		c.leaderTermStartIndexReqOutgoingBytes.observe(obs, leaderTermStartIndexReqOutgoingBytes)
+		c.leaderTermStartIndexReqOutgoingCount.observe(obs, leaderTermStartIndexReqOutgoingCount)
+		c.leaderTermStartIndexRespIncomingBytes.observe(obs, leaderTermStartIndexRespIncomingBytes)
+		c.leaderTermStartIndexRespIncomingCount.observe(obs, leaderTermStartIndexRespIncomingCount)
+		c.leaderTermStartIndexRespOutgoingBytes.observe(obs, leaderTermStartIndexRespOutgoingBytes)
+		c.leaderTermStartIndexRespOutgoingCount.observe(obs, leaderTermStartIndexRespOutgoingCount)
+		return nil
+	}, leaderTermStartIndexReqIncomingBytes, leaderTermStartIndexReqIncomingCount, leaderTermStartIndexReqOutgoingBytes, leaderTermStartIndexReqOutgoingCount, leaderTermStartIndexRespIncomingBytes, leaderTermStartIndexRespIncomingCount, leaderTermStartIndexRespOutgoingBytes, leaderTermStartIndexRespOutgoingCount)
+
 	var err error
 	// propose
 	c.channelProposeLatency, err = meter.Int64Histogram(
@@ -232,6 +402,14 @@ func newClusterMetrics(opts *Options) IClusterMetrics {
 		c.Panic("cluster_slot_propose_latency error", zap.Error(err))
 
 	}
+	c.channelProposeCommitSeconds, err = newProposeCommitSecondsHistogram(meter, "cluster_channel_propose_commit_seconds")
+	if err != nil {
+		c.Panic("cluster_channel_propose_commit_seconds error", zap.Error(err))
+	}
+	c.slotProposeCommitSeconds, err = newProposeCommitSecondsHistogram(meter, "cluster_slot_propose_commit_seconds")
+	if err != nil {
+		c.Panic("cluster_slot_propose_commit_seconds error", zap.Error(err))
+	}
 	channelProposeCount := NewInt64ObservableCounter("cluster_channel_propose_count")
 	channelProposeFailedCount := NewInt64ObservableCounter("cluster_channel_propose_failed_count")
 	channelProposeLatencyOver500ms := NewInt64ObservableCounter("cluster_channel_propose_latency_over_500ms")
@@ -244,32 +422,108 @@ func newClusterMetrics(opts *Options) IClusterMetrics {
 		return nil
 	}, channelProposeCount, channelProposeFailedCount, channelProposeLatencyUnder500ms, channelProposeLatencyOver500ms)
 
+	// apply lag
+	c.channelApplyLag, err = meter.Int64Histogram(
+		"cluster_channel_apply_lag",
+	)
+	if err != nil {
+		c.Panic("cluster_channel_apply_lag error", zap.Error(err))
+	}
+	c.slotApplyLag, err = meter.Int64Histogram(
+		"cluster_slot_apply_lag",
+	)
+	if err != nil {
+		c.Panic("cluster_slot_apply_lag error", zap.Error(err))
+	}
+	channelProposeThrottleCount := NewInt64ObservableCounter("cluster_channel_propose_throttle_count")
+	slotProposeThrottleCount := NewInt64ObservableCounter("cluster_slot_propose_throttle_count")
+	RegisterCallback(func(ctx context.Context, obs metric.Observer) error {
+		obs.ObserveInt64(channelProposeThrottleCount, c.channelProposeThrottleCount.Load())
+		obs.ObserveInt64(slotProposeThrottleCount, c.slotProposeThrottleCount.Load())
+		return nil
+	}, channelProposeThrottleCount, slotProposeThrottleCount)
+
+	// disk
+	diskFreeBytes := NewInt64ObservableGauge("cluster_disk_free_bytes")
+	diskReadOnly := NewInt64ObservableGauge("cluster_disk_read_only")
+	diskFullRejectedCount := NewInt64ObservableCounter("cluster_disk_full_rejected_count")
+	RegisterCallback(func(ctx context.Context, obs metric.Observer) error {
+		obs.ObserveInt64(diskFreeBytes, c.diskFreeBytes.Load())
+		obs.ObserveInt64(diskReadOnly, c.diskReadOnly.Load())
+		obs.ObserveInt64(diskFullRejectedCount, c.diskFullRejectedCount.Load())
+		return nil
+	}, diskFreeBytes, diskReadOnly, diskFullRejectedCount)
+
+	// election pause
+	electionPaused := NewInt64ObservableGauge("cluster_election_paused")
+	electionPauseRemaining := NewFloat64ObservableGauge("cluster_election_pause_remaining_seconds")
+	RegisterCallback(func(ctx context.Context, obs metric.Observer) error {
+		remaining := time.Until(time.Unix(0, c.electionPauseUntil.Load()))
+		if c.electionPauseUntil.Load() == 0 || remaining < 0 {
+			remaining = 0
+		}
+		var paused int64
+		if remaining > 0 {
+			paused = 1
+		}
+		obs.ObserveInt64(electionPaused, paused)
+		obs.ObserveFloat64(electionPauseRemaining, remaining.Seconds())
+		return nil
+	}, electionPaused, electionPauseRemaining)
+
+	// inbound limit
+	inboundDroppedCount := NewInt64ObservableCounter("cluster_inbound_dropped_count")
+	inboundThrottledCount := NewInt64ObservableCounter("cluster_inbound_throttled_count")
+	RegisterCallback(func(ctx context.Context, obs metric.Observer) error {
+		c.inboundDroppedCount.observe(obs, inboundDroppedCount)
+		c.inboundThrottledCount.observe(obs, inboundThrottledCount)
+		return nil
+	}, inboundDroppedCount, inboundThrottledCount)
+
+	messageTooLargeDroppedCount := NewInt64ObservableCounter("cluster_message_too_large_dropped_count")
+	RegisterCallback(func(ctx context.Context, obs metric.Observer) error {
+		c.messageTooLargeDroppedCount.observe(obs, messageTooLargeDroppedCount)
+		return nil
+	}, messageTooLargeDroppedCount)
+
+	proposeResultBackpressureCount := NewInt64ObservableCounter("cluster_propose_result_backpressure_count")
+	RegisterCallback(func(ctx context.Context, obs metric.Observer) error {
+		c.proposeResultBackpressureCount.observe(obs, proposeResultBackpressureCount)
+		return nil
+	}, proposeResultBackpressureCount)
+
+	channelCommitWaitGap := NewInt64ObservableGauge("cluster_channel_commit_wait_gap")
+	RegisterCallback(func(ctx context.Context, obs metric.Observer) error {
+		obs.ObserveInt64(channelCommitWaitGap, c.channelCommitWaitGapMax.Swap(0))
+		return nil
+	}, channelCommitWaitGap)
+
 	return c
 }
 
 func (c *clusterMetrics) MessageIncomingBytesAdd(kind ClusterKind, v int64) {
-	c.messageIncomingBytes.Add(v)
+	c.messageIncomingBytes.add(kind, v)
 	switch kind {
 	case ClusterKindChannel:
 		c.channelMsgIncomingBytes.Add(v)
 	}
 }
 func (c *clusterMetrics) MessageOutgoingBytesAdd(kind ClusterKind, v int64) {
-	c.messageOutgoingBytes.Add(v)
+	c.messageOutgoingBytes.add(kind, v)
 	switch kind {
 	case ClusterKindChannel:
 		c.channelMsgOutgoingBytes.Add(v)
 	}
 }
 func (c *clusterMetrics) MessageIncomingCountAdd(kind ClusterKind, v int64) {
-	c.messageIncomingCount.Add(v)
+	c.messageIncomingCount.add(kind, v)
 	switch kind {
 	case ClusterKindChannel:
 		c.channelMsgIncomingCount.Add(v)
 	}
 }
 func (c *clusterMetrics) MessageOutgoingCountAdd(kind ClusterKind, v int64) {
-	c.messageOutgoingCount.Add(v)
+	c.messageOutgoingCount.add(kind, v)
 	switch kind {
 	case ClusterKindChannel:
 		c.channelMsgOutgoingCount.Add(v)
@@ -297,54 +551,54 @@ func (c *clusterMetrics) SendPacketOutgoingCountAdd(v int64) {
 }
 
 func (c *clusterMetrics) RecvPacketIncomingBytesAdd(v int64) {
-
+	c.recvPacketIncomingBytes.Add(v)
 }
 
 func (c *clusterMetrics) RecvPacketOutgoingBytesAdd(v int64) {
-
+	c.recvPacketOutgoingBytes.Add(v)
 }
 
 func (c *clusterMetrics) RecvPacketIncomingCountAdd(v int64) {
-
+	c.recvPacketIncomingCount.Add(v)
 }
 
 func (c *clusterMetrics) RecvPacketOutgoingCountAdd(v int64) {
-
+	c.recvPacketOutgoingCount.Add(v)
 }
 
 func (c *clusterMetrics) MsgClusterPongIncomingBytesAdd(kind ClusterKind, v int64) {
-	c.clusterPongIncomingBytes.Add(v)
+	c.clusterPongIncomingBytes.add(kind, v)
 
 }
 func (c *clusterMetrics) MsgClusterPongIncomingCountAdd(kind ClusterKind, v int64) {
-	c.clusterPongIncomingCount.Add(v)
+	c.clusterPongIncomingCount.add(kind, v)
 }
 
 func (c *clusterMetrics) MsgClusterPongOutgoingBytesAdd(kind ClusterKind, v int64) {
-	c.clusterPongOutgoingBytes.Add(v)
+	c.clusterPongOutgoingBytes.add(kind, v)
 }
 func (c *clusterMetrics) MsgClusterPongOutgoingCountAdd(kind ClusterKind, v int64) {
-	c.clusterPongOutgoingCount.Add(v)
+	c.clusterPongOutgoingCount.add(kind, v)
 }
 
 func (c *clusterMetrics) MsgClusterPingIncomingBytesAdd(kind ClusterKind, v int64) {
-	c.clusterPingIncomingBytes.Add(v)
+	c.clusterPingIncomingBytes.add(kind, v)
 
 }
 func (c *clusterMetrics) MsgClusterPingIncomingCountAdd(kind ClusterKind, v int64) {
-	c.clusterPingIncomingCount.Add(v)
+	c.clusterPingIncomingCount.add(kind, v)
 }
 
 func (c *clusterMetrics) MsgClusterPingOutgoingBytesAdd(kind ClusterKind, v int64) {
-	c.clusterPingOutgoingBytes.Add(v)
+	c.clusterPingOutgoingBytes.add(kind, v)
 }
 
 func (c *clusterMetrics) MsgClusterPingOutgoingCountAdd(kind ClusterKind, v int64) {
-	c.clusterPingOutgoingCount.Add(v)
+	c.clusterPingOutgoingCount.add(kind, v)
 }
 
 func (c *clusterMetrics) MsgSyncIncomingBytesAdd(kind ClusterKind, v int64) {
-	c.msgSyncIncomingBytes.Add(v)
+	c.msgSyncIncomingBytes.add(kind, v)
 
 	switch kind {
 	case ClusterKindChannel:
@@ -355,7 +609,7 @@ func (c *clusterMetrics) MsgSyncIncomingBytesAdd(kind ClusterKind, v int64) {
 }
 
 func (c *clusterMetrics) MsgSyncOutgoingBytesAdd(kind ClusterKind, v int64) {
-	c.msgSyncOutgoingBytes.Add(v)
+	c.msgSyncOutgoingBytes.add(kind, v)
 
 	switch kind {
 	case ClusterKindChannel:
@@ -366,7 +620,7 @@ func (c *clusterMetrics) MsgSyncOutgoingBytesAdd(kind ClusterKind, v int64) {
 }
 
 func (c *clusterMetrics) MsgSyncIncomingCountAdd(kind ClusterKind, v int64) {
-	c.msgSyncIncomingCount.Add(v)
+	c.msgSyncIncomingCount.add(kind, v)
 
 	switch kind {
 	case ClusterKindChannel:
@@ -377,7 +631,7 @@ func (c *clusterMetrics) MsgSyncIncomingCountAdd(kind ClusterKind, v int64) {
 }
 
 func (c *clusterMetrics) MsgSyncOutgoingCountAdd(kind ClusterKind, v int64) {
-	c.msgSyncOutgoingCount.Add(v)
+	c.msgSyncOutgoingCount.add(kind, v)
 
 	switch kind {
 	case ClusterKindChannel:
@@ -388,80 +642,80 @@ func (c *clusterMetrics) MsgSyncOutgoingCountAdd(kind ClusterKind, v int64) {
 }
 
 func (c *clusterMetrics) MsgSyncRespIncomingBytesAdd(kind ClusterKind, v int64) {
-	c.msgSyncRespIncomingBytes.Add(v)
+	c.msgSyncRespIncomingBytes.add(kind, v)
 }
 func (c *clusterMetrics) MsgSyncRespIncomingCountAdd(kind ClusterKind, v int64) {
-	c.msgSyncRespIncomingCount.Add(v)
+	c.msgSyncRespIncomingCount.add(kind, v)
 }
 
 func (c *clusterMetrics) MsgSyncRespOutgoingBytesAdd(kind ClusterKind, v int64) {
-	c.msgSyncRespOutgoingBytes.Add(v)
+	c.msgSyncRespOutgoingBytes.add(kind, v)
 }
 func (c *clusterMetrics) MsgSyncRespOutgoingCountAdd(kind ClusterKind, v int64) {
-	c.msgSyncRespOutgoingCount.Add(v)
+	c.msgSyncRespOutgoingCount.add(kind, v)
 }
 
 func (c *clusterMetrics) LogIncomingBytesAdd(kind ClusterKind, v int64) {
-	c.channelLogIncomingBytes.Add(v)
+	c.channelLogIncomingBytes.add(kind, v)
 }
 
 func (c *clusterMetrics) LogIncomingCountAdd(kind ClusterKind, v int64) {
-	c.channelLogIncomingCount.Add(v)
+	c.channelLogIncomingCount.add(kind, v)
 }
 
 func (c *clusterMetrics) LogOutgoingBytesAdd(kind ClusterKind, v int64) {
-	c.channelLogOutgoingBytes.Add(v)
+	c.channelLogOutgoingBytes.add(kind, v)
 }
 
 func (c *clusterMetrics) LogOutgoingCountAdd(kind ClusterKind, v int64) {
-	c.channelLogOutgoingCount.Add(v)
+	c.channelLogOutgoingCount.add(kind, v)
 }
 
 func (c *clusterMetrics) MsgLeaderTermStartIndexReqIncomingBytesAdd(kind ClusterKind, v int64) {
-
+	c.leaderTermStartIndexReqIncomingBytes.add(kind, v)
 }
 
 func (c *clusterMetrics) MsgLeaderTermStartIndexReqIncomingCountAdd(kind ClusterKind, v int64) {
-
+	c.leaderTermStartIndexReqIncomingCount.add(kind, v)
 }
 
 func (c *clusterMetrics) MsgLeaderTermStartIndexReqOutgoingBytesAdd(kind ClusterKind, v int64) {
-
+	c.leaderTermStartIndexReqOutgoingBytes.add(kind, v)
 }
 
 func (c *clusterMetrics) MsgLeaderTermStartIndexReqOutgoingCountAdd(kind ClusterKind, v int64) {
-
+	c.leaderTermStartIndexReqOutgoingCount.add(kind, v)
 }
 
 func (c *clusterMetrics) MsgLeaderTermStartIndexRespIncomingBytesAdd(kind ClusterKind, v int64) {
-
+	c.leaderTermStartIndexRespIncomingBytes.add(kind, v)
 }
 
 func (c *clusterMetrics) MsgLeaderTermStartIndexRespIncomingCountAdd(kind ClusterKind, v int64) {
-
+	c.leaderTermStartIndexRespIncomingCount.add(kind, v)
 }
 
 func (c *clusterMetrics) MsgLeaderTermStartIndexRespOutgoingBytesAdd(kind ClusterKind, v int64) {
-
+	c.leaderTermStartIndexRespOutgoingBytes.add(kind, v)
 }
 
 func (c *clusterMetrics) MsgLeaderTermStartIndexRespOutgoingCountAdd(kind ClusterKind, v int64) {
-
+	c.leaderTermStartIndexRespOutgoingCount.add(kind, v)
 }
 func (c *clusterMetrics) ForwardProposeBytesAdd(v int64) {
-
+	c.forwardProposeBytes.Add(v)
 }
 
 func (c *clusterMetrics) ForwardProposeCountAdd(v int64) {
-
+	c.forwardProposeCount.Add(v)
 }
 
 func (c *clusterMetrics) ForwardProposeRespBytesAdd(v int64) {
-
+	c.forwardProposeRespBytes.Add(v)
 }
 
 func (c *clusterMetrics) ForwardProposeRespCountAdd(v int64) {
-
+	c.forwardProposeRespCount.Add(v)
 }
 
 func (c *clusterMetrics) ForwardConnPingBytesAdd(v int64) {
@@ -484,28 +738,127 @@ func (c *clusterMetrics) ChannelActiveCountAdd(v int64) {
 	c.channelActiveCount.Add(c.ctx, v)
 }
 
-func (c *clusterMetrics) ChannelElectionCountAdd(v int64) {
+func (c *clusterMetrics) ChannelCreateCountAdd(v int64) {
+	c.channelCreateCount.Add(c.ctx, v)
+}
 
+func (c *clusterMetrics) ChannelCreateRejectedCountAdd(v int64) {
+	c.channelCreateRejectedCount.Add(c.ctx, v)
 }
 
-func (c *clusterMetrics) ChannelElectionSuccessCountAdd(v int64) {
+func (c *clusterMetrics) ChannelReapedCountAdd(v int64) {
+	c.channelReapedCount.Add(c.ctx, v)
+}
 
+func (c *clusterMetrics) WriteBytesAdd(v int64) {
+	c.writeBytes.Add(c.ctx, v)
 }
 
-func (c *clusterMetrics) ChannelElectionFailCountAdd(v int64) {
+func (c *clusterMetrics) WriteThrottledCountAdd(v int64) {
+	c.writeThrottledCount.Add(c.ctx, v)
+}
 
+func (c *clusterMetrics) ProposeConcurrencyAdd(v int64) {
+	c.proposeConcurrency.Add(c.ctx, v)
 }
 
-func (c *clusterMetrics) SlotElectionCountAdd(v int64) {
+func (c *clusterMetrics) ProposeTooBusyCountAdd(v int64) {
+	c.proposeTooBusyCount.Add(c.ctx, v)
+}
 
+func (c *clusterMetrics) ChannelDebugOn(channelId string, channelType uint8) {
+	c.channelDebug.enable(channelId, channelType)
 }
 
-func (c *clusterMetrics) SlotElectionSuccessCountAdd(v int64) {
+func (c *clusterMetrics) ChannelDebugOff(channelId string, channelType uint8) {
+	c.channelDebug.disable(channelId, channelType)
+}
 
+func (c *clusterMetrics) IsChannelDebug(channelId string, channelType uint8) bool {
+	return c.channelDebug.stat(channelId, channelType) != nil
 }
 
-func (c *clusterMetrics) SlotElectionFailCountAdd(v int64) {
+func (c *clusterMetrics) ChannelDebugChannels() []DebugChannel {
+	return c.channelDebug.list()
+}
 
+func (c *clusterMetrics) ChannelDebugProposeCommitted(channelId string, channelType uint8, count int64, latency time.Duration) {
+	c.channelDebug.proposeCommitted(channelId, channelType, count, latency)
+}
+
+func (c *clusterMetrics) ChannelDebugProposeQueueDepthAdd(channelId string, channelType uint8, v int64) {
+	c.channelDebug.proposeQueueDepthAdd(channelId, channelType, v)
+}
+
+func (c *clusterMetrics) ChannelDebugApplyLagSet(channelId string, channelType uint8, v int64) {
+	c.channelDebug.applyLagSet(channelId, channelType, v)
+}
+
+func (c *clusterMetrics) ProposeNotLeaderRetryCountAdd(v int64) {
+	c.proposeNotLeaderRetryCount.Add(c.ctx, v)
+}
+
+func (c *clusterMetrics) ProposeAckTraceDroppedCountAdd(v int64) {
+	c.proposeAckTraceDroppedCount.Add(c.ctx, v)
+}
+
+func (c *clusterMetrics) ChannelLeaderChangeCountAdd(v int64) {
+	c.channelLeaderChangeCount.Add(c.ctx, v)
+}
+
+func (c *clusterMetrics) ChannelLeaderFlappingCountAdd(v int64) {
+	c.channelLeaderFlappingCount.Add(c.ctx, v)
+}
+
+func (c *clusterMetrics) ChannelLeaderFlappingAdd(v int64) {
+	c.channelLeaderFlapping.Add(c.ctx, v)
+}
+
+func (c *clusterMetrics) PeerSendQueueSource(f func() []PeerSendQueueStat) {
+	c.peerSendQueue.setSource(f)
+}
+
+func (c *clusterMetrics) ProposeLogSizeRecord(channelType uint8, size int64) {
+	if c.proposeLogSize == nil {
+		return
+	}
+	c.proposeLogSize.record(c.ctx, channelType, size)
+}
+
+func (c *clusterMetrics) ChannelTypeMessageIncomingBytesAdd(channelType uint8, v int64) {
+	c.channelTypeTraffic.incomingAdd(channelType, v)
+}
+
+func (c *clusterMetrics) ChannelTypeMessageOutgoingBytesAdd(channelType uint8, v int64) {
+	c.channelTypeTraffic.outgoingAdd(channelType, v)
+}
+
+func (c *clusterMetrics) ChannelTypeTrafficSnapshot() ChannelTypeTrafficSnapshot {
+	return c.channelTypeTraffic.snapshot()
+}
+
+func (c *clusterMetrics) ChannelElectionCountAdd(channelType uint8, v int64) {
+	c.channelElectionCount.Add(c.ctx, v, metric.WithAttributes(attribute.Int("channelType", int(channelType))))
+}
+
+func (c *clusterMetrics) ChannelElectionSuccessCountAdd(channelType uint8, v int64) {
+	c.channelElectionSuccessCount.Add(c.ctx, v, metric.WithAttributes(attribute.Int("channelType", int(channelType))))
+}
+
+func (c *clusterMetrics) ChannelElectionFailCountAdd(channelType uint8, v int64) {
+	c.channelElectionFailCount.Add(c.ctx, v, metric.WithAttributes(attribute.Int("channelType", int(channelType))))
+}
+
+func (c *clusterMetrics) SlotElectionCountAdd(v int64) {
+	c.slotElectionCount.Add(c.ctx, v)
+}
+
+func (c *clusterMetrics) SlotElectionSuccessCountAdd(v int64) {
+	c.slotElectionSuccessCount.Add(c.ctx, v)
+}
+
+func (c *clusterMetrics) SlotElectionFailCountAdd(v int64) {
+	c.slotElectionFailCount.Add(c.ctx, v)
 }
 
 func (c *clusterMetrics) ProposeLatencyAdd(kind ClusterKind, v int64) {
@@ -523,6 +876,15 @@ func (c *clusterMetrics) ProposeLatencyAdd(kind ClusterKind, v int64) {
 	}
 }
 
+func (c *clusterMetrics) ObserveProposeCommitLatency(kind ClusterKind, seconds float64) {
+	switch kind {
+	case ClusterKindChannel:
+		c.channelProposeCommitSeconds.Record(c.ctx, seconds)
+	case ClusterKindSlot:
+		c.slotProposeCommitSeconds.Record(c.ctx, seconds)
+	}
+}
+
 func (c *clusterMetrics) ProposeFailedCountAdd(kind ClusterKind, v int64) {
 	switch kind {
 	case ClusterKindChannel:
@@ -530,3 +892,105 @@ func (c *clusterMetrics) ProposeFailedCountAdd(kind ClusterKind, v int64) {
 	case ClusterKindSlot:
 	}
 }
+
+func (c *clusterMetrics) ApplyLagRecord(kind ClusterKind, v int64) {
+	switch kind {
+	case ClusterKindChannel:
+		c.channelApplyLag.Record(c.ctx, v)
+	case ClusterKindSlot:
+		c.slotApplyLag.Record(c.ctx, v)
+	}
+}
+
+func (c *clusterMetrics) ProposeThrottledCountAdd(kind ClusterKind, v int64) {
+	switch kind {
+	case ClusterKindChannel:
+		c.channelProposeThrottleCount.Add(v)
+	case ClusterKindSlot:
+		c.slotProposeThrottleCount.Add(v)
+	}
+}
+
+func (c *clusterMetrics) DiskFreeBytesSet(v int64) {
+	c.diskFreeBytes.Store(v)
+}
+
+func (c *clusterMetrics) DiskReadOnlySet(readOnly bool) {
+	if readOnly {
+		c.diskReadOnly.Store(1)
+	} else {
+		c.diskReadOnly.Store(0)
+	}
+}
+
+func (c *clusterMetrics) DiskFullRejectedCountAdd(v int64) {
+	c.diskFullRejectedCount.Add(v)
+}
+
+func (c *clusterMetrics) ElectionPauseUntilSet(until time.Time) {
+	if until.IsZero() {
+		c.electionPauseUntil.Store(0)
+		return
+	}
+	c.electionPauseUntil.Store(until.UnixNano())
+}
+
+func (c *clusterMetrics) InboundDroppedCountAdd(kind ClusterKind, v int64) {
+	c.inboundDroppedCount.add(kind, v)
+}
+
+func (c *clusterMetrics) InboundThrottledCountAdd(kind ClusterKind, v int64) {
+	c.inboundThrottledCount.add(kind, v)
+}
+
+func (c *clusterMetrics) MessageTooLargeDroppedCountAdd(kind ClusterKind, v int64) {
+	c.messageTooLargeDroppedCount.add(kind, v)
+}
+
+func (c *clusterMetrics) ProposeResultBackpressureCountAdd(kind ClusterKind, v int64) {
+	c.proposeResultBackpressureCount.add(kind, v)
+}
+
+func (c *clusterMetrics) CommitWaitersAdd(kind ClusterKind, v int64) {
+	switch kind {
+	case ClusterKindChannel:
+		c.channelCommitWaiters.Add(c.ctx, v)
+	case ClusterKindSlot:
+	}
+}
+
+func (c *clusterMetrics) CommitWaitGapRecord(kind ClusterKind, v int64) {
+	switch kind {
+	case ClusterKindChannel:
+		for {
+			old := c.channelCommitWaitGapMax.Load()
+			if v <= old || c.channelCommitWaitGapMax.CompareAndSwap(old, v) {
+				return
+			}
+		}
+	case ClusterKindSlot:
+	}
+}
+
+// kindCounter 按ClusterKind分别计数的计数器，观测时带上kind属性，可以按槽、频道、配置区分流量
+type kindCounter struct {
+	counts [clusterKindCount]atomic.Int64
+}
+
+func (k *kindCounter) add(kind ClusterKind, v int64) {
+	if kind < 0 || int(kind) >= len(k.counts) {
+		kind = ClusterKindUnknown
+	}
+	k.counts[kind].Add(v)
+}
+
+func (k *kindCounter) load(kind ClusterKind) int64 {
+	return k.counts[kind].Load()
+}
+
+func (k *kindCounter) observe(obs metric.Observer, counter metric.Int64ObservableCounter) {
+	for i := range k.counts {
+		kind := ClusterKind(i)
+		obs.ObserveInt64(counter, k.counts[i].Load(), metric.WithAttributes(attribute.String("kind", kind.String())))
+	}
+}
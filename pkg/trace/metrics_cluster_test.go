@@ -0,0 +1,251 @@
+package trace
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestKindCounter(t *testing.T) {
+	var k kindCounter
+	k.add(ClusterKindChannel, 10)
+	k.add(ClusterKindSlot, 2)
+	k.add(ClusterKindChannel, 5)
+	k.add(ClusterKindConfig, 1)
+	k.add(ClusterKind(100), 3) // 未知的类型归到unknown
+
+	assert.Equal(t, int64(15), k.load(ClusterKindChannel))
+	assert.Equal(t, int64(2), k.load(ClusterKindSlot))
+	assert.Equal(t, int64(1), k.load(ClusterKindConfig))
+	assert.Equal(t, int64(3), k.load(ClusterKindUnknown))
+}
+
+func TestClusterKindString(t *testing.T) {
+	assert.Equal(t, "slot", ClusterKindSlot.String())
+	assert.Equal(t, "channel", ClusterKindChannel.String())
+	assert.Equal(t, "config", ClusterKindConfig.String())
+	assert.Equal(t, "unknown", ClusterKindUnknown.String())
+}
+
+func TestProposeCommitSecondsHistogram(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	provider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	defer func() {
+		_ = provider.Shutdown(context.Background())
+	}()
+
+	h, err := newProposeCommitSecondsHistogram(provider.Meter("test"), "cluster_channel_propose_commit_seconds")
+	require.NoError(t, err)
+	h.Record(context.Background(), 0.003)
+	h.Record(context.Background(), 0.2)
+	h.Record(context.Background(), 3)
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	require.Len(t, rm.ScopeMetrics, 1)
+	require.Len(t, rm.ScopeMetrics[0].Metrics, 1)
+	assert.Equal(t, "s", rm.ScopeMetrics[0].Metrics[0].Unit)
+
+	data, ok := rm.ScopeMetrics[0].Metrics[0].Data.(metricdata.Histogram[float64])
+	require.True(t, ok)
+	require.Len(t, data.DataPoints, 1)
+	dp := data.DataPoints[0]
+	assert.Equal(t, uint64(3), dp.Count)
+	assert.Equal(t, proposeCommitSecondsBuckets, dp.Bounds)
+	assert.Equal(t, uint64(1), dp.BucketCounts[2])  // (0.0025, 0.005]
+	assert.Equal(t, uint64(1), dp.BucketCounts[7])  // (0.1, 0.25]
+	assert.Equal(t, uint64(1), dp.BucketCounts[11]) // (2.5, 5]
+}
+
+func TestRecvPacketCounters(t *testing.T) {
+	c := &clusterMetrics{}
+	c.RecvPacketIncomingBytesAdd(100)
+	c.RecvPacketIncomingBytesAdd(20)
+	c.RecvPacketIncomingCountAdd(2)
+	c.RecvPacketOutgoingBytesAdd(50)
+	c.RecvPacketOutgoingCountAdd(1)
+
+	assert.Equal(t, int64(120), c.recvPacketIncomingBytes.Load())
+	assert.Equal(t, int64(2), c.recvPacketIncomingCount.Load())
+	assert.Equal(t, int64(50), c.recvPacketOutgoingBytes.Load())
+	assert.Equal(t, int64(1), c.recvPacketOutgoingCount.Load())
+}
+
+func TestMessageCountersByKind(t *testing.T) {
+	c := &clusterMetrics{}
+	c.MessageIncomingBytesAdd(ClusterKindSlot, 100)
+	c.MessageIncomingBytesAdd(ClusterKindChannel, 30)
+	c.MessageIncomingCountAdd(ClusterKindSlot, 1)
+	c.MessageIncomingCountAdd(ClusterKindChannel, 1)
+	c.MessageOutgoingBytesAdd(ClusterKindChannel, 40)
+	c.MessageOutgoingCountAdd(ClusterKindConfig, 2)
+
+	assert.Equal(t, int64(100), c.messageIncomingBytes.load(ClusterKindSlot))
+	assert.Equal(t, int64(30), c.messageIncomingBytes.load(ClusterKindChannel))
+	assert.Equal(t, int64(1), c.messageIncomingCount.load(ClusterKindSlot))
+	assert.Equal(t, int64(1), c.messageIncomingCount.load(ClusterKindChannel))
+	assert.Equal(t, int64(40), c.messageOutgoingBytes.load(ClusterKindChannel))
+	assert.Equal(t, int64(0), c.messageOutgoingBytes.load(ClusterKindSlot))
+	assert.Equal(t, int64(2), c.messageOutgoingCount.load(ClusterKindConfig))
+
+	// 频道的影子计数保持不变
+	assert.Equal(t, int64(30), c.channelMsgIncomingBytes.Load())
+	assert.Equal(t, int64(40), c.channelMsgOutgoingBytes.Load())
+}
+
+func TestLeaderTermStartIndexCounters(t *testing.T) {
+	c := &clusterMetrics{}
+	c.MsgLeaderTermStartIndexReqOutgoingCountAdd(ClusterKindChannel, 1)
+	c.MsgLeaderTermStartIndexReqOutgoingBytesAdd(ClusterKindChannel, 32)
+	c.MsgLeaderTermStartIndexReqIncomingCountAdd(ClusterKindSlot, 1)
+	c.MsgLeaderTermStartIndexReqIncomingBytesAdd(ClusterKindSlot, 30)
+	c.MsgLeaderTermStartIndexRespOutgoingCountAdd(ClusterKindSlot, 1)
+	c.MsgLeaderTermStartIndexRespOutgoingBytesAdd(ClusterKindSlot, 8)
+	c.MsgLeaderTermStartIndexRespIncomingCountAdd(ClusterKindChannel, 2)
+	c.MsgLeaderTermStartIndexRespIncomingBytesAdd(ClusterKindChannel, 16)
+
+	assert.Equal(t, int64(1), c.leaderTermStartIndexReqOutgoingCount.load(ClusterKindChannel))
+	assert.Equal(t, int64(32), c.leaderTermStartIndexReqOutgoingBytes.load(ClusterKindChannel))
+	assert.Equal(t, int64(1), c.leaderTermStartIndexReqIncomingCount.load(ClusterKindSlot))
+	assert.Equal(t, int64(30), c.leaderTermStartIndexReqIncomingBytes.load(ClusterKindSlot))
+	assert.Equal(t, int64(1), c.leaderTermStartIndexRespOutgoingCount.load(ClusterKindSlot))
+	assert.Equal(t, int64(8), c.leaderTermStartIndexRespOutgoingBytes.load(ClusterKindSlot))
+	assert.Equal(t, int64(2), c.leaderTermStartIndexRespIncomingCount.load(ClusterKindChannel))
+	assert.Equal(t, int64(16), c.leaderTermStartIndexRespIncomingBytes.load(ClusterKindChannel))
+
+	assert.Equal(t, int64(0), c.leaderTermStartIndexReqOutgoingCount.load(ClusterKindSlot))
+}
+
+func TestForwardProposeCounters(t *testing.T) {
+	c := &clusterMetrics{}
+	c.ForwardProposeCountAdd(1)
+	c.ForwardProposeBytesAdd(128)
+	c.ForwardProposeCountAdd(1)
+	c.ForwardProposeBytesAdd(64)
+	c.ForwardProposeRespCountAdd(1)
+	c.ForwardProposeRespBytesAdd(16)
+
+	assert.Equal(t, int64(2), c.forwardProposeCount.Load())
+	assert.Equal(t, int64(192), c.forwardProposeBytes.Load())
+	assert.Equal(t, int64(1), c.forwardProposeRespCount.Load())
+	assert.Equal(t, int64(16), c.forwardProposeRespBytes.Load())
+}
+
+func TestChannelElectionCounters(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	provider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	defer func() {
+		_ = provider.Shutdown(context.Background())
+	}()
+	m := provider.Meter("test")
+
+	c := &clusterMetrics{ctx: context.Background()}
+	var err error
+	c.channelElectionCount, err = m.Int64Counter("cluster_channel_election_count")
+	require.NoError(t, err)
+	c.channelElectionSuccessCount, err = m.Int64Counter("cluster_channel_election_success_count")
+	require.NoError(t, err)
+	c.channelElectionFailCount, err = m.Int64Counter("cluster_channel_election_fail_count")
+	require.NoError(t, err)
+
+	c.ChannelElectionCountAdd(2, 1)
+	c.ChannelElectionCountAdd(2, 1)
+	c.ChannelElectionCountAdd(1, 1)
+	c.ChannelElectionSuccessCountAdd(2, 1)
+	c.ChannelElectionFailCountAdd(2, 1)
+	c.ChannelElectionFailCountAdd(1, 1)
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	require.Len(t, rm.ScopeMetrics, 1)
+
+	counts := make(map[string]map[int64]int64)
+	for _, mt := range rm.ScopeMetrics[0].Metrics {
+		data, ok := mt.Data.(metricdata.Sum[int64])
+		require.True(t, ok)
+		counts[mt.Name] = make(map[int64]int64)
+		for _, dp := range data.DataPoints {
+			channelType, _ := dp.Attributes.Value("channelType")
+			counts[mt.Name][channelType.AsInt64()] = dp.Value
+		}
+	}
+	assert.Equal(t, map[int64]int64{2: 2, 1: 1}, counts["cluster_channel_election_count"])
+	assert.Equal(t, map[int64]int64{2: 1}, counts["cluster_channel_election_success_count"])
+	assert.Equal(t, map[int64]int64{2: 1, 1: 1}, counts["cluster_channel_election_fail_count"])
+}
+
+func TestSlotElectionCounters(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	provider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	defer func() {
+		_ = provider.Shutdown(context.Background())
+	}()
+	m := provider.Meter("test")
+
+	c := &clusterMetrics{ctx: context.Background()}
+	var err error
+	c.slotElectionCount, err = m.Int64Counter("cluster_slot_election_count")
+	require.NoError(t, err)
+	c.slotElectionSuccessCount, err = m.Int64Counter("cluster_slot_election_success_count")
+	require.NoError(t, err)
+	c.slotElectionFailCount, err = m.Int64Counter("cluster_slot_election_fail_count")
+	require.NoError(t, err)
+
+	c.SlotElectionCountAdd(3)
+	c.SlotElectionSuccessCountAdd(2)
+	c.SlotElectionFailCountAdd(1)
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	require.Len(t, rm.ScopeMetrics, 1)
+
+	counts := make(map[string]int64)
+	for _, mt := range rm.ScopeMetrics[0].Metrics {
+		data, ok := mt.Data.(metricdata.Sum[int64])
+		require.True(t, ok)
+		require.Len(t, data.DataPoints, 1)
+		counts[mt.Name] = data.DataPoints[0].Value
+	}
+	assert.Equal(t, map[string]int64{
+		"cluster_slot_election_count":         3,
+		"cluster_slot_election_success_count": 2,
+		"cluster_slot_election_fail_count":    1,
+	}, counts)
+}
+
+func TestCommitWaitMetrics(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	provider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	defer func() {
+		_ = provider.Shutdown(context.Background())
+	}()
+	m := provider.Meter("test")
+
+	c := &clusterMetrics{ctx: context.Background()}
+	var err error
+	c.channelCommitWaiters, err = m.Int64UpDownCounter("cluster_channel_commit_waiters")
+	require.NoError(t, err)
+
+	c.CommitWaitersAdd(ClusterKindChannel, 3)
+	c.CommitWaitersAdd(ClusterKindChannel, -1)
+	c.CommitWaitersAdd(ClusterKindSlot, 5) // 只统计频道
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	require.Len(t, rm.ScopeMetrics, 1)
+	data, ok := rm.ScopeMetrics[0].Metrics[0].Data.(metricdata.Sum[int64])
+	require.True(t, ok)
+	assert.Equal(t, int64(2), data.DataPoints[0].Value)
+
+	// 差距取观测周期内的最大值，观测后重新统计
+	c.CommitWaitGapRecord(ClusterKindChannel, 5)
+	c.CommitWaitGapRecord(ClusterKindChannel, 20)
+	c.CommitWaitGapRecord(ClusterKindChannel, 8)
+	c.CommitWaitGapRecord(ClusterKindSlot, 100)
+	assert.Equal(t, int64(20), c.channelCommitWaitGapMax.Swap(0))
+	assert.Equal(t, int64(0), c.channelCommitWaitGapMax.Load())
+}
@@ -297,9 +297,10 @@ func (d *metrics) requestAndFillAppMetrics(label string, filterId string, rg v1.
 }
 
 func (d *metrics) requestAndFillClusterMetrics(label string, rg v1.Range, rate bool, resps *[]*clusterMetricsResp) {
-	query := `rate(` + label + `[1m])`
+	// 部分指标带有kind属性（槽、频道、配置），这里按节点汇总
+	query := `sum by (id) (rate(` + label + `[1m]))`
 	if !rate {
-		query = label
+		query = `sum by (id) (` + label + `)`
 	}
 	countValue, err := d.opts.requestPrometheus(query, rg)
 	if err != nil {
@@ -0,0 +1,33 @@
+package trace
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// logSizeBuckets 日志数据大小的分桶（字节），用于观察消息大小分布，指导批量、压缩和大日志阈值的配置
+var logSizeBuckets = []float64{64, 256, 1024, 4096, 16384, 65536, 262144, 1048576}
+
+// logSizeHistogram 提案日志数据大小的直方图（按频道类型）
+type logSizeHistogram struct {
+	h metric.Int64Histogram
+}
+
+func newLogSizeHistogram(m metric.Meter) *logSizeHistogram {
+	h, err := m.Int64Histogram(
+		"cluster_propose_log_size",
+		metric.WithDescription("The data size of proposed logs"),
+		metric.WithUnit("By"),
+		metric.WithExplicitBucketBoundaries(logSizeBuckets...),
+	)
+	if err != nil {
+		panic(err)
+	}
+	return &logSizeHistogram{h: h}
+}
+
+func (l *logSizeHistogram) record(ctx context.Context, channelType uint8, size int64) {
+	l.h.Record(ctx, size, metric.WithAttributes(attribute.Int("channelType", int(channelType))))
+}
@@ -0,0 +1,49 @@
+package trace
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestLogSizeHistogram(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	provider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	defer func() {
+		_ = provider.Shutdown(context.Background())
+	}()
+
+	h := newLogSizeHistogram(provider.Meter("test"))
+	h.record(context.Background(), 2, 100)
+	h.record(context.Background(), 2, 2000)
+	h.record(context.Background(), 1, 10)
+
+	var rm metricdata.ResourceMetrics
+	err := reader.Collect(context.Background(), &rm)
+	require.NoError(t, err)
+	require.Len(t, rm.ScopeMetrics, 1)
+	require.Len(t, rm.ScopeMetrics[0].Metrics, 1)
+
+	data, ok := rm.ScopeMetrics[0].Metrics[0].Data.(metricdata.Histogram[int64])
+	require.True(t, ok)
+	require.Len(t, data.DataPoints, 2)
+
+	for _, dp := range data.DataPoints {
+		channelType, _ := dp.Attributes.Value("channelType")
+		switch channelType.AsInt64() {
+		case 2:
+			require.Equal(t, uint64(2), dp.Count)
+			require.Equal(t, int64(2100), dp.Sum)
+			require.Equal(t, uint64(1), dp.BucketCounts[1]) // (64, 256]
+			require.Equal(t, uint64(1), dp.BucketCounts[3]) // (1024, 4096]
+		case 1:
+			require.Equal(t, uint64(1), dp.Count)
+			require.Equal(t, uint64(1), dp.BucketCounts[0]) // [0, 64]
+		default:
+			t.Fatalf("unexpected channelType %d", channelType.AsInt64())
+		}
+	}
+}
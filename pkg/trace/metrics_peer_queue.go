@@ -0,0 +1,78 @@
+package trace
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// PeerSendQueueStat 发往某个节点的发送队列状态
+type PeerSendQueueStat struct {
+	NodeId        uint64
+	Depth         int64         // 排队中的消息数量
+	QueuedBytes   int64         // 排队中的消息大小
+	InflightBytes int64         // 已从队列取出，正在发送的消息大小
+	OldestAge     time.Duration // 队列中最早的消息已经等待的时间
+}
+
+// peerSendQueue 每个节点的发送队列监控（节点id作为属性）
+// 观测时才通过source获取各个节点的队列状态，发送路径上不需要额外上报
+type peerSendQueue struct {
+	mu     sync.RWMutex
+	source func() []PeerSendQueueStat
+
+	depth         metric.Int64ObservableGauge
+	queuedBytes   metric.Int64ObservableGauge
+	inflightBytes metric.Int64ObservableGauge
+	oldestAge     metric.Int64ObservableGauge
+}
+
+func newPeerSendQueue(m metric.Meter) *peerSendQueue {
+	p := &peerSendQueue{}
+	var err error
+	if p.depth, err = m.Int64ObservableGauge("cluster_peer_send_queue_depth"); err != nil {
+		panic(err)
+	}
+	if p.queuedBytes, err = m.Int64ObservableGauge("cluster_peer_send_queue_bytes"); err != nil {
+		panic(err)
+	}
+	if p.inflightBytes, err = m.Int64ObservableGauge("cluster_peer_send_inflight_bytes"); err != nil {
+		panic(err)
+	}
+	if p.oldestAge, err = m.Int64ObservableGauge("cluster_peer_send_queue_oldest_age", metric.WithUnit("ms")); err != nil {
+		panic(err)
+	}
+	_, err = m.RegisterCallback(func(ctx context.Context, obs metric.Observer) error {
+		p.observe(obs)
+		return nil
+	}, p.depth, p.queuedBytes, p.inflightBytes, p.oldestAge)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+func (p *peerSendQueue) setSource(f func() []PeerSendQueueStat) {
+	p.mu.Lock()
+	p.source = f
+	p.mu.Unlock()
+}
+
+func (p *peerSendQueue) observe(obs metric.Observer) {
+	p.mu.RLock()
+	source := p.source
+	p.mu.RUnlock()
+	if source == nil {
+		return
+	}
+	for _, st := range source() {
+		attrs := metric.WithAttributes(attribute.Int64("nodeId", int64(st.NodeId)))
+		obs.ObserveInt64(p.depth, st.Depth, attrs)
+		obs.ObserveInt64(p.queuedBytes, st.QueuedBytes, attrs)
+		obs.ObserveInt64(p.inflightBytes, st.InflightBytes, attrs)
+		obs.ObserveInt64(p.oldestAge, st.OldestAge.Milliseconds(), attrs)
+	}
+}
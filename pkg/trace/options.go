@@ -19,6 +19,13 @@ type Options struct {
 	ServiceHostName  string
 	PrometheusApiUrl string
 	ReqTimeout       time.Duration
+	// ChannelTopN 单独统计消息数量的频道个数（最繁忙的N个），其他频道合并到other，0表示不统计
+	// 频道级别的时间序列最多为 ChannelTopN + 1 条
+	ChannelTopN int
+	// ChannelTopNInterval 重新计算topN频道的间隔
+	ChannelTopNInterval time.Duration
+	// LogSizeMetricsOn 是否统计提案日志数据大小的分布（直方图），默认关闭
+	LogSizeMetricsOn bool
 
 	prometheusClient api.Client // prometheus client
 	prometheusApi    v1.API
@@ -26,12 +33,14 @@ type Options struct {
 
 func NewOptions(opt ...Option) *Options {
 	opts := &Options{
-		TraceOn:          false,
-		Endpoint:         "127.0.0.1:4318",
-		ServiceName:      "wukongim",
-		ServiceHostName:  "wukongim",
-		PrometheusApiUrl: "http://127.0.0.1:9090",
-		ReqTimeout:       5 * time.Second,
+		TraceOn:             false,
+		Endpoint:            "127.0.0.1:4318",
+		ServiceName:         "wukongim",
+		ServiceHostName:     "wukongim",
+		PrometheusApiUrl:    "http://127.0.0.1:9090",
+		ReqTimeout:          5 * time.Second,
+		ChannelTopN:         10,
+		ChannelTopNInterval: time.Minute,
 	}
 
 	for _, o := range opt {
@@ -73,6 +82,24 @@ func WithReqTimeout(timeout time.Duration) Option {
 	}
 }
 
+func WithChannelTopN(n int) Option {
+	return func(o *Options) {
+		o.ChannelTopN = n
+	}
+}
+
+func WithChannelTopNInterval(interval time.Duration) Option {
+	return func(o *Options) {
+		o.ChannelTopNInterval = interval
+	}
+}
+
+func WithLogSizeMetricsOn(on bool) Option {
+	return func(o *Options) {
+		o.LogSizeMetricsOn = on
+	}
+}
+
 func WithTraceOn(on bool) Option {
 	return func(o *Options) {
 		o.TraceOn = on
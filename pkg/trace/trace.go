@@ -4,9 +4,12 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"strconv"
+	"sync"
 
 	"github.com/WuKongIM/WuKongIM/pkg/wkhttp"
 	"github.com/WuKongIM/WuKongIM/pkg/wklog"
+	"github.com/WuKongIM/WuKongIM/pkg/wkutil"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
@@ -21,8 +24,18 @@ func SetGlobalTrace(t *Trace) {
 
 var (
 	tracer = otel.Tracer("trace")
+	// 按频道类型区分的tracer（频道类型数量有限，频道id只作为属性，避免tracer数量爆炸）
+	channelTracers sync.Map
 )
 
+func channelTracer(channelType uint8) trace.Tracer {
+	if t, ok := channelTracers.Load(channelType); ok {
+		return t.(trace.Tracer)
+	}
+	t, _ := channelTracers.LoadOrStore(channelType, otel.Tracer("trace.channel."+strconv.Itoa(int(channelType))))
+	return t.(trace.Tracer)
+}
+
 type Trace struct {
 	opts     *Options
 	ctx      context.Context
@@ -82,6 +95,45 @@ func (t *Trace) StartSpan(ctx context.Context, name string) (context.Context, Sp
 	}
 }
 
+// StartChannelSpan 开始一个频道相关的span，span会带上channelKey和channelType属性，方便在追踪后台按频道过滤
+func (t *Trace) StartChannelSpan(ctx context.Context, name string, channelId string, channelType uint8) (context.Context, Span) {
+	if !t.opts.TraceOn {
+		return ctx, emptySpan
+	}
+	ctx, span := channelTracer(channelType).Start(ctx, name, trace.WithAttributes(ChannelAttributes(channelId, channelType)...))
+	return ctx, defaultSpan{
+		Span: span,
+	}
+}
+
+// StartChannelSpanWithLinks 开始一个频道相关的span，并链接到links里的span（例如投递span链接回消息的提案span）
+func (t *Trace) StartChannelSpanWithLinks(ctx context.Context, name string, channelId string, channelType uint8, links ...SpanContext) (context.Context, Span) {
+	if !t.opts.TraceOn {
+		return ctx, emptySpan
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	spanLinks := make([]trace.Link, 0, len(links))
+	for _, link := range links {
+		if link.IsValid() {
+			spanLinks = append(spanLinks, trace.Link{SpanContext: link.SpanContext})
+		}
+	}
+	ctx, span := channelTracer(channelType).Start(ctx, name, trace.WithAttributes(ChannelAttributes(channelId, channelType)...), trace.WithLinks(spanLinks...))
+	return ctx, defaultSpan{
+		Span: span,
+	}
+}
+
+// ChannelAttributes 频道相关的span属性
+func ChannelAttributes(channelId string, channelType uint8) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("channelKey", wkutil.ChannelToKey(channelId, channelType)),
+		attribute.Int("channelType", int(channelType)),
+	}
+}
+
 type Span interface {
 	trace.Span
 	SetInt(key string, value int)
@@ -155,6 +207,16 @@ func SpanFromContext(ctx context.Context) Span {
 	}
 }
 
+// SpanContextFromContext 获取ctx里的span上下文（trace id和span id）
+func SpanContextFromContext(ctx context.Context) SpanContext {
+	if !GlobalTrace.opts.TraceOn || ctx == nil {
+		return emptySpanContext
+	}
+	return SpanContext{
+		SpanContext: trace.SpanContextFromContext(ctx),
+	}
+}
+
 func ContextWithRemoteSpanContext(ctx context.Context, sc SpanContext) context.Context {
 	if !GlobalTrace.opts.TraceOn {
 		return context.Background()
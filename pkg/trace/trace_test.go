@@ -29,3 +29,12 @@ func TestTraceStartSpan(t *testing.T) {
 	time.Sleep(time.Second * 10)
 
 }
+
+func TestChannelAttributes(t *testing.T) {
+	attrs := trace.ChannelAttributes("g1", 2)
+	require.Len(t, attrs, 2)
+	require.Equal(t, "channelKey", string(attrs[0].Key))
+	require.Equal(t, "2&g1", attrs[0].Value.AsString())
+	require.Equal(t, "channelType", string(attrs[1].Key))
+	require.Equal(t, int64(2), attrs[1].Value.AsInt64())
+}
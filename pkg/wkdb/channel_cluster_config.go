@@ -156,6 +156,28 @@ func (wk *wukongDB) GetChannelClusterConfigs(offsetId uint64, limit int) ([]Chan
 
 }
 
+func (wk *wukongDB) IterateChannelClusterConfigs(fnc func(cfg ChannelClusterConfig) error) error {
+	// 基于快照遍历，遍历期间的写入不影响读到的数据，也不会阻塞写入
+	snapshot := wk.defaultShardDB().NewSnapshot()
+	defer snapshot.Close()
+
+	iter := snapshot.NewIter(&pebble.IterOptions{
+		LowerBound: key.NewChannelClusterConfigColumnKey(0, key.MinColumnKey),
+		UpperBound: key.NewChannelClusterConfigColumnKey(math.MaxUint64, key.MaxColumnKey),
+	})
+	defer iter.Close()
+
+	var fncErr error
+	err := wk.iteratorChannelClusterConfig(iter, func(cfg ChannelClusterConfig) bool {
+		fncErr = fnc(cfg)
+		return fncErr == nil
+	})
+	if err != nil {
+		return err
+	}
+	return fncErr
+}
+
 func (wk *wukongDB) SearchChannelClusterConfig(req ChannelClusterConfigSearchReq, filter ...func(cfg ChannelClusterConfig) bool) ([]ChannelClusterConfig, error) {
 	if req.ChannelId != "" {
 		cfg, err := wk.GetChannelClusterConfig(req.ChannelId, req.ChannelType)
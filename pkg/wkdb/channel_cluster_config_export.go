@@ -0,0 +1,70 @@
+package wkdb
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// 导出数据的最大单条配置大小，防止导入时读到损坏的数据分配过大的内存
+const maxExportChannelClusterConfigSize = 1024 * 1024
+
+var ErrInvalidChannelClusterConfigExport = errors.New("invalid channel cluster config export data")
+
+// ExportChannelClusterConfigs 将所有频道的分布式配置按id顺序写到w（每条格式：4字节长度 + 配置数据），返回导出的数量
+func ExportChannelClusterConfigs(db ChannelClusterConfigDB, w io.Writer) (int, error) {
+	count := 0
+	lenBytes := make([]byte, 4)
+	err := db.IterateChannelClusterConfigs(func(cfg ChannelClusterConfig) error {
+		data, err := cfg.Marshal()
+		if err != nil {
+			return err
+		}
+		binary.BigEndian.PutUint32(lenBytes, uint32(len(data)))
+		if _, err = w.Write(lenBytes); err != nil {
+			return err
+		}
+		if _, err = w.Write(data); err != nil {
+			return err
+		}
+		count++
+		return nil
+	})
+	return count, err
+}
+
+// ImportChannelClusterConfigs 从r中读取ExportChannelClusterConfigs导出的频道分布式配置，每读到一条调用一次fnc，返回导入的数量
+func ImportChannelClusterConfigs(r io.Reader, fnc func(cfg ChannelClusterConfig) error) (int, error) {
+	count := 0
+	lenBytes := make([]byte, 4)
+	for {
+		if _, err := io.ReadFull(r, lenBytes); err != nil {
+			if err == io.EOF {
+				return count, nil
+			}
+			if err == io.ErrUnexpectedEOF {
+				return count, ErrInvalidChannelClusterConfigExport
+			}
+			return count, err
+		}
+		size := binary.BigEndian.Uint32(lenBytes)
+		if size == 0 || size > maxExportChannelClusterConfigSize {
+			return count, ErrInvalidChannelClusterConfigExport
+		}
+		data := make([]byte, size)
+		if _, err := io.ReadFull(r, data); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				return count, ErrInvalidChannelClusterConfigExport
+			}
+			return count, err
+		}
+		cfg := ChannelClusterConfig{}
+		if err := cfg.Unmarshal(data); err != nil {
+			return count, err
+		}
+		if err := fnc(cfg); err != nil {
+			return count, err
+		}
+		count++
+	}
+}
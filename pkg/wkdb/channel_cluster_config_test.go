@@ -1,6 +1,8 @@
 package wkdb_test
 
 import (
+	"bytes"
+	"fmt"
 	"testing"
 	"time"
 
@@ -164,3 +166,69 @@ func TestDeleteChannelClusterConfig(t *testing.T) {
 	assert.ErrorIs(t, err, wkdb.ErrNotFound)
 
 }
+
+func TestExportImportChannelClusterConfigs(t *testing.T) {
+	d := newTestDB(t)
+	err := d.Open()
+	assert.NoError(t, err)
+	defer func() {
+		err := d.Close()
+		assert.NoError(t, err)
+	}()
+
+	createdAt := time.Unix(1700000000, 0)
+	updatedAt := time.Unix(1700000001, 0)
+	for i := 0; i < 10; i++ {
+		err = d.SaveChannelClusterConfig(wkdb.ChannelClusterConfig{
+			ChannelId:       fmt.Sprintf("channel%d", i),
+			ChannelType:     uint8(i%2 + 1),
+			ReplicaMaxCount: 3,
+			Replicas:        []uint64{1, 2, 3},
+			Learners:        []uint64{4},
+			LeaderId:        uint64(i%3 + 1),
+			Term:            uint32(i + 1),
+			ConfVersion:     uint64(i),
+			CreatedAt:       &createdAt,
+			UpdatedAt:       &updatedAt,
+		})
+		assert.NoError(t, err)
+	}
+
+	buff := bytes.NewBuffer(nil)
+	count, err := wkdb.ExportChannelClusterConfigs(d, buff)
+	assert.NoError(t, err)
+	assert.Equal(t, 10, count)
+
+	// 导入到新的数据库
+	d2 := newTestDB(t)
+	err = d2.Open()
+	assert.NoError(t, err)
+	defer func() {
+		err := d2.Close()
+		assert.NoError(t, err)
+	}()
+	count, err = wkdb.ImportChannelClusterConfigs(buff, d2.SaveChannelClusterConfig)
+	assert.NoError(t, err)
+	assert.Equal(t, 10, count)
+
+	// 两边的配置和顺序一致
+	exported, err := d.GetChannelClusterConfigs(0, 100)
+	assert.NoError(t, err)
+	imported, err := d2.GetChannelClusterConfigs(0, 100)
+	assert.NoError(t, err)
+	assert.Equal(t, len(exported), len(imported))
+	for i := range exported {
+		assert.Equal(t, exported[i].Id, imported[i].Id)
+		assert.Equal(t, exported[i].ChannelId, imported[i].ChannelId)
+		assert.Equal(t, exported[i].ChannelType, imported[i].ChannelType)
+		assert.Equal(t, exported[i].Replicas, imported[i].Replicas)
+		assert.Equal(t, exported[i].Learners, imported[i].Learners)
+		assert.Equal(t, exported[i].LeaderId, imported[i].LeaderId)
+		assert.Equal(t, exported[i].Term, imported[i].Term)
+		assert.Equal(t, exported[i].ConfVersion, imported[i].ConfVersion)
+	}
+
+	// 损坏的数据
+	_, err = wkdb.ImportChannelClusterConfigs(bytes.NewReader([]byte{0, 0, 0, 10, 1}), d2.SaveChannelClusterConfig)
+	assert.Equal(t, wkdb.ErrInvalidChannelClusterConfigExport, err)
+}
@@ -229,6 +229,9 @@ type ChannelClusterConfigDB interface {
 	// GetChannelClusterConfigs 获取频道的分布式配置
 	GetChannelClusterConfigs(offsetId uint64, limit int) ([]ChannelClusterConfig, error)
 
+	// IterateChannelClusterConfigs 基于快照按id顺序遍历所有频道的分布式配置，fnc返回错误时停止遍历并返回此错误
+	IterateChannelClusterConfigs(fnc func(cfg ChannelClusterConfig) error) error
+
 	// GetChannelClusterConfigCountWithSlotId 获取某个槽的频道的分布式配置数量
 	GetChannelClusterConfigCountWithSlotId(slotId uint32) (int, error)
 
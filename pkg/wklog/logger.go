@@ -136,6 +136,21 @@ func Debug(msg string, fields ...zap.Field) {
 
 }
 
+// DebugEnabled 是否输出Debug日志，高频路径打日志前先判断，避免日志关闭时还要构造字段
+func DebugEnabled() bool {
+	return atom.Enabled(zapcore.DebugLevel)
+}
+
+// Level 当前的日志级别
+func Level() zapcore.Level {
+	return atom.Level()
+}
+
+// SetLevel 动态调整日志级别，不需要重新配置日志
+func SetLevel(level zapcore.Level) {
+	atom.SetLevel(level)
+}
+
 // Error Error
 func Error(msg string, fields ...zap.Field) {
 	if errorLogger == nil {
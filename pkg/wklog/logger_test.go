@@ -16,3 +16,33 @@ func TestLogger(t *testing.T) {
 	Debug("this is debug")
 	Error("this is error", zap.String("key", "value"))
 }
+
+func TestDebugEnabled(t *testing.T) {
+	opts := NewOptions()
+	opts.LogDir = t.TempDir()
+	opts.Level = zap.InfoLevel
+	Configure(opts)
+	if DebugEnabled() {
+		t.Fatal("debug should be disabled at info level")
+	}
+
+	opts.Level = zap.DebugLevel
+	Configure(opts)
+	if !DebugEnabled() {
+		t.Fatal("debug should be enabled at debug level")
+	}
+}
+
+func TestSetLevel(t *testing.T) {
+	prevLevel := Level()
+	defer SetLevel(prevLevel)
+
+	SetLevel(zap.InfoLevel)
+	if DebugEnabled() {
+		t.Fatal("debug should be disabled at info level")
+	}
+	SetLevel(zap.DebugLevel)
+	if !DebugEnabled() {
+		t.Fatal("debug should be enabled at debug level")
+	}
+}